@@ -33,6 +33,7 @@ package backend
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"reflect"
 
@@ -56,6 +57,12 @@ type Handler interface {
 
 	// Save saves the passed Session to the session store.
 	SaveSession(Session) error
+
+	// Header returns the value of the given request header, if any.
+	Header(string) string
+
+	// RemoteAddr returns the IP address of the client.
+	RemoteAddr() string
 }
 
 // FiberHandler is a fiber based implementation of the Handler interface.
@@ -89,6 +96,18 @@ func (h *FiberHandler) Context() context.Context {
 	return h.Ctx.Context()
 }
 
+// Header implements the Handler Header method by calling the Get method
+// of the attached *fiber.Ctx.
+func (h *FiberHandler) Header(key string) string {
+	return h.Ctx.Get(key)
+}
+
+// RemoteAddr implements the Handler RemoteAddr method by calling the IP
+// method of the attached *fiber.Ctx.
+func (h *FiberHandler) RemoteAddr() string {
+	return h.Ctx.IP()
+}
+
 // Load implements the SessionStore interface for the FiberSessionStore type.
 func (h *FiberHandler) LoadSession(id string) (Session, error) {
 	return NewFiberSession(id, h.Ctx.Cookies(id))
@@ -141,6 +160,22 @@ func (h *NetHandler) Context() context.Context {
 	return h.r.Context()
 }
 
+// Header implements the Handler Header method by returning the header
+// of the attached *http.Request.
+func (h *NetHandler) Header(key string) string {
+	return h.r.Header.Get(key)
+}
+
+// RemoteAddr implements the Handler RemoteAddr method by returning the
+// host of the RemoteAddr of the attached *http.Request.
+func (h *NetHandler) RemoteAddr() string {
+	host, _, err := net.SplitHostPort(h.r.RemoteAddr)
+	if err != nil {
+		return h.r.RemoteAddr
+	}
+	return host
+}
+
 // Get implements the SessionStore interface for the GorillaSessionStore type.
 func (h *NetHandler) LoadSession(id string) (Session, error) {
 	sess, err := h.store.Get(h.r, id)
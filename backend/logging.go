@@ -0,0 +1,229 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Logging constants.
+const (
+	RequestIDHeader = "X-Request-ID"          // Header used to propagate request IDs between services.
+	traceHeader     = "X-Cloud-Trace-Context" // Header set by App Engine, i.e., TRACE_ID/SPAN_ID;o=OPTIONS.
+)
+
+// Log attribute keys, which are common to all services so that Cloud
+// Logging queries can correlate log lines, e.g.,
+// jsonPayload.request_id="...".
+const (
+	KeyRequestID = "request_id"
+	KeySite      = "skey"
+	KeyBroadcast = "broadcast_id"
+	keyTrace     = "logging.googleapis.com/trace"
+)
+
+// SetupLogging sets the default slog logger, which also handles
+// output from the log package. In App Engine mode, i.e., when json is
+// true, logs are JSON lines using Cloud Logging's field names,
+// otherwise logs are text. In both cases, log lines include any
+// attributes added to the context with WithRequestID, WithSite and
+// WithBroadcast.
+func SetupLogging(json bool) {
+	slog.SetDefault(slog.New(NewLogHandler(os.Stderr, json)))
+}
+
+// NewLogHandler returns a slog.Handler that writes to w, in JSON or
+// text format, and adds context attributes to each record.
+func NewLogHandler(w io.Writer, json bool) slog.Handler {
+	var h slog.Handler
+	if json {
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{ReplaceAttr: cloudLoggingAttr})
+	} else {
+		h = slog.NewTextHandler(w, nil)
+	}
+	return &contextHandler{h}
+}
+
+// cloudLoggingAttr renames the standard slog attributes to those
+// expected by Cloud Logging.
+func cloudLoggingAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) != 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		a.Key = "severity"
+		if lvl, ok := a.Value.Any().(slog.Level); ok && lvl == slog.LevelWarn {
+			a.Value = slog.StringValue("WARNING")
+		}
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.TimeKey:
+		a.Key = "timestamp"
+	}
+	return a
+}
+
+// attrsKey is the context key for log attributes.
+type attrsKey struct{}
+
+// contextHandler is a slog.Handler that adds the log attributes in
+// the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context attributes to a record then handles it.
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a new handler with the given attributes.
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a new handler with the given group.
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
+
+// WithLogAttrs returns a copy of ctx with log attributes added, which
+// are included in each log line logged with the context.
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, attrsKey{}, append(prev[:len(prev):len(prev)], attrs...))
+}
+
+// WithRequestID returns a copy of ctx with the request ID log attribute.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return WithLogAttrs(ctx, slog.String(KeyRequestID, id))
+}
+
+// WithSite returns a copy of ctx with the site key log attribute.
+func WithSite(ctx context.Context, skey int64) context.Context {
+	return WithLogAttrs(ctx, slog.Int64(KeySite, skey))
+}
+
+// WithBroadcast returns a copy of ctx with the broadcast ID log attribute.
+func WithBroadcast(ctx context.Context, id string) context.Context {
+	return WithLogAttrs(ctx, slog.String(KeyBroadcast, id))
+}
+
+// RequestID returns the request ID in ctx, if any.
+func RequestID(ctx context.Context) string {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	for _, a := range attrs {
+		if a.Key == KeyRequestID {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+// Logger returns a logger for ctx, which is useful for packages that
+// take a *slog.Logger or log.Println-like output function.
+func Logger(ctx context.Context) *slog.Logger {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return slog.Default().With(args...)
+}
+
+// Printf logs like log.Printf, at the info level with the attributes
+// in ctx.
+func Printf(ctx context.Context, format string, v ...any) {
+	slog.InfoContext(ctx, fmt.Sprintf(format, v...))
+}
+
+// LogFunc returns a log.Println-like function that logs at the info
+// level with the attributes in ctx.
+func LogFunc(ctx context.Context) func(v ...any) {
+	return func(v ...any) {
+		slog.InfoContext(ctx, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	}
+}
+
+// requestContext returns a context for a request with the request ID
+// and trace attributes, given the request headers. The request ID is
+// that of the calling service, if any, else the App Engine trace ID,
+// else a new ID.
+func requestContext(ctx context.Context, projectID, requestID, trace string) (context.Context, string) {
+	traceID, _, _ := strings.Cut(trace, "/")
+	id := requestID
+	if id == "" {
+		id = traceID
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+	ctx = WithRequestID(ctx, id)
+	if projectID != "" && traceID != "" {
+		ctx = WithLogAttrs(ctx, slog.String(keyTrace, "projects/"+projectID+"/traces/"+traceID))
+	}
+	return ctx, id
+}
+
+// RequestIDs returns middleware that adds the request ID, and App
+// Engine trace for the given project, to the request context and
+// response header. Services should be wrapped with this first, so
+// that all log lines for a request include the request ID.
+func RequestIDs(projectID string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, id := requestContext(r.Context(), projectID, r.Header.Get(RequestIDHeader), r.Header.Get(traceHeader))
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FiberRequestIDs is the Fiber equivalent of RequestIDs. Handlers
+// should log with c.UserContext().
+func FiberRequestIDs(projectID string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, id := requestContext(c.UserContext(), projectID, c.Get(RequestIDHeader), c.Get(traceHeader))
+		c.Set(RequestIDHeader, id)
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// PropagateRequestID sets the request ID header of an outgoing
+// request to another service, so that the other service logs with the
+// same request ID.
+func PropagateRequestID(ctx context.Context, req *http.Request) {
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLogging tests that log lines include context attributes in
+// Cloud Logging format.
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(&buf, true))
+
+	ctx := WithBroadcast(WithSite(WithRequestID(context.Background(), "abc"), 3), "xyz")
+	logger.WarnContext(ctx, "broadcast unhealthy")
+
+	var got map[string]any
+	err := json.Unmarshal(buf.Bytes(), &got)
+	assert.Nil(t, err)
+	assert.Equal(t, "WARNING", got["severity"])
+	assert.Equal(t, "broadcast unhealthy", got["message"])
+	assert.Equal(t, "abc", got[KeyRequestID])
+	assert.Equal(t, 3.0, got[KeySite])
+	assert.Equal(t, "xyz", got[KeyBroadcast])
+	assert.Equal(t, "abc", RequestID(ctx))
+
+	// Adding attributes does not modify the parent context.
+	WithSite(ctx, 4)
+	buf.Reset()
+	logger.InfoContext(WithRequestID(context.Background(), "def"), "ok")
+	assert.Contains(t, buf.String(), `"request_id":"def"`)
+	assert.NotContains(t, buf.String(), KeySite)
+}
+
+// TestRequestIDs tests the request ID middleware.
+func TestRequestIDs(t *testing.T) {
+	var got string
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestID(r.Context())
+		out, _ := http.NewRequest("GET", "http://oceantv/broadcast/save", nil)
+		PropagateRequestID(r.Context(), out)
+		assert.Equal(t, got, out.Header.Get(RequestIDHeader))
+	}), RequestIDs("oceanbench"))
+
+	tests := []struct {
+		header, value, want string
+	}{
+		{header: RequestIDHeader, value: "abc", want: "abc"},
+		{header: traceHeader, value: "105445aa7843bc8bf206b12000100000/1;o=1", want: "105445aa7843bc8bf206b12000100000"},
+		{},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		h.ServeHTTP(w, r)
+		if test.want == "" {
+			assert.NotEmpty(t, got)
+		} else {
+			assert.Equal(t, test.want, got)
+		}
+		assert.Equal(t, got, w.Header().Get(RequestIDHeader))
+	}
+
+	app := fiber.New()
+	app.Use(FiberRequestIDs("ausoceantv"))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString(RequestID(c.UserContext())) })
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(RequestIDHeader, "abc")
+	resp, err := app.Test(r, -1)
+	assert.Nil(t, err)
+	b, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", string(b))
+	assert.Equal(t, "abc", resp.Header.Get(RequestIDHeader))
+}
@@ -0,0 +1,288 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	runtime "runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ausocean/cloud/utils"
+)
+
+// Middleware wraps a net/http handler.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps a handler with middleware, with the first middleware
+// being outermost, i.e., called first.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Error is a structured error response, which is written in JSON
+// format, e.g., {"code":400,"error":"missing ma param"}.
+type Error struct {
+	Code    int    `json:"code"`  // HTTP status code.
+	Message string `json:"error"` // Error message.
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError returns an Error with the given HTTP status code and
+// formatted message.
+func NewError(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// toError converts err to an Error, with the given code unless err
+// is already an Error or a fiber.Error.
+func toError(code int, err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return &Error{Code: fe.Code, Message: fe.Message}
+	}
+	return &Error{Code: code, Message: err.Error()}
+}
+
+// WriteError logs an error then writes it as a structured error
+// response, with the given HTTP status code unless err is an Error.
+func WriteError(w http.ResponseWriter, code int, err error) {
+	e := toError(code, err)
+	log.Printf("%d error: %s", e.Code, e.Message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Code)
+	json.NewEncoder(w).Encode(e)
+}
+
+// FiberErrorHandler is a fiber.Config ErrorHandler that writes errors
+// returned by handlers as structured error responses. Errors other
+// than Error and fiber.Error are internal server errors.
+func FiberErrorHandler(c *fiber.Ctx, err error) error {
+	e := toError(fiber.StatusInternalServerError, err)
+	log.Printf("%d error: %s", e.Code, e.Message)
+	return c.Status(e.Code).JSON(e)
+}
+
+// requestURI returns the path and query, if any, of a request.
+func requestURI(path, query string) string {
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+// LogRequests returns middleware that logs requests if enabled,
+// typically in debug or standalone mode, since App Engine logs
+// requests automatically.
+func LogRequests(enabled bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slog.InfoContext(r.Context(), requestURI(r.URL.Path, r.URL.RawQuery))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FiberLogRequests is the Fiber equivalent of LogRequests.
+func FiberLogRequests(enabled bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if enabled {
+			slog.InfoContext(c.UserContext(), requestURI(c.Path(), string(c.Request().URI().QueryString())))
+		}
+		return c.Next()
+	}
+}
+
+// Recover returns middleware that recovers from panics in handlers.
+// The panic is passed to handle, if any, which may log it, send
+// notifications and write an error, as configured with
+// utils.NewConfigurableRecoveryHandler. Panics that are not handled
+// are logged with a stack trace and result in an internal server
+// error.
+func Recover(handle utils.RecoveryHandler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v) // Let net/http abort the response.
+				}
+				if handle != nil && handle(w, v) {
+					return
+				}
+				slog.ErrorContext(r.Context(), fmt.Sprintf("panic: %v, stack: %s", v, runtime.Stack()))
+				WriteError(w, http.StatusInternalServerError, fmt.Errorf("panic: %v", v))
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FiberRecover is the Fiber equivalent of Recover.
+func FiberRecover(handle utils.RecoveryHandler) fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if handle != nil && handle(&fiberResponseWriter{c: c}, v) {
+				return
+			}
+			slog.ErrorContext(c.UserContext(), fmt.Sprintf("panic: %v, stack: %s", v, runtime.Stack()))
+			err = NewError(fiber.StatusInternalServerError, "panic: %v", v)
+		}()
+		return c.Next()
+	}
+}
+
+// fiberResponseWriter adapts a fiber.Ctx to an http.ResponseWriter,
+// so that recovery handlers may write errors.
+type fiberResponseWriter struct {
+	c      *fiber.Ctx
+	header http.Header
+}
+
+// Header returns the response header.
+func (w *fiberResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+// WriteHeader sets the status code and header.
+func (w *fiberResponseWriter) WriteHeader(code int) {
+	for k, vs := range w.header {
+		for _, v := range vs {
+			w.c.Set(k, v)
+		}
+	}
+	w.c.Status(code)
+}
+
+// Write writes the response body.
+func (w *fiberResponseWriter) Write(b []byte) (int, error) {
+	return w.c.Write(b)
+}
+
+// CORSPolicy defines which cross-origin requests are allowed.
+type CORSPolicy struct {
+	Origins []string      // Allowed origins, or "*" for any origin.
+	Methods []string      // Allowed methods, defaulting to GET, POST and OPTIONS.
+	Headers []string      // Allowed request headers (optional).
+	MaxAge  time.Duration // How long preflight responses may be cached (optional).
+}
+
+// headers returns the CORS response headers for a request from the
+// given origin, or nil if the origin is not allowed.
+func (p *CORSPolicy) headers(origin string) map[string]string {
+	if origin == "" {
+		return nil
+	}
+	allowed := "*"
+	if !slices.Contains(p.Origins, "*") {
+		if !slices.Contains(p.Origins, origin) {
+			return nil
+		}
+		allowed = origin
+	}
+	methods := p.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	}
+	h := map[string]string{
+		"Access-Control-Allow-Origin":  allowed,
+		"Access-Control-Allow-Methods": strings.Join(methods, ", "),
+	}
+	if allowed != "*" {
+		h["Vary"] = "Origin"
+	}
+	if len(p.Headers) != 0 {
+		h["Access-Control-Allow-Headers"] = strings.Join(p.Headers, ", ")
+	}
+	if p.MaxAge > 0 {
+		h["Access-Control-Max-Age"] = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+	return h
+}
+
+// preflight returns true if a request is a CORS preflight request.
+func preflight(method, reqMethod string) bool {
+	return method == http.MethodOptions && reqMethod != ""
+}
+
+// CORS returns middleware that applies a CORS policy. Preflight
+// requests from allowed origins are answered without calling the
+// handler.
+func CORS(p CORSPolicy) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := p.headers(r.Header.Get("Origin"))
+			for k, v := range h {
+				w.Header().Set(k, v)
+			}
+			if h != nil && preflight(r.Method, r.Header.Get("Access-Control-Request-Method")) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FiberCORS is the Fiber equivalent of CORS.
+func FiberCORS(p CORSPolicy) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		h := p.headers(c.Get(fiber.HeaderOrigin))
+		for k, v := range h {
+			c.Set(k, v)
+		}
+		if h != nil && preflight(c.Method(), c.Get(fiber.HeaderAccessControlRequestMethod)) {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		return c.Next()
+	}
+}
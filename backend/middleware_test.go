@@ -0,0 +1,112 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backend
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMiddleware tests the net/http middleware.
+func TestMiddleware(t *testing.T) {
+	var notified bool
+	recovery := func(w http.ResponseWriter, v any) bool {
+		notified = true
+		return false
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) })
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, http.StatusBadRequest, errors.New("missing ma param"))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) { panic("oops") })
+	h := Chain(mux, Recover(recovery), LogRequests(true), CORS(CORSPolicy{Origins: []string{"https://bench.ausocean.org"}}))
+
+	tests := []struct {
+		method string
+		path   string
+		origin string
+		code   int
+		body   string
+		cors   string
+	}{
+		{method: "GET", path: "/ok", code: http.StatusOK, body: "OK"},
+		{method: "GET", path: "/ok", origin: "https://bench.ausocean.org", code: http.StatusOK, body: "OK", cors: "https://bench.ausocean.org"},
+		{method: "GET", path: "/ok", origin: "https://example.com", code: http.StatusOK, body: "OK"},
+		{method: "OPTIONS", path: "/ok", origin: "https://bench.ausocean.org", code: http.StatusNoContent, cors: "https://bench.ausocean.org"},
+		{method: "GET", path: "/error", code: http.StatusBadRequest, body: `{"code":400,"error":"missing ma param"}` + "\n"},
+		{method: "GET", path: "/panic", code: http.StatusInternalServerError, body: `{"code":500,"error":"panic: oops"}` + "\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if test.method == "OPTIONS" {
+			r.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		h.ServeHTTP(w, r)
+		assert.Equal(t, test.code, w.Code, "%s %s", test.method, test.path)
+		assert.Equal(t, test.body, w.Body.String(), "%s %s", test.method, test.path)
+		assert.Equal(t, test.cors, w.Header().Get("Access-Control-Allow-Origin"), "%s %s", test.method, test.path)
+	}
+	assert.True(t, notified, "recovery handler not called")
+}
+
+// TestFiberMiddleware tests the Fiber middleware.
+func TestFiberMiddleware(t *testing.T) {
+	recovery := func(w http.ResponseWriter, v any) bool {
+		http.Error(w, "recovered", http.StatusServiceUnavailable)
+		return true
+	}
+	app := fiber.New(fiber.Config{ErrorHandler: FiberErrorHandler})
+	app.Use(FiberRecover(recovery), FiberLogRequests(true), FiberCORS(CORSPolicy{Origins: []string{"*"}}))
+	app.Get("/ok", func(c *fiber.Ctx) error { return c.SendString("OK") })
+	app.Get("/error", func(c *fiber.Ctx) error { return NewError(fiber.StatusNotFound, "no such site %d", 3) })
+	app.Get("/panic", func(c *fiber.Ctx) error { panic("oops") })
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{path: "/ok", code: http.StatusOK, body: "OK"},
+		{path: "/error", code: http.StatusNotFound, body: `{"code":404,"error":"no such site 3"}`},
+		{path: "/missing", code: http.StatusNotFound, body: `{"code":404,"error":"Cannot GET /missing"}`},
+		{path: "/panic", code: http.StatusServiceUnavailable, body: "recovered"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		r.Header.Set("Origin", "https://example.com")
+		resp, err := app.Test(r, -1)
+		assert.Nil(t, err)
+		b, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, test.code, resp.StatusCode, test.path)
+		assert.Equal(t, test.body, strings.TrimSpace(string(b)), test.path)
+		assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"), test.path)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Health and readiness probe paths.
+const (
+	HealthPath    = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// Server is an HTTP server that shuts down gracefully upon SIGTERM or
+// SIGINT. It serves a health probe, which succeeds while the server is
+// running, and a readiness probe, which fails once shutdown begins so
+// that load balancers stop routing requests to it. In-flight requests
+// are then drained before the shutdown hooks are called, which may be
+// used to flush state.
+type Server struct {
+	srv     *http.Server
+	timeout time.Duration                 // Maximum time to drain connections and call hooks.
+	delay   time.Duration                 // Time between failing readiness and draining.
+	checks  []func(context.Context) error // Readiness checks.
+	hooks   []func(context.Context) error // Shutdown hooks, called in order.
+	closing atomic.Bool                   // True once shutdown begins.
+}
+
+// ServerOption is a functional option for NewServer.
+type ServerOption func(*Server)
+
+// WithShutdownTimeout sets the maximum time to drain connections and
+// call the shutdown hooks, which defaults to 10 seconds.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(s *Server) { s.timeout = d }
+}
+
+// WithDrainDelay sets the time between the readiness probe failing and
+// connections being drained, giving load balancers time to notice.
+func WithDrainDelay(d time.Duration) ServerOption {
+	return func(s *Server) { s.delay = d }
+}
+
+// WithReadinessCheck adds a check to the readiness probe, e.g., to
+// check that a datastore is reachable.
+func WithReadinessCheck(check func(context.Context) error) ServerOption {
+	return func(s *Server) { s.checks = append(s.checks, check) }
+}
+
+// WithShutdownHook adds a function to be called after connections are
+// drained, e.g., to save state. Hooks are called in the order added.
+func WithShutdownHook(hook func(context.Context) error) ServerOption {
+	return func(s *Server) { s.hooks = append(s.hooks, hook) }
+}
+
+// NewServer returns a server for the given address and handler, which
+// serves the health and readiness probes in addition to h.
+func NewServer(addr string, h http.Handler, opts ...ServerOption) *Server {
+	s := &Server{timeout: defaultShutdownTimeout}
+	for _, opt := range opts {
+		opt(s)
+	}
+	probes := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case HealthPath:
+			s.healthHandler(w, r)
+		case ReadinessPath:
+			s.readinessHandler(w, r)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+	s.srv = &http.Server{Addr: addr, Handler: probes}
+	return s
+}
+
+// ListenAndServe listens on the server's address then serves requests
+// until ctx is done or a SIGTERM or SIGINT is received, whereupon the
+// server is shut down gracefully. It returns nil upon a clean shutdown.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve is like ListenAndServe, but serves requests on the given
+// listener.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() { errs <- s.srv.Serve(l) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	return s.shutdown(errs)
+}
+
+// shutdown fails readiness, drains connections then calls the shutdown
+// hooks, all within the shutdown timeout. Hooks are called even if
+// draining times out, so that state is saved regardless.
+func (s *Server) shutdown(errs <-chan error) error {
+	log.Printf("shutting down")
+	s.closing.Store(true)
+	time.Sleep(s.delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var errList []error
+	err := s.srv.Shutdown(ctx)
+	if err != nil {
+		errList = append(errList, fmt.Errorf("could not drain connections: %w", err))
+	}
+	err = <-errs
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errList = append(errList, err)
+	}
+	for _, hook := range s.hooks {
+		err := hook(ctx)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("shutdown hook failed: %w", err))
+		}
+	}
+	if len(errList) == 0 {
+		log.Printf("shut down cleanly")
+	}
+	return errors.Join(errList...)
+}
+
+// healthHandler responds OK while the server is running.
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// readinessHandler responds OK if the server is not shutting down and
+// all readiness checks pass, else it responds with a 503 error.
+func (s *Server) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if s.closing.Load() {
+		WriteError(w, http.StatusServiceUnavailable, errors.New("shutting down"))
+		return
+	}
+	for _, check := range s.checks {
+		err := check(r.Context())
+		if err != nil {
+			WriteError(w, http.StatusServiceUnavailable, fmt.Errorf("not ready: %w", err))
+			return
+		}
+	}
+	w.Write([]byte("OK"))
+}
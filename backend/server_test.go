@@ -0,0 +1,111 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestServer tests the health and readiness probes and that in-flight
+// requests are drained before the shutdown hooks are called.
+func TestServer(t *testing.T) {
+	var ready bool
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var events []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		events = append(events, "request")
+		w.Write([]byte("done"))
+	})
+	s := NewServer("", mux,
+		WithReadinessCheck(func(ctx context.Context) error {
+			if !ready {
+				return errors.New("datastore unavailable")
+			}
+			return nil
+		}),
+		WithShutdownHook(func(ctx context.Context) error {
+			events = append(events, "hook")
+			return nil
+		}),
+	)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	url := "http://" + l.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(url + path)
+		if err != nil {
+			t.Fatalf("could not get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, _ := get(HealthPath); code != http.StatusOK {
+		t.Errorf("got health code %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := get(ReadinessPath); code != http.StatusServiceUnavailable {
+		t.Errorf("got readiness code %d before ready, want %d", code, http.StatusServiceUnavailable)
+	}
+	ready = true
+	if code, _ := get(ReadinessPath); code != http.StatusOK {
+		t.Errorf("got readiness code %d when ready, want %d", code, http.StatusOK)
+	}
+
+	// Shut down during a slow request, which should complete.
+	slow := make(chan string, 1)
+	go func() {
+		_, body := get("/slow")
+		slow <- body
+	}()
+	<-started
+	cancel()
+	for !s.closing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if body := <-slow; body != "done" {
+		t.Errorf("got slow response %q, want %q", body, "done")
+	}
+	err = <-done
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if len(events) != 2 || events[0] != "request" || events[1] != "hook" {
+		t.Errorf("got events %v, want [request hook]", events)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+DESCRIPTION
+  Viewer analytics for AusOcean TV.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// Analytics constants.
+const (
+	maxPlaybackEvents = 100            // Maximum number of events per request.
+	maxWatchedSeconds = 5 * 60         // Maximum seconds watched per request.
+	engagementFlush   = time.Minute    // Period between writes of engagement to the datastore.
+	engagementDays    = 30             // Default period of engagement reports in days.
+	engagementDay     = 24 * time.Hour // Engagement is counted per day (UTC).
+)
+
+// Playback event types.
+const (
+	playbackPlay    = "play"    // Playback started or resumed.
+	playbackPause   = "pause"   // Playback paused.
+	playbackQuality = "quality" // Playback quality changed.
+	playbackWatched = "watched" // Seconds watched since the last watched event.
+)
+
+// playbackEvent is an event reported by the web player.
+type playbackEvent struct {
+	Type    string `json:"type"`              // Event type, e.g., play.
+	Seconds int64  `json:"seconds,omitempty"` // Seconds watched, for watched events.
+}
+
+// engagementKey identifies a feed's engagement for a day.
+type engagementKey struct {
+	feed int64
+	day  time.Time // Start of the day, UTC.
+}
+
+// engagementCounter counts feed engagement in memory and writes it to
+// the datastore periodically, rather than on every request, so that
+// frequent playback events do not result in frequent datastore writes.
+// Engagement is also written when the instance shuts down.
+type engagementCounter struct {
+	mu     sync.Mutex
+	counts map[engagementKey]*model.FeedEngagement
+}
+
+// analyticsHandler handles playback events from the web player, which
+// are posted in batches as JSON of the form:
+//
+//	{"events":[{"type":"play"},{"type":"watched","seconds":30}]}
+//
+// Requests must carry a playback token for the feed, so that only
+// viewers can report events, and guests are distinguished from
+// subscribers. Events are counted towards the feed's engagement for
+// the day. Seconds watched are clamped to maxWatchedSeconds per
+// request, which exceeds the player's reporting period, so that a
+// misbehaving player cannot inflate engagement.
+func (svc *service) analyticsHandler(c *fiber.Ctx) error {
+	pc := c.Locals(playbackClaimsKey).(*playbackClaims)
+	var req struct {
+		Events []playbackEvent `json:"events"`
+	}
+	err := json.Unmarshal(c.Body(), &req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid events: %v", err))
+	}
+	if len(req.Events) > maxPlaybackEvents {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("too many events, maximum is %d", maxPlaybackEvents))
+	}
+
+	var e model.FeedEngagement
+	for _, ev := range req.Events {
+		switch ev.Type {
+		case playbackPlay:
+			e.Plays++
+		case playbackPause:
+			e.Pauses++
+		case playbackQuality:
+			e.QualityChanges++
+		case playbackWatched:
+			if ev.Seconds < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid seconds watched: %d", ev.Seconds))
+			}
+			e.Watched = min(e.Watched+ev.Seconds, maxWatchedSeconds)
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "invalid event type: "+ev.Type)
+		}
+	}
+	if pc.Subscriber == 0 {
+		e.GuestWatched = e.Watched
+	}
+	svc.engagement.count(pc.Feed, &e)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// count counts engagement with a feed.
+func (ec *engagementCounter) count(feed int64, e *model.FeedEngagement) {
+	k := engagementKey{feed, time.Now().UTC().Truncate(engagementDay)}
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if ec.counts == nil {
+		ec.counts = make(map[engagementKey]*model.FeedEngagement)
+	}
+	c, ok := ec.counts[k]
+	if !ok {
+		c = &model.FeedEngagement{Feed: feed, Date: k.day.Format(model.FeedEngagementDateFormat)}
+		ec.counts[k] = c
+	}
+	c.Add(e)
+}
+
+// flushPeriodically writes engagement every engagementFlush until ctx
+// is done.
+func (ec *engagementCounter) flushPeriodically(ctx context.Context, svc *service) {
+	ticker := time.NewTicker(engagementFlush)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = ec.flush(context.Background(), svc)
+		}
+	}
+}
+
+// flush adds counted engagement to the datastore. Engagement that
+// cannot be written is retained for the next flush, and an error is
+// returned.
+func (ec *engagementCounter) flush(ctx context.Context, svc *service) error {
+	ec.mu.Lock()
+	pending := ec.counts
+	ec.counts = make(map[engagementKey]*model.FeedEngagement)
+	ec.mu.Unlock()
+
+	var errs []error
+	for k, e := range pending {
+		err := model.AddFeedEngagement(ctx, svc.settingsStore, e)
+		if err == nil {
+			continue
+		}
+		log.Errorf("unable to add engagement for feed %d: %v", k.feed, err)
+		errs = append(errs, err)
+		ec.mu.Lock()
+		r, ok := ec.counts[k]
+		if ok {
+			r.Add(e)
+		} else {
+			ec.counts[k] = e
+		}
+		ec.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// engagementHandler handles admin requests for feed engagement over
+// the last days days (default 30). The response contains the daily
+// engagement with each feed, and the totals per feed.
+func (svc *service) engagementHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	days, err := formInt(c, "days", engagementDays)
+	if err != nil || days <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid days")
+	}
+	now := time.Now().UTC()
+	from := now.AddDate(0, 0, -int(days)+1).Format(model.FeedEngagementDateFormat)
+	to := now.Format(model.FeedEngagementDateFormat)
+	daily, err := model.GetFeedEngagement(context.Background(), svc.settingsStore, from, to)
+	if err != nil {
+		return fmt.Errorf("unable to get feed engagement: %w", err)
+	}
+
+	totals := make(map[int64]*model.FeedEngagement)
+	for i := range daily {
+		t, ok := totals[daily[i].Feed]
+		if !ok {
+			t = &model.FeedEngagement{Feed: daily[i].Feed}
+			totals[daily[i].Feed] = t
+		}
+		t.Add(&daily[i])
+	}
+	feeds := make([]model.FeedEngagement, 0, len(totals))
+	for _, t := range totals {
+		feeds = append(feeds, *t)
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].Watched > feeds[j].Watched })
+
+	return c.JSON(struct {
+		From  string                 `json:"from"`
+		To    string                 `json:"to"`
+		Feeds []model.FeedEngagement `json:"feeds"` // Totals per feed, most watched first.
+		Daily []model.FeedEngagement `json:"daily"` // Engagement per feed per day.
+	}{from, to, feeds, daily})
+}
@@ -0,0 +1,308 @@
+/*
+DESCRIPTION
+  Announcement emails from AusOcean TV to subscribers, e.g., of new
+  streams or maintenance.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// announceBatch is the number of subscribers processed between
+// updates of an announcement's progress.
+const announceBatch = 20
+
+// unsubscribeKeySecret is the secret used to sign unsubscribe links.
+const unsubscribeKeySecret = "unsubscribeKey"
+
+// setupAnnouncements gets the secret used to sign unsubscribe links.
+// Without it, announcements cannot be sent.
+func (svc *service) setupAnnouncements(ctx context.Context) {
+	key, err := gauth.GetSecret(ctx, projectID, unsubscribeKeySecret)
+	if err != nil {
+		log.Errorf("unable to get %s secret, announcements will not work: %v", unsubscribeKeySecret, err)
+		return
+	}
+	svc.unsubscribeKey = []byte(key)
+}
+
+// announceHandler handles requests to create an announcement and start
+// sending it. The following form values are accepted:
+//
+// - subject: email subject (required).
+// - body: email body, in plain text (required).
+// - area: comma-separated areas of interest of the audience (optional).
+// - class: comma-separated subscription classes of the audience (optional).
+//
+// The announcement is sent in the background to active subscribers
+// matching the audience. Each email includes a link to unsubscribe
+// from announcements. The response is the announcement in JSON
+// format, whose progress can be followed with announcementHandler.
+func (svc *service) announceHandler(c *fiber.Ctx) error {
+	p, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	if svc.unsubscribeKey == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "unsubscribe links not configured")
+	}
+	a := &model.Announcement{
+		Subject: strings.TrimSpace(c.FormValue("subject")),
+		Body:    c.FormValue("body"),
+		Areas:   splitList(c.FormValue("area")),
+		Classes: splitList(c.FormValue("class")),
+		Creator: p.Email,
+	}
+	if a.Subject == "" || strings.TrimSpace(a.Body) == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "subject and body required")
+	}
+	for _, class := range a.Classes {
+		if !slices.Contains([]string{model.SubscriptionDay, model.SubscriptionMonth, model.SubscriptionYear}, class) {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid class: "+class)
+		}
+	}
+
+	err = model.CreateAnnouncement(context.Background(), svc.settingsStore, a)
+	if err != nil {
+		return fmt.Errorf("unable to create announcement: %w", err)
+	}
+	log.Infof("%s created announcement %d: %s", p.Email, a.ID, a.Subject)
+	go svc.sendAnnouncement(context.Background(), a.ID, c.BaseURL())
+	return c.JSON(a)
+}
+
+// resumeAnnouncementHandler handles requests to resume sending an
+// announcement that was interrupted, e.g., by an instance shutting
+// down. Sending resumes after the last subscriber processed.
+func (svc *service) resumeAnnouncementHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	a, err := svc.getAnnouncement(c)
+	if err != nil {
+		return err
+	}
+	if a.Status == model.AnnouncementSent {
+		return fiber.NewError(fiber.StatusConflict, "announcement already sent")
+	}
+	go svc.sendAnnouncement(context.Background(), a.ID, c.BaseURL())
+	return c.JSON(a)
+}
+
+// announcementHandler handles requests for an announcement, including
+// its send progress.
+func (svc *service) announcementHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	a, err := svc.getAnnouncement(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(a)
+}
+
+// listAnnouncementsHandler handles requests to list announcements,
+// most recent first.
+func (svc *service) listAnnouncementsHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	as, err := model.GetAnnouncements(context.Background(), svc.settingsStore)
+	if err != nil {
+		return fmt.Errorf("unable to get announcements: %w", err)
+	}
+	return c.JSON(as)
+}
+
+// getAnnouncement returns the announcement identified by the id route
+// parameter.
+func (svc *service) getAnnouncement(c *fiber.Ctx) (*model.Announcement, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid id")
+	}
+	a, err := model.GetAnnouncement(context.Background(), svc.settingsStore, id)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "announcement not found")
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get announcement: %w", err)
+	}
+	return a, nil
+}
+
+// sendAnnouncement sends an announcement to its audience, starting
+// after the last subscriber processed, and updating its progress every
+// announceBatch subscribers. Only one send of an announcement runs at
+// a time per instance. Unsubscribe links are relative to baseURL.
+func (svc *service) sendAnnouncement(ctx context.Context, id int64, baseURL string) {
+	_, sending := svc.announcing.LoadOrStore(id, true)
+	if sending {
+		return
+	}
+	defer svc.announcing.Delete(id)
+
+	a, err := model.UpdateAnnouncement(ctx, svc.settingsStore, id, func(a *model.Announcement) { a.Status = model.AnnouncementSending })
+	if err != nil {
+		log.Errorf("unable to start announcement %d: %v", id, err)
+		return
+	}
+	send, err := svc.emailer(ctx)
+	if err != nil {
+		log.Errorf("unable to send announcement %d: %v", id, err)
+		return
+	}
+	subs, err := model.GetSubscribers(ctx, svc.settingsStore)
+	if err != nil {
+		log.Errorf("unable to get subscribers for announcement %d: %v", id, err)
+		return
+	}
+
+	var progress model.Announcement
+	update := func(status string) {
+		_, err := model.UpdateAnnouncement(ctx, svc.settingsStore, id, func(a *model.Announcement) {
+			a.Status = status
+			a.Cursor = progress.Cursor
+			a.Sent += progress.Sent
+			a.Failed += progress.Failed
+			a.Skipped += progress.Skipped
+		})
+		if err != nil {
+			log.Errorf("unable to update announcement %d: %v", id, err)
+			return
+		}
+		progress.Sent, progress.Failed, progress.Skipped = 0, 0, 0
+	}
+
+	now := time.Now()
+	n := 0
+	for i := range subs {
+		s := &subs[i]
+		if s.ID <= a.Cursor {
+			continue
+		}
+		progress.Cursor = s.ID
+		n++
+		ss, err := model.GetSubscriptions(ctx, svc.settingsStore, s.ID)
+		if err != nil {
+			log.Errorf("unable to get subscriptions for subscriber %d: %v", s.ID, err)
+			progress.Failed++
+		} else if !a.Audience(s, ss, now) {
+			progress.Skipped++
+		} else if err := svc.sendAnnouncementTo(send, a, s, baseURL); err != nil {
+			log.Errorf("unable to send announcement %d to subscriber %d: %v", id, s.ID, err)
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+		if n%announceBatch == 0 {
+			update(model.AnnouncementSending)
+		}
+	}
+	progress.Cursor = max(progress.Cursor, a.Cursor)
+	update(model.AnnouncementSent)
+	log.Infof("sent announcement %d", id)
+}
+
+// sendAnnouncementTo emails an announcement to a subscriber, with a
+// link to unsubscribe.
+func (svc *service) sendAnnouncementTo(send func(to, subject, body string) error, a *model.Announcement, s *model.Subscriber, baseURL string) error {
+	tok, err := gauth.PutClaims(map[string]interface{}{
+		"unsubscribe": strconv.FormatInt(s.ID, 10),
+	}, svc.unsubscribeKey)
+	if err != nil {
+		return fmt.Errorf("unable to sign unsubscribe link: %w", err)
+	}
+	link := baseURL + "/api/v1/unsubscribe?token=" + url.QueryEscape(tok)
+	body := fmt.Sprintf("%s\n\n--\nTo stop receiving AusOcean TV announcements, follow this link:\n%s\n", a.Body, link)
+	return send(s.Email, a.Subject, body)
+}
+
+// confirmUnsubscribeHandler handles unsubscribe links in announcement
+// emails, which are followed with GET requests. The subscriber is not
+// unsubscribed until they confirm, since email scanners may follow
+// links.
+func (svc *service) confirmUnsubscribeHandler(c *fiber.Ctx) error {
+	return sendConfirmPage(c, "Unsubscribe from AusOcean TV", "Stop receiving AusOcean TV announcements?", "Unsubscribe")
+}
+
+// unsubscribeHandler handles the confirmation of an unsubscribe link.
+// Unsubscribing stops announcements, but not emails about the
+// subscriber's subscription, such as failed payments.
+func (svc *service) unsubscribeHandler(c *fiber.Ctx) error {
+	claims, err := gauth.GetClaims(c.FormValue("token"), svc.unsubscribeKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, fmt.Sprintf("invalid unsubscribe link: %v", err))
+	}
+	v, _ := claims["unsubscribe"].(string)
+	sid, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid unsubscribe link")
+	}
+
+	ctx := context.Background()
+	s, err := model.GetSubscriber(ctx, svc.settingsStore, sid)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return fiber.NewError(fiber.StatusNotFound, "subscriber not found")
+	} else if err != nil {
+		return fmt.Errorf("unable to get subscriber %d: %w", sid, err)
+	}
+	if s.Unsubscribed.IsZero() {
+		s.Unsubscribed = time.Now()
+		err = model.UpdateSubscriber(ctx, svc.settingsStore, s)
+		if err != nil {
+			return fmt.Errorf("unable to unsubscribe subscriber %d: %w", sid, err)
+		}
+		log.Infof("subscriber %d unsubscribed from announcements", sid)
+	}
+	return c.SendString("You have been unsubscribed from AusOcean TV announcements.")
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
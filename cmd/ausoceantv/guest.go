@@ -0,0 +1,223 @@
+/*
+DESCRIPTION
+  Guest viewing sessions and conversion tracking for AusOcean TV.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Guest constants.
+const (
+	guestCookie    = "guest"             // Name of the guest session cookie.
+	guestMaxAge    = 30 * 24 * time.Hour // Lifetime of guest sessions.
+	guestAllowance = 10 * time.Minute    // Free viewing per guest.
+	guestTTL       = 5 * time.Minute     // Maximum lifetime of guest playback tokens.
+	guestsPerIP    = 5                   // Maximum guest sessions created per client IP address per hour.
+	funnelDays     = 30                  // Default period of funnel metrics in days.
+)
+
+// guestHandler handles requests to start a guest session, or resume
+// the current one. Guest sessions are signed and stored in a cookie.
+// Clients without a session cookie resume the last session they
+// created, if any, so that clearing cookies does not renew the free
+// viewing allowance, and the creation of sessions is rate limited per
+// client IP address. The response is the remaining free viewing time
+// in seconds.
+func (svc *service) guestHandler(c *fiber.Ctx) error {
+	if svc.playbackKey == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "playback not configured")
+	}
+	ctx := context.Background()
+	g, err := svc.guestSession(c)
+	if err != nil {
+		client := guestClient(c)
+		g, err = model.GetGuestByClient(ctx, svc.settingsStore, client, time.Now().Add(-guestMaxAge))
+		switch {
+		case errors.Is(err, datastore.ErrNoSuchEntity):
+			ok, _, err := model.TakeToken(ctx, svc.settingsStore, "guest.ip."+c.IP(), guestsPerIP/3600.0, guestsPerIP)
+			if err != nil {
+				return fmt.Errorf("unable to rate limit guest sessions: %w", err)
+			}
+			if !ok {
+				return fiber.NewError(fiber.StatusTooManyRequests, "too many guest sessions, try again later")
+			}
+			g, err = model.CreateGuestSession(ctx, svc.settingsStore, client)
+			if err != nil {
+				return fmt.Errorf("unable to create guest session: %w", err)
+			}
+			svc.recordFunnel(ctx, &model.FunnelEvent{Event: model.FunnelGuest, Guest: g.ID})
+		case err != nil:
+			return fmt.Errorf("unable to get guest session for client: %w", err)
+		}
+		tok, err := gauth.PutClaims(map[string]interface{}{
+			"guest": strconv.FormatInt(g.ID, 10),
+			"exp":   g.Created.Add(guestMaxAge).Unix(),
+		}, svc.playbackKey)
+		if err != nil {
+			return fmt.Errorf("unable to sign guest session: %w", err)
+		}
+		maxAge := time.Until(g.Created.Add(guestMaxAge))
+		c.Cookie(&fiber.Cookie{Name: guestCookie, Value: tok, MaxAge: int(maxAge / time.Second), HTTPOnly: true, Secure: !svc.standalone, SameSite: fiber.CookieSameSiteLaxMode})
+	}
+	remaining := max(guestAllowance-time.Duration(g.Viewed)*time.Second, 0)
+	return c.JSON(struct {
+		Remaining int64 `json:"remaining"`
+	}{int64(remaining / time.Second)})
+}
+
+// guestClient returns the identifier of the client of a guest
+// request, which is a hash of its IP address and user agent, so that
+// clients behind the same IP address are usually distinguished
+// without storing the address.
+func guestClient(c *fiber.Ctx) string {
+	h := sha256.Sum256([]byte(c.IP() + "\n" + c.Get(fiber.HeaderUserAgent)))
+	return hex.EncodeToString(h[:16])
+}
+
+// guestSession returns the guest session of a request, if any.
+func (svc *service) guestSession(c *fiber.Ctx) (*model.GuestSession, error) {
+	tok := c.Cookies(guestCookie)
+	if tok == "" {
+		return nil, errors.New("no guest session")
+	}
+	claims, err := gauth.GetClaims(tok, svc.playbackKey)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := claims["guest"].(string)
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid guest claim")
+	}
+	return model.GetGuestSession(context.Background(), svc.settingsStore, id)
+}
+
+// guestPlayback issues a short-lived playback token to a guest for a
+// feed that guests are entitled to, while their free viewing
+// allowance lasts.
+func (svc *service) guestPlayback(c *fiber.Ctx, fid int64) error {
+	g, err := svc.guestSession(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "login or guest session required")
+	}
+	ctx := context.Background()
+	e, err := model.GetEntitlement(ctx, svc.settingsStore, model.SubscriptionGuest)
+	if err != nil {
+		return fmt.Errorf("error getting guest entitlement: %w", err)
+	}
+	if !e.Includes(fid) {
+		return fiber.NewError(fiber.StatusForbidden, model.ErrNotEntitled.Error())
+	}
+	ttl, err := model.GrantGuestViewing(ctx, svc.settingsStore, g.ID, guestTTL, guestAllowance)
+	if err != nil {
+		return fmt.Errorf("unable to grant guest viewing: %w", err)
+	}
+	if ttl == 0 {
+		return fiber.NewError(fiber.StatusPaymentRequired, "free viewing used, please subscribe")
+	}
+
+	tok, exp, err := svc.issuePlaybackToken(0, fid, ttl)
+	if err != nil {
+		return fmt.Errorf("unable to issue playback token: %w", err)
+	}
+	// Views are recorded once per guest and feed, not per token.
+	err = model.CreateFunnelEventOnce(ctx, svc.settingsStore, &model.FunnelEvent{Event: model.FunnelView, Guest: g.ID, Feed: fid})
+	if err != nil {
+		log.Errorf("unable to record %s funnel event: %v", model.FunnelView, err)
+	}
+	return c.JSON(struct {
+		Token   string    `json:"token"`
+		Expires time.Time `json:"expires"`
+		Guest   bool      `json:"guest"`
+	}{tok, exp, true})
+}
+
+// recordSignup records that a user signed up as a subscriber,
+// converting their guest session, if any.
+func (svc *service) recordSignup(c *fiber.Ctx, sub *model.Subscriber) {
+	ctx := context.Background()
+	f := &model.FunnelEvent{Event: model.FunnelSignup, Subscriber: sub.ID}
+	g, err := svc.guestSession(c)
+	if err == nil {
+		f.Guest = g.ID
+		err = model.ConvertGuest(ctx, svc.settingsStore, g.ID, sub.ID)
+		if err != nil {
+			log.Errorf("unable to convert guest %d: %v", g.ID, err)
+		}
+	}
+	svc.recordFunnel(ctx, f)
+}
+
+// recordSubscribe records that a subscriber subscribed, attributing
+// the subscription to the guest session they converted from, if any.
+func (svc *service) recordSubscribe(ctx context.Context, sid int64) {
+	f := &model.FunnelEvent{Event: model.FunnelSubscribe, Subscriber: sid}
+	g, err := model.GetGuestBySubscriber(ctx, svc.settingsStore, sid)
+	if err == nil {
+		f.Guest = g.ID
+	} else if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		log.Errorf("unable to get guest for subscriber %d: %v", sid, err)
+	}
+	svc.recordFunnel(ctx, f)
+}
+
+// recordFunnel records a funnel event. Errors are logged, since
+// funnel metrics are not essential.
+func (svc *service) recordFunnel(ctx context.Context, f *model.FunnelEvent) {
+	err := model.CreateFunnelEvent(ctx, svc.settingsStore, f)
+	if err != nil {
+		log.Errorf("unable to record %s funnel event: %v", f.Event, err)
+	}
+}
+
+// funnelHandler handles admin requests for funnel metrics over the
+// last days days (default 30).
+func (svc *service) funnelHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	days, err := formInt(c, "days", funnelDays)
+	if err != nil || days <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid days")
+	}
+	m, err := model.GetFunnelMetrics(context.Background(), svc.settingsStore, time.Now().AddDate(0, 0, -int(days)))
+	if err != nil {
+		return fmt.Errorf("unable to get funnel metrics: %w", err)
+	}
+	return c.JSON(m)
+}
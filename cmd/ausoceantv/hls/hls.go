@@ -0,0 +1,192 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// Package hls proxies HLS playlists and segments from an upstream
+// source, either an HTTP server such as vidforward or a GCS bucket,
+// so that streams can be served to authorized viewers only. Playlists
+// are rewritten so that the URIs they contain resolve back to the
+// proxy, carrying the viewer's query string, e.g., a playback token.
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+// PlaylistType is the MIME type of HLS playlists.
+const PlaylistType = "application/vnd.apple.mpegurl"
+
+const gsScheme = "gs://"
+
+// Errors.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrInvalidPath = errors.New("invalid path")
+)
+
+// uriAttr matches URI attributes of tags, e.g., in EXT-X-KEY and EXT-X-MAP.
+var uriAttr = regexp.MustCompile(`URI="([^"]*)"`)
+
+// Proxy fetches HLS content from upstream sources.
+type Proxy struct {
+	Client *http.Client // HTTP client, defaulting to http.DefaultClient.
+
+	mu  sync.Mutex
+	gcs *storage.Client // Created on first use.
+}
+
+// Resolve returns the upstream URL of a file with the given path
+// relative to the directory of a source playlist, e.g., a segment or
+// variant playlist. Paths that escape the source directory are
+// rejected.
+func Resolve(source, p string) (string, error) {
+	if p == "" || strings.HasPrefix(p, "/") || strings.Contains(p, "\\") {
+		return "", ErrInvalidPath
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." || elem == "." {
+			return "", ErrInvalidPath
+		}
+	}
+	i := strings.LastIndex(source, "/")
+	if i < 0 || strings.HasSuffix(source[:i+1], "://") {
+		return "", fmt.Errorf("invalid source: %s", source)
+	}
+	return source[:i+1] + p, nil
+}
+
+// IsPlaylist returns true if the path is that of a playlist.
+func IsPlaylist(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".m3u8")
+}
+
+// Open opens an upstream file, returning its content and content type.
+// ErrNotFound is returned if the file does not exist.
+func (p *Proxy) Open(ctx context.Context, u string) (io.ReadCloser, string, error) {
+	if strings.HasPrefix(u, gsScheme) {
+		return p.openGCS(ctx, u)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	clt := p.Client
+	if clt == nil {
+		clt = http.DefaultClient
+	}
+	resp, err := clt.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not get %s: %w", u, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, "", ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("could not get %s: %s", u, resp.Status)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// openGCS opens a GCS object given its gs:// URL.
+func (p *Proxy) openGCS(ctx context.Context, u string) (io.ReadCloser, string, error) {
+	bkt, obj, ok := strings.Cut(strings.TrimPrefix(u, gsScheme), "/")
+	if !ok || obj == "" {
+		return nil, "", fmt.Errorf("invalid GCS URL: %s", u)
+	}
+	p.mu.Lock()
+	if p.gcs == nil {
+		clt, err := storage.NewClient(context.Background())
+		if err != nil {
+			p.mu.Unlock()
+			return nil, "", fmt.Errorf("could not create GCS client: %w", err)
+		}
+		p.gcs = clt
+	}
+	clt := p.gcs
+	p.mu.Unlock()
+
+	r, err := clt.Bucket(bkt).Object(obj).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read %s: %w", u, err)
+	}
+	return r, r.Attrs.ContentType, nil
+}
+
+// RewritePlaylist rewrites the relative URIs of a playlist, i.e.,
+// those of segments, variant playlists, keys and maps, by appending
+// the query string returned by query for each URI, so that they are
+// requested through the proxy with that query, e.g., a signature
+// authorizing the request. Absolute URIs, and those for which query
+// returns an empty string, are left unchanged.
+func RewritePlaylist(playlist []byte, query func(uri string) string) []byte {
+	rewrite := func(uri string) string {
+		if uri == "" || strings.Contains(uri, "://") || strings.HasPrefix(uri, "/") {
+			return uri
+		}
+		query := query(uri)
+		if query == "" {
+			return uri
+		}
+		sep := "?"
+		if strings.Contains(uri, "?") {
+			sep = "&"
+		}
+		return uri + sep + query
+	}
+
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(playlist))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		switch {
+		case strings.HasPrefix(line, "#"):
+			line = uriAttr.ReplaceAllStringFunc(line, func(m string) string {
+				return `URI="` + rewrite(uriAttr.FindStringSubmatch(m)[1]) + `"`
+			})
+		case strings.TrimSpace(line) != "":
+			line = rewrite(strings.TrimSpace(line))
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// Query returns the encoded query string for a playback token.
+func Query(token string) string {
+	return url.Values{"token": {token}}.Encode()
+}
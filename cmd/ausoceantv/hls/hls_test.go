@@ -0,0 +1,134 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package hls
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestRewritePlaylist tests that the relative URIs of playlists, and
+// only those, carry the query.
+func TestRewritePlaylist(t *testing.T) {
+	const query = "token=abc"
+	tests := []struct {
+		desc     string
+		playlist string
+		query    string
+		want     string
+	}{
+		{
+			desc:     "media playlist",
+			playlist: "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nseg0.ts\n#EXTINF:4.0,\nseg1.ts\n",
+			query:    query,
+			want:     "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nseg0.ts?token=abc\n#EXTINF:4.0,\nseg1.ts?token=abc\n",
+		},
+		{
+			desc:     "master playlist",
+			playlist: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n",
+			query:    query,
+			want:     "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8?token=abc\n",
+		},
+		{
+			desc:     "key and map URIs",
+			playlist: "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4.0,\nseg0.m4s\n",
+			query:    query,
+			want:     "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin?token=abc\"\n#EXT-X-MAP:URI=\"init.mp4?token=abc\"\n#EXTINF:4.0,\nseg0.m4s?token=abc\n",
+		},
+		{
+			desc:     "existing query",
+			playlist: "#EXTINF:4.0,\nseg0.ts?v=1\n",
+			query:    query,
+			want:     "#EXTINF:4.0,\nseg0.ts?v=1&token=abc\n",
+		},
+		{
+			desc:     "absolute URIs unchanged",
+			playlist: "#EXT-X-KEY:METHOD=AES-128,URI=\"https://keys.example.com/key\"\n#EXTINF:4.0,\nhttps://cdn.example.com/seg0.ts\n#EXTINF:4.0,\n/seg1.ts\n",
+			query:    query,
+			want:     "#EXT-X-KEY:METHOD=AES-128,URI=\"https://keys.example.com/key\"\n#EXTINF:4.0,\nhttps://cdn.example.com/seg0.ts\n#EXTINF:4.0,\n/seg1.ts\n",
+		},
+		{
+			desc:     "CRLF line endings and blank lines",
+			playlist: "#EXTM3U\r\n\r\n#EXTINF:4.0,\r\n seg0.ts \r\n",
+			query:    query,
+			want:     "#EXTM3U\n\n#EXTINF:4.0,\nseg0.ts?token=abc\n",
+		},
+		{
+			desc:     "empty query",
+			playlist: "#EXTINF:4.0,\nseg0.ts\n",
+			want:     "#EXTINF:4.0,\nseg0.ts\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := string(RewritePlaylist([]byte(tt.playlist), func(uri string) string { return tt.query }))
+			if got != tt.want {
+				t.Errorf("RewritePlaylist() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRewritePlaylistPerURI tests that each URI carries its own query.
+func TestRewritePlaylistPerURI(t *testing.T) {
+	const (
+		playlist = "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4.0,\nseg0.m4s\n#EXTINF:4.0,\nseg1.m4s\n"
+		want     = "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4?sig=init.mp4\"\n#EXTINF:4.0,\nseg0.m4s?sig=seg0.m4s\n#EXTINF:4.0,\nseg1.m4s?sig=seg1.m4s\n"
+	)
+	got := string(RewritePlaylist([]byte(playlist), func(uri string) string { return "sig=" + uri }))
+	if got != want {
+		t.Errorf("RewritePlaylist() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+// TestResolve tests that paths resolve relative to the source's
+// directory and cannot escape it.
+func TestResolve(t *testing.T) {
+	const source = "gs://bucket/feed/index.m3u8"
+	tests := []struct {
+		source  string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{source: source, path: "seg0.ts", want: "gs://bucket/feed/seg0.ts"},
+		{source: source, path: "low/index.m3u8", want: "gs://bucket/feed/low/index.m3u8"},
+		{source: "https://vidforward.example.com/live/index.m3u8", path: "seg0.ts", want: "https://vidforward.example.com/live/seg0.ts"},
+		{source: source, path: "", wantErr: ErrInvalidPath},
+		{source: source, path: "/seg0.ts", wantErr: ErrInvalidPath},
+		{source: source, path: "../other/index.m3u8", wantErr: ErrInvalidPath},
+		{source: source, path: "low/../../other/seg0.ts", wantErr: ErrInvalidPath},
+		{source: source, path: "./seg0.ts", wantErr: ErrInvalidPath},
+		{source: source, path: "..\\seg0.ts", wantErr: ErrInvalidPath},
+	}
+
+	for i, tt := range tests {
+		got, err := Resolve(tt.source, tt.path)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("test %d: Resolve(%q) returned error %v, want %v", i, tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("test %d: Resolve(%q) = %q, want %q", i, tt.path, got, tt.want)
+		}
+	}
+}
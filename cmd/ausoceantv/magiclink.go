@@ -0,0 +1,207 @@
+/*
+DESCRIPTION
+  Email login for AusOcean TV, which lets users without Google
+  accounts log in by following a link emailed to them.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Email login constants.
+const (
+	magicLinkTTL      = 15 * time.Minute // Lifetime of emailed login links.
+	magicLinkPerEmail = 3                // Maximum links per email address per hour.
+	magicLinkPerIP    = 10               // Maximum links per client IP address per hour.
+	magicLinkPurge    = time.Hour        // Period between deletions of expired links.
+)
+
+// emailLoginHandler handles requests to log in by email. A single-use
+// login link is emailed to the given address, which returns the user
+// to the given redirect URL once followed. Requests are rate limited
+// per email address and per client IP address. The response is the
+// same whether or not the address belongs to a subscriber, so that
+// addresses cannot be probed.
+func (svc *service) emailLoginHandler(c *fiber.Ctx) error {
+	addr, err := mail.ParseAddress(c.FormValue("email"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid email address")
+	}
+	email := strings.ToLower(addr.Address)
+	redirect := c.FormValue("redirect", "/")
+	if !localURL(redirect) {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid redirect")
+	}
+
+	ctx := context.Background()
+	for _, b := range []struct {
+		name  string
+		limit float64
+	}{{"email." + email, magicLinkPerEmail}, {"ip." + c.IP(), magicLinkPerIP}} {
+		ok, _, err := model.TakeToken(ctx, svc.settingsStore, "login."+b.name, b.limit/3600, b.limit)
+		if err != nil {
+			return fmt.Errorf("unable to rate limit email login: %w", err)
+		}
+		if !ok {
+			return fiber.NewError(fiber.StatusTooManyRequests, "too many login requests, try again later")
+		}
+	}
+
+	tok, err := model.CreateMagicLink(ctx, svc.settingsStore, email, redirect, magicLinkTTL)
+	if err != nil {
+		return fmt.Errorf("unable to create login link: %w", err)
+	}
+	link := c.BaseURL() + "/api/v1/auth/email/verify?token=" + url.QueryEscape(tok)
+	err = svc.sendLoginLink(ctx, email, link)
+	if err != nil {
+		return fmt.Errorf("unable to send login link: %w", err)
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// sendLoginLink emails a login link.
+func (svc *service) sendLoginLink(ctx context.Context, email, link string) error {
+	send, err := svc.emailer(ctx)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Follow this link to log in to AusOcean TV:\n\n%s\n\nThe link expires in %v and can only be used once. "+
+		"If you did not request it, you can ignore this email.\n", link, magicLinkTTL)
+	return send(email, "Log in to AusOcean TV", body)
+}
+
+// confirmEmailHandler handles login links emailed by
+// emailLoginHandler, which are followed with GET requests. The link is
+// not used until the user confirms the login, since email scanners may
+// follow links, which would otherwise use them up.
+func (svc *service) confirmEmailHandler(c *fiber.Ctx) error {
+	return sendConfirmPage(c, "Log in to AusOcean TV", "Log in to AusOcean TV with your email address?", "Log in")
+}
+
+// verifyEmailHandler handles the confirmation of a login link. A valid
+// link logs the user in with a profile for the verified email address,
+// and redirects them to the link's redirect URL. If the address
+// belongs to a subscriber, the subscriber's name is used and their
+// email is marked as verified.
+func (svc *service) verifyEmailHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+	l, err := model.UseMagicLink(ctx, svc.settingsStore, c.FormValue("token"))
+	switch {
+	case errors.Is(err, model.ErrLinkNotFound), errors.Is(err, model.ErrLinkExpired), errors.Is(err, model.ErrLinkUsed):
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	case err != nil:
+		return fmt.Errorf("unable to use login link: %w", err)
+	}
+
+	p := &gauth.Profile{Email: l.Email}
+	s, err := model.GetSubscriberByEmail(ctx, svc.settingsStore, l.Email)
+	switch {
+	case err == nil:
+		p.GivenName, p.FamilyName = s.GivenName, s.FamilyName
+		if s.EmailVerified.IsZero() {
+			s.EmailVerified = time.Now()
+			err = model.UpdateSubscriber(ctx, svc.settingsStore, s)
+			if err != nil {
+				log.Errorf("unable to mark subscriber %d email as verified: %v", s.ID, err)
+			}
+		}
+	case !errors.Is(err, datastore.ErrNoSuchEntity):
+		return fmt.Errorf("unable to get subscriber: %w", err)
+	}
+
+	err = svc.auth.LoginVerified(backend.NewFiberHandler(c), p)
+	if err != nil {
+		return fmt.Errorf("unable to log in: %w", err)
+	}
+	return c.Redirect(l.Redirect, fiber.StatusSeeOther)
+}
+
+// purgeMagicLinks deletes expired login links periodically, until ctx
+// is done.
+func (svc *service) purgeMagicLinks(ctx context.Context) {
+	ticker := time.NewTicker(magicLinkPurge)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := model.DeleteExpiredMagicLinks(ctx, svc.settingsStore, time.Now())
+			if err != nil {
+				log.Errorf("unable to delete expired login links: %v", err)
+				continue
+			}
+			log.Infof("deleted %d expired login links", n)
+		}
+	}
+}
+
+// confirmPage is a page asking the user to confirm the action of an
+// emailed link, which posts the link's token back to the link's path.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>{{.Prompt}}</p>
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+// sendConfirmPage responds to a GET request for an emailed link with
+// a page asking the user to confirm the link's action, which is then
+// POSTed to the same path with the link's token.
+func sendConfirmPage(c *fiber.Ctx, title, prompt, button string) error {
+	c.Type("html", "utf-8")
+	return confirmPage.Execute(c, struct {
+		Title, Prompt, Action, Token, Button string
+	}{title, prompt, c.Path(), c.Query("token"), button})
+}
+
+// localURL returns true if u is a path on this site, rather than an
+// absolute URL, so that login links cannot redirect elsewhere.
+func localURL(u string) bool {
+	return strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") && !strings.Contains(u, `\`)
+}
@@ -30,20 +30,25 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/encryptcookie"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 
 	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/cmd/ausoceantv/dsclient"
+	"github.com/ausocean/cloud/cmd/ausoceantv/hls"
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/cloud/utils"
 	"github.com/ausocean/openfish/cmd/openfish/api"
 	"github.com/ausocean/openfish/datastore"
 )
@@ -54,17 +59,27 @@ const (
 	oauthClientID = "1005382600755-7st09cc91eqcqveviinitqo091dtcmf0.apps.googleusercontent.com"
 	oauthMaxAge   = 60 * 60 * 24 * 7 // 7 days.
 	version       = "v0.3.0"
+
+	shutdownTimeout = 10 * time.Second // Maximum time to drain connections and write state on shutdown.
 )
 
 // service defines the properties of our web service.
 type service struct {
-	setupMutex    sync.Mutex
-	settingsStore datastore.Store
-	debug         bool
-	standalone    bool
-	development   bool
-	storePath     string
-	auth          *gauth.UserAuth
+	setupMutex     sync.Mutex
+	settingsStore  datastore.Store
+	debug          bool
+	standalone     bool
+	development    bool
+	storePath      string
+	auth           *gauth.UserAuth
+	notifier       notify.Notifier
+	webhookSecret  string
+	playbackKey    []byte
+	unsubscribeKey []byte
+	hls            hls.Proxy
+	engagement     engagementCounter
+	announcing     sync.Map // Announcement IDs being sent by this instance.
+	admins         []string // Email addresses of administrators, in lower case.
 }
 
 // svc is an instance of our service.
@@ -78,7 +93,10 @@ func registerAPIRoutes(app *fiber.App) {
 		Get("/login", svc.loginHandler).
 		Get("/logout", svc.logoutHandler).
 		Get("oauth2callback", svc.callbackHandler).
-		Get("profile", svc.profileHandler)
+		Get("profile", svc.profileHandler).
+		Post("/email", svc.emailLoginHandler).
+		Get("/email/verify", svc.confirmEmailHandler).
+		Post("/email/verify", svc.verifyEmailHandler)
 
 	v1.Get("version", svc.versionHandler)
 
@@ -87,10 +105,36 @@ func registerAPIRoutes(app *fiber.App) {
 		Post("/create-payment-intent", svc.handleCreatePaymentIntent).
 		Get("/price/:id", svc.handleGetPrice).
 		Get("/product/:id", svc.handleGetProduct).
-		Post("/cancel", svc.cancelSubscription)
+		Post("/cancel", svc.cancelSubscription).
+		Post("/webhook", svc.handleStripeWebhook)
 
 	v1.Group("/get").
 		Get("/subscription", svc.getSubscriptionHandler)
+
+	v1.Get("/promo/:code", svc.checkPromoHandler)
+
+	v1.Post("/guest", svc.guestHandler)
+	v1.Get("/playback/:feed", svc.playbackTokenHandler)
+	v1.Get("/stream/:feed", svc.requirePlayback, svc.streamHandler)
+	v1.Get("/hls/:feed/*", svc.requireSegment, svc.hlsHandler)
+	v1.Post("/analytics/:feed", svc.requirePlayback, svc.analyticsHandler)
+
+	v1.Group("/admin/promo").
+		Post("/", svc.mintPromoHandler).
+		Get("/", svc.listPromoHandler).
+		Delete("/:code", svc.deletePromoHandler)
+
+	v1.Post("/admin/entitlement", svc.entitlementHandler)
+	v1.Get("/admin/funnel", svc.funnelHandler)
+	v1.Get("/admin/analytics", svc.engagementHandler)
+
+	v1.Group("/admin/announcement").
+		Post("/", svc.announceHandler).
+		Get("/", svc.listAnnouncementsHandler).
+		Get("/:id", svc.announcementHandler).
+		Post("/:id/send", svc.resumeAnnouncementHandler)
+	v1.Get("/unsubscribe", svc.confirmUnsubscribeHandler)
+	v1.Post("/unsubscribe", svc.unsubscribeHandler)
 }
 
 func main() {
@@ -115,7 +159,14 @@ func main() {
 	flag.StringVar(&host, "host", "localhost", "Host we run on in standalone mode")
 	flag.IntVar(&port, "port", defaultPort, "Port we listen on in standalone mode")
 	flag.StringVar(&svc.storePath, "filestore", "store", "File store path")
+	var admins string
+	flag.StringVar(&admins, "admins", "", "Comma-separated email addresses of administrators")
 	flag.Parse()
+	for _, a := range strings.Split(admins, ",") {
+		if a = strings.ToLower(strings.TrimSpace(a)); a != "" {
+			svc.admins = append(svc.admins, a)
+		}
+	}
 
 	// Create app.
 	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
@@ -135,7 +186,7 @@ func main() {
 	svc.setup(ctx)
 
 	// Recover from panics.
-	app.Use(recover.New())
+	app.Use(backend.FiberRecover(svc.recoveryHandler(ctx)))
 
 	// CORS middleware.
 	app.Use(cors.New(cors.Config{
@@ -161,10 +212,52 @@ func main() {
 	// Register routes.
 	registerAPIRoutes(app)
 
-	// Start web server.
+	// Write playback engagement periodically, and on shutdown.
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go svc.engagement.flushPeriodically(ctx, svc)
+
+	// Delete expired login links periodically.
+	go svc.purgeMagicLinks(ctx)
+	app.Hooks().OnShutdown(func() error {
+		return svc.engagement.flush(context.Background(), svc)
+	})
+
+	// Start web server, which is shut down gracefully upon SIGTERM or SIGINT.
 	listenOn := fmt.Sprintf(":%d", port)
 	fmt.Printf("starting web server on %s\n", listenOn)
-	log.Fatal(app.Listen(listenOn))
+	go func() {
+		log.Fatal(app.Listen(listenOn))
+	}()
+	<-ctx.Done()
+	log.Info("shutting down")
+	err = app.ShutdownWithTimeout(shutdownTimeout)
+	if err != nil {
+		log.Errorf("unable to shut down: %v", err)
+	}
+}
+
+// recoveryHandler returns a panic recovery handler that notifies ops
+// of panics, with repeated panics notified at most once per period.
+func (svc *service) recoveryHandler(ctx context.Context) utils.RecoveryHandler {
+	const (
+		opsEmail    = "ops@ausocean.org"
+		dedupPeriod = time.Hour
+	)
+	secrets, err := gauth.GetSecrets(ctx, projectID, []string{"mailjetPublicKey", "mailjetPrivateKey"})
+	if err != nil {
+		log.Warnf("could not get mailjet secrets, panic notifications will not be sent: %v", err)
+	}
+	notifyOps := func(msg string) error {
+		if err != nil {
+			return fmt.Errorf("no mailjet secrets: %w", err)
+		}
+		return notify.Send(secrets["mailjetPublicKey"], secrets["mailjetPrivateKey"], "noreply@ausocean.tv", []string{opsEmail}, "URGENT: AusOcean TV Panic Recovery", msg)
+	}
+	return utils.NewConfigurableRecoveryHandler(
+		utils.WithNotification(notifyOps),
+		utils.WithDeduplication(dedupPeriod),
+	)
 }
 
 // preFlightOK returns a statusOK message to preflight messages.
@@ -200,10 +293,13 @@ func (svc *service) setup(ctx context.Context) {
 	log.Info("set up datastore")
 
 	svc.setupStripe(ctx)
+	svc.setupNotifier(ctx)
+	svc.setupPlayback(ctx)
+	svc.setupAnnouncements(ctx)
 
 	// Initialise OAuth2.
 	log.Info("Initializing OAuth2")
-	svc.auth = &gauth.UserAuth{ProjectID: projectID, ClientID: oauthClientID, MaxAge: oauthMaxAge}
+	svc.auth = &gauth.UserAuth{ProjectID: projectID, ClientID: oauthClientID, MaxAge: oauthMaxAge, Store: svc.settingsStore, Notify: svc.loginNotifier(ctx)}
 	svc.auth.Init(backend.NewFiberHandler(nil))
 }
 
@@ -0,0 +1,223 @@
+/*
+DESCRIPTION
+  Feed entitlement and playback token routes for AusOcean TV.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/cmd/ausoceantv/hls"
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Playback constants.
+const (
+	playbackTTL       = time.Hour     // Lifetime of playback tokens.
+	playbackKeySecret = "playbackKey" // Secret used to sign playback tokens.
+	playbackClaimsKey = "playback"    // Fiber locals key for playback claims.
+)
+
+// playbackClaims are the claims of a playback token.
+type playbackClaims struct {
+	Subscriber int64     // Subscriber ID.
+	Feed       int64     // Feed ID.
+	Expires    time.Time // Date/time the token expires.
+}
+
+// setupPlayback gets the secret used to sign playback tokens. Without
+// it, playback tokens cannot be issued.
+func (svc *service) setupPlayback(ctx context.Context) {
+	key, err := gauth.GetSecret(ctx, projectID, playbackKeySecret)
+	if err != nil {
+		log.Errorf("unable to get %s secret, playback will not work: %v", playbackKeySecret, err)
+		return
+	}
+	svc.playbackKey = []byte(key)
+}
+
+// issuePlaybackToken issues a signed token that permits a
+// subscriber, or a guest when sid is zero, to play a feed for ttl.
+func (svc *service) issuePlaybackToken(sid, fid int64, ttl time.Duration) (string, time.Time, error) {
+	exp := time.Now().Add(ttl)
+	// IDs are encoded as strings, since JSON numbers lose precision.
+	tok, err := gauth.PutClaims(map[string]interface{}{
+		"sub":  strconv.FormatInt(sid, 10),
+		"feed": strconv.FormatInt(fid, 10),
+		"exp":  exp.Unix(),
+	}, svc.playbackKey)
+	return tok, exp, err
+}
+
+// validatePlaybackToken validates a playback token, returning its claims.
+func (svc *service) validatePlaybackToken(tok string) (*playbackClaims, error) {
+	claims, err := gauth.GetClaims(tok, svc.playbackKey)
+	if err != nil {
+		return nil, err
+	}
+	var pc playbackClaims
+	sub, _ := claims["sub"].(string)
+	feed, _ := claims["feed"].(string)
+	exp, _ := claims["exp"].(float64)
+	pc.Subscriber, err = strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid sub claim")
+	}
+	pc.Feed, err = strconv.ParseInt(feed, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid feed claim")
+	}
+	pc.Expires = time.Unix(int64(exp), 0)
+	return &pc, nil
+}
+
+// playbackTokenHandler handles requests from the player for a
+// playback token for the feed given by the feed param. The logged in
+// user must be a subscriber entitled to the feed. Users who are not
+// logged in are treated as guests.
+func (svc *service) playbackTokenHandler(c *fiber.Ctx) error {
+	if svc.playbackKey == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "playback not configured")
+	}
+	fid, err := strconv.ParseInt(c.Params("feed"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed ID")
+	}
+
+	p, err := svc.auth.GetProfile(backend.NewFiberHandler(c))
+	if errors.Is(err, gauth.SessionNotFound) || errors.Is(err, gauth.TokenNotFound) {
+		return svc.guestPlayback(c, fid)
+	} else if err != nil {
+		return fmt.Errorf("unable to get profile: %w", err)
+	}
+
+	ctx := context.Background()
+	subscriber, err := model.GetSubscriberByEmail(ctx, svc.settingsStore, p.Email)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return fiber.NewError(fiber.StatusForbidden, model.ErrNotEntitled.Error())
+	} else if err != nil {
+		return fmt.Errorf("error getting subscriber by email for: %s: %w", p.Email, err)
+	}
+	_, err = model.Entitled(ctx, svc.settingsStore, subscriber.ID, fid)
+	if errors.Is(err, model.ErrNotEntitled) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	} else if err != nil {
+		return fmt.Errorf("error checking entitlement: %w", err)
+	}
+
+	tok, exp, err := svc.issuePlaybackToken(subscriber.ID, fid, playbackTTL)
+	if err != nil {
+		return fmt.Errorf("unable to issue playback token: %w", err)
+	}
+	return c.JSON(struct {
+		Token   string    `json:"token"`
+		Expires time.Time `json:"expires"`
+	}{tok, exp})
+}
+
+// requirePlayback is middleware that enforces playback tokens on
+// routes with a feed param. The token is supplied by the token query
+// param or a bearer Authorization header, and must be for the
+// requested feed. The claims are stored in the playbackClaimsKey local.
+func (svc *service) requirePlayback(c *fiber.Ctx) error {
+	if svc.playbackKey == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "playback not configured")
+	}
+	tok := c.Query("token")
+	if tok == "" {
+		tok = c.Get(fiber.HeaderAuthorization)
+	}
+	pc, err := svc.validatePlaybackToken(tok)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, fmt.Sprintf("invalid playback token: %v", err))
+	}
+	if strconv.FormatInt(pc.Feed, 10) != c.Params("feed") {
+		return fiber.NewError(fiber.StatusForbidden, "playback token is for a different feed")
+	}
+	c.Locals(playbackClaimsKey, pc)
+	return c.Next()
+}
+
+// streamHandler handles requests to play a feed, which must carry a
+// valid playback token, by redirecting to the feed's playlist on the
+// HLS proxy (see hlsHandler), with the same token. The feed's source
+// is never disclosed, so only HLS feeds, which can be proxied, can be
+// played.
+func (svc *service) streamHandler(c *fiber.Ctx) error {
+	pc := c.Locals(playbackClaimsKey).(*playbackClaims)
+	f, err := model.GetFeed(context.Background(), svc.settingsStore, pc.Feed)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return fiber.NewError(fiber.StatusNotFound, "feed not found")
+	} else if err != nil {
+		return fmt.Errorf("unable to get feed %d: %w", pc.Feed, err)
+	}
+	if !hls.IsPlaylist(f.Source) {
+		return fiber.NewError(fiber.StatusNotFound, "feed is not an HLS stream")
+	}
+	tok := c.Query("token")
+	if tok == "" {
+		tok = c.Get(fiber.HeaderAuthorization)
+	}
+	// The redirect is relative to /stream/:feed.
+	return c.Redirect(fmt.Sprintf("../hls/%d/%s?%s", pc.Feed, path.Base(f.Source), hls.Query(tok)), fiber.StatusFound)
+}
+
+// entitlementHandler handles admin requests to set the feeds
+// accessible by a subscription class. The class form value is
+// required, and the feeds form value is a comma-separated list of feed
+// IDs, or empty for all feeds.
+func (svc *service) entitlementHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	e := &model.Entitlement{Class: c.FormValue("class")}
+	if e.Class == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing class")
+	}
+	if v := c.FormValue("feeds"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			fid, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid feed ID: "+s)
+			}
+			e.Feeds = append(e.Feeds, fid)
+		}
+	}
+	err = model.PutEntitlement(context.Background(), svc.settingsStore, e)
+	if err != nil {
+		return fmt.Errorf("unable to put entitlement: %w", err)
+	}
+	return c.JSON(e)
+}
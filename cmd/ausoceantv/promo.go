@@ -0,0 +1,262 @@
+/*
+DESCRIPTION
+  Promo and gift code routes for AusOcean TV.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/coupon"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+// Promo code defaults.
+const (
+	defaultPromoDays = 30  // Default validity of a promo code in days.
+	maxPromoBatch    = 500 // Maximum number of codes minted at once.
+)
+
+// requireAdmin returns the profile of the logged in user if they are
+// an AusOcean TV administrator, i.e., their email address is one of
+// the admins, or an error otherwise.
+func (svc *service) requireAdmin(c *fiber.Ctx) (*gauth.Profile, error) {
+	p, err := svc.auth.GetProfile(backend.NewFiberHandler(c))
+	if errors.Is(err, gauth.SessionNotFound) || errors.Is(err, gauth.TokenNotFound) {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, fmt.Sprintf("error getting profile: %v", err))
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get profile: %w", err)
+	}
+	if !slices.Contains(svc.admins, strings.ToLower(p.Email)) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "admin access required")
+	}
+	return p, nil
+}
+
+// mintPromoHandler handles requests to mint promo codes. The
+// following form values are accepted:
+//
+// - class: subscription class, e.g., Month (required).
+// - off: percentage discount, where 100 (the default) is free.
+// - max: maximum redemptions per code (default 1).
+// - days: number of days the codes are valid (default 30).
+// - n: number of codes to mint (default 1).
+// - campaign: campaign name, e.g., "gift" (optional).
+// - code: the code itself, when minting a single code (optional).
+//
+// For discounted codes, a Stripe coupon is created and shared by the
+// batch. The response is the minted codes in JSON format.
+func (svc *service) mintPromoHandler(c *fiber.Ctx) error {
+	p, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	class := c.FormValue("class")
+	if !slices.Contains([]string{model.SubscriptionDay, model.SubscriptionMonth, model.SubscriptionYear}, class) {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid class: "+class)
+	}
+	off, err := formInt(c, "off", 100)
+	if err != nil || off <= 0 || off > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid off")
+	}
+	maxUses, err := formInt(c, "max", 1)
+	if err != nil || maxUses <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid max")
+	}
+	days, err := formInt(c, "days", defaultPromoDays)
+	if err != nil || days <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid days")
+	}
+	n, err := formInt(c, "n", 1)
+	if err != nil || n <= 0 || n > maxPromoBatch {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid n")
+	}
+	code := c.FormValue("code")
+	if code != "" && n != 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "code requires n=1")
+	}
+	campaign := c.FormValue("campaign")
+
+	var couponID string
+	if off < 100 {
+		params := &stripe.CouponParams{
+			PercentOff: stripe.Float64(float64(off)),
+			Duration:   stripe.String(string(stripe.CouponDurationOnce)),
+		}
+		if campaign != "" {
+			params.Name = stripe.String(campaign)
+		}
+		cp, err := coupon.New(params)
+		if err != nil {
+			return fmt.Errorf("unable to create stripe coupon: %w", err)
+		}
+		couponID = cp.ID
+	}
+
+	ctx := context.Background()
+	expires := time.Now().AddDate(0, 0, int(days))
+	var codes []*model.PromoCode
+	for i := int64(0); i < n; i++ {
+		promo := &model.PromoCode{
+			Code:           code,
+			Class:          class,
+			PercentOff:     off,
+			Coupon:         couponID,
+			Campaign:       campaign,
+			MaxRedemptions: maxUses,
+			Creator:        p.Email,
+			Expires:        expires,
+		}
+		err := model.CreatePromoCode(ctx, svc.settingsStore, promo)
+		if errors.Is(err, model.ErrPromoExists) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		} else if err != nil {
+			return fmt.Errorf("unable to create promo code: %w", err)
+		}
+		codes = append(codes, promo)
+	}
+	log.Infof("%s minted %d %s promo code(s) for campaign %q", p.Email, n, class, campaign)
+	return c.JSON(codes)
+}
+
+// listPromoHandler handles requests to list promo codes, optionally
+// for the given campaign.
+func (svc *service) listPromoHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	codes, err := model.GetPromoCodes(context.Background(), svc.settingsStore, c.Query("campaign"))
+	if err != nil {
+		return fmt.Errorf("unable to get promo codes: %w", err)
+	}
+	return c.JSON(codes)
+}
+
+// deletePromoHandler handles requests to delete a promo code.
+func (svc *service) deletePromoHandler(c *fiber.Ctx) error {
+	_, err := svc.requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	err = model.DeletePromoCode(context.Background(), svc.settingsStore, c.Params("code"))
+	if err != nil {
+		return fmt.Errorf("unable to delete promo code: %w", err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// checkPromoHandler handles requests from the checkout to check a
+// promo code before payment, returning its class and discount.
+func (svc *service) checkPromoHandler(c *fiber.Ctx) error {
+	promo, err := model.GetPromoCode(context.Background(), svc.settingsStore, c.Params("code"))
+	if err == nil {
+		err = promo.Check(0)
+	}
+	if err != nil {
+		return promoError(err)
+	}
+	return c.JSON(struct {
+		Class      string `json:"class"`
+		PercentOff int64  `json:"percentOff"`
+	}{promo.Class, promo.PercentOff})
+}
+
+// redeemPromo redeems a promo code for a subscriber during checkout
+// of a subscription of the given class. Free codes are fulfilled
+// immediately by granting a subscription, in which case the response
+// is written and done is true. The code is only consumed if the
+// subscription is granted. Otherwise the code is returned, so that its
+// discount can be applied to the payment.
+//
+// NOTE: Discounted codes are redeemed when the payment is created, so
+// an abandoned payment still counts towards the redemptions.
+func (svc *service) redeemPromo(c *fiber.Ctx, sub *model.Subscriber, code, class string) (promo *model.PromoCode, done bool, err error) {
+	ctx := context.Background()
+	var s *model.Subscription
+	promo, err = model.RedeemPromoCode(ctx, svc.settingsStore, code, sub.ID, class, func(p *model.PromoCode) error {
+		if !p.Free() {
+			return nil
+		}
+		var err error
+		s, err = model.GrantSubscription(ctx, svc.settingsStore, sub.ID, model.NoFeedID, p.Class)
+		return err
+	})
+	if err != nil {
+		return nil, false, promoError(err)
+	}
+	if !promo.Free() {
+		return promo, false, nil
+	}
+
+	log.Infof("subscriber %d redeemed promo code %s for a %s subscription", sub.ID, promo.Code, promo.Class)
+	svc.recordSubscribe(ctx, sub.ID)
+	return promo, true, c.JSON(struct {
+		Redeemed     bool                `json:"redeemed"`
+		Subscription *model.Subscription `json:"subscription"`
+	}{true, s})
+}
+
+// promoError converts promo code errors to client errors.
+func promoError(err error) error {
+	switch {
+	case errors.Is(err, model.ErrPromoNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrPromoExpired), errors.Is(err, model.ErrPromoUsed), errors.Is(err, model.ErrPromoRedeemed), errors.Is(err, model.ErrPromoClass):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, model.ErrPaidSubscription):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		return fmt.Errorf("unable to redeem promo code: %w", err)
+	}
+}
+
+// discounted returns the amount after applying a promo code's discount,
+// if any.
+func discounted(amount int64, promo *model.PromoCode) int64 {
+	if promo == nil {
+		return amount
+	}
+	return amount * (100 - promo.PercentOff) / 100
+}
+
+// formInt returns the integer value of a form value, or def if empty.
+func formInt(c *fiber.Ctx, key string, def int64) (int64, error) {
+	v := c.FormValue(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
@@ -0,0 +1,72 @@
+// Playback analytics, which batches playback events from a video
+// element and posts them to /api/v1/analytics/:feed.
+
+type PlaybackEvent = {
+  type: "play" | "pause" | "quality" | "watched";
+  seconds?: number;
+};
+
+// Period between posts of batched events (ms).
+const flushPeriod = 30 * 1000;
+
+export class PlaybackAnalytics {
+  private events: PlaybackEvent[] = [];
+  private watched = 0; // Seconds watched since the last watched event.
+  private last = -1; // Playback position at the last timeupdate, or -1 when not playing.
+  private timer?: number;
+
+  // feed is the feed ID, and token returns the current playback token,
+  // since tokens are refreshed while playing.
+  constructor(
+    private feed: number,
+    private token: () => string,
+  ) {}
+
+  // attach reports the playback events of a video element.
+  attach(video: HTMLVideoElement) {
+    video.addEventListener("play", () => {
+      this.events.push({ type: "play" });
+      this.last = video.currentTime;
+    });
+    video.addEventListener("pause", () => {
+      this.events.push({ type: "pause" });
+      this.last = -1;
+    });
+    video.addEventListener("timeupdate", () => {
+      if (this.last >= 0) {
+        const d = video.currentTime - this.last;
+        // Ignore seeks, which move the position further than playback.
+        if (d > 0 && d < 5) {
+          this.watched += d;
+        }
+      }
+      this.last = video.paused ? -1 : video.currentTime;
+    });
+    video.addEventListener("resize", () => this.events.push({ type: "quality" }));
+
+    this.timer = window.setInterval(() => this.flush(), flushPeriod);
+    window.addEventListener("pagehide", () => this.flush());
+  }
+
+  // detach stops reporting, posting any pending events.
+  detach() {
+    window.clearInterval(this.timer);
+    this.flush();
+  }
+
+  // flush posts pending events, using a beacon so that events are
+  // delivered when the page is closed.
+  flush() {
+    const seconds = Math.floor(this.watched);
+    if (seconds > 0) {
+      this.events.push({ type: "watched", seconds });
+      this.watched -= seconds;
+    }
+    if (this.events.length == 0) {
+      return;
+    }
+    const url = `/api/v1/analytics/${this.feed}?token=${encodeURIComponent(this.token())}`;
+    const body = JSON.stringify({ events: this.events.splice(0, 100) });
+    navigator.sendBeacon(url, new Blob([body], { type: "application/json" }));
+  }
+}
@@ -0,0 +1,107 @@
+import { html } from "lit";
+import { customElement, property, query, state } from "lit/decorators.js";
+import { TailwindElement } from "../shared/tailwind.element.ts";
+import { PlaybackAnalytics } from "../utils/analytics.ts";
+
+// Time before a playback token expires that it is refreshed (ms).
+const refreshMargin = 60 * 1000;
+
+// feed-player plays a feed given by its feed attribute, or else the
+// feed query param of the page, and reports playback analytics. Without
+// a feed, the slotted content is shown instead.
+@customElement("feed-player")
+export class FeedPlayer extends TailwindElement() {
+  @property({ type: Number })
+  feed = Number(new URLSearchParams(window.location.search).get("feed"));
+
+  @state()
+  private src = "";
+
+  @state()
+  private error = "";
+
+  @query("video")
+  private video?: HTMLVideoElement;
+
+  private token = "";
+  private refreshTimer?: number;
+  private analytics?: PlaybackAnalytics;
+
+  async connectedCallback() {
+    super.connectedCallback();
+    if (!this.feed) {
+      return;
+    }
+    await this.refresh();
+    if (this.token) {
+      const token = encodeURIComponent(this.token);
+      this.src = `/api/v1/stream/${this.feed}?token=${token}`;
+    }
+  }
+
+  disconnectedCallback() {
+    super.disconnectedCallback();
+    window.clearTimeout(this.refreshTimer);
+    this.analytics?.detach();
+  }
+
+  updated() {
+    if (this.video && !this.analytics) {
+      this.analytics = new PlaybackAnalytics(this.feed, () => this.token);
+      this.analytics.attach(this.video);
+    }
+  }
+
+  // refresh gets a playback token for the feed, and schedules its
+  // refresh before it expires.
+  private async refresh() {
+    await fetch(`/api/v1/playback/${this.feed}`)
+      .then((resp) => {
+        if (resp.status != 200) {
+          throw resp.status;
+        }
+        return resp.json();
+      })
+      .then((resp) => {
+        this.token = resp.token;
+        const delay =
+          new Date(resp.expires).getTime() - Date.now() - refreshMargin;
+        this.refreshTimer = window.setTimeout(
+          () => this.refresh(),
+          Math.max(delay, refreshMargin),
+        );
+      })
+      .catch((err) => {
+        console.log("Error getting playback token:", err);
+        if (this.token) {
+          return; // Keep playing until the token expires.
+        }
+        this.error =
+          err == 403
+            ? "Subscribe to watch this feed."
+            : "Unable to play this feed.";
+      });
+  }
+
+  render() {
+    if (!this.feed) {
+      return html`<slot></slot>`;
+    }
+    if (this.error) {
+      return html`<p class="p-5 text-white">${this.error}</p>`;
+    }
+    return html`<video
+      class="h-[500px] w-full"
+      src=${this.src}
+      controls
+      autoplay
+      playsinline
+    ></video>`;
+  }
+}
+
+declare global {
+  interface HTMLElementTagNameMap {
+    "feed-player": FeedPlayer;
+  }
+}
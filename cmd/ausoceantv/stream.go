@@ -0,0 +1,159 @@
+/*
+DESCRIPTION
+  Subscriber-only HLS streaming for AusOcean TV.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/ausocean/cloud/cmd/ausoceantv/hls"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// hlsHandler handles requests for the HLS playlists and segments of
+// a feed, which must carry a valid playback token for the feed or a
+// segment signature (see requireSegment). The feed's source is the
+// URL of its playlist on vidforward or in GCS, e.g.,
+// gs://bucket/feed/index.m3u8, and the path after the feed ID is
+// relative to the source's directory. Playlists are rewritten so that
+// the player requests each segment with its own signature, which
+// expires with the playback token, rather than with the token itself.
+// Upstream errors are logged rather than returned, so as not to
+// disclose the source.
+func (svc *service) hlsHandler(c *fiber.Ctx) error {
+	pc := c.Locals(playbackClaimsKey).(*playbackClaims)
+	ctx := context.Background()
+	f, err := model.GetFeed(ctx, svc.settingsStore, pc.Feed)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return fiber.NewError(fiber.StatusNotFound, "feed not found")
+	} else if err != nil {
+		return fmt.Errorf("unable to get feed %d: %w", pc.Feed, err)
+	}
+	if !hls.IsPlaylist(f.Source) {
+		return fiber.NewError(fiber.StatusNotFound, "feed is not an HLS stream")
+	}
+
+	p := c.Params("*")
+	if p == "" {
+		p = path.Base(f.Source)
+	}
+	u, err := hls.Resolve(f.Source, p)
+	if errors.Is(err, hls.ErrInvalidPath) {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	} else if err != nil {
+		log.Errorf("unable to resolve %s for feed %d: %v", p, f.ID, err)
+		return fiber.NewError(fiber.StatusBadGateway, "invalid feed source")
+	}
+	r, ctype, err := svc.hls.Open(ctx, u)
+	if errors.Is(err, hls.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "not found: "+p)
+	} else if err != nil {
+		log.Errorf("unable to open %s for feed %d: %v", p, f.ID, err)
+		return fiber.NewError(fiber.StatusBadGateway, "unable to get "+p)
+	}
+
+	// Content is for the token holder only.
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	if !hls.IsPlaylist(p) {
+		if ctype != "" {
+			c.Set(fiber.HeaderContentType, ctype)
+		}
+		return c.SendStream(r)
+	}
+
+	defer r.Close()
+	playlist, err := io.ReadAll(r)
+	if err != nil {
+		log.Errorf("unable to read playlist %s for feed %d: %v", p, f.ID, err)
+		return fiber.NewError(fiber.StatusBadGateway, "unable to read playlist")
+	}
+	dir := path.Dir(p)
+	c.Set(fiber.HeaderContentType, hls.PlaylistType)
+	return c.Send(hls.RewritePlaylist(playlist, func(uri string) string {
+		uri, _, _ = strings.Cut(uri, "?")
+		return svc.signSegment(pc.Feed, path.Join(dir, uri), pc.Expires)
+	}))
+}
+
+// segmentSignature returns the signature authorizing requests for
+// the file of a feed with the given path until exp.
+func (svc *service) segmentSignature(fid int64, p string, exp int64) string {
+	mac := hmac.New(sha256.New, svc.playbackKey)
+	fmt.Fprintf(mac, "segment\n%d\n%s\n%d", fid, p, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signSegment returns the query string authorizing requests for the
+// file of a feed with the given path until exp.
+func (svc *service) signSegment(fid int64, p string, exp time.Time) string {
+	return url.Values{
+		"exp": {strconv.FormatInt(exp.Unix(), 10)},
+		"sig": {svc.segmentSignature(fid, p, exp.Unix())},
+	}.Encode()
+}
+
+// requireSegment is middleware that enforces segment signatures, as
+// issued by hlsHandler, on HLS routes, or else playback tokens (see
+// requirePlayback). The claims are stored in the playbackClaimsKey
+// local.
+func (svc *service) requireSegment(c *fiber.Ctx) error {
+	sig := c.Query("sig")
+	if sig == "" {
+		return svc.requirePlayback(c)
+	}
+	if svc.playbackKey == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "playback not configured")
+	}
+	fid, err := strconv.ParseInt(c.Params("feed"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed ID")
+	}
+	exp, err := strconv.ParseInt(c.Query("exp"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid segment signature")
+	}
+	want := svc.segmentSignature(fid, c.Params("*"), exp)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid segment signature")
+	}
+	if time.Now().Unix() >= exp {
+		return fiber.NewError(fiber.StatusUnauthorized, "segment signature expired")
+	}
+	c.Locals(playbackClaimsKey, &playbackClaims{Feed: fid, Expires: time.Unix(exp, 0)})
+	return c.Next()
+}
@@ -52,7 +52,8 @@ var (
 
 // setupStripe gets the secrets required to set the stripe Key.
 // The secrets required are DEV_STRIPE_SECRET_KEY for standalone mode,
-// and STRIPE_SECRET_KEY for appengine mode.
+// and STRIPE_SECRET_KEY for appengine mode. The webhook signing
+// secret is similarly DEV_STRIPE_WEBHOOK_SECRET or STRIPE_WEBHOOK_SECRET.
 //
 // NOTE: If stripe keys aren't found, this causes a fatal error.
 func (svc *service) setupStripe(ctx context.Context) {
@@ -85,6 +86,17 @@ func (svc *service) setupStripe(ctx context.Context) {
 	// Set the global stripe key.
 	stripe.Key = key
 
+	// Get the webhook signing secret. Without it webhook events are
+	// rejected, so subscriptions are not kept in sync with Stripe.
+	name := "STRIPE_WEBHOOK_SECRET"
+	if svc.standalone || svc.development {
+		name = "DEV_STRIPE_WEBHOOK_SECRET"
+	}
+	svc.webhookSecret, err = gauth.GetSecret(ctx, projectID, name)
+	if err != nil {
+		log.Errorf("unable to get stripe webhook secret, webhooks will not work: %v", err)
+	}
+
 	log.Info("setup stripe")
 }
 
@@ -107,6 +119,7 @@ func (svc *service) handleCreatePaymentIntent(c *fiber.Ctx) error {
 		if err != nil {
 			return fmt.Errorf("unable to create susbcriber %v: %w", subscriber, err)
 		}
+		svc.recordSignup(c, subscriber)
 	} else if err != nil {
 		return fmt.Errorf("failed getting subscriber by email: %w", err)
 	}
@@ -116,6 +129,8 @@ func (svc *service) handleCreatePaymentIntent(c *fiber.Ctx) error {
 		return fmt.Errorf("error getting customer ID: %w", err)
 	}
 
+	// The price is validated first, so that a promo code is not
+	// consumed by an invalid request.
 	priceID := c.FormValue("priceID")
 	if priceID == "" {
 		err := c.SendStatus(fiber.StatusBadRequest)
@@ -130,21 +145,32 @@ func (svc *service) handleCreatePaymentIntent(c *fiber.Ctx) error {
 		return fmt.Errorf("error getting price: %w", err)
 	}
 
+	// Apply a promo code, if any, which must be for the price's class.
+	// Free codes need no payment.
+	var promo *model.PromoCode
+	if code := c.FormValue("promoCode"); code != "" {
+		var done bool
+		promo, done, err = svc.redeemPromo(c, subscriber, code, priceClass(price))
+		if done || err != nil {
+			return err
+		}
+	}
+
 	// If the price is recurring the selected product is a subscription and needs
 	// to be handled differently to the once off case.
 	if price.Recurring == nil {
-		return svc.createPaymentIntent(c, customerID, price)
+		return svc.createPaymentIntent(c, customerID, price, promo)
 	}
-	return svc.createSubscriptionIntent(c, customerID, priceID)
+	return svc.createSubscriptionIntent(c, customerID, priceID, promo)
 }
 
 type clientSecretResponse struct {
 	ClientSecret string `json:"clientSecret"`
 }
 
-func (svc *service) createPaymentIntent(c *fiber.Ctx, cid string, price *stripe.Price) error {
+func (svc *service) createPaymentIntent(c *fiber.Ctx, cid string, price *stripe.Price, promo *model.PromoCode) error {
 	params := &stripe.PaymentIntentParams{
-		Amount:                  stripe.Int64(price.UnitAmount),
+		Amount:                  stripe.Int64(discounted(price.UnitAmount, promo)),
 		Currency:                (*string)(&price.Currency),
 		Customer:                stripe.String(cid),
 		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{Enabled: stripe.Bool(true)},
@@ -176,7 +202,7 @@ func (svc *service) createPaymentIntent(c *fiber.Ctx, cid string, price *stripe.
 	return c.JSON(clientSecretResponse{pi.ClientSecret})
 }
 
-func (svc *service) createSubscriptionIntent(c *fiber.Ctx, cid, pid string) error {
+func (svc *service) createSubscriptionIntent(c *fiber.Ctx, cid, pid string, promo *model.PromoCode) error {
 	paymentSettings := &stripe.SubscriptionPaymentSettingsParams{
 		SaveDefaultPaymentMethod: stripe.String("on_subscription"),
 	}
@@ -188,6 +214,9 @@ func (svc *service) createSubscriptionIntent(c *fiber.Ctx, cid, pid string) erro
 		PaymentSettings: paymentSettings,
 		PaymentBehavior: stripe.String("default_incomplete"),
 	}
+	if promo != nil && promo.Coupon != "" {
+		subParams.Discounts = []*stripe.SubscriptionDiscountParams{{Coupon: stripe.String(promo.Coupon)}}
+	}
 	subParams.AddExpand("latest_invoice.payment_intent")
 	s, err := subscription.New(subParams)
 	if err != nil {
@@ -0,0 +1,296 @@
+/*
+DESCRIPTION
+  Stripe webhook handling for AusOcean TV, which keeps subscriptions
+  in sync with Stripe and emails subscribers about failed payments
+  and upcoming renewals.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of AusOcean TV. AusOcean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  AusOcean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with AusOcean TV in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/webhook"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+)
+
+// Notification kinds sent to subscribers. Notifications are keyed by
+// subscriber ID rather than site key.
+const (
+	kindPaymentFailed notify.Kind = "payment_failed"
+	kindRenewal       notify.Kind = "renewal"
+)
+
+// setupNotifier sets up the notifier used to email subscribers. If
+// the Mailjet secrets are not found, emails are logged but not sent.
+func (svc *service) setupNotifier(ctx context.Context) {
+	opts := []notify.Option{
+		notify.WithSender("noreply@ausocean.tv"),
+		notify.WithRecipientLookup(svc.subscriberRecipients),
+		notify.WithTemplate(kindPaymentFailed, notify.Template{Subject: "Your AusOcean TV payment failed", Body: "{{.Msg}}"}),
+		notify.WithTemplate(kindRenewal, notify.Template{Subject: "Your AusOcean TV subscription is renewing soon", Body: "{{.Msg}}"}),
+	}
+	secrets, err := gauth.GetSecrets(ctx, projectID, []string{"mailjetPublicKey", "mailjetPrivateKey"})
+	if err != nil {
+		log.Warnf("could not get mailjet secrets, subscriber emails will not be sent: %v", err)
+	} else {
+		opts = append(opts, notify.WithSecrets(secrets))
+	}
+	svc.notifier, err = notify.NewMailjetNotifier(opts...)
+	if err != nil {
+		log.Fatalf("could not set up notifier: %v", err)
+	}
+}
+
+// loginNotifier returns a function that emails users of logins from
+// new locations, or nil if emails cannot be sent.
+func (svc *service) loginNotifier(ctx context.Context) gauth.LoginNotifier {
+	secrets, err := gauth.GetSecrets(ctx, projectID, []string{"mailjetPublicKey", "mailjetPrivateKey"})
+	if err != nil {
+		log.Warnf("could not get mailjet secrets, login notifications will not be sent: %v", err)
+		return nil
+	}
+	return func(ctx context.Context, e *model.LoginEvent) error {
+		subject, body := gauth.LoginMessage(e)
+		return notify.Send(secrets["mailjetPublicKey"], secrets["mailjetPrivateKey"], "noreply@ausocean.tv", []string{e.Email}, subject, body)
+	}
+}
+
+// emailer returns a function that emails a single recipient. In
+// standalone mode emails are logged rather than sent.
+func (svc *service) emailer(ctx context.Context) (func(to, subject, body string) error, error) {
+	if svc.standalone {
+		return func(to, subject, body string) error {
+			log.Infof("email to %s: %s\n%s", to, subject, body)
+			return nil
+		}, nil
+	}
+	secrets, err := gauth.GetSecrets(ctx, projectID, []string{"mailjetPublicKey", "mailjetPrivateKey"})
+	if err != nil {
+		return nil, fmt.Errorf("could not get mailjet secrets: %w", err)
+	}
+	return func(to, subject, body string) error {
+		return notify.Send(secrets["mailjetPublicKey"], secrets["mailjetPrivateKey"], "noreply@ausocean.tv", []string{to}, subject, body)
+	}, nil
+}
+
+// subscriberRecipients looks up the email address of a subscriber,
+// given the subscriber ID.
+func (svc *service) subscriberRecipients(sid int64, kind notify.Kind) ([]string, time.Duration, error) {
+	s, err := model.GetSubscriber(context.Background(), svc.settingsStore, sid)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting subscriber %d: %w", sid, err)
+	}
+	return []string{s.Email}, 0, nil
+}
+
+// handleStripeWebhook handles Stripe webhook events, which are
+// verified using the webhook signing secret. The following events are
+// processed:
+//
+//   - customer.subscription.created/updated: the subscription's status,
+//     period and renewal are persisted.
+//   - customer.subscription.deleted: the subscription is canceled.
+//   - invoice.payment_failed: the subscription is marked past due and
+//     the subscriber is emailed.
+//   - invoice.upcoming: the subscriber is reminded of the renewal.
+//
+// Other events are acknowledged and ignored.
+func (svc *service) handleStripeWebhook(c *fiber.Ctx) error {
+	if svc.webhookSecret == "" {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "webhook secret not configured")
+	}
+	event, err := webhook.ConstructEvent(c.Body(), c.Get("Stripe-Signature"), svc.webhookSecret)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid webhook event: %v", err))
+	}
+	log.Infof("received stripe event %s (%s)", event.Type, event.ID)
+
+	ctx := context.Background()
+	switch event.Type {
+	case stripe.EventTypeCustomerSubscriptionCreated, stripe.EventTypeCustomerSubscriptionUpdated, stripe.EventTypeCustomerSubscriptionDeleted:
+		var s stripe.Subscription
+		err = json.Unmarshal(event.Data.Raw, &s)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("could not decode subscription: %v", err))
+		}
+		err = svc.syncSubscription(ctx, &s)
+
+	case stripe.EventTypeInvoicePaymentFailed, stripe.EventTypeInvoiceUpcoming:
+		var inv stripe.Invoice
+		err = json.Unmarshal(event.Data.Raw, &inv)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("could not decode invoice: %v", err))
+		}
+		if event.Type == stripe.EventTypeInvoicePaymentFailed {
+			err = svc.paymentFailed(ctx, &inv)
+		} else {
+			err = svc.upcomingRenewal(ctx, &inv)
+		}
+
+	default:
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if err != nil {
+		// Stripe retries events that are not acknowledged.
+		return fmt.Errorf("could not process %s event %s: %w", event.Type, event.ID, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// syncSubscription persists the state of a Stripe subscription to the
+// corresponding Subscription entity, creating it if necessary.
+func (svc *service) syncSubscription(ctx context.Context, s *stripe.Subscription) error {
+	subscriber, sub, err := svc.getStripeSubscription(ctx, s.Customer)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		sub = &model.Subscription{SubscriberID: subscriber.ID, FeedID: model.NoFeedID}
+	}
+	if s.Status == stripe.SubscriptionStatusActive && sub.Status != string(stripe.SubscriptionStatusActive) {
+		svc.recordSubscribe(ctx, subscriber.ID)
+	}
+
+	sub.PaymentID = s.ID
+	sub.Status = string(s.Status)
+	sub.Renew = !s.CancelAtPeriodEnd && s.Status != stripe.SubscriptionStatusCanceled
+	if s.CurrentPeriodStart != 0 {
+		sub.Start = time.Unix(s.CurrentPeriodStart, 0).UTC()
+	}
+	if s.CurrentPeriodEnd != 0 {
+		sub.Finish = time.Unix(s.CurrentPeriodEnd, 0).UTC()
+	}
+	if s.EndedAt != 0 {
+		sub.Finish = time.Unix(s.EndedAt, 0).UTC()
+	}
+	if s.Items != nil && len(s.Items.Data) > 0 && s.Items.Data[0].Price != nil && s.Items.Data[0].Price.Recurring != nil {
+		sub.Class = subscriptionClass(s.Items.Data[0].Price.Recurring.Interval)
+	}
+
+	log.Infof("subscription %s for subscriber %d is %s until %v", s.ID, subscriber.ID, sub.Status, sub.Finish)
+	return model.UpdateSubscription(ctx, svc.settingsStore, sub)
+}
+
+// paymentFailed marks a subscription as past due and emails the
+// subscriber, including when payment will next be attempted.
+func (svc *service) paymentFailed(ctx context.Context, inv *stripe.Invoice) error {
+	subscriber, sub, err := svc.getStripeSubscription(ctx, inv.Customer)
+	if err != nil {
+		return err
+	}
+	if sub != nil {
+		sub.Status = string(stripe.SubscriptionStatusPastDue)
+		err = model.UpdateSubscription(ctx, svc.settingsStore, sub)
+		if err != nil {
+			return fmt.Errorf("could not update subscription: %w", err)
+		}
+	}
+
+	msg := fmt.Sprintf("Hi %s,\n\nWe were unable to process your payment of %s for AusOcean TV.", subscriber.GivenName, formatAmount(inv.AmountDue, inv.Currency))
+	if inv.NextPaymentAttempt != 0 {
+		msg += fmt.Sprintf(" We will try again on %s.", time.Unix(inv.NextPaymentAttempt, 0).Format("2 January 2006"))
+	} else {
+		msg += " Your subscription will end unless payment is made."
+	}
+	if inv.HostedInvoiceURL != "" {
+		msg += "\n\nTo update your payment details or pay now, visit " + inv.HostedInvoiceURL
+	}
+	return svc.notifier.Send(ctx, subscriber.ID, kindPaymentFailed, msg)
+}
+
+// upcomingRenewal emails a subscriber ahead of a renewal, unless the
+// subscription is not set to renew.
+func (svc *service) upcomingRenewal(ctx context.Context, inv *stripe.Invoice) error {
+	subscriber, sub, err := svc.getStripeSubscription(ctx, inv.Customer)
+	if err != nil {
+		return err
+	}
+	if sub != nil && !sub.Renew {
+		return nil
+	}
+	date := "soon"
+	if inv.NextPaymentAttempt != 0 {
+		date = "on " + time.Unix(inv.NextPaymentAttempt, 0).Format("2 January 2006")
+	}
+	msg := fmt.Sprintf("Hi %s,\n\nYour AusOcean TV subscription will renew %s for %s. No action is required.", subscriber.GivenName, date, formatAmount(inv.AmountDue, inv.Currency))
+	return svc.notifier.Send(ctx, subscriber.ID, kindRenewal, msg)
+}
+
+// getStripeSubscription returns the subscriber for a Stripe customer
+// and their subscription, which is nil if none exists.
+func (svc *service) getStripeSubscription(ctx context.Context, cust *stripe.Customer) (*model.Subscriber, *model.Subscription, error) {
+	if cust == nil {
+		return nil, nil, fmt.Errorf("no customer")
+	}
+	subscriber, err := model.GetSubscriberByPaymentInfo(ctx, svc.settingsStore, cust.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get subscriber for customer %s: %w", cust.ID, err)
+	}
+	subs, err := model.GetSubscriptions(ctx, svc.settingsStore, subscriber.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get subscriptions for subscriber %d: %w", subscriber.ID, err)
+	}
+	for i := range subs {
+		if subs[i].SubscriberID == subscriber.ID && subs[i].FeedID == model.NoFeedID {
+			return subscriber, &subs[i], nil
+		}
+	}
+	return subscriber, nil, nil
+}
+
+// subscriptionClass returns the subscription class for a Stripe
+// billing interval.
+func subscriptionClass(interval stripe.PriceRecurringInterval) string {
+	switch interval {
+	case stripe.PriceRecurringIntervalDay:
+		return model.SubscriptionDay
+	case stripe.PriceRecurringIntervalYear:
+		return model.SubscriptionYear
+	default:
+		return model.SubscriptionMonth
+	}
+}
+
+// priceClass returns the subscription class for a Stripe price. Prices
+// that do not recur are for day passes.
+func priceClass(p *stripe.Price) string {
+	if p.Recurring == nil {
+		return model.SubscriptionDay
+	}
+	return subscriptionClass(p.Recurring.Interval)
+}
+
+// formatAmount formats an amount in the smallest currency unit, e.g.,
+// cents, as a decimal amount with its currency code.
+func formatAmount(amount int64, currency stripe.Currency) string {
+	return fmt.Sprintf("%d.%02d %s", amount/100, amount%100, strings.ToUpper(string(currency)))
+}
@@ -0,0 +1,174 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// alerts.go implements the evaluation of user-defined alert rules against incoming scalars.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// alertRuleKind is the notification kind for alert rules.
+const alertRuleKind notify.Kind = "alert-rule"
+
+// alertRuleCacheTTL is the time for which the enabled alert rules of
+// a site are cached.
+const alertRuleCacheTTL = time.Minute
+
+// cachedAlertRules holds the cached enabled alert rules of a site.
+type cachedAlertRules struct {
+	rules   []model.AlertRule
+	expires time.Time
+}
+
+var (
+	alertRuleMu    sync.Mutex
+	alertRuleCache = map[int64]cachedAlertRules{}
+)
+
+// siteAlertRules returns the enabled alert rules for a site. Since
+// this is called for every scalar received, rules are cached per site
+// for alertRuleCacheTTL, so changes to a site's rules take effect
+// within that time.
+func siteAlertRules(ctx context.Context, skey int64) ([]model.AlertRule, error) {
+	alertRuleMu.Lock()
+	c, ok := alertRuleCache[skey]
+	alertRuleMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.rules, nil
+	}
+
+	rules, err := model.GetEnabledAlertRulesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		return nil, err
+	}
+	alertRuleMu.Lock()
+	alertRuleCache[skey] = cachedAlertRules{rules: rules, expires: time.Now().Add(alertRuleCacheTTL)}
+	alertRuleMu.Unlock()
+	return rules, nil
+}
+
+// cacheAlertRule replaces a site's cached rule with its updated state.
+// Cached rules are never modified in place, since they are shared.
+func cacheAlertRule(r *model.AlertRule) {
+	alertRuleMu.Lock()
+	defer alertRuleMu.Unlock()
+	c, ok := alertRuleCache[r.Skey]
+	if !ok {
+		return
+	}
+	rules := make([]model.AlertRule, len(c.rules))
+	copy(rules, c.rules)
+	for i := range rules {
+		if rules[i].ID == r.ID {
+			rules[i] = *r
+		}
+	}
+	alertRuleCache[r.Skey] = cachedAlertRules{rules: rules, expires: c.expires}
+}
+
+// evaluateAlertRules evaluates a scalar value written for a device pin
+// against the enabled alert rules for the pin, notifying the site and
+// the rule's recipients when a rule trips or clears. Values are
+// transformed by the pin's sensor, if any, so that rule thresholds
+// are in the sensor's units. Rules are first evaluated against their
+// cached state, and only rules whose state changes are evaluated
+// again and updated in the datastore. Errors are logged rather than
+// returned, since alerts must not prevent data from being received.
+func evaluateAlertRules(ctx context.Context, dev *model.Device, pin string, n float64, ts int64) {
+	cached, err := siteAlertRules(ctx, dev.Skey)
+	if err != nil {
+		backend.Printf(ctx, "could not get alert rules for site %d: %v", dev.Skey, err)
+		return
+	}
+	var rules []model.AlertRule
+	for _, r := range cached {
+		if r.Mac == dev.Mac && r.Pin == pin {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	value, units := n, ""
+	sensor, err := model.GetSensorV2(ctx, settingsStore, dev.Mac, pin)
+	switch {
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+	case err != nil:
+		backend.Printf(ctx, "could not get sensor %s.%s: %v", dev.Hex(), pin, err)
+		return
+	default:
+		value, err = sensor.Transform(n)
+		if err != nil {
+			backend.Printf(ctx, "could not transform value for %s.%s: %v", dev.Hex(), pin, err)
+			return
+		}
+		units = sensor.Units
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		since, tripped := r.Since, r.Tripped
+		_, err := r.Evaluate(value, ts)
+		if err != nil {
+			backend.Printf(ctx, "could not evaluate alert rule %d: %v", r.ID, err)
+			continue
+		}
+		if r.Since == since && r.Tripped == tripped {
+			continue
+		}
+		r, transition, err := model.EvaluateAlertRule(ctx, settingsStore, r.ID, value, ts)
+		if err != nil {
+			backend.Printf(ctx, "could not update alert rule %d: %v", rules[i].ID, err)
+			continue
+		}
+		cacheAlertRule(r)
+
+		var msg string
+		switch transition {
+		case model.AlertRuleTripped:
+			msg = fmt.Sprintf("alert %q tripped for device %s (%s): %s is %g %s", r.Name, dev.Name, dev.MAC(), pin, value, units)
+		case model.AlertRuleCleared:
+			msg = fmt.Sprintf("alert %q cleared for device %s (%s): %s is %g %s", r.Name, dev.Name, dev.MAC(), pin, value, units)
+		default:
+			continue
+		}
+		backend.Printf(ctx, "%s", msg)
+		if notifier == nil {
+			continue
+		}
+		sev := notify.SeverityWarning
+		nctx := notify.NewContext(ctx, &notify.Data{Device: dev, Severity: &sev, Recipients: r.RecipientList(), Vars: map[string]string{"Rule": r.String()}})
+		err = notifier.Send(nctx, dev.Skey, alertRuleKind, msg)
+		if err != nil {
+			backend.Printf(ctx, "could not send alert rule notification: %v", err)
+		}
+	}
+}
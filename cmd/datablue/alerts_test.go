@@ -0,0 +1,80 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestEvaluateAlertRules tests that alert rules are tripped and
+// cleared, and that their cached state follows the stored state.
+func TestEvaluateAlertRules(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	mediaStore, settingsStore = store, store
+	notifier = nil
+	alertRuleCache = map[int64]cachedAlertRules{}
+
+	dev := &model.Device{Skey: 1, Mac: 1, Name: "test"}
+	rule := &model.AlertRule{Skey: 1, Mac: 1, Pin: "A0", Name: "Low battery", Comparison: "<", Threshold: 11.5, Duration: 10, Enabled: true}
+	err = model.CreateAlertRule(ctx, store, rule)
+	if err != nil {
+		t.Fatalf("CreateAlertRule returned error: %v", err)
+	}
+
+	tests := []struct {
+		value       float64
+		ts          int64
+		wantSince   int64
+		wantTripped bool
+	}{
+		{value: 12, ts: 100},
+		{value: 11, ts: 110, wantSince: 110},
+		{value: 11, ts: 115, wantSince: 110},
+		{value: 11, ts: 120, wantSince: 110, wantTripped: true},
+		{value: 12, ts: 130},
+	}
+	for i, tt := range tests {
+		evaluateAlertRules(ctx, dev, "A0", tt.value, tt.ts)
+		r, err := model.GetAlertRule(ctx, store, rule.ID)
+		if err != nil {
+			t.Fatalf("GetAlertRule returned error: %v", err)
+		}
+		if r.Since != tt.wantSince || r.Tripped != tt.wantTripped {
+			t.Errorf("test %d: unexpected rule state: got since %d, tripped %t, want since %d, tripped %t", i, r.Since, r.Tripped, tt.wantSince, tt.wantTripped)
+		}
+		cached, err := siteAlertRules(ctx, dev.Skey)
+		if err != nil {
+			t.Fatalf("siteAlertRules returned error: %v", err)
+		}
+		if len(cached) != 1 || cached[0].Since != r.Since || cached[0].Tripped != r.Tripped {
+			t.Errorf("test %d: cached rules %v do not match stored rule %v", i, cached, r)
+		}
+	}
+}
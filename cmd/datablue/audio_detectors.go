@@ -0,0 +1,140 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// audio_detectors.go implements the built-in audio detectors, which
+// use simple signal processing rather than ML models.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/mjibson/go-dsp/fft"
+	"github.com/mjibson/go-dsp/window"
+)
+
+// Snapping shrimp detection parameters.
+const (
+	snapFactor     = 8.0   // Threshold for snaps as a multiple of the median amplitude.
+	snapRefractory = 0.005 // Minimum time between snaps in seconds.
+	snapMinRate    = 8000  // Minimum sample rate in Hz, since snaps are broadband clicks.
+	snapMinLevel   = 1e-4  // Minimum median amplitude, below which audio is considered silent.
+)
+
+// shrimpDetector measures the intensity of snapping shrimp noise as
+// the number of snaps per second. Snaps are detected as impulses in
+// the first difference of the audio, which emphasizes the high
+// frequencies of snaps over the background noise.
+type shrimpDetector struct{}
+
+// Detect implements audioDetector.Detect.
+func (shrimpDetector) Detect(ctx context.Context, a *audioClip) (*detection, error) {
+	if a.Rate < snapMinRate || len(a.Samples) < 2 {
+		return nil, nil
+	}
+	diff := make([]float64, len(a.Samples)-1)
+	for i := range diff {
+		diff[i] = math.Abs(a.Samples[i+1] - a.Samples[i])
+	}
+	sorted := append([]float64(nil), diff...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if median < snapMinLevel {
+		return &detection{Value: 0}, nil
+	}
+
+	threshold := snapFactor * median
+	refractory := int(snapRefractory * float64(a.Rate))
+	var snaps int
+	last := -refractory
+	for i, d := range diff {
+		if d > threshold && i-last >= refractory {
+			snaps++
+			last = i
+		}
+	}
+	return &detection{Value: float64(snaps) / a.Duration()}, nil
+}
+
+// Vessel noise detection parameters.
+const (
+	vesselBandLow   = 50.0   // Lower bound of the vessel noise band in Hz.
+	vesselBandHigh  = 1000.0 // Upper bound of the vessel noise band in Hz.
+	vesselLevel     = -50.0  // Band level in dBFS above which vessel noise is detected.
+	vesselDominance = 0.6    // Fraction of power in the band above which vessel noise is detected.
+	vesselFrame     = 4096   // FFT frame size in samples.
+)
+
+// vesselDetector measures the level of low-frequency noise in the
+// band typical of vessel engines and propellers, in dB relative to
+// full scale, i.e., a full-scale sine wave in the band is 0 dBFS.
+// Vessel noise is detected when the band is both loud and dominates
+// the spectrum.
+type vesselDetector struct{}
+
+// Detect implements audioDetector.Detect.
+func (vesselDetector) Detect(ctx context.Context, a *audioClip) (*detection, error) {
+	if a.Rate < 2*vesselBandHigh || len(a.Samples) < vesselFrame {
+		return nil, nil
+	}
+
+	// Average the power spectra of non-overlapping Hann-windowed frames.
+	hann := window.Hann(vesselFrame)
+	var wss float64 // Sum of squares of the window, for normalization.
+	for _, w := range hann {
+		wss += w * w
+	}
+	frame := make([]float64, vesselFrame)
+	power := make([]float64, vesselFrame/2)
+	frames := len(a.Samples) / vesselFrame
+	for f := 0; f < frames; f++ {
+		for i := range frame {
+			frame[i] = a.Samples[f*vesselFrame+i] * hann[i]
+		}
+		spec := fft.FFTReal(frame)
+		for k := 1; k < len(power); k++ {
+			// Scaled so that the power summed over bins is the mean square of the signal.
+			power[k] += 2 * math.Pow(cmplx.Abs(spec[k]), 2) / (vesselFrame * wss) / float64(frames)
+		}
+	}
+
+	var band, total float64
+	binWidth := float64(a.Rate) / vesselFrame
+	for k, p := range power {
+		total += p
+		if f := float64(k) * binWidth; f >= vesselBandLow && f <= vesselBandHigh {
+			band += p
+		}
+	}
+	if band == 0 {
+		return nil, nil // Silence.
+	}
+
+	// A full-scale sine wave has a mean square of 0.5.
+	level := 10 * math.Log10(band/0.5)
+	d := &detection{Value: level}
+	if level > vesselLevel && band/total > vesselDominance {
+		d.Label = fmt.Sprintf("vessel noise at %.1f dBFS in %g-%g Hz band", level, vesselBandLow, vesselBandHigh)
+	}
+	return d, nil
+}
@@ -0,0 +1,116 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// cbor.go implements the optional CBOR encoding of device requests
+// and responses, which is more compact than JSON. Devices opt in by
+// sending "Accept: application/cbor", or by sending a CBOR body with
+// "Content-Type: application/cbor". Messages have the same keys and
+// values as their JSON counterparts. See protocol.cddl.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	mimeJSON = "application/json"
+	mimeCBOR = "application/cbor"
+)
+
+// cborEnc encodes floats and integers in their shortest form.
+var cborEnc, _ = cbor.EncOptions{ShortestFloat: cbor.ShortestFloat16}.EncMode()
+
+// wantsCBOR returns true if the client accepts or sends CBOR.
+func wantsCBOR(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), mimeCBOR) || isCBOR(r)
+}
+
+// isCBOR returns true if the request body is CBOR.
+func isCBOR(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), mimeCBOR)
+}
+
+// writeResponse writes a JSON response, or its CBOR equivalent if the
+// client wants CBOR. Should transcoding fail, the JSON is written.
+func writeResponse(w http.ResponseWriter, r *http.Request, resp []byte) {
+	if !wantsCBOR(r) {
+		w.Write(resp)
+		return
+	}
+	b, err := toCBOR(resp)
+	if err != nil {
+		log.Printf("could not encode CBOR response: %v", err)
+		w.Write(resp)
+		return
+	}
+	w.Header().Set("Content-Type", mimeCBOR)
+	w.Write(b)
+}
+
+// toCBOR transcodes JSON to CBOR. Integral numbers are encoded as
+// CBOR integers rather than floats.
+func toCBOR(j []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode JSON: %w", err)
+	}
+	return cborEnc.Marshal(fromNumbers(v))
+}
+
+// fromNumbers recursively replaces json.Number values with int64 or
+// float64 values.
+func fromNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = fromNumbers(v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = fromNumbers(v[i])
+		}
+	}
+	return v
+}
+
+// unmarshalBody decodes a JSON or CBOR request body according to
+// the request's content type.
+func unmarshalBody(r *http.Request, body []byte, v interface{}) error {
+	if isCBOR(r) {
+		return cbor.Unmarshal(body, v)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,196 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// command.go implements device commands, which services queue and
+// devices fetch and acknowledge when polling.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errInvalidCmd   = errors.New("invalid command")
+)
+
+// commandResponse is the JSON representation of a command returned to services.
+type commandResponse struct {
+	ID        int64  `json:"id"`
+	MAC       string `json:"ma"`
+	Command   string `json:"cmd"`
+	Payload   string `json:"pl,omitempty"`
+	Issued    int64  `json:"issued"`
+	Delivered int64  `json:"delivered,omitempty"`
+	Acked     int64  `json:"acked,omitempty"`
+	Result    string `json:"result,omitempty"`
+}
+
+// commandHandler handles requests from services to queue a command,
+// or to get a command's status. Requests are authorized by a JWT
+// signed with the cron secret, whose skey claim must match the site
+// of the device.
+//
+// POST params:
+// - ma: MAC address.
+// - cmd: Command.
+// - pl: Payload (optional).
+//
+// GET params:
+// - ma: MAC address.
+// - id: Command ID.
+func commandHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	setup(ctx)
+
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	if err != nil {
+		log.Printf("command request from %s has invalid claims: %v", r.RemoteAddr, err)
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	skey, ok := claims["skey"].(float64)
+	if !ok {
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	ma := r.FormValue("ma")
+	dev, err := model.GetDevice(ctx, settingsStore, model.MacEncode(ma))
+	if err != nil {
+		backend.WriteError(w, http.StatusNotFound, model.ErrDeviceNotFound)
+		return
+	}
+	if dev.Skey != int64(skey) {
+		backend.WriteError(w, http.StatusForbidden, errUnauthorized)
+		return
+	}
+
+	var c *model.DeviceCommand
+	switch r.Method {
+	case http.MethodPost:
+		c = &model.DeviceCommand{Mac: dev.Mac, Skey: dev.Skey, Command: r.FormValue("cmd"), Payload: r.FormValue("pl")}
+		if c.Command == "" {
+			backend.WriteError(w, http.StatusBadRequest, errInvalidCmd)
+			return
+		}
+		err = model.CreateDeviceCommand(ctx, settingsStore, c)
+		if errors.Is(err, model.ErrTooManyCommands) {
+			backend.WriteError(w, http.StatusTooManyRequests, err)
+			return
+		}
+		if err != nil {
+			backend.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		log.Printf("queued command %s (%d) for device %s", c.Command, c.ID, ma)
+
+	case http.MethodGet:
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err == nil {
+			c, err = model.GetDeviceCommand(ctx, settingsStore, dev.Mac, id)
+		}
+		if err != nil {
+			backend.WriteError(w, http.StatusNotFound, errInvalidCmd)
+			return
+		}
+
+	default:
+		backend.WriteError(w, http.StatusMethodNotAllowed, errInvalidAPI)
+		return
+	}
+
+	resp, _ := json.Marshal(commandResponse{
+		ID:        c.ID,
+		MAC:       ma,
+		Command:   c.Command,
+		Payload:   c.Payload,
+		Issued:    c.Issued.Unix(),
+		Delivered: unixOrZero(c.Delivered),
+		Acked:     unixOrZero(c.Acked),
+		Result:    c.Result,
+	})
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// unixOrZero returns the Unix time of t, or zero if t is zero.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// processCommands acknowledges the commands identified by the "ca"
+// params, then adds the device's pending commands, if any, to the
+// poll response map as "cm". Each ca param takes the form id or
+// id:result. Pending commands are resent on every poll until they are
+// acknowledged.
+func processCommands(ctx context.Context, dev *model.Device, acks []string, respMap map[string]interface{}) error {
+	for _, ack := range acks {
+		idStr, result, _ := strings.Cut(ack, ":")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			log.Printf("device %s sent invalid command ack: %s", dev.MAC(), ack)
+			continue
+		}
+		err = model.AckDeviceCommand(ctx, settingsStore, dev.Mac, id, result)
+		if err != nil {
+			log.Printf("could not ack command %d for device %s: %v", id, dev.MAC(), err)
+		}
+	}
+
+	cmds, err := model.GetDeviceCommands(ctx, settingsStore, dev.Mac, true)
+	if err != nil {
+		return fmt.Errorf("could not get commands for device %s: %w", dev.MAC(), err)
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	var cm []map[string]interface{}
+	for i := range cmds {
+		c := &cmds[i]
+		cm = append(cm, map[string]interface{}{"id": c.ID, "cmd": c.Command, "pl": c.Payload})
+		if !c.Delivered.IsZero() {
+			continue
+		}
+		c.Delivered = time.Now()
+		err = model.PutDeviceCommand(ctx, settingsStore, c)
+		if err != nil {
+			log.Printf("could not update command %d for device %s: %v", c.ID, dev.MAC(), err)
+		}
+	}
+	respMap["cm"] = cm
+	return nil
+}
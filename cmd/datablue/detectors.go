@@ -0,0 +1,323 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// detectors.go implements hooks for running detectors of audio events,
+// e.g., snapping shrimp or vessel noise, on audio received via /mts.
+// Received audio is queued for detection, which is run by /detect.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ausocean/av/codec/adpcm"
+	"github.com/ausocean/av/codec/codecutil"
+	"github.com/ausocean/av/container/mts"
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// audioClip is decoded audio on which detectors are run.
+type audioClip struct {
+	Rate    int       // Sample rate in Hz.
+	Samples []float64 // Samples of the first channel, normalized to [-1, 1).
+}
+
+// Duration returns the duration of the audio in seconds.
+func (a *audioClip) Duration() float64 {
+	return float64(len(a.Samples)) / float64(a.Rate)
+}
+
+// detection is the result of running a detector on audio.
+type detection struct {
+	Value float64 // Value, e.g., an intensity or level, stored as a scalar.
+	Label string  // Description of a detected event, if any, stored as text.
+}
+
+// audioDetector is implemented by detectors of events in audio.
+// Detectors may be simple signal processing, like the built-in
+// detectors, or may call out to ML models.
+type audioDetector interface {
+	// Detect returns the detection for the given audio, or nil if the
+	// detector is not applicable, e.g., the sample rate is too low.
+	Detect(ctx context.Context, a *audioClip) (*detection, error)
+}
+
+// registeredDetector is an audio detector along with the pins for its
+// results. The value of a detection for sound pin S<n> is written as
+// a scalar to pin X<pin+n> and its label, if any, is written as text
+// to pin T<pin+n>.
+type registeredDetector struct {
+	pin      int
+	detector audioDetector
+}
+
+// audioDetectors are the registered detectors by name. Devices enable
+// detectors by listing their names in the <MAC>.detectors variable,
+// e.g., "shrimp,vessel".
+var audioDetectors = map[string]registeredDetector{
+	"shrimp": {pin: 70, detector: shrimpDetector{}},
+	"vessel": {pin: 80, detector: vesselDetector{}},
+}
+
+// Detection queue parameters.
+const (
+	detectorCacheTTL = 5 * time.Minute // Time for which the detectors enabled for a device are cached.
+	detectBatch      = 20              // Maximum detections run per request.
+	detectStale      = 5 * time.Minute // Time after which claimed detections are reclaimed.
+)
+
+var errUnsupportedAudio = errors.New("unsupported audio")
+
+// cachedDetectors are the detectors enabled for a device and when they expire.
+type cachedDetectors struct {
+	names   []string
+	expires time.Time
+}
+
+var (
+	detectorMu    sync.Mutex
+	detectorCache = map[int64]cachedDetectors{}
+)
+
+// enabledDetectors returns the names of the detectors enabled for a
+// device. Since this is called for every audio clip received, the
+// names are cached for detectorCacheTTL, so changes to a device's
+// detectors take effect within that time.
+func enabledDetectors(ctx context.Context, dev *model.Device) []string {
+	detectorMu.Lock()
+	c, ok := detectorCache[dev.Mac]
+	detectorMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.names
+	}
+
+	var names []string
+	v, err := model.GetVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".detectors")
+	switch {
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+	case err != nil:
+		backend.Printf(ctx, "could not get detectors for %s: %v", dev.Hex(), err)
+		return nil
+	default:
+		for _, name := range strings.Split(v.Value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	detectorMu.Lock()
+	detectorCache[dev.Mac] = cachedDetectors{names: names, expires: time.Now().Add(detectorCacheTTL)}
+	detectorMu.Unlock()
+	return names
+}
+
+// queueDetections queues detections for the audio persisted for each
+// sound pin of a device, if the device has detectors, so that
+// detection does not delay responding to the device. The queue is
+// processed by detectHandler.
+func queueDetections(ctx context.Context, dev *model.Device, ranges map[string][2]int64) {
+	if len(enabledDetectors(ctx, dev)) == 0 {
+		return
+	}
+	for pin, r := range ranges {
+		if pin[0] != 'S' {
+			continue
+		}
+		err := model.QueueDetection(ctx, mediaStore, model.ToMID(dev.MAC(), pin), r[0], r[1])
+		if err != nil {
+			backend.Printf(ctx, "could not queue detection for %s.%s: %v", dev.Hex(), pin, err)
+		}
+	}
+}
+
+// detectHandler handles requests to run queued detections. It is
+// invoked periodically by App Engine cron (see cron.yaml), and runs
+// up to detectBatch detections per request. Requests must have the
+// X-Appengine-Cron header or else a JWT signed with the cron secret.
+func detectHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	setup(ctx)
+
+	if !cronRequest(r) {
+		log.Printf("detect request from %s is unauthorized", r.RemoteAddr)
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	ds, err := model.GetPendingDetections(ctx, mediaStore, detectBatch)
+	if err != nil {
+		backend.WriteError(w, http.StatusInternalServerError, fmt.Errorf("could not get pending detections: %w", err))
+		return
+	}
+	for _, d := range ds {
+		_, err := model.ClaimDetection(ctx, mediaStore, d.MID, d.Start, time.Now().Add(-detectStale))
+		if errors.Is(err, model.ErrDetectionClaimed) {
+			continue
+		}
+		if err != nil {
+			backend.Printf(ctx, "could not claim detection %d.%d: %v", d.MID, d.Start, err)
+			continue
+		}
+		err = detect(ctx, &d)
+		if err != nil {
+			// Retried once the claim is stale.
+			backend.Printf(ctx, "could not run detection %d.%d: %v", d.MID, d.Start, err)
+			continue
+		}
+		err = model.DeleteDetection(ctx, mediaStore, d.MID, d.Start)
+		if err != nil {
+			backend.Printf(ctx, "could not delete detection %d.%d: %v", d.MID, d.Start, err)
+		}
+	}
+}
+
+// detect runs the detectors of a queued detection on its audio. An
+// error is returned only if the detection should be retried.
+func detect(ctx context.Context, d *model.Detection) error {
+	ma, pin := model.FromMID(d.MID)
+	dev, err := model.GetDevice(ctx, settingsStore, model.MacEncode(ma))
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil // Deleted device.
+	}
+	if err != nil {
+		return fmt.Errorf("could not get device: %w", err)
+	}
+	names := enabledDetectors(ctx, dev)
+	if len(names) == 0 {
+		return nil
+	}
+	media, err := model.GetMtsMedia(ctx, mediaStore, d.MID, nil, []int64{d.Start, d.End + 1})
+	if err != nil {
+		return fmt.Errorf("could not get media: %w", err)
+	}
+	var clip []byte
+	for _, m := range media {
+		clip = append(clip, m.Clip...)
+	}
+	a, err := decodeAudio(clip)
+	if err != nil {
+		backend.Printf(ctx, "could not decode audio from %s.%s for detection: %v", dev.Hex(), pin, err)
+		return nil
+	}
+	runDetectors(ctx, dev, pin, d.Start, a, names)
+	return nil
+}
+
+// runDetectors runs the named detectors on audio received for a sound
+// pin of a device with the given timestamp, storing the detections and
+// evaluating alert rules for their values. Errors are logged rather
+// than returned, so that one failing detector does not prevent others
+// from being run.
+func runDetectors(ctx context.Context, dev *model.Device, pin string, ts int64, a *audioClip, names []string) {
+	n, err := strconv.Atoi(pin[1:])
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		rd, ok := audioDetectors[name]
+		if !ok {
+			backend.Printf(ctx, "device %s has unknown detector %s", dev.Hex(), name)
+			continue
+		}
+		d, err := rd.detector.Detect(ctx, a)
+		if err != nil {
+			backend.Printf(ctx, "%s detector failed for %s.%s: %v", name, dev.Hex(), pin, err)
+			continue
+		}
+		if d == nil {
+			continue
+		}
+
+		xpin := "X" + strconv.Itoa(rd.pin+n)
+		err = model.PutScalar(ctx, mediaStore, &model.Scalar{ID: model.ToSID(dev.MAC(), xpin), Timestamp: ts, Value: d.Value})
+		if err != nil {
+			backend.Printf(ctx, "could not put %s detection for %s.%s: %v", name, dev.Hex(), pin, err)
+			continue
+		}
+		evaluateAlertRules(ctx, dev, xpin, d.Value, ts)
+		if d.Label == "" {
+			continue
+		}
+		backend.Printf(ctx, "%s detected %q on %s.%s", name, d.Label, dev.Hex(), pin)
+		tpin := "T" + strconv.Itoa(rd.pin+n)
+		err = model.WriteText(ctx, mediaStore, &model.Text{MID: model.ToMID(dev.MAC(), tpin), Timestamp: ts, Data: d.Label, Type: "text/plain"})
+		if err != nil {
+			backend.Printf(ctx, "could not write %s detection for %s.%s: %v", name, dev.Hex(), pin, err)
+		}
+	}
+}
+
+// decodeAudio decodes the audio in an MTS clip, using the clip's
+// metadata for the audio parameters. Only 16-bit PCM and ADPCM are
+// supported, and only the first channel is decoded.
+func decodeAudio(clip []byte) (*audioClip, error) {
+	c, err := mts.Extract(clip)
+	if err != nil {
+		return nil, fmt.Errorf("could not extract media: %w", err)
+	}
+	if len(c.Frames()) == 0 {
+		return nil, errors.New("no audio frames")
+	}
+	meta := c.Frames()[0].Meta
+	rate, err := strconv.Atoi(meta["sampleRate"])
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %q", meta["sampleRate"])
+	}
+	channels, err := strconv.Atoi(meta["channels"])
+	if err != nil || channels < 1 {
+		return nil, fmt.Errorf("invalid number of channels: %q", meta["channels"])
+	}
+
+	audio := c.Bytes()
+	switch meta["codec"] {
+	case codecutil.PCM:
+		if meta["bitDepth"] != "16" {
+			return nil, fmt.Errorf("%w: %s-bit PCM", errUnsupportedAudio, meta["bitDepth"])
+		}
+	case codecutil.ADPCM:
+		decoded := bytes.NewBuffer(make([]byte, 0, len(audio)*4))
+		_, err = adpcm.NewDecoder(decoded).Write(audio)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode ADPCM: %w", err)
+		}
+		audio = decoded.Bytes()
+	default:
+		return nil, fmt.Errorf("%w: codec %q", errUnsupportedAudio, meta["codec"])
+	}
+
+	stride := 2 * channels
+	a := &audioClip{Rate: rate, Samples: make([]float64, 0, len(audio)/stride)}
+	for i := 0; i+1 < len(audio); i += stride {
+		a.Samples = append(a.Samples, float64(int16(binary.LittleEndian.Uint16(audio[i:])))/(1<<15))
+	}
+	return a, nil
+}
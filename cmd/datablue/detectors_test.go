@@ -0,0 +1,246 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ausocean/av/codec/codecutil"
+	"github.com/ausocean/av/container/mts"
+	"github.com/ausocean/av/container/mts/meta"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+	"github.com/ausocean/utils/logging"
+)
+
+// sine returns n samples of a sine wave of the given frequency and amplitude.
+func sine(rate, n int, freq, amp float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+	}
+	return s
+}
+
+// TestShrimpDetector tests measuring snaps per second.
+func TestShrimpDetector(t *testing.T) {
+	const rate = 16000
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]float64, rate)
+	for i := range noise {
+		noise[i] = 0.01 * (2*rng.Float64() - 1)
+	}
+	snaps := append([]float64(nil), noise...)
+	for i := 0; i < len(snaps); i += rate / 10 {
+		snaps[i] = 0.9
+	}
+
+	tests := []struct {
+		desc string
+		clip audioClip
+		want *detection
+	}{
+		{desc: "snaps", clip: audioClip{Rate: rate, Samples: snaps}, want: &detection{Value: 10}},
+		{desc: "noise", clip: audioClip{Rate: rate, Samples: noise}, want: &detection{Value: 0}},
+		{desc: "silence", clip: audioClip{Rate: rate, Samples: make([]float64, rate)}, want: &detection{Value: 0}},
+		{desc: "low sample rate", clip: audioClip{Rate: 4000, Samples: snaps}, want: nil},
+	}
+	for _, tt := range tests {
+		got, err := shrimpDetector{}.Detect(context.Background(), &tt.clip)
+		if err != nil {
+			t.Errorf("%s: Detect returned error: %v", tt.desc, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%s: Detect returned %+v, want %+v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+// TestVesselDetector tests measuring the level of the vessel noise
+// band and detecting when it is loud and dominant.
+func TestVesselDetector(t *testing.T) {
+	const rate = 8000
+	tests := []struct {
+		desc      string
+		clip      audioClip
+		wantNil   bool
+		wantLevel float64 // In dBFS.
+		wantLabel bool
+	}{
+		{desc: "loud in band", clip: audioClip{Rate: rate, Samples: sine(rate, rate, 200, 0.5)}, wantLevel: -6, wantLabel: true},
+		{desc: "quiet in band", clip: audioClip{Rate: rate, Samples: sine(rate, rate, 200, 0.001)}, wantLevel: -60},
+		{desc: "out of band", clip: audioClip{Rate: rate, Samples: sine(rate, rate, 3000, 0.5)}, wantLevel: math.NaN()},
+		{desc: "silence", clip: audioClip{Rate: rate, Samples: make([]float64, rate)}, wantNil: true},
+		{desc: "low sample rate", clip: audioClip{Rate: 1000, Samples: sine(1000, rate, 200, 0.5)}, wantNil: true},
+		{desc: "too short", clip: audioClip{Rate: rate, Samples: sine(rate, vesselFrame-1, 200, 0.5)}, wantNil: true},
+	}
+	for _, tt := range tests {
+		got, err := vesselDetector{}.Detect(context.Background(), &tt.clip)
+		if err != nil {
+			t.Errorf("%s: Detect returned error: %v", tt.desc, err)
+			continue
+		}
+		if tt.wantNil {
+			if got != nil {
+				t.Errorf("%s: Detect returned %+v, want nil", tt.desc, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("%s: Detect returned nil", tt.desc)
+			continue
+		}
+		if !math.IsNaN(tt.wantLevel) && math.Abs(got.Value-tt.wantLevel) > 1 {
+			t.Errorf("%s: Detect returned level %.1f dBFS, want %.1f dBFS", tt.desc, got.Value, tt.wantLevel)
+		}
+		if (got.Label != "") != tt.wantLabel {
+			t.Errorf("%s: Detect returned label %q, want label: %t", tt.desc, got.Label, tt.wantLabel)
+		}
+	}
+}
+
+// nopCloser adds a no-op Close method to a bytes.Buffer.
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+// TestDecodeAudio tests decoding the audio of MTS clips.
+func TestDecodeAudio(t *testing.T) {
+	const rate = 8000
+	samples := sine(rate, rate/10, 440, 0.5)
+	pcm := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(s*(1<<15))))
+	}
+
+	encode := func(codec, bitDepth string) []byte {
+		mts.Meta = meta.New()
+		mts.Meta.Add("codec", codec)
+		mts.Meta.Add("sampleRate", "8000")
+		mts.Meta.Add("channels", "1")
+		mts.Meta.Add("bitDepth", bitDepth)
+		var buf bytes.Buffer
+		e, err := mts.NewEncoder(nopCloser{&buf}, (*logging.TestLogger)(t), mts.MediaType(mts.EncodePCM), mts.PacketBasedPSI(1))
+		if err != nil {
+			t.Fatalf("could not create encoder: %v", err)
+		}
+		_, err = e.Write(pcm)
+		if err != nil {
+			t.Fatalf("could not encode audio: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	a, err := decodeAudio(encode(codecutil.PCM, "16"))
+	if err != nil {
+		t.Fatalf("decodeAudio returned error: %v", err)
+	}
+	if a.Rate != rate || len(a.Samples) != len(samples) {
+		t.Fatalf("decodeAudio returned %d samples at %d Hz, want %d at %d Hz", len(a.Samples), a.Rate, len(samples), rate)
+	}
+	for i := range samples {
+		if math.Abs(a.Samples[i]-samples[i]) > 1.0/(1<<14) {
+			t.Fatalf("sample %d: got %f, want %f", i, a.Samples[i], samples[i])
+		}
+	}
+
+	_, err = decodeAudio(encode(codecutil.PCM, "8"))
+	if !errors.Is(err, errUnsupportedAudio) {
+		t.Errorf("decodeAudio of 8-bit PCM returned %v, want %v", err, errUnsupportedAudio)
+	}
+	_, err = decodeAudio(encode(codecutil.H264, "16"))
+	if !errors.Is(err, errUnsupportedAudio) {
+		t.Errorf("decodeAudio of H.264 returned %v, want %v", err, errUnsupportedAudio)
+	}
+}
+
+// fixedDetector is a detector that returns a fixed detection.
+type fixedDetector struct {
+	d   *detection
+	err error
+}
+
+// Detect implements audioDetector.Detect.
+func (f fixedDetector) Detect(ctx context.Context, a *audioClip) (*detection, error) {
+	return f.d, f.err
+}
+
+// TestRunDetectors tests that detections are stored for the pins of
+// the detectors that produced them.
+func TestRunDetectors(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	mediaStore, settingsStore = store, store
+
+	saved := audioDetectors
+	defer func() { audioDetectors = saved }()
+	audioDetectors = map[string]registeredDetector{
+		"level":   {pin: 70, detector: fixedDetector{d: &detection{Value: 12}}},
+		"event":   {pin: 80, detector: fixedDetector{d: &detection{Value: 3, Label: "event"}}},
+		"skipped": {pin: 90, detector: fixedDetector{}},
+		"failed":  {pin: 100, detector: fixedDetector{err: errors.New("detector failed")}},
+	}
+
+	const ts = 1700000000
+	dev := &model.Device{Skey: 1, Mac: 1}
+	runDetectors(ctx, dev, "S1", ts, &audioClip{Rate: 8000}, []string{"level", "event", "skipped", "failed", "unknown"})
+
+	for _, tt := range []struct {
+		pin   string
+		value float64
+	}{{"X71", 12}, {"X81", 3}} {
+		scalars, err := model.GetScalars(ctx, store, model.ToSID(dev.MAC(), tt.pin), []int64{ts, ts + 1})
+		if err != nil {
+			t.Fatalf("GetScalars returned error: %v", err)
+		}
+		if len(scalars) != 1 || scalars[0].Value != tt.value {
+			t.Errorf("unexpected scalars for %s: got %v, want value %v", tt.pin, scalars, tt.value)
+		}
+	}
+	texts, err := model.GetText(ctx, store, model.ToMID(dev.MAC(), "T81"), []int64{ts, ts + 1})
+	if err != nil {
+		t.Fatalf("GetText returned error: %v", err)
+	}
+	if len(texts) != 1 || texts[0].Data != "event" {
+		t.Errorf("unexpected texts for T81: got %v, want event", texts)
+	}
+
+	for _, pin := range []string{"X91", "X101"} {
+		scalars, err := model.GetScalars(ctx, store, model.ToSID(dev.MAC(), pin), []int64{ts, ts + 1})
+		if err != nil {
+			t.Fatalf("GetScalars returned error: %v", err)
+		}
+		if len(scalars) != 0 {
+			t.Errorf("unexpected scalars for %s: %v", pin, scalars)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// forward.go implements the forwarding of sensor readings to external endpoints.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/openfish/datastore"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// forwardingKind is the notification kind for failing forwardings.
+	forwardingKind notify.Kind = "forwarding"
+
+	// forwardLag is how far behind the present readings are forwarded,
+	// so that readings sent late by devices are not skipped.
+	forwardLag = 5 * time.Minute
+
+	// forwardTimeout is the timeout for a delivery to an endpoint.
+	forwardTimeout = 30 * time.Second
+
+	// forwardFailures is the number of consecutive failed deliveries
+	// after which ops are notified.
+	forwardFailures = 3
+
+	// erddapBatch is the maximum number of readings sent in one ERDDAP
+	// insert request, which are sent as URL query params.
+	erddapBatch = 100
+)
+
+// reading is a sensor reading to be forwarded, with its value
+// transformed and converted to the canonical units of the sensor's
+// quantity.
+type reading struct {
+	ts     int64
+	sensor model.ForwardedSensor
+	name   string
+	units  string
+	value  float64
+}
+
+// errNonPublicAddress is returned when a destination resolves to an
+// address that is not public.
+var errNonPublicAddress = errors.New("destination address is not public")
+
+// forwardDialer dials destinations, refusing non-public addresses, so
+// that forwardings cannot reach internal services even when the host
+// of a destination resolves to one.
+var forwardDialer = &net.Dialer{
+	Timeout: forwardTimeout,
+	Control: func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !model.PublicIP(ip) {
+			return fmt.Errorf("%w: %s", errNonPublicAddress, host)
+		}
+		return nil
+	},
+}
+
+// forwardClient is the HTTP client for ERDDAP and HTTP destinations.
+// Redirects are not followed, and so fail as non-2xx responses.
+var forwardClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:         forwardDialer.DialContext,
+		TLSHandshakeTimeout: forwardTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Timeout: forwardTimeout,
+}
+
+// forwarders deliver readings to the destination of each forwarding type.
+var forwarders = map[string]func(ctx context.Context, f *model.Forwarding, readings []reading) error{
+	model.ForwardERDDAP: forwardERDDAP,
+	model.ForwardHTTP:   forwardHTTP,
+	model.ForwardSFTP:   forwardSFTP,
+}
+
+// forwardResponse is the JSON representation of the result of a forward request.
+type forwardResponse struct {
+	Attempted int `json:"attempted"`
+	Failed    int `json:"failed"`
+	Readings  int `json:"readings"`
+}
+
+// forwardHandler handles requests to deliver new readings for
+// forwardings that are due, with each forwarding's period determining
+// how often its readings are delivered. It is invoked periodically by
+// App Engine cron (see cron.yaml) for the forwardings of all sites, as
+// indicated by the X-Appengine-Cron header, which App Engine removes
+// from external requests. Requests with a JWT signed with the cron
+// secret, such as OceanCron rpc requests, deliver the forwardings of
+// the site given by the skey claim.
+func forwardHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	setup(ctx)
+
+	var fwds []model.Forwarding
+	var err error
+	if r.Header.Get("X-Appengine-Cron") == "true" {
+		fwds, err = model.GetEnabledForwardings(ctx, settingsStore)
+	} else {
+		var claims map[string]interface{}
+		claims, err = gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+		if err != nil {
+			log.Printf("forward request from %s has invalid claims: %v", r.RemoteAddr, err)
+			backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		skey, ok := claims["skey"].(float64)
+		if !ok {
+			backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		fwds, err = model.GetForwardingsBySite(ctx, settingsStore, int64(skey))
+	}
+	if err != nil {
+		backend.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var res forwardResponse
+	now := time.Now()
+	for i := range fwds {
+		f := &fwds[i]
+		if !f.Due(now) {
+			continue
+		}
+		res.Attempted++
+		n, err := forward(ctx, f, now)
+		if err != nil {
+			res.Failed++
+			continue
+		}
+		res.Readings += n
+	}
+
+	resp, _ := json.Marshal(res)
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// forward delivers the readings of a forwarding since its cursor,
+// records the outcome, and returns the number of readings delivered.
+// Ops are notified when deliveries start failing persistently.
+func forward(ctx context.Context, f *model.Forwarding, now time.Time) (int, error) {
+	end := now.Add(-forwardLag).Unix()
+	readings, err := getReadings(ctx, f, f.Cursor, end)
+	if err == nil && len(readings) > 0 {
+		err = forwarders[f.DestType](ctx, f, readings)
+	}
+	rerr := model.RecordForwarding(ctx, settingsStore, f.ID, end, len(readings), err, now)
+	if rerr != nil {
+		backend.Printf(ctx, "could not record forwarding %d: %v", f.ID, rerr)
+	}
+	if err == nil {
+		backend.Printf(ctx, "forwarded %d readings for forwarding %d to %s", len(readings), f.ID, f.Dest)
+		return len(readings), nil
+	}
+
+	msg := fmt.Sprintf("forwarding %q to %s failed: %v", f.Name, f.Dest, err)
+	backend.Printf(ctx, "%s", msg)
+	if f.Failures+1 == forwardFailures && notifier != nil {
+		nerr := notifier.Send(ctx, f.Skey, forwardingKind, fmt.Sprintf("%s, after %d attempts", msg, forwardFailures))
+		if nerr != nil {
+			backend.Printf(ctx, "could not send forwarding notification: %v", nerr)
+		}
+	}
+	return 0, err
+}
+
+// getReadings returns the readings of a forwarding's sensors from
+// Unix time start (inclusive) to end (exclusive), in time order.
+// Sensors must belong to devices of the forwarding's site.
+func getReadings(ctx context.Context, f *model.Forwarding, start, end int64) ([]reading, error) {
+	sensors, err := f.SensorList()
+	if err != nil {
+		return nil, err
+	}
+
+	var readings []reading
+	for _, s := range sensors {
+		dev, err := model.GetDevice(ctx, settingsStore, s.Mac)
+		if err != nil {
+			return nil, fmt.Errorf("could not get device for %s: %w", s, err)
+		}
+		if dev.Skey != f.Skey {
+			return nil, fmt.Errorf("device for %s does not belong to site %d", s, f.Skey)
+		}
+
+		name, units := s.String(), ""
+		sensor, err := model.GetSensorV2(ctx, settingsStore, s.Mac, s.Pin)
+		switch {
+		case errors.Is(err, datastore.ErrNoSuchEntity):
+			sensor = nil // Raw values are forwarded.
+		case err != nil:
+			return nil, fmt.Errorf("could not get sensor %s: %w", s, err)
+		default:
+			name = sensor.Name
+		}
+
+		scalars, err := model.GetScalars(ctx, mediaStore, model.ToSID(dev.MAC(), s.Pin), []int64{start, end})
+		if err != nil {
+			return nil, fmt.Errorf("could not get readings for %s: %w", s, err)
+		}
+		for _, sc := range scalars {
+			v := sc.Value
+			if sensor != nil {
+				v, err = sensor.Transform(v)
+				if err != nil {
+					return nil, fmt.Errorf("could not transform reading for %s: %w", s, err)
+				}
+				var u model.Unit
+				v, u, err = sensor.CanonicalValue(v)
+				if err != nil {
+					return nil, fmt.Errorf("could not convert reading for %s to canonical units: %w", s, err)
+				}
+				units = string(u)
+			}
+			readings = append(readings, reading{ts: sc.Timestamp, sensor: s, name: name, units: units, value: v})
+		}
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].ts < readings[j].ts })
+	return readings, nil
+}
+
+// formatTime returns a Unix time in ISO 8601 format, as used by ERDDAP.
+func formatTime(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+// formatValue returns a reading value in its shortest decimal form.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// encodeCSV encodes readings as CSV with a header row.
+func encodeCSV(readings []reading) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"time", "device", "pin", "sensor", "value", "units"})
+	for _, r := range readings {
+		w.Write([]string{formatTime(r.ts), model.MacDecode(r.sensor.Mac), r.sensor.Pin, r.name, formatValue(r.value), r.units})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// forwardHTTP POSTs readings as CSV to an HTTP endpoint.
+func forwardHTTP(ctx context.Context, f *model.Forwarding, readings []reading) error {
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.Dest, bytes.NewReader(encodeCSV(readings)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	if f.Credential != "" {
+		req.Header.Set("Authorization", "Bearer "+f.Credential)
+	}
+	return doForwardRequest(req)
+}
+
+// forwardERDDAP inserts readings into an ERDDAP EDDTableFromHttpGet
+// dataset, which must have device, pin, time and value columns.
+// Readings are inserted in batches, one sensor per request, with the
+// time and value columns as arrays.
+func forwardERDDAP(ctx context.Context, f *model.Forwarding, readings []reading) error {
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+
+	bySensor := map[model.ForwardedSensor][]reading{}
+	var order []model.ForwardedSensor
+	for _, r := range readings {
+		if _, ok := bySensor[r.sensor]; !ok {
+			order = append(order, r.sensor)
+		}
+		bySensor[r.sensor] = append(bySensor[r.sensor], r)
+	}
+
+	for _, s := range order {
+		rs := bySensor[s]
+		for len(rs) > 0 {
+			batch := rs[:min(len(rs), erddapBatch)]
+			rs = rs[len(batch):]
+			times := make([]string, len(batch))
+			values := make([]string, len(batch))
+			for i, r := range batch {
+				times[i] = formatTime(r.ts)
+				values[i] = formatValue(r.value)
+			}
+			// ERDDAP requires the author param to be last, so the query
+			// is built in order rather than using url.Values.
+			q := "device=" + url.QueryEscape(strconv.Quote(model.MacDecode(s.Mac))) +
+				"&pin=" + url.QueryEscape(strconv.Quote(s.Pin)) +
+				"&time=" + url.QueryEscape("["+strings.Join(times, ",")+"]") +
+				"&value=" + url.QueryEscape("["+strings.Join(values, ",")+"]") +
+				"&author=" + url.QueryEscape(f.Credential)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.Dest+"?"+q, nil)
+			if err != nil {
+				return err
+			}
+			err = doForwardRequest(req)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// doForwardRequest sends a request to an endpoint, returning an error
+// if the response status is not 2xx.
+func doForwardRequest(req *http.Request) error {
+	resp, err := forwardClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request failed with status: %s", resp.Status)
+	}
+	return nil
+}
+
+// forwardSFTP writes readings as a CSV file to a directory on an SFTP
+// server. Each delivery creates a new file, named by forwarding ID and
+// the time of its last reading, e.g., 1234-20260102T030405Z.csv.
+func forwardSFTP(ctx context.Context, f *model.Forwarding, readings []reading) error {
+	u, err := url.Parse(f.Dest)
+	if err != nil {
+		return err
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(f.HostKey))
+	if err != nil {
+		return fmt.Errorf("invalid host key: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+	conn, err := forwardDialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return fmt.Errorf("could not connect to %s: %w", host, err)
+	}
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, host, &ssh.ClientConfig{
+		User:            u.User.Username(),
+		Auth:            []ssh.AuthMethod{ssh.Password(f.Credential)},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+	})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("could not connect to %s: %w", host, err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("could not start sftp session with %s: %w", host, err)
+	}
+	defer sc.Close()
+
+	name := fmt.Sprintf("%d-%s.csv", f.ID, time.Unix(readings[len(readings)-1].ts, 0).UTC().Format("20060102T150405Z"))
+	file, err := sc.Create(path.Join(u.Path, name))
+	if err != nil {
+		return fmt.Errorf("could not create %s: %w", name, err)
+	}
+	_, err = file.Write(encodeCSV(readings))
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not write %s: %w", name, err)
+	}
+	return file.Close()
+}
@@ -23,22 +23,28 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/openfish/datastore"
 	"github.com/ausocean/utils/sliceutils"
 )
 
+// disabledPeriod is the monitor period of disabled devices.
+const disabledPeriod = time.Hour
+
 var (
 	errInvalidBody  = errors.New("invalid body")
 	errInvalidJSON  = errors.New("invalid JSON")
@@ -59,8 +65,8 @@ var (
 // - ut: Uptime.
 // - la: Local (IP) address.
 // - vt: Var types present in body when non-zero.
+// - ch: Hash of the device's current config, if any. See writeConfig.
 func configHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	ctx := r.Context()
 
 	q := r.URL.Query()
@@ -76,6 +82,9 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 	// Is this request for a valid device?
 	setup(ctx)
 	dev, err := model.CheckDevice(ctx, settingsStore, ma, dk)
+	if dev != nil {
+		ctx = backend.WithSite(ctx, dev.Skey)
+	}
 
 	var dkey int64
 	switch err {
@@ -84,11 +93,15 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 	case model.ErrMissingDeviceKey:
 		// Device key defaults to zero.
 	case datastore.ErrNoSuchEntity:
-		log.Printf("/config from unknown device %s", ma)
-		writeError(w, model.ErrDeviceNotFound)
+		backend.Printf(ctx, "/config from unknown device %s", ma)
+		writeError(w, r, model.ErrDeviceNotFound)
+		return
+	case model.ErrDeviceNotEnabled:
+		backend.Printf(ctx, "/config from disabled device %s", ma)
+		writeDisabledConfig(w, r, dev)
 		return
 	default:
-		writeDeviceError(w, dev, err)
+		writeDeviceError(w, r, dev, err)
 		return
 	}
 
@@ -97,21 +110,21 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 	if vt != "" {
 		n, err := strconv.Atoi(vt)
 		if err != nil {
-			log.Printf("error parsing vt param for device %s: %v", ma, err)
-			writeError(w, errInvalidSize)
+			backend.Printf(ctx, "error parsing vt param for device %s: %v", ma, err)
+			writeError(w, r, errInvalidSize)
 			return
 		}
 		body := make([]byte, n)
 		_, err = io.ReadFull(r.Body, body)
 		if err != nil {
-			log.Printf("error reading body for device %s: %v", ma, err)
-			writeError(w, errInvalidBody)
+			backend.Printf(ctx, "error reading body for device %s: %v", ma, err)
+			writeError(w, r, errInvalidBody)
 			return
 		}
-		err = json.Unmarshal(body, &varTypes)
+		err = unmarshalBody(r, body, &varTypes)
 		if err != nil {
-			log.Printf("error unmarshalling var types for device %s: %v", ma, err)
-			writeError(w, errInvalidJSON)
+			backend.Printf(ctx, "error unmarshalling var types for device %s: %v", ma, err)
+			writeError(w, r, errInvalidJSON)
 			return
 		}
 	}
@@ -121,60 +134,84 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = updateDeviceStatus(ctx, dev)
 	if err != nil {
-		log.Printf("could not update device status: %v", err)
+		backend.Printf(ctx, "could not update device status: %v", err)
 	}
 
 	switch dev.Status {
 	case model.DeviceStatusOK:
 		// Device is configured, so check the device key matches.
-		if dkey != dev.Dkey {
+		if !dev.ValidKey(dkey, time.Now()) {
 			// We should not get here. A known, configured device is using the wrong key,
 			// so we return an error rather than forcing the device to reconfigure.
-			log.Printf("/config from device %s with invalid device key %d", ma, dkey)
-			writeError(w, model.ErrInvalidDeviceKey)
+			backend.Printf(ctx, "/config from device %s with invalid device key %d", ma, dkey)
+			writeError(w, r, model.ErrInvalidDeviceKey)
 			return
 		}
+		if dkey != dev.ActiveKey() {
+			// Inform the device of its new key during a key rotation.
+			dk = strconv.FormatInt(dev.ActiveKey(), 10)
+		}
 
 	case model.DeviceStatusUpgrade:
 		if md == "Completed" {
-			log.Printf("device %s upgrade completed", ma)
+			backend.Printf(ctx, "device %s upgrade completed", ma)
 			dev.Status = model.DeviceStatusOK
 		} // Else upgrade in progress.
 
 	default:
-		log.Printf("/config from unconfigured device %s", ma)
-		if dkey != dev.Dkey {
+		backend.Printf(ctx, "/config from unconfigured device %s", ma)
+		if dev.Rotating() && !dev.ValidKey(dkey, time.Now()) {
+			// Only a device that authenticates with its current key may
+			// learn its new key during a key rotation.
+			backend.Printf(ctx, "/config from rotating device %s with invalid device key %d", ma, dkey)
+			writeError(w, r, model.ErrInvalidDeviceKey)
+			return
+		}
+		if dkey != dev.ActiveKey() {
 			// Inform the device of its new key.
-			dk = strconv.FormatInt(dev.Dkey, 10)
+			dk = strconv.FormatInt(dev.ActiveKey(), 10)
 		}
 		dev.Status = model.DeviceStatusOK
 	}
 
 	vs, err := model.GetVarSum(ctx, settingsStore, dev.Skey, dev.Hex())
 	if err != nil {
-		log.Printf("could not get var sum for device %s: %v", ma, err)
+		backend.Printf(ctx, "could not get var sum for device %s: %v", ma, err)
 	}
 
-	resp, err := configJSON(dev, vs, dk)
+	err = writeConfig(w, r, dev, vs, dk)
 	if err != nil {
-		log.Printf("could not generate config response JSON for device %s: %v", ma, err)
-		writeError(w, err)
+		backend.Printf(ctx, "could not generate config response JSON for device %s: %v", ma, err)
+		writeError(w, r, err)
 		return
 	}
-	fmt.Fprint(w, resp)
 
 	// NB: Perform datastore operations _after_ responding to the client.
-	// Update the device.
-	dev.Updated = time.Now()
+	// Update the device atomically, so as not to overwrite a concurrent
+	// key rotation change.
 	if vn != "" && vn != dev.Protocol {
-		log.Printf("netsender %s updated to protocol %s", ma, vn)
-		dev.Protocol = vn
+		backend.Printf(ctx, "netsender %s updated to protocol %s", ma, vn)
+	}
+	_, err = model.UpdateDevice(ctx, settingsStore, dev.Mac, func(d *model.Device) error {
+		d.Status = dev.Status
+		if d.ValidKey(dkey, time.Now()) {
+			d.LastDkey = dkey
+		}
+		if vn != "" {
+			d.Protocol = vn
+		}
+		return nil
+	})
+	if err != nil {
+		backend.Printf(ctx, "could not update device %s: %v", ma, err)
 	}
-	model.PutDevice(ctx, settingsStore, dev)
 
 	// Update the variables corresponding to the client's uptime, local address and var types.
 	if md != "" {
-		model.PutVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".mode", md)
+		err := model.PutTypedVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".mode", md)
+		if err != nil {
+			backend.Printf(ctx, "could not put mode for %s: %v", ma, err)
+		}
 		model.PutVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".error", er)
 	}
 	if ut != "" {
@@ -190,6 +227,53 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeDisabledConfig writes the config for a disabled device, which
+// has no pins, so that the device stops reporting, and a long
+// monitor period, so that the device checks in infrequently until it
+// is enabled again.
+func writeDisabledConfig(w http.ResponseWriter, r *http.Request, dev *model.Device) {
+	disabled := *dev
+	disabled.Inputs = ""
+	disabled.Outputs = ""
+	disabled.MonitorPeriod = int64(disabledPeriod.Seconds())
+	disabled.ActPeriod = int64(disabledPeriod.Seconds())
+	disabled.Status = model.DeviceStatusOK
+	err := writeConfig(w, r, &disabled, 0, "")
+	if err != nil {
+		backend.Printf(r.Context(), "could not generate config response JSON for device %s: %v", dev.MAC(), err)
+		writeError(w, r, err)
+	}
+}
+
+// writeConfig writes a config response, which includes a hash of the
+// config ("ch"). Devices request their config frequently, but it
+// rarely changes, so devices that supply the hash of their current
+// config (ch param) receive 304 Not Modified, with no body, when
+// their config is up to date, which saves cellular data.
+func writeConfig(w http.ResponseWriter, r *http.Request, dev *model.Device, vs int64, dk string) error {
+	resp, err := configJSON(dev, vs, dk, "")
+	if err != nil {
+		return err
+	}
+	ch := configHash(resp)
+	if ch == r.URL.Query().Get("ch") {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	resp, err = configJSON(dev, vs, dk, ch)
+	if err != nil {
+		return err
+	}
+	writeResponse(w, r, []byte(resp))
+	return nil
+}
+
+// configHash returns a short hash of a config response.
+func configHash(resp string) string {
+	sum := sha256.Sum256([]byte(resp))
+	return hex.EncodeToString(sum[:4])
+}
+
 // updateDeviceStatus updates the device status with the value of the
 // status variable then deletes the variable, if any. Setting the
 // status variable is therefore equivalent to the status being changed
@@ -220,9 +304,9 @@ func updateDeviceStatus(ctx context.Context, dev *model.Device) error {
 	return nil
 }
 
-// configJSON generates JSON for a config request response given a device, varsum, and device key.
-// Includes the response code ("rc") when it is non-zero.
-func configJSON(dev *model.Device, vs int64, dk string) (string, error) {
+// configJSON generates JSON for a config request response given a device, varsum, device key and config hash.
+// Includes the response code ("rc") and config hash ("ch") when they are non-zero.
+func configJSON(dev *model.Device, vs int64, dk, ch string) (string, error) {
 	config := struct {
 		MAC           string `json:"ma"`
 		Wifi          string `json:"wi"`
@@ -235,6 +319,7 @@ func configJSON(dev *model.Device, vs int64, dk string) (string, error) {
 		Vs            int64  `json:"vs"`
 		DK            string `json:"dk,omitempty"`
 		RC            int    `json:"rc,omitempty"`
+		CH            string `json:"ch,omitempty"`
 	}{
 		MAC:           dev.MAC(),
 		Wifi:          dev.Wifi,
@@ -247,6 +332,7 @@ func configJSON(dev *model.Device, vs int64, dk string) (string, error) {
 		Vs:            vs,
 		DK:            dk,
 		RC:            int(dev.Status),
+		CH:            ch,
 	}
 
 	jsonBytes, err := json.Marshal(config)
@@ -257,9 +343,9 @@ func configJSON(dev *model.Device, vs int64, dk string) (string, error) {
 	return string(jsonBytes), nil
 }
 
-// pollHandler handles poll requests.
+// pollHandler handles poll requests. Readings are sent either as
+// query params, or as a JSON body for batched readings. See writeBatch.
 func pollHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	ctx := r.Context()
 
 	q := r.URL.Query()
@@ -271,11 +357,30 @@ func pollHandler(w http.ResponseWriter, r *http.Request) {
 	setup(ctx)
 	dev, err := model.CheckDevice(ctx, settingsStore, ma, dk)
 	if err != nil {
-		writeDeviceError(w, dev, err)
+		writeDeviceError(w, r, dev, err)
 		return
 	}
+	ctx = backend.WithSite(ctx, dev.Skey)
+	if throttled(w, r, dev) {
+		return
+	}
+	countUsage(dev, requestSize(r))
+	recordDeviceKey(ctx, dev, dk)
+
+	// Batched readings are sent as a JSON or CBOR body instead of query params.
+	var acks map[string][]int64
+	if r.Method == http.MethodPost && (strings.HasPrefix(r.Header.Get("Content-Type"), mimeJSON) || isCBOR(r)) {
+		acks, err = writeBatch(r, dev)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
 
 	for _, pin := range dev.InputList() {
+		if acks != nil {
+			break
+		}
 		// Get numeric value for pin, if present.
 		v := q.Get(pin)
 		if v == "" {
@@ -283,13 +388,21 @@ func pollHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		n, err := strconv.ParseFloat(v, 64)
 		if err != nil {
-			writeError(w, errInvalidValue)
+			writeError(w, r, errInvalidValue)
 			break
 		}
 
 		switch pin[0] {
 		case 'A', 'D', 'X':
-			err = writeScalar(r, ma, pin, n)
+			ts := time.Now().Unix()
+			sv := newScalarValidator(ctx, dev, pin)
+			if sv.valid(ctx, n, ts) {
+				err = writeScalar(r, ma, pin, n, ts)
+				if err == nil {
+					evaluateAlertRules(ctx, dev, pin, n, ts)
+				}
+			}
+			sv.count(ctx)
 
 		case 'B':
 			err = writeBinary(r, ma, pin, int(n))
@@ -301,26 +414,29 @@ func pollHandler(w http.ResponseWriter, r *http.Request) {
 			err = writeText(r, ma, pin, int(n))
 
 		default:
-			log.Printf("device %s sending invalid pin: %s", ma, pin)
+			backend.Printf(ctx, "device %s sending invalid pin: %s", ma, pin)
 			err = errInvalidPin
 		}
 
 		if err != nil {
-			writeError(w, err)
+			writeError(w, r, err)
 			return
 		}
 	}
 
 	vs, err := model.GetVarSum(ctx, settingsStore, dev.Skey, dev.Hex())
 	if err != nil {
-		log.Printf("error getting varsum: %v", err)
+		backend.Printf(ctx, "error getting varsum: %v", err)
 	}
 
 	respMap := map[string]interface{}{"ma": ma, "vs": int(vs)}
+	if acks != nil {
+		respMap["ak"] = acks
+	}
 
 	err = updateDeviceStatus(ctx, dev)
 	if err != nil {
-		log.Printf("could not update device status: %v", err)
+		backend.Printf(ctx, "could not update device status: %v", err)
 	}
 
 	if dev.Status != model.DeviceStatusOK {
@@ -329,22 +445,27 @@ func pollHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = processActuators(ctx, dev, respMap)
 	if err != nil {
-		writeError(w, err)
+		writeError(w, r, err)
 		return
 	}
 
+	err = processCommands(ctx, dev, q["ca"], respMap)
+	if err != nil {
+		backend.Printf(ctx, "could not process commands: %v", err)
+	}
+
 	resp, err := json.Marshal(respMap)
 	if err != nil {
-		writeError(w, fmt.Errorf("could not marshal response map %w", err))
+		writeError(w, r, fmt.Errorf("could not marshal response map %w", err))
 		return
 	}
-	w.Write(resp)
+	writeResponse(w, r, resp)
 
 	// NB: Perform datastore operations _after_ responding to the client.
 	// Update the variable corresponding to client's uptime.
 	err = model.PutVariable(ctx, settingsStore, dev.Skey, "_"+dev.Hex()+".uptime", ut)
 	if err != nil {
-		log.Printf("error putting variable %s: %v", "_"+dev.Hex()+".uptime", err)
+		backend.Printf(ctx, "error putting variable %s: %v", "_"+dev.Hex()+".uptime", err)
 	}
 }
 
@@ -388,13 +509,152 @@ func toInt(s string) (int64, error) {
 	}
 }
 
-// writeScalar writes a scalar value.
-func writeScalar(r *http.Request, ma, pin string, n float64) error {
+// writeScalar writes a scalar value with the given timestamp.
+func writeScalar(r *http.Request, ma, pin string, n float64, ts int64) error {
 	id := model.ToSID(ma, pin)
-	ts := time.Now().Unix()
 	return model.PutScalar(r.Context(), mediaStore, &model.Scalar{ID: id, Timestamp: ts, Value: n})
 }
 
+// batchReading is a single reading in a batched poll request.
+type batchReading struct {
+	TS int64   `json:"ts" cbor:"ts"` // Unix timestamp (s).
+	V  float64 `json:"v" cbor:"v"`   // Value.
+}
+
+// writeBatch writes batched scalar readings from a poll request body,
+// which is a JSON (or CBOR) object mapping pins to arrays of readings, e.g.,
+//
+//	{"A0":[{"ts":1700000000,"v":512},{"ts":1700000060,"v":515}]}
+//
+// Readings are written as back-dated scalars, oldest first. It returns
+// the timestamps of the readings that were handled for each pin, which
+// includes rejected readings, so that devices can discard them. Only
+// A, D and X pins can be batched, and readings for other pins or
+// with missing timestamps are not acknowledged.
+func writeBatch(r *http.Request, dev *model.Device) (map[string][]int64, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, errInvalidBody
+	}
+	var batch map[string][]batchReading
+	err = unmarshalBody(r, body, &batch)
+	if err != nil {
+		return nil, errInvalidJSON
+	}
+
+	ctx := r.Context()
+	ma := dev.MAC()
+	inputs := dev.InputList()
+	acks := make(map[string][]int64)
+	for pin, readings := range batch {
+		if pin == "" || !sliceutils.ContainsString(inputs, pin) || !strings.ContainsAny(pin[:1], "ADX") {
+			backend.Printf(ctx, "device %s sending invalid batch pin: %s", ma, pin)
+			continue
+		}
+		sort.SliceStable(readings, func(i, j int) bool { return readings[i].TS < readings[j].TS })
+		sv := newScalarValidator(ctx, dev, pin)
+		ts := []int64{}
+		for _, rd := range readings {
+			if rd.TS <= 0 {
+				continue
+			}
+			if sv.valid(ctx, rd.V, rd.TS) {
+				err = writeScalar(r, ma, pin, rd.V, rd.TS)
+				if err != nil {
+					backend.Printf(ctx, "could not write batched scalar for %s.%s: %v", ma, pin, err)
+					continue
+				}
+				evaluateAlertRules(ctx, dev, pin, rd.V, rd.TS)
+			}
+			ts = append(ts, rd.TS)
+		}
+		sv.count(ctx)
+		acks[pin] = ts
+	}
+	return acks, nil
+}
+
+// scalarValidator checks scalar values against the limits of the
+// sensor for a device pin. The sensor and the latest scalar are read
+// once, so that validating a batch of readings does not read them per
+// reading. Readings that change faster than the sensor allows are
+// flagged but still stored, whereas readings outside the sensor's
+// range are rejected. Either outcome is counted by a device counter,
+// which is reported by monitoring.
+type scalarValidator struct {
+	dev      *model.Device
+	pin      string
+	sensor   *model.SensorV2
+	prev     *model.Scalar // Latest valid scalar, if any.
+	flagged  int64
+	rejected int64
+}
+
+// newScalarValidator returns a validator for the given device pin,
+// provisioning a sensor if there is none.
+func newScalarValidator(ctx context.Context, dev *model.Device, pin string) *scalarValidator {
+	sv := &scalarValidator{dev: dev, pin: pin}
+	sensor, err := model.GetSensorV2(ctx, settingsStore, dev.Mac, pin)
+	switch {
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+		provisionSensor(ctx, dev, pin)
+		sensor = &model.SensorV2{}
+	case err != nil:
+		backend.Printf(ctx, "could not get sensor %s.%s: %v", dev.Hex(), pin, err)
+		return sv
+	}
+	sv.sensor = sensor
+
+	if sensor.MaxRate != 0 {
+		sv.prev, err = model.GetLatestScalar(ctx, mediaStore, model.ToSID(dev.MAC(), pin))
+		if err != nil && !errors.Is(err, datastore.ErrNoSuchEntity) {
+			backend.Printf(ctx, "could not get latest scalar for %s.%s: %v", dev.Hex(), pin, err)
+		}
+	}
+	return sv
+}
+
+// valid returns false if the value n received at time ts is rejected.
+func (sv *scalarValidator) valid(ctx context.Context, n float64, ts int64) bool {
+	if sv.sensor == nil {
+		return true
+	}
+	validity, err := sv.sensor.Validate(n, ts, sv.prev)
+	if err != nil {
+		backend.Printf(ctx, "could not validate %s.%s: %v", sv.dev.Hex(), sv.pin, err)
+		return true
+	}
+
+	switch validity {
+	case model.ReadingFlagged:
+		backend.Printf(ctx, "flagged %s.%s value %v", sv.dev.Hex(), sv.pin, n)
+		sv.flagged++
+	case model.ReadingRejected:
+		backend.Printf(ctx, "rejected %s.%s value %v", sv.dev.Hex(), sv.pin, n)
+		sv.rejected++
+		return false
+	}
+	if sv.prev == nil || ts > sv.prev.Timestamp {
+		sv.prev = &model.Scalar{Timestamp: ts, Value: n}
+	}
+	return true
+}
+
+// count adds the flagged and rejected readings to the device's counters.
+func (sv *scalarValidator) count(ctx context.Context) {
+	for kind, n := range map[string]int64{"flagged": sv.flagged, "rejected": sv.rejected} {
+		if n == 0 {
+			continue
+		}
+		name := "_" + sv.dev.Hex() + "." + kind
+		err := model.IncrementVariable(ctx, settingsStore, sv.dev.Skey, name, n)
+		if err != nil {
+			backend.Printf(ctx, "could not increment variable %s: %v", name, err)
+		}
+	}
+	sv.flagged, sv.rejected = 0, 0
+}
+
 // writeText writes text data.
 func writeText(r *http.Request, ma, pin string, n int) error {
 	data := make([]byte, n)
@@ -431,7 +691,6 @@ func writeBinary(r *http.Request, ma, pin string, n int) error {
 
 // actHandler handles act requests.
 func actHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	ctx := r.Context()
 	q := r.URL.Query()
 	ma := q.Get("ma")
@@ -441,15 +700,16 @@ func actHandler(w http.ResponseWriter, r *http.Request) {
 	setup(ctx)
 	dev, err := model.CheckDevice(ctx, settingsStore, ma, dk)
 	if err != nil {
-		writeDeviceError(w, dev, err)
+		writeDeviceError(w, r, dev, err)
 		return
 	}
+	ctx = backend.WithSite(ctx, dev.Skey)
 
 	respMap := map[string]interface{}{"ma": ma}
 
 	err = updateDeviceStatus(ctx, dev)
 	if err != nil {
-		log.Printf("could not update device status: %v", err)
+		backend.Printf(ctx, "could not update device status: %v", err)
 	}
 
 	// If status is not okay.
@@ -458,7 +718,7 @@ func actHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		vs, err := model.GetVarSum(ctx, settingsStore, dev.Skey, dev.Hex())
 		if err != nil {
-			writeError(w, fmt.Errorf("could not get var sum: %w", err))
+			writeError(w, r, fmt.Errorf("could not get var sum: %w", err))
 			return
 		}
 
@@ -467,18 +727,18 @@ func actHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = processActuators(ctx, dev, respMap)
 	if err != nil {
-		writeError(w, err)
+		writeError(w, r, err)
 		return
 	}
 
 	err = model.PutVariable(ctx, settingsStore, dev.Skey, "_"+dev.Hex()+".uptime", "")
 	if err != nil {
-		log.Printf("error putting variable %s: %v", "_"+dev.Hex()+".uptime", err)
+		backend.Printf(ctx, "error putting variable %s: %v", "_"+dev.Hex()+".uptime", err)
 	}
 
 	resp, err := json.Marshal(respMap)
 	if err != nil {
-		writeError(w, fmt.Errorf("could not marshal response map %w", err))
+		writeError(w, r, fmt.Errorf("could not marshal response map %w", err))
 		return
 	}
 
@@ -488,7 +748,6 @@ func actHandler(w http.ResponseWriter, r *http.Request) {
 // varsHandler returns vars for a given device (except for system variables).
 // NB: Format vs as a string, not an int.
 func varsHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	ctx := r.Context()
 
 	q := r.URL.Query()
@@ -501,17 +760,21 @@ func varsHandler(w http.ResponseWriter, r *http.Request) {
 	setup(ctx)
 	dev, err := model.CheckDevice(ctx, settingsStore, ma, dk)
 	if err != nil {
-		writeDeviceError(w, dev, err)
+		writeDeviceError(w, r, dev, err)
 		return
 	}
+	ctx = backend.WithSite(ctx, dev.Skey)
 
 	if md != "" {
-		model.PutVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".mode", md)
+		err = model.PutTypedVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".mode", md)
+		if err != nil {
+			backend.Printf(ctx, "could not put mode for %s: %v", ma, err)
+		}
 		model.PutVariable(ctx, settingsStore, dev.Skey, dev.Hex()+".error", er)
 	}
 	vars, err := model.GetVariablesBySite(ctx, settingsStore, dev.Skey, dev.Hex())
 	if err != nil {
-		writeError(w, err)
+		writeError(w, r, err)
 		return
 	}
 
@@ -526,18 +789,17 @@ func varsHandler(w http.ResponseWriter, r *http.Request) {
 
 	vs := model.ComputeVarSum(vars)
 	resp += `"vs":"` + strconv.Itoa(int(vs)) + `"}`
-	fmt.Fprint(w, resp)
+	writeResponse(w, r, []byte(resp))
 }
 
 // apiHandler handles API requests which take the form:
 //
 //	/api/operation/property/value
 func apiHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 
 	req := strings.Split(r.URL.Path, "/")
 	if len(req) < 5 {
-		writeError(w, errInvalidAPI)
+		writeError(w, r, errInvalidAPI)
 		return
 	}
 
@@ -550,7 +812,7 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 	case "test":
 		n, err := strconv.ParseInt(val, 10, 64)
 		if err != nil {
-			writeError(w, fmt.Errorf("could not parse size: %w", err))
+			writeError(w, r, fmt.Errorf("could not parse size: %w", err))
 			return
 		}
 		body := make([]byte, n)
@@ -560,7 +822,7 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		if len(req) == 6 {
 			chunk, err = strconv.ParseInt(req[5], 10, 64)
 			if err != nil {
-				writeError(w, fmt.Errorf("could not parse chunk size: %w", err))
+				writeError(w, r, fmt.Errorf("could not parse chunk size: %w", err))
 				return
 			}
 		}
@@ -570,7 +832,7 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 			// Receive n bytes from the client.
 			_, err = io.ReadFull(r.Body, body)
 			if err != nil {
-				writeError(w, fmt.Errorf("could not read body: %w", err))
+				writeError(w, r, fmt.Errorf("could not read body: %w", err))
 				return
 			}
 			fmt.Fprint(w, "OK")
@@ -590,29 +852,53 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 	default:
-		writeError(w, errInvalidAPI)
+		writeError(w, r, errInvalidAPI)
 		return
 	}
 }
 
 // writeError writes an error in JSON format.
-func writeError(w http.ResponseWriter, err error) {
-	writeDeviceError(w, nil, err)
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeDeviceError(w, r, nil, err)
+}
+
+// writeStatusError writes an error in JSON format with the given HTTP status.
+func writeStatusError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeError(w, r, err)
 }
 
 // writeDeviceError writes an error in JSON format with an optional update response code for device key errors.
-func writeDeviceError(w http.ResponseWriter, dev *model.Device, err error) {
+func writeDeviceError(w http.ResponseWriter, r *http.Request, dev *model.Device, err error) {
 	var rc string
 	switch err {
 	case model.ErrMalformedDeviceKey, model.ErrInvalidDeviceKey:
 		if dev != nil {
-			log.Printf("bad request from %s: %v", dev.MAC(), err)
+			backend.Printf(r.Context(), "bad request from %s: %v", dev.MAC(), err)
 		}
 		fallthrough
-	case model.ErrMissingDeviceKey:
+	case model.ErrMissingDeviceKey, model.ErrDeviceNotEnabled:
+		// Disabled devices must update their config to stop reporting.
 		rc = `,"rc":` + strconv.Itoa(model.DeviceStatusUpdate)
 	}
 	w.Header().Add("Content-Type", "application/json")
 	fmt.Fprint(w, `{"er":"`+err.Error()+`"`+rc+`}`)
-	log.Println("Wrote device error: " + err.Error())
+	backend.Printf(r.Context(), "Wrote device error: %v", err)
+}
+
+// recordDeviceKey records the key used by a device while its key is
+// being rotated, which is written only when it changes.
+func recordDeviceKey(ctx context.Context, dev *model.Device, dk string) {
+	if !dev.Rotating() {
+		return
+	}
+	dkey, err := strconv.ParseInt(dk, 10, 64)
+	if err != nil || dkey == dev.LastDkey {
+		return
+	}
+	err = model.RecordDeviceKey(ctx, settingsStore, dev.Mac, dkey)
+	if err != nil {
+		backend.Printf(ctx, "could not record key of device %s: %v", dev.MAC(), err)
+	}
 }
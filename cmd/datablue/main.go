@@ -30,20 +30,27 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
 	"github.com/ausocean/openfish/datastore"
 )
 
 const (
-	version   = "v0.2.1"
-	projectID = "datablue"
+	version            = "v0.2.1"
+	projectID          = "datablue"
+	cronServiceAccount = "oceancron@appspot.gserviceaccount.com"
 )
 
 var (
 	setupMutex    sync.Mutex
 	mediaStore    datastore.Store
 	settingsStore datastore.Store
+	notifier      notify.Notifier
+	cronSecret    []byte
 	debug         bool
 	standalone    bool
 	storePath     string
@@ -59,17 +66,36 @@ func main() {
 		}
 	}
 
-	var host string
+	var host, broker string
 	var port int
+	var refresh time.Duration
 	flag.BoolVar(&debug, "debug", false, "Run in debug mode.")
 	flag.BoolVar(&standalone, "standalone", false, "Run in standalone mode.")
 	flag.StringVar(&host, "host", "localhost", "Host we run on in standalone mode")
 	flag.IntVar(&port, "port", defaultPort, "Port we listen on in standalone mode")
 	flag.StringVar(&storePath, "filestore", "store", "File store path")
+	flag.StringVar(&broker, "mqtt", "", "MQTT broker URL, e.g., tcp://localhost:1883 (optional)")
+	flag.DurationVar(&refresh, "mqttrefresh", 5*time.Minute, "MQTT topic refresh period")
+	flag.Float64Var(&deviceRate, "devicerate", 60, "Max requests per minute per device (0 for unlimited)")
+	flag.Float64Var(&siteRate, "siterate", 600, "Max requests per minute per site (0 for unlimited)")
 	flag.Parse()
+	backend.SetupLogging(!(debug || standalone))
 
 	// Perform one-time setup.
-	setup(context.Background())
+	ctx := context.Background()
+	setup(ctx)
+
+	// Flush counted data usage on shutdown.
+	opts := []backend.ServerOption{backend.WithShutdownHook(flushUsage)}
+
+	// Bridge MQTT devices, if any, until shutdown.
+	if broker != "" {
+		b, err := startMqttBridge(ctx, broker, refresh)
+		if err != nil {
+			log.Fatalf("could not start MQTT bridge: %v", err)
+		}
+		opts = append(opts, backend.WithShutdownHook(b.stop))
+	}
 
 	// Device requests.
 	http.HandleFunc("/config", configHandler)
@@ -81,12 +107,24 @@ func main() {
 	http.HandleFunc("/api", apiHandler)
 	http.HandleFunc("/api/", apiHandler)
 
+	// Service requests.
+	http.HandleFunc("/command", commandHandler)
+	http.HandleFunc("/mts/status", mtsStatusHandler)
+	http.HandleFunc("/forward", forwardHandler)
+	http.HandleFunc("/digests", digestHandler)
+	http.HandleFunc("/detect", detectHandler)
+	http.HandleFunc("/purge/uploads", purgeUploadsHandler)
+
 	// Other requests
 	http.HandleFunc("/_ah/warmup", warmupHandler)
 	http.HandleFunc("/", indexHandler)
 
 	log.Printf("Listening on %s:%d", host, port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), nil))
+	h := backend.Chain(http.DefaultServeMux, backend.RequestIDs(projectID), backend.Recover(nil), backend.LogRequests(debug || standalone))
+	err := backend.NewServer(fmt.Sprintf("%s:%d", host, port), h, opts...).ListenAndServe(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 // warmupHandler handles App Engine warmup requests. It simply ensures that the instance is loaded.
@@ -97,10 +135,48 @@ func warmupHandler(w http.ResponseWriter, r *http.Request) {
 // indexHandler handles requests for the home page and is here just to
 // test that the service is running. Devices do not use this endpoint.
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	w.Write([]byte(projectID + " " + version))
 }
 
+// digestHandler handles requests to send the notification digests
+// that are due. It is designed to be invoked periodically by App
+// Engine cron (see cron.yaml), so that digests are sent even when no
+// further notifications are queued. Requests must have the
+// X-Appengine-Cron header, which App Engine removes from external
+// requests, or else a JWT signed with the cron secret.
+func digestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	setup(ctx)
+
+	if !cronRequest(r) {
+		log.Printf("digest request from %s is unauthorized", r.RemoteAddr)
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	mn, ok := notifier.(*notify.MailjetNotifier)
+	if !ok || mn == nil {
+		return // Notifications disabled.
+	}
+	err := mn.FlushDigests(ctx)
+	if err != nil {
+		backend.WriteError(w, http.StatusInternalServerError, fmt.Errorf("could not flush digests: %w", err))
+		return
+	}
+}
+
+// cronRequest returns true if a request is from App Engine cron, as
+// indicated by the X-Appengine-Cron header, which App Engine removes
+// from external requests, or else has a JWT signed with the cron
+// secret and issued by Ocean Cron.
+func cronRequest(r *http.Request) bool {
+	if r.Header.Get("X-Appengine-Cron") == "true" {
+		return true
+	}
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	return err == nil && claims["iss"] == cronServiceAccount
+}
+
 // setup executes per-instance one-time warmup and is used to
 // initialize datastores. In standalone mode we use a file store for
 // storing both media and settings. In App Engine mode we use
@@ -136,6 +212,47 @@ func setup(ctx context.Context) {
 	}
 
 	model.RegisterEntities()
+
+	// Get shared cronSecret, which is used to authorize service requests.
+	cronSecret, err = gauth.GetHexSecret(ctx, "oceancron", "cronSecret")
+	if err != nil || cronSecret == nil {
+		log.Printf("could not get cronSecret: %v", err)
+	}
+
+	// Notifications are optional, since they are not required to receive data.
+	// Routine notifications, e.g., throttling, are batched into hourly
+	// digests, which are sent by digestHandler. Alerts and forwarding
+	// failures are sent immediately.
+	secrets, err := gauth.GetSecrets(ctx, projectID, nil)
+	if err != nil {
+		log.Printf("could not get secrets, notifications disabled: %v", err)
+		return
+	}
+	notifier, err = notify.NewMailjetNotifier(
+		notify.WithSecrets(secrets),
+		notify.WithRecipientLookup(opsRecipients),
+		notify.WithStore(notify.NewStore(settingsStore)),
+		notify.WithDigest(settingsStore, time.Hour),
+		notify.WithSeverity(alertRuleKind, notify.SeverityWarning),
+		notify.WithSeverity(forwardingKind, notify.SeverityWarning),
+		notify.WithDedup(provisionalSensorKind, provisionalSensorPeriod),
+		notify.WithTemplateStore(settingsStore),
+		notify.WithHistory(settingsStore),
+		notify.WithRouteStore(settingsStore),
+	)
+	if err != nil {
+		log.Printf("could not set up email notifier: %v", err)
+	}
+}
+
+// opsRecipients looks up the ops email address and notification
+// period for the given site.
+func opsRecipients(skey int64, kind notify.Kind) ([]string, time.Duration, error) {
+	site, err := model.GetSite(context.Background(), settingsStore, skey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not get site: %w", err)
+	}
+	return []string{site.OpsEmail}, time.Duration(site.NotifyPeriod) * time.Hour, nil
 }
 
 // setupLocal creates a local site and device for use in standalone mode.
@@ -147,17 +264,3 @@ func setupLocal(ctx context.Context, store datastore.Store) error {
 	err = model.PutDevice(ctx, store, &model.Device{Skey: 1, Mac: 1, Dkey: 0, Name: "localdevice", Inputs: "A0,V0,S0", MonitorPeriod: 60, Enabled: true})
 	return err
 }
-
-// logRequest logs a request if in debug mode and standalone mode.
-// It does nothing in App Engine mode as App Engine logs requests
-// automatically.
-func logRequest(r *http.Request) {
-	if !(debug || standalone) {
-		return
-	}
-	if r.URL.RawQuery == "" {
-		log.Println(r.URL.Path)
-		return
-	}
-	log.Println(r.URL.Path + "?" + r.URL.RawQuery)
-}
@@ -0,0 +1,264 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// mqtt.go implements a bridge for devices that publish readings via MQTT.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+// MQTT defaults.
+const (
+	mqttClientID = projectID + "-bridge"
+	mqttQoS      = 1
+	mqttTimeout  = 10 * time.Second
+)
+
+// mqttReading is the JSON payload published by MQTT devices.
+// Numeric pins (A, D and X) require a value, whereas text pins (T)
+// require text. The timestamp is optional and defaults to the time
+// the message is received.
+type mqttReading struct {
+	DK    string   `json:"dk"`              // Device key.
+	Value *float64 `json:"value,omitempty"` // Numeric value.
+	Text  string   `json:"text,omitempty"`  // Text value.
+	TS    int64    `json:"ts,omitempty"`    // Unix timestamp (s).
+}
+
+// mqttBridge subscribes to the MQTT topics stored in the datastore
+// and writes received readings as scalars or text.
+type mqttBridge struct {
+	ctx    context.Context // Context for handling messages.
+	client mqtt.Client
+	mu     sync.Mutex
+	topics map[string]model.MqttTopic // Subscribed topics.
+}
+
+// startMqttBridge connects to the given MQTT broker, subscribes to all
+// enabled topics, then periodically reloads the topic mappings so
+// that changes take effect without restarting. Reloading stops and
+// the bridge disconnects when ctx is done.
+func startMqttBridge(ctx context.Context, broker string, refresh time.Duration) (*mqttBridge, error) {
+	opts := mqtt.NewClientOptions().AddBroker(broker).SetClientID(mqttClientID).SetAutoReconnect(true)
+	secrets, err := gauth.GetSecrets(ctx, projectID, nil)
+	if err == nil && secrets["mqttUsername"] != "" {
+		opts.SetUsername(secrets["mqttUsername"]).SetPassword(secrets["mqttPassword"])
+	}
+
+	b := &mqttBridge{ctx: ctx, topics: make(map[string]model.MqttTopic)}
+
+	// Resubscribe to everything after a reconnect.
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		b.mu.Lock()
+		b.topics = make(map[string]model.MqttTopic)
+		b.mu.Unlock()
+		err := b.load(ctx)
+		if err != nil {
+			log.Printf("could not load MQTT topics: %v", err)
+		}
+	})
+
+	b.client = mqtt.NewClient(opts)
+	err = mqttWait(ctx, b.client.Connect())
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to MQTT broker %s: %w", broker, err)
+	}
+	log.Printf("connected to MQTT broker %s", broker)
+
+	go func() {
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				b.stop(context.Background())
+				return
+			case <-ticker.C:
+			}
+			err := b.load(ctx)
+			if err != nil {
+				log.Printf("could not reload MQTT topics: %v", err)
+			}
+		}
+	}()
+
+	return b, nil
+}
+
+// stop disconnects from the MQTT broker, allowing in-flight messages
+// to be handled first.
+func (b *mqttBridge) stop(ctx context.Context) error {
+	b.client.Disconnect(uint(mqttTimeout / time.Millisecond))
+	log.Printf("disconnected from MQTT broker")
+	return nil
+}
+
+// load subscribes to enabled topics that we are not yet subscribed to
+// and unsubscribes from topics that have been removed or disabled.
+// Topics that could not be subscribed to, including those that timed
+// out, are retried on the next load, and their errors are returned.
+func (b *mqttBridge) load(ctx context.Context) error {
+	topics, err := model.GetMqttTopics(ctx, settingsStore, 0)
+	if err != nil {
+		return fmt.Errorf("could not get MQTT topics: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	wanted := make(map[string]model.MqttTopic)
+	for _, t := range topics {
+		if !t.Enabled || t.Topic == "" {
+			continue
+		}
+		if !validMqttPin(t.Pin) {
+			errs = append(errs, fmt.Errorf("MQTT topic %s has invalid pin %q", t.Topic, t.Pin))
+			continue
+		}
+		wanted[t.Topic] = t
+	}
+
+	for topic := range b.topics {
+		if _, ok := wanted[topic]; ok {
+			continue
+		}
+		err := mqttWait(ctx, b.client.Unsubscribe(topic))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not unsubscribe from MQTT topic %s: %w", topic, err))
+			continue
+		}
+		delete(b.topics, topic)
+		log.Printf("unsubscribed from MQTT topic %s", topic)
+	}
+
+	for topic, t := range wanted {
+		if _, ok := b.topics[topic]; ok {
+			b.topics[topic] = t // Pick up any mapping changes.
+			continue
+		}
+		err := mqttWait(ctx, b.client.Subscribe(topic, mqttQoS, func(c mqtt.Client, msg mqtt.Message) { b.handle(msg) }))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not subscribe to MQTT topic %s: %w", topic, err))
+			continue
+		}
+		b.topics[topic] = t
+		log.Printf("subscribed to MQTT topic %s for %s.%s", topic, model.MacDecode(t.Mac), t.Pin)
+	}
+
+	return errors.Join(errs...)
+}
+
+// mqttWait waits for an MQTT operation to complete, returning an
+// error if it fails, does not complete within mqttTimeout, or ctx is
+// done first.
+func mqttWait(ctx context.Context, tok mqtt.Token) error {
+	ctx, cancel := context.WithTimeout(ctx, mqttTimeout)
+	defer cancel()
+	select {
+	case <-tok.Done():
+		return tok.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// validMqttPin returns true if readings for pin can be bridged, i.e.,
+// it is an A, D, X or T pin.
+func validMqttPin(pin string) bool {
+	return len(pin) > 1 && strings.ContainsAny(pin[:1], "ADXT")
+}
+
+// handle handles a single MQTT message.
+func (b *mqttBridge) handle(msg mqtt.Message) {
+	b.mu.Lock()
+	t, ok := b.topics[msg.Topic()]
+	b.mu.Unlock()
+	if !ok {
+		log.Printf("MQTT message for unknown topic %s", msg.Topic())
+		return
+	}
+
+	err := writeMqttReading(b.ctx, t, msg.Payload())
+	if err != nil {
+		log.Printf("could not write MQTT reading from topic %s: %v", msg.Topic(), err)
+	}
+}
+
+// writeMqttReading authenticates the device using its device key,
+// then writes the reading as a scalar or text depending on the pin.
+func writeMqttReading(ctx context.Context, t model.MqttTopic, payload []byte) error {
+	if !validMqttPin(t.Pin) {
+		return errInvalidPin
+	}
+	var rd mqttReading
+	err := json.Unmarshal(payload, &rd)
+	if err != nil {
+		return errInvalidJSON
+	}
+
+	ma := model.MacDecode(t.Mac)
+	dev, err := model.CheckDevice(ctx, settingsStore, ma, rd.DK)
+	if err != nil {
+		return fmt.Errorf("device %s failed check: %w", ma, err)
+	}
+	if dev.Skey != t.Skey {
+		return fmt.Errorf("device %s does not belong to site %d", ma, t.Skey)
+	}
+	countUsage(dev, int64(len(payload)))
+
+	ts := rd.TS
+	if ts == 0 {
+		ts = time.Now().Unix()
+	}
+
+	switch t.Pin[0] {
+	case 'A', 'D', 'X':
+		if rd.Value == nil {
+			return errInvalidValue
+		}
+		err = model.PutScalar(ctx, mediaStore, &model.Scalar{ID: model.ToSID(ma, t.Pin), Timestamp: ts, Value: *rd.Value})
+		if err != nil {
+			return err
+		}
+		checkSensor(ctx, dev, t.Pin)
+		evaluateAlertRules(ctx, dev, t.Pin, *rd.Value, ts)
+		return nil
+
+	case 'T':
+		return model.WriteText(ctx, mediaStore, &model.Text{MID: model.ToMID(ma, t.Pin), Timestamp: ts, Data: rd.Text, Type: "text/plain"})
+
+	default:
+		return errInvalidPin
+	}
+}
@@ -0,0 +1,40 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// TestWriteMqttReadingInvalidPin tests that readings for topics with
+// invalid pins are rejected before the device is checked.
+func TestWriteMqttReadingInvalidPin(t *testing.T) {
+	for _, pin := range []string{"", "A", "B0", "S0"} {
+		err := writeMqttReading(context.Background(), model.MqttTopic{Mac: 1, Pin: pin}, []byte(`{"dk":"1","value":1}`))
+		if !errors.Is(err, errInvalidPin) {
+			t.Errorf("pin %q: writeMqttReading returned %v, want %v", pin, err, errInvalidPin)
+		}
+	}
+}
@@ -24,15 +24,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/ausocean/av/container/mts"
 	"github.com/ausocean/av/container/mts/pes"
+	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/openfish/datastore"
 )
@@ -51,8 +52,22 @@ import (
 // The supplied MAC address (ma) must correspond to a valid
 // NetReceiver device and the supplied device key (dk) must to match
 // the device's. The pin type (pn) must be either V(ideo) or S(ound).
+//
+// So that devices which buffer media locally know what they can
+// safely delete, the response lists the segments persisted ("sg"),
+// each with its pin, timestamp and size in bytes, and the range of
+// timestamps persisted per pin ("pr"). Devices may also supply an
+// idempotency key (ik), unique per upload, in which case a retried
+// upload that previously succeeded returns the original response
+// without writing the media again, and a concurrent retry of an upload
+// in progress fails with 409 Conflict.
+//
+// Clips are validated on receipt, and their continuity counter and PCR
+// discontinuities are logged and counted; see mtsStatusHandler.
+//
+// Once persisted, audio clips are queued for the device's audio
+// detectors, if any; see queueDetections.
 func mtsHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	ctx := r.Context()
 
 	q := r.URL.Query()
@@ -63,9 +78,15 @@ func mtsHandler(w http.ResponseWriter, r *http.Request) {
 	setup(ctx)
 	dev, err := model.CheckDevice(ctx, settingsStore, ma, dk)
 	if err != nil {
-		writeDeviceError(w, dev, err)
+		writeDeviceError(w, r, dev, err)
+		return
+	}
+	ctx = backend.WithSite(ctx, dev.Skey)
+	recordDeviceKey(ctx, dev, dk)
+	if throttled(w, r, dev) {
 		return
 	}
+	countUsage(dev, requestSize(r))
 
 	gh := q.Get("gh")
 
@@ -74,15 +95,39 @@ func mtsHandler(w http.ResponseWriter, r *http.Request) {
 	if t != "" {
 		ts, err = strconv.ParseInt(t, 10, 64)
 		if err != nil {
-			writeError(w, err)
+			writeError(w, r, err)
 		}
 	}
 	if ts == 0 {
 		ts = time.Now().Unix()
 	}
 
+	// Claim the upload, or return the original response for a retried upload.
+	ik := q.Get("ik")
+	if ik != "" {
+		u, err := model.ClaimMtsUpload(ctx, mediaStore, dev.Mac, ik)
+		switch {
+		case errors.Is(err, model.ErrMtsUploadInProgress):
+			r.Body.Close()
+			writeStatusError(w, r, http.StatusConflict, err)
+			return
+		case err != nil:
+			backend.Printf(ctx, "could not claim MTS upload %s: %v", ik, err)
+			ik = ""
+		case u != nil:
+			backend.Printf(ctx, "/mts upload %s from %s already persisted", ik, ma)
+			r.Body.Close()
+			w.Write(u.Response)
+			return
+		}
+	}
+
 	resp := make(map[string]interface{})
 	resp["ma"] = ma
+	if ik != "" {
+		resp["ik"] = ik
+	}
+	segs := []mtsSegment{}
 
 	var found bool
 	for _, pin := range dev.InputList() {
@@ -104,36 +149,49 @@ func mtsHandler(w http.ResponseWriter, r *http.Request) {
 		n, err := io.ReadFull(r.Body, clip)
 		// NB: An empty body (sz == 0) is _not_ considered invalid (as it is useful for testing).
 		if err != nil {
-			log.Printf("could not read body: %v", err)
+			backend.Printf(ctx, "could not read body: %v", err)
 			break
 		}
 		if n != sz || n%mts.PacketSize != 0 {
-			log.Printf("invalid size: n = %d, sz=%d", n, sz)
+			backend.Printf(ctx, "invalid size: n = %d, sz=%d", n, sz)
 			resp["er"] = errInvalidSize.Error()
 			break
 		}
+		recordMts(ctx, dev.MAC(), pin, clip)
 		mid := model.ToMID(ma, pin)
-		err = writeMtsMedia(ctx, mid, gh, ts, clip, model.WriteMtsMedia)
+		err = writeMtsMedia(ctx, mid, gh, ts, clip, func(ctx context.Context, store datastore.Store, m *model.MtsMedia) error {
+			sz := len(m.Clip) // WriteMtsMedia may truncate the clip.
+			err := model.WriteMtsMedia(ctx, store, m)
+			if err == nil {
+				segs = append(segs, mtsSegment{Pin: pin, Timestamp: m.Timestamp, Size: sz})
+			}
+			return err
+		})
 		if err != nil {
-			log.Printf("could not write MTS media: %v", err)
+			backend.Printf(ctx, "could not write MTS media: %v", err)
 			resp["er"] = fmt.Sprintf("could not write MTS media: %v", err)
 			break
 		}
 	}
 
 	if !found {
-		log.Printf("/mts called without MTS data")
+		backend.Printf(ctx, "/mts called without MTS data")
 	}
 
 	err = r.Body.Close()
 	if err != nil {
-		log.Printf("could not close body: %v", err)
+		backend.Printf(ctx, "could not close body: %v", err)
 		// Don't bother to inform the client.
 	}
 
 	// Insert timestamp
 	resp["ts"] = ts
 
+	// Insert write confirmations.
+	resp["sg"] = segs
+	ranges := persistedRanges(segs)
+	resp["pr"] = ranges
+
 	// Insert device location, if any
 	if dev.Latitude != 0 && dev.Longitude != 0 {
 		resp["ll"] = fmt.Sprintf("%0.5f,%0.5f", dev.Latitude, dev.Longitude)
@@ -142,10 +200,82 @@ func mtsHandler(w http.ResponseWriter, r *http.Request) {
 	// Return response to client as JSON
 	jsn, err := json.Marshal(resp)
 	if err != nil {
-		log.Printf("could not marshal JSON: %v", err)
+		backend.Printf(ctx, "could not marshal JSON: %v", err)
+		releaseMtsUpload(ctx, dev, ik)
 		return
 	}
 	fmt.Fprint(w, string(jsn))
+
+	// Remember successful uploads, so that retries are not written
+	// again, and release the claim on failed uploads so they can be retried.
+	_, failed := resp["er"]
+	switch {
+	case ik == "":
+	case failed:
+		releaseMtsUpload(ctx, dev, ik)
+	default:
+		err = model.PutMtsUpload(ctx, mediaStore, &model.MtsUpload{Mac: dev.Mac, Key: ik, Response: jsn})
+		if err != nil {
+			backend.Printf(ctx, "could not put MTS upload %s: %v", ik, err)
+		}
+	}
+
+	queueDetections(ctx, dev, ranges)
+}
+
+// releaseMtsUpload releases the claim on an MTS upload, if any.
+func releaseMtsUpload(ctx context.Context, dev *model.Device, ik string) {
+	if ik == "" {
+		return
+	}
+	err := model.DeleteMtsUpload(ctx, mediaStore, dev.Mac, ik)
+	if err != nil {
+		backend.Printf(ctx, "could not delete MTS upload %s: %v", ik, err)
+	}
+}
+
+// purgeUploadsHandler deletes expired MTS uploads. It is requested by
+// App Engine cron (see cron.yaml), and requests must satisfy
+// cronRequest.
+func purgeUploadsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	setup(ctx)
+
+	if !cronRequest(r) {
+		backend.Printf(ctx, "purge uploads request from %s is unauthorized", r.RemoteAddr)
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	n, err := model.DeleteExpiredMtsUploads(ctx, mediaStore, time.Now())
+	if err != nil {
+		backend.WriteError(w, http.StatusInternalServerError, fmt.Errorf("could not delete expired MTS uploads: %w", err))
+		return
+	}
+	backend.Printf(ctx, "deleted %d expired MTS uploads", n)
+}
+
+// mtsSegment confirms that an MTS segment was persisted.
+type mtsSegment struct {
+	Pin       string `json:"pn"`
+	Timestamp int64  `json:"ts"`
+	Size      int    `json:"sz"` // Size in bytes.
+}
+
+// persistedRanges returns the first and last timestamps of the
+// persisted segments for each pin.
+func persistedRanges(segs []mtsSegment) map[string][2]int64 {
+	ranges := make(map[string][2]int64)
+	for _, sg := range segs {
+		r, ok := ranges[sg.Pin]
+		if !ok {
+			r = [2]int64{sg.Timestamp, sg.Timestamp}
+		}
+		r[0] = min(r[0], sg.Timestamp)
+		r[1] = max(r[1], sg.Timestamp)
+		ranges[sg.Pin] = r
+	}
+	return ranges
 }
 
 // writeMtsMedia splits MTS data on PSI boundaries (~1 second for
@@ -153,14 +283,14 @@ func mtsHandler(w http.ResponseWriter, r *http.Request) {
 // should start with PSI (PAT and then PMT); anything prior is ignored.
 func writeMtsMedia(ctx context.Context, mid int64, gh string, ts int64, data []byte, write func(context.Context, datastore.Store, *model.MtsMedia) error) error {
 	if len(data) == 0 {
-		log.Printf("writeMtsMedia(%d) called with zero-length data", mid)
+		backend.Printf(ctx, "writeMtsMedia(%d) called with zero-length data", mid)
 		return nil
 	}
 
 	// First find PSI (PAT+PMT).
 	i, s, m, err := mts.FindPSI(data)
 	if err != nil {
-		log.Printf("writeMtsMedia(%d) PSI not found, len=%d", mid, len(data))
+		backend.Printf(ctx, "writeMtsMedia(%d) PSI not found, len=%d", mid, len(data))
 		return write(ctx, mediaStore, &model.MtsMedia{MID: mid, Geohash: gh, Timestamp: ts, Continues: true, Clip: data})
 	}
 
@@ -168,12 +298,12 @@ func writeMtsMedia(ctx context.Context, mid int64, gh string, ts int64, data []b
 	// we have an unknown media type, then the MtsMedia.Type field will remain unset.
 	var mime string
 	if len(s) == 0 {
-		log.Printf("writeMtsMedia(%d) no elementary streams in media", mid)
+		backend.Printf(ctx, "writeMtsMedia(%d) no elementary streams in media", mid)
 	} else {
 		for _, v := range s {
 			mime, err = pes.SIDToMIMEType(int(v))
 			if err != nil {
-				log.Printf("writeMtsMedia(%d) could not get MIME type: %v", mid, err)
+				backend.Printf(ctx, "writeMtsMedia(%d) could not get MIME type: %v", mid, err)
 			}
 			break
 		}
@@ -184,21 +314,21 @@ func writeMtsMedia(ctx context.Context, mid int64, gh string, ts int64, data []b
 	if err != nil {
 		const defaultRate = 25.0 // ToDo: Write rate depends on the media type and the CODEC used.
 		wr = defaultRate
-		log.Printf("writeMtsMedia(%d) write rate not found; defaulting to %f", mid, wr)
+		backend.Printf(ctx, "writeMtsMedia(%d) write rate not found; defaulting to %f", mid, wr)
 	}
 	fp := int64((1 / wr)) * mts.PTSFrequency
 
 	// Get the first timestamp, or default to supplied ts.
 	t, err := strconv.Atoi(m["ts"])
 	if err != nil {
-		log.Printf("writeMtsMedia(%d) timestamp not found; defaulting to %d", mid, ts)
+		backend.Printf(ctx, "writeMtsMedia(%d) timestamp not found; defaulting to %d", mid, ts)
 	} else {
 		ts = int64(t)
 	}
 
 	// Trim before first PSI.
 	if i > 0 {
-		log.Printf("writeMtsMedia(%d) trimming %d bytes at start", mid, i)
+		backend.Printf(ctx, "writeMtsMedia(%d) trimming %d bytes at start", mid, i)
 		data = data[i:]
 		i = 0
 	}
@@ -225,7 +355,7 @@ func writeMtsMedia(ctx context.Context, mid int64, gh string, ts int64, data []b
 			if sz > datastore.MaxBlob {
 				// Trim clips if they exceed the max blob size.
 				sz = datastore.MaxBlob / mts.PacketSize * mts.PacketSize
-				log.Printf("writeMtsMedia(%d) trimming %d bytes at end", mid, i+psiSize+j-sz)
+				backend.Printf(ctx, "writeMtsMedia(%d) trimming %d bytes at end", mid, i+psiSize+j-sz)
 			}
 			err := write(ctx, mediaStore, &model.MtsMedia{MID: mid, Geohash: gh, Timestamp: ts, Continues: true, Type: mime, Clip: data[:sz], FramePTS: fp})
 			if err != nil {
@@ -0,0 +1,197 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// mts_validate.go implements receive-side validation of MPEG-TS
+// clips, which checks continuity counters and PCR monotonicity within
+// each clip. Discontinuities found on receipt indicate corruption at
+// the device, rather than in forwarding. Clips are validated
+// individually, since consecutive clips of a device pin may be received
+// by different instances, and the errors of each device pin are
+// recorded in the datastore.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ausocean/av/container/mts"
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// MPEG-TS packet constants.
+const (
+	mtsSyncByte = 0x47
+	mtsNullPID  = 0x1fff
+)
+
+// pcrWrap is the period of the PCR, whose 33-bit base counts 90kHz
+// ticks and whose 9-bit extension counts 27MHz ticks.
+const pcrWrap = (1 << 33) * 300
+
+// mtsClipStats holds the validation statistics of an MPEG-TS clip,
+// and the continuity state of its packets.
+type mtsClipStats struct {
+	Packets   int64
+	CCErrors  int64 // Continuity counter discontinuities.
+	PCRErrors int64 // PCRs which did not increase.
+	BadSync   int64 // Packets without a sync byte.
+
+	cc  map[uint16]byte   // Last continuity counter per PID.
+	pcr map[uint16]uint64 // Last PCR per PID.
+}
+
+// validateMts validates the packets of a clip, continuing from the
+// clip's first packet of each PID, and returns its stats.
+func validateMts(clip []byte) *mtsClipStats {
+	st := &mtsClipStats{cc: make(map[uint16]byte), pcr: make(map[uint16]uint64)}
+	for i := 0; i+mts.PacketSize <= len(clip); i += mts.PacketSize {
+		st.validatePacket(clip[i : i+mts.PacketSize])
+	}
+	return st
+}
+
+// validatePacket validates a packet, counting whether its continuity
+// counter or PCR is discontinuous with the previous packet of its PID.
+// Discontinuities signalled by the packet's discontinuity indicator
+// are not errors.
+func (st *mtsClipStats) validatePacket(p []byte) {
+	st.Packets++
+	if p[0] != mtsSyncByte {
+		st.BadSync++
+		return
+	}
+	pid := uint16(p[1]&0x1f)<<8 | uint16(p[2])
+	if pid == mtsNullPID {
+		return
+	}
+	afc := p[3] >> 4 & 0x3
+	cc := p[3] & 0x0f
+	hasAF := afc&0x2 != 0 && p[4] > 0
+	discontinuity := hasAF && p[5]&0x80 != 0
+
+	// The counter increments only for packets with a payload, which
+	// may be duplicated once.
+	if last, ok := st.cc[pid]; ok && !discontinuity {
+		want := last
+		if afc&0x1 != 0 {
+			want = (last + 1) & 0x0f
+		}
+		if cc != want && cc != last {
+			st.CCErrors++
+		}
+	}
+	st.cc[pid] = cc
+
+	if hasAF && p[5]&0x10 != 0 && p[4] >= 7 {
+		base := uint64(p[6])<<25 | uint64(p[7])<<17 | uint64(p[8])<<9 | uint64(p[9])<<1 | uint64(p[10])>>7
+		ext := uint64(p[10]&0x1)<<8 | uint64(p[11])
+		pcr := base*300 + ext
+		if last, ok := st.pcr[pid]; ok && !discontinuity && pcr <= last && last-pcr < pcrWrap/2 {
+			st.PCRErrors++
+		}
+		st.pcr[pid] = pcr
+	}
+}
+
+// recordMts validates a clip received from a device pin, logging and
+// recording any errors.
+func recordMts(ctx context.Context, ma, pin string, clip []byte) {
+	st := validateMts(clip)
+	if st.CCErrors == 0 && st.PCRErrors == 0 && st.BadSync == 0 {
+		return
+	}
+	backend.Printf(ctx, "MTS from %s.%s has %d continuity and %d PCR discontinuities and %d bad sync bytes", ma, pin, st.CCErrors, st.PCRErrors, st.BadSync)
+	err := model.AddMtsValidation(ctx, mediaStore, model.ToMID(ma, pin), st.CCErrors, st.PCRErrors, st.BadSync, time.Now())
+	if err != nil {
+		backend.Printf(ctx, "could not add MTS validation for %s.%s: %v", ma, pin, err)
+	}
+}
+
+// mtsStatusHandler handles requests from services for the MTS
+// validation errors of a device's pins, of which only pins with
+// errors are returned. Requests are authorized by a JWT signed with
+// the cron secret, whose skey claim must match the site of the device.
+//
+// GET params:
+// - ma: MAC address.
+func mtsStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	setup(ctx)
+
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	if err != nil {
+		log.Printf("MTS status request from %s has invalid claims: %v", r.RemoteAddr, err)
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	skey, ok := claims["skey"].(float64)
+	if !ok {
+		backend.WriteError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	ma := r.FormValue("ma")
+	dev, err := model.GetDevice(ctx, settingsStore, model.MacEncode(ma))
+	if err != nil {
+		backend.WriteError(w, http.StatusNotFound, model.ErrDeviceNotFound)
+		return
+	}
+	if dev.Skey != int64(skey) {
+		backend.WriteError(w, http.StatusForbidden, errUnauthorized)
+		return
+	}
+
+	type pinStats struct {
+		Clips     int64     `json:"clips"` // Clips with errors.
+		CCErrors  int64     `json:"ccErrors"`
+		PCRErrors int64     `json:"pcrErrors"`
+		BadSync   int64     `json:"badSync"`
+		LastError time.Time `json:"lastError"`
+	}
+	pins := make(map[string]pinStats)
+	for _, pin := range dev.InputList() {
+		if !isMtsPin(pin) {
+			continue
+		}
+		v, err := model.GetMtsValidation(ctx, mediaStore, model.ToMID(dev.MAC(), pin))
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			continue
+		}
+		if err != nil {
+			backend.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		pins[pin] = pinStats{Clips: v.Clips, CCErrors: v.CCErrors, PCRErrors: v.PCRErrors, BadSync: v.BadSync, LastError: v.LastError}
+	}
+
+	resp, _ := json.Marshal(struct {
+		MAC  string              `json:"ma"`
+		Pins map[string]pinStats `json:"pins"`
+	}{MAC: dev.MAC(), Pins: pins})
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(resp)
+}
@@ -0,0 +1,71 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/ausocean/av/container/mts"
+)
+
+// packet returns an MPEG-TS packet of a PID with a payload and the
+// given continuity counter.
+func packet(pid uint16, cc byte) []byte {
+	p := make([]byte, mts.PacketSize)
+	p[0] = mtsSyncByte
+	p[1] = byte(pid >> 8)
+	p[2] = byte(pid)
+	p[3] = 0x10 | cc
+	return p
+}
+
+// TestValidateMts tests validating the continuity of the packets of
+// a clip.
+func TestValidateMts(t *testing.T) {
+	clip := func(ps ...[]byte) []byte {
+		var b []byte
+		for _, p := range ps {
+			b = append(b, p...)
+		}
+		return b
+	}
+	badSync := packet(256, 0)
+	badSync[0] = 0
+
+	tests := []struct {
+		desc        string
+		clip        []byte
+		wantCC      int64
+		wantBadSync int64
+	}{
+		{desc: "continuous", clip: clip(packet(256, 3), packet(256, 4), packet(257, 9), packet(256, 5))},
+		{desc: "wraps", clip: clip(packet(256, 15), packet(256, 0))},
+		{desc: "duplicate", clip: clip(packet(256, 1), packet(256, 1), packet(256, 2))},
+		{desc: "discontinuous", clip: clip(packet(256, 1), packet(256, 3), packet(256, 4), packet(256, 9)), wantCC: 2},
+		{desc: "bad sync", clip: clip(packet(256, 1), badSync), wantBadSync: 1},
+	}
+	for _, tt := range tests {
+		st := validateMts(tt.clip)
+		if st.Packets != int64(len(tt.clip)/mts.PacketSize) || st.CCErrors != tt.wantCC || st.BadSync != tt.wantBadSync {
+			t.Errorf("%s: got %d packets, %d CC errors and %d bad sync, want %d, %d and %d", tt.desc, st.Packets, st.CCErrors, st.BadSync, len(tt.clip)/mts.PacketSize, tt.wantCC, tt.wantBadSync)
+		}
+	}
+}
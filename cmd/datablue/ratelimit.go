@@ -0,0 +1,92 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// ratelimit.go implements per-device and per-site rate limiting of device requests.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+)
+
+// throttledKind is the notification kind for throttled devices.
+const throttledKind notify.Kind = "throttled"
+
+// Rate limits in requests per minute, where zero means unlimited.
+// Buckets hold one minute's worth of requests, which allows devices
+// to catch up after a brief outage.
+var (
+	deviceRate float64
+	siteRate   float64
+)
+
+var errThrottled = errors.New("too many requests")
+
+// throttled returns true if either the device or its site has
+// exceeded its rate limit, in which case it writes a 429 response.
+// Ops are notified when throttling starts. Rate limiting errors are
+// logged but do not block requests.
+func throttled(w http.ResponseWriter, r *http.Request, dev *model.Device) bool {
+	ctx := r.Context()
+	for _, b := range []struct {
+		name string
+		rate float64
+	}{
+		{model.DeviceBucket(dev.Mac), deviceRate},
+		{model.SiteBucket(dev.Skey), siteRate},
+	} {
+		ok, started, err := model.TakeToken(ctx, settingsStore, b.name, b.rate/60, b.rate)
+		if err != nil {
+			log.Printf("could not take token for %s: %v", b.name, err)
+			continue
+		}
+		if ok {
+			continue
+		}
+		if started {
+			notifyThrottled(ctx, dev, b.name, b.rate)
+		}
+		w.Header().Add("Retry-After", "60")
+		writeStatusError(w, r, http.StatusTooManyRequests, errThrottled)
+		return true
+	}
+	return false
+}
+
+// notifyThrottled notifies ops that a device is being throttled.
+func notifyThrottled(ctx context.Context, dev *model.Device, name string, rate float64) {
+	msg := fmt.Sprintf("device %s (%s) throttled at %s, exceeding %s limit of %.0f requests per minute",
+		dev.Name, dev.MAC(), time.Now().UTC().Format(time.RFC3339), name, rate)
+	log.Print(msg)
+	if notifier == nil {
+		return
+	}
+	err := notifier.Send(ctx, dev.Skey, throttledKind, msg)
+	if err != nil {
+		log.Printf("could not send throttled notification: %v", err)
+	}
+}
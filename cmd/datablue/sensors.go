@@ -0,0 +1,121 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// sensors.go implements provisional sensors for pins that send data without a sensor.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	// provisionalSensorKind is the notification kind for provisional sensors.
+	provisionalSensorKind notify.Kind = "provisional-sensor"
+
+	// provisionalSensorPeriod is the minimum time between notifications
+	// of provisional sensors for a device.
+	provisionalSensorPeriod = 24 * time.Hour
+
+	// provisionedTTL is the time for which pins that have been
+	// provisioned, or whose provisional sensors were dismissed, are
+	// not provisioned again by an instance.
+	provisionedTTL = time.Hour
+)
+
+// provisioned holds the expiry times of device pins, as <MAC>.<pin>,
+// that this instance has provisioned or found dismissed, so that
+// readings from pins without a sensor do not each attempt to
+// provision one.
+var provisioned = struct {
+	sync.Mutex
+	pins map[string]time.Time
+}{pins: map[string]time.Time{}}
+
+// checkSensor provisions a sensor for a device pin if it has none.
+func checkSensor(ctx context.Context, dev *model.Device, pin string) {
+	_, err := model.GetSensorV2(ctx, settingsStore, dev.Mac, pin)
+	switch {
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+		provisionSensor(ctx, dev, pin)
+	case err != nil:
+		backend.Printf(ctx, "could not get sensor %s.%s: %v", dev.Hex(), pin, err)
+	}
+}
+
+// provisionSensor creates a provisional sensor for a device pin that
+// has sent data but has no sensor, and notifies ops, so that the pin
+// is reviewed rather than its data going unlabeled. Provisional
+// sensors are confirmed or dismissed by admins in Ocean Bench, and
+// dismissed pins are not provisioned again. Ops are notified at most
+// once per device per provisionalSensorPeriod, since the notification
+// does not name the pin and is deduplicated (see setup). Errors are
+// logged rather than returned, since provisioning must not prevent
+// data from being received.
+func provisionSensor(ctx context.Context, dev *model.Device, pin string) {
+	id := dev.Hex() + "." + pin
+	now := time.Now()
+	provisioned.Lock()
+	expires, ok := provisioned.pins[id]
+	provisioned.Unlock()
+	if ok && now.Before(expires) {
+		return
+	}
+
+	_, err := model.CreateProvisionalSensorV2(ctx, settingsStore, dev.Mac, pin)
+	switch {
+	case errors.Is(err, datastore.ErrEntityExists), errors.Is(err, model.ErrSensorDismissed):
+		// Created by a concurrent request or dismissed.
+	case err != nil:
+		backend.Printf(ctx, "could not create provisional sensor %s: %v", id, err)
+		return
+	}
+	provisioned.Lock()
+	for k, v := range provisioned.pins {
+		if !now.Before(v) {
+			delete(provisioned.pins, k)
+		}
+	}
+	provisioned.pins[id] = now.Add(provisionedTTL)
+	provisioned.Unlock()
+	if err != nil {
+		return
+	}
+
+	backend.Printf(ctx, "created provisional sensor for %s", id)
+	msg := fmt.Sprintf("device %s (%s) sent data on pins without sensors, so provisional sensors were created for review",
+		dev.Name, dev.MAC())
+	if notifier == nil {
+		return
+	}
+	nctx := notify.NewContext(ctx, &notify.Data{Device: dev})
+	err = notifier.Send(nctx, dev.Skey, provisionalSensorKind, msg)
+	if err != nil {
+		backend.Printf(ctx, "could not send provisional sensor notification: %v", err)
+	}
+}
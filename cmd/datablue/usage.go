@@ -0,0 +1,115 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Data Blue. This is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Data Blue is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Data Blue in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// usage.go implements counting of the data ingested from devices.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// usageFlushPeriod is how often data usage is written to the
+// datastore. Usage is also flushed when an instance shuts down, but
+// is lost if it is killed, so this should not be too long.
+const usageFlushPeriod = time.Minute
+
+// usageKey identifies a device's data usage for a day.
+type usageKey struct {
+	skey, mac int64
+	day       time.Time // Start of the day, UTC.
+}
+
+// usageCount is a count of bytes and requests.
+type usageCount struct {
+	bytes, requests int64
+}
+
+// Data usage is counted in memory and flushed periodically, rather
+// than written on every request, to avoid doubling datastore writes.
+var (
+	usageMu   sync.Mutex
+	usage     = map[usageKey]usageCount{}
+	usageOnce sync.Once
+)
+
+// countUsage counts n bytes ingested from a device in one request,
+// and starts the flusher on first use.
+func countUsage(dev *model.Device, n int64) {
+	usageOnce.Do(func() { go flushUsagePeriodically(context.Background()) })
+	k := usageKey{dev.Skey, dev.Mac, time.Now().UTC().Truncate(24 * time.Hour)}
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	c := usage[k]
+	c.bytes += n
+	c.requests++
+	usage[k] = c
+}
+
+// requestSize returns the number of bytes in a request's query string
+// and body. The body is only counted if its length is known.
+func requestSize(r *http.Request) int64 {
+	n := int64(len(r.URL.RawQuery))
+	if r.ContentLength > 0 {
+		n += r.ContentLength
+	}
+	return n
+}
+
+// flushUsagePeriodically flushes data usage every usageFlushPeriod.
+func flushUsagePeriodically(ctx context.Context) {
+	for range time.Tick(usageFlushPeriod) {
+		_ = flushUsage(ctx)
+	}
+}
+
+// flushUsage adds counted data usage to the datastore. Usage that
+// cannot be written is retained for the next flush, and an error is
+// returned. It is also registered as a shutdown hook, so that usage
+// counted since the last periodic flush is not lost.
+func flushUsage(ctx context.Context) error {
+	usageMu.Lock()
+	pending := usage
+	usage = map[usageKey]usageCount{}
+	usageMu.Unlock()
+
+	var errs []error
+	for k, c := range pending {
+		err := model.AddDataUsage(ctx, settingsStore, k.skey, k.mac, k.day, c.bytes, c.requests)
+		if err == nil {
+			continue
+		}
+		log.Printf("could not add data usage for %s: %v", model.MacDecode(k.mac), err)
+		errs = append(errs, err)
+		usageMu.Lock()
+		r := usage[k]
+		r.bytes += c.bytes
+		r.requests += c.requests
+		usage[k] = r
+		usageMu.Unlock()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,286 @@
+/*
+DESCRIPTION
+  Package backup implements datastore backups to Google Cloud Storage.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+// Package backup implements datastore backups to Google Cloud
+// Storage (GCS). Each backup dumps the entities of the configured
+// kinds, one encoded entity per line, to objects named
+// <prefix>/<timestamp>/<kind>, where the timestamp is in
+// 20060102T150405Z format. Backups older than the retention period
+// are pruned after each successful backup.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/ausocean/openfish/datastore"
+	"google.golang.org/api/iterator"
+)
+
+// TimeFormat is the format of backup timestamps.
+const TimeFormat = "20060102T150405Z"
+
+// Config represents a backup configuration.
+type Config struct {
+	Bucket    string   `json:"bucket"`    // GCS bucket name.
+	Prefix    string   `json:"prefix"`    // Object name prefix, e.g., the datastore name.
+	Kinds     []string `json:"kinds"`     // Kinds to back up.
+	Retention int      `json:"retention"` // Retention period in days, or zero to retain all backups.
+	Skey      int64    `json:"-"`         // Site key to restrict the backup to, or zero for all sites.
+}
+
+// Result represents the outcome of a backup.
+type Result struct {
+	Time   string         // Backup timestamp.
+	Counts map[string]int // Entities backed up, by kind.
+	Pruned []string       // Timestamps of pruned backups.
+}
+
+// Bucket represents the storage bucket operations used by backups.
+type Bucket interface {
+	NewWriter(ctx context.Context, name string) io.WriteCloser
+	NewReader(ctx context.Context, name string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// gcsBucket implements Bucket for a GCS bucket.
+type gcsBucket struct {
+	bkt *storage.BucketHandle
+}
+
+// NewGCSBucket returns a Bucket for the named GCS bucket.
+func NewGCSBucket(ctx context.Context, name string) (Bucket, error) {
+	c, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create storage client: %w", err)
+	}
+	return &gcsBucket{bkt: c.Bucket(name)}, nil
+}
+
+// NewWriter returns a writer for the named object.
+func (b *gcsBucket) NewWriter(ctx context.Context, name string) io.WriteCloser {
+	return b.bkt.Object(name).NewWriter(ctx)
+}
+
+// NewReader returns a reader for the named object.
+func (b *gcsBucket) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.bkt.Object(name).NewReader(ctx)
+}
+
+// List returns the names of the objects with the given prefix.
+func (b *gcsBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	it := b.bkt.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, attrs.Name)
+	}
+}
+
+// Delete deletes the named object.
+func (b *gcsBucket) Delete(ctx context.Context, name string) error {
+	return b.bkt.Object(name).Delete(ctx)
+}
+
+// Dump writes the entities of the given kind to w, one encoded entity
+// per line, returning the number of entities written. Entities that
+// implement datastore.EntityEncoder are encoded with Encode, otherwise
+// they are JSON encoded.
+func Dump(ctx context.Context, store datastore.Store, kind string, w io.Writer) (int, error) {
+	return dump(ctx, store, kind, 0, w)
+}
+
+// dump implements Dump, writing only the entities whose Skey property
+// is skey, if non-zero. Since FileStore queries cannot filter on
+// properties, entities are also checked once retrieved.
+func dump(ctx context.Context, store datastore.Store, kind string, skey int64, w io.Writer) (int, error) {
+	q := store.NewQuery(kind, true)
+	if skey != 0 {
+		q.Filter("Skey =", skey)
+	}
+	keys, err := store.GetAll(ctx, q, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, k := range keys {
+		e, err := datastore.NewEntity(kind)
+		if err != nil {
+			return n, err
+		}
+		err = store.Get(ctx, k, e)
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			continue // Deleted since the query.
+		}
+		if err != nil {
+			return n, err
+		}
+		if skey != 0 && !hasSkey(e, skey) {
+			continue
+		}
+
+		var encoded []byte
+		encodable, ok := e.(datastore.EntityEncoder)
+		if ok {
+			encoded = encodable.Encode()
+		} else {
+			encoded, err = json.Marshal(e)
+			if err != nil {
+				return n, fmt.Errorf("could not encode %s entity: %w", kind, err)
+			}
+		}
+		_, err = w.Write(append(encoded, '\n'))
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// hasSkey returns true if an entity has an Skey field equal to skey.
+func hasSkey(e datastore.Entity, skey int64) bool {
+	f := reflect.Indirect(reflect.ValueOf(e)).FieldByName("Skey")
+	return f.IsValid() && f.Kind() == reflect.Int64 && f.Int() == skey
+}
+
+// Run backs up the configured kinds from store to bkt, verifies that
+// each object holds the expected number of entities, then prunes
+// backups older than the retention period. Pruning is skipped if the
+// backup fails. If the configuration has a site key, only entities of
+// that site are backed up.
+func Run(ctx context.Context, store datastore.Store, bkt Bucket, cfg Config, now time.Time) (*Result, error) {
+	if len(cfg.Kinds) == 0 {
+		return nil, errors.New("no kinds to back up")
+	}
+	res := &Result{Time: now.UTC().Format(TimeFormat), Counts: map[string]int{}}
+	for _, kind := range cfg.Kinds {
+		name := objectName(cfg.Prefix, res.Time, kind)
+		w := bkt.NewWriter(ctx, name)
+		n, err := dump(ctx, store, kind, cfg.Skey, w)
+		if err != nil {
+			w.Close()
+			return res, fmt.Errorf("could not back up %s: %w", kind, err)
+		}
+		err = w.Close()
+		if err != nil {
+			return res, fmt.Errorf("could not write %s: %w", name, err)
+		}
+		err = verify(ctx, bkt, name, n)
+		if err != nil {
+			return res, err
+		}
+		res.Counts[kind] = n
+	}
+
+	if cfg.Retention <= 0 {
+		return res, nil
+	}
+	var err error
+	res.Pruned, err = prune(ctx, bkt, cfg.Prefix, now.Add(-time.Duration(cfg.Retention)*24*time.Hour))
+	if err != nil {
+		return res, fmt.Errorf("could not prune backups: %w", err)
+	}
+	return res, nil
+}
+
+// objectName returns the name of the object for a kind's backup.
+func objectName(prefix, ts, kind string) string {
+	if prefix == "" {
+		return ts + "/" + kind
+	}
+	return prefix + "/" + ts + "/" + kind
+}
+
+// verify checks that the named object holds n entities.
+func verify(ctx context.Context, bkt Bucket, name string, n int) error {
+	r, err := bkt.NewReader(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", name, err)
+	}
+	defer r.Close()
+	lines := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16*1024*1024)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) > 0 {
+			lines++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("could not read %s: %w", name, err)
+	}
+	if lines != n {
+		return fmt.Errorf("%s holds %d entities, expected %d", name, lines, n)
+	}
+	return nil
+}
+
+// prune deletes backups older than t, returning their timestamps.
+func prune(ctx context.Context, bkt Bucket, prefix string, t time.Time) ([]string, error) {
+	if prefix != "" {
+		prefix += "/"
+	}
+	names, err := bkt.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	pruned := map[string]bool{}
+	for _, name := range names {
+		ts, _, ok := strings.Cut(strings.TrimPrefix(name, prefix), "/")
+		if !ok {
+			continue
+		}
+		bt, err := time.Parse(TimeFormat, ts)
+		if err != nil || !bt.Before(t) {
+			continue // Not a backup, or too recent.
+		}
+		err = bkt.Delete(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not delete %s: %w", name, err)
+		}
+		pruned[ts] = true
+	}
+	var tss []string
+	for ts := range pruned {
+		tss = append(tss, ts)
+	}
+	sort.Strings(tss)
+	return tss, nil
+}
@@ -0,0 +1,103 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// memBucket implements Bucket in memory.
+type memBucket map[string]*bytes.Buffer
+
+type memWriter struct {
+	*bytes.Buffer
+}
+
+func (memWriter) Close() error { return nil }
+
+func (b memBucket) NewWriter(ctx context.Context, name string) io.WriteCloser {
+	b[name] = new(bytes.Buffer)
+	return memWriter{b[name]}
+}
+
+func (b memBucket) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	buf, ok := b[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+}
+
+func (b memBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	for name := range b {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (b memBucket) Delete(ctx context.Context, name string) error {
+	delete(b, name)
+	return nil
+}
+
+// TestRunSite tests that backups restricted to a site only include
+// the site's entities.
+func TestRunSite(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	for _, dev := range []model.Device{{Skey: 1, Mac: 1}, {Skey: 1, Mac: 2}, {Skey: 2, Mac: 3}} {
+		err = model.PutDevice(ctx, store, &dev)
+		if err != nil {
+			t.Fatalf("could not put device: %v", err)
+		}
+	}
+
+	bkt := memBucket{}
+	res, err := Run(ctx, store, bkt, Config{Prefix: "netreceiver/1", Kinds: []string{"Device"}, Skey: 1}, time.Now())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if res.Counts["Device"] != 2 {
+		t.Errorf("unexpected number of devices backed up for site: got %d, want 2", res.Counts["Device"])
+	}
+
+	res, err = Run(ctx, store, bkt, Config{Prefix: "netreceiver", Kinds: []string{"Device"}}, time.Now())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if res.Counts["Device"] != 3 {
+		t.Errorf("unexpected number of devices backed up: got %d, want 3", res.Counts["Device"])
+	}
+}
@@ -42,12 +42,30 @@ LICENSE
 //
 // To copy SiteV3 to Site (preserving the ID key), i.e, to complete a migration:
 // - dsadmin --task copy --idkey --kind1 SiteV3 --kind2 Site
+//
+// To migrate variable-encoded broadcast configs to BroadcastConfig entities:
+// - dsadmin --task migrate --kind BroadcastConfig
+//
+// To set the IANA timezones of sites from their UTC offsets:
+// - dsadmin --task migrate --kind SiteTimezone
+//
+// To back up Site and Device entities to a GCS bucket, pruning backups
+// older than 30 days:
+// - dsadmin --task backup --kind Site,Device --bucket ausocean-backups --retention 30
+//
+// To inspect and patch individual entities interactively:
+// - dsadmin --task shell
+//
+// To verify that the composite indexes required by the model exist,
+// optionally only those for a given kind:
+// - dsadmin --task indexes
+// - dsadmin --task indexes --kind Variable
 
 package main
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -56,17 +74,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ausocean/cloud/cmd/dsadmin/backup"
 	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/openfish/datastore"
 	"github.com/ausocean/utils/sliceutils"
 )
 
 func main() {
-	var task, kind, kind2, ds, ds2, input, output string
+	var task, kind, kind2, ds, ds2, input, output, bucket string
 	var key int64
 	var idKey bool
+	var retention int
 
-	flag.StringVar(&task, "task", "", "Datastore task (count, dump, delete, extract, copy or migrate)")
+	flag.StringVar(&task, "task", "", "Datastore task (count, dump, delete, extract, copy, migrate, indexes, backup or shell)")
 	flag.StringVar(&kind, "kind", "", "Datastore kind")
 	flag.StringVar(&kind, "kind1", "", "Datastore kind 1 (same as --kind)")
 	flag.StringVar(&kind2, "kind2", "", "Datastore kind 2")
@@ -76,6 +96,8 @@ func main() {
 	flag.StringVar(&output, "output", "output", "Output file or file store")
 	flag.Int64Var(&key, "key", 0, "Datastore key, e.g., Skey, MID, etc.")
 	flag.BoolVar(&idKey, "idkey", false, "True for and ID key, false for a name key")
+	flag.StringVar(&bucket, "bucket", "", "GCS bucket for backups")
+	flag.IntVar(&retention, "retention", 0, "Backup retention period in days (0 to retain all backups)")
 	flag.Parse()
 
 	log.SetFlags(0) // Minimise log messages.
@@ -94,7 +116,7 @@ func main() {
 		log.Fatal("datastore (-ds2) invalid")
 	}
 
-	if kind == "" {
+	if kind == "" && task != "indexes" && task != "shell" {
 		log.Fatal("kind missing")
 	}
 
@@ -202,10 +224,32 @@ func main() {
 			if err != nil {
 				log.Fatalf("migrateSignals failed with error: %v", err)
 			}
+		case "BroadcastConfig":
+			err = migrateBroadcastConfigs(store)
+			if err != nil {
+				log.Fatalf("migrateBroadcastConfigs failed with error: %v", err)
+			}
+		case "SiteTimezone":
+			err = migrateSiteTimezones(store)
+			if err != nil {
+				log.Fatalf("migrateSiteTimezones failed with error: %v", err)
+			}
 		default:
 			log.Fatalf("invalid kind %s", kind)
 		}
 
+	case "indexes":
+		err = verifyIndexes(store, kind)
+
+	case "shell":
+		err = shell(store, os.Stdin, os.Stdout)
+
+	case "backup":
+		if bucket == "" {
+			log.Fatal("backup requires bucket option")
+		}
+		err = runBackup(store, backup.Config{Bucket: bucket, Prefix: ds, Kinds: strings.Split(kind, ","), Retention: retention})
+
 	default:
 		log.Fatal("invalid task")
 	}
@@ -215,6 +259,53 @@ func main() {
 	}
 }
 
+// verifyIndexes verifies that the composite indexes required by the
+// model exist, optionally only for the given kind, and prints any
+// missing indexes in index.yaml format.
+func verifyIndexes(store datastore.Store, kind string) error {
+	ctx := context.Background()
+
+	var indexes []model.Index
+	for _, idx := range model.Indexes {
+		if kind == "" || idx.Kind == kind {
+			indexes = append(indexes, idx)
+		}
+	}
+	missing, err := model.VerifyIndexes(ctx, store, indexes)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Verified %d indexes, %d missing\n", len(indexes), len(missing))
+	for _, idx := range missing {
+		fmt.Printf("\n%s", idx.YAML())
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d missing indexes, add to index.yaml and deploy", len(missing))
+	}
+	return nil
+}
+
+// runBackup backs up entities to a GCS bucket.
+func runBackup(store datastore.Store, cfg backup.Config) error {
+	ctx := context.Background()
+
+	bkt, err := backup.NewGCSBucket(ctx, cfg.Bucket)
+	if err != nil {
+		return err
+	}
+	res, err := backup.Run(ctx, store, bkt, cfg, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, kind := range cfg.Kinds {
+		fmt.Printf("Backed up %d entities of kind %s to gs://%s/%s/%s/%s\n", res.Counts[kind], kind, cfg.Bucket, cfg.Prefix, res.Time, kind)
+	}
+	if len(res.Pruned) > 0 {
+		fmt.Printf("Pruned backups %s\n", strings.Join(res.Pruned, ", "))
+	}
+	return nil
+}
+
 // count counts entities of the given kind.
 func count(store datastore.Store, kind string) error {
 	ctx := context.Background()
@@ -232,44 +323,18 @@ func count(store datastore.Store, kind string) error {
 func dump(store datastore.Store, kind, file string) error {
 	ctx := context.Background()
 
-	q := store.NewQuery(kind, true)
-	keys, err := store.GetAll(ctx, q, nil)
+	// Create a dump file with one encoded entity per line.
+	var buf bytes.Buffer
+	n, err := backup.Dump(ctx, store, kind, &buf)
 	if err != nil {
 		return err
 	}
 
-	// Create a dump file with one encoded entity per line.
-	n := 0
-	var data []byte
-	for _, k := range keys {
-		e, err := datastore.NewEntity(kind)
-		if err != nil {
-			return err
-		}
-		err = store.Get(ctx, k, e)
-		if err != nil {
-			return err
-		}
-
-		var encoded []byte
-
-		encodable, ok := e.(datastore.EntityEncoder)
-		if ok {
-			encoded = encodable.Encode()
-		} else {
-			encoded, _ = json.Marshal(e)
-		}
-
-		data = append(data, encoded...)
-		data = append(data, '\n')
-		n += 1
-	}
-
-	err = os.WriteFile(file, data, 0666)
+	err = os.WriteFile(file, buf.Bytes(), 0666)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Dumped %d entities of kind %s to file %s\n", len(keys), kind, file)
+	fmt.Printf("Dumped %d entities of kind %s to file %s\n", n, kind, file)
 
 	return nil
 }
@@ -626,6 +691,55 @@ func migrateCrons() error {
 	return nil
 }
 
+// migrateBroadcastConfigs migrates the variable-encoded broadcast
+// configs of all sites to BroadcastConfig entities.
+func migrateBroadcastConfigs(store datastore.Store) error {
+	ctx := context.Background()
+
+	sites, err := model.GetAllSites(ctx, store)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, s := range sites {
+		m, err := model.MigrateBroadcastConfigs(ctx, store, s.Skey)
+		if err != nil {
+			return err
+		}
+		if m > 0 {
+			fmt.Printf("%d %s: migrated %d broadcasts\n", s.Skey, s.Name, m)
+		}
+		n += m
+	}
+	fmt.Printf("Migrated %d broadcasts\n", n)
+	return nil
+}
+
+// migrateSiteTimezones sets the IANA timezones of sites without one
+// from their UTC offsets.
+func migrateSiteTimezones(store datastore.Store) error {
+	ctx := context.Background()
+
+	sites, err := model.GetAllSites(ctx, store)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, s := range sites {
+		name, err := model.MigrateSiteTimezone(ctx, store, s.Skey)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		fmt.Printf("%d %s: timezone %s\n", s.Skey, s.Name, name)
+		n++
+	}
+	fmt.Printf("Migrated %d sites\n", n)
+	return nil
+}
+
 // migrateSites migrates sites from kind1, usually Site, to kind2.
 // Notea:
 //   - The migration from SiteV1 to SiteV2 was performed on 31 July 2023.
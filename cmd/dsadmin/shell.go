@@ -0,0 +1,353 @@
+/*
+DESCRIPTION
+  Interactive shell for inspecting and patching individual entities.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// defaultQueryLimit is the default maximum number of entities printed by a query.
+const defaultQueryLimit = 20
+
+const shellHelp = `Commands:
+  get <kind> <key>                      Print an entity.
+  query <kind> [<field> <op> <value>]... [limit <n>]
+                                        Print entities matching filters, e.g.,
+                                        query Device Skey = 3 limit 5
+  set <kind> <key> <field> <value>      Set a single field of an entity, after
+                                        confirmation. Only Device, Site, User,
+                                        Cron, SensorV2, ActuatorV2 and Variable
+                                        entities can be set, excluding their
+                                        key fields.
+  help                                  Print this help.
+  quit                                  Exit the shell.
+Keys that are integers are ID keys, otherwise they are name keys.
+Prefix a key with "name:" to force a name key, e.g., name:123.
+`
+
+// shell runs an interactive shell, reading commands from in and
+// writing results to out, until quit or EOF.
+func shell(store datastore.Store, in io.Reader, out io.Writer) error {
+	ctx := context.Background()
+	sc := bufio.NewScanner(in)
+	fmt.Fprint(out, "Type help for commands.\n> ")
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		cmd, args := cutField(line)
+		var err error
+		switch cmd {
+		case "":
+		case "get":
+			err = shellGet(ctx, store, args, out)
+		case "query":
+			err = shellQuery(ctx, store, args, out)
+		case "set":
+			err = shellSet(ctx, store, args, sc, out)
+		case "help":
+			fmt.Fprint(out, shellHelp)
+		case "quit", "exit":
+			return nil
+		default:
+			err = fmt.Errorf("unknown command %q, type help for commands", cmd)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+		fmt.Fprint(out, "> ")
+	}
+	return sc.Err()
+}
+
+// shellGet implements the get command.
+func shellGet(ctx context.Context, store datastore.Store, args string, out io.Writer) error {
+	kind, args := cutField(args)
+	key, _ := cutField(args)
+	if kind == "" || key == "" {
+		return errors.New("usage: get <kind> <key>")
+	}
+	e, _, err := getEntity(ctx, store, kind, key)
+	if err != nil {
+		return err
+	}
+	return printEntity(out, e)
+}
+
+// shellQuery implements the query command.
+func shellQuery(ctx context.Context, store datastore.Store, args string, out io.Writer) error {
+	kind, args := cutField(args)
+	if kind == "" {
+		return errors.New("usage: query <kind> [<field> <op> <value>]... [limit <n>]")
+	}
+	fields := strings.Fields(args)
+	limit := defaultQueryLimit
+	var filters [][3]string
+	for len(fields) > 0 {
+		if fields[0] == "limit" && len(fields) >= 2 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid limit: %s", fields[1])
+			}
+			limit = n
+			fields = fields[2:]
+			continue
+		}
+		if len(fields) < 3 {
+			return fmt.Errorf("incomplete filter: %s", strings.Join(fields, " "))
+		}
+		filters = append(filters, [3]string{fields[0], fields[1], fields[2]})
+		fields = fields[3:]
+	}
+
+	var keyParts []string
+	for _, f := range filters {
+		keyParts = append(keyParts, f[0])
+	}
+	q := store.NewQuery(kind, true, keyParts...)
+	for _, f := range filters {
+		err := q.FilterField(f[0], f[1], parseValue(f[2]))
+		if err != nil {
+			return fmt.Errorf("invalid filter %s: %w", strings.Join(f[:], " "), err)
+		}
+	}
+	q.Limit(limit)
+	keys, err := store.GetAll(ctx, q, nil)
+	if err != nil {
+		return err
+	}
+	if len(keys) > limit {
+		keys = keys[:limit] // The FileStore ignores limits.
+	}
+	for _, k := range keys {
+		e, err := datastore.NewEntity(kind)
+		if err != nil {
+			return err
+		}
+		err = store.Get(ctx, k, e)
+		if err != nil {
+			return fmt.Errorf("could not get %v: %w", k, err)
+		}
+		fmt.Fprintf(out, "%s:\n", keyString(k))
+		err = printEntity(out, e)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "%d entities\n", len(keys))
+	return nil
+}
+
+// settable describes a kind of entity that can be set by the shell.
+type settable struct {
+	// keyFields are the fields from which the key is derived.
+	keyFields []string
+
+	// put is the model function that puts the entity.
+	put func(ctx context.Context, store datastore.Store, e datastore.Entity) error
+}
+
+// settables are the kinds of entities that can be set by the shell.
+// Entities are put using their model functions, so that derived
+// fields are computed and caches are invalidated as usual.
+var settables = map[string]settable{
+	"Device": {[]string{"Mac"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		return model.PutDevice(ctx, store, e.(*model.Device))
+	}},
+	"Site": {[]string{"Skey"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		return model.PutSite(ctx, store, e.(*model.Site))
+	}},
+	"User": {[]string{"Skey", "Email"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		return model.PutUser(ctx, store, e.(*model.User))
+	}},
+	"Cron": {[]string{"Skey", "ID"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		return model.PutCron(ctx, store, e.(*model.Cron))
+	}},
+	"SensorV2": {[]string{"Mac", "Pin"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		return model.PutSensorV2(ctx, store, e.(*model.SensorV2))
+	}},
+	"ActuatorV2": {[]string{"Mac", "Pin"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		return model.PutActuatorV2(ctx, store, e.(*model.ActuatorV2))
+	}},
+	"Variable": {[]string{"Skey", "Name", "Scope"}, func(ctx context.Context, store datastore.Store, e datastore.Entity) error {
+		v := e.(*model.Variable)
+		return model.PutVariable(ctx, store, v.Skey, v.Name, v.Value)
+	}},
+}
+
+// shellSet implements the set command, prompting for confirmation
+// via sc before putting the updated entity.
+func shellSet(ctx context.Context, store datastore.Store, args string, sc *bufio.Scanner, out io.Writer) error {
+	kind, args := cutField(args)
+	key, args := cutField(args)
+	field, value := cutField(args)
+	if kind == "" || key == "" || field == "" {
+		return errors.New("usage: set <kind> <key> <field> <value>")
+	}
+	s, ok := settables[kind]
+	if !ok {
+		return fmt.Errorf("cannot set %s entities", kind)
+	}
+	if slices.Contains(s.keyFields, field) {
+		return fmt.Errorf("cannot set %s key field %s", kind, field)
+	}
+	e, _, err := getEntity(ctx, store, kind, key)
+	if err != nil {
+		return err
+	}
+
+	// Update the field via the entity's JSON representation, using
+	// the current value to determine the type of the new value.
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("could not encode entity: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]any
+	err = dec.Decode(&m)
+	if err != nil {
+		return fmt.Errorf("could not decode entity: %w", err)
+	}
+	old, ok := m[field]
+	if !ok {
+		return fmt.Errorf("%s has no field %s", kind, field)
+	}
+	switch old.(type) {
+	case string:
+		m[field] = value
+	case json.Number:
+		_, err = strconv.ParseFloat(value, 64)
+		m[field] = json.Number(value)
+	case bool:
+		m[field], err = strconv.ParseBool(value)
+	default:
+		var v any
+		err = json.Unmarshal([]byte(value), &v)
+		m[field] = v
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %s", field, value)
+	}
+	b, err = json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("could not encode entity: %w", err)
+	}
+	updated, err := datastore.NewEntity(kind)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, updated)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", field, err)
+	}
+
+	fmt.Fprintf(out, "%s: %v -> %v\n", field, old, m[field])
+	fmt.Fprint(out, "Put? [y/N] ")
+	if !sc.Scan() || strings.ToLower(strings.TrimSpace(sc.Text())) != "y" {
+		fmt.Fprintln(out, "Not put.")
+		return nil
+	}
+	err = s.put(ctx, store, updated)
+	if err != nil {
+		return fmt.Errorf("could not put entity: %w", err)
+	}
+	fmt.Fprintln(out, "Put.")
+	return nil
+}
+
+// getEntity gets an entity of the given kind by its key.
+func getEntity(ctx context.Context, store datastore.Store, kind, key string) (datastore.Entity, *datastore.Key, error) {
+	e, err := datastore.NewEntity(kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	var k *datastore.Key
+	id, err := strconv.ParseInt(key, 10, 64)
+	if err == nil {
+		k = store.IDKey(kind, id)
+	} else {
+		k = store.NameKey(kind, strings.TrimPrefix(key, "name:"))
+	}
+	err = store.Get(ctx, k, e)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get %s %s: %w", kind, key, err)
+	}
+	return e, k, nil
+}
+
+// printEntity pretty-prints an entity as JSON.
+func printEntity(out io.Writer, e datastore.Entity) error {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode entity: %w", err)
+	}
+	fmt.Fprintf(out, "%s\n", b)
+	return nil
+}
+
+// keyString returns a key as a string, i.e., its ID or name.
+func keyString(k *datastore.Key) string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return strconv.FormatInt(k.ID, 10)
+}
+
+// parseValue parses a filter value as an int, float or bool, falling
+// back to a string. Quoted values are always strings.
+func parseValue(s string) any {
+	if uq, err := strconv.Unquote(s); err == nil {
+		return uq
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+// cutField returns the first whitespace-separated field of s and the
+// remainder of s, with leading whitespace removed.
+func cutField(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
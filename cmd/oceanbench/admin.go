@@ -57,6 +57,7 @@ type adminData struct {
 	Skey      int64
 	Site      *model.Site
 	SiteUsers []model.User
+	Invites   []model.Invite
 	Roles     []role
 	commonData
 }
@@ -67,6 +68,7 @@ type utilsData struct {
 	Sites   []model.Site
 	Devices []model.Device
 	Info    map[string]string
+	Crons   *cronReconcileResult
 	commonData
 }
 
@@ -118,10 +120,20 @@ func adminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Onboarding a new site also requires the user to be a super admin.
+	if r.URL.Path == "/admin/site/onboard" {
+		if !isSuperAdmin(p.Email) {
+			http.Redirect(w, r, "/", http.StatusUnauthorized)
+			return
+		}
+		onboardHandler(w, r, p)
+		return
+	}
+
 	// Require POST method, except for admin landing pages.
 	if r.Method != "POST" {
 		switch r.URL.Path {
-		case "/admin/site", "/admin/broadcast", "/admin/utils":
+		case "/admin/site", "/admin/broadcast", "/admin/utils", "/admin/tokens", "/admin/sensors":
 			// Okay.
 		default:
 			http.Redirect(w, r, "/", http.StatusMethodNotAllowed)
@@ -153,6 +165,15 @@ func adminHandler(w http.ResponseWriter, r *http.Request) {
 	case "/admin/user/delete":
 		err = deleteUser(w, r, p)
 
+	case "/admin/user/invite":
+		err = inviteUser(w, r, p)
+
+	case "/admin/user/reinvite":
+		err = reinviteUser(w, r, p)
+
+	case "/admin/user/uninvite":
+		err = uninviteUser(w, r, p)
+
 	case "/admin/broadcast":
 		broadcastHandler(w, r)
 		return
@@ -161,6 +182,14 @@ func adminHandler(w http.ResponseWriter, r *http.Request) {
 		utilsHandler(w, r, p)
 		return
 
+	case "/admin/tokens":
+		tokensHandler(w, r, p)
+		return
+
+	case "/admin/sensors":
+		sensorsHandler(w, r, p)
+		return
+
 	case "/admin/site":
 		err = nil // Just render the admin page.
 
@@ -247,11 +276,18 @@ func updateSite(w http.ResponseWriter, r *http.Request, p *gauth.Profile) error
 		return errors.New("empty site name")
 	}
 	desc := r.FormValue("sd")
-	org := r.FormValue("org")
+	org := strings.TrimSpace(r.FormValue("org"))
 	tz, err := strconv.ParseFloat(r.FormValue("tz"), 64)
 	if err != nil {
 		return fmt.Errorf("invalid timezone: %w", err)
 	}
+	tzn := r.FormValue("tzn")
+	if tzn != "" {
+		_, err = time.LoadLocation(tzn)
+		if err != nil {
+			return fmt.Errorf("invalid IANA timezone: %w", err)
+		}
+	}
 	ll, err := parseLocation(r.FormValue("ll"))
 	if err != nil {
 		return fmt.Errorf("invalid location: %w", err)
@@ -271,12 +307,19 @@ func updateSite(w http.ResponseWriter, r *http.Request, p *gauth.Profile) error
 	if err != nil {
 		return fmt.Errorf("cannot get site: %w", err)
 	}
+	if org != "" && org != site.OrgID {
+		err = checkSiteOrg(ctx, org, p.Email)
+		if err != nil {
+			return fmt.Errorf("invalid organization %s: %w", org, err)
+		}
+	}
 
 	site.Skey = skey // Immutable!
 	site.Name = name
 	site.Description = desc
 	site.OrgID = org
 	site.Timezone = tz
+	site.TimezoneName = tzn
 	site.Latitude = ll.Lat
 	site.Longitude = ll.Lng
 	site.OpsEmail = ops
@@ -395,6 +438,10 @@ func writeAdmin(w http.ResponseWriter, r *http.Request, p *gauth.Profile, err er
 	if err != nil {
 		log.Printf("GetUsersBySite error: %v", err)
 	}
+	data.Invites, err = model.GetInvitesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		log.Printf("GetInvitesBySite error: %v", err)
+	}
 
 	writeTemplate(w, r, "admin.html", &data, msg)
 }
@@ -455,6 +502,33 @@ func utilsTaskHandler(w http.ResponseWriter, r *http.Request, p *gauth.Profile,
 
 	task := r.FormValue("task")
 
+	// Reconcile Ocean Cron, which does not concern a device.
+	if task == "reconcile" {
+		if !isSuperAdmin(p.Email) {
+			return fmt.Errorf("super admin privilege required to reconcile crons")
+		}
+		res, err := cronScheduler.reconcile(r.FormValue("dryrun") == "true")
+		if err != nil {
+			return fmt.Errorf("cannot reconcile crons: %w", err)
+		}
+		data.Crons = res
+		data.Msg = fmt.Sprintf("reconciled %d crons: %d added, %d removed, %d changed", res.Crons, len(res.Added), len(res.Removed), len(res.Changed))
+		return nil
+	}
+
+	// Geohash devices and sites put before geohashing.
+	if task == "geohash" {
+		if !isSuperAdmin(p.Email) {
+			return fmt.Errorf("super admin privilege required to geohash locations")
+		}
+		n, err := model.PutGeohashes(ctx, settingsStore)
+		if err != nil {
+			return fmt.Errorf("cannot geohash locations: %w", err)
+		}
+		data.Msg = fmt.Sprintf("geohashed %d devices and sites", n)
+		return nil
+	}
+
 	// Get device.
 	ma := r.FormValue("ma")
 	data.Ma = ma
@@ -549,11 +623,16 @@ func isSuperAdmin(email string) bool {
 	return domain == "@localhost" || domain == "@ausocean.org"
 }
 
-// isAdmin returns true if a user has admin privileges for the given site.
+// isAdmin returns true if a user has admin privileges for the given
+// site, either directly or as an admin of the site's organization.
 func isAdmin(ctx context.Context, skey int64, email string) bool {
 	user, err := model.GetUser(ctx, settingsStore, skey, email)
-	if err == nil {
-		return user.Perm&model.AdminPermission != 0
+	if err == nil && user.Perm&model.AdminPermission != 0 {
+		return true
+	}
+	site, err := model.GetSite(ctx, settingsStore, skey)
+	if err != nil || site.OrgID == "" {
+		return false
 	}
-	return false
+	return model.IsOrgAdmin(ctx, settingsStore, site.OrgID, email)
 }
@@ -0,0 +1,212 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// alertsData stores the data served to the alert rules page.
+type alertsData struct {
+	Rules       []model.AlertRule
+	Devices     []model.Device
+	Comparisons []string
+	Preference  *model.RecipientPreference
+	commonData
+}
+
+// alertsHandler handles the alert rules settings page, which lists
+// the site's alert rules. Rules are evaluated by Data Blue as sensor
+// values arrive. POST requests perform the task given by the task
+// param:
+//
+//   - add: add a rule named an for the sensor on device ma and pin pn,
+//     which trips when the value compares with threshold th using
+//     comparison ac for at least ad seconds, notifying recipients ar
+//     in addition to the site's.
+//   - enable: enable rule ai if ae is true, else disable it.
+//   - delete: delete rule ai.
+//   - quiet: set the user's quiet hours, from qs to qe in timezone qz,
+//     during which only notifications of at least severity qm are
+//     delivered, or clear them if qs and qe are empty.
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	skey, _ := profileData(profile)
+	user, err := model.GetUser(ctx, settingsStore, skey, profile.Email)
+	if errors.Is(err, datastore.ErrNoSuchEntity) || (err == nil && user.Perm&model.WritePermission == 0) {
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("failed to get permission for user: %v", err)
+		http.Redirect(w, r, "/", http.StatusInternalServerError)
+		return
+	}
+
+	data := alertsData{
+		Comparisons: model.AlertComparisons,
+		commonData: commonData{
+			Pages:   pages("alerts"),
+			Profile: profile,
+		},
+	}
+
+	var msg string
+	if r.Method == "POST" {
+		err := alertsTaskHandler(r, skey, profile.Email)
+		if err != nil {
+			msg = err.Error()
+		}
+	}
+
+	data.Devices, err = model.GetDevicesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		msg = fmt.Sprintf("cannot get devices: %v", err)
+	}
+	data.Rules, err = model.GetAlertRulesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		msg = fmt.Sprintf("cannot get alert rules: %v", err)
+	}
+	data.Preference, err = model.GetRecipientPreference(ctx, settingsStore, profile.Email)
+	if err != nil {
+		if !errors.Is(err, datastore.ErrNoSuchEntity) {
+			msg = fmt.Sprintf("cannot get quiet hours: %v", err)
+		}
+		data.Preference = &model.RecipientPreference{Email: profile.Email}
+	}
+	writeTemplate(w, r, "set/alerts.html", &data, msg)
+}
+
+// alertsTaskHandler handles an alert rules task for the user with
+// the given email address.
+func alertsTaskHandler(r *http.Request, skey int64, email string) error {
+	ctx := r.Context()
+
+	switch r.FormValue("task") {
+	case "add":
+		name := strings.TrimSpace(r.FormValue("an"))
+		if name == "" {
+			return errors.New("name is required")
+		}
+		dev, err := model.GetDevice(ctx, settingsStore, model.MacEncode(r.FormValue("ma")))
+		if err != nil || dev.Skey != skey {
+			return errors.New("invalid device")
+		}
+		pin := r.FormValue("pn")
+		if !slices.Contains(dev.InputList(), pin) || !strings.ContainsAny(pin[:1], "ADX") {
+			return fmt.Errorf("%s is not an A, D or X input of %s", pin, dev.Name)
+		}
+		th, err := strconv.ParseFloat(r.FormValue("th"), 64)
+		if err != nil {
+			return errors.New("invalid threshold")
+		}
+		var ad int64
+		if v := r.FormValue("ad"); v != "" {
+			ad, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || ad < 0 {
+				return errors.New("invalid duration")
+			}
+		}
+		rule := &model.AlertRule{
+			Skey:       skey,
+			Mac:        dev.Mac,
+			Pin:        pin,
+			Name:       name,
+			Comparison: r.FormValue("ac"),
+			Threshold:  th,
+			Duration:   ad,
+			Recipients: r.FormValue("ar"),
+			Enabled:    true,
+		}
+		err = model.CreateAlertRule(ctx, settingsStore, rule)
+		if err != nil {
+			return fmt.Errorf("cannot add alert rule: %w", err)
+		}
+		return nil
+
+	case "enable", "delete":
+		id, err := strconv.ParseInt(r.FormValue("ai"), 10, 64)
+		if err != nil {
+			return errors.New("invalid alert rule")
+		}
+		rule, err := model.GetAlertRule(ctx, settingsStore, id)
+		if err != nil || rule.Skey != skey {
+			return errors.New("alert rule not found")
+		}
+		if r.FormValue("task") == "delete" {
+			return model.DeleteAlertRule(ctx, settingsStore, id)
+		}
+		rule.Enabled = r.FormValue("ae") == "true"
+		if !rule.Enabled {
+			// Reset the state, so a re-enabled rule starts afresh.
+			rule.Since = 0
+			rule.Tripped = false
+		}
+		return model.PutAlertRule(ctx, settingsStore, rule)
+
+	case "quiet":
+		start, end := r.FormValue("qs"), r.FormValue("qe")
+		if start == "" && end == "" {
+			return model.DeleteRecipientPreference(ctx, settingsStore, email)
+		}
+		for _, hm := range []string{start, end} {
+			_, err := time.Parse("15:04", hm)
+			if err != nil {
+				return fmt.Errorf("invalid time %q, expected HH:MM", hm)
+			}
+		}
+		tz := r.FormValue("qz")
+		_, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q", tz)
+		}
+		sev, err := strconv.ParseInt(r.FormValue("qm"), 10, 64)
+		if err != nil || sev < 0 {
+			return errors.New("invalid severity")
+		}
+		p := &model.RecipientPreference{Email: email, Timezone: tz, QuietStart: start, QuietEnd: end, QuietMinSeverity: sev}
+		return model.PutRecipientPreference(ctx, settingsStore, p)
+
+	default:
+		return errors.New("invalid task")
+	}
+}
@@ -0,0 +1,242 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/cmd/oceantv/openfish"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// ofsvc is the OpenFish service to which annotations are pushed, or
+// nil if OpenFish is not configured.
+var ofsvc *openfish.OpenfishService
+
+// annotationsHandler handles media annotation requests, depending on
+// the method:
+//
+//	GET:    list the annotations for media id, optionally overlapping
+//	        ts, as JSON, or as CSV if format is csv.
+//	POST:   create an annotation for media id from Unix time st to ft
+//	        with label lb and note nt, returning it as JSON. If vs is
+//	        an OpenFish video stream ID, the annotation is also pushed
+//	        to OpenFish.
+//	PUT:    update the label lb and note nt of annotation ai.
+//	DELETE: delete annotation ai.
+//
+// Reading requires read permission for the media and other methods
+// require write permission.
+func annotationsHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+	profile, _ := getProfile(w, r)
+
+	// Determine the media ID, which for existing annotations is part of the key.
+	var a *model.Annotation
+	var mid int64
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		mid, err = strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			writeHttpError(w, http.StatusBadRequest, errInvalidMID.Error())
+			return
+		}
+	case http.MethodPut, http.MethodDelete:
+		id, err := strconv.ParseInt(r.FormValue("ai"), 10, 64)
+		if err != nil {
+			writeHttpError(w, http.StatusBadRequest, "invalid annotation")
+			return
+		}
+		a, err = model.GetAnnotation(ctx, mediaStore, id)
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			writeHttpError(w, http.StatusNotFound, "annotation not found")
+			return
+		} else if err != nil {
+			writeHttpError(w, http.StatusInternalServerError, "could not get annotation: %v", err)
+			return
+		}
+		mid = a.MID
+	default:
+		writeHttpError(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	perm := int64(model.WritePermission)
+	if r.Method == http.MethodGet {
+		perm = model.ReadPermission
+	}
+	ok, err := hasPermission(ctx, profile, mid, perm)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not check permission: %v", err)
+		return
+	}
+	if !ok {
+		writeHttpError(w, http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var ts []int64
+		if v := r.FormValue("ts"); v != "" {
+			ts, err = splitTimestamps(v, true)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, errInvalidTimestamp.Error())
+				return
+			}
+		}
+		as, err := model.GetAnnotations(ctx, mediaStore, mid, ts)
+		if err != nil {
+			writeHttpError(w, http.StatusInternalServerError, "could not get annotations: %v", err)
+			return
+		}
+		if r.FormValue("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"annotations-%d.csv\"", mid))
+			err = writeAnnotationsCSV(w, as)
+			if err != nil {
+				log.Printf("could not write annotations CSV: %v", err)
+			}
+			return
+		}
+		writeAnnotationsJSON(w, as)
+
+	case http.MethodPost:
+		a = &model.Annotation{MID: mid, Label: strings.TrimSpace(r.FormValue("lb")), Note: r.FormValue("nt")}
+		if profile != nil {
+			a.Author = profile.Email
+		}
+		a.Timestamp, err = strconv.ParseInt(r.FormValue("st"), 10, 64)
+		if err != nil {
+			writeHttpError(w, http.StatusBadRequest, errInvalidTimestamp.Error())
+			return
+		}
+		a.End = a.Timestamp
+		if v := r.FormValue("ft"); v != "" {
+			a.End, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, errInvalidTimestamp.Error())
+				return
+			}
+		}
+		err = model.CreateAnnotation(ctx, mediaStore, a)
+		if errors.Is(err, model.ErrInvalidAnnotation) {
+			writeHttpError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if err != nil {
+			writeHttpError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if v := r.FormValue("vs"); v != "" {
+			err = pushAnnotation(a, v)
+			if err != nil {
+				log.Printf("could not push annotation %d to OpenFish: %v", a.ID, err)
+			}
+		}
+		writeAnnotationsJSON(w, a)
+
+	case http.MethodPut:
+		a.Label = strings.TrimSpace(r.FormValue("lb"))
+		a.Note = r.FormValue("nt")
+		err = model.PutAnnotation(ctx, mediaStore, a)
+		if errors.Is(err, model.ErrInvalidAnnotation) {
+			writeHttpError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if err != nil {
+			writeHttpError(w, http.StatusInternalServerError, "could not update annotation: %v", err)
+			return
+		}
+		writeAnnotationsJSON(w, a)
+
+	case http.MethodDelete:
+		err = model.DeleteAnnotation(ctx, mediaStore, a.ID)
+		if err != nil {
+			writeHttpError(w, http.StatusInternalServerError, "could not delete annotation: %v", err)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}
+}
+
+// pushAnnotation pushes an annotation to the OpenFish video stream
+// with the given ID, then records the OpenFish annotation ID.
+func pushAnnotation(a *model.Annotation, videostream string) error {
+	if ofsvc == nil {
+		return errors.New("OpenFish not configured")
+	}
+	vs, err := strconv.ParseInt(videostream, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid video stream ID: %s", videostream)
+	}
+	obs := map[string]string{"label": a.Label}
+	if a.Note != "" {
+		obs["note"] = a.Note
+	}
+	a.OpenFishID, err = ofsvc.CreateAnnotation(vs, time.Unix(a.Timestamp, 0), time.Unix(a.End, 0), obs)
+	if err != nil {
+		return err
+	}
+	return model.PutAnnotation(context.Background(), mediaStore, a)
+}
+
+// writeAnnotationsJSON writes v as a JSON response.
+func writeAnnotationsJSON(w http.ResponseWriter, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not marshal annotations: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// writeAnnotationsCSV writes annotations as CSV, with a header row.
+// Times are in RFC 3339 format, in UTC.
+func writeAnnotationsCSV(w io.Writer, as []model.Annotation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "mid", "start", "end", "author", "label", "note"})
+	for _, a := range as {
+		cw.Write([]string{
+			strconv.FormatInt(a.ID, 10),
+			strconv.FormatInt(a.MID, 10),
+			time.Unix(a.Timestamp, 0).UTC().Format(time.RFC3339),
+			time.Unix(a.End, 0).UTC().Format(time.RFC3339),
+			a.Author,
+			a.Label,
+			a.Note,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
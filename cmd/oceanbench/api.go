@@ -28,6 +28,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -35,6 +36,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
@@ -87,8 +89,7 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 				writeHttpError(w, http.StatusInternalServerError, "could not get site with site key: %v: %v", strconv.Itoa(int(skey)), err)
 				return
 			}
-			enc := site.Encode()
-			fmt.Fprint(w, string(enc))
+			writeETagged(w, r, site.Encode())
 			return
 
 		case "devices":
@@ -124,7 +125,7 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 					writeHttpError(w, http.StatusInternalServerError, "unable to marshal devs into json: %v", err)
 					return
 				}
-				w.Write(data)
+				writeETagged(w, r, data)
 				return
 			}
 
@@ -167,13 +168,297 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 					writeHttpError(w, http.StatusInternalServerError, "unable to marshal user sites")
 					return
 				}
-				w.Write(b)
+				writeETagged(w, r, b)
 				return
 			}
 			output := "{" + strings.Join(s, ",") + "}"
 			fmt.Fprint(w, output)
 			return
 
+		case "notifications":
+			// The value is the site key, and filters are optional params.
+			skey, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "could not parse site key from url: %v", err)
+				return
+			}
+			user, err := model.GetUser(ctx, settingsStore, skey, p.Email)
+			if err != nil {
+				writeHttpError(w, http.StatusUnauthorized, "unable to get user: %v", err)
+				return
+			}
+			if user.Perm&model.ReadPermission == 0 {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have read permissions")
+				return
+			}
+			f, err := notificationFilter(r)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "invalid notification filter: %v", err)
+				return
+			}
+			ns, err := model.GetNotifications(ctx, settingsStore, skey, f)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to get notifications: %v", err)
+				return
+			}
+			data, err := json.Marshal(ns)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal notifications: %v", err)
+				return
+			}
+			w.Write(data)
+			return
+
+		case "overview":
+			switch val {
+			case "sites":
+				overviews, err := siteOverviews(ctx, p.Email)
+				if err != nil {
+					writeHttpError(w, http.StatusInternalServerError, "unable to get site overviews: %v", err)
+					return
+				}
+				data, err := json.Marshal(overviews)
+				if err != nil {
+					writeHttpError(w, http.StatusInternalServerError, "unable to marshal site overviews: %v", err)
+					return
+				}
+				w.Write(data)
+				return
+			}
+
+		case "map":
+			switch val {
+			case "sites":
+				box, err := parseBox(r.FormValue("box"))
+				if err != nil {
+					writeHttpError(w, http.StatusBadRequest, "%v", err)
+					return
+				}
+				md, err := mapSites(ctx, settingsStore, p.Email, box)
+				if err != nil {
+					writeHttpError(w, http.StatusInternalServerError, "unable to get map sites: %v", err)
+					return
+				}
+				data, err := json.Marshal(md)
+				if err != nil {
+					writeHttpError(w, http.StatusInternalServerError, "unable to marshal map sites: %v", err)
+					return
+				}
+				w.Write(data)
+				return
+			}
+
+		case "usage":
+			// The value is a site key, or "sites" for all of the user's sites.
+			from, to, err := usageDates(r)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "%v", err)
+				return
+			}
+			var usage any
+			if val == "sites" {
+				usage, err = sitesUsage(ctx, p.Email, from, to)
+			} else {
+				var skey int64
+				skey, err = strconv.ParseInt(val, 10, 64)
+				if err != nil {
+					writeHttpError(w, http.StatusBadRequest, "could not parse site key from url: %v", err)
+					return
+				}
+				var user *model.User
+				user, err = model.GetUser(ctx, settingsStore, skey, p.Email)
+				if err != nil {
+					writeHttpError(w, http.StatusUnauthorized, "unable to get user: %v", err)
+					return
+				}
+				if user.Perm&model.ReadPermission == 0 {
+					writeHttpError(w, http.StatusUnauthorized, "profile does not have read permissions")
+					return
+				}
+				usage, err = getSiteUsage(ctx, skey, from, to)
+			}
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to get data usage: %v", err)
+				return
+			}
+			data, err := json.Marshal(usage)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal data usage: %v", err)
+				return
+			}
+			w.Write(data)
+			return
+
+		case "broadcasts":
+			// The value is the site key. Archives of broadcasts that
+			// went live between the optional from and to dates are
+			// returned, as for usage.
+			skey, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "could not parse site key from url: %v", err)
+				return
+			}
+			user, err := model.GetUser(ctx, settingsStore, skey, p.Email)
+			if err != nil {
+				writeHttpError(w, http.StatusUnauthorized, "unable to get user: %v", err)
+				return
+			}
+			if user.Perm&model.ReadPermission == 0 {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have read permissions")
+				return
+			}
+			from, to, err := usageDates(r)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "%v", err)
+				return
+			}
+			since, _ := time.Parse(model.DataUsageDateFormat, from)
+			until, _ := time.Parse(model.DataUsageDateFormat, to)
+			as, err := model.GetBroadcastArchives(ctx, settingsStore, skey, since, until.AddDate(0, 0, 1))
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to get broadcast archives: %v", err)
+				return
+			}
+			data, err := json.Marshal(as)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal broadcast archives: %v", err)
+				return
+			}
+			w.Write(data)
+			return
+
+		case "viewers":
+			// The value is the site key, and the id parameter is the
+			// video ID of one of the site's broadcasts, whose viewer
+			// samples are returned.
+			skey, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "could not parse site key from url: %v", err)
+				return
+			}
+			user, err := model.GetUser(ctx, settingsStore, skey, p.Email)
+			if err != nil {
+				writeHttpError(w, http.StatusUnauthorized, "unable to get user: %v", err)
+				return
+			}
+			if user.Perm&model.ReadPermission == 0 {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have read permissions")
+				return
+			}
+			id := r.FormValue("id")
+			if id == "" {
+				writeHttpError(w, http.StatusBadRequest, "missing video ID")
+				return
+			}
+			vs, err := model.GetViewerSamples(ctx, settingsStore, id)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to get viewer samples: %v", err)
+				return
+			}
+			samples := []model.ViewerSample{}
+			for _, v := range vs {
+				if v.Skey == skey {
+					samples = append(samples, v)
+				}
+			}
+			data, err := json.Marshal(samples)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal viewer samples: %v", err)
+				return
+			}
+			w.Write(data)
+			return
+
+		case "logins":
+			// The value is the user's email address, or "all" for all
+			// users. Users may only get their own login events unless
+			// they are a super admin. Dates are as for usage.
+			email := val
+			if email == "all" {
+				email = ""
+			}
+			if email != p.Email && !isSuperAdmin(p.Email) {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have permission to get login events for %s", val)
+				return
+			}
+			from, to, err := usageDates(r)
+			if err != nil {
+				writeHttpError(w, http.StatusBadRequest, "%v", err)
+				return
+			}
+			since, _ := time.Parse(model.DataUsageDateFormat, from)
+			until, _ := time.Parse(model.DataUsageDateFormat, to)
+			es, err := model.GetLoginEvents(ctx, settingsStore, email, since, until.AddDate(0, 0, 1))
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to get login events: %v", err)
+				return
+			}
+			data, err := json.Marshal(es)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal login events: %v", err)
+				return
+			}
+			w.Write(data)
+			return
+
+		case "org":
+			// The value is the organization ID.
+			data, err := getOrgData(ctx, val, p.Email)
+			switch {
+			case errors.Is(err, errNotOrgMember):
+				writeHttpError(w, http.StatusUnauthorized, "profile is not a member of organization %s", val)
+				return
+			case err != nil:
+				writeHttpError(w, http.StatusInternalServerError, "unable to get organization: %v", err)
+				return
+			}
+			b, err := json.Marshal(data)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal organization: %v", err)
+				return
+			}
+			writeETagged(w, r, b)
+			return
+
+		case "textretention":
+			// Get all text retentions, i.e., /api/get/textretention/all.
+			if val != "all" {
+				break
+			}
+			if !isSuperAdmin(p.Email) {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have permission to get text retention")
+				return
+			}
+			trs, err := model.GetTextRetentions(ctx, settingsStore)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to get text retentions: %v", err)
+				return
+			}
+			b, err := json.Marshal(trs)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal text retentions: %v", err)
+				return
+			}
+			w.Write(b)
+			return
+
+		case "orgs":
+			switch val {
+			case "user":
+				ms, err := model.GetMemberships(ctx, settingsStore, p.Email)
+				if err != nil {
+					writeHttpError(w, http.StatusInternalServerError, "unable to get memberships: %v", err)
+					return
+				}
+				b, err := json.Marshal(ms)
+				if err != nil {
+					writeHttpError(w, http.StatusInternalServerError, "unable to marshal memberships: %v", err)
+					return
+				}
+				writeETagged(w, r, b)
+				return
+			}
+
 		case "profile":
 			switch val {
 			case "data":
@@ -231,7 +516,7 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 					writeHttpError(w, http.StatusInternalServerError, "unable to marshal variables: %v", err)
 					return
 				}
-				w.Write(data)
+				writeETagged(w, r, data)
 				return
 			}
 		}
@@ -264,6 +549,101 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			fmt.Fprint(w, "OK")
 			return
+
+		case "orgmember":
+			// Set an organization member's role, i.e., /api/set/orgmember/<orgid>:<email>:<member|admin|none>.
+			parts := strings.Split(val, ":")
+			if len(parts) != 3 {
+				writeHttpError(w, http.StatusBadRequest, "invalid member, wanted: <orgid>:<email>:<member|admin|none>")
+				return
+			}
+			err := setOrgMember(ctx, parts[0], parts[1], parts[2], p.Email)
+			switch {
+			case errors.Is(err, errNotOrgMember):
+				writeHttpError(w, http.StatusUnauthorized, "profile is not an admin of organization %s", parts[0])
+				return
+			case errors.Is(err, model.ErrInvalidOrgRole):
+				writeHttpError(w, http.StatusBadRequest, "%v", err)
+				return
+			case err != nil:
+				writeHttpError(w, http.StatusInternalServerError, "could not set member: %v", err)
+				return
+			}
+			fmt.Fprint(w, "OK")
+			return
+
+		case "textretention":
+			// Set the retention of a text type in days, or "none" to
+			// remove it, i.e., /api/set/textretention/<days|none>?type=<type>.
+			// The type "*" is the default for types without their own retention.
+			if !isSuperAdmin(p.Email) {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have permission to set text retention")
+				return
+			}
+			typ := r.FormValue("type")
+			if typ == "" {
+				writeHttpError(w, http.StatusBadRequest, "missing text type")
+				return
+			}
+			if val == "none" {
+				err = model.DeleteTextRetention(ctx, settingsStore, typ)
+			} else {
+				var days int64
+				days, err = strconv.ParseInt(val, 10, 64)
+				if err != nil {
+					writeHttpError(w, http.StatusBadRequest, "invalid retention days: %v", err)
+					return
+				}
+				err = model.PutTextRetention(ctx, settingsStore, &model.TextRetention{Type: typ, Days: days})
+			}
+			switch {
+			case errors.Is(err, model.ErrInvalidRetention):
+				writeHttpError(w, http.StatusBadRequest, "%v", err)
+				return
+			case err != nil:
+				writeHttpError(w, http.StatusInternalServerError, "could not set text retention: %v", err)
+				return
+			}
+			fmt.Fprint(w, "OK")
+			return
+
+		case "dkey":
+			// Rotate a device key, i.e., /api/set/dkey/<mac>:<rotate|finalize|cancel>.
+			// Rotations are finalized before the device uses its new key only with force=true.
+			ma, action, ok := strings.Cut(val, ":")
+			tasks := map[string]string{"rotate": "Rotate Key", "finalize": "Finalize Key", "cancel": "Cancel Rotation"}
+			if !ok || tasks[action] == "" {
+				writeHttpError(w, http.StatusBadRequest, "invalid key rotation, wanted: <mac>:<rotate|finalize|cancel>")
+				return
+			}
+			mac := model.MacEncode(ma)
+			dev, err := model.GetDevice(ctx, settingsStore, mac)
+			if err != nil {
+				writeHttpError(w, http.StatusNotFound, "could not get device: %v", err)
+				return
+			}
+			user, err := model.GetUser(ctx, settingsStore, dev.Skey, p.Email)
+			if err != nil || user.Perm&model.WritePermission == 0 {
+				writeHttpError(w, http.StatusUnauthorized, "profile does not have write permissions")
+				return
+			}
+			dev, err = rotateDeviceKey(ctx, mac, tasks[action], r.FormValue("force") == "true")
+			switch {
+			case errors.Is(err, model.ErrNoKeyRotation), errors.Is(err, model.ErrDeviceKeyNotInUse):
+				writeHttpError(w, http.StatusConflict, "could not %s device key: %v", action, err)
+				return
+			case err != nil:
+				writeHttpError(w, http.StatusInternalServerError, "could not %s device key: %v", action, err)
+				return
+			}
+			data, err := json.Marshal(dev)
+			if err != nil {
+				writeHttpError(w, http.StatusInternalServerError, "unable to marshal device into json: %v", err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+			return
 		}
 
 	case "test":
@@ -350,7 +730,46 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeHttpError(w, http.StatusBadRequest, "invalid url path, expected /get{/site, /sites}, /set/site, /test{/upload, /download}, or /health/site, got: /%v/%v", req[2], req[3])
+	writeHttpError(w, http.StatusBadRequest, "invalid url path, expected /get{/site, /sites, /notifications}, /set/site, /test{/upload, /download}, or /health/site, got: /%v/%v", req[2], req[3])
+}
+
+// notificationFilter returns the notification filter for a
+// notifications request, which takes the following optional params:
+//
+//	kind:  notification kind
+//	ch:    delivery channel, e.g., email or slack
+//	st:    delivery status, i.e., sent, failed or queued
+//	since: start time, in Unix seconds
+//	until: end time, in Unix seconds
+//	n:     maximum number of notifications
+func notificationFilter(r *http.Request) (*model.NotificationFilter, error) {
+	f := &model.NotificationFilter{
+		Kind:    r.FormValue("kind"),
+		Channel: r.FormValue("ch"),
+		Status:  r.FormValue("st"),
+	}
+	for _, t := range []struct {
+		param string
+		dst   *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		v := r.FormValue(t.param)
+		if v == "" {
+			continue
+		}
+		secs, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s param: %s", t.param, v)
+		}
+		*t.dst = time.Unix(secs, 0)
+	}
+	if v := r.FormValue("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid n param: %s", v)
+		}
+		f.Limit = n
+	}
+	return f, nil
 }
 
 // splitNumbers splits a comma-separated string of numbers, ignoring the decimal part.
@@ -26,9 +26,13 @@ LICENSE
 package main
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/gauth"
@@ -78,12 +82,151 @@ func oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getProfile returns the profile for the logged-in user.
+// errReadOnly is returned by getProfile for requests that would
+// modify state while impersonating a user.
+var errReadOnly = errors.New("impersonated sessions are read-only")
+
+// getProfile returns the profile for the logged-in user. When an admin
+// is impersonating a user, the user's profile is returned, but only
+// for read-only requests.
 func getProfile(w http.ResponseWriter, r *http.Request) (*gauth.Profile, error) {
 	if standalone {
 		return &gauth.Profile{Email: localEmail, Data: standaloneData}, nil
 	}
-	return auth.GetProfile(backend.NewNetHandler(w, r, auth.NetStore))
+	p, err := auth.GetProfile(backend.NewNetHandler(w, r, auth.NetStore))
+	if err != nil {
+		return nil, err
+	}
+	err = checkImpersonation(p, r)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkImpersonation returns errReadOnly if p is an impersonated
+// profile and r is not a read-only request, or nil otherwise.
+func checkImpersonation(p *gauth.Profile, r *http.Request) error {
+	if p.Impersonator != "" && !readOnlyRequest(r) {
+		log.Printf("%s denied %s %s while impersonating %s", p.Impersonator, r.Method, r.URL.Path, p.Email)
+		return errReadOnly
+	}
+	return nil
+}
+
+// readOnlyPaths are the paths of pages and APIs which do not modify
+// state when requested with GET or HEAD, and are therefore available
+// while impersonating. Paths match exactly. Endpoints not listed here,
+// or in readOnlyPrefixes, are denied, so that new endpoints are
+// read-only until they are reviewed. Device and cron edit endpoints
+// are not listed, since they modify state on GET requests.
+var readOnlyPaths = []string{
+	"/",
+	"/favicon.ico",
+	"/sw.js",
+	"/search",
+	"/play",
+	"/learn/mooring",
+	"/set/devices/",
+	"/set/crons/",
+	"/set/alerts",
+	"/set/forwarding",
+	"/get",
+	"/monitor",
+	"/overview",
+	"/map",
+	"/usage",
+	"/track",
+	"/spectrogram",
+	"/annotations",
+	"/admin/site",
+	"/admin/broadcast",
+	"/admin/utils",
+	"/admin/tokens",
+	"/admin/sensors",
+}
+
+// readOnlyPrefixes are the path prefixes of pages and APIs which do
+// not modify state when requested with GET or HEAD.
+var readOnlyPrefixes = []string{
+	"/s/",
+	"/api/get/",
+	"/api/scalar/get/",
+	"/live/",
+	"/portal/",
+	"/data/",
+}
+
+// readOnlyRequest returns true if a request does not modify state,
+// i.e., is a GET or HEAD request without a task to one of
+// readOnlyPaths or readOnlyPrefixes, or is a request to select a site.
+func readOnlyRequest(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/set/site/") {
+		return true
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.URL.Query().Get("task") != "" {
+		return false
+	}
+	if slices.Contains(readOnlyPaths, r.URL.Path) {
+		return true
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// impersonateHandler handles requests by super admins to view Ocean
+// Bench as another user, e.g., to reproduce permission issues, for up
+// to the given number of minutes. The session is read-only until the
+// impersonation is stopped or expires.
+func impersonateHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	if standalone || r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusMethodNotAllowed)
+		return
+	}
+	p, err := getProfile(w, r)
+	if err != nil || !isSuperAdmin(p.Email) {
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+	mins, err := strconv.Atoi(r.FormValue("mins"))
+	if err != nil {
+		mins = int(gauth.MaxImpersonation / time.Minute)
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		writeHttpError(w, http.StatusBadRequest, "a reason is required to impersonate a user")
+		return
+	}
+	err = auth.Impersonate(backend.NewNetHandler(w, r, auth.NetStore), r.FormValue("email"), reason, time.Duration(mins)*time.Minute)
+	if err != nil {
+		writeHttpError(w, http.StatusBadRequest, "could not impersonate user: %v", err)
+		return
+	}
+	putProfileData(w, r, "") // Deselect the site, which the user may not have access to.
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// stopImpersonatingHandler handles requests to stop impersonating a user.
+func stopImpersonatingHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	if standalone {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	err := auth.StopImpersonating(backend.NewNetHandler(w, r, auth.NetStore))
+	if err != nil && !errors.Is(err, gauth.ErrNotImpersonating) {
+		writeHttpError(w, http.StatusInternalServerError, "could not stop impersonating: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/admin/utils", http.StatusFound)
 }
 
 // putProfileData puts profile data.
@@ -0,0 +1,83 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ausocean/cloud/gauth"
+)
+
+func TestReadOnlyRequest(t *testing.T) {
+	tests := []struct {
+		method, url string
+		want        bool
+	}{
+		{method: "GET", url: "/", want: true},
+		{method: "GET", url: "/api/get/map/sites", want: true},
+		{method: "GET", url: "/api/set/site/1:Rapid%20Bay", want: true},
+		{method: "GET", url: "/set/devices/?ma=0A:00:00:00:00:01", want: true},
+		{method: "GET", url: "/set/devices/?ma=0A:00:00:00:00:01&task=delete"},
+		{method: "GET", url: "/api/set/orgmember/org:a@b.org:admin"},
+		{method: "GET", url: "/purge/text"},
+		{method: "POST", url: "/admin/site/update"},
+		{method: "GET", url: "/admin/site/delete"},
+		{method: "GET", url: "/api/test/upload"},
+		{method: "GET", url: "/backup"},
+		{method: "GET", url: "/unreviewed"},
+		{method: "GET", url: "/set/devices/edit?ma=0A:00:00:00:00:01"},
+		{method: "GET", url: "/set/devices/configure?ma=0A:00:00:00:00:01"},
+		{method: "GET", url: "/set/devices/other"},
+		{method: "GET", url: "/set/crons/edit"},
+	}
+
+	for _, test := range tests {
+		got := readOnlyRequest(httptest.NewRequest(test.method, test.url, nil))
+		if got != test.want {
+			t.Errorf("readOnlyRequest(%s %s) returned %t, expected %t", test.method, test.url, got, test.want)
+		}
+	}
+}
+
+func TestCheckImpersonation(t *testing.T) {
+	p := &gauth.Profile{Email: "user@example.org", Impersonator: "admin@ausocean.org"}
+	for _, url := range []string{
+		"/set/devices/edit/var?ma=0A:00:00:00:00:01&vn=x&vd=true",
+		"/set/devices/edit/var?ma=0A:00:00:00:00:01&vn=x&vv=1",
+	} {
+		err := checkImpersonation(p, httptest.NewRequest("GET", url, nil))
+		if !errors.Is(err, errReadOnly) {
+			t.Errorf("checkImpersonation(GET %s) returned %v, expected %v", url, err, errReadOnly)
+		}
+	}
+
+	err := checkImpersonation(p, httptest.NewRequest("GET", "/set/devices/?ma=0A:00:00:00:00:01", nil))
+	if err != nil {
+		t.Errorf("checkImpersonation of device page returned %v", err)
+	}
+	p.Impersonator = ""
+	err = checkImpersonation(p, httptest.NewRequest("GET", "/set/devices/edit/var?ma=0A:00:00:00:00:01&vn=x&vd=true", nil))
+	if err != nil {
+		t.Errorf("checkImpersonation without impersonation returned %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/cmd/dsadmin/backup"
+	"github.com/ausocean/cloud/gauth"
+)
+
+// backupKinds are the kinds that may be backed up by backupHandler,
+// all of which are site-scoped, i.e., have an Skey property.
+var backupKinds = map[string]bool{
+	"Site":     true,
+	"Device":   true,
+	"Variable": true,
+	"Cron":     true,
+	"User":     true,
+}
+
+// backupBucket is the GCS bucket that site backups are written to.
+var backupBucket string
+
+// backupRequest is the body of a backup request.
+type backupRequest struct {
+	Kinds     []string `json:"kinds"`     // Kinds to back up, which must be in backupKinds.
+	Retention int      `json:"retention"` // Retention period in days, or zero to retain all backups.
+}
+
+// backupHandler backs up the datastore entities of a single site to
+// backupBucket, as per dsadmin --task backup. It is designed to be
+// invoked via OceanCron rpc requests, which are signed with the cron
+// secret and specify the site key, with a JSON body such as:
+//
+//	{"kinds":["Site","Device"],"retention":30}
+//
+// Backups are written under netreceiver/<skey> and only kinds in
+// backupKinds may be backed up. Other kinds, e.g., MtsMedia, should be
+// backed up with dsadmin instead.
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	if err != nil {
+		writeHttpError(w, http.StatusUnauthorized, fmt.Sprintf("invalid claims: %v", err))
+		return
+	}
+	if claims["iss"] != cronServiceAccount {
+		writeHttpError(w, http.StatusUnauthorized, fmt.Sprintf("invalid issuer: %v", claims["iss"]))
+		return
+	}
+	sk, ok := claims["skey"].(float64)
+	if !ok || sk == 0 {
+		writeHttpError(w, http.StatusBadRequest, fmt.Sprintf("invalid skey: %v", claims["skey"]))
+		return
+	}
+	skey := int64(sk)
+
+	var req backupRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeHttpError(w, http.StatusBadRequest, fmt.Sprintf("%v: %v", errInvalidJSON, err))
+		return
+	}
+	for _, kind := range req.Kinds {
+		if !backupKinds[kind] {
+			writeHttpError(w, http.StatusBadRequest, "invalid kind: "+kind)
+			return
+		}
+	}
+	if backupBucket == "" {
+		writeHttpError(w, http.StatusInternalServerError, "no backup bucket configured")
+		return
+	}
+
+	setup(ctx)
+	cfg := backup.Config{
+		Bucket:    backupBucket,
+		Prefix:    "netreceiver/" + strconv.FormatInt(skey, 10),
+		Kinds:     req.Kinds,
+		Retention: req.Retention,
+		Skey:      skey,
+	}
+	bkt, err := backup.NewGCSBucket(ctx, cfg.Bucket)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res, err := backup.Run(ctx, settingsStore, bkt, cfg, time.Now())
+	if err != nil {
+		log.Printf("backup of site %d to %s failed: %v", skey, cfg.Bucket, err)
+		writeHttpError(w, http.StatusInternalServerError, fmt.Sprintf("backup failed: %v", err))
+		return
+	}
+	log.Printf("backed up %v to gs://%s/%s/%s, pruned %v", res.Counts, cfg.Bucket, cfg.Prefix, res.Time, res.Pruned)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
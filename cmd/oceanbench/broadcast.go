@@ -33,12 +33,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/cmd/oceantv/broadcast"
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
@@ -104,8 +107,9 @@ type broadcastRequest struct {
 
 // Settings contains constant values to be used to populate the form with limited options.
 type Settings struct {
-	Resolution []string
-	Privacy    []string
+	Resolution              []string
+	Privacy                 []string
+	VoltageRecoveryStrategy []string
 }
 
 // BroadcastConfig holds configuration data for a YouTube broadcast.
@@ -124,10 +128,13 @@ type BroadcastConfig struct {
 	EndTimestamp             string        // End time of the broadcast in unix format.
 	End                      time.Time     // End time in native go format for easy operations.
 	VidforwardHost           string        // Host address of vidforward service.
+	HLSOutput                string        // Playlist vidforward also writes HLS to, i.e., a gs:// URL or a path on vidforward, e.g., "rapidbay/index.m3u8", or empty for RTMP only.
+	HLSFeed                  int64         // ID of the AusOcean TV feed played from the HLS output, if any.
 	CameraMac                int64         // Camera hardware's MAC address.
 	ControllerMAC            int64         // Controller hardware's MAC adress (controller used to power camera).
 	OnActions                string        // A series of actions to be used for power up of camera hardware.
 	OffActions               string        // A series of actions to be used for power down of camera hardware.
+	Controllers              []Controller  // Additional controllers, e.g., powering lights separately to the camera.
 	RTMPVar                  string        // The variable name that holds the RTMP URL and key.
 	Active                   bool          // This is true if the broadcast is currently active i.e. waiting for data or currently streaming.
 	Slate                    bool          // This is true if the broadcast is currently in slate mode i.e. no camera.
@@ -151,8 +158,31 @@ type BroadcastConfig struct {
 	RecoveringVoltage        bool          // True if the broadcast is currently recovering voltage.
 	RequiredStreamingVoltage float64       // The required battery voltage for the camera to stream.
 	VoltageRecoveryTimeout   int           // Max allowable hours for voltage recovery before failure.
+	VoltageRecoveryStrategy  string        // The voltage recovery strategy, i.e. linear (default), solar or extrapolate.
 	RegisterOpenFish         bool          // True if the video should be registered with openfish for annotation.
-	OpenFishCaptureSource    string        // The capture source to register the stream to.
+	OpenFishCaptureSource    string        // The capture source to register the stream to, created if empty.
+	OpenFishAnnotators       string        // Comma-separated emails of users to annotate the stream in openfish.
+	NotifyRecipients         string        // Comma-separated emails notified in addition to the site's recipients.
+	NotifyRoutes             string        // Channel overrides, e.g., "sms:+61400000000,+61411111111;slack".
+	MinStatusInterval        int           // Min minutes between status checks, used when unhealthy or just started (default 1).
+	MaxStatusInterval        int           // Max minutes between status checks, backed off to while healthy (default 10).
+	SlateSchedule            string        // Daily slate windows for permanent broadcasts in local time, e.g., "20:00-06:00=back-at-dawn.mp4".
+	GenerateSlate            bool          // True if slates showing the site name and resume time are generated for permanent broadcasts.
+	SlateImageURL            string        // URL of the base image of generated slates, if any, otherwise a plain background is used.
+	ReadinessLeadTime        int           // Minutes before the start at which readiness is checked, or zero to not check.
+	ReadinessChecked         time.Time     // The start time for which readiness was last checked.
+	TitleTemplate            string        // Template for refreshed titles, e.g., "{{.Site}} Live {{.Date}}", or empty to use the name and date.
+	ThumbnailURL             string        // URL of an image set as the thumbnail when metadata is refreshed, if any.
+	MetadataRefreshTime      string        // Daily local time at which metadata is refreshed, e.g., "06:00", or empty to not refresh.
+	MetadataRefreshed        time.Time     // The time metadata was last refreshed.
+	MetadataErr              string        // The metadata refresh error last notified, if any.
+	SendConditions           bool          // True if tide and weather conditions are added to the description and chat messages.
+	HealthIncidents          int           // The number of health incidents during the current broadcast.
+	PowerOffDelay            int           // Minutes after the end for which hardware stays on and the stream continues, or zero to power off at the end.
+	Draining                 bool          // True if the broadcast has finished and is waiting for its power off delay before powering off.
+	OverrunTolerance         int           // Minutes past the end, after any power off delay, before an active broadcast is forcibly finished (default 30).
+	ViewersSampled           time.Time     // The time concurrent viewers were last sampled.
+	PendingChanges           []byte        // Restart-required changes saved while active, JSON encoded, which are applied once inactive.
 }
 
 // SensorEntry contains the information for each sensor.
@@ -163,6 +193,121 @@ type SensorEntry struct {
 	DeviceMac int64
 }
 
+// Controller contains the information for a controller powering
+// broadcast hardware, e.g., lights powered separately to the camera.
+type Controller struct {
+	MAC        int64  // Controller MAC address.
+	OnActions  string // Actions to power up the controller's hardware.
+	OffActions string // Actions to power down the controller's hardware.
+	VoltagePin string // Battery voltage pin, A0 if empty.
+}
+
+// parseControllers parses additional controllers, separated by
+// semicolons, each in the form MAC|OnActions|OffActions|VoltagePin,
+// where all but the MAC are optional.
+func parseControllers(s string) ([]Controller, error) {
+	var cs []Controller
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, "|")
+		if len(fields) > 4 {
+			return nil, fmt.Errorf("too many fields in controller %q", entry)
+		}
+		fields = append(fields, make([]string, 4-len(fields))...)
+		c := Controller{
+			MAC:        model.MacEncode(strings.TrimSpace(fields[0])),
+			OnActions:  strings.TrimSpace(fields[1]),
+			OffActions: strings.TrimSpace(fields[2]),
+			VoltagePin: strings.TrimSpace(fields[3]),
+		}
+		if c.MAC == 0 {
+			return nil, fmt.Errorf("invalid MAC in controller %q", entry)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+// hlsBuckets are the GCS buckets that broadcasts may write HLS to.
+var hlsBuckets = []string{"ausocean-hls"}
+
+// checkHLSOutput checks that an HLS output is a playlist, which is
+// either a gs:// URL in one of hlsBuckets or a relative path, without
+// parent directories in either case.
+func checkHLSOutput(s string) error {
+	if s == "" {
+		return nil
+	}
+	if !strings.HasSuffix(s, ".m3u8") {
+		return fmt.Errorf("HLS output %q is not an .m3u8 playlist", s)
+	}
+	path := s
+	if obj, ok := strings.CutPrefix(s, "gs://"); ok {
+		bkt, p, _ := strings.Cut(obj, "/")
+		if !slices.Contains(hlsBuckets, bkt) {
+			return fmt.Errorf("HLS output %q is not in a permitted bucket", s)
+		}
+		path = p
+	}
+	if strings.HasPrefix(path, "/") || strings.Contains(path, "://") || slices.Contains(strings.Split(path, "/"), "..") {
+		return fmt.Errorf("HLS output %q is not a relative path", s)
+	}
+	return nil
+}
+
+// hlsSource returns the URL from which the broadcast's HLS output is
+// played, i.e., the output itself if in GCS, otherwise the output's
+// path beneath vidforward's /hls endpoint.
+func hlsSource(cfg *BroadcastConfig) string {
+	if strings.HasPrefix(cfg.HLSOutput, "gs://") {
+		return cfg.HLSOutput
+	}
+	return "http://" + cfg.VidforwardHost + "/hls/" + cfg.HLSOutput
+}
+
+// setHLSFeedSource sets the source of the broadcast's AusOcean TV feed,
+// if any, to its HLS output. The feed must belong to the broadcast's
+// site.
+func setHLSFeedSource(ctx context.Context, store datastore.Store, cfg *BroadcastConfig) error {
+	if cfg.HLSFeed == 0 || cfg.HLSOutput == "" {
+		return nil
+	}
+	err := checkHLSOutput(cfg.HLSOutput)
+	if err != nil {
+		return err
+	}
+	f, err := model.GetFeed(ctx, store, cfg.HLSFeed)
+	if err != nil {
+		return fmt.Errorf("could not get feed %d: %w", cfg.HLSFeed, err)
+	}
+	if f.Skey != cfg.SKey {
+		return fmt.Errorf("feed %d does not belong to site %d", cfg.HLSFeed, cfg.SKey)
+	}
+	src := hlsSource(cfg)
+	if f.Source == src {
+		return nil
+	}
+	f.Source = src
+	err = model.PutFeed(ctx, store, f)
+	if err != nil {
+		return fmt.Errorf("could not put feed %d: %w", cfg.HLSFeed, err)
+	}
+	return nil
+}
+
+// ControllersText returns the broadcast's additional controllers in
+// the form parsed by parseControllers.
+func (c *BroadcastConfig) ControllersText() string {
+	var entries []string
+	for _, ctl := range c.Controllers {
+		entries = append(entries, strings.TrimRight(strings.Join([]string{model.MacDecode(ctl.MAC), ctl.OnActions, ctl.OffActions, ctl.VoltagePin}, "|"), "|"))
+	}
+	return strings.Join(entries, ";")
+}
+
 // parseStartEnd takes the start and end time unix strings from the broadcast
 // and provides these as time.Time.
 func (c *BroadcastConfig) parseStartEnd() error {
@@ -217,13 +362,24 @@ func broadcastHandler(w http.ResponseWriter, r *http.Request) {
 			Enabled:               r.FormValue("enabled") == "enabled",
 			InFailure:             r.FormValue("in-failure") == "in-failure",
 			RegisterOpenFish:      r.FormValue("register-openfish") == "register-openfish",
+			SendConditions:        r.FormValue("send-conditions") == "send-conditions",
 			OpenFishCaptureSource: r.FormValue("openfish-capturesource"),
+			OpenFishAnnotators:    r.FormValue("openfish-annotators"),
+			NotifyRecipients:      r.FormValue("notify-recipients"),
+			NotifyRoutes:          r.FormValue("notify-routes"),
+			SlateSchedule:         r.FormValue("slate-schedule"),
+			GenerateSlate:         r.FormValue("generate-slate") == "generate-slate",
+			SlateImageURL:         r.FormValue("slate-image-url"),
+			TitleTemplate:         r.FormValue("title-template"),
+			ThumbnailURL:          r.FormValue("thumbnail-url"),
+			MetadataRefreshTime:   r.FormValue("metadata-refresh-time"),
 		},
 		Action:             r.FormValue("action"),
 		ListingSecondaries: r.FormValue("list-secondaries") == "listing-secondaries",
 		Settings: Settings{
-			Resolution: []string{"1080p"},
-			Privacy:    []string{"unlisted", "private", "public"},
+			Resolution:              []string{"1080p"},
+			Privacy:                 []string{"unlisted", "private", "public"},
+			VoltageRecoveryStrategy: []string{"linear", "solar", "extrapolate"},
 		},
 	}
 
@@ -241,6 +397,48 @@ func broadcastHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Status check intervals are optional, defaulting when zero.
+	cfg.MinStatusInterval, _ = strconv.Atoi(r.FormValue("min-status-interval"))
+	cfg.MaxStatusInterval, _ = strconv.Atoi(r.FormValue("max-status-interval"))
+
+	// Readiness checks are optional, disabled when zero.
+	cfg.ReadinessLeadTime, _ = strconv.Atoi(r.FormValue("readiness-lead-time"))
+
+	// Hardware is powered off at the end unless delayed.
+	cfg.PowerOffDelay, _ = strconv.Atoi(r.FormValue("power-off-delay"))
+
+	// Overrun tolerance is optional, defaulting when zero.
+	cfg.OverrunTolerance, _ = strconv.Atoi(r.FormValue("overrun-tolerance"))
+
+	cfg.VoltageRecoveryStrategy = r.FormValue("voltage-recovery-strategy")
+
+	// HLS output is optional, as is the feed played from it.
+	cfg.HLSOutput = strings.TrimSpace(r.FormValue("hls-output"))
+	err = checkHLSOutput(cfg.HLSOutput)
+	if err != nil {
+		reportError(w, r, req, "invalid HLS output: %v", err)
+		return
+	}
+	if v := r.FormValue("hls-feed"); v != "" {
+		cfg.HLSFeed, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			reportError(w, r, req, "invalid HLS feed ID: %v", err)
+			return
+		}
+	}
+
+	_, err = broadcast.ParseSlateSchedule(cfg.SlateSchedule)
+	if err != nil {
+		reportError(w, r, req, "invalid slate schedule: %v", err)
+		return
+	}
+
+	cfg.Controllers, err = parseControllers(r.FormValue("controllers"))
+	if err != nil {
+		reportError(w, r, req, "could not parse additional controllers: %v", err)
+		return
+	}
+
 	// Load config information for any existing broadcasts that have been saved.
 	req.BroadcastVars, err = model.GetVariablesBySite(ctx, settingsStore, sKey, broadcastScope)
 	switch err {
@@ -320,7 +518,7 @@ func broadcastHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Store the account email in the broadcast config.
 		cfg.Account = profile.Email
-		err := saveBroadcast(ctx, &req.CurrentBroadcast)
+		_, err := saveBroadcast(ctx, &req.CurrentBroadcast)
 		if err != nil {
 			reportError(w, r, req, "could not save broadcast: %v", err)
 			return
@@ -334,12 +532,21 @@ func broadcastHandler(w http.ResponseWriter, r *http.Request) {
 			reportError(w, r, req, "could not get existing account for name: %s: %v", cfg.Name, err)
 			return
 		}
-		err := saveBroadcast(ctx, &req.CurrentBroadcast)
+		deferred, err := saveBroadcast(ctx, &req.CurrentBroadcast)
 		if err != nil {
 			reportError(w, r, req, "could not save broadcast: %v", err)
 			return
 		}
 		msg = "broadcast saved successfully"
+		if len(deferred) != 0 {
+			msg = fmt.Sprintf("warning: broadcast is in progress, so changes to %s will be applied once it has finished", strings.Join(deferred, ", "))
+		}
+
+		err = setHLSFeedSource(ctx, settingsStore, cfg)
+		if err != nil {
+			reportError(w, r, req, "could not set source of HLS feed: %v", err)
+			return
+		}
 
 		// Ensure that the CheckBroadcast cron exists.
 		c := &model.Cron{Skey: cfg.SKey, ID: "Broadcast Check", TOD: "* * * * *", Action: "rpc", Var: tvURL + "/checkbroadcasts", Enabled: true}
@@ -407,35 +614,47 @@ func stringToAction(s string, req broadcastRequest) Action {
 	return buttonPress
 }
 
-// saveBroadcast sends a request to save a broadcast to the broadcast manager service (oceantv).
+// saveBroadcast sends a request to save a broadcast to the broadcast manager service (oceantv),
+// returning the names of fields whose changes were deferred because the broadcast is in progress,
+// which are applied once it has finished.
 // TODO: Add JWT signing.
-func saveBroadcast(ctx context.Context, cfg *Cfg) error {
+func saveBroadcast(ctx context.Context, cfg *Cfg) ([]string, error) {
 	data, err := json.Marshal(cfg)
 	if err != nil {
-		return fmt.Errorf("error marshalling BroadcastConfig: %w", err)
+		return nil, fmt.Errorf("error marshalling BroadcastConfig: %w", err)
 	}
 
 	const saveMethod = "/broadcast/save"
 	url := tvURL + saveMethod
 	reader := bytes.NewReader(data)
-	req, err := http.NewRequest("POST", url, reader)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, reader)
 	if err != nil {
-		return fmt.Errorf("error creating %s request: %w", saveMethod, err)
+		return nil, fmt.Errorf("error creating %s request: %w", saveMethod, err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	backend.PropagateRequestID(ctx, req)
 
 	clt := &http.Client{}
 	resp, err := clt.Do(req)
 	if err != nil {
-		return fmt.Errorf("error sending %s request: %w", saveMethod, err)
+		return nil, fmt.Errorf("error sending %s request: %w", saveMethod, err)
 	}
 
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s request failed with status code: %s", saveMethod, http.StatusText(resp.StatusCode))
+		return nil, fmt.Errorf("%s request failed with status code: %s", saveMethod, http.StatusText(resp.StatusCode))
+	}
+
+	// Older versions of the TV service respond with an empty body,
+	// which means that no changes were deferred.
+	var saved struct{ Deferred []string }
+	err = json.NewDecoder(resp.Body).Decode(&saved)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not decode %s response: %w", saveMethod, err)
 	}
 
 	log.Printf("%s OK", saveMethod)
-	return nil
+	return saved.Deferred, nil
 }
 
 // deleteBroadcast deletes a broadcast from the datastore and also updates the BroadcastVars
@@ -446,6 +665,10 @@ func deleteBroadcast(ctx context.Context, req *broadcastRequest, store datastore
 	if err != nil {
 		return fmt.Errorf("could not delete broadcast: %v", err)
 	}
+	err = model.DeleteBroadcastConfig(ctx, store, cfg.SKey, cfg.Name)
+	if err != nil {
+		return fmt.Errorf("could not delete broadcast config: %v", err)
+	}
 
 	req.BroadcastVars, err = model.GetVariablesBySite(ctx, store, cfg.SKey, broadcastScope)
 	switch err {
@@ -133,10 +133,12 @@ func vidforwardRequest(cfg *BroadcastConfig, status vidforwardStatus) error {
 	data := struct {
 		MAC, Status string
 		URLs        []string
+		HLS         string `json:",omitempty"` // HLS playlist output, if any.
 	}{
 		MAC:    model.MacDecode(primary.CameraMac),
 		URLs:   urls,
 		Status: string(status),
+		HLS:    primary.HLSOutput,
 	}
 
 	log.Printf("broadcast: %s, ID: %s, attempting to update vidforward configuration, data: %+v", cfg.Name, cfg.ID, data)
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestBroadcastConfigFields tests that BroadcastConfig mirrors model.BroadcastConfig.
+func TestBroadcastConfigFields(t *testing.T) {
+	err := model.MatchBroadcastConfig(reflect.TypeOf(BroadcastConfig{}))
+	if err != nil {
+		t.Errorf("BroadcastConfig does not match model.BroadcastConfig: %v", err)
+	}
+}
+
+func TestParseControllers(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Controller
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{
+			in:   "00:00:00:00:00:02",
+			want: []Controller{{MAC: 2}},
+		},
+		{
+			in: "00:00:00:00:00:02|Lights=on,Pump=on|Lights=off|A4; 00:00:00:00:00:03||Lights=off",
+			want: []Controller{
+				{MAC: 2, OnActions: "Lights=on,Pump=on", OffActions: "Lights=off", VoltagePin: "A4"},
+				{MAC: 3, OffActions: "Lights=off"},
+			},
+		},
+		{in: "lights|Lights=on", wantErr: true},
+		{in: "00:00:00:00:00:02|a|b|c|d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseControllers(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseControllers(%q) returned unexpected error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseControllers(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		// Formatted controllers parse to the same controllers.
+		cfg := BroadcastConfig{Controllers: got}
+		got, err = parseControllers(cfg.ControllersText())
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseControllers(%q) = %+v, %v, want %+v", cfg.ControllersText(), got, err, tt.want)
+		}
+	}
+}
+
+func TestHLSOutput(t *testing.T) {
+	tests := []struct {
+		out     string
+		want    string
+		wantErr bool
+	}{
+		{out: "gs://ausocean-hls/rapidbay/index.m3u8", want: "gs://ausocean-hls/rapidbay/index.m3u8"},
+		{out: "rapidbay/index.m3u8", want: "http://vidforward.example:8080/hls/rapidbay/index.m3u8"},
+		{out: "rapidbay/index.ts", wantErr: true},
+		{out: "/var/hls/index.m3u8", wantErr: true},
+		{out: "../index.m3u8", wantErr: true},
+		{out: "http://example.com/index.m3u8", wantErr: true},
+		{out: "gs://other-bucket/index.m3u8", wantErr: true},
+		{out: "gs://ausocean-hls/../other/index.m3u8", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := checkHLSOutput(tt.out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkHLSOutput(%q) returned unexpected error: %v", tt.out, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		got := hlsSource(&BroadcastConfig{VidforwardHost: "vidforward.example:8080", HLSOutput: tt.out})
+		if got != tt.want {
+			t.Errorf("hlsSource(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestSetHLSFeedSource(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	for _, f := range []model.Feed{{ID: 1, Skey: 1, Source: "old"}, {ID: 2, Skey: 2, Source: "other"}} {
+		err = model.PutFeed(ctx, store, &f)
+		if err != nil {
+			t.Fatalf("could not put feed: %v", err)
+		}
+	}
+
+	const out = "gs://ausocean-hls/rapidbay/index.m3u8"
+	err = setHLSFeedSource(ctx, store, &BroadcastConfig{SKey: 1, HLSFeed: 1, HLSOutput: out})
+	if err != nil {
+		t.Fatalf("setHLSFeedSource returned error for own feed: %v", err)
+	}
+	f, err := model.GetFeed(ctx, store, 1)
+	if err != nil || f.Source != out {
+		t.Errorf("feed source not set: got %q, %v, want %q", f.Source, err, out)
+	}
+
+	err = setHLSFeedSource(ctx, store, &BroadcastConfig{SKey: 1, HLSFeed: 2, HLSOutput: out})
+	if err == nil {
+		t.Errorf("setHLSFeedSource did not return error for feed of another site")
+	}
+	f, err = model.GetFeed(ctx, store, 2)
+	if err != nil || f.Source != "other" {
+		t.Errorf("source of feed of another site changed: got %q, %v", f.Source, err)
+	}
+}
@@ -29,55 +29,312 @@ LICENSE
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
 )
 
-// proxyScheduler is a cron client that forwards requests to a cron service, such as Ocean Cron.
-type proxyScheduler struct {
+// queuedScheduler is a cron client that forwards requests to a cron
+// service, such as Ocean Cron. Requests are queued in the datastore
+// before being sent, and those that fail are retried with backoff by
+// syncCronsHandler, so that cron changes eventually reach the service
+// even if it is briefly unavailable.
+type queuedScheduler struct {
 	url string
 }
 
-// Set simply forwards a cron schedule request to a service running a
-// cron scheduler. The caller is required to perform relevant cron
-// datastore operations _before_ this calling this method, otherwise
-// changes will not be visible to the remote service.
-// TODO: Sign requests using JWT.
-func (ps *proxyScheduler) Set(cron *model.Cron) error {
-	log.Printf("setting cron: %v", cron.ID)
+// Set queues a request to schedule a cron with the cron service and
+// attempts to send it. The caller is required to perform relevant
+// cron datastore operations _before_ calling this method, since
+// whether the cron is set or unset is determined by its state in the
+// datastore, which is also what the remote service schedules. An error
+// is returned only if the request could be neither queued nor sent;
+// requests that are queued but not sent are retried later.
+func (qs *queuedScheduler) Set(cron *model.Cron) error {
+	return qs.set(context.Background(), settingsStore, cron)
+}
+
+func (qs *queuedScheduler) set(ctx context.Context, store datastore.Store, cron *model.Cron) error {
+	cs, err := model.QueueCronSync(ctx, store, cron.Skey, cron.ID)
+	if err != nil {
+		log.Printf("could not queue cron %s, sending without retry: %v", cron.ID, err)
+		return qs.send(cron.Skey, cron.ID, cron.Enabled)
+	}
+	err = qs.sync(ctx, store, cs)
+	if err != nil {
+		log.Printf("could not sync cron %s, will retry: %v", cron.ID, err)
+	}
+	return nil
+}
+
+// sync attempts a queued cron sync, setting the cron if it is enabled
+// in the datastore and unsetting it otherwise, and records the outcome.
+func (qs *queuedScheduler) sync(ctx context.Context, store datastore.Store, cs *model.CronSync) error {
+	cron, err := model.GetCron(ctx, store, cs.Skey, cs.ID)
+	switch {
+	case err == nil:
+		err = qs.send(cs.Skey, cs.ID, cron.Enabled)
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+		// The cron has been deleted, so unset it.
+		err = qs.send(cs.Skey, cs.ID, false)
+	default:
+		err = fmt.Errorf("could not get cron: %w", err)
+	}
+	if err != nil {
+		ferr := model.FailCronSync(ctx, store, cs, err, time.Now())
+		if ferr != nil {
+			log.Printf("could not record failed sync of cron %s: %v", cs.ID, ferr)
+		}
+		return err
+	}
+	return model.CompleteCronSync(ctx, store, cs)
+}
+
+// cronSyncResult is the response to a cron sync request.
+type cronSyncResult struct {
+	Pending int // Number of pending syncs, including those not yet due.
+	Synced  int // Number of syncs that succeeded.
+	Failed  int // Number of syncs that failed, which are retried later.
+}
+
+// syncCrons attempts the queued cron syncs that are due at time now.
+func (qs *queuedScheduler) syncCrons(ctx context.Context, store datastore.Store, now time.Time) (*cronSyncResult, error) {
+	syncs, err := model.GetCronSyncs(ctx, store)
+	if err != nil {
+		return nil, fmt.Errorf("could not get cron syncs: %w", err)
+	}
+	res := &cronSyncResult{Pending: len(syncs)}
+	for i := range syncs {
+		cs := &syncs[i]
+		if !cs.Due(now) {
+			continue
+		}
+		err := qs.sync(ctx, store, cs)
+		if err != nil {
+			log.Printf("could not sync cron %d.%s after %d attempts: %v", cs.Skey, cs.ID, cs.Attempts, err)
+			res.Failed++
+			continue
+		}
+		res.Synced++
+		res.Pending--
+	}
+	return res, nil
+}
+
+// cronRequest returns true if a request is from App Engine cron, i.e.,
+// has the X-Appengine-Cron header, which App Engine removes from
+// external requests, or else carries a JWT signed with the cron
+// secret. Otherwise, an error is written and false is returned.
+func cronRequest(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("X-Appengine-Cron") == "true" {
+		return true
+	}
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	if err != nil {
+		writeHttpError(w, http.StatusUnauthorized, fmt.Sprintf("invalid claims: %v", err))
+		return false
+	}
+	if claims["iss"] != cronServiceAccount {
+		writeHttpError(w, http.StatusUnauthorized, fmt.Sprintf("invalid issuer: %v", claims["iss"]))
+		return false
+	}
+	return true
+}
+
+// syncCronsHandler retries queued cron syncs that are due. It is
+// invoked by App Engine cron, as scheduled by oceanbench_cron.yaml,
+// since the cron service that syncs are sent to cannot be relied upon
+// to invoke it. See cronRequest.
+func syncCronsHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+
+	if !cronRequest(w, r) {
+		return
+	}
+
+	setup(ctx)
+	res, err := cronScheduler.syncCrons(ctx, settingsStore, time.Now())
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, fmt.Sprintf("cron sync failed: %v", err))
+		return
+	}
+	log.Printf("synced %d crons, with %d failures and %d pending", res.Synced, res.Failed, res.Pending)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// cronReconcileResult is the response of the cron service to a
+// reconcile request, listing the crons, as site key and cron ID, that
+// were added to, removed from or changed in its scheduler.
+type cronReconcileResult struct {
+	DryRun  bool     `json:"dryRun,omitempty"`
+	Crons   int      `json:"crons"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// reconcile requests that the cron service reconcile its scheduled
+// jobs with the crons in the datastore, returning the result. When
+// dryRun is true, differences are reported but not resolved.
+func (qs *queuedScheduler) reconcile(dryRun bool) (*cronReconcileResult, error) {
+	url := qs.url + "/cron/reconcile"
+	if dryRun {
+		url += "?dryrun=true"
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating reconcile request: %w", err)
+	}
+	resp, err := qs.do(req, map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("error sending reconcile request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("reconcile request failed with status code: " + http.StatusText(resp.StatusCode))
+	}
 
-	// Create a new HTTP client.
-	clt := &http.Client{}
+	var res cronReconcileResult
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode reconcile result: %w", err)
+	}
+	return &res, nil
+}
+
+// Cron service request limits.
+const (
+	cronTimeout = time.Minute // Timeout of requests to the cron service.
+	maxCronSize = 1 << 16     // Maximum size of a JSON cron to simulate.
+)
+
+// do sends a request to the cron service. The request carries a JWT
+// signed with the cron secret, with the given claims in addition to
+// the issuer, the same as the cron service's own rpc requests.
+func (qs *queuedScheduler) do(req *http.Request, claims map[string]interface{}) (*http.Response, error) {
+	claims["iss"] = cronServiceAccount
+	tokString, err := gauth.PutClaims(claims, cronSecret)
+	if err != nil {
+		return nil, fmt.Errorf("error signing claims: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tokString)
+	clt := &http.Client{Timeout: cronTimeout}
+	return clt.Do(req)
+}
+
+// simulate requests that the cron service simulate a cron on behalf of
+// a site admin, returning the response. A non-empty body is the JSON
+// cron to simulate in place of the saved cron, and n is the optional
+// number of run times.
+func (qs *queuedScheduler) simulate(ctx context.Context, skey int64, id, email, n string, body []byte) (*http.Response, error) {
+	url := qs.url + "/cron/simulate/" + strconv.FormatInt(skey, 10) + "/" + id
+	if n != "" {
+		url += "?n=" + n
+	}
+	method := http.MethodGet
+	if len(body) > 0 {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating simulate request: %w", err)
+	}
+	resp, err := qs.do(req, map[string]interface{}{"skey": skey, "email": email})
+	if err != nil {
+		return nil, fmt.Errorf("error sending simulate request: %w", err)
+	}
+	return resp, nil
+}
+
+// simulateCronHandler handles requests by site admins to simulate a
+// cron of their current site, i.e., to see when it would run and what
+// it would affect. The ci param is the cron ID and the optional n
+// param is the number of run times. A POSTed JSON cron, e.g., an
+// unsaved edit, is simulated in place of the saved cron. The
+// simulation is performed by the cron service and its JSON response is
+// returned as is.
+func simulateCronHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		writeHttpError(w, http.StatusUnauthorized, "user could not be authenticated")
+		return
+	}
+	skey, _ := profileData(profile)
+	if !isAdmin(ctx, skey, profile.Email) {
+		writeHttpError(w, http.StatusForbidden, "only site admins may simulate crons")
+		return
+	}
+	id := r.FormValue("ci")
+	if id == "" {
+		writeHttpError(w, http.StatusBadRequest, errInvalidID.Error())
+		return
+	}
+
+	var body []byte
+	if r.Method == http.MethodPost {
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxCronSize))
+		if err != nil {
+			writeHttpError(w, http.StatusBadRequest, "could not read cron: %v", err)
+			return
+		}
+	}
+
+	resp, err := cronScheduler.simulate(ctx, skey, id, profile.Email, r.URL.Query().Get("n"), body)
+	if err != nil {
+		writeHttpError(w, http.StatusBadGateway, "could not simulate cron: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// send forwards a request to set or unset a cron to the cron service.
+// Requests are signed, with the site key as a claim, so that only
+// Ocean Bench may change the crons that the service schedules.
+func (qs *queuedScheduler) send(skey int64, id string, enabled bool) error {
+	log.Printf("setting cron: %v", id)
 
-	// Create a new request.
 	var op string
-	if cron.Enabled {
+	if enabled {
 		op = "set"
 	} else {
 		op = "unset"
 	}
-	url := ps.url + "/cron/" + op + "/" + strconv.Itoa(int(cron.Skey)) + "/" + cron.ID
-	req, err := http.NewRequest("GET", url, nil)
+	url := qs.url + "/cron/" + op + "/" + strconv.FormatInt(skey, 10) + "/" + id
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("error creating cron request: %w", err)
 	}
-
-	// Send the request.
-	resp, err := clt.Do(req)
+	resp, err := qs.do(req, map[string]interface{}{"skey": skey})
 	if err != nil {
 		return fmt.Errorf("error sending cron request: %w", err)
 	}
-
-	// Check the response.
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return errors.New("cron request failed with status code: " + http.StatusText(resp.StatusCode))
 	}
 
-	log.Printf("/cron/%s/%s/%s OK", op, strconv.Itoa(int(cron.Skey)), cron.ID)
+	log.Printf("/cron/%s/%d/%s OK", op, skey, id)
 	return nil
 }
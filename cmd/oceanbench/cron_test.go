@@ -0,0 +1,120 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestQueuedScheduler(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	saved := cronSecret
+	defer func() { cronSecret = saved }()
+	cronSecret = []byte("secret")
+
+	// A cron service that is initially unavailable.
+	var (
+		up       bool
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+		if err != nil || claims["iss"] != cronServiceAccount || claims["skey"] != float64(1) {
+			t.Errorf("unexpected claims for %s: %v (%v)", r.URL.Path, claims, err)
+		}
+		requests = append(requests, r.URL.Path)
+	}))
+	defer srv.Close()
+	qs := &queuedScheduler{url: srv.URL}
+
+	c := &model.Cron{Skey: 1, ID: "cron1", Action: "set", Var: "X", Enabled: true}
+	err = model.PutCron(ctx, store, c)
+	if err != nil {
+		t.Fatalf("could not put cron: %v", err)
+	}
+	err = qs.set(ctx, store, c)
+	if err != nil {
+		t.Fatalf("set returned error while service unavailable: %v", err)
+	}
+
+	now := time.Now()
+	res, err := qs.syncCrons(ctx, store, now)
+	if err != nil {
+		t.Fatalf("syncCrons returned error: %v", err)
+	}
+	if res.Synced != 0 || res.Pending != 1 {
+		t.Errorf("got %+v while service unavailable, expected 1 pending", res)
+	}
+
+	// Syncs are retried once the service is available and the backoff has elapsed.
+	up = true
+	res, err = qs.syncCrons(ctx, store, now.Add(10*time.Minute))
+	if err != nil {
+		t.Fatalf("syncCrons returned error: %v", err)
+	}
+	if res.Synced != 1 || res.Failed != 0 || res.Pending != 0 {
+		t.Errorf("got %+v after service available, expected 1 synced", res)
+	}
+
+	// Deleted crons are unset.
+	err = model.DeleteCron(ctx, store, 1, "cron1")
+	if err != nil {
+		t.Fatalf("could not delete cron: %v", err)
+	}
+	err = qs.set(ctx, store, &model.Cron{Skey: 1, ID: "cron1"})
+	if err != nil {
+		t.Fatalf("set returned error: %v", err)
+	}
+
+	want := []string{"/cron/set/1/cron1", "/cron/unset/1/cron1"}
+	if len(requests) != len(want) {
+		t.Fatalf("got requests %v, expected %v", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("got request %s, expected %s", requests[i], want[i])
+		}
+	}
+	syncs, err := model.GetCronSyncs(ctx, store)
+	if err != nil {
+		t.Fatalf("could not get cron syncs: %v", err)
+	}
+	if len(syncs) != 0 {
+		t.Errorf("got %d pending syncs, expected none", len(syncs))
+	}
+}
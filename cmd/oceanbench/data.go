@@ -50,6 +50,9 @@ const (
 // A valid request is of form scheme://host/data/<skey>.
 // Unlike NetReceiver, we only support timestamps for start (ds) and finish (df) times.
 // Data duration (dd) and data unit (du) params are currently unsupported.
+// Values of sensors are converted to the canonical units of their
+// quantity, and the unit is appended to each CSV record and included
+// in JSON output.
 func dataHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
 	ctx := r.Context()
@@ -131,7 +134,8 @@ func dataHandler(w http.ResponseWriter, r *http.Request) {
 		scalars = newScalars
 	}
 
-	// Apply sensors, if any.
+	// Apply sensors, if any, and convert to canonical units.
+	var unit model.Unit
 	sensor, err := model.GetSensorV2(ctx, settingsStore, model.MacEncode(ma), pn)
 	if err != nil && err != datastore.ErrNoSuchEntity {
 		writeError(w, fmt.Errorf("could not get sensor: %w", err))
@@ -144,6 +148,11 @@ func dataHandler(w http.ResponseWriter, r *http.Request) {
 				writeError(w, fmt.Errorf("could not transform value %f: %w", scalars[i].Value, err))
 				return
 			}
+			scalars[i].Value, unit, err = sensor.CanonicalValue(scalars[i].Value)
+			if err != nil {
+				writeError(w, fmt.Errorf("could not convert value %f to canonical units: %w", scalars[i].Value, err))
+				return
+			}
 		}
 	}
 
@@ -153,7 +162,11 @@ func dataHandler(w http.ResponseWriter, r *http.Request) {
 		csvw := csv.NewWriter(w)
 		for _, s := range scalars {
 			ts := time.Unix(s.Timestamp, 0).In(fixedTimezone(tzUnix)).Format(timeFmt)
-			err := csvw.Write([]string{ts, s.FormatValue(3)})
+			record := []string{ts, s.FormatValue(3)}
+			if unit != "" {
+				record = append(record, string(unit))
+			}
+			err := csvw.Write(record)
 			if err != nil {
 				writeError(w, fmt.Errorf("could not write csv scalar record: %w", err))
 				return
@@ -165,26 +178,30 @@ func dataHandler(w http.ResponseWriter, r *http.Request) {
 		enc := json.NewEncoder(w)
 
 		type scalarData struct {
-			d string
-			v float64
+			D string  `json:"d"`
+			V float64 `json:"v"`
 		}
 
 		type scalarOut struct {
-			ma, pn, tz string
-			sd         []scalarData
+			MA string       `json:"ma"`
+			PN string       `json:"pn"`
+			TZ string       `json:"tz"`
+			UN string       `json:"un,omitempty"` // Canonical unit, if known.
+			SD []scalarData `json:"sd"`
 		}
 
 		out := scalarOut{
-			ma: ma,
-			pn: pn,
-			tz: tz,
-			sd: make([]scalarData, len(scalars)),
+			MA: ma,
+			PN: pn,
+			TZ: tz,
+			UN: string(unit),
+			SD: make([]scalarData, len(scalars)),
 		}
 
 		for i, s := range scalars {
 			ts := time.Unix(s.Timestamp, 0).Add(time.Duration(int64(60.0*site.Timezone)) * time.Minute).Format(timeFmt)
-			out.sd[i].d = ts
-			out.sd[i].v = s.Value
+			out.SD[i].D = ts
+			out.SD[i].V = s.Value
 		}
 
 		err = enc.Encode(out)
@@ -0,0 +1,170 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// forwardingData stores the data served to the forwarding page.
+type forwardingData struct {
+	Forwardings []model.Forwarding
+	Devices     []model.Device
+	Types       []string
+	commonData
+}
+
+// forwardingHandler handles the forwarding settings page, which lists
+// the site's forwardings of sensor data to external endpoints.
+// Readings are delivered by Data Blue when App Engine cron invokes its
+// /forward endpoint. POST requests perform the task given by the
+// task param:
+//
+//   - add: add a forwarding named fn of sensors fs, given as
+//     comma-separated MAC.pin values, to destination fd of type ft,
+//     with credential fc, SFTP host key fk, and period fp minutes.
+//   - enable: enable forwarding fi if fe is true, else disable it.
+//   - delete: delete forwarding fi.
+func forwardingHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	skey, _ := profileData(profile)
+	user, err := model.GetUser(ctx, settingsStore, skey, profile.Email)
+	if errors.Is(err, datastore.ErrNoSuchEntity) || (err == nil && user.Perm&model.WritePermission == 0) {
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("failed to get permission for user: %v", err)
+		http.Redirect(w, r, "/", http.StatusInternalServerError)
+		return
+	}
+
+	data := forwardingData{
+		Types: model.ForwardTypes,
+		commonData: commonData{
+			Pages:   pages("forwarding"),
+			Profile: profile,
+		},
+	}
+
+	var msg string
+	if r.Method == "POST" {
+		err := forwardingTaskHandler(r, skey)
+		if err != nil {
+			msg = err.Error()
+		}
+	}
+
+	data.Devices, err = model.GetDevicesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		msg = fmt.Sprintf("cannot get devices: %v", err)
+	}
+	data.Forwardings, err = model.GetForwardingsBySite(ctx, settingsStore, skey)
+	if err != nil {
+		msg = fmt.Sprintf("cannot get forwardings: %v", err)
+	}
+	writeTemplate(w, r, "set/forwarding.html", &data, msg)
+}
+
+// forwardingTaskHandler handles a forwarding task.
+func forwardingTaskHandler(r *http.Request, skey int64) error {
+	ctx := r.Context()
+
+	switch r.FormValue("task") {
+	case "add":
+		name := strings.TrimSpace(r.FormValue("fn"))
+		if name == "" {
+			return errors.New("name is required")
+		}
+		var period int64
+		if v := r.FormValue("fp"); v != "" {
+			var err error
+			period, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || period < 0 {
+				return errors.New("invalid period")
+			}
+		}
+		f := &model.Forwarding{
+			Skey:       skey,
+			Name:       name,
+			Sensors:    r.FormValue("fs"),
+			DestType:   r.FormValue("ft"),
+			Dest:       strings.TrimSpace(r.FormValue("fd")),
+			Credential: r.FormValue("fc"),
+			HostKey:    strings.TrimSpace(r.FormValue("fk")),
+			Period:     period,
+			Enabled:    true,
+		}
+		sensors, err := f.SensorList()
+		if err != nil {
+			return err
+		}
+		for _, s := range sensors {
+			dev, err := model.GetDevice(ctx, settingsStore, s.Mac)
+			if err != nil || dev.Skey != skey {
+				return fmt.Errorf("invalid device for sensor %s", s)
+			}
+		}
+		err = model.CreateForwarding(ctx, settingsStore, f)
+		if err != nil {
+			return fmt.Errorf("cannot add forwarding: %w", err)
+		}
+		return nil
+
+	case "enable", "delete":
+		id, err := strconv.ParseInt(r.FormValue("fi"), 10, 64)
+		if err != nil {
+			return errors.New("invalid forwarding")
+		}
+		f, err := model.GetForwarding(ctx, settingsStore, id)
+		if err != nil || f.Skey != skey {
+			return errors.New("forwarding not found")
+		}
+		if r.FormValue("task") == "delete" {
+			return model.DeleteForwarding(ctx, settingsStore, id)
+		}
+		enabled := r.FormValue("fe") == "true"
+		_, err = model.UpdateForwarding(ctx, settingsStore, id, func(f *model.Forwarding) { f.Enabled = enabled })
+		return err
+
+	default:
+		return errors.New("invalid task")
+	}
+}
@@ -0,0 +1,221 @@
+/*
+DESCRIPTION
+  Ocean Bench user invitations, which add users to a site once they
+  follow an emailed link and log in with the invited email address.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+)
+
+// inviteTTL is the time for which invites can be accepted.
+const inviteTTL = 7 * 24 * time.Hour
+
+// inviteData stores the data served to the invite page.
+type inviteData struct {
+	Invite *model.Invite
+	Site   string // Name of the site.
+	Role   string // Name of the role.
+	commonData
+}
+
+// inviteUser invites a user to the current site, emailing them a link
+// to accept the invite.
+func inviteUser(w http.ResponseWriter, r *http.Request, p *gauth.Profile) error {
+	skey, _ := profileData(p)
+	addr, err := mail.ParseAddress(r.FormValue("email"))
+	if err != nil {
+		return fmt.Errorf("invalid email address: %w", err)
+	}
+	perm, err := strconv.ParseInt(r.FormValue("perm"), 10, 64)
+	if err != nil || perm == 0 {
+		return errors.New("invalid role")
+	}
+	return sendInvite(r.Context(), &model.Invite{Skey: skey, Email: addr.Address, Perm: perm, Inviter: p.Email})
+}
+
+// reinviteUser replaces an invite to the current site with a new one,
+// e.g., when the original invite has expired.
+func reinviteUser(w http.ResponseWriter, r *http.Request, p *gauth.Profile) error {
+	skey, _ := profileData(p)
+	ctx := r.Context()
+	inv, err := siteInvite(ctx, skey, r.FormValue("hash"))
+	if err != nil {
+		return err
+	}
+	err = sendInvite(ctx, &model.Invite{Skey: skey, Email: inv.Email, Perm: inv.Perm, Inviter: p.Email})
+	if err != nil {
+		return err
+	}
+	err = model.DeleteInvite(ctx, settingsStore, inv.Hash)
+	if err != nil {
+		return fmt.Errorf("cannot delete invite: %w", err)
+	}
+	return nil
+}
+
+// uninviteUser revokes an invite to the current site.
+func uninviteUser(w http.ResponseWriter, r *http.Request, p *gauth.Profile) error {
+	skey, _ := profileData(p)
+	ctx := r.Context()
+	inv, err := siteInvite(ctx, skey, r.FormValue("hash"))
+	if err != nil {
+		return err
+	}
+	err = model.DeleteInvite(ctx, settingsStore, inv.Hash)
+	if err != nil {
+		return fmt.Errorf("cannot delete invite: %w", err)
+	}
+	return nil
+}
+
+// siteInvite returns the invite to the given site with the given hash.
+func siteInvite(ctx context.Context, skey int64, hash string) (*model.Invite, error) {
+	invites, err := model.GetInvitesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get invites: %w", err)
+	}
+	for i := range invites {
+		if invites[i].Hash == hash {
+			return &invites[i], nil
+		}
+	}
+	return nil, model.ErrInviteNotFound
+}
+
+// sendInvite creates an invite and emails the link to the invitee. In
+// standalone mode, the link is logged instead.
+func sendInvite(ctx context.Context, inv *model.Invite) error {
+	site, err := model.GetSite(ctx, settingsStore, inv.Skey)
+	if err != nil {
+		return fmt.Errorf("cannot get site: %w", err)
+	}
+	tok, err := model.CreateInvite(ctx, settingsStore, inv, inviteTTL)
+	if err != nil {
+		return fmt.Errorf("cannot create invite: %w", err)
+	}
+	link := dataHost + "/invite?token=" + url.QueryEscape(tok)
+	log.Printf("%s invited %s to site %d", inv.Inviter, inv.Email, inv.Skey)
+	if standalone {
+		log.Printf("invite link for %s: %s", inv.Email, link)
+		return nil
+	}
+
+	const sender = "vidgrindservice@gmail.com"
+	secrets, err := gauth.GetSecrets(ctx, projectID, []string{"mailjetPublicKey", "mailjetPrivateKey"})
+	if err != nil {
+		return fmt.Errorf("cannot get mailjet secrets: %w", err)
+	}
+	subject := "Invitation to " + site.Name + " on Ocean Bench"
+	body := fmt.Sprintf("%s has invited you to join the site %s on Ocean Bench as a %s user.\n\n"+
+		"To accept, follow this link and log in with your Google account for %s:\n%s\n\n"+
+		"The invitation expires on %s.\n",
+		inv.Inviter, site.Name, roleName(inv.Perm), inv.Email, link, inv.Expires.Format("2 January 2006"))
+	err = notify.Send(secrets["mailjetPublicKey"], secrets["mailjetPrivateKey"], sender, []string{inv.Email}, subject, body)
+	if err != nil {
+		return fmt.Errorf("cannot email invite: %w", err)
+	}
+	return nil
+}
+
+// inviteHandler handles invite links. Users who are not logged in are
+// asked to log in first. Accepting the invite creates the user and
+// selects the site.
+func inviteHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+	setup(ctx)
+
+	data := inviteData{commonData: commonData{Pages: pages("home")}}
+	tok := r.FormValue("token")
+	inv, err := model.GetInvite(ctx, settingsStore, tok)
+	if err != nil {
+		writeTemplate(w, r, "invite.html", &data, inviteError(err).Error())
+		return
+	}
+	data.Invite = inv
+	data.Role = roleName(inv.Perm)
+	site, err := model.GetSite(ctx, settingsStore, inv.Skey)
+	if err != nil {
+		writeTemplate(w, r, "invite.html", &data, fmt.Sprintf("cannot get site: %v", err))
+		return
+	}
+	data.Site = site.Name
+
+	p, err := getProfile(w, r)
+	if err != nil || r.Method != "POST" {
+		if err != nil && !errors.Is(err, gauth.TokenNotFound) {
+			log.Printf("authentication error: %v", err)
+		}
+		writeTemplate(w, r, "invite.html", &data, "")
+		return
+	}
+
+	_, err = model.AcceptInvite(ctx, settingsStore, tok, p.Email)
+	if err != nil {
+		writeTemplate(w, r, "invite.html", &data, inviteError(err).Error())
+		return
+	}
+	log.Printf("%s accepted invite to site %d", p.Email, inv.Skey)
+	putProfileData(w, r, strconv.FormatInt(site.Skey, 10)+":"+site.Name)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// inviteError returns a user-friendly error for an invite that cannot
+// be accepted.
+func inviteError(err error) error {
+	switch {
+	case errors.Is(err, model.ErrInviteNotFound):
+		return errors.New("this invitation is no longer valid")
+	case errors.Is(err, model.ErrInviteExpired):
+		return errors.New("this invitation has expired, please ask for a new one")
+	case errors.Is(err, model.ErrInviteAccepted):
+		return errors.New("this invitation has already been accepted")
+	case errors.Is(err, model.ErrInviteEmail):
+		return errors.New("this invitation is for a different email address, please log in with the invited account")
+	default:
+		return fmt.Errorf("cannot accept invitation: %w", err)
+	}
+}
+
+// roleName returns the name of the role with the given permissions.
+func roleName(perm int64) string {
+	for name, p := range onboardRoles {
+		if p == perm {
+			return name
+		}
+	}
+	return "custom"
+}
@@ -0,0 +1,46 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+)
+
+func TestRoleName(t *testing.T) {
+	tests := []struct {
+		perm int64
+		want string
+	}{
+		{perm: model.ReadPermission, want: "read"},
+		{perm: model.ReadPermission | model.WritePermission, want: "write"},
+		{perm: model.ReadPermission | model.WritePermission | model.AdminPermission, want: "admin"},
+		{perm: model.WritePermission, want: "custom"},
+	}
+
+	for _, test := range tests {
+		got := roleName(test.perm)
+		if got != test.want {
+			t.Errorf("roleName(%d) returned %s, expected %s", test.perm, got, test.want)
+		}
+	}
+}
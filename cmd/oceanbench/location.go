@@ -30,11 +30,14 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"log"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/ausocean/cloud/model"
 	"github.com/tarm/serial"
 )
 
@@ -79,8 +82,9 @@ func setLocation(lat, lng, alt float64) {
 // pollGPS continually reads NMEA sentences from a GPS receiver on a
 // serial port and updates the gpsStore. Note that the altitude used
 // is supplied by the caller, not the one reported by the receiver. A
-// negative altitude represents a depth.
-func pollGPS(name string, baud int, alt float64) {
+// negative altitude represents a depth. The location is also recorded
+// as a track point every trackPeriod, unless trackPeriod is zero.
+func pollGPS(name string, baud int, alt float64, trackPeriod time.Duration) {
 	cfg := &serial.Config{Name: name, Baud: baud}
 	rd, err := serial.OpenPort(cfg)
 	if err != nil {
@@ -88,6 +92,7 @@ func pollGPS(name string, baud int, alt float64) {
 	}
 	log.Printf("Polling GPS on serial port " + name)
 
+	var tracked time.Time
 	sc := bufio.NewScanner(rd)
 	for sc.Scan() {
 		sentence := sc.Text()
@@ -106,8 +111,14 @@ func pollGPS(name string, baud int, alt float64) {
 		}
 
 		lat, lng, ok := parseLatLng(sentence)
-		if ok {
-			setLocation(lat, lng, alt)
+		if !ok {
+			continue
+		}
+		setLocation(lat, lng, alt)
+		now := time.Now()
+		if trackPeriod > 0 && now.Sub(tracked) >= trackPeriod {
+			recordTrackPoint(lat, lng, alt, now)
+			tracked = now
 		}
 	}
 
@@ -154,3 +165,14 @@ func parseLatLng(sentence string) (lat, lng float64, ok bool) {
 	ok = true
 	return
 }
+
+// recordTrackPoint records the location at time t as a track point
+// for site 1, i.e., the standalone site.
+func recordTrackPoint(lat, lng, alt float64, t time.Time) {
+	ctx := context.Background()
+	p := &model.TrackPoint{Skey: 1, Timestamp: t.Unix(), Latitude: lat, Longitude: lng, Altitude: alt}
+	err := model.PutTrackPoint(ctx, mediaStore, p)
+	if err != nil {
+		log.Printf("could not record track point: %v", err)
+	}
+}
@@ -45,6 +45,7 @@ LICENSE
 //	[-baudRate int] serial device baud rate (9600 by default).
 //	[-loc string]   latitude,longitude of the GPS receiver in decimal degrees format.
 //	[-alt float]    altitude of the GPS receiver. Negative numbers signify depths (0 by default).
+//	[-trackperiod duration] period at which GPS track points are recorded, or 0 to disable (10s by default).
 //
 // The PORT environment variable can be used to set the default port number.
 package main
@@ -61,13 +62,17 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/cmd/oceantv/openfish"
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
 	"github.com/ausocean/openfish/datastore"
 	"github.com/ausocean/utils/sliceutils"
 )
@@ -133,7 +138,7 @@ var (
 )
 
 var (
-	cronScheduler proxyScheduler
+	cronScheduler queuedScheduler
 	cronSecret    []byte
 )
 
@@ -146,6 +151,7 @@ var templateFuncs = template.FuncMap{
 	"localdate":     formatLocalDate,
 	"localtime":     formatLocalTime,
 	"localdatetime": formatLocalDateTime,
+	"sitedatetime":  formatSiteDateTime,
 	"json":          toJSON,
 }
 
@@ -172,6 +178,7 @@ func main() {
 	var loc string
 	var port int
 	var cronURL string
+	var trackPeriod time.Duration
 	flag.BoolVar(&debug, "debug", false, "Run in debug mode.")
 	flag.BoolVar(&standalone, "standalone", false, "Run in standalone mode.")
 	flag.Float64Var(&alt, "alt", 0, "Altitude (negative for depth)")
@@ -183,7 +190,10 @@ func main() {
 	flag.StringVar(&cronURL, "cronurl", cronServiceURL, "Cron service URL")
 	flag.StringVar(&tvURL, "tvurl", tvServiceURL, "TV service URL")
 	flag.StringVar(&storePath, "filestore", "store", "File store path")
+	flag.StringVar(&backupBucket, "backupbucket", "ausocean-backups", "GCS bucket for site backups")
+	flag.DurationVar(&trackPeriod, "trackperiod", 10*time.Second, "GPS track recording period, or 0 to disable")
 	flag.Parse()
+	backend.SetupLogging(!(debug || standalone))
 
 	// Perform one-time setup or bail.
 	ctx := context.Background()
@@ -193,6 +203,7 @@ func main() {
 	http.Handle("/s/", http.StripPrefix("/s", http.FileServer(http.Dir("s"))))
 	// Except for favicon.ico.
 	http.HandleFunc("/favicon.ico", faviconHandler)
+	http.HandleFunc("/sw.js", serviceWorkerHandler)
 
 	// Get shared cronSecret.
 	var err error
@@ -201,6 +212,15 @@ func main() {
 		log.Printf("could not get cronSecret: %v", err)
 	}
 
+	// Get secret for signing share tokens.
+	shareSecret, err = gauth.GetHexSecret(ctx, projectID, "shareSecret")
+	if err != nil {
+		log.Printf("could not get shareSecret: %v", err)
+	}
+
+	// Generate requested spectrograms in the background.
+	go spectrogramWorker(ctx)
+
 	// Warmup handler.
 	http.HandleFunc("/_ah/warmup", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("warmup request received, version: " + version)
@@ -211,32 +231,59 @@ func main() {
 	http.HandleFunc("/play", playHandler)
 	http.HandleFunc("/learn/mooring", mooringHandler)
 	http.HandleFunc("/upload", uploadHandler)
-	http.HandleFunc("/set/devices/edit/var", editVarHandler)
-	http.HandleFunc("/set/devices/edit/sensor", editSensorHandler)
-	http.HandleFunc("/set/devices/edit/actuator", editActuatorHandler)
-	http.HandleFunc("/set/devices/edit", editDevicesHandler)
-	http.HandleFunc("/set/devices/configure", configDevicesHandler)
-	http.HandleFunc("/set/devices/", setDevicesHandler)
-	http.HandleFunc("/set/crons/edit", editCronsHandler)
-	http.HandleFunc("/set/crons/", setCronsHandler)
+	http.HandleFunc("/set/devices/edit/var", idempotent(editVarHandler))
+	http.HandleFunc("/set/devices/edit/sensor", idempotent(editSensorHandler))
+	http.HandleFunc("/set/devices/edit/actuator", idempotent(editActuatorHandler))
+	http.HandleFunc("/set/devices/edit", idempotent(editDevicesHandler))
+	http.HandleFunc("/set/devices/configure", idempotent(configDevicesHandler))
+	http.HandleFunc("/set/devices/", idempotent(setDevicesHandler))
+	http.HandleFunc("/set/crons/edit", idempotent(editCronsHandler))
+	http.HandleFunc("/set/crons/simulate", simulateCronHandler)
+	http.HandleFunc("/set/crons/", idempotent(setCronsHandler))
+	http.HandleFunc("/set/alerts", idempotent(alertsHandler))
+	http.HandleFunc("/set/forwarding", idempotent(forwardingHandler))
 	http.HandleFunc("/get", getHandler)
+	http.HandleFunc("/api/onboard", onboardAPIHandler)
 	http.HandleFunc("/api/", apiHandler)
+	http.HandleFunc("/backup", backupHandler)
+	http.HandleFunc("/purge/text", purgeTextHandler)
+	http.HandleFunc("/purge/notifications", purgeNotificationsHandler)
+	http.HandleFunc("/purge/cronruns", purgeCronRunsHandler)
+	http.HandleFunc("/purge/queuededits", purgeQueuedEditsHandler)
+	http.HandleFunc("/sync/crons", syncCronsHandler)
 	http.HandleFunc("/test/", testHandler)
 	http.HandleFunc("/login", loginHandler)
 	http.HandleFunc("/logout", logoutHandler)
 	http.HandleFunc("/oauth2callback", oauthCallbackHandler)
 	http.HandleFunc("/live/", liveHandler)
+	http.HandleFunc("/portal/", portalHandler)
 	http.HandleFunc("/monitor", monitorHandler)
+	http.HandleFunc("/overview", overviewHandler)
+	http.HandleFunc("/map", mapHandler)
+	http.HandleFunc("/usage", usageHandler)
+	http.HandleFunc("/track", trackHandler)
 	http.HandleFunc("/play/audiorequest", filterHandler)
+	http.HandleFunc("/spectrogram", spectrogramHandler)
+	http.HandleFunc("/annotations", annotationsHandler)
 	http.HandleFunc("/admin/site/add", adminHandler)
+	http.HandleFunc("/admin/site/onboard", adminHandler)
 	http.HandleFunc("/admin/site/update", adminHandler)
 	http.HandleFunc("/admin/site/delete", adminHandler)
 	http.HandleFunc("/admin/user/add", adminHandler)
 	http.HandleFunc("/admin/user/update", adminHandler)
 	http.HandleFunc("/admin/user/delete", adminHandler)
+	http.HandleFunc("/admin/user/invite", adminHandler)
+	http.HandleFunc("/admin/user/reinvite", adminHandler)
+	http.HandleFunc("/admin/user/uninvite", adminHandler)
+	http.HandleFunc("/invite", inviteHandler)
+	http.HandleFunc("/share", shareHandler)
+	http.HandleFunc("/admin/impersonate", impersonateHandler)
+	http.HandleFunc("/admin/impersonate/stop", stopImpersonatingHandler)
 	http.HandleFunc("/admin/site", adminHandler)
 	http.HandleFunc("/admin/broadcast", adminHandler)
 	http.HandleFunc("/admin/utils", adminHandler)
+	http.HandleFunc("/admin/tokens", adminHandler)
+	http.HandleFunc("/admin/sensors", adminHandler)
 	http.HandleFunc("/data/", dataHandler)
 	http.HandleFunc("/", indexHandler)
 
@@ -259,22 +306,45 @@ func main() {
 		}
 		if gps != "" {
 			// Poll for NMEA GPS messages.
-			go pollGPS(gps, baud, alt)
+			go pollGPS(gps, baud, alt, trackPeriod)
 		}
 		dataHost = "http://" + host + ":" + strconv.Itoa(port)
 
 	} else {
 		log.Printf("Initializing OAuth2")
-		auth = &gauth.UserAuth{ProjectID: projectID, ClientID: oauthClientID, MaxAge: oauthMaxAge}
+		auth = &gauth.UserAuth{ProjectID: projectID, ClientID: oauthClientID, MaxAge: oauthMaxAge, Store: settingsStore, Notify: loginNotifier(ctx)}
 		auth.Init(backend.NewNetHandler(nil, nil, nil))
+
+		// Pushing annotations to OpenFish is optional.
+		of, err := openfish.New()
+		if err != nil {
+			log.Printf("could not set up OpenFish service, annotations will not be pushed: %v", err)
+		} else {
+			ofsvc = &of
+		}
 		host = "" // Host is determined by App Engine.
 	}
 
-	cronScheduler = proxyScheduler{url: cronURL}
+	cronScheduler = queuedScheduler{url: cronURL}
 	log.Printf("Listening on %s:%d", host, port)
 	log.Printf("Sending cron requests to %s", cronURL)
 	log.Printf("Sending TV requests to %s", tvURL)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), nil))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), backend.Chain(http.DefaultServeMux, backend.RequestIDs(projectID))))
+}
+
+// loginNotifier returns a function that emails users of logins from
+// new locations, or nil if emails cannot be sent.
+func loginNotifier(ctx context.Context) gauth.LoginNotifier {
+	const sender = "vidgrindservice@gmail.com"
+	secrets, err := gauth.GetSecrets(ctx, projectID, []string{"mailjetPublicKey", "mailjetPrivateKey"})
+	if err != nil {
+		log.Printf("could not get mailjet secrets, login notifications will not be sent: %v", err)
+		return nil
+	}
+	return func(ctx context.Context, e *model.LoginEvent) error {
+		subject, body := gauth.LoginMessage(e)
+		return notify.Send(secrets["mailjetPublicKey"], secrets["mailjetPrivateKey"], sender, []string{e.Email}, subject, body)
+	}
 }
 
 // setup executes per-instance one-time warmup and is used to
@@ -383,7 +453,8 @@ func warmupHandler(w http.ResponseWriter, r *http.Request) {
 
 // getHandler handles media and text requests, depending on the pin type.
 // Requires read permission for the requested media, otherwise permission is denied.
-// The user need not be logged in to access public sites.
+// The user need not be logged in to access public sites, or media
+// shared by means of a share token (the share param).
 // When no output is specified, media data is downloaded to the client.
 func getHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
@@ -421,14 +492,28 @@ func getHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	setup(ctx)
 
-	ok, err := hasPermission(ctx, p, mid, model.ReadPermission)
-	if err != nil {
-		writeError(w, err)
-		return
-	}
-	if !ok {
-		writeError(w, errPermissionDenied)
-		return
+	// Media shared by means of a share token is permitted regardless
+	// of the user, but only within the share's scope.
+	if tok := q.Get("share"); tok != "" {
+		if ky != nil {
+			writeError(w, errPermissionDenied)
+			return
+		}
+		err = checkShare(ctx, tok, mid, ts)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: %v", errPermissionDenied, err))
+			return
+		}
+	} else {
+		ok, err := hasPermission(ctx, p, mid, model.ReadPermission)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !ok {
+			writeError(w, errPermissionDenied)
+			return
+		}
 	}
 
 	var content []byte
@@ -532,9 +617,10 @@ func writeTemplate(w http.ResponseWriter, r *http.Request, name string, data int
 	if p.IsValid() {
 		p.SetString(msg)
 	}
+	var profile *gauth.Profile
 	p = v.FieldByName("Profile")
 	if p.IsValid() {
-		profile, _ := getProfile(w, r)
+		profile, _ = getProfile(w, r)
 		p.Set(reflect.ValueOf(profile))
 	}
 	p = v.FieldByName("LoginURL")
@@ -548,7 +634,7 @@ func writeTemplate(w http.ResponseWriter, r *http.Request, name string, data int
 
 	const footer = "footer.html"
 	var b bytes.Buffer
-	err := templates.ExecuteTemplate(&b, footer, nil)
+	err := templates.ExecuteTemplate(&b, footer, profile)
 	if err != nil {
 		log.Fatalf("ExecuteTemplate failed on %s: %v", footer, err)
 	}
@@ -556,7 +642,12 @@ func writeTemplate(w http.ResponseWriter, r *http.Request, name string, data int
 	p.Set(reflect.ValueOf(template.HTML(b.String())))
 
 	if strings.HasPrefix(name, "set/") {
-		err = setTemplates.ExecuteTemplate(w, name[4:], data)
+		// Settings pages are ETagged, so that they can be revalidated offline.
+		b.Reset()
+		err = setTemplates.ExecuteTemplate(&b, name[4:], data)
+		if err == nil {
+			writeETagged(w, r, b.Bytes())
+		}
 	} else {
 		err = templates.ExecuteTemplate(w, name, data)
 	}
@@ -583,6 +674,21 @@ func pages(selected string) []page {
 			URL:  "/monitor",
 			Perm: model.ReadPermission,
 		},
+		{
+			Name: "overview",
+			URL:  "/overview",
+			Perm: model.ReadPermission,
+		},
+		{
+			Name: "map",
+			URL:  "/map",
+			Perm: model.ReadPermission,
+		},
+		{
+			Name: "usage",
+			URL:  "/usage",
+			Perm: model.ReadPermission,
+		},
 		{
 			Name: "play",
 			URL:  "/play",
@@ -610,6 +716,18 @@ func pages(selected string) []page {
 			Level: 1,
 			Perm:  model.WritePermission,
 		},
+		{
+			Name:  "alerts",
+			URL:   "/set/alerts",
+			Level: 1,
+			Perm:  model.WritePermission,
+		},
+		{
+			Name:  "forwarding",
+			URL:   "/set/forwarding",
+			Level: 1,
+			Perm:  model.WritePermission,
+		},
 		{
 			Name:  "admin",
 			Group: true,
@@ -633,6 +751,22 @@ func pages(selected string) []page {
 			Level: 1,
 			Perm:  model.AdminPermission,
 		},
+		{
+			Name:  "tokens",
+			URL:   "/admin/tokens",
+			Level: 1,
+			Perm:  model.AdminPermission,
+		},
+		{
+			Name:  "sensors",
+			URL:   "/admin/sensors",
+			Level: 1,
+			Perm:  model.AdminPermission,
+		},
+	}
+	if standalone {
+		// GPS tracks are only recorded in standalone mode.
+		pages = slices.Insert(pages, 5, page{Name: "track", URL: "/track", Perm: model.ReadPermission})
 	}
 	for i := range pages {
 		if pages[i].Name == selected {
@@ -0,0 +1,217 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Live statuses of devices on the map.
+const (
+	mapReporting = "reporting" // Reported within two monitor periods.
+	mapStale     = "stale"     // Reported within mapStaleTime.
+	mapOffline   = "offline"   // Not reported within mapStaleTime, or never reported.
+)
+
+// mapStaleTime is the time since a device last reported after which
+// it is considered offline, rather than stale.
+const mapStaleTime = 24 * time.Hour
+
+// worldBox is the bounding box of the entire map.
+var worldBox = model.BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}
+
+// mapData is the JSON representation of the sites and devices on the map.
+type mapData struct {
+	Sites   []mapSite
+	Devices []mapDevice
+}
+
+// mapSite represents a site on the map, with counts of its devices on
+// the map by live status.
+type mapSite struct {
+	Skey      int64
+	Name      string
+	Latitude  float64
+	Longitude float64
+	Reporting int
+	Stale     int
+	Offline   int
+}
+
+// mapDevice represents a device on the map.
+type mapDevice struct {
+	Skey      int64
+	Site      string
+	Name      string
+	MAC       string
+	Latitude  float64
+	Longitude float64
+	Status    string // Live status, i.e., reporting, stale or offline.
+	Reported  int64  // Unix time last reported, or zero if never.
+	URL       string `json:",omitempty"` // Device page, if the user has write permission.
+}
+
+// mapHandler handles the map page, which shows the sites and devices
+// that the user has access to on a map. The page is populated from,
+// and periodically refreshed by, the /api/get/map/sites endpoint.
+func mapHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+
+	data := commonData{Pages: pages("map"), Profile: profile}
+	writeTemplate(w, r, "map.html", &data, "")
+}
+
+// parseBox parses a bounding box of the form
+// minLat,minLon,maxLat,maxLon, returning the entire map if s is empty.
+func parseBox(s string) (model.BoundingBox, error) {
+	if s == "" {
+		return worldBox, nil
+	}
+	p := strings.Split(s, ",")
+	if len(p) != 4 {
+		return model.BoundingBox{}, fmt.Errorf("invalid box %q, expected minLat,minLon,maxLat,maxLon", s)
+	}
+	var v [4]float64
+	for i := range p {
+		var err error
+		v[i], err = strconv.ParseFloat(strings.TrimSpace(p[i]), 64)
+		if err != nil {
+			return model.BoundingBox{}, fmt.Errorf("invalid box %q: %w", s, err)
+		}
+	}
+	b := model.BoundingBox{MinLat: v[0], MinLon: v[1], MaxLat: v[2], MaxLon: v[3]}
+	return b, b.Validate()
+}
+
+// mapSites returns the sites and devices located within a bounding box
+// which belong to sites for which the user with the given email has
+// read permission. Sites are ordered by name, and devices by site then
+// name. Sites and devices without a location are omitted. Only the
+// user's sites are read, rather than all located sites and devices,
+// since users typically belong to few sites.
+func mapSites(ctx context.Context, store datastore.Store, email string, box model.BoundingBox) (*mapData, error) {
+	err := box.Validate()
+	if err != nil {
+		return nil, err
+	}
+	users, err := model.GetUsers(ctx, store, email)
+	if err != nil {
+		return nil, fmt.Errorf("could not get users: %w", err)
+	}
+
+	data := &mapData{Sites: []mapSite{}, Devices: []mapDevice{}}
+	now := time.Now()
+	for _, u := range users {
+		if u.Perm&model.ReadPermission == 0 {
+			continue
+		}
+		site, err := model.GetSite(ctx, store, u.Skey)
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get site %d: %w", u.Skey, err)
+		}
+		ms := mapSite{Skey: site.Skey, Name: site.Name, Latitude: site.Latitude, Longitude: site.Longitude}
+		onMap := site.Geohash != "" && box.Contains(site.Latitude, site.Longitude)
+
+		devs, err := model.GetDevicesBySite(ctx, store, u.Skey)
+		if err != nil {
+			return nil, fmt.Errorf("could not get devices for site %d: %w", u.Skey, err)
+		}
+		for _, dev := range devs {
+			if dev.Geohash == "" || !box.Contains(dev.Latitude, dev.Longitude) {
+				continue
+			}
+			md := mapDevice{Skey: dev.Skey, Site: site.Name, Name: dev.Name, MAC: dev.MAC(), Latitude: dev.Latitude, Longitude: dev.Longitude}
+			if u.Perm&model.WritePermission != 0 {
+				md.URL = "/set/devices/?ma=" + md.MAC + "&sk=auto"
+			}
+			v, err := model.GetVariable(ctx, store, dev.Skey, "_"+dev.Hex()+".uptime")
+			switch {
+			case errors.Is(err, datastore.ErrNoSuchEntity):
+				md.Status = liveStatus(dev, nil, now)
+			case err != nil:
+				return nil, fmt.Errorf("could not get uptime variable for %s: %w", md.MAC, err)
+			default:
+				md.Status = liveStatus(dev, v, now)
+				md.Reported = v.Updated.Unix()
+			}
+			switch md.Status {
+			case mapReporting:
+				ms.Reporting++
+			case mapStale:
+				ms.Stale++
+			default:
+				ms.Offline++
+			}
+			data.Devices = append(data.Devices, md)
+		}
+		if onMap {
+			data.Sites = append(data.Sites, ms)
+		}
+	}
+
+	sort.Slice(data.Sites, func(i, j int) bool { return data.Sites[i].Name < data.Sites[j].Name })
+	sort.Slice(data.Devices, func(i, j int) bool {
+		if data.Devices[i].Site != data.Devices[j].Site {
+			return data.Devices[i].Site < data.Devices[j].Site
+		}
+		return data.Devices[i].Name < data.Devices[j].Name
+	})
+	return data, nil
+}
+
+// liveStatus returns the live status of a device given its uptime
+// variable, which is nil if the device has never reported.
+func liveStatus(dev model.Device, uptime *model.Variable, now time.Time) string {
+	switch {
+	case uptime == nil:
+		return mapOffline
+	case sendingStatus(dev, uptime, now) == "green":
+		return mapReporting
+	case now.Sub(uptime.Updated) < mapStaleTime:
+		return mapStale
+	default:
+		return mapOffline
+	}
+}
@@ -0,0 +1,166 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestLiveStatus(t *testing.T) {
+	now := time.Now()
+	dev := model.Device{MonitorPeriod: 60}
+	tests := []struct {
+		uptime *model.Variable
+		want   string
+	}{
+		{uptime: nil, want: mapOffline},
+		{uptime: &model.Variable{Updated: now}, want: mapReporting},
+		{uptime: &model.Variable{Updated: now.Add(-119 * time.Second)}, want: mapReporting},
+		{uptime: &model.Variable{Updated: now.Add(-120 * time.Second)}, want: mapStale},
+		{uptime: &model.Variable{Updated: now.Add(-23 * time.Hour)}, want: mapStale},
+		{uptime: &model.Variable{Updated: now.Add(-mapStaleTime)}, want: mapOffline},
+	}
+
+	for i, test := range tests {
+		got := liveStatus(dev, test.uptime, now)
+		if got != test.want {
+			t.Errorf("test %d: got %s, expected %s", i, got, test.want)
+		}
+	}
+}
+
+func TestParseBox(t *testing.T) {
+	tests := []struct {
+		s    string
+		want model.BoundingBox
+		ok   bool
+	}{
+		{s: "", want: worldBox, ok: true},
+		{s: "-36,138,-35,139", want: model.BoundingBox{MinLat: -36, MinLon: 138, MaxLat: -35, MaxLon: 139}, ok: true},
+		{s: "-36, 138, -35, 139", want: model.BoundingBox{MinLat: -36, MinLon: 138, MaxLat: -35, MaxLon: 139}, ok: true},
+		{s: "-36,138,-35"},
+		{s: "-36,138,-35,east"},
+		{s: "-35,138,-36,139"},
+		{s: "-36,138,-35,181"},
+	}
+
+	for _, test := range tests {
+		got, err := parseBox(test.s)
+		if (err == nil) != test.ok {
+			t.Errorf("parseBox(%q) returned error %v, expected ok %t", test.s, err, test.ok)
+			continue
+		}
+		if test.ok && got != test.want {
+			t.Errorf("parseBox(%q) returned %+v, expected %+v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestMapSites(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	const email = "user@ausocean.org"
+	sites := []model.Site{
+		{Skey: 1, Name: "Rapid Bay", Latitude: -35.52, Longitude: 138.18},
+		{Skey: 2, Name: "Port Lincoln", Latitude: -34.72, Longitude: 135.86},
+		{Skey: 3, Name: "Hidden", Latitude: -35.0, Longitude: 138.5},
+	}
+	for i := range sites {
+		err := model.PutSite(ctx, store, &sites[i])
+		if err != nil {
+			t.Fatalf("could not put site: %v", err)
+		}
+	}
+	users := []model.User{
+		{Skey: 1, Email: email, Perm: model.ReadPermission | model.WritePermission},
+		{Skey: 2, Email: email, Perm: model.ReadPermission},
+	}
+	for i := range users {
+		err := model.PutUser(ctx, store, &users[i])
+		if err != nil {
+			t.Fatalf("could not put user: %v", err)
+		}
+	}
+	devs := []model.Device{
+		{Skey: 1, Mac: 1, Name: "Camera", MonitorPeriod: 60, Latitude: -35.52, Longitude: 138.18},
+		{Skey: 1, Mac: 2, Name: "Controller", MonitorPeriod: 60, Latitude: -35.53, Longitude: 138.19},
+		{Skey: 1, Mac: 3, Name: "Unlocated", MonitorPeriod: 60},
+		{Skey: 2, Mac: 4, Name: "Hydrophone", MonitorPeriod: 60, Latitude: -34.72, Longitude: 135.86},
+		{Skey: 3, Mac: 5, Name: "Secret", MonitorPeriod: 60, Latitude: -35.0, Longitude: 138.5},
+	}
+	for i := range devs {
+		err := model.PutDevice(ctx, store, &devs[i])
+		if err != nil {
+			t.Fatalf("could not put device: %v", err)
+		}
+	}
+	err = model.PutVariable(ctx, store, 1, "_"+devs[0].Hex()+".uptime", "100")
+	if err != nil {
+		t.Fatalf("could not put variable: %v", err)
+	}
+
+	data, err := mapSites(ctx, store, email, worldBox)
+	if err != nil {
+		t.Fatalf("mapSites returned error: %v", err)
+	}
+	if len(data.Sites) != 2 || data.Sites[0].Name != "Port Lincoln" || data.Sites[1].Name != "Rapid Bay" {
+		t.Fatalf("got sites %+v", data.Sites)
+	}
+	if s := data.Sites[1]; s.Reporting != 1 || s.Stale != 0 || s.Offline != 1 {
+		t.Errorf("got site %+v, expected 1 reporting and 1 offline", s)
+	}
+	wantDevs := []struct {
+		name, status string
+		linked       bool
+	}{
+		{"Hydrophone", mapOffline, false},
+		{"Camera", mapReporting, true},
+		{"Controller", mapOffline, true},
+	}
+	if len(data.Devices) != len(wantDevs) {
+		t.Fatalf("got devices %+v", data.Devices)
+	}
+	for i, want := range wantDevs {
+		got := data.Devices[i]
+		if got.Name != want.name || got.Status != want.status || (got.URL != "") != want.linked {
+			t.Errorf("got device %+v, expected %+v", got, want)
+		}
+	}
+
+	// Only Port Lincoln is within the box.
+	data, err = mapSites(ctx, store, email, model.BoundingBox{MinLat: -35, MinLon: 135, MaxLat: -34, MaxLon: 136})
+	if err != nil {
+		t.Fatalf("mapSites returned error: %v", err)
+	}
+	if len(data.Sites) != 1 || len(data.Devices) != 1 || data.Devices[0].Site != "Port Lincoln" {
+		t.Errorf("got %+v", data)
+	}
+}
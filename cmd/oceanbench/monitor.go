@@ -74,6 +74,8 @@ type monitorDevice struct {
 	Count                 int // Number of scalars sent in the monitor period.
 	MaxCount              int // Max number of scalars that could be sent.
 	Throughput            int // Percentage of successful scalars.
+	Flagged               int // Number of scalars flagged by sensor validation.
+	Rejected              int // Number of scalars rejected by sensor validation.
 	Sensors               []sensorData
 }
 
@@ -219,10 +221,8 @@ func monitorLoadRoutine(
 	case err != nil:
 		reportMonitorError(w, r, &data, "could not get uptime variable: %v", err)
 		return
-	case time.Since(v.Updated) < time.Duration(2*int(dev.MonitorPeriod))*time.Second:
-		md.Sending = "green"
 	default:
-		md.Sending = "red"
+		md.Sending = sendingStatus(dev, v, time.Now())
 	}
 	md.LastReportedTimestamp = v.Updated.Unix()
 
@@ -232,37 +232,71 @@ func monitorLoadRoutine(
 		return
 	}
 
-	sensors, err := model.GetSensorsV2(ctx, settingsStore, dev.Mac)
+	// Set the validation counters, which are absent unless readings have been flagged or rejected.
+	md.Flagged = readingCount(ctx, dev, "flagged")
+	md.Rejected = readingCount(ctx, dev, "rejected")
+
+	md.Sensors, err = latestSensors(ctx, dev, tz)
 	if err != nil {
-		reportMonitorError(w, r, &data, "could not get sensors: %v", err)
+		reportMonitorError(w, r, &data, "%v", err)
 		return
 	}
+	ch <- md
+	wg.Done()
+}
+
+// sendingStatus returns the sending status of a device given its
+// uptime variable, i.e., green if the device has reported within two
+// monitor periods of now, or red otherwise.
+func sendingStatus(dev model.Device, uptime *model.Variable, now time.Time) string {
+	if now.Sub(uptime.Updated) < time.Duration(2*int(dev.MonitorPeriod))*time.Second {
+		return "green"
+	}
+	return "red"
+}
 
+// latestSensors returns the latest transformed value of each of the
+// device's sensors, omitting sensors without values. Dates are
+// formatted in the given timezone.
+func latestSensors(ctx context.Context, dev model.Device, tz float64) ([]sensorData, error) {
+	sensors, err := model.GetSensorsV2(ctx, settingsStore, dev.Mac)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sensors: %v", err)
+	}
+
+	var sd []sensorData
 	for _, sensor := range sensors {
 		id := model.ToSID(model.MacDecode(sensor.Mac), sensor.Pin)
 		scalar, err := model.GetLatestScalar(ctx, mediaStore, id)
 		if err == datastore.ErrNoSuchEntity {
 			continue
 		} else if err != nil {
-			reportMonitorError(w, r, &data, "could not get latest scalar %d: %v", id, err)
-			return
+			return nil, fmt.Errorf("could not get latest scalar %d: %v", id, err)
 		}
 		value, err := sensor.Transform(scalar.Value)
 		if err != nil {
-			reportMonitorError(w, r, &data, "could not transform scalar for sensor %d.%s: %v", sensor.Mac, sensor.Pin, err)
-			return
+			return nil, fmt.Errorf("could not transform scalar for sensor %d.%s: %v", sensor.Mac, sensor.Pin, err)
 		}
 
-		sensorData := sensorData{
+		sd = append(sd, sensorData{
 			Name:   sensor.Name,
 			Units:  sensor.Units,
 			Scalar: fmt.Sprintf("%.2f", value),
 			Date:   time.Unix(scalar.Timestamp, 0).In(fixedTimezone(tz)).Format("Jan 2 15:04:05"),
-		}
-		md.Sensors = append(md.Sensors, sensorData)
+		})
 	}
-	ch <- md
-	wg.Done()
+	return sd, nil
+}
+
+// readingCount returns the device's count of readings of the given
+// kind, i.e., flagged or rejected, or zero if there are none.
+func readingCount(ctx context.Context, dev model.Device, kind string) int {
+	v, err := model.GetVariable(ctx, settingsStore, dev.Skey, "_"+dev.Hex()+"."+kind)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(v.Value)
+	return n
 }
 
 // secondsToUptime converts the uptime variable of a device to a formatted
@@ -28,6 +28,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ausocean/cloud/model"
 )
@@ -65,3 +66,23 @@ func TestSecondsToUptime(t *testing.T) {
 		}
 	}
 }
+
+func TestSendingStatus(t *testing.T) {
+	now := time.Now()
+	dev := model.Device{MonitorPeriod: 60}
+	var tests = []struct {
+		updated time.Time
+		want    string
+	}{
+		{updated: now, want: "green"},
+		{updated: now.Add(-119 * time.Second), want: "green"},
+		{updated: now.Add(-120 * time.Second), want: "red"},
+		{updated: now.Add(-time.Hour), want: "red"},
+	}
+	for i, test := range tests {
+		got := sendingStatus(dev, &model.Variable{Updated: test.updated}, now)
+		if got != test.want {
+			t.Errorf("did not get expected result for test no. %d \ngot: %s \nwant: %s", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// Settings pages and settings API responses carry an ETag, so that
+// clients, including the service worker (s/sw.js) which keeps recently
+// viewed settings readable offline, can revalidate them cheaply with
+// conditional requests.
+
+// idempotencyHeader is the request header carrying the idempotency
+// key of a settings edit replayed by the service worker.
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKey is the maximum length of an idempotency key.
+const maxIdempotencyKey = 64
+
+// idempotent wraps a settings edit handler so that an edit queued
+// offline is applied only once, even if the service worker replays it
+// more than once, e.g., when connectivity is lost again before the
+// response to a replay arrives. Replays of an applied edit succeed
+// without applying it again. Edits without an idempotency key are
+// handled as usual.
+func idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeHttpError(w, http.StatusBadRequest, "idempotency key too long")
+			return
+		}
+
+		ctx := r.Context()
+		setup(ctx)
+		err := model.ClaimQueuedEdit(ctx, settingsStore, key)
+		switch {
+		case errors.Is(err, model.ErrQueuedEditApplied):
+			log.Printf("queued edit %s to %s already applied", key, r.URL.Path)
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "already applied")
+			return
+		case err != nil:
+			// Apply the edit anyway, since it is more likely to be new than replayed.
+			log.Printf("could not claim queued edit %s: %v", key, err)
+			h(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h(sw, r)
+		if sw.status < http.StatusInternalServerError {
+			return
+		}
+		// Release the claim, so that the edit can be replayed.
+		err = model.DeleteQueuedEdit(ctx, settingsStore, key)
+		if err != nil {
+			log.Printf("could not release queued edit %s: %v", key, err)
+		}
+	}
+}
+
+// statusWriter is an http.ResponseWriter that records the status written.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status, then writes it.
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// writeETagged writes data with an ETag derived from its content, or
+// writes 304 Not Modified if the request's If-None-Match header
+// matches. Responses are private and must be revalidated before reuse.
+func writeETagged(w http.ResponseWriter, r *http.Request, data []byte) {
+	writeCached(w, r, data, "private, no-cache")
+}
+
+// writeCached writes data with an ETag, like writeETagged, and the
+// given Cache-Control header.
+func writeCached(w http.ResponseWriter, r *http.Request, data []byte, cacheControl string) {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", cacheControl)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(data)
+}
+
+// etagMatch returns true if an If-None-Match header value matches
+// etag. Matching is weak, i.e., W/ prefixes are ignored.
+func etagMatch(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceWorkerHandler serves the service worker from the root, since
+// a service worker only controls pages within its own path.
+func serviceWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, "s/sw.js")
+}
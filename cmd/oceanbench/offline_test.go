@@ -0,0 +1,60 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteETagged(t *testing.T) {
+	data := []byte(`{"Skey":1}`)
+	w := httptest.NewRecorder()
+	writeETagged(w, httptest.NewRequest("GET", "/api/get/site/1", nil), data)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.String() != string(data) {
+		t.Fatalf("unconditional request: got status %d, ETag %q, body %q", w.Code, etag, w.Body.String())
+	}
+
+	tests := []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{`"stale", ` + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"stale"`, http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/get/site/1", nil)
+		r.Header.Set("If-None-Match", test.ifNoneMatch)
+		w := httptest.NewRecorder()
+		writeETagged(w, r, data)
+		if w.Code != test.want {
+			t.Errorf("If-None-Match %s: got status %d, want %d", test.ifNoneMatch, w.Code, test.want)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: got body with 304", test.ifNoneMatch)
+		}
+	}
+}
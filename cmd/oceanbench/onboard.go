@@ -0,0 +1,473 @@
+/*
+DESCRIPTION
+  Ocean Bench site onboarding, which creates a functional site in one
+  flow, i.e., the site itself, its users, its first device and,
+  optionally, a standard broadcast and sunrise/sunset crons.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/system"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Onboarding defaults.
+const (
+	onboardNotifyPeriod   = 24 // Hours between notifications.
+	onboardBroadcastStart = 7  // Local hour at which the standard broadcast starts.
+	onboardBroadcastEnd   = 17 // Local hour at which the standard broadcast ends.
+	onboardResolution     = "1080p"
+	onboardPrivacy        = "unlisted"
+	onboardDaylightVar    = "Daylight" // Site variable set by the sunrise/sunset crons.
+)
+
+// onboardRoles maps user role names to permissions.
+var onboardRoles = map[string]int64{
+	"read":  model.ReadPermission,
+	"write": model.ReadPermission | model.WritePermission,
+	"admin": model.ReadPermission | model.WritePermission | model.AdminPermission,
+}
+
+// onboardUser is a user to be added to an onboarded site.
+type onboardUser struct {
+	Email string
+	Role  string // One of read, write or admin, defaulting to read.
+}
+
+// onboardDevice is the first device of an onboarded site.
+type onboardDevice struct {
+	Name string
+	MAC  string
+	Type string // One of devTypes.
+	Wifi string // Comma-separated SSID and password, if any.
+}
+
+// onboardRequest holds the details of a site to be onboarded.
+type onboardRequest struct {
+	Name         string
+	Description  string
+	TimezoneName string // IANA timezone, defaulting to model.DefaultTimezoneName.
+	Latitude     float64
+	Longitude    float64
+	OpsEmail     string
+	Users        []onboardUser
+	Device       *onboardDevice // Optional first device.
+	Broadcast    bool           // True to create a standard broadcast.
+	SunCrons     bool           // True to create sunrise and sunset crons.
+}
+
+// onboardResult describes what was created for an onboarded site.
+// Warnings report steps that failed after the site was created, which
+// can be completed from the individual settings pages.
+type onboardResult struct {
+	Skey      int64
+	Name      string
+	Users     []string
+	Device    string `json:",omitempty"` // MAC address.
+	Dkey      int64  `json:",omitempty"`
+	Broadcast string `json:",omitempty"`
+	Crons     []string
+	Warnings  []string
+}
+
+// validate checks an onboarding request, applying defaults.
+func (req *onboardRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("empty site name")
+	}
+	if req.TimezoneName == "" {
+		req.TimezoneName = model.DefaultTimezoneName
+	}
+	_, err := time.LoadLocation(req.TimezoneName)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", req.TimezoneName, err)
+	}
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		return model.ErrInvalidLocation
+	}
+	if req.SunCrons && req.Latitude == 0 && req.Longitude == 0 {
+		return errors.New("sunrise and sunset crons require a location")
+	}
+	for i, u := range req.Users {
+		if !strings.Contains(u.Email, "@") {
+			return fmt.Errorf("invalid user email %q", u.Email)
+		}
+		if u.Role == "" {
+			req.Users[i].Role = "read"
+		} else if _, ok := onboardRoles[u.Role]; !ok {
+			return fmt.Errorf("invalid role %q for user %s", u.Role, u.Email)
+		}
+	}
+	if req.Device != nil {
+		if req.Device.Name == "" {
+			return errors.New("empty device name")
+		}
+		if !model.IsMacAddress(req.Device.MAC) {
+			return model.ErrInvalidMACAddress
+		}
+		if !slices.Contains(devTypes, req.Device.Type) {
+			return model.ErrInvalidDevType
+		}
+	}
+	return nil
+}
+
+// parseOnboardForm parses an onboarding request from the wizard form,
+// whose users are given one per line as email[,role].
+func parseOnboardForm(r *http.Request) (*onboardRequest, error) {
+	req := &onboardRequest{
+		Name:         r.FormValue("sn"),
+		Description:  r.FormValue("sd"),
+		TimezoneName: strings.TrimSpace(r.FormValue("tzn")),
+		OpsEmail:     strings.TrimSpace(r.FormValue("ops")),
+		Broadcast:    r.FormValue("bc") != "",
+		SunCrons:     r.FormValue("sc") != "",
+	}
+	if ll := r.FormValue("ll"); ll != "" {
+		loc, err := parseLocation(ll)
+		if err != nil {
+			return nil, err
+		}
+		req.Latitude, req.Longitude = loc.Lat, loc.Lng
+	}
+	for _, line := range strings.Split(r.FormValue("users"), "\n") {
+		email, role, _ := strings.Cut(strings.TrimSpace(line), ",")
+		if email == "" {
+			continue
+		}
+		req.Users = append(req.Users, onboardUser{Email: strings.TrimSpace(email), Role: strings.TrimSpace(role)})
+	}
+	if ma := strings.TrimSpace(r.FormValue("ma")); ma != "" {
+		req.Device = &onboardDevice{Name: r.FormValue("dn"), MAC: ma, Type: r.FormValue("dt"), Wifi: r.FormValue("wi")}
+	}
+	return req, nil
+}
+
+// onboardSite creates a site and its admin, users, first device,
+// broadcast config and crons in the datastore. The broadcast and crons
+// are not scheduled; see scheduleOnboarded.
+func onboardSite(ctx context.Context, store datastore.Store, admin string, req *onboardRequest) (*onboardResult, *BroadcastConfig, []*model.Cron, error) {
+	err := req.validate()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if req.Device != nil {
+		_, err = model.GetDevice(ctx, store, model.MacEncode(req.Device.MAC))
+		if err == nil {
+			return nil, nil, nil, fmt.Errorf("device %s already exists", req.Device.MAC)
+		}
+	}
+
+	loc, _ := time.LoadLocation(req.TimezoneName)
+	_, offset := time.Now().In(loc).Zone()
+	site := model.Site{
+		Name:         req.Name,
+		Description:  req.Description,
+		OwnerEmail:   admin,
+		OpsEmail:     req.OpsEmail,
+		Latitude:     req.Latitude,
+		Longitude:    req.Longitude,
+		Timezone:     float64(offset) / 3600,
+		TimezoneName: req.TimezoneName,
+		NotifyPeriod: onboardNotifyPeriod,
+		Enabled:      true,
+		Created:      time.Now(),
+	}
+	for {
+		// Create a random 31-bit number that is at least 10 digits.
+		site.Skey = rand.Int63n((1<<31)-1000000000) + 1000000000
+		err = model.CreateSite(ctx, store, &site)
+		if err == nil {
+			break
+		}
+		if err != datastore.ErrEntityExists {
+			return nil, nil, nil, fmt.Errorf("cannot create site: %w", err)
+		}
+	}
+	res := &onboardResult{Skey: site.Skey, Name: site.Name}
+
+	users := append([]onboardUser{{Email: admin, Role: "admin"}}, req.Users...)
+	for i, u := range users {
+		if i > 0 && u.Email == admin {
+			continue // The admin keeps their admin role.
+		}
+		user := model.User{Skey: site.Skey, Email: u.Email, Perm: onboardRoles[u.Role], Created: time.Now()}
+		err = model.PutUser(ctx, store, &user)
+		if err != nil {
+			return res, nil, nil, fmt.Errorf("cannot create user %s: %w", u.Email, err)
+		}
+		res.Users = append(res.Users, u.Email)
+	}
+
+	if req.Device != nil {
+		dev, err := onboardDeviceFor(ctx, store, site, req.Device)
+		if err != nil {
+			return res, nil, nil, err
+		}
+		res.Device, res.Dkey = dev.MAC(), dev.Dkey
+	}
+
+	var cfg *BroadcastConfig
+	if req.Broadcast {
+		cfg = standardBroadcast(site, req.Device, time.Now().In(loc))
+		res.Broadcast = cfg.Name
+	}
+
+	var crons []*model.Cron
+	if req.SunCrons {
+		crons = []*model.Cron{
+			{Skey: site.Skey, ID: "Sunrise", TOD: "@sunrise", Action: "set", Var: onboardDaylightVar, Data: "true", Enabled: true},
+			{Skey: site.Skey, ID: "Sunset", TOD: "@sunset", Action: "set", Var: onboardDaylightVar, Data: "false", Enabled: true},
+		}
+		for _, c := range crons {
+			err = model.PutCron(ctx, store, c)
+			if err != nil {
+				return res, cfg, nil, fmt.Errorf("cannot create cron %s: %w", c.ID, err)
+			}
+			res.Crons = append(res.Crons, c.ID)
+		}
+	}
+	return res, cfg, crons, nil
+}
+
+// onboardDeviceFor creates the first device of a site. Controllers are
+// created as rig systems with default sensors, actuators and
+// variables. Devices are created with a random key and set to update,
+// so that they fetch their configuration when they first connect.
+func onboardDeviceFor(ctx context.Context, store datastore.Store, site model.Site, d *onboardDevice) (*model.Device, error) {
+	dkey, err := model.NewDeviceKey()
+	if err != nil {
+		return nil, err
+	}
+	var ssid, pass string
+	if wifi := strings.Split(d.Wifi, ","); len(wifi) == 2 {
+		ssid, pass = wifi[0], wifi[1]
+	}
+
+	if d.Type == model.DevTypeController {
+		sys, err := system.NewRigSystem(site.Skey, d.MAC, d.Name,
+			system.WithRigSystemDefaults(),
+			system.WithWifi(ssid, pass),
+			system.WithLocation(site.Latitude, site.Longitude),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create rig system: %w", err)
+		}
+		sys.Controller.Dkey = dkey
+		sys.Controller.Status = model.DeviceStatusUpdate
+		err = system.PutRigSystem(ctx, store, sys)
+		if err != nil {
+			return nil, fmt.Errorf("cannot put rig system: %w", err)
+		}
+		return &sys.Controller, nil
+	}
+
+	dev := &model.Device{
+		Skey:          site.Skey,
+		Mac:           model.MacEncode(d.MAC),
+		Name:          d.Name,
+		Dkey:          dkey,
+		Type:          d.Type,
+		Wifi:          d.Wifi,
+		MonitorPeriod: 60,
+		ActPeriod:     60,
+		Latitude:      site.Latitude,
+		Longitude:     site.Longitude,
+		Status:        model.DeviceStatusUpdate,
+		Enabled:       true,
+	}
+	err = model.PutDevice(ctx, store, dev)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create device: %w", err)
+	}
+	return dev, nil
+}
+
+// standardBroadcast returns the standard daytime broadcast of a site,
+// using its first device as the camera or controller. Broadcasts are
+// created disabled, since they require a YouTube account before they
+// can be started.
+func standardBroadcast(site model.Site, d *onboardDevice, now time.Time) *BroadcastConfig {
+	y, m, day := now.Date()
+	cfg := &BroadcastConfig{
+		SKey:           site.Skey,
+		Name:           site.Name + " Live",
+		Description:    defaultMessage,
+		Privacy:        onboardPrivacy,
+		Resolution:     onboardResolution,
+		Start:          time.Date(y, m, day, onboardBroadcastStart, 0, 0, 0, now.Location()),
+		End:            time.Date(y, m, day, onboardBroadcastEnd, 0, 0, 0, now.Location()),
+		CheckingHealth: true,
+	}
+	cfg.StartTimestamp = strconv.FormatInt(cfg.Start.Unix(), 10)
+	cfg.EndTimestamp = strconv.FormatInt(cfg.End.Unix(), 10)
+	if d != nil {
+		switch d.Type {
+		case model.DevTypeCamera:
+			cfg.CameraMac = model.MacEncode(d.MAC)
+		case model.DevTypeController:
+			cfg.ControllerMAC = model.MacEncode(d.MAC)
+		}
+	}
+	return cfg
+}
+
+// scheduleOnboarded saves an onboarded site's broadcast with the
+// broadcast manager and schedules its crons, recording failures as
+// warnings, since the site is usable without them.
+func scheduleOnboarded(ctx context.Context, res *onboardResult, cfg *BroadcastConfig, crons []*model.Cron) {
+	if cfg != nil {
+		_, err := saveBroadcast(ctx, cfg)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("could not save broadcast: %v", err))
+		}
+	}
+	for _, c := range crons {
+		err := cronScheduler.Set(c)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("could not schedule cron %s: %v", c.ID, err))
+		}
+	}
+	for _, w := range res.Warnings {
+		log.Printf("onboarding site %d: %s", res.Skey, w)
+	}
+}
+
+// onboard onboards a site for the admin, returning what was created.
+func onboard(ctx context.Context, admin string, req *onboardRequest) (*onboardResult, error) {
+	res, cfg, crons, err := onboardSite(ctx, settingsStore, admin, req)
+	if err != nil {
+		if res != nil {
+			return res, fmt.Errorf("site %d partially onboarded: %w", res.Skey, err)
+		}
+		return nil, err
+	}
+	scheduleOnboarded(ctx, res, cfg, crons)
+	return res, nil
+}
+
+// onboardData holds the data served to the onboarding wizard page.
+type onboardData struct {
+	DevTypes []string
+	Timezone string
+	Result   *onboardResult
+	commonData
+}
+
+// onboardHandler serves the onboarding wizard and handles its
+// submission, selecting the new site on success. Onboarding requires
+// the user to be a super admin.
+func onboardHandler(w http.ResponseWriter, r *http.Request, p *gauth.Profile) {
+	data := onboardData{
+		DevTypes: devTypes,
+		Timezone: model.DefaultTimezoneName,
+		commonData: commonData{
+			Pages:   pages("admin"),
+			Profile: p,
+		},
+	}
+
+	switch r.Method {
+	case "GET":
+		writeTemplate(w, r, "onboard.html", &data, "")
+
+	case "POST":
+		req, err := parseOnboardForm(r)
+		if err != nil {
+			writeTemplate(w, r, "onboard.html", &data, err.Error())
+			return
+		}
+		data.Result, err = onboard(r.Context(), p.Email, req)
+		if err != nil {
+			writeTemplate(w, r, "onboard.html", &data, err.Error())
+			return
+		}
+		putProfileData(w, r, strconv.FormatInt(data.Result.Skey, 10)+":"+data.Result.Name)
+		writeTemplate(w, r, "onboard.html", &data, strings.Join(data.Result.Warnings, "; "))
+
+	default:
+		http.Redirect(w, r, "/", http.StatusMethodNotAllowed)
+	}
+}
+
+// onboardAPIHandler handles onboarding requests sent as JSON, i.e., an
+// onboardRequest, responding with an onboardResult. Onboarding
+// requires the user to be a super admin.
+func onboardAPIHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	p, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		writeHttpError(w, http.StatusUnauthorized, "user could not be authenticated: %v", err)
+		return
+	}
+	if !isSuperAdmin(p.Email) {
+		writeHttpError(w, http.StatusForbidden, "onboarding requires super admin")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeHttpError(w, http.StatusMethodNotAllowed, "onboarding requires POST")
+		return
+	}
+
+	ctx := r.Context()
+	setup(ctx)
+	var req onboardRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeHttpError(w, http.StatusBadRequest, "could not decode onboarding request: %v", err)
+		return
+	}
+	res, err := onboard(ctx, p.Email, &req)
+	if err != nil && res == nil {
+		writeHttpError(w, http.StatusBadRequest, "could not onboard site: %v", err)
+		return
+	}
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not onboard site: %v", err)
+		return
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not marshal onboarding result: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,117 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestOnboardValidate(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  onboardRequest
+		ok   bool
+	}{
+		{desc: "name only", req: onboardRequest{Name: "Rapid Bay"}, ok: true},
+		{desc: "no name", req: onboardRequest{Name: " "}},
+		{desc: "bad timezone", req: onboardRequest{Name: "Rapid Bay", TimezoneName: "Mars/Olympus"}},
+		{desc: "crons without location", req: onboardRequest{Name: "Rapid Bay", SunCrons: true}},
+		{desc: "bad role", req: onboardRequest{Name: "Rapid Bay", Users: []onboardUser{{Email: "a@b.org", Role: "owner"}}}},
+		{desc: "bad email", req: onboardRequest{Name: "Rapid Bay", Users: []onboardUser{{Email: "ab.org"}}}},
+		{desc: "bad MAC", req: onboardRequest{Name: "Rapid Bay", Device: &onboardDevice{Name: "Camera", MAC: "00:00", Type: model.DevTypeCamera}}},
+		{desc: "bad type", req: onboardRequest{Name: "Rapid Bay", Device: &onboardDevice{Name: "Camera", MAC: "0A:00:00:00:00:01", Type: "robot"}}},
+	}
+
+	for _, test := range tests {
+		err := test.req.validate()
+		if (err == nil) != test.ok {
+			t.Errorf("%s: validate returned %v, expected ok %t", test.desc, err, test.ok)
+		}
+	}
+}
+
+func TestOnboardSite(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	const admin = "admin@ausocean.org"
+	req := &onboardRequest{
+		Name:      "Rapid Bay",
+		Latitude:  -35.5,
+		Longitude: 138.2,
+		Users:     []onboardUser{{Email: "viewer@ausocean.org"}, {Email: admin, Role: "read"}},
+		Device:    &onboardDevice{Name: "Camera", MAC: "0A:00:00:00:00:01", Type: model.DevTypeCamera},
+		Broadcast: true,
+		SunCrons:  true,
+	}
+	res, cfg, crons, err := onboardSite(ctx, store, admin, req)
+	if err != nil {
+		t.Fatalf("onboardSite returned error: %v", err)
+	}
+
+	site, err := model.GetSite(ctx, store, res.Skey)
+	if err != nil {
+		t.Fatalf("could not get site: %v", err)
+	}
+	if site.Name != req.Name || site.TimezoneName != model.DefaultTimezoneName || !site.Enabled || site.Public {
+		t.Errorf("got site %+v", site)
+	}
+
+	wantPerms := map[string]int64{admin: onboardRoles["admin"], "viewer@ausocean.org": model.ReadPermission}
+	for email, perm := range wantPerms {
+		user, err := model.GetUser(ctx, store, res.Skey, email)
+		if err != nil {
+			t.Fatalf("could not get user %s: %v", email, err)
+		}
+		if user.Perm != perm {
+			t.Errorf("got perm %d for %s, expected %d", user.Perm, email, perm)
+		}
+	}
+
+	dev, err := model.GetDevice(ctx, store, model.MacEncode(req.Device.MAC))
+	if err != nil {
+		t.Fatalf("could not get device: %v", err)
+	}
+	if dev.Skey != res.Skey || dev.Dkey != res.Dkey || dev.Status != model.DeviceStatusUpdate {
+		t.Errorf("got device %+v", dev)
+	}
+
+	if cfg == nil || cfg.CameraMac != dev.Mac || cfg.Enabled || !cfg.End.After(cfg.Start) {
+		t.Errorf("got broadcast %+v", cfg)
+	}
+	if len(crons) != 2 || len(res.Crons) != 2 {
+		t.Errorf("got %d crons, expected 2", len(crons))
+	}
+
+	// Onboarding the same device again fails without creating a site.
+	res, _, _, err = onboardSite(ctx, store, admin, req)
+	if err == nil || res != nil {
+		t.Errorf("onboardSite with existing device returned %v, %v", res, err)
+	}
+}
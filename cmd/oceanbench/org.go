@@ -0,0 +1,144 @@
+/*
+DESCRIPTION
+  Ocean Bench organization administration, which allows admins of an
+  organization to manage its members and view its sites and devices.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+var errNotOrgMember = errors.New("not a member of organization")
+
+// orgData is the organization data returned by the API.
+type orgData struct {
+	Organization *model.Organization
+	Members      []model.OrgMember
+	Sites        []minimalSite
+	Devices      int // Number of devices across all sites.
+}
+
+// canViewOrg returns true if the user is a member of the organization
+// or a super admin.
+func canViewOrg(ctx context.Context, id, email string) bool {
+	if isSuperAdmin(email) {
+		return true
+	}
+	_, err := model.GetOrgMember(ctx, settingsStore, id, email)
+	return err == nil
+}
+
+// canAdminOrg returns true if the user is an admin of the organization
+// or a super admin.
+func canAdminOrg(ctx context.Context, id, email string) bool {
+	return isSuperAdmin(email) || model.IsOrgAdmin(ctx, settingsStore, id, email)
+}
+
+// getOrgData returns an organization with its members, sites and
+// device count, if the user may view it.
+func getOrgData(ctx context.Context, id, email string) (*orgData, error) {
+	if !canViewOrg(ctx, id, email) {
+		return nil, errNotOrgMember
+	}
+	org, err := model.GetOrganization(ctx, settingsStore, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get organization: %w", err)
+	}
+	members, err := model.GetOrgMembers(ctx, settingsStore, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get members: %w", err)
+	}
+	sites, err := model.GetOrgSites(ctx, settingsStore, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sites: %w", err)
+	}
+	devs, err := model.GetOrgDevices(ctx, settingsStore, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get devices: %w", err)
+	}
+
+	// Org admins administer all of the organization's sites, so only
+	// the users of other members need be read.
+	orgAdmin := model.IsOrgAdmin(ctx, settingsStore, id, email)
+	data := &orgData{Organization: org, Members: members, Devices: len(devs)}
+	for _, s := range sites {
+		var perm int64
+		if orgAdmin {
+			perm = model.ReadPermission | model.WritePermission | model.AdminPermission
+		} else if u, err := model.GetUser(ctx, settingsStore, s.Skey, email); err == nil {
+			perm = u.Perm
+		}
+		data.Sites = append(data.Sites, minimalSite{s.Skey, perm, s.Name, s.Public})
+	}
+	return data, nil
+}
+
+// checkSiteOrg returns an error unless the user may add a site to the
+// organization, i.e., is a member of it or a super admin. Members are
+// added by the organization's admins, so sites cannot be added to an
+// organization without its consent. Only super admins may add sites to
+// an organization that does not exist, which creates it.
+func checkSiteOrg(ctx context.Context, id, email string) error {
+	if isSuperAdmin(email) {
+		_, err := model.EnsureOrganization(ctx, settingsStore, id)
+		return err
+	}
+	_, err := model.GetOrgMember(ctx, settingsStore, id, email)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return errNotOrgMember
+	}
+	return err
+}
+
+// setOrgMember sets the role of a member of an organization, or
+// removes the member when role is "none", if the user is an admin of
+// the organization. Admins cannot change their own role, so that an
+// organization is not left without an admin.
+func setOrgMember(ctx context.Context, id, member, role, email string) error {
+	if !canAdminOrg(ctx, id, email) {
+		return errNotOrgMember
+	}
+	if member == email && !isSuperAdmin(email) {
+		return errors.New("cannot change own role")
+	}
+	if role == "none" {
+		err := model.DeleteOrgMember(ctx, settingsStore, id, member)
+		if err != nil && !errors.Is(err, datastore.ErrNoSuchEntity) {
+			return fmt.Errorf("could not delete member: %w", err)
+		}
+		return nil
+	}
+	m, err := model.GetOrgMember(ctx, settingsStore, id, member)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		m = &model.OrgMember{OrgID: id, Email: member}
+	} else if err != nil {
+		return fmt.Errorf("could not get member: %w", err)
+	}
+	m.Role = role
+	return model.PutOrgMember(ctx, settingsStore, m)
+}
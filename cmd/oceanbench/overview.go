@@ -0,0 +1,189 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// maxOverviewWorkers is the maximum number of sites summarized concurrently.
+const maxOverviewWorkers = 8
+
+// siteOverview summarizes the state of a site for the overview page.
+type siteOverview struct {
+	Skey       int64
+	Name       string
+	Devices    []deviceOverview
+	Sending    int      // Number of devices sending.
+	Stopped    int      // Number of devices that have stopped sending.
+	Unreported int      // Number of devices that have never reported.
+	Broadcasts []string // Names of active broadcasts.
+	Alerts     []alertOverview
+	Error      string `json:",omitempty"` // Set if the site could not be summarized.
+}
+
+// deviceOverview summarizes the state of a device for the overview page.
+type deviceOverview struct {
+	Name       string
+	MAC        string
+	StatusText string
+	Sending    string // Sending status, i.e., green, red or black.
+	Sensors    []sensorData
+}
+
+// alertOverview summarizes an unacknowledged alert for the overview page.
+type alertOverview struct {
+	Kind    string
+	Subject string
+	Created time.Time
+}
+
+// overviewHandler handles the overview page, which summarizes every
+// site that the user has access to. The page is populated from the
+// /api/get/overview/sites endpoint, since summarizing many sites can
+// take some time.
+func overviewHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+
+	data := commonData{Pages: pages("overview"), Profile: profile}
+	writeTemplate(w, r, "overview.html", &data, "")
+}
+
+// siteOverviews returns overviews of the sites for which the user
+// with the given email has read permission, ordered by name. Sites
+// are summarized concurrently. A site that cannot be summarized has
+// its Error set, rather than failing the entire request.
+func siteOverviews(ctx context.Context, email string) ([]siteOverview, error) {
+	users, err := model.GetUsers(ctx, settingsStore, email)
+	if err != nil {
+		return nil, fmt.Errorf("could not get users: %w", err)
+	}
+
+	// Get unacknowledged alerts for all sites at once, rather than per site.
+	alerts, err := model.GetUnackedAlerts(ctx, settingsStore)
+	if err != nil {
+		return nil, fmt.Errorf("could not get alerts: %w", err)
+	}
+	siteAlerts := make(map[int64][]alertOverview)
+	for _, a := range alerts {
+		siteAlerts[a.Skey] = append(siteAlerts[a.Skey], alertOverview{Kind: a.Kind, Subject: a.Subject, Created: a.Created})
+	}
+
+	var skeys []int64
+	for _, u := range users {
+		if u.Perm&model.ReadPermission != 0 {
+			skeys = append(skeys, u.Skey)
+		}
+	}
+
+	overviews := make([]siteOverview, len(skeys))
+	sem := make(chan struct{}, maxOverviewWorkers)
+	var wg sync.WaitGroup
+	for i, skey := range skeys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			so := &overviews[i]
+			so.Skey = skey
+			so.Alerts = siteAlerts[skey]
+			err := summarizeSite(ctx, so)
+			if err != nil {
+				log.Printf("could not summarize site %d: %v", skey, err)
+				so.Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(overviews, func(i, j int) bool { return overviews[i].Name < overviews[j].Name })
+	return overviews, nil
+}
+
+// summarizeSite summarizes the devices and broadcasts of the site
+// with the given overview's site key.
+func summarizeSite(ctx context.Context, so *siteOverview) error {
+	site, err := model.GetSite(ctx, settingsStore, so.Skey)
+	if err != nil {
+		return fmt.Errorf("could not get site: %w", err)
+	}
+	so.Name = site.Name
+
+	devices, err := model.GetDevicesBySite(ctx, settingsStore, so.Skey)
+	if err != nil {
+		return fmt.Errorf("could not get devices: %w", err)
+	}
+	now := time.Now()
+	for _, dev := range devices {
+		do := deviceOverview{Name: dev.Name, MAC: dev.MAC(), StatusText: dev.StatusText()}
+		v, err := model.GetVariable(ctx, settingsStore, dev.Skey, "_"+dev.Hex()+".uptime")
+		switch {
+		case errors.Is(err, datastore.ErrNoSuchEntity):
+			do.Sending = "black"
+			so.Unreported++
+		case err != nil:
+			return fmt.Errorf("could not get uptime variable: %w", err)
+		default:
+			do.Sending = sendingStatus(dev, v, now)
+			if do.Sending == "green" {
+				so.Sending++
+			} else {
+				so.Stopped++
+			}
+		}
+		do.Sensors, err = latestSensors(ctx, dev, site.Timezone)
+		if err != nil {
+			return err
+		}
+		so.Devices = append(so.Devices, do)
+	}
+
+	cfgs, err := model.GetBroadcastConfigs(ctx, settingsStore, so.Skey)
+	if err != nil {
+		return fmt.Errorf("could not get broadcasts: %w", err)
+	}
+	for _, cfg := range cfgs {
+		if cfg.Active {
+			so.Broadcasts = append(so.Broadcasts, cfg.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,420 @@
+/*
+DESCRIPTION
+  Ocean Bench public data portal, which presents the latest imagery,
+  sensor charts and stream links of public sites without login.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ausocean/av/container/mts"
+	"github.com/ausocean/cloud/model"
+)
+
+// Portal responses are the same for all users, so are cached publicly.
+const (
+	portalPageCache   = "public, max-age=60"
+	portalSensorCache = "public, max-age=300"
+	portalImageCache  = "public, max-age=60"
+)
+
+// Portal limits.
+const (
+	portalMediaWindow  = time.Hour // Period within which media is considered latest.
+	portalDefaultHours = 24        // Default period of sensor charts.
+	portalMaxHours     = 7 * 24    // Maximum period of sensor charts.
+	portalMaxPoints    = 288       // Maximum points per sensor chart.
+)
+
+// portalSensorTTL is the time for which sensor chart data is cached
+// per instance.
+const portalSensorTTL = 5 * time.Minute
+
+var errSiteNotPublic = errors.New("site not found")
+
+// portalSensorsKey identifies cached sensor chart data by site and hours.
+type portalSensorsKey struct {
+	skey  int64
+	hours int
+}
+
+// cachedPortalSensors is cached sensor chart data and when it expires.
+type cachedPortalSensors struct {
+	data    []byte
+	expires time.Time
+}
+
+// portalSensorsCache caches sensor chart data, which reads all the
+// scalars of a site's sensors over the chart period, so that popular
+// portals do not repeat those reads for every visitor. The public
+// Cache-Control header only helps when there is a shared cache.
+var portalSensorsCache = struct {
+	sync.Mutex
+	data map[portalSensorsKey]cachedPortalSensors
+}{data: map[portalSensorsKey]cachedPortalSensors{}}
+
+// portalSite is the public subset of a site.
+type portalSite struct {
+	Skey        int64
+	Name        string
+	Description string
+	Latitude    float64
+	Longitude   float64
+	Timezone    float64
+}
+
+// portalCamera is a camera pin of a public site.
+type portalCamera struct {
+	Name  string // Device name.
+	MID   int64  // Media ID of the pin.
+	Image bool   // True if the latest media is an image.
+	Time  int64  // Timestamp of the latest media, or zero if none.
+}
+
+// portalStream is a live stream of a public site.
+type portalStream struct {
+	Name string
+	URL  string
+}
+
+// portalSensor is the chart data of a sensor of a public site.
+type portalSensor struct {
+	Name   string
+	Device string
+	Units  string
+	Values [][2]float64 // Pairs of timestamp and transformed value.
+}
+
+// portalData holds the data served to the portal pages. The data must
+// not depend on the user, since portal pages are cached publicly.
+type portalData struct {
+	Version string
+	Site    *portalSite
+	Sites   []portalSite
+	Cameras []portalCamera
+	Streams []portalStream
+}
+
+// portalHandler handles public portal requests, which are read only
+// and do not require login:
+//
+//	/portal/                        list of public sites
+//	/portal/<skey>                  site page
+//	/portal/<skey>/sensors?h=hours  sensor chart data as JSON
+//	/portal/<skey>/latest/<mid>     latest image of a camera pin
+//
+// Private, disabled and missing sites are all reported as not found.
+func portalHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeHttpError(w, http.StatusMethodNotAllowed, "portal is read only")
+		return
+	}
+
+	ctx := r.Context()
+	setup(ctx)
+
+	req := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/portal"), "/"), "/")
+	if req[0] == "" {
+		writePortalSites(w, r)
+		return
+	}
+
+	skey, err := strconv.ParseInt(req[0], 10, 64)
+	if err != nil {
+		writeHttpError(w, http.StatusNotFound, errSiteNotPublic.Error())
+		return
+	}
+	site, err := publicSite(ctx, skey)
+	if err != nil {
+		writeHttpError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	switch {
+	case len(req) == 1:
+		writePortalSite(w, r, site)
+	case len(req) == 2 && req[1] == "sensors":
+		writePortalSensors(w, r, site)
+	case len(req) == 3 && req[1] == "latest":
+		mid, err := strconv.ParseInt(req[2], 10, 64)
+		if err != nil {
+			writeHttpError(w, http.StatusNotFound, "invalid media ID")
+			return
+		}
+		writePortalImage(w, r, site, mid)
+	default:
+		writeHttpError(w, http.StatusNotFound, "invalid portal path")
+	}
+}
+
+// publicSite returns a site if it is public and enabled, or
+// errSiteNotPublic otherwise.
+func publicSite(ctx context.Context, skey int64) (*model.Site, error) {
+	site, err := model.GetSite(ctx, settingsStore, skey)
+	if err != nil || !site.Public || !site.Enabled {
+		return nil, errSiteNotPublic
+	}
+	return site, nil
+}
+
+// toPortalSite returns the public subset of a site.
+func toPortalSite(site *model.Site) portalSite {
+	return portalSite{Skey: site.Skey, Name: site.Name, Description: site.Description, Latitude: site.Latitude, Longitude: site.Longitude, Timezone: site.Timezone}
+}
+
+// writePortalSites writes the list of public sites.
+func writePortalSites(w http.ResponseWriter, r *http.Request) {
+	sites, err := model.GetPublicSites(r.Context(), settingsStore)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not get public sites: %v", err)
+		return
+	}
+	data := portalData{Version: version}
+	for i := range sites {
+		if sites[i].Enabled {
+			data.Sites = append(data.Sites, toPortalSite(&sites[i]))
+		}
+	}
+	writePortal(w, r, &data)
+}
+
+// writePortalSite writes the page of a public site.
+func writePortalSite(w http.ResponseWriter, r *http.Request, site *model.Site) {
+	ctx := r.Context()
+	ps := toPortalSite(site)
+	data := portalData{Version: version, Site: &ps}
+
+	devs, err := model.GetDevicesBySite(ctx, settingsStore, site.Skey)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not get devices: %v", err)
+		return
+	}
+	for _, dev := range devs {
+		if !dev.Enabled {
+			continue
+		}
+		for _, pin := range dev.InputList() {
+			if !strings.HasPrefix(pin, "V") {
+				continue
+			}
+			cam := portalCamera{Name: dev.Name, MID: model.ToMID(dev.MAC(), pin)}
+			m, err := latestMedia(ctx, cam.MID)
+			if err == nil {
+				cam.Image = strings.HasPrefix(m.Type, "image/")
+				cam.Time = m.Timestamp
+			} else if !errors.Is(err, model.ErrMediaNotFound) {
+				log.Printf("could not get latest media for %d: %v", cam.MID, err)
+			}
+			data.Cameras = append(data.Cameras, cam)
+		}
+	}
+
+	data.Streams, err = portalStreams(ctx, site.Skey)
+	if err != nil {
+		log.Printf("could not get streams for site %d: %v", site.Skey, err)
+	}
+	writePortal(w, r, &data)
+}
+
+// writePortal renders the portal template and writes it with public
+// caching.
+func writePortal(w http.ResponseWriter, r *http.Request, data *portalData) {
+	var b bytes.Buffer
+	err := templates.ExecuteTemplate(&b, "portal.html", data)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not render portal: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writeCached(w, r, b.Bytes(), portalPageCache)
+}
+
+// portalStreams returns the live streams of a site's broadcasts,
+// excluding private and secondary broadcasts.
+func portalStreams(ctx context.Context, skey int64) ([]portalStream, error) {
+	vars, err := model.GetVariablesBySite(ctx, settingsStore, skey, broadcastScope)
+	if err != nil {
+		return nil, err
+	}
+	var streams []portalStream
+	for _, v := range vars {
+		var cfg BroadcastConfig
+		err := json.Unmarshal([]byte(v.Value), &cfg)
+		if err != nil || cfg.Privacy == "private" || strings.HasSuffix(cfg.Name, secondaryBroadcastPostfix) {
+			continue
+		}
+		_, err = model.GetVariable(ctx, settingsStore, -1, liveScope+"."+cfg.Name)
+		if err != nil {
+			continue // Not streamed yet.
+		}
+		streams = append(streams, portalStream{Name: cfg.Name, URL: "/live/" + url.PathEscape(cfg.Name)})
+	}
+	return streams, nil
+}
+
+// latestMedia returns the latest media for a media ID within the
+// portal media window.
+func latestMedia(ctx context.Context, mid int64) (*model.MtsMedia, error) {
+	start := time.Now().Add(-portalMediaWindow).Unix()
+	key, err := model.GetLatestMtsMediaKey(ctx, mediaStore, mid, start)
+	if err != nil {
+		return nil, err
+	}
+	return model.GetMtsMediaByKey(ctx, mediaStore, uint64(key.ID))
+}
+
+// writePortalImage writes the latest image of a camera pin of a
+// public site.
+func writePortalImage(w http.ResponseWriter, r *http.Request, site *model.Site, mid int64) {
+	ctx := r.Context()
+	ma, _ := model.FromMID(mid)
+	dev, err := model.GetDevice(ctx, settingsStore, model.MacEncode(ma))
+	if err != nil || dev.Skey != site.Skey || !dev.Enabled {
+		writeHttpError(w, http.StatusNotFound, "camera not found")
+		return
+	}
+	m, err := latestMedia(ctx, mid)
+	if err != nil || !strings.HasPrefix(m.Type, "image/") {
+		writeHttpError(w, http.StatusNotFound, "no recent image")
+		return
+	}
+	clip, err := mts.Extract(m.Clip)
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, "could not extract image: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", m.Type)
+	writeCached(w, r, clip.Bytes(), portalImageCache)
+}
+
+// writePortalSensors writes the chart data of a public site's
+// sensors, over the number of hours given by the h param. Chart data
+// is cached for portalSensorTTL.
+func writePortalSensors(w http.ResponseWriter, r *http.Request, site *model.Site) {
+	hours := portalDefaultHours
+	if h := r.FormValue("h"); h != "" {
+		n, err := strconv.Atoi(h)
+		if err != nil || n < 1 || n > portalMaxHours {
+			writeHttpError(w, http.StatusBadRequest, "h must be between 1 and %d", portalMaxHours)
+			return
+		}
+		hours = n
+	}
+
+	key := portalSensorsKey{skey: site.Skey, hours: hours}
+	now := time.Now()
+	portalSensorsCache.Lock()
+	c, ok := portalSensorsCache.data[key]
+	portalSensorsCache.Unlock()
+	if !ok || !now.Before(c.expires) {
+		data, err := portalSensors(r.Context(), site, now.Add(-time.Duration(hours)*time.Hour).Unix())
+		if err != nil {
+			writeHttpError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		c = cachedPortalSensors{data: data, expires: now.Add(portalSensorTTL)}
+		portalSensorsCache.Lock()
+		for k, v := range portalSensorsCache.data {
+			if !now.Before(v.expires) {
+				delete(portalSensorsCache.data, k)
+			}
+		}
+		portalSensorsCache.data[key] = c
+		portalSensorsCache.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeCached(w, r, c.data, portalSensorCache)
+}
+
+// portalSensors returns the chart data of a public site's sensors
+// from Unix time start, as JSON.
+func portalSensors(ctx context.Context, site *model.Site, start int64) ([]byte, error) {
+	devs, err := model.GetDevicesBySite(ctx, settingsStore, site.Skey)
+	if err != nil {
+		return nil, fmt.Errorf("could not get devices: %w", err)
+	}
+	sensors := []portalSensor{}
+	for _, dev := range devs {
+		if !dev.Enabled {
+			continue
+		}
+		ss, err := model.GetSensorsV2(ctx, settingsStore, dev.Mac)
+		if err != nil {
+			log.Printf("could not get sensors for device %s: %v", dev.MAC(), err)
+			continue
+		}
+		for i := range ss {
+			if ss[i].Provisional {
+				continue
+			}
+			scalars, err := model.GetScalars(ctx, mediaStore, model.ToSID(dev.MAC(), ss[i].Pin), []int64{start, -1})
+			if err != nil {
+				log.Printf("could not get scalars for %s.%s: %v", dev.MAC(), ss[i].Pin, err)
+				continue
+			}
+			sensors = append(sensors, portalSensor{
+				Name:   ss[i].Name,
+				Device: dev.Name,
+				Units:  ss[i].Units,
+				Values: portalValues(&ss[i], scalars, portalMaxPoints),
+			})
+		}
+	}
+
+	data, err := json.Marshal(sensors)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal sensors: %w", err)
+	}
+	return data, nil
+}
+
+// portalValues returns up to max transformed values of a sensor,
+// evenly sampling the scalars when there are more, and skipping
+// values that cannot be transformed.
+func portalValues(s *model.SensorV2, scalars []model.Scalar, max int) [][2]float64 {
+	step := 1
+	if len(scalars) > max {
+		step = (len(scalars) + max - 1) / max
+	}
+	values := [][2]float64{}
+	for i := 0; i < len(scalars); i += step {
+		v, err := s.Transform(scalars[i].Value)
+		if err != nil {
+			continue
+		}
+		values = append(values, [2]float64{float64(scalars[i].Timestamp), v})
+	}
+	return values
+}
@@ -0,0 +1,98 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+)
+
+func TestPortalValues(t *testing.T) {
+	scalars := make([]model.Scalar, 1000)
+	for i := range scalars {
+		scalars[i] = model.Scalar{Timestamp: int64(i), Value: float64(i)}
+	}
+	s := &model.SensorV2{Func: "scale", Args: "2"}
+
+	values := portalValues(s, scalars, 100)
+	if len(values) != 100 {
+		t.Fatalf("got %d values, expected 100", len(values))
+	}
+	if values[1] != [2]float64{10, 20} {
+		t.Errorf("got second value %v, expected [10 20]", values[1])
+	}
+
+	values = portalValues(s, scalars[:10], 100)
+	if len(values) != 10 {
+		t.Errorf("got %d values, expected 10", len(values))
+	}
+
+	values = portalValues(&model.SensorV2{Func: "unknown"}, scalars[:10], 100)
+	if len(values) != 0 {
+		t.Errorf("got %d values for untransformable sensor, expected 0", len(values))
+	}
+}
+
+func TestPortalTemplate(t *testing.T) {
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseGlob("t/*.html")
+	if err != nil {
+		t.Fatalf("could not parse templates: %v", err)
+	}
+	site := &portalSite{Skey: 1, Name: "Rapid Bay"}
+	tests := []struct {
+		data portalData
+		want string
+	}{
+		{data: portalData{Sites: []portalSite{*site}}, want: `href="/portal/1"`},
+		{
+			data: portalData{
+				Site:    site,
+				Cameras: []portalCamera{{Name: "Camera", MID: 42, Image: true, Time: 1700000000}},
+				Streams: []portalStream{{Name: "Rapid Bay", URL: "/live/Rapid%20Bay"}},
+			},
+			want: `src="/portal/1/latest/42"`,
+		},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		err := tmpl.ExecuteTemplate(&b, "portal.html", &test.data)
+		if err != nil {
+			t.Fatalf("could not execute template: %v", err)
+		}
+		if !strings.Contains(b.String(), test.want) {
+			t.Errorf("portal page does not contain %s", test.want)
+		}
+	}
+}
+
+func TestPortalReadOnly(t *testing.T) {
+	w := httptest.NewRecorder()
+	portalHandler(w, httptest.NewRequest("POST", "/portal/1", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+DESCRIPTION
+  Ocean Bench text retention, which purges text, i.e., logs, GPS and
+  diagnostics, once older than the retention period of its type.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// purgeResult is the response to a text purge request.
+type purgeResult struct {
+	Devices int // Number of devices whose text was purged.
+	Purged  int // Number of texts purged.
+	Errors  int // Number of media IDs that could not be purged.
+}
+
+// purgeTextHandler purges text older than its retention period from
+// all devices. It is invoked daily by App Engine cron, as scheduled
+// by oceanbench_cron.yaml. See cronRequest.
+func purgeTextHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+
+	if !cronRequest(w, r) {
+		return
+	}
+
+	setup(ctx)
+	res, err := purgeText(ctx, time.Now())
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, fmt.Sprintf("purge failed: %v", err))
+		return
+	}
+	log.Printf("purged %d texts from %d devices, with %d errors", res.Purged, res.Devices, res.Errors)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// purgeText purges the text of the text pins of all devices as per
+// the text retentions at time now. Errors purging individual media
+// IDs are logged and counted, so that one bad device does not stop
+// the purge.
+func purgeText(ctx context.Context, now time.Time) (*purgeResult, error) {
+	m, err := textRetentions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &purgeResult{}
+	if len(m) == 0 {
+		return res, nil
+	}
+
+	sites, err := model.GetAllSites(ctx, settingsStore)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sites: %w", err)
+	}
+	for _, site := range sites {
+		devs, err := model.GetDevicesBySite(ctx, settingsStore, site.Skey)
+		if err != nil {
+			log.Printf("could not get devices for site %d: %v", site.Skey, err)
+			res.Errors++
+			continue
+		}
+		for _, dev := range devs {
+			var purged bool
+			for _, pin := range dev.InputList() {
+				if !strings.HasPrefix(pin, "T") {
+					continue
+				}
+				n, err := model.PurgeText(ctx, mediaStore, model.ToMID(dev.MAC(), pin), m, now)
+				if err != nil {
+					log.Printf("could not purge text for %s.%s: %v", dev.MAC(), pin, err)
+					res.Errors++
+				}
+				res.Purged += n
+				purged = true
+			}
+			if purged {
+				res.Devices++
+			}
+		}
+	}
+	return res, nil
+}
+
+// textRetentions returns the configured text retentions.
+func textRetentions(ctx context.Context) (model.TextRetentions, error) {
+	trs, err := model.GetTextRetentions(ctx, settingsStore)
+	if err != nil {
+		return nil, fmt.Errorf("could not get text retentions: %w", err)
+	}
+	return model.NewTextRetentions(trs), nil
+}
+
+// purgeNotificationsHandler deletes notifications older than the
+// notification retention period. It is invoked daily by App Engine
+// cron, as scheduled by oceanbench_cron.yaml. See cronRequest.
+func purgeNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+	if !cronRequest(w, r) {
+		return
+	}
+
+	setup(ctx)
+	n, err := model.DeleteNotifications(ctx, settingsStore, time.Now().Add(-model.NotificationRetention))
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, fmt.Sprintf("purge failed after %d notifications: %v", n, err))
+		return
+	}
+	log.Printf("purged %d notifications", n)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"Purged": n})
+}
+
+// purgeCronRunsHandler deletes cron runs older than the cron run
+// retention period. It is invoked daily by App Engine cron, as
+// scheduled by oceanbench_cron.yaml. See cronRequest.
+func purgeCronRunsHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+	if !cronRequest(w, r) {
+		return
+	}
+
+	setup(ctx)
+	n, err := model.DeleteCronRuns(ctx, settingsStore, time.Now().Add(-model.CronRunRetention))
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, fmt.Sprintf("purge failed after %d cron runs: %v", n, err))
+		return
+	}
+	log.Printf("purged %d cron runs", n)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"Purged": n})
+}
+
+// purgeQueuedEditsHandler deletes expired queued edits, which are
+// recorded so that settings edits replayed by the service worker are
+// applied only once. It is invoked daily by App Engine cron, as
+// scheduled by oceanbench_cron.yaml. See cronRequest.
+func purgeQueuedEditsHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	ctx := r.Context()
+	if !cronRequest(w, r) {
+		return
+	}
+
+	setup(ctx)
+	n, err := model.DeleteExpiredQueuedEdits(ctx, settingsStore, time.Now())
+	if err != nil {
+		writeHttpError(w, http.StatusInternalServerError, fmt.Sprintf("purge failed after %d queued edits: %v", n, err))
+		return
+	}
+	log.Printf("purged %d queued edits", n)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"Purged": n})
+}
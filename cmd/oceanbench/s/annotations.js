@@ -0,0 +1,187 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+
+// Annotations are markers on media segments, listed below the player.
+// The media ID and time range are taken from the URL input, e.g.,
+// get?id=<MID>&ts=<start>-<end>&out=m3u, and times are Unix seconds.
+
+let annotationMID, annotationStart;
+
+document.addEventListener("DOMContentLoaded", function () {
+  document.getElementById("annotateBtn").addEventListener("click", annotate);
+  document.getElementById("loadBtn").addEventListener("click", loadAnnotations);
+  // Wait for the player to populate the URL input on first load.
+  setTimeout(loadAnnotations, 0);
+});
+
+// mediaParams returns the media ID and time range from the URL input,
+// or null if there is no media ID.
+function mediaParams() {
+  const url = document.getElementById("url").value;
+  const params = new URLSearchParams(url.substring(url.indexOf("?") + 1));
+  const mid = params.get("id");
+  if (!mid) {
+    return null;
+  }
+  return { mid: mid, ts: params.get("ts") };
+}
+
+// loadAnnotations fetches and lists the annotations for the media.
+async function loadAnnotations() {
+  const panel = document.getElementById("annotations");
+  const p = mediaParams();
+  if (p == null) {
+    panel.hidden = true;
+    return;
+  }
+  panel.hidden = false;
+  annotationMID = p.mid;
+  annotationStart = p.ts ? parseInt(p.ts.split("-")[0]) : null;
+  let query = "id=" + encodeURIComponent(p.mid);
+  if (p.ts) {
+    query += "&ts=" + encodeURIComponent(p.ts);
+  }
+  document.getElementById("annotationsCSV").href = "/annotations?format=csv&" + query;
+
+  const list = document.getElementById("annotationList");
+  list.innerHTML = "";
+  let annotations;
+  try {
+    const resp = await fetch("/annotations?" + query);
+    if (!resp.ok) {
+      throw new Error(await resp.text());
+    }
+    annotations = await resp.json();
+  } catch (e) {
+    list.append(annotationText("li", "Could not get annotations: " + e.message, "red"));
+    return;
+  }
+  if (!annotations || annotations.length == 0) {
+    list.append(annotationText("li", "No annotations."));
+    return;
+  }
+  for (const a of annotations) {
+    list.append(annotationItem(a));
+  }
+}
+
+// annotationItem returns a list item for an annotation, which seeks
+// the video to the start of the annotation when clicked.
+function annotationItem(a) {
+  const li = document.createElement("li");
+  const start = new Date(a.Timestamp * 1000).toLocaleString();
+  const marker = annotationText("a", `${start}: ${a.Label}`);
+  marker.href = "#";
+  marker.addEventListener("click", function (e) {
+    e.preventDefault();
+    seek(a.Timestamp);
+  });
+  li.append(marker);
+  if (a.End > a.Timestamp) {
+    li.append(` (${a.End - a.Timestamp}s)`);
+  }
+  if (a.Note) {
+    li.append(" - ", annotationText("span", a.Note));
+  }
+  li.append(annotationText("span", ` ${a.Author}`, "text-muted"));
+  const del = document.createElement("img");
+  del.src = "/s/delete.png";
+  del.alt = "Delete";
+  del.className = "ms-2";
+  del.style.cursor = "pointer";
+  del.addEventListener("click", () => deleteAnnotation(a.ID));
+  li.append(del);
+  return li;
+}
+
+// seek seeks the video to the given Unix time, relative to the start
+// of the media range.
+function seek(ts) {
+  const video = document.getElementById("video");
+  if (!video || annotationStart == null) {
+    return;
+  }
+  video.currentTime = Math.max(0, ts - annotationStart);
+}
+
+// currentTime returns the Unix time of the current video position, or
+// null if unknown.
+function currentTime() {
+  const video = document.getElementById("video");
+  if (!video || annotationStart == null) {
+    return null;
+  }
+  return annotationStart + Math.floor(video.currentTime);
+}
+
+// annotate creates an annotation at the current video position.
+async function annotate() {
+  const st = currentTime();
+  if (st == null) {
+    alert("Load a video with a time range to annotate it.");
+    return;
+  }
+  const label = document.getElementById("annotationLabel").value.trim();
+  if (label == "") {
+    alert("Label required.");
+    return;
+  }
+  const dur = parseInt(document.getElementById("annotationDuration").value) || 0;
+  const form = new URLSearchParams({
+    id: annotationMID,
+    st: st,
+    ft: st + dur,
+    lb: label,
+    nt: document.getElementById("annotationNote").value,
+    vs: document.getElementById("annotationStream").value,
+  });
+  const resp = await fetch("/annotations", { method: "POST", body: form });
+  if (!resp.ok) {
+    alert("Could not create annotation: " + (await resp.text()));
+    return;
+  }
+  document.getElementById("annotationLabel").value = "";
+  document.getElementById("annotationNote").value = "";
+  loadAnnotations();
+}
+
+// deleteAnnotation deletes an annotation, after confirmation.
+async function deleteAnnotation(id) {
+  if (!confirm("Delete annotation?")) {
+    return;
+  }
+  const resp = await fetch("/annotations?ai=" + id, { method: "DELETE" });
+  if (!resp.ok) {
+    alert("Could not delete annotation: " + (await resp.text()));
+    return;
+  }
+  loadAnnotations();
+}
+
+// annotationText returns an element of the given tag containing text, with an optional class.
+function annotationText(tag, s, cls) {
+  const e = document.createElement(tag);
+  e.textContent = s;
+  if (cls) {
+    e.className = cls;
+  }
+  return e;
+}
@@ -239,3 +239,19 @@ function checkUTCOffset(tz) {
   const utcOffsetRegex = /^[+-](?:2[0-3]|[01][0-9]):[0-5][0-9]$/;
   return utcOffsetRegex.test(tz);
 }
+
+// Register the service worker, which keeps recently viewed settings
+// readable offline and posts edits queued offline when back online.
+if ("serviceWorker" in navigator) {
+  navigator.serviceWorker.register("/sw.js").catch((e) => console.log("could not register service worker: " + e));
+  window.addEventListener("online", function () {
+    if (navigator.serviceWorker.controller) {
+      navigator.serviceWorker.controller.postMessage({ type: "replay" });
+    }
+  });
+  navigator.serviceWorker.addEventListener("message", function (event) {
+    if (event.data && event.data.type == "replayed" && !event.data.ok) {
+      alert("Queued change to " + event.data.url + " failed with status " + event.data.status);
+    }
+  });
+}
@@ -0,0 +1,106 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// mapRefreshPeriod is the period between refreshes of the map (ms).
+const mapRefreshPeriod = 60 * 1000;
+
+// statusColors are the marker colors of device statuses.
+const statusColors = { reporting: "green", stale: "orange", offline: "red" };
+
+let map;
+let markers;
+
+// initMap initializes the map, shows all of the user's sites and
+// devices, then periodically refreshes those within the visible area.
+async function initMap() {
+  map = L.map("map").setView([0, 0], 2);
+  L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+    maxZoom: 19,
+    attribution: '&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a>',
+  }).addTo(map);
+  markers = L.layerGroup().addTo(map);
+
+  const data = await refreshMap("");
+  if (data) {
+    const points = data.Sites.concat(data.Devices).map((p) => [p.Latitude, p.Longitude]);
+    if (points.length > 0) {
+      map.fitBounds(L.latLngBounds(points), { maxZoom: 15, padding: [20, 20] });
+    }
+  }
+  setInterval(() => refreshMap(visibleBox()), mapRefreshPeriod);
+}
+
+// visibleBox returns the visible area of the map as
+// minLat,minLon,maxLat,maxLon, clamped to valid coordinates.
+function visibleBox() {
+  const b = map.getBounds();
+  const clamp = (v, lim) => Math.min(Math.max(v, -lim), lim);
+  return [clamp(b.getSouth(), 90), clamp(b.getWest(), 180), clamp(b.getNorth(), 90), clamp(b.getEast(), 180)].join(",");
+}
+
+// refreshMap fetches the sites and devices within the given box, or
+// everywhere if the box is empty, and replaces the map's markers,
+// returning the fetched data, or null on error.
+async function refreshMap(box) {
+  const status = document.getElementById("map-status");
+  let data;
+  try {
+    const resp = await fetch("/api/get/map/sites" + (box ? "?box=" + box : ""));
+    if (!resp.ok) {
+      throw new Error(await resp.text());
+    }
+    data = await resp.json();
+  } catch (e) {
+    status.textContent = "Could not get sites: " + e.message;
+    status.className = "red";
+    return null;
+  }
+
+  markers.clearLayers();
+  for (const site of data.Sites) {
+    L.circleMarker([site.Latitude, site.Longitude], { radius: 12, color: "blue", fill: false })
+      .bindPopup(
+        `<b>${escapeHTML(site.Name)}</b><br>` +
+          `${site.Reporting} reporting, ${site.Stale} stale, ${site.Offline} offline`,
+      )
+      .addTo(markers);
+  }
+  for (const dev of data.Devices) {
+    const name = escapeHTML(dev.Name);
+    const reported = dev.Reported ? new Date(dev.Reported * 1000).toLocaleString() : "never";
+    L.circleMarker([dev.Latitude, dev.Longitude], { radius: 6, color: statusColors[dev.Status] || "black", fillOpacity: 0.8 })
+      .bindPopup(
+        (dev.URL ? `<a href="${dev.URL}">${name}</a>` : name) +
+          ` (${dev.MAC})<br>${escapeHTML(dev.Site)}<br>` +
+          `Status: ${dev.Status}<br>Last reported: ${reported}`,
+      )
+      .addTo(markers);
+  }
+  status.textContent = "Updated " + new Date().toLocaleTimeString() + ".";
+  status.className = "";
+  return data;
+}
+
+// escapeHTML escapes text for inclusion in HTML.
+function escapeHTML(s) {
+  const e = document.createElement("span");
+  e.textContent = s;
+  return e.innerHTML;
+}
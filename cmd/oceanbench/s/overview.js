@@ -0,0 +1,89 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// initOverview fetches the overviews of the user's sites and renders them.
+async function initOverview() {
+  const div = document.getElementById("overview");
+  let sites;
+  try {
+    const resp = await fetch("/api/get/overview/sites");
+    if (!resp.ok) {
+      throw new Error(await resp.text());
+    }
+    sites = await resp.json();
+  } catch (e) {
+    div.innerHTML = "";
+    div.append(card(text("div", "Could not get sites: " + e.message, "red")));
+    return;
+  }
+  div.innerHTML = "";
+  if (!sites || sites.length == 0) {
+    div.append(card(text("div", "No sites.")));
+    return;
+  }
+  for (const site of sites) {
+    div.append(siteCard(site), document.createElement("br"));
+  }
+}
+
+// siteCard returns a card summarizing a site.
+function siteCard(site) {
+  const c = card(text("span", `${site.Name} (${site.Skey})`, "monitor-title"));
+  if (site.Error) {
+    c.append(text("div", site.Error, "red"));
+    return c;
+  }
+  c.append(text("div", `Devices: ${site.Sending} sending, ${site.Stopped} stopped, ${site.Unreported} unreported`));
+  c.append(text("div", "Broadcasts: " + (site.Broadcasts ? site.Broadcasts.join(", ") : "none")));
+  for (const a of site.Alerts || []) {
+    c.append(text("div", `Alert: ${a.Subject} (${a.Kind}, ${new Date(a.Created).toLocaleString()})`, "red"));
+  }
+  for (const dev of site.Devices || []) {
+    c.append(document.createElement("hr"));
+    const title = text("div", "");
+    const img = document.createElement("img");
+    img.src = `/s/${dev.Sending}.png`;
+    img.alt = dev.Sending;
+    title.append(img, " ", text("span", dev.Name, "monitor-title"), ` (${dev.MAC})`);
+    c.append(title);
+    for (const s of dev.Sensors || []) {
+      c.append(text("div", `${s.Name}: ${s.Scalar} ${s.Units} (${s.Date})`));
+    }
+  }
+  return c;
+}
+
+// card returns a card element containing the given elements.
+function card(...elems) {
+  const c = document.createElement("div");
+  c.className = "border rounded p-4 container-md bg-white";
+  c.append(...elems);
+  return c;
+}
+
+// text returns an element of the given tag containing text, with an optional class.
+function text(tag, s, cls) {
+  const e = document.createElement(tag);
+  e.textContent = s;
+  if (cls) {
+    e.className = cls;
+  }
+  return e;
+}
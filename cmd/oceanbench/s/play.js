@@ -98,6 +98,9 @@ async function load(firstLoad) {
       } else {
         playAudioFile(audioFile, bd, chan, rate);
       }
+      if (firstLoad) {
+        loadSpectrogram(params);
+      }
       break;
     default:
       console.warn("missing or invalid media output type in URL");
@@ -105,6 +108,36 @@ async function load(firstLoad) {
   }
 }
 
+// spectrogramPollPeriod is the period in milliseconds at which a pending spectrogram is polled.
+const spectrogramPollPeriod = 3000;
+
+// loadSpectrogram requests a server-side spectrogram of the audio given by the id and ts params,
+// polling until it has been generated.
+async function loadSpectrogram(params) {
+  let id = params.get("id");
+  let ts = params.get("ts");
+  if (id == null || ts == null) {
+    return;
+  }
+  document.getElementById("spectrogram").hidden = false;
+  let msg = document.getElementById("spectrogramMsg");
+  let resp = await fetch("/spectrogram?id=" + encodeURIComponent(id) + "&ts=" + encodeURIComponent(ts));
+  if (resp.status == 202) {
+    msg.innerHTML = "Generating spectrogram...";
+    setTimeout(function () {
+      loadSpectrogram(params);
+    }, spectrogramPollPeriod);
+    return;
+  }
+  if (resp.headers.get("Content-Type") != "image/png") {
+    let data = await resp.json();
+    msg.innerText = data.er;
+    return;
+  }
+  msg.innerHTML = "";
+  document.getElementById("spectrogramImg").src = URL.createObjectURL(await resp.blob());
+}
+
 // getURL will format and return a URL based on the type of media requested. The url input element is updated also.
 function getURL(firstLoad, query, type, params) {
   let url = "";
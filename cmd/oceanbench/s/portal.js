@@ -0,0 +1,82 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+
+// portal.js charts the sensors of a public site on the public data
+// portal, one chart per sensor. It requires amCharts 4 core.js and
+// charts.js.
+
+let sensorCharts = [];
+
+// loadSensors loads and renders the sensors of a public site over the
+// selected number of hours.
+async function loadSensors(skey) {
+  const errDiv = document.getElementById("sensors-error");
+  errDiv.textContent = "";
+  const hours = document.getElementById("hours").value;
+  try {
+    const resp = await fetch(`/portal/${skey}/sensors?h=${hours}`);
+    if (!resp.ok) {
+      throw new Error(await resp.text());
+    }
+    renderSensors(await resp.json());
+  } catch (e) {
+    errDiv.textContent = "Could not get sensors: " + e.message;
+  }
+}
+
+// renderSensors renders a line chart for each sensor.
+function renderSensors(sensors) {
+  for (const c of sensorCharts) {
+    c.dispose();
+  }
+  sensorCharts = [];
+  const div = document.getElementById("sensors");
+  div.innerHTML = "";
+  if (sensors.length == 0) {
+    div.textContent = "No sensors.";
+    return;
+  }
+
+  for (const s of sensors) {
+    const title = document.createElement("p");
+    title.textContent = `${s.Device} ${s.Name}` + (s.Units ? ` (${s.Units})` : "");
+    const chartDiv = document.createElement("div");
+    chartDiv.style.height = "300px";
+    div.append(title, chartDiv);
+    if (s.Values.length == 0) {
+      chartDiv.style.height = "auto";
+      chartDiv.textContent = "No recent data.";
+      continue;
+    }
+
+    const chart = am4core.create(chartDiv, am4charts.XYChart);
+    chart.data = s.Values.map((v) => ({ date: new Date(v[0] * 1000), value: v[1] }));
+    chart.xAxes.push(new am4charts.DateAxis());
+    const yAxis = chart.yAxes.push(new am4charts.ValueAxis());
+    yAxis.title.text = s.Units;
+    const series = chart.series.push(new am4charts.LineSeries());
+    series.dataFields.dateX = "date";
+    series.dataFields.valueY = "value";
+    series.tooltipText = "{valueY.formatNumber('#.##')}";
+    chart.cursor = new am4charts.XYCursor();
+    sensorCharts.push(chart);
+  }
+}
@@ -0,0 +1,198 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+
+// sw.js is the Ocean Bench service worker, which keeps recently viewed
+// settings readable offline and queues settings edits made offline,
+// posting them when connectivity returns.
+//
+// Settings pages, settings API responses and static files are fetched
+// network first, so that they are always fresh when online, and are
+// cached for use when offline. Settings responses carry ETags, so the
+// network fetch is a cheap conditional request when nothing changed.
+
+const CACHE = "oceanbench-v1";
+const DB = "oceanbench";
+const QUEUE = "queue";
+
+// Paths of settings API requests, which are cached for offline use.
+const SETTINGS_API = ["/api/get/site/", "/api/get/devices/", "/api/get/sites/", "/api/get/vars/"];
+
+self.addEventListener("install", () => self.skipWaiting());
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches
+      .keys()
+      .then((keys) => Promise.all(keys.filter((k) => k != CACHE).map((k) => caches.delete(k))))
+      .then(() => self.clients.claim())
+      .then(replay),
+  );
+});
+
+self.addEventListener("fetch", (event) => {
+  const req = event.request;
+  const url = new URL(req.url);
+  if (url.origin != self.location.origin) {
+    return;
+  }
+  if (req.method == "GET" && cacheable(url.pathname)) {
+    event.respondWith(networkFirst(req));
+  } else if (req.method == "POST" && url.pathname.startsWith("/set/")) {
+    event.respondWith(postOrQueue(req));
+  }
+});
+
+self.addEventListener("sync", (event) => {
+  if (event.tag == "replay") {
+    event.waitUntil(replay());
+  }
+});
+
+// Pages post a replay message when they come back online, since
+// background sync is not supported by all browsers.
+self.addEventListener("message", (event) => {
+  if (event.data && event.data.type == "replay") {
+    event.waitUntil(replay());
+  }
+});
+
+// cacheable returns true if GET requests for the path are cached.
+function cacheable(path) {
+  return path.startsWith("/set/") || path.startsWith("/s/") || SETTINGS_API.some((p) => path.startsWith(p));
+}
+
+// networkFirst fetches a request, caching successful responses, and
+// falls back to the cache when the network is unavailable.
+async function networkFirst(req) {
+  const cache = await caches.open(CACHE);
+  try {
+    const resp = await fetch(req);
+    if (resp.ok) {
+      cache.put(req, resp.clone());
+    }
+    return resp;
+  } catch (e) {
+    const cached = await cache.match(req);
+    if (cached) {
+      return cached;
+    }
+    return new Response("Offline and not cached: " + req.url, {
+      status: 503,
+      headers: { "Content-Type": "text/plain" },
+    });
+  }
+}
+
+// postOrQueue posts a settings edit, or queues it if the network is
+// unavailable.
+async function postOrQueue(req) {
+  const queued = req.clone();
+  try {
+    return await fetch(req);
+  } catch (e) {
+    await enqueue({
+      key: crypto.randomUUID(),
+      url: queued.url,
+      contentType: queued.headers.get("Content-Type"),
+      body: await queued.arrayBuffer(),
+      time: Date.now(),
+    });
+    if (self.registration.sync) {
+      self.registration.sync.register("replay").catch(() => {});
+    }
+    return new Response(
+      "<!DOCTYPE html><html><body><p>You are offline. Your change has been queued " +
+        "and will be sent when connectivity returns.</p><p><a href=\"javascript:history.back()\">Back</a></p></body></html>",
+      { status: 202, headers: { "Content-Type": "text/html" } },
+    );
+  }
+}
+
+// replay posts queued edits in the order they were made, stopping at
+// the first network failure. Edits rejected by the server are dropped,
+// since retrying them would not succeed, and clients are told. Each
+// edit carries an idempotency key, so that the server applies it only
+// once even if it is replayed again after a failure, e.g., when the
+// connection drops before the response arrives.
+async function replay() {
+  const items = await queued();
+  for (const item of items) {
+    const headers = {};
+    if (item.key) {
+      headers["Idempotency-Key"] = item.key;
+    }
+    if (item.contentType) {
+      headers["Content-Type"] = item.contentType;
+    }
+    let resp;
+    try {
+      resp = await fetch(item.url, {
+        method: "POST",
+        body: item.body,
+        headers: headers,
+        credentials: "same-origin",
+      });
+    } catch (e) {
+      return;
+    }
+    await dequeue(item.id);
+    const clients = await self.clients.matchAll();
+    for (const c of clients) {
+      c.postMessage({ type: "replayed", url: item.url, ok: resp.ok, status: resp.status });
+    }
+  }
+}
+
+// openDB opens the queue database.
+function openDB() {
+  return new Promise((resolve, reject) => {
+    const req = indexedDB.open(DB, 1);
+    req.onupgradeneeded = () => req.result.createObjectStore(QUEUE, { keyPath: "id", autoIncrement: true });
+    req.onsuccess = () => resolve(req.result);
+    req.onerror = () => reject(req.error);
+  });
+}
+
+// transact runs fn on the queue store, resolving with the result of its request.
+async function transact(mode, fn) {
+  const db = await openDB();
+  return new Promise((resolve, reject) => {
+    const tx = db.transaction(QUEUE, mode);
+    const req = fn(tx.objectStore(QUEUE));
+    tx.oncomplete = () => resolve(req.result);
+    tx.onerror = () => reject(tx.error);
+  });
+}
+
+// enqueue adds an edit to the queue.
+function enqueue(item) {
+  return transact("readwrite", (s) => s.add(item));
+}
+
+// dequeue removes an edit from the queue.
+function dequeue(id) {
+  return transact("readwrite", (s) => s.delete(id));
+}
+
+// queued returns the queued edits, oldest first.
+function queued() {
+  return transact("readonly", (s) => s.getAll());
+}
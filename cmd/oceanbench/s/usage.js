@@ -0,0 +1,164 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+
+// usage.js charts the data ingested from devices, as recorded daily
+// by datablue, and lists the site's archived broadcasts, as recorded
+// by Ocean TV. It requires amCharts 4 core.js and charts.js.
+
+let usageChart;
+
+// initUsage sets the default date range and loads usage.
+function initUsage() {
+  const to = new Date();
+  const from = new Date(to.getTime() - 29 * 24 * 3600 * 1000);
+  document.getElementById("from").value = from.toISOString().slice(0, 10);
+  document.getElementById("to").value = to.toISOString().slice(0, 10);
+  loadUsage();
+}
+
+// loadUsage loads and renders usage for the current site and all sites.
+async function loadUsage() {
+  const errDiv = document.getElementById("usage-error");
+  errDiv.textContent = "";
+  const params = new URLSearchParams({
+    from: document.getElementById("from").value,
+    to: document.getElementById("to").value,
+  });
+  try {
+    const skey = (await getText("/api/get/profile/data")).split(":")[0];
+    if (skey) {
+      renderSite(JSON.parse(await getText(`/api/get/usage/${skey}?${params}`)));
+      renderBroadcasts(JSON.parse(await getText(`/api/get/broadcasts/${skey}?${params}`)));
+    }
+    renderSites(JSON.parse(await getText(`/api/get/usage/sites?${params}`)));
+  } catch (e) {
+    errDiv.textContent = "Could not get usage: " + e.message;
+  }
+}
+
+// getText fetches a URL, returning the response text or throwing an error.
+async function getText(url) {
+  const resp = await fetch(url);
+  if (!resp.ok) {
+    throw new Error(await resp.text());
+  }
+  return resp.text();
+}
+
+// renderSite renders a site's usage as a chart of daily bytes stacked
+// by device, and a table of device totals.
+function renderSite(site) {
+  document.getElementById("site-title").textContent = `${site.Name} (${site.Skey})`;
+  const days = daysBetween(site.From, site.To);
+  document.getElementById("site-summary").textContent =
+    `Total: ${formatBytes(site.Bytes)} in ${site.Requests} requests, ` +
+    `average ${formatBytes(site.Bytes / days)} per day, ` +
+    `projected ${formatBytes((site.Bytes / days) * 30)} per 30 days.`;
+
+  const names = {};
+  for (const d of site.Devices || []) {
+    names[d.MAC] = d.Name || d.MAC;
+  }
+  const data = (site.Days || []).map((day) => {
+    const row = { date: day.Date };
+    for (const mac in day.Devices) {
+      row[mac] = day.Devices[mac] / 1e6;
+    }
+    return row;
+  });
+
+  if (usageChart) {
+    usageChart.dispose();
+  }
+  usageChart = am4core.create("chart", am4charts.XYChart);
+  usageChart.data = data;
+  const xAxis = usageChart.xAxes.push(new am4charts.CategoryAxis());
+  xAxis.dataFields.category = "date";
+  const yAxis = usageChart.yAxes.push(new am4charts.ValueAxis());
+  yAxis.title.text = "MB";
+  for (const d of site.Devices || []) {
+    const series = usageChart.series.push(new am4charts.ColumnSeries());
+    series.dataFields.categoryX = "date";
+    series.dataFields.valueY = d.MAC;
+    series.name = names[d.MAC];
+    series.stacked = true;
+    series.columns.template.tooltipText = "{name}: {valueY.formatNumber('#.##')} MB";
+  }
+  usageChart.legend = new am4charts.Legend();
+
+  const rows = (site.Devices || []).map((d) => [d.Name, d.MAC, formatBytes(d.Bytes), d.Requests]);
+  renderTable("devices", ["Device", "MAC", "Bytes", "Requests"], rows);
+}
+
+// renderSites renders a table of site totals.
+function renderSites(sites) {
+  const rows = (sites || []).map((s) => [`${s.Name} (${s.Skey})`, formatBytes(s.Bytes), s.Requests]);
+  renderTable("sites", ["Site", "Bytes", "Requests"], rows);
+}
+
+// renderBroadcasts renders a table of archived broadcasts.
+function renderBroadcasts(archives) {
+  const rows = (archives || []).map((a) => [
+    a.Name,
+    new Date(a.ActualStart).toLocaleString(),
+    a.LiveMinutes,
+    a.HealthIncidents,
+    a.Views,
+    a.Likes,
+    a.OpenFishRegistered ? "Yes" : "No",
+  ]);
+  renderTable("broadcasts", ["Broadcast", "Start", "Live Minutes", "Health Incidents", "Views", "Likes", "OpenFish"], rows);
+}
+
+// renderTable renders a table with the given headings and rows.
+function renderTable(id, headings, rows) {
+  const table = document.getElementById(id);
+  table.innerHTML = "";
+  const head = table.createTHead().insertRow();
+  for (const h of headings) {
+    const th = document.createElement("th");
+    th.textContent = h;
+    head.append(th);
+  }
+  const body = table.createTBody();
+  for (const r of rows) {
+    const tr = body.insertRow();
+    for (const v of r) {
+      tr.insertCell().textContent = v;
+    }
+  }
+}
+
+// daysBetween returns the number of days from one date to another, inclusive.
+function daysBetween(from, to) {
+  return Math.round((Date.parse(to) - Date.parse(from)) / (24 * 3600 * 1000)) + 1;
+}
+
+// formatBytes formats a number of bytes using decimal units.
+function formatBytes(n) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1000 && i < units.length - 1) {
+    n /= 1000;
+    i++;
+  }
+  return (i == 0 ? Math.round(n) : n.toFixed(1)) + " " + units[i];
+}
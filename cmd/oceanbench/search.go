@@ -431,6 +431,12 @@ func formatLocalDateTime(ts int64, tz float64) string {
 	return formatLocalDate(ts, tz) + " " + formatLocalTime(ts, tz)
 }
 
+// formatSiteDateTime formats a Unix timestamp as a date with time in
+// the site's local time, observing daylight saving time, if any.
+func formatSiteDateTime(ts int64, site *model.Site) string {
+	return model.LocalTime(site, time.Unix(ts, 0)).Format("2006-01-02 15:04:05")
+}
+
 // parseFloat parses a string representing a float, otherwise returns 0.
 func parseFloat(s string) float64 {
 	f, _ := strconv.ParseFloat(s, 64)
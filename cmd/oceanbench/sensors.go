@@ -0,0 +1,145 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+// sensorsData stores the data served to the admin sensors page.
+type sensorsData struct {
+	Sensors []provisionalSensor
+	commonData
+}
+
+// provisionalSensor is a provisional sensor and its device.
+type provisionalSensor struct {
+	Device string // Device name.
+	MAC    string
+	model.SensorV2
+	Latest string // Latest value and time, if any.
+}
+
+// sensorsHandler handles the admin sensors page, which lists the
+// site's provisional sensors for review. Provisional sensors are
+// created by datablue when a device sends data on a pin without a
+// sensor. POST requests perform the task given by the task param:
+//
+//   - confirm: confirm the sensor for device ma and pin pn, renaming
+//     it sn if given.
+//   - dismiss: delete the sensor for device ma and pin pn, and record
+//     its dismissal so that it is not provisioned again.
+func sensorsHandler(w http.ResponseWriter, r *http.Request, p *gauth.Profile) {
+	ctx := r.Context()
+	skey, _ := profileData(p)
+
+	data := sensorsData{
+		commonData: commonData{
+			Pages:   pages("sensors"),
+			Profile: p,
+		},
+	}
+
+	var msg string
+	if r.Method == "POST" {
+		err := sensorsTaskHandler(r, skey)
+		if err != nil {
+			msg = err.Error()
+		}
+	}
+
+	devs, err := model.GetDevicesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		msg = fmt.Sprintf("cannot get devices: %v", err)
+	}
+	for _, dev := range devs {
+		sensors, err := model.GetSensorsV2(ctx, settingsStore, dev.Mac)
+		if err != nil {
+			msg = fmt.Sprintf("cannot get sensors: %v", err)
+			break
+		}
+		for _, s := range sensors {
+			if !s.Provisional || s.Mac != dev.Mac {
+				continue
+			}
+			ps := provisionalSensor{Device: dev.Name, MAC: dev.MAC(), SensorV2: s}
+			scalar, err := model.GetLatestScalar(ctx, mediaStore, model.ToSID(dev.MAC(), s.Pin))
+			if err == nil {
+				ps.Latest = fmt.Sprintf("%g at %s", scalar.Value, time.Unix(scalar.Timestamp, 0).UTC().Format("2006-01-02 15:04"))
+			}
+			data.Sensors = append(data.Sensors, ps)
+		}
+	}
+	writeTemplate(w, r, "sensors.html", &data, msg)
+}
+
+// sensorsTaskHandler handles a provisional sensor task.
+func sensorsTaskHandler(r *http.Request, skey int64) error {
+	ctx := r.Context()
+
+	ma := r.FormValue("ma")
+	if !model.IsMacAddress(ma) {
+		return errors.New("invalid MAC address")
+	}
+	mac := model.MacEncode(ma)
+	dev, err := model.GetDevice(ctx, settingsStore, mac)
+	if err != nil || dev.Skey != skey {
+		return errors.New("device not found")
+	}
+	pn := r.FormValue("pn")
+	s, err := model.GetSensorV2(ctx, settingsStore, mac, pn)
+	if err != nil {
+		return errors.New("sensor not found")
+	}
+
+	switch r.FormValue("task") {
+	case "confirm":
+		if sn := strings.TrimSpace(r.FormValue("sn")); sn != "" {
+			s.Name = sn
+		}
+		s.Provisional = false
+		err = model.PutSensorV2(ctx, settingsStore, s)
+		if err != nil {
+			return fmt.Errorf("cannot confirm sensor: %w", err)
+		}
+		return nil
+
+	case "dismiss":
+		if !s.Provisional {
+			return errors.New("sensor is not provisional")
+		}
+		err = model.DismissSensorV2(ctx, settingsStore, mac, pn)
+		if err != nil {
+			return fmt.Errorf("cannot dismiss sensor: %w", err)
+		}
+		return nil
+
+	default:
+		return errors.New("invalid task")
+	}
+}
@@ -120,7 +120,7 @@ func writeDevices(w http.ResponseWriter, r *http.Request, msg string, args ...in
 		},
 		Mac:        r.FormValue("ma"),
 		Device:     &model.Device{Enabled: true},
-		Quantities: nmea.DefaultQuantities(),
+		Quantities: model.Quantities(),
 		Funcs:      model.SensorFuncs(),
 		Formats:    model.SensorFormats(),
 		DevTypes:   devTypes,
@@ -318,6 +318,18 @@ func editDevicesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Key rotation tasks leave the rest of the device unchanged.
+	switch task {
+	case "Rotate Key", "Finalize Key", "Cancel Rotation":
+		_, err = rotateDeviceKey(ctx, mac, task, r.FormValue("force") != "")
+		if err != nil {
+			writeDevices(w, r, fmt.Sprintf("could not %s: %v", strings.ToLower(task), err))
+			return
+		}
+		http.Redirect(w, r, "/set/devices?ma="+ma, http.StatusFound)
+		return
+	}
+
 	// Update the device.
 	// Note that the MAC address is immutable.
 	ip := r.FormValue("ip")
@@ -401,6 +413,23 @@ func editDevicesHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/set/devices?ma="+ma, http.StatusFound)
 }
 
+// rotateDeviceKey performs a key rotation task for a device, which is
+// one of "Rotate Key", "Finalize Key" or "Cancel Rotation". A rotation
+// is only finalized before the device has used its new key when force
+// is true.
+func rotateDeviceKey(ctx context.Context, mac int64, task string, force bool) (*model.Device, error) {
+	switch task {
+	case "Rotate Key":
+		return model.StartKeyRotation(ctx, settingsStore, mac, model.DefaultKeyRotationGrace)
+	case "Finalize Key":
+		return model.FinalizeKeyRotation(ctx, settingsStore, mac, force)
+	case "Cancel Rotation":
+		return model.CancelKeyRotation(ctx, settingsStore, mac)
+	default:
+		return nil, fmt.Errorf("invalid key rotation task: %s", task)
+	}
+}
+
 // configDevicesHandler handles configuration of new devices.
 //
 // Form Fields:
@@ -556,6 +585,12 @@ func writeConfigure(w http.ResponseWriter, r *http.Request, profile *gauth.Profi
 //   - vn: variable name
 //   - vv: variable value
 //   - vd: variable delete
+//   - vt: variable type (optional)
+//   - ve: permitted values of an enum variable type (optional)
+//
+// When vt is present a schema is created for the variable name,
+// which then applies to variables of that name on all devices in the
+// site. Values are validated against the schema, if any.
 func editVarHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
 	ctx := r.Context()
@@ -573,6 +608,8 @@ func editVarHandler(w http.ResponseWriter, r *http.Request) {
 	vn := strings.TrimSpace(r.FormValue("vn"))
 	vv := strings.Join(r.Form["vv"], ",")
 	vd := r.FormValue("vd")
+	vt := r.FormValue("vt")
+	ve := r.FormValue("ve")
 
 	mac := model.MacEncode(ma)
 	if mac == 0 {
@@ -592,10 +629,18 @@ func editVarHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if vt != "" && vd != "true" {
+		err = model.PutVariableSchema(ctx, settingsStore, &model.VariableSchema{Skey: skey, Name: vn, Type: vt, Values: ve})
+		if err != nil {
+			writeDevices(w, r, err.Error())
+			return
+		}
+	}
+
 	if vd == "true" {
 		err = model.DeleteVariable(ctx, settingsStore, skey, dev.Hex()+"."+vn)
 	} else {
-		err = model.PutVariable(ctx, settingsStore, skey, dev.Hex()+"."+vn, vv)
+		err = model.PutTypedVariable(ctx, settingsStore, skey, dev.Hex()+"."+vn, vv)
 	}
 
 	if err != nil {
@@ -664,6 +709,16 @@ func editSensorHandler(w http.ResponseWriter, r *http.Request) {
 		writeDevices(w, r, "sensor func missing")
 		return
 	}
+	err = formSensor.ValidateUnits()
+	if err != nil {
+		writeDevices(w, r, "sensor units error: %v", err)
+		return
+	}
+	err = formSensor.SetLimits(r.FormValue("slimits"))
+	if err != nil {
+		writeDevices(w, r, "sensor limits error: %v", err)
+		return
+	}
 
 	log.Printf("putting sensor: %v", formSensor)
 	err = model.PutSensorV2(ctx, settingsStore, &formSensor)
@@ -806,7 +861,7 @@ func writeCrons(w http.ResponseWriter, r *http.Request, msg string) {
 		},
 		Timezone: site.Timezone,
 		Crons:    crons,
-		Actions:  []string{"set", "del", "call", "rpc", "email"},
+		Actions:  []string{"set", "del", "call", "rpc", "http", "email"},
 	}
 
 	writeTemplate(w, r, "set/cron.html", &data, msg)
@@ -821,6 +876,7 @@ func writeCrons(w http.ResponseWriter, r *http.Request, msg string) {
 //   - cv: cron variable
 //   - cd: cron data (variable value)
 //   - ce: cron enabled
+//   - cj: cron jitter in minutes (optional)
 func editCronsHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
 	ctx := r.Context()
@@ -840,6 +896,7 @@ func editCronsHandler(w http.ResponseWriter, r *http.Request) {
 	cv := strings.Trim(r.FormValue("cv"), " ")
 	cd := r.FormValue("cd")
 	ce := r.FormValue("ce")
+	cj := strings.Trim(r.FormValue("cj"), " ")
 	task := r.FormValue("task")
 
 	if id == "" {
@@ -871,6 +928,13 @@ func editCronsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := model.Cron{Skey: skey, ID: id, Action: ca, Var: cv, Data: cd, Enabled: ce != ""}
+	if cj != "" {
+		c.Jitter, err = strconv.ParseInt(cj, 10, 64)
+		if err != nil || c.Jitter < 0 {
+			writeCrons(w, r, fmt.Sprintf("invalid jitter: %s", cj))
+			return
+		}
+	}
 	err = c.ParseTime(ct, site.Timezone)
 	if err != nil {
 		writeCrons(w, r, fmt.Sprintf("could not parse time: %v", err))
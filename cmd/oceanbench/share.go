@@ -0,0 +1,284 @@
+/*
+DESCRIPTION
+  Ocean Bench media sharing, which permits users to share a single
+  clip with people who are not users of the site by means of a signed,
+  expiring link.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	shareTemplate   = "share.html"
+	defaultShareTTL = 7 * 24 * time.Hour // Default time for which shares are valid.
+	maxShareTTL     = 90 * 24 * time.Hour
+)
+
+var (
+	errShareUnbounded = errors.New("shares require a start and end time")
+	errShareTTL       = errors.New("invalid share expiry")
+)
+
+// shareSecret is the secret used to sign share tokens.
+var shareSecret []byte
+
+// shareData stores the data served to the share page.
+type shareData struct {
+	Id, Ts string // Media ID and timestamps of the media to share, if any.
+	Link   string // Link to the newly-created share, if any.
+	Site   *model.Site
+	Shares []shareRow
+	commonData
+}
+
+// shareRow is a media share along with its link.
+type shareRow struct {
+	model.MediaShare
+	Link string
+}
+
+// Status returns the status of the share, i.e., "active", "expired" or "revoked".
+func (s shareRow) Status() string {
+	switch {
+	case !s.Revoked.IsZero():
+		return "revoked"
+	case time.Now().After(s.Expires):
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// shareToken returns a signed token for a media share, which
+// identifies the share and expires along with it.
+func shareToken(s *model.MediaShare) (string, error) {
+	return gauth.PutClaims(map[string]interface{}{
+		"share": strconv.FormatInt(s.ID, 10),
+		"exp":   s.Expires.Unix(),
+	}, shareSecret)
+}
+
+// shareLink returns the link for getting the media of a media share.
+func shareLink(s *model.MediaShare) (string, error) {
+	tok, err := shareToken(s)
+	if err != nil {
+		return "", err
+	}
+	ts := strconv.FormatInt(s.Start, 10)
+	if s.End != s.Start {
+		ts += "-" + strconv.FormatInt(s.End, 10)
+	}
+	return dataHost + "/get?id=" + strconv.FormatInt(s.MID, 10) + "&ts=" + ts + "&share=" + url.QueryEscape(tok), nil
+}
+
+// checkShare returns nil if the given share token permits getting the
+// media with the given MID and timestamps, or an error otherwise.
+func checkShare(ctx context.Context, tok string, mid int64, ts []int64) error {
+	claims, err := gauth.GetClaims(tok, shareSecret)
+	if err != nil {
+		return fmt.Errorf("invalid share token: %w", err)
+	}
+	v, _ := claims["share"].(string)
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return errors.New("invalid share token: missing share ID")
+	}
+	s, err := model.GetMediaShare(ctx, settingsStore, id)
+	if err != nil {
+		return fmt.Errorf("could not get share %d: %w", id, err)
+	}
+	return s.Check(mid, ts, time.Now())
+}
+
+// createShare creates a share of the media with the given MID and
+// timestamps, returning its link. The user must have read permission
+// for the media.
+func createShare(ctx context.Context, p *gauth.Profile, mid int64, ts []int64, note string, ttl time.Duration) (string, error) {
+	if ts[0] == datastore.EpochStart || ts[len(ts)-1] == datastore.EpochEnd {
+		return "", errShareUnbounded
+	}
+	ok, err := hasPermission(ctx, p, mid, model.ReadPermission)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errPermissionDenied
+	}
+	ma, _ := model.FromMID(mid)
+	dev, err := model.GetDevice(ctx, settingsStore, model.MacEncode(ma))
+	if err != nil {
+		return "", fmt.Errorf("could not get device: %w", err)
+	}
+
+	s := &model.MediaShare{Skey: dev.Skey, MID: mid, Start: ts[0], End: ts[len(ts)-1], Note: note, Creator: p.Email}
+	err = model.CreateMediaShare(ctx, settingsStore, s, ttl)
+	if err != nil {
+		return "", err
+	}
+	link, err := shareLink(s)
+	if err != nil {
+		// Delete the share, since it cannot be used without its link.
+		delErr := model.DeleteMediaShare(ctx, settingsStore, s.ID)
+		if delErr != nil {
+			log.Printf("could not delete share %d: %v", s.ID, delErr)
+		}
+		return "", fmt.Errorf("could not create share link: %w", err)
+	}
+	log.Printf("%s shared media %d from %d to %d until %v", p.Email, mid, s.Start, s.End, s.Expires)
+	return link, nil
+}
+
+// revokeShare revokes a share of the current site's media. Only the
+// creator of a share or users with write permission may revoke it.
+func revokeShare(ctx context.Context, p *gauth.Profile, skey, id int64) error {
+	s, err := model.GetMediaShare(ctx, settingsStore, id)
+	if err != nil {
+		return fmt.Errorf("could not get share %d: %w", id, err)
+	}
+	if s.Skey != skey {
+		return errPermissionDenied
+	}
+	if s.Creator != p.Email {
+		user, err := model.GetUser(ctx, settingsStore, skey, p.Email)
+		if err != nil || user.Perm&model.WritePermission == 0 {
+			return errPermissionDenied
+		}
+	}
+	err = model.RevokeMediaShare(ctx, settingsStore, id)
+	if err != nil {
+		return fmt.Errorf("could not revoke share %d: %w", id, err)
+	}
+	log.Printf("%s revoked share %d", p.Email, id)
+	return nil
+}
+
+// shareHandler handles requests to share media and lists the current
+// site's shares.
+//
+// Query params:
+//
+//	id: Media ID
+//	ts: timestamp or timestamp range
+//
+// Form params (POST only):
+//
+//	task: "create" or "revoke"
+//	note: note describing the share
+//	days: days until the share expires
+//	share: ID of the share to revoke
+func shareHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	p, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+	skey, _ := profileData(p)
+	ctx := r.Context()
+	setup(ctx)
+
+	data := shareData{
+		Id:         r.FormValue("id"),
+		Ts:         r.FormValue("ts"),
+		commonData: commonData{Pages: pages("search")},
+	}
+	var msg string
+	if r.Method == "POST" {
+		switch r.FormValue("task") {
+		case "create":
+			data.Link, err = createShareFromForm(r, p)
+			if err != nil {
+				msg = fmt.Sprintf("could not create share: %v", err)
+			}
+		case "revoke":
+			id, _ := strconv.ParseInt(r.FormValue("share"), 10, 64)
+			err = revokeShare(ctx, p, skey, id)
+			if err != nil {
+				msg = err.Error()
+			}
+		}
+	}
+
+	data.Site, err = model.GetSite(ctx, settingsStore, skey)
+	if err != nil {
+		writeTemplate(w, r, shareTemplate, &data, fmt.Sprintf("could not get site: %v", err))
+		return
+	}
+	shares, err := model.GetMediaSharesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		writeTemplate(w, r, shareTemplate, &data, fmt.Sprintf("could not get shares: %v", err))
+		return
+	}
+	for _, s := range shares {
+		row := shareRow{MediaShare: s}
+		row.Link, err = shareLink(&s)
+		if err != nil {
+			log.Printf("could not get link for share %d: %v", s.ID, err)
+		}
+		data.Shares = append(data.Shares, row)
+	}
+	writeTemplate(w, r, shareTemplate, &data, msg)
+}
+
+// createShareFromForm creates a share from the share form, returning its link.
+func createShareFromForm(r *http.Request, p *gauth.Profile) (string, error) {
+	mid, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		return "", errInvalidMID
+	}
+	t := r.FormValue("ts")
+	if t == "" {
+		return "", errMissingTimestamp
+	}
+	ts, err := splitTimestamps(t, false)
+	if err != nil {
+		return "", errInvalidTimestamp
+	}
+	ttl := defaultShareTTL
+	if v := r.FormValue("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			return "", errShareTTL
+		}
+		ttl = time.Duration(days) * 24 * time.Hour
+	}
+	if ttl > maxShareTTL {
+		return "", errShareTTL
+	}
+	return createShare(r.Context(), p, mid, ts, r.FormValue("note"), ttl)
+}
@@ -0,0 +1,111 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestCheckShare(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	defer func(store datastore.Store, secret []byte) { settingsStore, shareSecret = store, secret }(settingsStore, shareSecret)
+	settingsStore, shareSecret = store, []byte("secret")
+
+	const (
+		mid   = 123
+		start = 1700000000
+		end   = start + 60
+	)
+	s := &model.MediaShare{Skey: 1, MID: mid, Start: start, End: end}
+	err = model.CreateMediaShare(ctx, store, s, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateMediaShare returned error: %v", err)
+	}
+	tok, err := shareToken(s)
+	if err != nil {
+		t.Fatalf("shareToken returned error: %v", err)
+	}
+
+	tests := []struct {
+		desc    string
+		tok     string
+		mid     int64
+		ts      []int64
+		wantErr bool
+	}{
+		{desc: "in scope", tok: tok, mid: mid, ts: []int64{start, end}},
+		{desc: "other media", tok: tok, mid: mid + 1, ts: []int64{start, end}, wantErr: true},
+		{desc: "out of range", tok: tok, mid: mid, ts: []int64{start, end + 1}, wantErr: true},
+		{desc: "tampered token", tok: tok + "x", mid: mid, ts: []int64{start, end}, wantErr: true},
+	}
+	for _, test := range tests {
+		err := checkShare(ctx, test.tok, test.mid, test.ts)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: checkShare returned error %v, want error: %t", test.desc, err, test.wantErr)
+		}
+	}
+
+	shareSecret = []byte("other")
+	if err := checkShare(ctx, tok, mid, []int64{start, end}); err == nil {
+		t.Errorf("checkShare accepted token signed with another secret")
+	}
+}
+
+func TestCreateShareWithoutSecret(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	defer func(store datastore.Store, secret []byte, sa bool) {
+		settingsStore, shareSecret, standalone = store, secret, sa
+	}(settingsStore, shareSecret, standalone)
+	settingsStore, shareSecret, standalone = store, nil, true
+
+	const ma = "0A:00:00:00:00:02"
+	err = model.PutDevice(ctx, store, &model.Device{Skey: 1, Mac: model.MacEncode(ma)})
+	if err != nil {
+		t.Fatalf("PutDevice returned error: %v", err)
+	}
+	_, err = createShare(ctx, &gauth.Profile{Email: "user@example.org"}, model.ToMID(ma, "V0"), []int64{1700000000, 1700000060}, "", time.Hour)
+	if err == nil {
+		t.Fatalf("createShare without a share secret returned no error")
+	}
+	shares, err := model.GetMediaSharesBySite(ctx, store, 1)
+	if err != nil {
+		t.Fatalf("GetMediaSharesBySite returned error: %v", err)
+	}
+	if len(shares) != 0 {
+		t.Errorf("createShare left %d shares after failing", len(shares))
+	}
+}
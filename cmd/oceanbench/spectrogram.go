@@ -0,0 +1,411 @@
+/*
+DESCRIPTION
+  Ocean Bench spectrogram generation. Requests for spectrograms of
+  audio are queued as Spectrogram entities, which are generated as PNG
+  images by a background worker and cached for subsequent requests.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"math/cmplx"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/av/codec/adpcm"
+	"github.com/ausocean/av/codec/codecutil"
+	"github.com/ausocean/av/container/mts"
+	"github.com/mjibson/go-dsp/fft"
+	"github.com/mjibson/go-dsp/window"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Spectrogram generation parameters.
+const (
+	spectrogramWindow      = 512              // FFT window size in samples, which is twice the image height.
+	spectrogramMaxWidth    = 1200             // Maximum image width in pixels.
+	spectrogramRange       = 80.0             // Dynamic range in dB.
+	spectrogramMaxDuration = 10 * 60          // Maximum duration of audio in seconds.
+	spectrogramBatch       = 5                // Maximum spectrograms generated per worker run.
+	spectrogramStale       = 10 * time.Minute // Time after which processing spectrograms are reclaimed.
+	spectrogramPeriod      = time.Minute      // Period at which the worker checks for pending spectrograms.
+	spectrogramPublicRate  = 10               // Maximum new spectrograms per minute requested anonymously per IP address.
+)
+
+var (
+	errNotAudio          = errors.New("media is not audio")
+	errNoAudio           = errors.New("no audio in time range")
+	errSpectrogramRange  = fmt.Errorf("spectrograms require a start and end time no more than %d seconds apart", spectrogramMaxDuration)
+	errUnsupportedCodec  = errors.New("unsupported audio codec")
+	errInsufficientAudio = errors.New("insufficient audio for spectrogram")
+	errSpectrogramRate   = errors.New("too many spectrogram requests")
+)
+
+// spectrogramQueued signals the spectrogram worker that a spectrogram
+// has been requested.
+var spectrogramQueued = make(chan struct{}, 1)
+
+// spectrogramHandler handles spectrogram requests for the audio of a
+// MID within a time range. Spectrograms that have been generated are
+// written as PNG images, otherwise the spectrogram is queued for
+// generation and the status is written as JSON with HTTP status 202,
+// i.e., clients should poll until the image is returned. Like
+// getHandler, users need not be logged in to access public sites.
+//
+// Spectrograms are generated from whole clips, so requests are
+// deduplicated by the clips within the time range, i.e., requests for
+// ranges spanning the same clips share a spectrogram. Users who are
+// not logged in may request up to spectrogramPublicRate new
+// spectrograms per minute per IP address, beyond which requests fail
+// with HTTP status 429.
+//
+// Query params:
+//
+//	id:    Media ID
+//	ts:    timestamp range
+//	retry: "true" to retry a failed spectrogram
+func spectrogramHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	p, _ := getProfile(w, r) // Ignore errors, since users need not be logged in.
+
+	q := r.URL.Query()
+	mid, err := strconv.ParseInt(q.Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, errInvalidMID)
+		return
+	}
+	if _, pin := model.FromMID(mid); pin[0] != 'S' {
+		writeError(w, errNotAudio)
+		return
+	}
+	t := q.Get("ts")
+	if t == "" {
+		writeError(w, errMissingTimestamp)
+		return
+	}
+	ts, err := splitTimestamps(t, false)
+	if err != nil {
+		writeError(w, errInvalidTimestamp)
+		return
+	}
+	if len(ts) != 2 || ts[1] <= ts[0] || ts[1]-ts[0] > spectrogramMaxDuration {
+		writeError(w, errSpectrogramRange)
+		return
+	}
+
+	ctx := r.Context()
+	setup(ctx)
+
+	ok, err := hasPermission(ctx, p, mid, model.ReadPermission)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok {
+		writeError(w, errPermissionDenied)
+		return
+	}
+
+	start, end, err := clipRange(ctx, mid, ts[0], ts[1])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var email string
+	if p != nil {
+		email = p.Email
+	}
+	s, err := model.GetSpectrogram(ctx, mediaStore, mid, start, end)
+	switch {
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+		if p == nil && !takeSpectrogramToken(w, r) {
+			return
+		}
+		s, err = model.RequestSpectrogram(ctx, mediaStore, mid, start, end, email)
+	case err == nil && s.Status == model.SpectrogramFailed && q.Get("retry") == "true":
+		if p == nil && !takeSpectrogramToken(w, r) {
+			return
+		}
+		err = model.DeleteSpectrogram(ctx, mediaStore, mid, start, end)
+		if err == nil {
+			s, err = model.RequestSpectrogram(ctx, mediaStore, mid, start, end, email)
+		}
+		if err != nil {
+			err = fmt.Errorf("could not retry spectrogram: %w", err)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch s.Status {
+	case model.SpectrogramDone:
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		w.Write(s.Image)
+	case model.SpectrogramFailed:
+		writeError(w, fmt.Errorf("could not generate spectrogram: %s", s.Error))
+	default:
+		queueSpectrogram()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": s.Status})
+	}
+}
+
+// clipRange returns the time range of the clips of a MID within the
+// time range from start to end, which are those whose timestamps fall
+// within it. Since spectrograms are generated from whole clips, this
+// determines the audio of a spectrogram, and so identifies it.
+func clipRange(ctx context.Context, mid, start, end int64) (int64, int64, error) {
+	keys, err := model.GetMtsMediaKeys(ctx, mediaStore, mid, nil, []int64{start, end})
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get media keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return 0, 0, errNoAudio
+	}
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, k := range keys {
+		_, ts, _ := datastore.SplitIDKey(k.ID)
+		first, last = min(first, ts), max(last, ts)
+	}
+	return first, last + 1, nil
+}
+
+// takeSpectrogramToken takes a token from the spectrogram bucket of
+// the request's IP address, returning true if successful, or writing a
+// 429 response otherwise. Rate limiting errors are logged but do not
+// block requests.
+func takeSpectrogramToken(w http.ResponseWriter, r *http.Request) bool {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	ok, _, err := model.TakeToken(r.Context(), settingsStore, "spectrogram."+ip, spectrogramPublicRate/60.0, spectrogramPublicRate)
+	if err != nil {
+		log.Printf("could not take spectrogram token for %s: %v", ip, err)
+		return true
+	}
+	if !ok {
+		w.Header().Set("Retry-After", "60")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		writeError(w, errSpectrogramRate)
+	}
+	return ok
+}
+
+// queueSpectrogram signals the spectrogram worker without blocking.
+func queueSpectrogram() {
+	select {
+	case spectrogramQueued <- struct{}{}:
+	default:
+	}
+}
+
+// spectrogramWorker generates pending spectrograms whenever a
+// spectrogram is requested, or periodically in order to pick up
+// spectrograms requested from other instances or whose generation was
+// interrupted.
+func spectrogramWorker(ctx context.Context) {
+	ticker := time.NewTicker(spectrogramPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-spectrogramQueued:
+		case <-ticker.C:
+		}
+		for processSpectrograms(ctx) == spectrogramBatch {
+			// Continue while there may be more pending spectrograms.
+		}
+	}
+}
+
+// processSpectrograms generates a batch of pending spectrograms,
+// returning the number of spectrograms generated or failed.
+// Spectrograms claimed by another worker are skipped.
+func processSpectrograms(ctx context.Context) int {
+	pending, err := model.GetPendingSpectrograms(ctx, mediaStore, time.Now().Add(-spectrogramStale), spectrogramBatch)
+	if err != nil {
+		log.Printf("could not get pending spectrograms: %v", err)
+		return 0
+	}
+	var n int
+	for _, s := range pending {
+		_, err := model.ClaimSpectrogram(ctx, mediaStore, s.MID, s.Start, s.End, time.Now().Add(-spectrogramStale))
+		if errors.Is(err, model.ErrSpectrogramClaimed) {
+			continue
+		}
+		if err != nil {
+			log.Printf("could not claim spectrogram for %d from %d to %d: %v", s.MID, s.Start, s.End, err)
+			continue
+		}
+		n++
+		img, genErr := generateSpectrogram(ctx, s.MID, s.Start, s.End)
+		if genErr != nil {
+			log.Printf("could not generate spectrogram for %d from %d to %d: %v", s.MID, s.Start, s.End, genErr)
+		}
+		err = model.CompleteSpectrogram(ctx, mediaStore, s.MID, s.Start, s.End, img, genErr)
+		if err != nil {
+			log.Printf("could not complete spectrogram for %d from %d to %d: %v", s.MID, s.Start, s.End, err)
+		}
+	}
+	return n
+}
+
+// generateSpectrogram generates a PNG spectrogram of the audio of a
+// MID from start to end. Only the first channel of multichannel audio
+// is used.
+func generateSpectrogram(ctx context.Context, mid, start, end int64) ([]byte, error) {
+	media, err := model.GetMtsMedia(ctx, mediaStore, mid, nil, []int64{start, end})
+	if err != nil {
+		return nil, fmt.Errorf("could not get media: %w", err)
+	}
+	if len(media) == 0 {
+		return nil, errNoAudio
+	}
+	clip, err := mts.Extract(joinMedia(media))
+	if err != nil {
+		return nil, errCannotExtractMedia
+	}
+	if len(clip.Frames()) == 0 {
+		return nil, errNoAudio
+	}
+
+	// Get audio parameters from the metadata, as per convertPcmToWav.
+	meta := clip.Frames()[0].Meta
+	channels, err := strconv.Atoi(meta["channels"])
+	if err != nil || channels < 1 {
+		return nil, fmt.Errorf("invalid number of channels: %q", meta["channels"])
+	}
+	audio := clip.Bytes()
+	switch meta["codec"] {
+	case codecutil.PCM:
+		if meta["bitDepth"] != "16" {
+			return nil, fmt.Errorf("%w: %s-bit PCM", errUnsupportedCodec, meta["bitDepth"])
+		}
+	case codecutil.ADPCM:
+		decoded := bytes.NewBuffer(make([]byte, 0, len(audio)*4))
+		_, err = adpcm.NewDecoder(decoded).Write(audio)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode ADPCM: %w", err)
+		}
+		audio = decoded.Bytes()
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedCodec, meta["codec"])
+	}
+
+	img, err := renderSpectrogram(firstChannel(audio, channels))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// firstChannel returns the samples of the first channel of 16-bit
+// little-endian PCM audio.
+func firstChannel(audio []byte, channels int) []float64 {
+	stride := 2 * channels
+	samples := make([]float64, 0, len(audio)/stride)
+	for i := 0; i+1 < len(audio); i += stride {
+		samples = append(samples, float64(int16(binary.LittleEndian.Uint16(audio[i:]))))
+	}
+	return samples
+}
+
+// renderSpectrogram renders a spectrogram of the given samples, with
+// time increasing to the right and frequency increasing upwards. Each
+// column is the power spectrum of a Hann-windowed frame of samples,
+// with frames overlapping by 75% unless more columns than
+// spectrogramMaxWidth would result. Power is scaled relative to the
+// maximum over spectrogramRange dB.
+func renderSpectrogram(samples []float64) (image.Image, error) {
+	const n = spectrogramWindow
+	if len(samples) < n {
+		return nil, errInsufficientAudio
+	}
+	hop := n / 4
+	cols := (len(samples)-n)/hop + 1
+	if cols > spectrogramMaxWidth {
+		hop = (len(samples) - n) / (spectrogramMaxWidth - 1)
+		cols = spectrogramMaxWidth
+	}
+
+	// Compute power spectra in dB.
+	hann := window.Hann(n)
+	frame := make([]float64, n)
+	power := make([][]float64, cols)
+	peak := math.Inf(-1)
+	for c := range power {
+		for i := range frame {
+			frame[i] = samples[c*hop+i] * hann[i]
+		}
+		spec := fft.FFTReal(frame)
+		power[c] = make([]float64, n/2)
+		for f := range power[c] {
+			p := 20 * math.Log10(cmplx.Abs(spec[f])+1e-9)
+			power[c][f] = p
+			peak = math.Max(peak, p)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, cols, n/2))
+	for c := range power {
+		for f, p := range power[c] {
+			img.Set(c, n/2-1-f, heatColor(1+(p-peak)/spectrogramRange))
+		}
+	}
+	return img, nil
+}
+
+// heatColor returns the colour of a value between 0 and 1 on a heat
+// map running from black through red and yellow to white. Values
+// outside this range are clamped.
+func heatColor(v float64) color.RGBA {
+	v = math.Min(math.Max(v, 0), 1)
+	scale := func(x float64) uint8 { return uint8(math.Min(math.Max(x, 0), 1) * 255) }
+	return color.RGBA{R: scale(3 * v), G: scale(3*v - 1), B: scale(3*v - 2), A: 255}
+}
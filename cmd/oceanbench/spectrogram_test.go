@@ -0,0 +1,143 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestFirstChannel(t *testing.T) {
+	audio := make([]byte, 8)
+	for i, v := range []int16{1, -1, 2, -2} {
+		binary.LittleEndian.PutUint16(audio[2*i:], uint16(v))
+	}
+	got := firstChannel(audio, 2)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("firstChannel returned %v, expected [1 2]", got)
+	}
+}
+
+func TestRenderSpectrogram(t *testing.T) {
+	const (
+		rate = 8000
+		freq = 1000
+	)
+	_, err := renderSpectrogram(make([]float64, spectrogramWindow-1))
+	if err != errInsufficientAudio {
+		t.Errorf("renderSpectrogram of short audio returned %v, expected %v", err, errInsufficientAudio)
+	}
+
+	samples := make([]float64, 60*rate)
+	for i := range samples {
+		samples[i] = 10000 * math.Sin(2*math.Pi*freq*float64(i)/rate)
+	}
+	img, err := renderSpectrogram(samples)
+	if err != nil {
+		t.Fatalf("renderSpectrogram returned error: %v", err)
+	}
+	b := img.Bounds()
+	if b.Dx() != spectrogramMaxWidth || b.Dy() != spectrogramWindow/2 {
+		t.Fatalf("renderSpectrogram returned %dx%d image, expected %dx%d", b.Dx(), b.Dy(), spectrogramMaxWidth, spectrogramWindow/2)
+	}
+
+	// The tone's frequency bin should be the brightest in each column.
+	bin := freq * spectrogramWindow / rate
+	wantY := spectrogramWindow/2 - 1 - bin
+	for _, x := range []int{0, b.Dx() / 2, b.Dx() - 1} {
+		brightest, brightestY := -1, -1
+		for y := 0; y < b.Dy(); y++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if v := int(c.R) + int(c.G) + int(c.B); v > brightest {
+				brightest, brightestY = v, y
+			}
+		}
+		if brightestY != wantY {
+			t.Errorf("brightest row in column %d is %d, expected %d", x, brightestY, wantY)
+		}
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want color.RGBA
+	}{
+		{v: -1, want: color.RGBA{A: 255}},
+		{v: 0, want: color.RGBA{A: 255}},
+		{v: 1, want: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{v: 2, want: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+	for _, test := range tests {
+		got := heatColor(test.v)
+		if got != test.want {
+			t.Errorf("heatColor(%v) returned %v, expected %v", test.v, got, test.want)
+		}
+	}
+}
+
+func TestClipRange(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	defer func(store datastore.Store) { mediaStore = store }(mediaStore)
+	mediaStore = store
+
+	mid := model.ToMID("0A:00:00:00:00:03", "S0")
+	const t0 = datastore.EpochStart + 1000
+	for _, ts := range []int64{t0, t0 + 60, t0 + 120} {
+		_, err = store.Put(ctx, store.IDKey("MtsMedia", datastore.IDKey(mid, ts, 0)), &model.MtsMedia{MID: mid, Timestamp: ts})
+		if err != nil {
+			t.Fatalf("could not put media: %v", err)
+		}
+	}
+
+	tests := []struct {
+		start, end int64
+		want       [2]int64
+		wantErr    error
+	}{
+		{start: t0, end: t0 + 200, want: [2]int64{t0, t0 + 121}},
+		{start: t0 - 10, end: t0 + 100, want: [2]int64{t0, t0 + 61}},
+		{start: t0 + 1, end: t0 + 121, want: [2]int64{t0 + 60, t0 + 121}},
+		{start: t0 + 10, end: t0 + 50, wantErr: errNoAudio},
+	}
+	for _, test := range tests {
+		start, end, err := clipRange(ctx, mid, test.start, test.end)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("clipRange(%d, %d) returned error %v, expected %v", test.start, test.end, err, test.wantErr)
+			continue
+		}
+		if err == nil && [2]int64{start, end} != test.want {
+			t.Errorf("clipRange(%d, %d) returned %d, %d, expected %v", test.start, test.end, start, end, test.want)
+		}
+	}
+}
@@ -30,14 +30,18 @@ LICENSE
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/openfish/datastore"
 )
 
+var errNoText = errors.New("no text found")
+
 // getText handles text data requests. The text data and mime type are returned.
 func getText(r *http.Request, mid int64, ts []int64, ky []uint64) ([]byte, string, error) {
 	// Download text data.
@@ -45,6 +49,9 @@ func getText(r *http.Request, mid int64, ts []int64, ky []uint64) ([]byte, strin
 	if err != nil && !errors.Is(err, datastore.ErrNoSuchEntity) {
 		return nil, "", fmt.Errorf("could not get text from datastore: %w", err)
 	}
+	if len(media) == 0 {
+		return nil, "", noTextError(r.Context(), ts)
+	}
 
 	mime := media[0].Type
 	return joinText(media), mime, nil
@@ -59,3 +66,22 @@ func joinText(texts []model.Text) []byte {
 	}
 	return data
 }
+
+// noTextError returns the error for a request for text that does not
+// exist, explaining if text for the requested period has been purged
+// as per its retention, so that intentionally deleted text is not
+// mistaken for missing data.
+func noTextError(ctx context.Context, ts []int64) error {
+	if len(ts) == 0 {
+		return errNoText
+	}
+	m, err := textRetentions(ctx)
+	if err != nil {
+		return errNoText
+	}
+	purgedBefore := m.PurgedBefore("", time.Now())
+	if purgedBefore == 0 || ts[0] >= purgedBefore {
+		return errNoText
+	}
+	return fmt.Errorf("%w: text before %s has been deleted as per the retention policy", errNoText, time.Unix(purgedBefore, 0).UTC().Format(time.DateOnly))
+}
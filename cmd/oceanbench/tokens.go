@@ -0,0 +1,130 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+// Provisioning token limits.
+const (
+	maxTokens       = 100
+	maxTokenUses    = 100
+	maxTokenExpiry  = 30 // Days.
+	defaultTokenAge = 1  // Days.
+)
+
+// tokensData stores the data served to the admin tokens page.
+type tokensData struct {
+	Tokens []model.ProvisioningToken
+	commonData
+}
+
+// tokensHandler handles the admin provisioning tokens page, which
+// lists the site's outstanding and used tokens. Tokens are used by
+// Ocean Center to install devices into the site. POST requests
+// perform the task given by the task param:
+//
+//   - create: create a batch of n tokens, each valid for mu uses and
+//     ed days.
+//   - delete: delete token tk.
+func tokensHandler(w http.ResponseWriter, r *http.Request, p *gauth.Profile) {
+	ctx := r.Context()
+	skey, _ := profileData(p)
+
+	data := tokensData{
+		commonData: commonData{
+			Pages:   pages("tokens"),
+			Profile: p,
+		},
+	}
+
+	var msg string
+	if r.Method == "POST" {
+		err := tokensTaskHandler(r, p)
+		if err != nil {
+			msg = err.Error()
+		}
+	}
+
+	var err error
+	data.Tokens, err = model.GetProvisioningTokens(ctx, settingsStore, skey)
+	if err != nil {
+		msg = fmt.Sprintf("cannot get tokens: %v", err)
+	}
+	writeTemplate(w, r, "tokens.html", &data, msg)
+}
+
+// tokensTaskHandler handles a provisioning tokens task.
+func tokensTaskHandler(r *http.Request, p *gauth.Profile) error {
+	ctx := r.Context()
+	skey, _ := profileData(p)
+
+	switch r.FormValue("task") {
+	case "create":
+		n, err := strconv.Atoi(r.FormValue("n"))
+		if err != nil || n < 1 || n > maxTokens {
+			return fmt.Errorf("number of tokens must be between 1 and %d", maxTokens)
+		}
+		mu, err := strconv.ParseInt(r.FormValue("mu"), 10, 64)
+		if err != nil || mu < 1 || mu > maxTokenUses {
+			return fmt.Errorf("uses must be between 1 and %d", maxTokenUses)
+		}
+		ed := defaultTokenAge
+		if v := r.FormValue("ed"); v != "" {
+			ed, err = strconv.Atoi(v)
+			if err != nil || ed < 1 || ed > maxTokenExpiry {
+				return fmt.Errorf("expiry must be between 1 and %d days", maxTokenExpiry)
+			}
+		}
+		_, err = model.CreateProvisioningTokens(ctx, settingsStore, skey, n, mu, time.Now().AddDate(0, 0, ed), p.Email)
+		if err != nil {
+			return fmt.Errorf("cannot create tokens: %w", err)
+		}
+		return nil
+
+	case "delete":
+		tk, err := strconv.ParseInt(r.FormValue("tk"), 10, 64)
+		if err != nil {
+			return errors.New("invalid token")
+		}
+		toks, err := model.GetProvisioningTokens(ctx, settingsStore, skey)
+		if err != nil {
+			return fmt.Errorf("cannot get tokens: %w", err)
+		}
+		for _, t := range toks {
+			if t.Token == tk {
+				return model.DeleteProvisioningToken(ctx, settingsStore, tk)
+			}
+		}
+		return errors.New("token not found")
+
+	default:
+		return errors.New("invalid task")
+	}
+}
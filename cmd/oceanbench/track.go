@@ -0,0 +1,154 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Track map defaults.
+const (
+	defaultTrackHours = 24
+	maxTrackHours     = 24 * 7
+	maxTrackGap       = time.Minute // Maximum gap between media and the nearest track point.
+)
+
+// trackData stores the data served to the track page.
+type trackData struct {
+	Ma       string              // Device MAC address.
+	Pn       string              // Media pin, e.g., V0.
+	Hr       int                 // Hours of track shown.
+	Track    []model.TrackPoint  // Track, ordered by time.
+	Captures []mediaCapturePoint // Media capture points, ordered by time.
+	Devices  []model.Device      // Site devices.
+	commonData
+}
+
+// mediaCapturePoint represents media captured at a given track
+// point. Media captured within maxTrackGap of the same track point
+// is represented by a single capture point.
+type mediaCapturePoint struct {
+	Latitude  float64
+	Longitude float64
+	Start     int64 // Unix time of the first media.
+	End       int64 // Unix time of the last media.
+	Count     int   // Number of media.
+}
+
+// trackHandler handles the track page, which shows the GPS track
+// recorded in standalone mode on a map, overlaid with points at which
+// media was captured by the device with MAC ma on pin pn. The track
+// covers the last hr hours.
+func trackHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+
+	data := trackData{
+		Ma: r.FormValue("ma"),
+		Pn: r.FormValue("pn"),
+		Hr: defaultTrackHours,
+		commonData: commonData{
+			Pages:   pages("track"),
+			Profile: profile,
+		},
+	}
+	if v := r.FormValue("hr"); v != "" {
+		data.Hr, err = strconv.Atoi(v)
+		if err != nil || data.Hr < 1 || data.Hr > maxTrackHours {
+			data.Hr = defaultTrackHours
+			writeTemplate(w, r, "track.html", &data, fmt.Sprintf("hours must be between 1 and %d", maxTrackHours))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	skey, _ := profileData(profile)
+	data.Devices, err = model.GetDevicesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		writeTemplate(w, r, "track.html", &data, fmt.Sprintf("could not get devices: %v", err))
+		return
+	}
+
+	end := time.Now().Unix() + 1
+	start := end - int64(data.Hr)*60*60
+	data.Track, err = model.GetTrack(ctx, mediaStore, skey, start, end)
+	if err != nil {
+		writeTemplate(w, r, "track.html", &data, fmt.Sprintf("could not get track: %v", err))
+		return
+	}
+	if len(data.Track) == 0 || data.Ma == "" {
+		writeTemplate(w, r, "track.html", &data, "")
+		return
+	}
+
+	if data.Pn == "" {
+		data.Pn = "V0"
+	}
+	keys, err := model.GetMtsMediaKeys(ctx, mediaStore, model.ToMID(data.Ma, data.Pn), nil, []int64{start, end})
+	if err != nil {
+		writeTemplate(w, r, "track.html", &data, fmt.Sprintf("could not get media: %v", err))
+		return
+	}
+	data.Captures = captures(data.Track, keys)
+	writeTemplate(w, r, "track.html", &data, "")
+}
+
+// captures returns the capture points of the media with the given
+// keys, ordered by time. Media more than maxTrackGap from the nearest
+// track point is omitted.
+func captures(track []model.TrackPoint, keys []*datastore.Key) []mediaCapturePoint {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	var cps []mediaCapturePoint
+	var last model.TrackPoint
+	for _, k := range keys {
+		_, ts, _ := datastore.SplitIDKey(k.ID)
+		p, ok := model.TrackPosition(track, ts, maxTrackGap)
+		if !ok {
+			continue
+		}
+		if len(cps) > 0 && p == last {
+			cp := &cps[len(cps)-1]
+			cp.End = max(cp.End, ts)
+			cp.Count++
+			continue
+		}
+		cps = append(cps, mediaCapturePoint{Latitude: p.Latitude, Longitude: p.Longitude, Start: ts, End: ts, Count: 1})
+		last = p
+	}
+	return cps
+}
@@ -0,0 +1,198 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Bench. Ocean Bench is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Bench is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+// defaultUsageDays is the default number of days of data usage shown.
+const defaultUsageDays = 30
+
+// siteUsage summarizes the data ingested from a site's devices over a
+// range of dates, as recorded by datablue.
+type siteUsage struct {
+	Skey     int64
+	Name     string
+	From, To string // Date range, inclusive, in model.DataUsageDateFormat.
+	Bytes    int64  // Total bytes.
+	Requests int64  // Total requests.
+	Devices  []deviceUsage
+	Days     []dayUsage
+}
+
+// deviceUsage is the data ingested from a device over a range of dates.
+type deviceUsage struct {
+	Name     string
+	MAC      string
+	Bytes    int64
+	Requests int64
+}
+
+// dayUsage is the data ingested from a site on a given day.
+type dayUsage struct {
+	Date    string
+	Bytes   int64
+	Devices map[string]int64 // Bytes by device MAC address.
+}
+
+// usageHandler handles the data usage page, which charts the volume
+// of data ingested from each device at the current site, as well as
+// the totals for each of the user's sites, in order to plan cellular
+// data budgets, and lists the site's archived broadcasts. The page is
+// populated from the /api/get/usage and /api/get/broadcasts endpoints.
+func usageHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	profile, err := getProfile(w, r)
+	if err != nil {
+		if err != gauth.TokenNotFound {
+			log.Printf("authentication error: %v", err)
+		}
+		http.Redirect(w, r, "/", http.StatusUnauthorized)
+		return
+	}
+
+	data := commonData{Pages: pages("usage"), Profile: profile}
+	writeTemplate(w, r, "usage.html", &data, "")
+}
+
+// usageDates returns the date range for a usage request, from the
+// optional from and to params, which default to the defaultUsageDays
+// ending today (UTC).
+func usageDates(r *http.Request) (from, to string, err error) {
+	end := time.Now().UTC()
+	if v := r.FormValue("to"); v != "" {
+		end, err = time.Parse(model.DataUsageDateFormat, v)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid to date: %s", v)
+		}
+	}
+	start := end.AddDate(0, 0, 1-defaultUsageDays)
+	if v := r.FormValue("from"); v != "" {
+		start, err = time.Parse(model.DataUsageDateFormat, v)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid from date: %s", v)
+		}
+	}
+	if start.After(end) {
+		return "", "", fmt.Errorf("from date is after to date")
+	}
+	return start.Format(model.DataUsageDateFormat), end.Format(model.DataUsageDateFormat), nil
+}
+
+// getSiteUsage returns the data usage for a site from date from to
+// date to, inclusive.
+func getSiteUsage(ctx context.Context, skey int64, from, to string) (*siteUsage, error) {
+	site, err := model.GetSite(ctx, settingsStore, skey)
+	if err != nil {
+		return nil, fmt.Errorf("could not get site: %w", err)
+	}
+	usage, err := model.GetDataUsage(ctx, settingsStore, skey, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not get data usage: %w", err)
+	}
+	devs, err := model.GetDevicesBySite(ctx, settingsStore, skey)
+	if err != nil {
+		return nil, fmt.Errorf("could not get devices: %w", err)
+	}
+	names := make(map[int64]string)
+	for _, dev := range devs {
+		names[dev.Mac] = dev.Name
+	}
+	su := summarizeUsage(usage, names)
+	su.Skey = skey
+	su.Name = site.Name
+	su.From = from
+	su.To = to
+	return su, nil
+}
+
+// summarizeUsage summarizes data usage, which must be ordered by date
+// as per model.GetDataUsage, using names to name devices. Devices are
+// ordered by decreasing bytes.
+func summarizeUsage(usage []model.DataUsage, names map[int64]string) *siteUsage {
+	su := &siteUsage{}
+	devs := make(map[int64]*deviceUsage)
+	for _, u := range usage {
+		mac := model.MacDecode(u.Mac)
+		du, ok := devs[u.Mac]
+		if !ok {
+			du = &deviceUsage{Name: names[u.Mac], MAC: mac}
+			devs[u.Mac] = du
+		}
+		du.Bytes += u.Bytes
+		du.Requests += u.Requests
+		su.Bytes += u.Bytes
+		su.Requests += u.Requests
+
+		if len(su.Days) == 0 || su.Days[len(su.Days)-1].Date != u.Date {
+			su.Days = append(su.Days, dayUsage{Date: u.Date, Devices: make(map[string]int64)})
+		}
+		day := &su.Days[len(su.Days)-1]
+		day.Bytes += u.Bytes
+		day.Devices[mac] += u.Bytes
+	}
+	for _, du := range devs {
+		su.Devices = append(su.Devices, *du)
+	}
+	sort.Slice(su.Devices, func(i, j int) bool {
+		if su.Devices[i].Bytes != su.Devices[j].Bytes {
+			return su.Devices[i].Bytes > su.Devices[j].Bytes
+		}
+		return su.Devices[i].MAC < su.Devices[j].MAC
+	})
+	return su
+}
+
+// sitesUsage returns the total data usage for each site for which the
+// user with the given email has read permission, ordered by name.
+// Device and daily breakdowns are omitted.
+func sitesUsage(ctx context.Context, email, from, to string) ([]siteUsage, error) {
+	users, err := model.GetUsers(ctx, settingsStore, email)
+	if err != nil {
+		return nil, fmt.Errorf("could not get users: %w", err)
+	}
+	var sus []siteUsage
+	for _, u := range users {
+		if u.Perm&model.ReadPermission == 0 {
+			continue
+		}
+		su, err := getSiteUsage(ctx, u.Skey, from, to)
+		if err != nil {
+			log.Printf("could not get usage for site %d: %v", u.Skey, err)
+			continue
+		}
+		su.Devices = nil
+		su.Days = nil
+		sus = append(sus, *su)
+	}
+	sort.Slice(sus, func(i, j int) bool { return sus[i].Name < sus[j].Name })
+	return sus, nil
+}
@@ -0,0 +1,118 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Center. Ocean Center is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Center is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Center in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// deviceResponse is the JSON representation of a device's lifecycle state.
+type deviceResponse struct {
+	MAC     string `json:"ma"`
+	Skey    int64  `json:"sk"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Updated int64  `json:"updated"`
+}
+
+// archiveResponse is the JSON representation of a device archive.
+type archiveResponse struct {
+	MAC      string `json:"ma"`
+	Skey     int64  `json:"sk"`
+	Name     string `json:"name"`
+	Reason   string `json:"reason"`
+	Archived int64  `json:"archived"`
+}
+
+// deviceHandler handles device lifecycle requests from operators,
+// which are authorized by checkAdmin. The ma parameter is the MAC
+// address of the device and the op parameter is one of:
+//
+// - enable: enable the device.
+// - disable: disable the device. Disabled devices receive a config
+// without pins from Data Blue, so they stop reporting.
+// - decommission: archive the device's configuration then delete the
+// device, freeing its MAC address for reuse, with an optional reason.
+// - archives: list the archives for the MAC address.
+//
+// The response is the device or archive(s) in JSON format.
+func (svc *service) deviceHandler(w http.ResponseWriter, r *http.Request) {
+	svc.logRequest(r)
+	ctx := r.Context()
+
+	if !svc.checkAdmin(w, r) {
+		return
+	}
+
+	ma := r.FormValue("ma")
+	mac := model.MacEncode(ma)
+	if mac == 0 {
+		writeError(w, http.StatusBadRequest, "ma invalid MAC address")
+		return
+	}
+
+	switch op := r.FormValue("op"); op {
+	case "enable", "disable":
+		dev, err := model.GetDevice(ctx, svc.settingsStore, mac)
+		if err != nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("could not get device: %v", err))
+			return
+		}
+		dev.Enabled = op == "enable"
+		dev.Updated = time.Now()
+		err = model.PutDevice(ctx, svc.settingsStore, dev)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not put device: %v", err))
+			return
+		}
+		log.Printf("device %s %sd", ma, op)
+		writeJSON(w, deviceResponse{MAC: dev.MAC(), Skey: dev.Skey, Name: dev.Name, Enabled: dev.Enabled, Updated: dev.Updated.Unix()})
+
+	case "decommission":
+		a, err := model.DecommissionDevice(ctx, svc.settingsStore, mac, r.FormValue("reason"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not decommission device: %v", err))
+			return
+		}
+		log.Printf("device %s decommissioned from site %d", ma, a.Skey)
+		writeJSON(w, archiveResponse{MAC: model.MacDecode(a.Mac), Skey: a.Skey, Name: a.Name, Reason: a.Reason, Archived: a.Archived.Unix()})
+
+	case "archives":
+		as, err := model.GetDeviceArchives(ctx, svc.settingsStore, mac)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not get device archives: %v", err))
+			return
+		}
+		resp := []archiveResponse{}
+		for _, a := range as {
+			resp = append(resp, archiveResponse{MAC: model.MacDecode(a.Mac), Skey: a.Skey, Name: a.Name, Reason: a.Reason, Archived: a.Archived.Unix()})
+		}
+		writeJSON(w, resp)
+
+	default:
+		writeError(w, http.StatusBadRequest, "invalid op: "+op)
+	}
+}
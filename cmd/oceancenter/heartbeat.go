@@ -0,0 +1,379 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Center. Ocean Center is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Center is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Center in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Heartbeat constants.
+const (
+	cronServiceURL                  = "https://oceancron.appspot.com"
+	cronServiceAccount              = "oceancron@appspot.gserviceaccount.com"
+	notifyGoneDark      notify.Kind = "gone-dark"
+	notifyGoneDarkOwner notify.Kind = "gone-dark-owner"
+	escalationVar                   = "escalation" // Device variable holding the current escalation level.
+)
+
+// checkHeartbeatsHandler checks the heartbeats of the devices for a
+// single site, escalating according to the site's escalation policy.
+// It is designed to be invoked periodically via OceanCron rpc
+// requests, which are signed with the cron secret and specify the
+// site key.
+func (svc *service) checkHeartbeatsHandler(w http.ResponseWriter, r *http.Request) {
+	svc.logRequest(r)
+	ctx := r.Context()
+
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), svc.cronSecret)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("request from %s has invalid claims: %v", r.RemoteAddr, err))
+		return
+	}
+	if claims["iss"] != cronServiceAccount {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("request from %s has invalid issuer: %q", r.RemoteAddr, claims["iss"]))
+		return
+	}
+	sk, ok := claims["skey"].(float64)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("request from %s has invalid skey: %q", r.RemoteAddr, claims["skey"]))
+		return
+	}
+
+	err = svc.checkHeartbeats(ctx, int64(sk))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not check heartbeats for site %d: %v", int64(sk), err))
+		return
+	}
+}
+
+// checkHeartbeats checks the heartbeats of the devices for a site. A
+// device's heartbeat is its uptime variable, which is updated each
+// time the device reports. The escalation level of each device is
+// stored in a private device variable so that each level is only
+// acted upon once, when reached. When a device reports again, its
+// escalation level is reset and any open incident is resolved.
+func (svc *service) checkHeartbeats(ctx context.Context, skey int64) error {
+	policy, err := model.GetEscalationPolicy(ctx, svc.settingsStore, skey)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get escalation policy: %w", err)
+	}
+	devs, err := model.GetDevicesBySite(ctx, svc.settingsStore, skey)
+	if err != nil {
+		return fmt.Errorf("could not get devices: %w", err)
+	}
+
+	log.Printf("checking heartbeats for site %d", skey)
+	now := time.Now()
+	for _, dev := range devs {
+		if !dev.Enabled {
+			continue
+		}
+		v, err := model.GetVariable(ctx, svc.settingsStore, skey, "_"+dev.Hex()+".uptime")
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			continue // Never reported.
+		}
+		if err != nil {
+			log.Printf("could not get uptime for device %s: %v", dev.MAC(), err)
+			continue
+		}
+		missed := model.MissedReports(&dev, v.Updated, now)
+		err = svc.escalate(ctx, policy, &dev, missed)
+		if err != nil {
+			log.Printf("could not escalate device %s: %v", dev.MAC(), err)
+		}
+	}
+	return nil
+}
+
+// escalate escalates or de-escalates a device, according to the
+// number of missed reports.
+func (svc *service) escalate(ctx context.Context, policy *model.EscalationPolicy, dev *model.Device, missed int64) error {
+	name := "_" + dev.Hex() + "." + escalationVar
+	prev := model.EscalateNone
+	v, err := model.GetVariable(ctx, svc.settingsStore, dev.Skey, name)
+	switch {
+	case err == nil:
+		prev, _ = strconv.Atoi(v.Value)
+	case !errors.Is(err, datastore.ErrNoSuchEntity):
+		return fmt.Errorf("could not get escalation level: %w", err)
+	}
+
+	level := policy.Level(missed)
+	if level == prev {
+		return nil
+	}
+	if level == model.EscalateNone {
+		err = model.DeleteVariable(ctx, svc.settingsStore, dev.Skey, name)
+		if err != nil {
+			return fmt.Errorf("could not delete escalation level: %w", err)
+		}
+		inc, err := model.GetOpenIncident(ctx, svc.settingsStore, dev.Skey, dev.Mac)
+		if err == nil {
+			err = model.ResolveIncident(ctx, svc.settingsStore, inc, "device reported")
+			if err != nil {
+				return fmt.Errorf("could not resolve incident: %w", err)
+			}
+		}
+		svc.notify(ctx, dev.Skey, notifyGoneDark, "Device %s (%s) in site %d is reporting again.", dev.Name, dev.MAC(), dev.Skey)
+		return nil
+	}
+
+	err = model.PutVariable(ctx, svc.settingsStore, dev.Skey, name, strconv.Itoa(level))
+	if err != nil {
+		return fmt.Errorf("could not put escalation level: %w", err)
+	}
+	if level < prev {
+		return nil // The policy has changed.
+	}
+
+	msg := fmt.Sprintf("Device %s (%s) in site %d has missed %d reports.", dev.Name, dev.MAC(), dev.Skey, missed)
+	switch level {
+	case model.EscalateOps:
+		svc.notify(ctx, dev.Skey, notifyGoneDark, "%s", msg)
+
+	case model.EscalateOwner:
+		svc.notify(ctx, dev.Skey, notifyGoneDarkOwner, "%s", msg)
+
+	case model.EscalateIncident:
+		inc, err := model.OpenIncident(ctx, svc.settingsStore, dev.Skey, dev.Mac, missed)
+		if errors.Is(err, model.ErrIncidentOpen) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not open incident: %w", err)
+		}
+		msg += fmt.Sprintf("\nOpened incident %d.", inc.ID)
+		if policy.PowerCycle != "" {
+			note := "ran power cycle cron " + policy.PowerCycle
+			err = svc.runCron(dev.Skey, policy.PowerCycle)
+			if err != nil {
+				note = fmt.Sprintf("could not run power cycle cron %s: %v", policy.PowerCycle, err)
+			}
+			inc.Notes = note
+			err = model.PutIncident(ctx, svc.settingsStore, inc)
+			if err != nil {
+				log.Printf("could not put incident %d: %v", inc.ID, err)
+			}
+			msg += "\n" + strings.ToUpper(note[:1]) + note[1:] + "."
+		}
+		svc.notify(ctx, dev.Skey, notifyGoneDark, "%s", msg)
+	}
+	return nil
+}
+
+// runCron requests Ocean Cron to run a site's cron immediately. The
+// request carries a JWT signed with the cron secret, the same as
+// OceanCron rpc requests.
+func (svc *service) runCron(skey int64, id string) error {
+	url := svc.cronURL + "/cron/run/" + strconv.FormatInt(skey, 10) + "/" + id
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid cron request: %w", err)
+	}
+	tokString, err := gauth.PutClaims(map[string]interface{}{"iss": cronServiceAccount, "skey": skey}, svc.cronSecret)
+	if err != nil {
+		return fmt.Errorf("error signing cron request claims: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tokString)
+	clt := &http.Client{Timeout: time.Minute}
+	resp, err := clt.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending cron request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("cron request failed with status code: " + http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// notify logs and sends a notification, logging any error.
+func (svc *service) notify(ctx context.Context, skey int64, kind notify.Kind, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	err := svc.notifier.Send(ctx, skey, kind, msg)
+	if err != nil {
+		log.Printf("could not send notification: %v", err)
+	}
+}
+
+// recipients looks up the notification recipients for a site and
+// notification kind. Gone dark notifications for the site owner go to
+// the owner, and all others to ops. Gone dark notifications are
+// rate-limited by escalation levels, so they have no minimum period.
+func (svc *service) recipients(skey int64, kind notify.Kind) ([]string, time.Duration, error) {
+	site, err := model.GetSite(context.Background(), svc.settingsStore, skey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not get site %d: %w", skey, err)
+	}
+	switch kind {
+	case notifyGoneDarkOwner:
+		return []string{site.OwnerEmail}, 0, nil
+	case notifyGoneDark:
+		return []string{site.OpsEmail}, 0, nil
+	default:
+		return []string{site.OpsEmail}, time.Duration(site.NotifyPeriod) * time.Hour, nil
+	}
+}
+
+// policyResponse is the JSON representation of an escalation policy.
+type policyResponse struct {
+	Skey          int64  `json:"sk"`
+	OpsAfter      int64  `json:"ops"`
+	OwnerAfter    int64  `json:"owner"`
+	IncidentAfter int64  `json:"incident"`
+	PowerCycle    string `json:"pc,omitempty"`
+}
+
+// incidentResponse is the JSON representation of an incident.
+type incidentResponse struct {
+	ID       int64  `json:"id"`
+	MAC      string `json:"ma"`
+	Missed   int64  `json:"missed"`
+	Opened   int64  `json:"opened"`
+	Resolved int64  `json:"resolved,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+}
+
+// escalationHandler handles escalation policy and incident requests
+// from operators, which are authorized by checkAdmin. The sk
+// parameter is the site key and the op parameter is one of:
+//
+// - get: get the site's escalation policy.
+// - set: set the site's escalation policy, with params ops, owner and
+// incident (missed reports before each level, or 0 to disable) and
+// pc (power cycle cron ID, optional).
+// - delete: delete the site's escalation policy.
+// - incidents: list the site's incidents.
+// - resolve: resolve incident id.
+//
+// The response is the policy or incident(s) in JSON format.
+func (svc *service) escalationHandler(w http.ResponseWriter, r *http.Request) {
+	svc.logRequest(r)
+	ctx := r.Context()
+
+	if !svc.checkAdmin(w, r) {
+		return
+	}
+
+	skey, err := strconv.ParseInt(r.FormValue("sk"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid sk param")
+		return
+	}
+
+	switch op := r.FormValue("op"); op {
+	case "get":
+		p, err := model.GetEscalationPolicy(ctx, svc.settingsStore, skey)
+		if err != nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("could not get escalation policy: %v", err))
+			return
+		}
+		writeJSON(w, policyResponse{Skey: p.Skey, OpsAfter: p.OpsAfter, OwnerAfter: p.OwnerAfter, IncidentAfter: p.IncidentAfter, PowerCycle: p.PowerCycle})
+
+	case "set":
+		p := &model.EscalationPolicy{Skey: skey, PowerCycle: r.FormValue("pc")}
+		for param, n := range map[string]*int64{"ops": &p.OpsAfter, "owner": &p.OwnerAfter, "incident": &p.IncidentAfter} {
+			v := r.FormValue(param)
+			if v == "" {
+				continue
+			}
+			*n, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || *n < 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+param+" param")
+				return
+			}
+		}
+		err = model.PutEscalationPolicy(ctx, svc.settingsStore, p)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not put escalation policy: %v", err))
+			return
+		}
+		log.Printf("set escalation policy for site %d", skey)
+		writeJSON(w, policyResponse{Skey: p.Skey, OpsAfter: p.OpsAfter, OwnerAfter: p.OwnerAfter, IncidentAfter: p.IncidentAfter, PowerCycle: p.PowerCycle})
+
+	case "delete":
+		err = model.DeleteEscalationPolicy(ctx, svc.settingsStore, skey)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not delete escalation policy: %v", err))
+			return
+		}
+		log.Printf("deleted escalation policy for site %d", skey)
+		writeJSON(w, policyResponse{Skey: skey})
+
+	case "incidents":
+		incs, err := model.GetIncidents(ctx, svc.settingsStore, skey)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not get incidents: %v", err))
+			return
+		}
+		resp := []incidentResponse{}
+		for _, inc := range incs {
+			resp = append(resp, newIncidentResponse(&inc))
+		}
+		writeJSON(w, resp)
+
+	case "resolve":
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		var inc *model.Incident
+		if err == nil {
+			inc, err = model.GetIncident(ctx, svc.settingsStore, id)
+		}
+		if err != nil || inc.Skey != skey {
+			writeError(w, http.StatusNotFound, "invalid incident id")
+			return
+		}
+		err = model.ResolveIncident(ctx, svc.settingsStore, inc, "resolved by operator")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not resolve incident: %v", err))
+			return
+		}
+		log.Printf("resolved incident %d for site %d", id, skey)
+		writeJSON(w, newIncidentResponse(inc))
+
+	default:
+		writeError(w, http.StatusBadRequest, "invalid op: "+op)
+	}
+}
+
+// newIncidentResponse returns the JSON representation of an incident.
+func newIncidentResponse(inc *model.Incident) incidentResponse {
+	resp := incidentResponse{ID: inc.ID, MAC: model.MacDecode(inc.Mac), Missed: inc.Missed, Opened: inc.Opened.Unix(), Notes: inc.Notes}
+	if !inc.Open() {
+		resp.Resolved = inc.Resolved.Unix()
+	}
+	return resp
+}
@@ -24,8 +24,9 @@ LICENSE
 // Ocean Center is a cloud service for remote device management, including:
 //
 // - device software installation
-// - device software upgrades
-// - device enabling and disabling (TODO)
+// - device software upgrades, via staged rollouts
+// - device enabling, disabling and decommissioning
+// - device heartbeat checks, with escalation when devices go dark
 package main
 
 import (
@@ -50,8 +51,9 @@ import (
 
 // Project constants.
 const (
-	projectID = "oceancenter"
-	version   = "v0.2.3"
+	projectID  = "oceancenter"
+	projectURL = "https://oceancenter.appspot.com"
+	version    = "v0.3.0"
 )
 
 // Site/device defaults.
@@ -68,6 +70,9 @@ const (
 	localEmail    = "localuser@localhost"
 )
 
+// ackTimeout is the time before unacknowledged alerts are escalated.
+const ackTimeout = 30 * time.Minute
+
 // TOTP constants.
 const (
 	totpSecretKey   = "totpSecret"
@@ -80,6 +85,14 @@ const (
 	notifyNewDevice notify.Kind = "new-device"
 )
 
+// newDeviceTemplate is the default template for new device
+// notifications, which may be overridden in the datastore.
+var newDeviceTemplate = notify.Template{
+	Subject: "New device notification",
+	Body: "New device with MAC addresses {{.Vars.wi}}, {{.Device.MAC}} and device key {{.Vars.dk}} detected at {{.Vars.detected}} in site {{.Device.Skey}}.\n" +
+		"Configure at https://bench.cloudblue.org/set/devices/?ma={{.Device.MAC}}&sk=auto",
+}
+
 // service defines the properties of our web service.
 type service struct {
 	setupMutex    sync.Mutex
@@ -88,6 +101,10 @@ type service struct {
 	standalone    bool
 	notifier      notify.Notifier
 	totpSecret    []byte
+	releaseSecret []byte
+	cronSecret    []byte
+	ackSecret     []byte
+	cronURL       string
 	storePath     string
 }
 
@@ -111,6 +128,7 @@ func main() {
 	flag.StringVar(&host, "host", "localhost", "Host we run on in standalone mode")
 	flag.IntVar(&port, "port", defaultPort, "Port we listen on in standalone mode")
 	flag.StringVar(&app.storePath, "filestore", "store", "File store path")
+	flag.StringVar(&app.cronURL, "cronurl", cronServiceURL, "Cron service URL")
 	flag.Parse()
 
 	// Perform one-time setup or bail.
@@ -123,6 +141,13 @@ func main() {
 
 	http.HandleFunc("/", app.indexHandler)
 	http.HandleFunc("/install", app.installHandler)
+	http.HandleFunc("/upgrade", app.upgradeHandler)
+	http.HandleFunc("/report", app.reportHandler)
+	http.HandleFunc("/release", app.releaseHandler)
+	http.HandleFunc("/device", app.deviceHandler)
+	http.HandleFunc("/escalation", app.escalationHandler)
+	http.HandleFunc("/checkheartbeats", app.checkHeartbeatsHandler)
+	http.Handle("/ack", notify.NewAckHandler(app.settingsStore, app.ackSecret))
 
 	log.Printf("Listening on %s:%d", host, port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), nil))
@@ -203,11 +228,19 @@ func (svc *service) setup(ctx context.Context) {
 	if err != nil {
 		log.Fatalf("could not get secrets: %v", err)
 	}
+	svc.ackSecret = []byte(secrets[notify.AckSecretKey])
 	svc.notifier, err = notify.NewMailjetNotifier(
 		notify.WithSecrets(secrets),
-		notify.WithRecipient(site.OpsEmail),
+		notify.WithRecipientLookup(svc.recipients),
 		notify.WithStore(notify.NewStore(svc.settingsStore)),
-		notify.WithPeriod(time.Duration(site.NotifyPeriod)*time.Hour),
+		notify.WithTemplateStore(svc.settingsStore),
+		notify.WithHistory(svc.settingsStore),
+		notify.WithQuietHours(svc.settingsStore),
+		notify.WithRouteStore(svc.settingsStore),
+		notify.WithSeverity(notifyGoneDark, notify.SeverityWarning),
+		notify.WithSeverity(notifyRelease, notify.SeverityWarning),
+		notify.WithAcks(svc.settingsStore, svc.ackSecret, projectURL+"/ack", ackTimeout, notify.OwnerTier(svc.settingsStore)),
+		notify.WithTemplate(notifyNewDevice, newDeviceTemplate),
 	)
 	if err != nil {
 		log.Fatalf("could not set up email notifier: %v", err)
@@ -221,6 +254,21 @@ func (svc *service) setup(ctx context.Context) {
 	}
 	svc.totpSecret = []byte(secret)
 	log.Printf("set up TOTP")
+
+	// Get shared cron secret, required for heartbeat checks.
+	svc.cronSecret, err = gauth.GetHexSecret(ctx, "oceancron", "cronSecret")
+	if err != nil {
+		log.Printf("could not get cronSecret: %v", err)
+	}
+
+	// Get secret required for release management, which is
+	// unavailable without it.
+	secret, ok = secrets[releaseSecretKey]
+	if !ok {
+		log.Printf("could not get %s", releaseSecretKey)
+		return
+	}
+	svc.releaseSecret = []byte(secret)
 }
 
 // installHandler handles installation requests from new devices.
@@ -232,8 +280,10 @@ func (svc *service) setup(ctx context.Context) {
 //
 // If a request is received from an unknown device, and the device key
 // is a valid TOTP, a device is created and placed in the sandbox
-// site. Ops for the sandbox site is notified of the new device and
-// responsible for assigning the client type (ct). A device
+// site. Alternatively, the device key may be a provisioning token,
+// in which case the device is placed in the token's site. Ops for
+// the device's site is notified of the new device and responsible for
+// assigning the client type (ct). A device
 // configuration is not considered complete until its client type has
 // been assigned. Clients are therefore expected to periodically call
 // this method until the the device configuration has been completed
@@ -300,18 +350,7 @@ func (svc *service) installHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// We've detected a new device.
-	// Check if device key is a recently-generated TOTP.
-	ok, err := totp.CheckTOTP(dk, time.Now(), totpGracePeriod, totpDigits, svc.totpSecret)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not check TOTP: %v", err))
-		return
-	}
-	if !ok {
-		writeError(w, http.StatusBadRequest, "invalid device key")
-		return
-	}
-
-	// Provision a new device with a temporary name that includes the current time.
+	// Provision it with a temporary name that includes the current time.
 	now := time.Now()
 	loc, err := time.LoadLocation(sandboxLoc)
 	if err != nil {
@@ -330,9 +369,37 @@ func (svc *service) installHandler(w http.ResponseWriter, r *http.Request) {
 		Status:        model.DeviceStatusUpdate,
 		Updated:       now,
 	}
-	err = model.PutDevice(ctx, svc.settingsStore, dev)
+
+	// Check if device key is a recently-generated TOTP, else a provisioning token,
+	// whose use is undone if the device cannot be created.
+	ok, err := totp.CheckTOTP(dk, now, totpGracePeriod, totpDigits, svc.totpSecret)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not put device: %v", err))
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not check TOTP: %v", err))
+		return
+	}
+	if ok {
+		err = model.CreateDevice(ctx, svc.settingsStore, dev)
+	} else {
+		_, err = model.ProvisionDevice(ctx, svc.settingsStore, dkey, dev)
+	}
+	switch {
+	case errors.Is(err, model.ErrTokenNotFound):
+		writeError(w, http.StatusBadRequest, "invalid device key")
+		return
+	case errors.Is(err, model.ErrTokenExpired), errors.Is(err, model.ErrTokenUsed):
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid device key: %v", err))
+		return
+	case errors.Is(err, datastore.ErrEntityExists):
+		// The device was installed by a concurrent request.
+		dev, err = model.GetDevice(ctx, svc.settingsStore, mac)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not get device: %v", err))
+			return
+		}
+		writeDeviceConfig(w, dev)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not create device: %v", err))
 		return
 	}
 
@@ -340,26 +407,37 @@ func (svc *service) installHandler(w http.ResponseWriter, r *http.Request) {
 	// NB: This will be an incomplete config, since the client type is not yet known.
 	writeDeviceConfig(w, dev)
 
-	// Notify ops of the new device.
-	// TODO: Use a template once the notifier supports templates.
-	msg := fmt.Sprintf("New device with MAC addresses %s, %s and device key %s detected at %s.\nConfigure at https://bench.cloudblue.org/set/devices/?ma=%s&sk=auto",
-		wi, ma, dk, localTime.Format("2006-01-02 15:04:05"), ma)
-	err = svc.notifier.Send(ctx, sandboxSite, notifyNewDevice, msg)
+	// Notify ops for the device's site of the new device.
+	msg := fmt.Sprintf("New device %s detected in site %d.", ma, dev.Skey)
+	ctx = notify.NewContext(ctx, &notify.Data{Device: dev, Vars: map[string]string{"wi": wi, "dk": dk, "detected": localTime.Format("2006-01-02 15:04:05")}})
+	err = svc.notifier.Send(ctx, dev.Skey, notifyNewDevice, msg)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not send notification: %v", err))
 		return
 	}
 }
 
+// checkAdmin checks that an admin request is authorized by a JWT
+// signed with the release secret, as release management requests are,
+// writing an error otherwise.
+func (svc *service) checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	_, err := gauth.GetClaims(r.Header.Get("Authorization"), svc.releaseSecret)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("invalid claims: %v", err))
+		return false
+	}
+	return true
+}
+
 // writeDeviceConfig writes a minimal device configuration in CSV
 // format that can be used by clients to write netsender.conf.
 // The client type (ct) param is omitted when it is empty.
 func writeDeviceConfig(w http.ResponseWriter, dev *model.Device) {
 	var resp string
 	if dev.Type == "" {
-		resp = fmt.Sprintf("ma %s\ndk %d", dev.MAC(), dev.Dkey)
+		resp = fmt.Sprintf("ma %s\ndk %d", dev.MAC(), dev.ActiveKey())
 	} else {
-		resp = fmt.Sprintf("ma %s\ndk %d\nct %s", dev.MAC(), dev.Dkey, dev.Type)
+		resp = fmt.Sprintf("ma %s\ndk %d\nct %s", dev.MAC(), dev.ActiveKey(), dev.Type)
 	}
 	log.Printf("Replying with %s", strings.ReplaceAll(resp, "\n", " "))
 	w.Write([]byte(resp))
@@ -0,0 +1,344 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Center. Ocean Center is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Center is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Center in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Rollout constants. A release is halted automatically when more
+// than maxFailureRate of the devices that have reported its outcome
+// have failed, once at least minReports devices have reported.
+const (
+	releaseSecretKey             = "releaseSecret"
+	maxFailureRate               = 0.2
+	minReports                   = 5
+	notifyRelease    notify.Kind = "release"
+)
+
+// upgradeHandler handles requests from devices for their assigned
+// release. Two parameters are expected:
+//
+// - ma: MAC address.
+// - dk: device key.
+//
+// If a release is assigned to the device, the response is in
+// netsender.conf format, i.e.,
+//
+//	vn <version>
+//	url <artifact-URL>
+//	cs <SHA-256-checksum>
+//
+// otherwise the response is empty. Devices report the outcome of
+// the upgrade via /report.
+func (svc *service) upgradeHandler(w http.ResponseWriter, r *http.Request) {
+	svc.logRequest(r)
+	ctx := r.Context()
+
+	dev, ok := svc.checkDevice(w, r)
+	if !ok {
+		return
+	}
+
+	rs, err := model.GetReleases(ctx, svc.settingsStore, dev.Type)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not get releases: %v", err))
+		return
+	}
+	rel := model.AssignedRelease(rs, dev)
+	if rel == nil {
+		return
+	}
+
+	_, err = model.GetDeviceRelease(ctx, svc.settingsStore, rel.ID, dev.Mac)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		err = model.PutDeviceRelease(ctx, svc.settingsStore, &model.DeviceRelease{ReleaseID: rel.ID, Mac: dev.Mac, Status: model.DeviceReleaseAssigned})
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not record device release: %v", err))
+		return
+	}
+
+	resp := fmt.Sprintf("vn %s\nurl %s\ncs %s", rel.Version, artifactURL(rel.URL), rel.Checksum)
+	log.Printf("Replying with %s", strings.ReplaceAll(resp, "\n", " "))
+	w.Write([]byte(resp))
+}
+
+// reportHandler handles reports from devices of their current
+// version, following an upgrade. Three parameters are expected:
+//
+// - ma: MAC address.
+// - dk: device key.
+// - vn: current version.
+//
+// An optional er parameter reports an upgrade error. A device that
+// reports an error, or a version other than that of its assigned
+// release, is deemed to have failed. Failures count towards the
+// release's failure rate, and the release is halted if the rate is
+// too high.
+func (svc *service) reportHandler(w http.ResponseWriter, r *http.Request) {
+	svc.logRequest(r)
+	ctx := r.Context()
+
+	dev, ok := svc.checkDevice(w, r)
+	if !ok {
+		return
+	}
+	vn := r.FormValue("vn")
+	if vn == "" {
+		writeError(w, http.StatusBadRequest, "missing vn param")
+		return
+	}
+	er := r.FormValue("er")
+
+	rs, err := model.GetReleases(ctx, svc.settingsStore, dev.Type)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not get releases: %v", err))
+		return
+	}
+
+	if dev.Version != vn {
+		dev.Version = vn
+		err = model.PutDevice(ctx, svc.settingsStore, dev)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not put device: %v", err))
+			return
+		}
+	}
+
+	// Compare against the reported version, so that a device that has
+	// installed its assigned release is no longer assigned it.
+	rel := model.AssignedRelease(rs, dev)
+	if rel == nil {
+		// Either no release is assigned or the device is running it.
+		for _, rel := range rs {
+			if rel.Version == vn {
+				svc.recordOutcome(ctx, &rel, dev, model.DeviceReleaseInstalled, "")
+				break
+			}
+		}
+		return
+	}
+	if er == "" {
+		er = fmt.Sprintf("reported version %s, expected %s", vn, rel.Version)
+	}
+	svc.recordOutcome(ctx, rel, dev, model.DeviceReleaseFailed, er)
+}
+
+// recordOutcome records the outcome of a release on a device, then
+// halts the release if its failure rate is too high.
+func (svc *service) recordOutcome(ctx context.Context, rel *model.Release, dev *model.Device, status, msg string) {
+	d, err := model.GetDeviceRelease(ctx, svc.settingsStore, rel.ID, dev.Mac)
+	if err != nil && !errors.Is(err, datastore.ErrNoSuchEntity) {
+		log.Printf("could not get device release: %v", err)
+		return
+	}
+	if err == nil && d.Status == status {
+		return
+	}
+	err = model.PutDeviceRelease(ctx, svc.settingsStore, &model.DeviceRelease{ReleaseID: rel.ID, Mac: dev.Mac, Status: status, Error: msg})
+	if err != nil {
+		log.Printf("could not put device release: %v", err)
+		return
+	}
+	if status != model.DeviceReleaseFailed || rel.Stage == model.ReleaseHalted {
+		return
+	}
+
+	ds, err := model.GetDeviceReleases(ctx, svc.settingsStore, rel.ID)
+	if err != nil {
+		log.Printf("could not get device releases: %v", err)
+		return
+	}
+	reported, failed := model.ReleaseFailures(ds)
+	if reported < minReports || float64(failed)/float64(reported) <= maxFailureRate {
+		return
+	}
+
+	rel.Stage = model.ReleaseHalted
+	rel.Notes = fmt.Sprintf("halted after %d of %d devices failed", failed, reported)
+	err = model.PutRelease(ctx, svc.settingsStore, rel)
+	if err != nil {
+		log.Printf("could not halt release %d: %v", rel.ID, err)
+		return
+	}
+	msg = fmt.Sprintf("Release %s for %s devices (%d) %s, most recently device %s: %s", rel.Version, rel.Type, rel.ID, rel.Notes, dev.MAC(), msg)
+	log.Print(msg)
+	err = svc.notifier.Send(ctx, sandboxSite, notifyRelease, msg)
+	if err != nil {
+		log.Printf("could not send notification: %v", err)
+	}
+}
+
+// checkDevice checks the ma and dk params of a device request,
+// returning the device if valid, or writing an error otherwise.
+func (svc *service) checkDevice(w http.ResponseWriter, r *http.Request) (*model.Device, bool) {
+	mac := model.MacEncode(r.FormValue("ma"))
+	if mac == 0 {
+		writeError(w, http.StatusBadRequest, "ma invalid MAC address")
+		return nil, false
+	}
+	dkey, err := strconv.ParseInt(r.FormValue("dk"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "could not parse device key")
+		return nil, false
+	}
+	dev, err := model.GetDevice(r.Context(), svc.settingsStore, mac)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("could not get device: %v", err))
+		return nil, false
+	}
+	if !dev.ValidKey(dkey, time.Now()) {
+		writeError(w, http.StatusUnauthorized, "invalid device key")
+		return nil, false
+	}
+	return dev, true
+}
+
+// artifactURL returns the URL of an artifact, converting GCS objects
+// to their public URL.
+func artifactURL(u string) string {
+	if obj, ok := strings.CutPrefix(u, "gs://"); ok {
+		return "https://storage.googleapis.com/" + obj
+	}
+	return u
+}
+
+// releaseHandler handles release management requests from
+// operators. Requests are authorized by a JWT signed with the
+// release secret. The op parameter is one of:
+//
+// - create: create a canary release, with params ty (device type),
+// vn (version), url (artifact URL or GCS object), cs (checksum),
+// cp (canary percentage) and notes (optional).
+// - canary: set the canary percentage of release id to cp.
+// - promote: promote release id to the whole fleet.
+// - halt: halt release id.
+// - list: list releases, optionally for device type ty.
+//
+// The response is the affected release(s) in JSON format.
+func (svc *service) releaseHandler(w http.ResponseWriter, r *http.Request) {
+	svc.logRequest(r)
+	ctx := r.Context()
+
+	_, err := gauth.GetClaims(r.Header.Get("Authorization"), svc.releaseSecret)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("invalid claims: %v", err))
+		return
+	}
+
+	var rel *model.Release
+	switch op := r.FormValue("op"); op {
+	case "create":
+		rel = &model.Release{
+			Type:     r.FormValue("ty"),
+			Version:  r.FormValue("vn"),
+			URL:      r.FormValue("url"),
+			Checksum: strings.ToLower(r.FormValue("cs")),
+			Notes:    r.FormValue("notes"),
+		}
+		if rel.Type == "" || rel.Version == "" || rel.URL == "" || rel.Checksum == "" {
+			writeError(w, http.StatusBadRequest, "missing ty, vn, url or cs param")
+			return
+		}
+		rel.Canary, err = parseCanary(r.FormValue("cp"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		err = model.CreateRelease(ctx, svc.settingsStore, rel)
+
+	case "canary", "promote", "halt":
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err == nil {
+			rel, err = model.GetRelease(ctx, svc.settingsStore, id)
+		}
+		if err != nil {
+			writeError(w, http.StatusNotFound, "invalid release id")
+			return
+		}
+		switch op {
+		case "canary":
+			rel.Stage = model.ReleaseCanary
+			rel.Canary, err = parseCanary(r.FormValue("cp"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		case "promote":
+			rel.Stage = model.ReleaseFleet
+		case "halt":
+			rel.Stage = model.ReleaseHalted
+			rel.Notes = "halted by operator"
+		}
+		err = model.PutRelease(ctx, svc.settingsStore, rel)
+
+	case "list":
+		rs, err := model.GetReleases(ctx, svc.settingsStore, r.FormValue("ty"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not get releases: %v", err))
+			return
+		}
+		writeJSON(w, rs)
+		return
+
+	default:
+		writeError(w, http.StatusBadRequest, "invalid op: "+op)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("could not put release: %v", err))
+		return
+	}
+	log.Printf("release %d for %s devices version %s is %s", rel.ID, rel.Type, rel.Version, rel.Stage)
+	writeJSON(w, rel)
+}
+
+// parseCanary parses a canary percentage.
+func parseCanary(s string) (int64, error) {
+	cp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || cp < 0 || cp > 100 {
+		return 0, fmt.Errorf("invalid canary percentage: %s", s)
+	}
+	return cp, nil
+}
+
+// writeJSON writes v in JSON format.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("could not write JSON: %v", err)
+	}
+}
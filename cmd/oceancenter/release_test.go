@@ -0,0 +1,103 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Center. Ocean Center is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Center is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Center in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestReportSuccess tests that a device reporting the version of its
+// assigned release is recorded as a success, and does not halt the
+// release.
+func TestReportSuccess(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	svc := &service{settingsStore: store}
+
+	const (
+		oldVersion = "v1.0.0"
+		newVersion = "v1.1.0"
+		dkey       = 12345678
+	)
+	rel := &model.Release{Type: model.DevTypeCamera, Version: newVersion, URL: "gs://releases/camera-v1.1.0.tar.gz", Checksum: "abc"}
+	err = model.CreateRelease(ctx, store, rel)
+	if err != nil {
+		t.Fatalf("CreateRelease returned error: %v", err)
+	}
+	rel.Stage = model.ReleaseFleet
+	err = model.PutRelease(ctx, store, rel)
+	if err != nil {
+		t.Fatalf("PutRelease returned error: %v", err)
+	}
+
+	// Enough devices to reach minReports, were they all to fail.
+	for i := 1; i <= minReports; i++ {
+		dev := &model.Device{Skey: sandboxSite, Mac: int64(i), Dkey: dkey, Type: model.DevTypeCamera, Version: oldVersion, Enabled: true}
+		err = model.PutDevice(ctx, store, dev)
+		if err != nil {
+			t.Fatalf("PutDevice returned error: %v", err)
+		}
+
+		params := url.Values{"ma": {dev.MAC()}, "dk": {strconv.Itoa(dkey)}, "vn": {newVersion}}
+		req := httptest.NewRequest(http.MethodPost, "/report?"+params.Encode(), nil)
+		rec := httptest.NewRecorder()
+		svc.reportHandler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("device %d: reportHandler returned status %d: %s", i, rec.Code, rec.Body.String())
+		}
+
+		d, err := model.GetDeviceRelease(ctx, store, rel.ID, dev.Mac)
+		if err != nil {
+			t.Fatalf("device %d: GetDeviceRelease returned error: %v", i, err)
+		}
+		if d.Status != model.DeviceReleaseInstalled {
+			t.Errorf("device %d: unexpected device release status: got %s, want %s (%s)", i, d.Status, model.DeviceReleaseInstalled, d.Error)
+		}
+	}
+
+	ds, err := model.GetDeviceReleases(ctx, store, rel.ID)
+	if err != nil {
+		t.Fatalf("GetDeviceReleases returned error: %v", err)
+	}
+	reported, failed := model.ReleaseFailures(ds)
+	if reported != minReports || failed != 0 {
+		t.Errorf("unexpected release outcomes: got %d reported, %d failed, want %d reported, 0 failed", reported, failed, minReports)
+	}
+	rel, err = model.GetRelease(ctx, store, rel.ID)
+	if err != nil {
+		t.Fatalf("GetRelease returned error: %v", err)
+	}
+	if rel.Stage != model.ReleaseFleet {
+		t.Errorf("release unexpectedly %s: %s", rel.Stage, rel.Notes)
+	}
+}
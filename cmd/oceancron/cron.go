@@ -23,10 +23,13 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -34,11 +37,11 @@ import (
 	"sync"
 	"time"
 
-	"github.com/kortschak/sun"
 	cron "github.com/robfig/cron/v3"
 
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
 )
 
 // The location ID consistent with IANA Time Zone database convention.
@@ -75,7 +78,7 @@ func newScheduler() (*scheduler, error) {
 	if err != nil {
 		return nil, err
 	}
-	c := cron.New(cron.WithParser(sun.Parser{}), cron.WithLocation(loc))
+	c := cron.New(cron.WithParser(eventParser{}), cron.WithLocation(loc))
 	c.Start() // We will not stop the cron.
 	return &scheduler{
 		cron:    c,
@@ -129,112 +132,290 @@ func (s *scheduler) Set(job *model.Cron) error {
 		delete(s.entries, id)
 	}
 
-	// TODO: Get lat,lon from site.
-	lat := math.NaN()
-	lon := math.NaN()
+	spec, sched, err := cronSchedule(job)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("cron: %s spec: %v", job.ID, spec)
+
+	action, err := s.action(job)
+	if action == nil || err != nil {
+		return err
+	}
+
+	id = s.cron.Schedule(sched, cron.FuncJob(s.once(*job, action)))
+	s.ids[cronID{Site: job.Skey, ID: job.ID}] = id
+	s.entries[id] = *job
+	return nil
+}
+
+// cronSchedule returns the spec and schedule of an enabled cron job,
+// located at its site, in its site's timezone, and jittered, if
+// required.
+func cronSchedule(job *model.Cron) (string, cron.Schedule, error) {
+	lat, lon := siteLocation(job.Skey)
 
 	spec, err := cronSpec(job, lat, lon)
 	if err != nil {
-		return fmt.Errorf("could not get cron spec for job: %s: %w", job.ID, err)
+		return "", nil, fmt.Errorf("could not get cron spec for job: %s: %w", job.ID, err)
 	}
 
-	log.Printf("cron: %s spec: %v", job.ID, spec)
+	sched, err := eventParser{}.Parse(spec)
+	if err != nil {
+		return spec, nil, fmt.Errorf("failed to add cron spec %s to the cron scheduler: %w", spec, err)
+	}
+	sched = &zonedSchedule{Schedule: sched, loc: siteTimezone(job.Skey)}
+	if job.Jitter > 0 {
+		sched = &jitterSchedule{Schedule: sched, jitter: time.Duration(job.Jitter) * time.Minute, seed: job.Skey ^ int64(fnv64(job.ID))}
+	}
+	return spec, sched, nil
+}
 
-	// Build a job from the action, var and data values.
+// action builds a cron job's action from its action, var and data
+// values. The action is nil for unimplemented actions.
+func (s *scheduler) action(job *model.Cron) (func() error, error) {
 	ctx := context.Background()
-	var action func()
-	notify := func(msg string) error { return notifier.Send(ctx, job.Skey, "cron", msg) }
+	var action func() error
 	switch strings.ToLower(job.Action) {
 	case "set":
-		action = func() {
-			log.Printf("cron run: setting %s=%q for site=%d: %v", job.Var, job.Data, job.Skey, err)
-			err = model.PutVariable(ctx, settingsStore, job.Skey, job.Var, job.Data)
+		action = func() error {
+			log.Printf("cron run: setting %s=%q for site=%d", job.Var, job.Data, job.Skey)
+			err := model.PutVariable(ctx, settingsStore, job.Skey, job.Var, job.Data)
 			if err != nil {
-				logAndNotify(notify, "cron: error setting %s=%q for site=%d: %v", job.Var, job.Data, job.Skey, err)
+				return fmt.Errorf("error setting %s=%q for site=%d: %w", job.Var, job.Data, job.Skey, err)
 			}
+			return nil
 		}
 
 	case "del":
-		action = func() {
-			log.Printf("cron run: deleting %s for site=%d: %v", job.Var, job.Skey, err)
-			err = model.DeleteVariable(ctx, settingsStore, job.Skey, job.Var)
+		action = func() error {
+			log.Printf("cron run: deleting %s for site=%d", job.Var, job.Skey)
+			err := model.DeleteVariable(ctx, settingsStore, job.Skey, job.Var)
 			if err != nil {
-				logAndNotify(notify, "cron: error deleting %s for site=%d: %v", job.Var, job.Skey, err)
+				return fmt.Errorf("error deleting %s for site=%d: %w", job.Var, job.Skey, err)
 			}
+			return nil
 		}
 
 	case "call":
 		fn, ok := s.funcs[job.Var]
 		if !ok {
-			return fmt.Errorf("no function %q", job.Var)
+			return nil, fmt.Errorf("no function %q", job.Var)
 		}
-		action = func() {
+		action = func() error {
 			log.Printf("cron run: calling %s(%d, %s)", job.Var, job.Skey, job.Data)
-			err = fn(job.Skey, job.Data)
+			err := fn(job.Skey, job.Data)
 			if err != nil {
-				logAndNotify(notify, "cron: error calling %s(%d, %s): %v", job.Var, job.Skey, job.Data, err)
+				return fmt.Errorf("error calling %s(%d, %s): %w", job.Var, job.Skey, job.Data, err)
 			}
+			return nil
 		}
 
 	case "rpc":
 		_, err := url.Parse(job.Var)
 		if err != nil {
-			return fmt.Errorf("invalid cron rpc URL %s: %w", job.Var, err)
+			return nil, fmt.Errorf("invalid cron rpc URL %s: %w", job.Var, err)
 		}
-		action = func() {
+		action = func() error {
 			log.Printf("cron run: rpc %s at site=%v", job.Var, job.Skey)
 			reader := bytes.NewReader([]byte(job.Data))
 			req, err := http.NewRequest("POST", job.Var, reader)
 			if err != nil {
-				logAndNotify(notify, "cron: rpc %s request invalid: %v", job.Var, err)
-				return
+				return fmt.Errorf("rpc %s request invalid: %w", job.Var, err)
 			}
 			req.Header.Set("Content-Type", "application/json")
 			tokString, err := gauth.PutClaims(map[string]interface{}{"iss": cronServiceAccount, "skey": job.Skey}, cronSecret)
 			if err != nil {
-				logAndNotify(notify, "cron: rpc %s request error signing claims: %v", job.Var, err)
-				return
+				return fmt.Errorf("rpc %s request error signing claims: %w", job.Var, err)
 			}
 			req.Header.Set("Authorization", "Bearer "+tokString)
 			clt := &http.Client{}
 			resp, err := clt.Do(req)
 			if err != nil {
-				logAndNotify(notify, "cron: rpc %s request error: %v", job.Var, err)
-				return
+				return fmt.Errorf("rpc %s request error: %w", job.Var, err)
 			}
 			if resp.StatusCode != http.StatusOK {
-				logAndNotify(notify, "cron: rpc %s returned unexpected status: %s", job.Var, http.StatusText(resp.StatusCode))
+				return fmt.Errorf("rpc %s returned unexpected status: %s", job.Var, http.StatusText(resp.StatusCode))
 			}
+			return nil
+		}
+
+	case "http", "webhook":
+		wh, err := newWebhook(job)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron http action: %w", err)
+		}
+		job := *job
+		action = func() error {
+			log.Printf("cron run: http %s %s at site=%v", wh.method, wh.url, job.Skey)
+			return wh.do(ctx, job)
 		}
 
 	case "email":
-		action = func() {
+		action = func() error {
 			log.Printf("cron run: email sent at %v\nvar=%s\ndata=%q", time.Now(), job.Var, job.Data)
 			err := notifier.Send(ctx, job.Skey, "cron",
 				fmt.Sprintf("cron email sent at %v\nvar=%s\ndata=%q",
 					time.Now(), job.Var, job.Data))
 			if err != nil {
-				logAndNotify(notify, "cron: unable to notify ops: %v", err)
+				return fmt.Errorf("unable to notify ops: %w", err)
 			}
+			return nil
 		}
 
 	case "sms":
 		// TODO: Implement.
-		return nil
+		return nil, nil
 
 	default:
-		return fmt.Errorf("unknown action: %q", job.Action)
+		return nil, fmt.Errorf("unknown action: %q", job.Action)
 	}
+	return action, nil
 
-	id, err = s.cron.AddFunc(spec, action)
-	if err != nil {
-		return fmt.Errorf("failed to add cron spec %s to the cron scheduler: %w", spec, err)
+}
+
+// Run runs a cron job immediately, regardless of its schedule, e.g.,
+// to trigger a power cycle on demand. Callers are responsible for
+// checking that the cron is enabled.
+// Like scheduled runs, the run is recorded and skipped during
+// blackout windows.
+func (s *scheduler) Run(job *model.Cron) error {
+	action, err := s.action(job)
+	if action == nil || err != nil {
+		return err
 	}
-	s.ids[cronID{Site: job.Skey, ID: job.ID}] = id
-	s.entries[id] = *job
+	s.once(*job, action)()
 	return nil
 }
 
+// once wraps a cron action so that it runs at most once per scheduled
+// time across all scheduler instances. Before running, a CronRun is
+// claimed for the scheduled time, i.e., the current time rounded to
+// the nearest minute, and the run is skipped if another instance has
+// already claimed it. Runs that fall within one of the site's
+// blackout windows are skipped, otherwise the outcome is recorded in
+// the CronRun. Errors are logged and notified.
+func (s *scheduler) once(job model.Cron, action func() error) func() {
+	return func() {
+		ctx := context.Background()
+		notify := func(msg string) error { return notifier.Send(ctx, job.Skey, "cron", msg) }
+
+		run := &model.CronRun{Skey: job.Skey, CronID: job.ID, Scheduled: time.Now().Round(time.Minute), Instance: instanceID}
+		err := model.ClaimCronRun(ctx, settingsStore, run)
+		switch {
+		case errors.Is(err, datastore.ErrEntityExists):
+			log.Printf("cron %s for site=%d at %v already claimed", job.ID, job.Skey, run.Scheduled)
+			return
+		case err != nil:
+			// Better to risk running twice than not at all.
+			log.Printf("could not claim cron %s for site=%d: %v", job.ID, job.Skey, err)
+		}
+
+		blackout, err := model.InBlackout(ctx, settingsStore, job.Skey, run.Scheduled)
+		if err != nil {
+			log.Printf("could not check blackout for site=%d: %v", job.Skey, err)
+		}
+		if blackout {
+			log.Printf("cron %s for site=%d at %v skipped during blackout", job.ID, job.Skey, run.Scheduled)
+			err = model.SkipCronRun(ctx, settingsStore, run, "blackout")
+			if err != nil {
+				log.Printf("could not record cron %s run for site=%d: %v", job.ID, job.Skey, err)
+			}
+			return
+		}
+
+		actionErr := action()
+		if actionErr != nil {
+			logAndNotify(notify, "cron: %v", actionErr)
+		}
+
+		err = model.FinishCronRun(ctx, settingsStore, run, actionErr)
+		if err != nil {
+			log.Printf("could not record cron %s run for site=%d: %v", job.ID, job.Skey, err)
+		}
+		if actionErr != nil {
+			checkFailures(ctx, job, notify)
+		}
+	}
+}
+
+// checkFailures notifies when a cron has failed maxFailures times in
+// a row. Subsequent failures are not notified until the cron succeeds
+// again, since each failure is already notified individually.
+func checkFailures(ctx context.Context, job model.Cron, notify func(msg string) error) {
+	if maxFailures == 0 {
+		return
+	}
+	runs, err := model.GetCronRuns(ctx, settingsStore, job.Skey, job.ID, maxFailures+1)
+	if err != nil {
+		log.Printf("could not get cron %s runs for site=%d: %v", job.ID, job.Skey, err)
+		return
+	}
+	if model.ConsecutiveFailures(runs) == maxFailures {
+		logAndNotify(notify, "cron: %s for site=%d has failed %d consecutive times, last error: %s", job.ID, job.Skey, maxFailures, runs[0].Error)
+	}
+}
+
+// zonedSchedule is a cron.Schedule that computes times in a site's
+// timezone rather than the scheduler's, so that, e.g., "0 6 * * *" is
+// 6am at the site. Times are computed in civil time, so that daily
+// times remain the same local time across daylight saving
+// transitions. Times that do not exist due to a transition are
+// skipped, whereas times that occur twice run twice.
+type zonedSchedule struct {
+	cron.Schedule
+	loc *time.Location
+}
+
+// Next returns the next time after t in the schedule's timezone.
+func (s *zonedSchedule) Next(t time.Time) time.Time {
+	return s.Schedule.Next(t.In(s.loc))
+}
+
+// jitterSchedule is a cron.Schedule that offsets each time of another
+// schedule by a random amount, up to jitter either side, in whole
+// minutes. This spreads the load of crons scheduled for the same time,
+// e.g., sunrise, across sites. The offset is derived from the seed and
+// the scheduled time, so that it is the same for every scheduler
+// instance and each scheduled time is claimed only once.
+type jitterSchedule struct {
+	cron.Schedule
+	jitter time.Duration
+	seed   int64
+}
+
+// Next returns the next jittered time after t. Since jitter may
+// reorder closely scheduled times, this is the earliest jittered time
+// of any scheduled time that could be jittered to after t.
+func (s *jitterSchedule) Next(t time.Time) time.Time {
+	var best time.Time
+	for next := s.Schedule.Next(t.Add(-s.jitter)); !next.IsZero(); next = s.Schedule.Next(next) {
+		if !best.IsZero() && next.Add(-s.jitter).After(best) {
+			break
+		}
+		jittered := next.Add(s.offset(next))
+		if jittered.After(t) && (best.IsZero() || jittered.Before(best)) {
+			best = jittered
+		}
+	}
+	return best
+}
+
+// offset returns the jitter offset for the given scheduled time.
+func (s *jitterSchedule) offset(t time.Time) time.Duration {
+	mins := int64(s.jitter / time.Minute)
+	r := rand.New(rand.NewSource(s.seed ^ t.Unix()))
+	return time.Duration(r.Int63n(2*mins+1)-mins) * time.Minute
+}
+
+// fnv64 returns the 64-bit FNV-1a hash of s.
+func fnv64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
 // run immediately runs all cron jobs. It is unexported as it is only used in testing.
 func (s *scheduler) run() {
 	for _, job := range s.cron.Entries() {
@@ -255,7 +436,8 @@ func isSameCron(a, b model.Cron) bool {
 // cronSpec returns the Cron rendered as a cron spec line for the given
 // geographic location. The spec line makes use of cron predefined scheduling
 // definitions implemented by github.com/robfig/cron/v3 and
-// github.com/kortschak/sun.
+// github.com/kortschak/sun, as well as the twilight, moon phase and
+// tide definitions implemented by eventParser.
 func cronSpec(c *model.Cron, lat, lon float64) (string, error) {
 	if !c.Enabled {
 		return "", nil
@@ -265,7 +447,7 @@ func cronSpec(c *model.Cron, lat, lon float64) (string, error) {
 		return "", errNoTimeSpec
 	}
 
-	if strings.HasPrefix(c.TOD, "@sunrise") || strings.HasPrefix(c.TOD, "@noon") || strings.HasPrefix(c.TOD, "@sunset") {
+	if isLocatedEvent(c.TOD) {
 		if math.IsNaN(lat) || math.IsNaN(lon) {
 			return "", errNoLocation
 		}
@@ -276,6 +458,31 @@ func cronSpec(c *model.Cron, lat, lon float64) (string, error) {
 	return c.TOD, nil
 }
 
+// siteLocation returns the latitude and longitude of a site, or NaN
+// if the site cannot be found or its location is not set.
+func siteLocation(skey int64) (lat, lon float64) {
+	site, err := model.GetSite(context.Background(), settingsStore, skey)
+	if err != nil {
+		log.Printf("could not get site=%d: %v", skey, err)
+		return math.NaN(), math.NaN()
+	}
+	if site.Latitude == 0 && site.Longitude == 0 {
+		return math.NaN(), math.NaN()
+	}
+	return site.Latitude, site.Longitude
+}
+
+// siteTimezone returns the timezone of a site, or the default
+// timezone if the site cannot be found.
+func siteTimezone(skey int64) *time.Location {
+	site, err := model.GetSite(context.Background(), settingsStore, skey)
+	if err != nil {
+		log.Printf("could not get site=%d: %v", skey, err)
+		site = &model.Site{Skey: skey}
+	}
+	return model.SiteNow(site).Location()
+}
+
 // logAndNotify will log and then call the notify func with the provided message
 // (as a formattable string) and args. The notify function for example could
 // send an email.
@@ -288,7 +495,10 @@ func logAndNotify(notify func(msg string) error, msg string, args ...interface{}
 }
 
 // cronHandler handles cron requests originating from a cron client.
-// These take the form: /cron/op/skey/id.
+// These take the form: /cron/op/skey/id, where op is set, unset, run,
+// runs or simulate. Set, unset and run requests must be authorized by
+// checkCronClaims, and only enabled crons are run. Simulate requests must be authorized
+// by checkSiteAdmin.
 func cronHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
 
@@ -309,6 +519,15 @@ func cronHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	id := req[4]
 
+	switch op {
+	case "set", "unset", "run":
+		err = checkCronClaims(r, skey)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, fmt.Sprintf("request from %s is not authorized: %v", r.RemoteAddr, err))
+			return
+		}
+	}
+
 	var cron *model.Cron
 	switch op {
 	case "set":
@@ -322,6 +541,39 @@ func cronHandler(w http.ResponseWriter, r *http.Request) {
 	case "unset":
 		cron = &model.Cron{Skey: skey, ID: id, Enabled: false}
 
+	case "run":
+		cron, err = model.GetCron(ctx, settingsStore, skey, id)
+		if err != nil {
+			log.Printf("could not get cron %s: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "could not get cron "+id)
+			return
+		}
+		if !cron.Enabled {
+			writeError(w, http.StatusConflict, "cron "+id+" is disabled")
+			return
+		}
+		err = cronScheduler.Run(cron)
+		if err != nil {
+			log.Printf("could not run cron %s: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "could not run cron "+id)
+			return
+		}
+		w.Write([]byte("ran cron " + id))
+		return
+
+	case "runs":
+		writeRuns(w, r, skey, id)
+		return
+
+	case "simulate":
+		err = checkSiteAdmin(ctx, r, skey)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, fmt.Sprintf("request from %s is not authorized: %v", r.RemoteAddr, err))
+			return
+		}
+		writeSimulation(w, r, skey, id)
+		return
+
 	default:
 		writeError(w, http.StatusBadRequest, "invalid operation: "+op)
 		return
@@ -336,3 +588,50 @@ func cronHandler(w http.ResponseWriter, r *http.Request) {
 	resp := op + " cron " + id
 	w.Write([]byte(resp))
 }
+
+// cronRunResponse is the JSON representation of a cron run.
+type cronRunResponse struct {
+	Scheduled int64  `json:"scheduled"`
+	Started   int64  `json:"started"`
+	Finished  int64  `json:"finished,omitempty"`
+	Outcome   string `json:"outcome"`
+	Error     string `json:"error,omitempty"`
+}
+
+// writeRuns writes the recent runs of a cron as JSON, newest
+// first. The optional n query param limits the number of runs, which
+// defaults to defaultRuns.
+func writeRuns(w http.ResponseWriter, r *http.Request, skey int64, id string) {
+	const defaultRuns = 10
+	n := defaultRuns
+	if v := r.FormValue("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid n: "+v)
+			return
+		}
+	}
+
+	runs, err := model.GetCronRuns(r.Context(), settingsStore, skey, id, n)
+	if err != nil {
+		log.Printf("could not get cron %s runs: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "could not get runs for cron "+id)
+		return
+	}
+
+	resp := make([]cronRunResponse, len(runs))
+	for i, run := range runs {
+		resp[i] = cronRunResponse{
+			Scheduled: run.Scheduled.Unix(),
+			Started:   run.Started.Unix(),
+			Outcome:   run.Outcome,
+			Error:     run.Error,
+		}
+		if !run.Finished.IsZero() {
+			resp[i].Finished = run.Finished.Unix()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -23,8 +23,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
@@ -78,6 +82,24 @@ var cronSpecTests = []struct {
 		want:    "@noon 1 1",
 		wantErr: nil,
 	},
+	{
+		cron: &model.Cron{TOD: "@dawn-30m", Enabled: true},
+		lat:  1, lon: 1,
+		want:    "@dawn-30m 1 1",
+		wantErr: nil,
+	},
+	{
+		cron: &model.Cron{TOD: "@hightide", Enabled: true},
+		lat:  math.NaN(), lon: math.NaN(),
+		want:    "",
+		wantErr: errNoLocation,
+	},
+	{
+		cron: &model.Cron{TOD: "@fullmoon", Enabled: true},
+		lat:  math.NaN(), lon: math.NaN(),
+		want:    "@fullmoon",
+		wantErr: nil,
+	},
 	{
 		cron: &model.Cron{TOD: "@midnight", Enabled: true},
 		lat:  1, lon: 1,
@@ -144,3 +166,206 @@ func TestRun(t *testing.T) {
 
 	testScheduler.run()
 }
+
+func TestWebhook(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	settingsStore, err = datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up datastore: %v", err)
+	}
+	model.RegisterEntities()
+	err = model.PutSite(ctx, settingsStore, &model.Site{Skey: 1, Name: "localhost", Enabled: true})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	err = model.PutVariable(ctx, settingsStore, 1, "000000000001.mode", "Normal")
+	if err != nil {
+		t.Fatalf("could not put variable: %v", err)
+	}
+
+	cronSecret = []byte("secret")
+	var gotMethod, gotBody, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotAuth = r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer srv.Close()
+
+	job := model.Cron{Skey: 1, ID: "hook", Action: "http", Var: "put " + srv.URL, Data: `{"site":"{{.Site.Name}}","mode":"{{index .Vars "000000000001.mode"}}"}`}
+	wh, err := newWebhook(&job)
+	if err != nil {
+		t.Fatalf("newWebhook returned error: %v", err)
+	}
+	err = wh.do(ctx, job)
+	if err != nil {
+		t.Fatalf("webhook returned error: %v", err)
+	}
+	const wantBody = `{"site":"localhost","mode":"Normal"}`
+	if gotMethod != http.MethodPut || gotBody != wantBody {
+		t.Errorf("unexpected request: got %s %s, want %s %s", gotMethod, gotBody, http.MethodPut, wantBody)
+	}
+	if gotAuth != "" {
+		t.Errorf("unexpected authorization without a webhook secret: %s", gotAuth)
+	}
+
+	// Requests are signed with the webhook's own secret, never the cron secret.
+	webhookSecrets = map[string]string{webhookSecretKey(&job): "hook secret"}
+	defer func() { webhookSecrets = nil }()
+	wh, err = newWebhook(&job)
+	if err != nil {
+		t.Fatalf("newWebhook returned error: %v", err)
+	}
+	err = wh.do(ctx, job)
+	if err != nil {
+		t.Fatalf("webhook returned error: %v", err)
+	}
+	_, err = gauth.GetClaims(gotAuth, cronSecret)
+	if err == nil {
+		t.Errorf("webhook request signed with the cron secret")
+	}
+	claims, err := gauth.GetClaims(gotAuth, []byte("hook secret"))
+	if err != nil {
+		t.Fatalf("could not get webhook claims: %v", err)
+	}
+	if claims["cron"] != job.ID {
+		t.Errorf("unexpected webhook claims: %v", claims)
+	}
+
+	for _, v := range []string{"ftp://example.com", "FETCH " + srv.URL} {
+		_, err = newWebhook(&model.Cron{ID: "bad", Var: v})
+		if err == nil {
+			t.Errorf("expected error for %q", v)
+		}
+	}
+}
+
+func TestJitterSchedule(t *testing.T) {
+	base, err := eventParser{}.Parse("0 6 * * *")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &jitterSchedule{Schedule: base, jitter: 10 * time.Minute, seed: 1}
+
+	now := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		next := s.Next(now)
+		want := time.Date(next.Year(), next.Month(), next.Day(), 6, 0, 0, 0, time.UTC)
+		if d := next.Sub(want).Abs(); d > 10*time.Minute || next.Second() != 0 {
+			t.Errorf("unexpected jittered time: got:%v want:%v±10m", next, want)
+		}
+		if !next.After(now) {
+			t.Errorf("jittered time %v not after %v", next, now)
+		}
+		if again := s.Next(now); !again.Equal(next) {
+			t.Errorf("jittered time not deterministic: got:%v and %v", next, again)
+		}
+		now = next
+	}
+}
+
+func TestZonedSchedule(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	settingsStore, err = datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up datastore: %v", err)
+	}
+	model.RegisterEntities()
+	for _, site := range []model.Site{
+		{Skey: 1, Name: "Adelaide"},
+		{Skey: 2, Name: "Perth", TimezoneName: "Australia/Perth"},
+		{Skey: 3, Name: "Brisbane", Timezone: 10},
+	} {
+		err = model.PutSite(ctx, settingsStore, &site)
+		if err != nil {
+			t.Fatalf("could not put site: %v", err)
+		}
+	}
+
+	tests := []struct {
+		desc string
+		skey int64
+		tod  string
+		from string
+		want []string
+	}{
+		{
+			desc: "default timezone",
+			skey: 1,
+			tod:  "0 6 * * *",
+			from: "2026-10-15T12:00:00Z",
+			want: []string{"2026-10-16T06:00:00+10:30", "2026-10-17T06:00:00+10:30"},
+		},
+		{
+			desc: "IANA timezone",
+			skey: 2,
+			tod:  "0 6 * * *",
+			from: "2026-10-15T12:00:00Z",
+			want: []string{"2026-10-16T06:00:00+08:00", "2026-10-17T06:00:00+08:00"},
+		},
+		{
+			desc: "fixed offset",
+			skey: 3,
+			tod:  "0 6 * * *",
+			from: "2026-10-15T12:00:00Z",
+			want: []string{"2026-10-16T06:00:00+10:00", "2026-10-17T06:00:00+10:00"},
+		},
+		{
+			desc: "midnight across DST start",
+			skey: 1,
+			tod:  "@midnight",
+			from: "2026-10-03T02:30:00Z",
+			want: []string{"2026-10-04T00:00:00+09:30", "2026-10-05T00:00:00+10:30"},
+		},
+		{
+			desc: "midnight across DST end",
+			skey: 1,
+			tod:  "@midnight",
+			from: "2026-04-04T02:30:00Z",
+			want: []string{"2026-04-05T00:00:00+10:30", "2026-04-06T00:00:00+09:30"},
+		},
+		{
+			desc: "midnight from just before midnight UTC",
+			skey: 1,
+			tod:  "@midnight",
+			from: "2026-10-15T23:59:00Z",
+			want: []string{"2026-10-17T00:00:00+10:30"},
+		},
+		{
+			desc: "skipped time at DST start",
+			skey: 1,
+			tod:  "30 2 * * *",
+			from: "2026-10-03T02:30:00Z",
+			want: []string{"2026-10-05T02:30:00+10:30"},
+		},
+		{
+			desc: "repeated time at DST end",
+			skey: 1,
+			tod:  "30 2 * * *",
+			from: "2026-04-04T02:30:00Z",
+			want: []string{"2026-04-05T02:30:00+10:30", "2026-04-05T02:30:00+09:30", "2026-04-06T02:30:00+09:30"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, sched, err := cronSchedule(&model.Cron{Skey: tt.skey, ID: "test", TOD: tt.tod, Enabled: true})
+			if err != nil {
+				t.Fatalf("cronSchedule returned error: %v", err)
+			}
+			next, err := time.Parse(time.RFC3339, tt.from)
+			if err != nil {
+				t.Fatalf("could not parse time: %v", err)
+			}
+			for _, want := range tt.want {
+				next = sched.Next(next)
+				if got := next.Format(time.RFC3339); got != want {
+					t.Errorf("got %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+// events.go implements scheduling of twilight, moon phase and tidal
+// events, in addition to the solar events implemented by
+// github.com/kortschak/sun.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/tide"
+	"github.com/kortschak/sun"
+	cron "github.com/robfig/cron/v3"
+)
+
+// eventKind is the kind of a scheduled event.
+type eventKind int
+
+const (
+	dawn eventKind = iota
+	dusk
+	nauticalDawn
+	nauticalDusk
+	highTide
+	lowTide
+	newMoon
+	fullMoon
+)
+
+// eventDescriptors are the cron spec descriptors for each kind of
+// event. Descriptors that contain another descriptor, e.g.,
+// @nauticaldawn and @dawn, must come first.
+var eventDescriptors = []struct {
+	name    string
+	kind    eventKind
+	located bool // Located events require a lat/lon.
+}{
+	{"@nauticaldawn", nauticalDawn, true},
+	{"@nauticaldusk", nauticalDusk, true},
+	{"@dawn", dawn, true},
+	{"@dusk", dusk, true},
+	{"@hightide", highTide, true},
+	{"@lowtide", lowTide, true},
+	{"@newmoon", newMoon, false},
+	{"@fullmoon", fullMoon, false},
+}
+
+// Solar zenith angles in degrees of twilight.
+const (
+	civilZenith    = 96
+	nauticalZenith = 102
+)
+
+// Moon phase constants. Phases are computed from the mean synodic
+// month and are therefore accurate to within about half a day.
+var (
+	moonEpoch    = time.Date(2000, time.January, 6, 18, 14, 0, 0, time.UTC) // A new moon.
+	synodicMonth = time.Duration(29.530588853 * 24 * float64(time.Hour))
+)
+
+// meanTideInterval is the mean interval between successive high or
+// low tides, i.e., the period of the principal lunar semidiurnal
+// constituent, M2.
+const meanTideInterval = 12*time.Hour + 25*time.Minute + 14*time.Second
+
+// tideWindow is how far ahead tides are requested from the tide provider.
+const tideWindow = 7 * 24 * time.Hour
+
+var errNoTideProvider = errors.New("no tide provider")
+
+// eventParser is a cron spec parser that handles the twilight, moon
+// phase and tide descriptors, i.e.,
+//   - @dawn, @dusk (civil twilight)
+//   - @nauticaldawn, @nauticaldusk (nautical twilight)
+//   - @hightide, @lowtide
+//   - @newmoon, @fullmoon
+//
+// Located event specs take the form
+//
+//	@event([+-]duration)? lat lon
+//
+// and moon phase specs take the form
+//
+//	@event([+-]duration)?
+//
+// All other specs, including solar events, are handled by sun.Parser.
+type eventParser struct {
+	sun.Parser
+}
+
+// Parse returns a schedule representing the given spec.
+func (p eventParser) Parse(spec string) (cron.Schedule, error) {
+	for _, d := range eventDescriptors {
+		if !strings.HasPrefix(spec, d.name) {
+			continue
+		}
+		var (
+			offset   time.Duration
+			lat, lon float64
+			err      error
+		)
+		rest := spec[len(d.name):]
+		f := strings.Fields(rest)
+		if strings.HasPrefix(rest, "+") || strings.HasPrefix(rest, "-") {
+			offset, err = time.ParseDuration(f[0])
+			if err != nil {
+				return nil, fmt.Errorf("provided bad offset %s: %w", f[0], err)
+			}
+			f = f[1:]
+		}
+		if !d.located {
+			if len(f) != 0 {
+				return nil, fmt.Errorf("provided unexpected args %q", spec)
+			}
+			return &moonSchedule{kind: d.kind, offset: offset}, nil
+		}
+		if len(f) != 2 {
+			return nil, fmt.Errorf("provided bad lat/lon %q", spec)
+		}
+		lat, err = strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("provided bad latitude %q: %w", f[0], err)
+		}
+		lon, err = strconv.ParseFloat(f[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("provided bad longitude %q: %w", f[1], err)
+		}
+		switch d.kind {
+		case highTide, lowTide:
+			return &tideSchedule{kind: d.kind, offset: offset, lat: lat, lon: lon}, nil
+		default:
+			return &twilightSchedule{kind: d.kind, offset: offset, lat: lat, lon: lon}, nil
+		}
+	}
+	return p.Parser.Parse(spec)
+}
+
+// isLocatedEvent returns true if tod is an event descriptor requiring
+// a location, including solar events.
+func isLocatedEvent(tod string) bool {
+	if strings.HasPrefix(tod, "@sunrise") || strings.HasPrefix(tod, "@noon") || strings.HasPrefix(tod, "@sunset") {
+		return true
+	}
+	for _, d := range eventDescriptors {
+		if strings.HasPrefix(tod, d.name) {
+			return d.located
+		}
+	}
+	return false
+}
+
+// eventCache caches computed event times per location.
+var eventCache = &locCache{
+	twilight: make(map[twilightKey]time.Time),
+	tides:    make(map[location]*tideTimes),
+}
+
+// location is a latitude and longitude.
+type location struct {
+	lat, lon float64
+}
+
+// twilightKey identifies a twilight event on a given date at a location.
+type twilightKey struct {
+	location
+	kind eventKind
+	date string
+}
+
+// tideTimes holds the tides at a location that are known up until a given time.
+type tideTimes struct {
+	tides []tide.Event
+	until time.Time
+}
+
+// locCache is a cache of event times per location, which are typically
+// sites.
+type locCache struct {
+	mu       sync.Mutex
+	twilight map[twilightKey]time.Time
+	tides    map[location]*tideTimes
+}
+
+// twilightSchedule is a cron.Schedule for civil or nautical twilight.
+type twilightSchedule struct {
+	kind     eventKind
+	offset   time.Duration
+	lat, lon float64
+}
+
+// Next returns the next time the twilight event occurs, or the zero
+// time if it does not occur within a year, i.e., in polar regions.
+func (s *twilightSchedule) Next(t time.Time) time.Time {
+	for i := -1; i <= 366; i++ {
+		next := eventCache.twilightTime(t.AddDate(0, 0, i), s.lat, s.lon, s.kind)
+		if next.IsZero() {
+			continue
+		}
+		next = next.Add(s.offset)
+		if next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// twilightTime returns the cached time of the twilight event on the given
+// date, computing it if necessary. Entries for dates before the given
+// date are evicted when a new time is computed.
+func (c *locCache) twilightTime(date time.Time, lat, lon float64, kind eventKind) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := twilightKey{location: location{lat, lon}, kind: kind, date: date.Format(time.DateOnly)}
+	tt, ok := c.twilight[k]
+	if ok {
+		return tt
+	}
+	yesterday := date.AddDate(0, 0, -1).Format(time.DateOnly)
+	for k2 := range c.twilight {
+		if k2.location == k.location && k2.date < yesterday {
+			delete(c.twilight, k2)
+		}
+	}
+	tt = twilight(date, lat, lon, kind)
+	c.twilight[k] = tt
+	return tt
+}
+
+// twilight returns the time of the twilight event on the given date
+// at the given location, or the zero time if it does not occur. It
+// uses the NOAA general solar position equations, as used by
+// github.com/kortschak/sun, but with the zenith angle for twilight.
+func twilight(date time.Time, lat, lon float64, kind eventKind) time.Time {
+	zenith := civilZenith
+	if kind == nauticalDawn || kind == nauticalDusk {
+		zenith = nauticalZenith
+	}
+	const rad = math.Pi / 180
+
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, date.Location()).UTC()
+	g := 2 * math.Pi / 365 * (float64(noon.YearDay()-1) + float64(noon.Hour()-12)/24)
+	eqtime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) - 0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) - 0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) - 0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+
+	cosHA := math.Cos(float64(zenith)*rad)/(math.Cos(lat*rad)*math.Cos(decl)) - math.Tan(lat*rad)*math.Tan(decl)
+	if cosHA < -1 || cosHA > 1 {
+		return time.Time{}
+	}
+	ha := math.Acos(cosHA) / rad
+	if kind == dusk || kind == nauticalDusk {
+		ha = -ha
+	}
+	mins := 720 - 4*(lon+ha) - eqtime
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return midnight.Add(time.Duration(mins * float64(time.Minute))).Round(time.Second).In(date.Location())
+}
+
+// moonSchedule is a cron.Schedule for the new or full moon.
+type moonSchedule struct {
+	kind   eventKind
+	offset time.Duration
+}
+
+// Next returns the next time the moon phase occurs.
+func (s *moonSchedule) Next(t time.Time) time.Time {
+	phase := 0.0
+	if s.kind == fullMoon {
+		phase = 0.5
+	}
+	k := math.Ceil(float64(t.Sub(moonEpoch)-s.offset)/float64(synodicMonth) - phase)
+	for {
+		next := moonEpoch.Add(time.Duration((k+phase)*float64(synodicMonth)) + s.offset).Round(time.Minute)
+		if next.After(t) {
+			return next.In(t.Location())
+		}
+		k++
+	}
+}
+
+// tides is the tide provider, or nil if none is configured.
+var tides tide.Provider
+
+// tideSchedule is a cron.Schedule for high or low tides.
+type tideSchedule struct {
+	kind     eventKind
+	offset   time.Duration
+	lat, lon float64
+}
+
+// Next returns the next time the tide occurs. Tides are requested
+// from the tide provider a week at a time and cached per location. If
+// the provider fails, the next tide is estimated from the last known
+// tide using the mean tidal interval. The zero time is returned if no
+// tide is known, in which case the cron will not run until it is next
+// set.
+func (s *tideSchedule) Next(t time.Time) time.Time {
+	from := t.Add(-s.offset)
+	ev, err := eventCache.nextTide(context.Background(), location{s.lat, s.lon}, from, s.kind == highTide)
+	if err != nil {
+		log.Printf("could not get tides for %v,%v: %v", s.lat, s.lon, err)
+	}
+	if ev.IsZero() {
+		return time.Time{}
+	}
+	return ev.Add(s.offset).In(t.Location())
+}
+
+// nextTide returns the time of the next high or low tide after t at
+// the given location, fetching tides from the provider when t is
+// near the end of the cached tides. The cache is not locked while
+// fetching, so that a slow provider does not block other locations.
+func (c *locCache) nextTide(ctx context.Context, loc location, t time.Time, high bool) (time.Time, error) {
+	c.mu.Lock()
+	tt, ok := c.tides[loc]
+	c.mu.Unlock()
+
+	var err error
+	if !ok || t.Add(2*meanTideInterval).After(tt.until) || t.Before(tt.tides[0].Time) {
+		var fetched []tide.Event
+		if tides == nil {
+			err = errNoTideProvider
+		} else {
+			fetched, err = tides.Tides(ctx, loc.lat, loc.lon, t.Add(-meanTideInterval), t.Add(tideWindow))
+		}
+		if err == nil && len(fetched) > 0 {
+			tt = &tideTimes{tides: fetched, until: t.Add(tideWindow)}
+			c.mu.Lock()
+			c.tides[loc] = tt
+			c.mu.Unlock()
+		} else if err == nil {
+			err = errors.New("no tides returned")
+		}
+	}
+	if tt == nil {
+		return time.Time{}, err
+	}
+
+	var last time.Time
+	for _, ev := range tt.tides {
+		if ev.High != high {
+			continue
+		}
+		if ev.Time.After(t) {
+			return ev.Time, err
+		}
+		last = ev.Time
+	}
+	if last.IsZero() {
+		return time.Time{}, err
+	}
+	for !last.After(t) {
+		last = last.Add(meanTideInterval)
+	}
+	return last, err
+}
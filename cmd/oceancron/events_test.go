@@ -0,0 +1,187 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt. If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/tide"
+	"github.com/kortschak/sun"
+)
+
+// Adelaide, South Australia.
+const testLat, testLon = -34.9285, 138.6007
+
+func TestTwilight(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	now := time.Date(2026, time.June, 1, 0, 0, 0, 0, loc)
+	rise, _, set := sun.Times(now.Add(12*time.Hour), testLat, testLon)
+
+	times := make(map[string]time.Time)
+	for _, spec := range []string{"@nauticaldawn", "@dawn", "@dusk", "@nauticaldusk"} {
+		s, err := eventParser{}.Parse(spec + " -34.9285 138.6007")
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %v", spec, err)
+		}
+		times[spec] = s.Next(now)
+	}
+
+	// Civil twilight is about 25 minutes from sunrise and sunset in
+	// Adelaide, and nautical twilight a further 30 minutes.
+	checks := []struct {
+		name        string
+		early, late time.Time
+		min, max    time.Duration
+	}{
+		{"dawn", times["@dawn"], rise, 20 * time.Minute, 35 * time.Minute},
+		{"dusk", set, times["@dusk"], 20 * time.Minute, 35 * time.Minute},
+		{"nautical dawn", times["@nauticaldawn"], times["@dawn"], 25 * time.Minute, 40 * time.Minute},
+		{"nautical dusk", times["@dusk"], times["@nauticaldusk"], 25 * time.Minute, 40 * time.Minute},
+	}
+	for _, c := range checks {
+		d := c.late.Sub(c.early)
+		if d < c.min || d > c.max {
+			t.Errorf("unexpected %s interval: got:%v want:%v to %v", c.name, d, c.min, c.max)
+		}
+	}
+
+	// Twilight does not occur in mid-winter at the pole.
+	s := &twilightSchedule{kind: dawn, lat: 89, lon: 0}
+	next := s.Next(time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC))
+	if next.Month() == time.December {
+		t.Errorf("unexpected polar dawn: %v", next)
+	}
+}
+
+func TestMoon(t *testing.T) {
+	// Full moon on 25 January 2024 at 17:54 UTC.
+	want := time.Date(2024, time.January, 25, 17, 54, 0, 0, time.UTC)
+	s, err := eventParser{}.Parse("@fullmoon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := s.Next(want.Add(-7 * 24 * time.Hour))
+	if d := got.Sub(want).Abs(); d > 18*time.Hour {
+		t.Errorf("unexpected full moon: got:%v want:%v", got, want)
+	}
+	next := s.Next(got)
+	if d := next.Sub(got); d != synodicMonth.Round(time.Minute) && (d-synodicMonth).Abs() > time.Minute {
+		t.Errorf("unexpected interval between full moons: %v", d)
+	}
+}
+
+// testTides is a tide provider that returns high tides at midnight
+// and low tides at noon, UTC.
+type testTides struct {
+	calls int
+}
+
+func (p *testTides) Tides(ctx context.Context, lat, lon float64, start, end time.Time) ([]tide.Event, error) {
+	p.calls++
+	var evs []tide.Event
+	for t := start.Truncate(12 * time.Hour); t.Before(end); t = t.Add(12 * time.Hour) {
+		evs = append(evs, tide.Event{Time: t, High: t.Hour() == 0})
+	}
+	return evs, nil
+}
+
+func TestTides(t *testing.T) {
+	p := &testTides{}
+	tides = p
+	defer func() { tides = nil }()
+
+	hi, err := eventParser{}.Parse("@hightide-1h 1 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lo, err := eventParser{}.Parse("@lowtide 1 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Date(2026, time.March, 1, 6, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		day := now.AddDate(0, 0, i)
+		want := time.Date(day.Year(), day.Month(), day.Day(), 23, 0, 0, 0, time.UTC)
+		if got := hi.Next(day); !got.Equal(want) {
+			t.Errorf("unexpected high tide: got:%v want:%v", got, want)
+		}
+		want = time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, time.UTC)
+		if got := lo.Next(day); !got.Equal(want) {
+			t.Errorf("unexpected low tide: got:%v want:%v", got, want)
+		}
+	}
+	if p.calls != 1 {
+		t.Errorf("unexpected tide provider calls: got:%d want:1", p.calls)
+	}
+}
+
+// blockingTides is a tide provider that blocks until released.
+type blockingTides struct {
+	called, release chan struct{}
+}
+
+func (p *blockingTides) Tides(ctx context.Context, lat, lon float64, start, end time.Time) ([]tide.Event, error) {
+	close(p.called)
+	<-p.release
+	return nil, errors.New("no tides")
+}
+
+// TestTidesUnlocked tests that fetching tides does not block other
+// events from being computed.
+func TestTidesUnlocked(t *testing.T) {
+	p := &blockingTides{called: make(chan struct{}), release: make(chan struct{})}
+	tides = p
+	defer func() { tides = nil }()
+
+	hi, err := eventParser{}.Parse("@hightide 3 4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dawn, err := eventParser{}.Parse("@dawn 3 4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Date(2026, time.March, 1, 6, 0, 0, 0, time.UTC)
+	done := make(chan struct{})
+	go func() {
+		hi.Next(now)
+		close(done)
+	}()
+	<-p.called
+	select {
+	case <-done:
+		t.Fatal("tide schedule returned before tides were fetched")
+	default:
+	}
+	if dawn.Next(now).IsZero() {
+		t.Errorf("unexpected zero dawn")
+	}
+	close(p.release)
+	<-done
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/cloud/tide"
 	"github.com/ausocean/openfish/datastore"
 )
 
@@ -43,6 +44,8 @@ const (
 	version            = "v0.1.3"
 	cronServiceURL     = "https://oceancron.appspot.com"
 	cronServiceAccount = "oceancron@appspot.gserviceaccount.com"
+	ackTimeout         = 30 * time.Minute // Time before unacknowledged alerts are escalated.
+	tickPeriod         = 5 * time.Minute  // Period of notifier work.
 )
 
 var (
@@ -54,7 +57,10 @@ var (
 	cronScheduler *scheduler
 	cronSecret    []byte
 	notifier      notify.Notifier
+	ackSecret     []byte
 	storePath     string
+	instanceID    string // Identifies this instance when claiming cron runs.
+	maxFailures   int    // Consecutive failures before notifying, or zero to disable.
 )
 
 func main() {
@@ -74,6 +80,7 @@ func main() {
 	flag.StringVar(&host, "host", "localhost", "Host we run on in standalone mode")
 	flag.IntVar(&port, "port", defaultPort, "Port we listen on in standalone mode")
 	flag.StringVar(&storePath, "filestore", "store", "File store path")
+	flag.IntVar(&maxFailures, "failures", 3, "Consecutive cron failures before notifying (0 to disable)")
 	flag.Parse()
 
 	// Perform one-time setup or bail.
@@ -82,8 +89,20 @@ func main() {
 
 	http.HandleFunc("/_ah/warmup", warmupHandler)
 	http.HandleFunc("/cron/", cronHandler)
+	http.HandleFunc("/cron/reconcile", reconcileHandler)
+	http.Handle("/ack", notify.NewAckHandler(settingsStore, ackSecret))
 	http.HandleFunc("/", indexHandler)
 
+	// Periodically send notification digests and escalate unacknowledged alerts.
+	go func() {
+		for range time.Tick(tickPeriod) {
+			err := notify.Tick(ctx, notifier)
+			if err != nil {
+				log.Printf("could not perform notifier work: %v", err)
+			}
+		}
+	}()
+
 	log.Printf("Listening on %s:%d", host, port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), nil))
 }
@@ -124,24 +143,43 @@ func setup(ctx context.Context) {
 	}
 	model.RegisterEntities()
 
+	instanceID = os.Getenv("GAE_INSTANCE")
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
 	cronSecret, err = gauth.GetHexSecret(ctx, projectID, "cronSecret")
 	if err != nil || cronSecret == nil {
 		log.Printf("could not get cronSecret: %v", err)
 	}
 
-	err = setupCronScheduler(ctx)
+	secrets, err := gauth.GetSecrets(ctx, projectID, nil)
 	if err != nil {
-		log.Fatalf("could not set up cron scheduler: %v", err)
+		log.Fatalf("could not get secrets: %v", err)
 	}
 
-	secrets, err := gauth.GetSecrets(ctx, projectID, nil)
+	ackSecret = []byte(secrets[notify.AckSecretKey])
+	webhookSecrets = secrets
+
+	// Tides are only available when a tide provider is configured.
+	if key := secrets["worldTidesKey"]; key != "" {
+		tides = &tide.WorldTides{Key: key}
+	}
+
+	err = setupCronScheduler(ctx)
 	if err != nil {
-		log.Fatalf("could not get secrets: %v", err)
+		log.Fatalf("could not set up cron scheduler: %v", err)
 	}
+
 	notifier, err = notify.NewMailjetNotifier(
 		notify.WithSecrets(secrets),
 		notify.WithRecipientLookup(cronRecipients),
 		notify.WithStore(notify.NewStore(settingsStore)),
+		notify.WithTemplateStore(settingsStore),
+		notify.WithHistory(settingsStore),
+		notify.WithQuietHours(settingsStore),
+		notify.WithRouteStore(settingsStore),
+		notify.WithAcks(settingsStore, ackSecret, cronServiceURL+"/ack", ackTimeout, notify.OwnerTier(settingsStore)),
 	)
 	if err != nil {
 		log.Fatalf("could not set up email notifier: %v", err)
@@ -168,28 +206,42 @@ func setupCronScheduler(ctx context.Context) error {
 		return fmt.Errorf("could not create new scheduler: %w", err)
 	}
 
-	sites, err := model.GetAllSites(ctx, settingsStore)
+	crons, err := getAllCrons(ctx)
 	if err != nil {
-		if sites == nil {
-			return fmt.Errorf("could not get sites for cron initialization: %v", err)
-		}
-		log.Printf("got sites for cron initialization but encountered error: %v", err)
+		return fmt.Errorf("could not get crons for cron initialization: %w", err)
 	}
-	for _, site := range sites {
-		crons, err := model.GetCronsBySite(ctx, settingsStore, site.Skey)
-		if err != nil {
-			log.Printf("failed to get crons from site=%d: %v", site.Skey, err)
-			continue
-		}
-		for j := range crons {
-			err = cronScheduler.Set(&crons[j])
-			if err != nil {
-				log.Printf("failed to set job %v from site=%d: %v", crons[j], site.Skey, err)
-			}
-		}
-		log.Printf("set %d crons for site=%d", len(crons), site.Skey)
+	res := cronScheduler.Reconcile(crons, false)
+	for _, msg := range res.Errors {
+		log.Printf("failed to set job %s", msg)
 	}
+	log.Printf("set %d of %d crons", len(res.Added), len(crons))
+
+	return nil
+}
 
+// checkCronClaims checks that a request originates from App Engine
+// cron, i.e., has the X-Appengine-Cron header, which App Engine
+// removes from external requests, or else carries a JWT signed with
+// the cron secret. When skey is non-zero, the JWT's skey claim must
+// match it.
+func checkCronClaims(r *http.Request, skey int64) error {
+	if r.Header.Get("X-Appengine-Cron") == "true" {
+		return nil
+	}
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	if err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+	if claims["iss"] != cronServiceAccount {
+		return fmt.Errorf("invalid issuer: %q", claims["iss"])
+	}
+	if skey == 0 {
+		return nil
+	}
+	sk, ok := claims["skey"].(float64)
+	if !ok || int64(sk) != skey {
+		return fmt.Errorf("invalid skey: %v", claims["skey"])
+	}
 	return nil
 }
 
@@ -0,0 +1,163 @@
+/*
+DESCRIPTION
+  Reconciliation of the cron scheduler with the crons in the datastore.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// reconcileResult is the JSON representation of a reconciliation,
+// listing the crons, as site key and cron ID, e.g., "3.Broadcast Check",
+// that were added to, removed from or changed in the scheduler.
+type reconcileResult struct {
+	DryRun  bool     `json:"dryRun,omitempty"`
+	Crons   int      `json:"crons"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// String returns the site key and ID of a cron.
+func (id cronID) String() string {
+	return strconv.FormatInt(id.Site, 10) + "." + id.ID
+}
+
+// reconcileHandler handles requests to reconcile the scheduler with
+// the crons in the datastore, which self-heals any drift, e.g., from
+// set and unset requests that were lost. The dryrun query parameter
+// reports differences without changing the scheduler. It is invoked
+// nightly via App Engine cron (see cron.yaml) and on demand from Ocean
+// Bench, and so must be authorized by checkCronClaims.
+func reconcileHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	ctx := r.Context()
+	setup(ctx)
+
+	err := checkCronClaims(r, 0)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("request from %s is not authorized: %v", r.RemoteAddr, err))
+		return
+	}
+
+	crons, err := getAllCrons(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "could not get crons: "+err.Error())
+		return
+	}
+	res := cronScheduler.Reconcile(crons, r.FormValue("dryrun") == "true")
+	log.Printf("reconciled %d crons: %d added, %d removed, %d changed, %d errors", res.Crons, len(res.Added), len(res.Removed), len(res.Changed), len(res.Errors))
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("could not write reconcile result: %v", err)
+	}
+}
+
+// getAllCrons returns the crons of all sites.
+func getAllCrons(ctx context.Context) ([]model.Cron, error) {
+	sites, err := model.GetAllSites(ctx, settingsStore)
+	if err != nil {
+		if sites == nil {
+			return nil, fmt.Errorf("could not get sites: %w", err)
+		}
+		log.Printf("got sites but encountered error: %v", err)
+	}
+	var all []model.Cron
+	for _, site := range sites {
+		crons, err := model.GetCronsBySite(ctx, settingsStore, site.Skey)
+		if err != nil {
+			log.Printf("failed to get crons from site=%d: %v", site.Skey, err)
+			continue
+		}
+		all = append(all, crons...)
+	}
+	return all, nil
+}
+
+// Reconcile sets the scheduler's jobs to the enabled crons, adding
+// those that are not scheduled, re-setting those whose state differs
+// and removing jobs that are not enabled crons, returning what was
+// done. When dryRun is true, the scheduler is left unchanged.
+func (s *scheduler) Reconcile(crons []model.Cron, dryRun bool) *reconcileResult {
+	res := &reconcileResult{DryRun: dryRun, Crons: len(crons), Added: []string{}, Removed: []string{}, Changed: []string{}}
+
+	// Take a snapshot of the scheduled jobs, since Set locks the scheduler.
+	s.mu.Lock()
+	scheduled := make(map[cronID]model.Cron, len(s.ids))
+	for id, entry := range s.ids {
+		scheduled[id] = s.entries[entry]
+	}
+	s.mu.Unlock()
+
+	set := func(job *model.Cron, list *[]string) {
+		id := cronID{Site: job.Skey, ID: job.ID}
+		if !dryRun {
+			err := s.Set(job)
+			if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", id, err))
+				return
+			}
+		}
+		*list = append(*list, id.String())
+	}
+
+	enabled := make(map[cronID]bool)
+	for i := range crons {
+		job := &crons[i]
+		if !job.Enabled {
+			continue
+		}
+		id := cronID{Site: job.Skey, ID: job.ID}
+		enabled[id] = true
+		current, ok := scheduled[id]
+		switch {
+		case !ok:
+			set(job, &res.Added)
+		case !isSameCron(current, *job):
+			set(job, &res.Changed)
+		}
+	}
+	for id := range scheduled {
+		if !enabled[id] {
+			set(&model.Cron{Skey: id.Site, ID: id.ID, Enabled: false}, &res.Removed)
+		}
+	}
+
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Strings(res.Changed)
+	sort.Strings(res.Errors)
+	return res
+}
@@ -0,0 +1,147 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt. If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	settingsStore, err = datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up datastore: %v", err)
+	}
+	model.RegisterEntities()
+	err = model.PutSite(ctx, settingsStore, &model.Site{Skey: 1, Name: "localhost", Enabled: true})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	s, err := newScheduler()
+	if err != nil {
+		t.Fatalf("newScheduler returned error: %v", err)
+	}
+
+	const url = "https://tv.cloudblue.org/checkbroadcasts"
+	same := model.Cron{Skey: 1, ID: "same", TOD: "0 6 * * *", Action: "rpc", Var: url, Enabled: true}
+	changed := model.Cron{Skey: 1, ID: "changed", TOD: "0 6 * * *", Action: "rpc", Var: url, Enabled: true}
+	disabled := model.Cron{Skey: 1, ID: "disabled", TOD: "0 6 * * *", Action: "rpc", Var: url, Enabled: true}
+	deleted := model.Cron{Skey: 1, ID: "deleted", TOD: "0 6 * * *", Action: "rpc", Var: url, Enabled: true}
+	for _, c := range []model.Cron{same, changed, disabled, deleted} {
+		err = s.Set(&c)
+		if err != nil {
+			t.Fatalf("Set(%s) returned error: %v", c.ID, err)
+		}
+	}
+
+	// Drift the datastore from the scheduler.
+	changed.TOD = "0 7 * * *"
+	disabled.Enabled = false
+	added := model.Cron{Skey: 1, ID: "added", TOD: "0 6 * * *", Action: "rpc", Var: url, Enabled: true}
+	for _, c := range []model.Cron{same, changed, disabled, added} {
+		err = model.PutCron(ctx, settingsStore, &c)
+		if err != nil {
+			t.Fatalf("could not put cron %s: %v", c.ID, err)
+		}
+	}
+	crons, err := getAllCrons(ctx)
+	if err != nil {
+		t.Fatalf("getAllCrons returned error: %v", err)
+	}
+
+	check := func(res *reconcileResult) {
+		t.Helper()
+		if !slices.Equal(res.Added, []string{"1.added"}) ||
+			!slices.Equal(res.Removed, []string{"1.deleted", "1.disabled"}) ||
+			!slices.Equal(res.Changed, []string{"1.changed"}) ||
+			len(res.Errors) != 0 {
+			t.Errorf("unexpected reconcile result: %+v", res)
+		}
+	}
+
+	// A dry run reports differences without resolving them.
+	check(s.Reconcile(crons, true))
+	check(s.Reconcile(crons, false))
+
+	res := s.Reconcile(crons, false)
+	if len(res.Added)+len(res.Removed)+len(res.Changed) != 0 {
+		t.Errorf("scheduler not reconciled: %+v", res)
+	}
+	if len(s.ids) != 3 {
+		t.Errorf("got %d scheduled crons, expected 3", len(s.ids))
+	}
+}
+
+func TestReconcileAuth(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	settingsStore, err = datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up datastore: %v", err)
+	}
+	model.RegisterEntities()
+	cronScheduler, err = newScheduler()
+	if err != nil {
+		t.Fatalf("newScheduler returned error: %v", err)
+	}
+	defer func(secret []byte) { cronSecret = secret }(cronSecret)
+	cronSecret = []byte("secret")
+
+	sign := func(secret []byte) string {
+		tok, err := gauth.PutClaims(map[string]interface{}{"iss": cronServiceAccount}, secret)
+		if err != nil {
+			t.Fatalf("could not sign claims: %v", err)
+		}
+		return "Bearer " + tok
+	}
+
+	tests := []struct {
+		desc   string
+		header string
+		value  string
+		want   int
+	}{
+		{desc: "unauthenticated", want: http.StatusUnauthorized},
+		{desc: "app engine cron", header: "X-Appengine-Cron", value: "true", want: http.StatusOK},
+		{desc: "cron JWT", header: "Authorization", value: sign(cronSecret), want: http.StatusOK},
+		{desc: "wrong secret", header: "Authorization", value: sign([]byte("other")), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/cron/reconcile?dryrun=true", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		reconcileHandler(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.desc, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Simulation limits.
+const (
+	defaultSimulatedRuns = 10
+	maxSimulatedRuns     = 100
+)
+
+// redacted replaces the values of secret variables in simulations.
+const redacted = "[redacted]"
+
+// secretWords are words which, when part of a variable's name, mark
+// the variable as secret, e.g., "RTMPKey" or "ApiToken".
+var secretWords = []string{"key", "secret", "token", "password", "rtmp"}
+
+// cronSimulation is the JSON representation of a simulated cron,
+// i.e., when it would run and what it would affect.
+type cronSimulation struct {
+	Spec     string            `json:"spec"`               // Cron spec, including the site location, if any.
+	Timezone string            `json:"timezone"`           // Site timezone of times.
+	Times    []string          `json:"times"`              // Next run times in RFC3339 format.
+	Variable string            `json:"variable,omitempty"` // Variable set or deleted, if any.
+	Value    *string           `json:"value,omitempty"`    // Current value of variable, if it exists.
+	Devices  []simulatedDevice `json:"devices,omitempty"`  // Devices affected, if any.
+	Warnings []string          `json:"warnings,omitempty"` // Likely misconfigurations.
+}
+
+// simulatedDevice is a device affected by a simulated cron.
+type simulatedDevice struct {
+	MAC     string `json:"mac"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// checkSiteAdmin checks that a request is made on behalf of an admin of
+// the given site, i.e., that it carries a JWT signed with the cron
+// secret, such as one issued by Ocean Bench, whose skey claim is the
+// site and whose email claim is that of a site admin, either directly
+// or as an admin of the site's organization.
+func checkSiteAdmin(ctx context.Context, r *http.Request, skey int64) error {
+	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
+	if err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+	if claims["iss"] != cronServiceAccount {
+		return fmt.Errorf("invalid issuer: %q", claims["iss"])
+	}
+	if sk, ok := claims["skey"].(float64); !ok || int64(sk) != skey {
+		return fmt.Errorf("invalid skey: %v", claims["skey"])
+	}
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return errors.New("missing email")
+	}
+	user, err := model.GetUser(ctx, settingsStore, skey, email)
+	if err == nil && user.Perm&model.AdminPermission != 0 {
+		return nil
+	}
+	site, err := model.GetSite(ctx, settingsStore, skey)
+	if err == nil && model.IsOrgAdmin(ctx, settingsStore, site.OrgID, email) {
+		return nil
+	}
+	return fmt.Errorf("%s is not an admin of site %d", email, skey)
+}
+
+// writeSimulation writes the simulation of a cron as JSON. The cron is
+// given as JSON in the request body, e.g., an unsaved edit, or else
+// the saved cron is simulated. The cron is simulated as if enabled, and
+// is neither saved nor scheduled. The optional n query param is the
+// number of run times, which defaults to defaultSimulatedRuns. Times
+// are in the site's timezone. Requests must be authorized by
+// checkSiteAdmin.
+func writeSimulation(w http.ResponseWriter, r *http.Request, skey int64, id string) {
+	n := defaultSimulatedRuns
+	if v := r.FormValue("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSimulatedRuns {
+			writeError(w, http.StatusBadRequest, "invalid n: "+v)
+			return
+		}
+	}
+
+	var job model.Cron
+	if r.Method == http.MethodPost {
+		err := json.NewDecoder(r.Body).Decode(&job)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cron: "+err.Error())
+			return
+		}
+	} else {
+		saved, err := model.GetCron(r.Context(), settingsStore, skey, id)
+		if err != nil {
+			log.Printf("could not get cron %s: %v", id, err)
+			writeError(w, http.StatusNotFound, "could not get cron "+id)
+			return
+		}
+		job = *saved
+	}
+	job.Skey = skey
+	job.ID = id
+
+	sim, err := simulateCron(r.Context(), &job, time.Now(), n)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sim)
+}
+
+// simulateCron returns the next n run times of a cron after t and
+// resolves the variable and devices that it would affect. Errors are
+// returned for crons that could not be scheduled, whereas likely
+// misconfigurations, such as variables for unknown devices, are
+// returned as warnings.
+func simulateCron(ctx context.Context, job *model.Cron, t time.Time, n int) (*cronSimulation, error) {
+	job.Enabled = true
+	spec, sched, err := cronSchedule(job)
+	if err != nil {
+		return nil, err
+	}
+	loc := siteTimezone(job.Skey)
+
+	sim := &cronSimulation{Spec: spec, Timezone: loc.String(), Times: []string{}}
+	for next := t.In(loc); len(sim.Times) < n; {
+		next = sched.Next(next)
+		if next.IsZero() {
+			sim.Warnings = append(sim.Warnings, "cron does not run again")
+			break
+		}
+		sim.Times = append(sim.Times, next.In(loc).Format(time.RFC3339))
+	}
+
+	s := &scheduler{funcs: cronFuncs}
+	action, err := s.action(job)
+	if err != nil {
+		return nil, err
+	}
+	if action == nil {
+		sim.Warnings = append(sim.Warnings, fmt.Sprintf("action %s is not implemented", job.Action))
+	}
+
+	devs, err := model.GetDevicesBySite(ctx, settingsStore, job.Skey)
+	if err != nil {
+		return nil, fmt.Errorf("could not get devices for site=%d: %w", job.Skey, err)
+	}
+	sort.Slice(devs, func(i, j int) bool { return devs[i].Mac < devs[j].Mac })
+
+	switch strings.ToLower(job.Action) {
+	case "set", "del":
+		sim.Variable = job.Var
+		v, err := model.GetVariable(ctx, settingsStore, job.Skey, job.Var)
+		switch {
+		case err == nil:
+			value := v.Value
+			if secretVar(v.Name) {
+				value = redacted
+			}
+			sim.Value = &value
+		case errors.Is(err, datastore.ErrNoSuchEntity):
+			if strings.ToLower(job.Action) == "del" {
+				sim.Warnings = append(sim.Warnings, fmt.Sprintf("variable %s does not exist", job.Var))
+			}
+		default:
+			return nil, fmt.Errorf("could not get variable %s: %w", job.Var, err)
+		}
+		scope, _, scoped := strings.Cut(job.Var, ".")
+		if scoped {
+			sim.addDevices(devs, scope, fmt.Sprintf("no device %s for variable %s", scope, job.Var))
+		}
+
+	case "call":
+		if job.Var == "check" {
+			warning := "no devices to check"
+			if job.Data != "" {
+				warning = fmt.Sprintf("no device %s to check", job.Data)
+			}
+			sim.addDevices(devs, job.Data, warning)
+		}
+	}
+	return sim, nil
+}
+
+// addDevices adds the devices whose MAC address matches mac, with or
+// without colons, or all devices if mac is empty. If no device
+// matches, the warning is added instead. Disabled devices are also
+// warned of.
+func (sim *cronSimulation) addDevices(devs []model.Device, mac, warning string) {
+	mac = strings.ToLower(strings.ReplaceAll(mac, ":", ""))
+	for _, dev := range devs {
+		if mac != "" && mac != dev.Hex() {
+			continue
+		}
+		sim.Devices = append(sim.Devices, simulatedDevice{MAC: dev.MAC(), Name: dev.Name, Enabled: dev.Enabled})
+		if !dev.Enabled {
+			sim.Warnings = append(sim.Warnings, fmt.Sprintf("device %s (%s) is disabled", dev.Name, dev.MAC()))
+		}
+	}
+	if len(sim.Devices) == 0 {
+		sim.Warnings = append(sim.Warnings, warning)
+	}
+}
+
+// secretVar returns true if the named variable is secret, i.e., if
+// its name, excluding any scope, contains one of secretWords.
+func secretVar(name string) bool {
+	if _, n, ok := strings.Cut(name, "."); ok {
+		name = n
+	}
+	name = strings.ToLower(name)
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
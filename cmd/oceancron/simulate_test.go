@@ -0,0 +1,206 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestSimulateCron(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	settingsStore, err = datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up datastore: %v", err)
+	}
+	model.RegisterEntities()
+	err = model.PutSite(ctx, settingsStore, &model.Site{Skey: 1, Name: "localhost", Enabled: true})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	for _, dev := range []model.Device{
+		{Skey: 1, Mac: model.MacEncode("00:00:00:00:00:01"), Name: "Controller", Enabled: true},
+		{Skey: 1, Mac: model.MacEncode("00:00:00:00:00:02"), Name: "Camera"},
+	} {
+		err = model.PutDevice(ctx, settingsStore, &dev)
+		if err != nil {
+			t.Fatalf("could not put device: %v", err)
+		}
+	}
+	for name, value := range map[string]string{"000000000001.Power1": "true", "000000000001.RTMPKey": "rtmp://a.rtmp.youtube.com/live2/abcd-1234"} {
+		err = model.PutVariable(ctx, settingsStore, 1, name, value)
+		if err != nil {
+			t.Fatalf("could not put variable: %v", err)
+		}
+	}
+
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	now := time.Date(2026, time.October, 16, 12, 0, 0, 0, loc)
+
+	tests := []struct {
+		desc         string
+		cron         model.Cron
+		wantTimes    []string
+		wantValue    string
+		wantDevices  []string
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			desc:        "set device variable",
+			cron:        model.Cron{TOD: "30 6 * * *", Action: "set", Var: "00:00:00:00:00:01.Power1", Data: "false"},
+			wantTimes:   []string{"2026-10-17T06:30:00+10:30", "2026-10-18T06:30:00+10:30", "2026-10-19T06:30:00+10:30"},
+			wantValue:   "true",
+			wantDevices: []string{"00:00:00:00:00:01"},
+		},
+		{
+			desc:        "set secret variable",
+			cron:        model.Cron{TOD: "30 6 * * *", Action: "set", Var: "00:00:00:00:00:01.RTMPKey", Data: "rtmp://example.com/live/key"},
+			wantTimes:   []string{"2026-10-17T06:30:00+10:30"},
+			wantValue:   redacted,
+			wantDevices: []string{"00:00:00:00:00:01"},
+		},
+		{
+			desc:         "delete missing variable of unknown device",
+			cron:         model.Cron{TOD: "0 * * * *", Action: "del", Var: "0000000000ff.Power1"},
+			wantTimes:    []string{"2026-10-16T13:00:00+10:30", "2026-10-16T14:00:00+10:30", "2026-10-16T15:00:00+10:30"},
+			wantWarnings: []string{"variable 0000000000ff.Power1 does not exist", "no device 0000000000ff"},
+		},
+		{
+			desc:         "check all devices",
+			cron:         model.Cron{TOD: "@midnight", Action: "call", Var: "check"},
+			wantTimes:    []string{"2026-10-17T00:00:00+10:30", "2026-10-18T00:00:00+10:30", "2026-10-19T00:00:00+10:30"},
+			wantDevices:  []string{"00:00:00:00:00:01", "00:00:00:00:00:02"},
+			wantWarnings: []string{"device Camera (00:00:00:00:00:02) is disabled"},
+		},
+		{
+			desc:    "solar event without site location",
+			cron:    model.Cron{TOD: "@sunrise", Action: "set", Var: "Mode", Data: "Day"},
+			wantErr: true,
+		},
+		{
+			desc:    "unknown function",
+			cron:    model.Cron{TOD: "@midnight", Action: "call", Var: "reboot"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.cron.Skey = 1
+			tt.cron.ID = "test"
+			sim, err := simulateCron(ctx, &tt.cron, now, len(tt.wantTimes))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if strings.Join(sim.Times, ",") != strings.Join(tt.wantTimes, ",") {
+				t.Errorf("got times %v, want %v", sim.Times, tt.wantTimes)
+			}
+			if tt.wantValue != "" && (sim.Value == nil || *sim.Value != tt.wantValue) {
+				t.Errorf("got value %v, want %s", sim.Value, tt.wantValue)
+			}
+			var macs []string
+			for _, dev := range sim.Devices {
+				macs = append(macs, dev.MAC)
+			}
+			if strings.Join(macs, ",") != strings.Join(tt.wantDevices, ",") {
+				t.Errorf("got devices %v, want %v", macs, tt.wantDevices)
+			}
+			if len(sim.Warnings) != len(tt.wantWarnings) {
+				t.Fatalf("got warnings %q, want %q", sim.Warnings, tt.wantWarnings)
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(sim.Warnings[i], want) {
+					t.Errorf("got warning %q, want %q", sim.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSiteAdmin(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	settingsStore, err = datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not set up datastore: %v", err)
+	}
+	model.RegisterEntities()
+	defer func(secret []byte) { cronSecret = secret }(cronSecret)
+	cronSecret = []byte("secret")
+
+	for _, user := range []model.User{
+		{Skey: 1, Email: "admin@example.com", Perm: model.ReadPermission | model.WritePermission | model.AdminPermission},
+		{Skey: 1, Email: "writer@example.com", Perm: model.ReadPermission | model.WritePermission},
+	} {
+		err = model.PutUser(ctx, settingsStore, &user)
+		if err != nil {
+			t.Fatalf("could not put user: %v", err)
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		claims  map[string]interface{}
+		secret  []byte
+		wantErr bool
+	}{
+		{desc: "site admin", claims: map[string]interface{}{"iss": cronServiceAccount, "skey": 1, "email": "admin@example.com"}},
+		{desc: "not an admin", claims: map[string]interface{}{"iss": cronServiceAccount, "skey": 1, "email": "writer@example.com"}, wantErr: true},
+		{desc: "other site", claims: map[string]interface{}{"iss": cronServiceAccount, "skey": 2, "email": "admin@example.com"}, wantErr: true},
+		{desc: "no email", claims: map[string]interface{}{"iss": cronServiceAccount, "skey": 1}, wantErr: true},
+		{desc: "wrong secret", claims: map[string]interface{}{"iss": cronServiceAccount, "skey": 1, "email": "admin@example.com"}, secret: []byte("other"), wantErr: true},
+	}
+	for _, tt := range tests {
+		secret := tt.secret
+		if secret == nil {
+			secret = cronSecret
+		}
+		tok, err := gauth.PutClaims(tt.claims, secret)
+		if err != nil {
+			t.Fatalf("%s: could not sign claims: %v", tt.desc, err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/cron/simulate/1/test", nil)
+		r.Header.Set("Authorization", "Bearer "+tok)
+		err = checkSiteAdmin(ctx, r, 1)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkSiteAdmin returned error %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+	}
+	if err := checkSiteAdmin(ctx, httptest.NewRequest(http.MethodGet, "/cron/simulate/1/test", nil), 1); err == nil {
+		t.Errorf("checkSiteAdmin accepted request without token")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean Cron. Ocean Cron is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean Cron is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with Ocean Cron in gpl.txt.  If not, see
+  <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+)
+
+// webhook represents an HTTP request performed by a cron with the
+// "http" action. The cron's Var holds the method and URL separated by
+// a space, e.g., "PUT https://example.com/hook", where the method is
+// optional and defaults to POST. The cron's Data holds the body, which
+// is a text/template executed with webhookData. For example:
+//
+//	{"site":"{{.Site.Name}}","mode":"{{index .Vars "ABCDEF123456.mode"}}"}
+//
+// Each webhook has its own secret, which is never shared with other
+// webhooks or services. When the webhook's secret is present in the
+// secrets under the key returned by webhookSecretKey, requests carry a
+// short-lived JWT signed with it, otherwise requests are unauthenticated.
+type webhook struct {
+	method string
+	url    string
+	body   *template.Template
+	secret []byte
+}
+
+// webhookTokenLifetime is the lifetime of the JWTs carried by webhook requests.
+const webhookTokenLifetime = 5 * time.Minute
+
+// webhookSecrets holds webhook secrets by webhookSecretKey.
+var webhookSecrets map[string]string
+
+// webhookSecretKey returns the key of the secret for the given cron's
+// webhook, e.g., "webhook.3.poke" for the "poke" cron of site 3.
+func webhookSecretKey(job *model.Cron) string {
+	return fmt.Sprintf("webhook.%d.%s", job.Skey, job.ID)
+}
+
+// webhookData is the data available to webhook body templates.
+type webhookData struct {
+	Site *model.Site       // The cron's site.
+	Vars map[string]string // Site variables, including device variables, e.g., "ABCDEF123456.mode".
+	Cron model.Cron        // The cron.
+	Time time.Time         // The time the request is made.
+}
+
+// newWebhook parses the webhook for the given cron.
+func newWebhook(job *model.Cron) (*webhook, error) {
+	wh := &webhook{method: http.MethodPost, url: strings.TrimSpace(job.Var)}
+	if method, u, ok := strings.Cut(wh.url, " "); ok {
+		wh.method = strings.ToUpper(method)
+		wh.url = strings.TrimSpace(u)
+	}
+	switch wh.method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil, fmt.Errorf("invalid http method %s", wh.method)
+	}
+
+	u, err := url.Parse(wh.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid http URL %s", wh.url)
+	}
+
+	wh.body, err = template.New(job.ID).Option("missingkey=zero").Parse(job.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http body template: %w", err)
+	}
+	if secret := webhookSecrets[webhookSecretKey(job)]; secret != "" {
+		wh.secret = []byte(secret)
+	}
+	return wh, nil
+}
+
+// do renders the body for the given cron and performs the request.
+func (wh *webhook) do(ctx context.Context, job model.Cron) error {
+	site, err := model.GetSite(ctx, settingsStore, job.Skey)
+	if err != nil {
+		return fmt.Errorf("http %s could not get site: %w", wh.url, err)
+	}
+	vars, err := model.GetVariablesBySite(ctx, settingsStore, job.Skey, "")
+	if err != nil {
+		return fmt.Errorf("http %s could not get variables: %w", wh.url, err)
+	}
+	data := webhookData{Site: site, Vars: make(map[string]string, len(vars)), Cron: job, Time: time.Now()}
+	for _, v := range vars {
+		data.Vars[v.Name] = v.Value
+	}
+
+	var body bytes.Buffer
+	err = wh.body.Execute(&body, data)
+	if err != nil {
+		return fmt.Errorf("http %s could not render body: %w", wh.url, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, wh.method, wh.url, &body)
+	if err != nil {
+		return fmt.Errorf("http %s request invalid: %w", wh.url, err)
+	}
+	if b := bytes.TrimSpace(body.Bytes()); len(b) > 0 {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if b[0] == '{' || b[0] == '[' {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if wh.secret != nil {
+		now := time.Now()
+		claims := map[string]interface{}{
+			"iss":  cronServiceAccount,
+			"skey": job.Skey,
+			"cron": job.ID,
+			"iat":  now.Unix(),
+			"exp":  now.Add(webhookTokenLifetime).Unix(),
+		}
+		tokString, err := gauth.PutClaims(claims, wh.secret)
+		if err != nil {
+			return fmt.Errorf("http %s request error signing claims: %w", wh.url, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tokString)
+	}
+
+	clt := &http.Client{Timeout: time.Minute}
+	resp, err := clt.Do(req)
+	if err != nil {
+		return fmt.Errorf("http %s request error: %w", wh.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("http %s returned unexpected status: %s", wh.url, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
@@ -30,13 +30,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/cmd/oceantv/broadcast"
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
@@ -101,10 +102,13 @@ type BroadcastConfig struct {
 	EndTimestamp             string        // End time of the broadcast in unix format.
 	End                      time.Time     // End time in native go format for easy operations.
 	VidforwardHost           string        // Host address of vidforward service.
+	HLSOutput                string        // Playlist vidforward also writes HLS to, i.e., a gs:// URL or a path on vidforward, e.g., "rapidbay/index.m3u8", or empty for RTMP only.
+	HLSFeed                  int64         // ID of the AusOcean TV feed played from the HLS output, if any.
 	CameraMac                int64         // Camera hardware's MAC address.
 	ControllerMAC            int64         // Controller hardware's MAC adress (controller used to power camera).
 	OnActions                string        // A series of actions to be used for power up of camera hardware.
 	OffActions               string        // A series of actions to be used for power down of camera hardware.
+	Controllers              []Controller  // Additional controllers, e.g., powering lights separately to the camera.
 	RTMPVar                  string        // The variable name that holds the RTMP URL and key.
 	Active                   bool          // This is true if the broadcast is currently active i.e. waiting for data or currently streaming.
 	Slate                    bool          // This is true if the broadcast is currently in slate mode i.e. no camera.
@@ -128,8 +132,31 @@ type BroadcastConfig struct {
 	RecoveringVoltage        bool          // True if the broadcast is currently recovering voltage.
 	RequiredStreamingVoltage float64       // The required battery voltage for the camera to stream.
 	VoltageRecoveryTimeout   int           // Max allowable hours for voltage recovery before failure.
+	VoltageRecoveryStrategy  string        // The voltage recovery strategy, i.e. linear (default), solar or extrapolate.
 	RegisterOpenFish         bool          // True if the video should be registered with openfish for annotation.
-	OpenFishCaptureSource    string        // The capture source to register the stream to.
+	OpenFishCaptureSource    string        // The capture source to register the stream to, created if empty.
+	OpenFishAnnotators       string        // Comma-separated emails of users to annotate the stream in openfish.
+	NotifyRecipients         string        // Comma-separated emails notified in addition to the site's recipients.
+	NotifyRoutes             string        // Channel overrides, e.g., "sms:+61400000000,+61411111111;slack".
+	MinStatusInterval        int           // Min minutes between status checks, used when unhealthy or just started (default 1).
+	MaxStatusInterval        int           // Max minutes between status checks, backed off to while healthy (default 10).
+	SlateSchedule            string        // Daily slate windows for permanent broadcasts in local time, e.g., "20:00-06:00=back-at-dawn.mp4".
+	GenerateSlate            bool          // True if slates showing the site name and resume time are generated for permanent broadcasts.
+	SlateImageURL            string        // URL of the base image of generated slates, if any, otherwise a plain background is used.
+	ReadinessLeadTime        int           // Minutes before the start at which readiness is checked, or zero to not check.
+	ReadinessChecked         time.Time     // The start time for which readiness was last checked.
+	TitleTemplate            string        // Template for refreshed titles, e.g., "{{.Site}} Live {{.Date}}", or empty to use the name and date.
+	ThumbnailURL             string        // URL of an image set as the thumbnail when metadata is refreshed, if any.
+	MetadataRefreshTime      string        // Daily local time at which metadata is refreshed, e.g., "06:00", or empty to not refresh.
+	MetadataRefreshed        time.Time     // The time metadata was last refreshed.
+	MetadataErr              string        // The metadata refresh error last notified, if any.
+	SendConditions           bool          // True if tide and weather conditions are added to the description and chat messages.
+	HealthIncidents          int           // The number of health incidents during the current broadcast.
+	PowerOffDelay            int           // Minutes after the end for which hardware stays on and the stream continues, or zero to power off at the end.
+	Draining                 bool          // True if the broadcast has finished and is waiting for its power off delay before powering off.
+	OverrunTolerance         int           // Minutes past the end, after any power off delay, before an active broadcast is forcibly finished (default 30).
+	ViewersSampled           time.Time     // The time concurrent viewers were last sampled.
+	PendingChanges           []byte        // Restart-required changes saved while active, JSON encoded, which are applied once inactive.
 }
 
 // SensorEntry contains the information for each sensor.
@@ -140,6 +167,43 @@ type SensorEntry struct {
 	DeviceMac int64
 }
 
+// Controller contains the information for a controller powering
+// broadcast hardware, e.g., lights powered separately to the camera.
+type Controller struct {
+	MAC        int64  // Controller MAC address.
+	OnActions  string // Actions to power up the controller's hardware.
+	OffActions string // Actions to power down the controller's hardware.
+	VoltagePin string // Battery voltage pin, defaultVoltagePin if empty.
+}
+
+// defaultVoltagePin is the controller pin reporting battery voltage,
+// unless otherwise specified.
+const defaultVoltagePin = "A0"
+
+// voltagePin returns the controller's battery voltage pin.
+func (c Controller) voltagePin() string {
+	if c.VoltagePin == "" {
+		return defaultVoltagePin
+	}
+	return c.VoltagePin
+}
+
+// controllers returns the controllers whose voltage and status are
+// monitored, i.e., the primary controller given by ControllerMAC,
+// followed by any additional controllers, omitting unset MACs.
+func (cfg *BroadcastConfig) controllers() []Controller {
+	var cs []Controller
+	if cfg.ControllerMAC != 0 {
+		cs = append(cs, Controller{MAC: cfg.ControllerMAC, OnActions: cfg.OnActions, OffActions: cfg.OffActions})
+	}
+	for _, c := range cfg.Controllers {
+		if c.MAC != 0 {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
 type Camera struct {
 	Name string // Name of camera device.
 	MAC  string // Encoded MAC address of associated camera device.
@@ -160,36 +224,61 @@ func (c *BroadcastConfig) parseStartEnd() error {
 	return nil
 }
 
+// Default bounds of the interval between status checks.
+const (
+	defaultMinStatusInterval = 1 * time.Minute
+	defaultMaxStatusInterval = 10 * time.Minute
+)
+
+// defaultRequiredStreamingVoltage is the battery voltage required for
+// the camera to stream when RequiredStreamingVoltage is not set.
+const defaultRequiredStreamingVoltage = 24.5
+
+// statusIntervalBounds returns the minimum and maximum intervals
+// between status checks, using the defaults where not configured.
+func (c *BroadcastConfig) statusIntervalBounds() (lo, hi time.Duration) {
+	lo, hi = defaultMinStatusInterval, defaultMaxStatusInterval
+	if c.MinStatusInterval > 0 {
+		lo = time.Duration(c.MinStatusInterval) * time.Minute
+	}
+	if c.MaxStatusInterval > 0 {
+		hi = time.Duration(c.MaxStatusInterval) * time.Minute
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
 // checkBroadcastsHandler checks the broadcasts for a single site.
 // It is designed to be invoked via OceanCron rpc requests, not cron.yaml.
 func checkBroadcastsHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
-
 	ctx := r.Context()
 	claims, err := gauth.GetClaims(r.Header.Get("Authorization"), cronSecret)
 	if err != nil {
-		writeError(w, http.StatusUnauthorized, fmt.Errorf("request from %s has invalid claims: %v", r.RemoteAddr, err))
+		backend.WriteError(w, http.StatusUnauthorized, fmt.Errorf("request from %s has invalid claims: %w", r.RemoteAddr, err))
 		return
 	}
 	if claims["iss"] != cronServiceAccount {
-		writeError(w, http.StatusUnauthorized, fmt.Errorf("request from %s has invalid issuer: %q", r.RemoteAddr, claims["iss"]))
+		backend.WriteError(w, http.StatusUnauthorized, fmt.Errorf("request from %s has invalid issuer: %q", r.RemoteAddr, claims["iss"]))
 		return
 	}
 	if _, ok := claims["skey"].(float64); !ok {
-		writeError(w, http.StatusBadRequest, fmt.Errorf("request from %s has invalid skey: %q", r.RemoteAddr, claims["skey"]))
+		backend.WriteError(w, http.StatusBadRequest, fmt.Errorf("request from %s has invalid skey: %q", r.RemoteAddr, claims["skey"]))
 		return
 	}
 
 	skey := int64(claims["skey"].(float64))
+	ctx = backend.WithSite(ctx, skey)
 	site, err := model.GetSite(ctx, settingsStore, skey)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Errorf("error getting site %d: %v", skey, err))
+		backend.WriteError(w, http.StatusInternalServerError, fmt.Errorf("could not get site %d: %w", skey, err))
 		return
 	}
-	log.Printf("checking broadcasts for site %d", skey)
+	backend.Printf(ctx, "checking broadcasts for site %d", skey)
 	err = checkBroadcastsForSites(ctx, []model.Site{*site})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Errorf("error checking broadcasts for site %d: %v", skey, err))
+		backend.WriteError(w, http.StatusInternalServerError, fmt.Errorf("could not check broadcasts for site %d: %w", skey, err))
 		return
 	}
 	fmt.Fprint(w, "OK")
@@ -201,7 +290,7 @@ func checkBroadcastsForSites(ctx context.Context, sites []model.Site) error {
 	for _, s := range sites {
 		vars, err := model.GetVariablesBySite(ctx, settingsStore, s.Skey, broadcastScope)
 		if err != nil {
-			log.Printf("could not get broadcast entities for site, skey: %d, name: %s, %v", s.Skey, s.Name, err)
+			backend.Printf(ctx, "could not get broadcast entities for site, skey: %d, name: %s, %v", s.Skey, s.Name, err)
 			continue
 		}
 		cfgVars = append(cfgVars, vars...)
@@ -209,7 +298,7 @@ func checkBroadcastsForSites(ctx context.Context, sites []model.Site) error {
 
 	// If there are no entities then we don't have anything to do.
 	if len(cfgVars) == 0 {
-		log.Println("no broadcast configurations in datastore, doing nothing")
+		backend.Printf(ctx, "no broadcast configurations in datastore, doing nothing")
 		return nil
 	}
 
@@ -243,10 +332,10 @@ func performChecksInternalThroughStateMachine(
 ) error {
 	// We'll use this context to determine if anything happens after the handler
 	// has returned (we might need to store states for next time).
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancel(backend.WithBroadcast(ctx, cfg.ID))
 	defer cancel()
 
-	sys, err := newBroadcastSystem(ctx, store, cfg, log.Println)
+	sys, err := newBroadcastSystem(ctx, store, cfg, backend.LogFunc(ctx))
 	if err != nil {
 		return fmt.Errorf("could not create broadcast system: %w", err)
 	}
@@ -292,34 +381,54 @@ func saveLinkFunc() func(string, string) error {
 // extStart uses the OnActions in the provided broadcast config to perform
 // external streaming hardware startup. In addition, the RTMP key is obtained
 // from the broadcast's associated stream object and used to set the devices
-// RTMPKey variable.
+// RTMPKey variable. The OnActions of any additional controllers are then
+// performed.
 func extStart(ctx context.Context, cfg *BroadcastConfig, log func(string, ...interface{})) error {
-	if cfg.OnActions == "" {
-		return nil
+	if cfg.OnActions != "" {
+		onActions := cfg.OnActions + "," + cfg.RTMPVar + "=" + rtmpDestinationAddress + cfg.RTMPKey
+		err := setActionVars(ctx, cfg.SKey, onActions, settingsStore, log)
+		if err != nil {
+			return fmt.Errorf("could not set device variables required to start stream: %w", err)
+		}
 	}
 
-	onActions := cfg.OnActions + "," + cfg.RTMPVar + "=" + rtmpDestinationAddress + cfg.RTMPKey
-	err := setActionVars(ctx, cfg.SKey, onActions, settingsStore, log)
-	if err != nil {
-		return fmt.Errorf("could not set device variables required to start stream: %w", err)
+	for _, c := range cfg.Controllers {
+		if c.OnActions == "" {
+			continue
+		}
+		err := setActionVars(ctx, cfg.SKey, c.OnActions, settingsStore, log)
+		if err != nil {
+			return fmt.Errorf("could not set device variables required to start controller %s: %w", model.MacDecode(c.MAC), err)
+		}
 	}
 
 	return nil
 }
 
-// extStop uses the OffActions in the provided broadcast config to perform
-// external streaming hardware shutdown.
+// extStop uses the OffActions in the provided broadcast config, and
+// those of any additional controllers, to perform external streaming
+// hardware shutdown. All controllers are stopped even if stopping one
+// fails.
 func extStop(ctx context.Context, cfg *BroadcastConfig, log func(string, ...interface{})) error {
-	if cfg.OffActions == "" {
-		return nil
+	var errs []error
+	if cfg.OffActions != "" {
+		err := setActionVars(ctx, cfg.SKey, cfg.OffActions, settingsStore, log)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not set device variables to end stream: %w", err))
+		}
 	}
 
-	err := setActionVars(ctx, cfg.SKey, cfg.OffActions, settingsStore, log)
-	if err != nil {
-		return fmt.Errorf("could not set device variables to end stream: %w", err)
+	for _, c := range cfg.Controllers {
+		if c.OffActions == "" {
+			continue
+		}
+		err := setActionVars(ctx, cfg.SKey, c.OffActions, settingsStore, log)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not set device variables to stop controller %s: %w", model.MacDecode(c.MAC), err))
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // saveBroadcast saves a broadcast configuration to the datastore with the
@@ -331,12 +440,41 @@ func saveBroadcast(ctx context.Context, cfg *BroadcastConfig, store datastore.St
 		return fmt.Errorf("could not marshal JSON for broadcast save: %w", err)
 	}
 
+	var prev []byte
+	v, err := model.GetVariable(ctx, store, cfg.SKey, broadcastScope+"."+cfg.Name)
+	switch {
+	case err == nil:
+		prev = []byte(v.Value)
+	case !errors.Is(err, datastore.ErrNoSuchEntity):
+		return fmt.Errorf("could not get broadcast data from store: %w", err)
+	}
+
 	log("saving, cfg: %s", provideConfig(cfg))
 	err = model.PutVariable(ctx, store, cfg.SKey, broadcastScope+"."+cfg.Name, string(d))
 	if err != nil {
 		return fmt.Errorf("could not put broadcast data in store: %w", err)
 	}
 
+	return putBroadcastEntity(ctx, store, d, prev)
+}
+
+// putBroadcastEntity mirrors a JSON encoded broadcast configuration to
+// its model.BroadcastConfig entity, which makes it visible to other
+// services. The entity is only written when the configuration differs
+// from prev, its previous encoding, since most saves change nothing.
+func putBroadcastEntity(ctx context.Context, store datastore.Store, d, prev []byte) error {
+	if bytes.Equal(d, prev) {
+		return nil
+	}
+	var c model.BroadcastConfig
+	err := json.Unmarshal(d, &c)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal broadcast config entity: %w", err)
+	}
+	err = model.PutBroadcastConfig(ctx, store, &c)
+	if err != nil {
+		return fmt.Errorf("could not put broadcast config entity: %w", err)
+	}
 	return nil
 }
 
@@ -387,33 +525,73 @@ func stopBroadcast(ctx context.Context, cfg *BroadcastConfig, store datastore.St
 			return fmt.Errorf("could not complete broadcast: %w", err)
 		}
 
+		var ofErr error
 		if cfg.RegisterOpenFish {
 			// Register stream with openfish so we can annotate the video.
-			cs, err := strconv.Atoi(cfg.OpenFishCaptureSource)
-			if err != nil {
-				return fmt.Errorf("bad capturesource ID: %w", err)
-			}
-			err = ofsvc.RegisterStream(cfg.SID, cs, cfg.Start, cfg.End)
-			if err != nil {
-				return fmt.Errorf("register stream with openfish error: %w", err)
-			}
+			ofErr = registerOpenFish(ctx, cfg, store, log)
+		}
+
+		// The archive is written regardless of openfish registration,
+		// which it records.
+		err = archiveBroadcast(ctx, cfg, store, svc, cfg.RegisterOpenFish && ofErr == nil, time.Now(), log)
+		if err != nil {
+			log("could not archive broadcast: %v", err)
+		}
+
+		if ofErr != nil {
+			return fmt.Errorf("could not register stream with openfish: %w", ofErr)
 		}
 	}
 
 	cfg.Active = false
 	err = saveBroadcast(ctx, cfg, store, log)
 	if err != nil {
-		return fmt.Errorf("save broadcast error: %w", err)
+		return fmt.Errorf("could not save broadcast: %w", err)
 	}
 
 	return nil
 }
 
+// registerOpenFish registers the broadcast's stream with openfish.
+// If the broadcast has no capture source, one is created from the
+// broadcast's site and camera, and its ID is stored on the broadcast
+// configuration, to be saved by the caller. The stream is assigned to
+// the broadcast's annotators, if any.
+func registerOpenFish(ctx context.Context, cfg *BroadcastConfig, store datastore.Store, log func(string, ...interface{})) error {
+	if cfg.OpenFishCaptureSource == "" {
+		site, err := model.GetSite(ctx, store, cfg.SKey)
+		if err != nil {
+			return fmt.Errorf("could not get site: %w", err)
+		}
+		hardware := "unknown"
+		cam, err := model.GetDevice(ctx, store, cfg.CameraMac)
+		if err == nil && cam.Name != "" {
+			hardware = cam.Name
+		}
+		id, err := ofsvc.CreateCaptureSource(cfg.Name, site.Latitude, site.Longitude, hardware, cfg.SKey)
+		if err != nil {
+			return fmt.Errorf("could not create capture source: %w", err)
+		}
+		cfg.OpenFishCaptureSource = strconv.FormatInt(id, 10)
+		log("created openfish capture source %d", id)
+	}
+
+	cs, err := strconv.ParseInt(cfg.OpenFishCaptureSource, 10, 64)
+	if err != nil {
+		return fmt.Errorf("could not parse capture source ID: %w", err)
+	}
+	var annotators []string
+	for _, a := range strings.Split(cfg.OpenFishAnnotators, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			annotators = append(annotators, a)
+		}
+	}
+	return ofsvc.RegisterStream(cfg.SID, cs, cfg.Start, cfg.End, annotators)
+}
+
 // liveHandler handles requests to /live/<broadcast name>. This redirects to the
 // livestream URL stored in a variable with name corresponding to the given broadcast name.
 func liveHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
-
 	ctx := r.Context()
 	setup(ctx)
 
@@ -424,6 +602,6 @@ func liveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("redirecting to livestream link, link: %s", v.Value)
+	backend.Printf(ctx, "redirecting to livestream link, link: %s", v.Value)
 	http.Redirect(w, r, v.Value, http.StatusFound)
 }
@@ -0,0 +1,78 @@
+/*
+DESCRIPTION
+  slate.go provides parsing of slate schedules, which are shared by
+  Ocean TV, which displays the scheduled slates, and Ocean Bench, which
+  validates them when broadcasts are saved.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package broadcast
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SlateWindow is a daily time window in which a slate is displayed.
+type SlateWindow struct {
+	Start, End time.Duration // Offsets from local midnight.
+	Slate      string        // Name of slate file.
+}
+
+// ParseSlateSchedule parses a slate schedule, which is a semicolon
+// separated list of daily windows in local time of the form
+// HH:MM-HH:MM=slate, e.g., "20:00-06:00=back-at-dawn.mp4". Windows may
+// span midnight.
+func ParseSlateSchedule(s string) ([]SlateWindow, error) {
+	var windows []SlateWindow
+	for _, w := range strings.Split(s, ";") {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		span, slate, ok := strings.Cut(w, "=")
+		from, to, ok2 := strings.Cut(span, "-")
+		if !ok || !ok2 || strings.TrimSpace(slate) == "" {
+			return nil, fmt.Errorf("invalid slate window: %q", w)
+		}
+		start, err := time.Parse("15:04", strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid slate window start: %q", w)
+		}
+		end, err := time.Parse("15:04", strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid slate window end: %q", w)
+		}
+		windows = append(windows, SlateWindow{
+			Start: time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+			End:   time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+			Slate: strings.TrimSpace(slate),
+		})
+	}
+	return windows, nil
+}
+
+// Contains returns true if the time of day d falls within the window.
+func (w SlateWindow) Contains(d time.Duration) bool {
+	if w.Start <= w.End {
+		return d >= w.Start && d < w.End
+	}
+	return d >= w.Start || d < w.End
+}
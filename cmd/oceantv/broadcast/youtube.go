@@ -37,6 +37,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -484,6 +485,59 @@ func setCatAndDesc(svc *youtube.Service, title, id, description string, log func
 	return resp.ServerResponse, nil
 }
 
+// UpdateMetadata updates the title and description of the broadcast with
+// identification id and, if thumbnail is not nil, sets its thumbnail.
+func UpdateMetadata(svc *youtube.Service, id, title, description string, thumbnail io.Reader, log func(string, ...interface{})) error {
+	_, err := setCatAndDesc(svc, title, id, description, log)
+	if err != nil {
+		return fmt.Errorf("could not set title and description: %w", err)
+	}
+	if thumbnail == nil {
+		return nil
+	}
+	_, err = svc.Thumbnails.Set(id).Media(thumbnail).Do()
+	if err != nil {
+		return fmt.Errorf("could not set thumbnail: %w", err)
+	}
+	return nil
+}
+
+// Stats holds the statistics of a broadcast's video. Start and end
+// times are zero if the broadcast has not started or ended.
+type Stats struct {
+	ActualStart time.Time // Time the broadcast went live.
+	ActualEnd   time.Time // Time the broadcast ended.
+	Views       int64     // View count.
+	Likes       int64     // Like count.
+	Comments    int64     // Comment count.
+	Viewers     int64     // Concurrent viewers, while live.
+}
+
+// GetStats gets the statistics of the video of the broadcast with the
+// provided ID.
+func GetStats(svc *youtube.Service, id string) (*Stats, error) {
+	resp, err := svc.Videos.List([]string{"statistics", "liveStreamingDetails"}).Id(id).Do()
+	if err != nil {
+		return nil, fmt.Errorf("could not list videos: %w", err)
+	}
+	if len(resp.Items) == 0 {
+		return nil, ErrNoBroadcastItems
+	}
+	v := resp.Items[0]
+	var s Stats
+	if d := v.LiveStreamingDetails; d != nil {
+		s.ActualStart, _ = time.Parse(time.RFC3339, d.ActualStartTime)
+		s.ActualEnd, _ = time.Parse(time.RFC3339, d.ActualEndTime)
+		s.Viewers = int64(d.ConcurrentViewers)
+	}
+	if st := v.Statistics; st != nil {
+		s.Views = int64(st.ViewCount)
+		s.Likes = int64(st.LikeCount)
+		s.Comments = int64(st.CommentCount)
+	}
+	return &s, nil
+}
+
 // insertStream corresponds to https://github.com/youtube/api-samples/blob/07263305b59a7c3275bc7e925f9ce6cabf774022/python/create_broadcast.py#L86-L106
 func insertStream(svc *youtube.Service, stream, resolution, typ, framerate string, log func(string, ...interface{}), opts ...googleapi.CallOption) (id string, servResp googleapi.ServerResponse, err error) {
 	log("inserting stream, name: %s, res: %s, typ: %s, rate: %s", stream, resolution, typ, framerate)
@@ -0,0 +1,76 @@
+/*
+DESCRIPTION
+  broadcast_archive.go provides archiving of finished broadcasts, i.e.,
+  writing a BroadcastArchive record of each broadcast's times, health
+  and viewer statistics for use in reports.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// archiveBroadcast writes an archive record of the finished broadcast
+// at t. Times not yet reported by the broadcast service fall back to
+// the scheduled start and t respectively, and viewer statistics are
+// omitted if unavailable, so that a record is always written.
+func archiveBroadcast(ctx context.Context, cfg *BroadcastConfig, store datastore.Store, svc BroadcastService, openFishRegistered bool, t time.Time, log func(string, ...interface{})) error {
+	a := &model.BroadcastArchive{
+		VideoID:            cfg.ID,
+		Skey:               cfg.SKey,
+		Name:               cfg.Name,
+		ActualStart:        cfg.Start,
+		ActualEnd:          t,
+		HealthIncidents:    int64(cfg.HealthIncidents),
+		OpenFishRegistered: openFishRegistered,
+	}
+	if openFishRegistered {
+		a.OpenFishCaptureSource = cfg.OpenFishCaptureSource
+	}
+
+	stats, err := svc.BroadcastStats(ctx, cfg.ID)
+	if err != nil {
+		log("could not get broadcast stats for archive: %v", err)
+	} else {
+		if !stats.ActualStart.IsZero() {
+			a.ActualStart = stats.ActualStart
+		}
+		if !stats.ActualEnd.IsZero() {
+			a.ActualEnd = stats.ActualEnd
+		}
+		a.Views, a.Likes, a.Comments = stats.Views, stats.Likes, stats.Comments
+	}
+	if a.ActualEnd.After(a.ActualStart) {
+		a.LiveMinutes = int64(a.ActualEnd.Sub(a.ActualStart) / time.Minute)
+	}
+
+	err = model.PutBroadcastArchive(ctx, store, a)
+	if err != nil {
+		return fmt.Errorf("could not put broadcast archive: %w", err)
+	}
+	log("archived broadcast %s, live for %d minutes", a.VideoID, a.LiveMinutes)
+	return nil
+}
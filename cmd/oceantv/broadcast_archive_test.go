@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/cmd/oceantv/broadcast"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestArchiveBroadcast(t *testing.T) {
+	ctx := context.Background()
+	model.RegisterEntities()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	start := time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	cfg := &BroadcastConfig{
+		SKey:                  1,
+		Name:                  "Rapid Bay",
+		Start:                 start,
+		HealthIncidents:       2,
+		OpenFishCaptureSource: "42",
+	}
+
+	tests := []struct {
+		desc       string
+		id         string
+		stats      *broadcast.Stats
+		registered bool
+		want       model.BroadcastArchive
+	}{
+		{
+			desc:       "with stats",
+			id:         "abc",
+			stats:      &broadcast.Stats{ActualStart: start.Add(time.Minute), ActualEnd: end, Views: 100, Likes: 5, Comments: 2},
+			registered: true,
+			want: model.BroadcastArchive{
+				VideoID:               "abc",
+				Skey:                  1,
+				Name:                  "Rapid Bay",
+				ActualStart:           start.Add(time.Minute),
+				ActualEnd:             end,
+				LiveMinutes:           89,
+				HealthIncidents:       2,
+				Views:                 100,
+				Likes:                 5,
+				Comments:              2,
+				OpenFishRegistered:    true,
+				OpenFishCaptureSource: "42",
+			},
+		},
+		{
+			desc: "without stats",
+			id:   "def",
+			want: model.BroadcastArchive{
+				VideoID:         "def",
+				Skey:            1,
+				Name:            "Rapid Bay",
+				ActualStart:     start,
+				ActualEnd:       end,
+				LiveMinutes:     90,
+				HealthIncidents: 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg.ID = tt.id
+			err := archiveBroadcast(ctx, cfg, store, newDummyService(WithStats(tt.stats)), tt.registered, end, t.Logf)
+			if err != nil {
+				t.Fatalf("could not archive broadcast: %v", err)
+			}
+			got, err := model.GetBroadcastArchive(ctx, store, tt.id)
+			if err != nil {
+				t.Fatalf("could not get broadcast archive: %v", err)
+			}
+			got.Created = time.Time{}
+			if !got.ActualStart.Equal(tt.want.ActualStart) || !got.ActualEnd.Equal(tt.want.ActualEnd) {
+				t.Errorf("got times %v to %v, want %v to %v", got.ActualStart, got.ActualEnd, tt.want.ActualStart, tt.want.ActualEnd)
+			}
+			got.ActualStart, got.ActualEnd = tt.want.ActualStart, tt.want.ActualEnd
+			if *got != tt.want {
+				t.Errorf("got archive %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
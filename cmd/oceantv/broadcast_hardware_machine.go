@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -35,66 +36,92 @@ func newHardwareStarting(ctx *broadcastContext) *hardwareStarting {
 }
 func (s *hardwareStarting) enter() {
 	s.LastEntered = time.Now()
-	// A MAC of 0 indicates it is invalid or unset, proceed with starting the camera.
-	if s.cfg.ControllerMAC == 0 {
+
+	// The states of all controllers are aggregated. An invalid
+	// configuration takes precedence, then a controller failure, then
+	// low voltage. With no controllers, proceed with starting the camera.
+	var failed, low bool
+	for _, c := range s.cfg.controllers() {
+		switch s.checkController(c) {
+		case controllerInvalid:
+			return
+		case controllerFailed:
+			failed = true
+		case controllerLowVoltage:
+			low = true
+		}
+	}
+
+	switch {
+	case failed:
+		s.bus.publish(controllerFailureEvent{})
+	case low:
+		s.bus.publish(lowVoltageEvent{})
+	default:
+		// All controllers are reporting and above streaming voltage,
+		// let's power on the camera.
 		s.camera.start(s.broadcastContext)
-		return
 	}
+}
 
-	voltage, err := s.camera.voltage(s.broadcastContext)
-	if err != nil {
-		msg := fmt.Sprintf("could not get hardware voltage: %v", err)
+// Controller states, as determined by hardwareStarting.checkController.
+const (
+	controllerOK = iota
+	controllerInvalid
+	controllerFailed
+	controllerLowVoltage
+)
+
+// checkController checks the voltage and status of a controller prior
+// to starting, returning its state. An invalidConfigurationEvent is
+// published for invalid configurations.
+func (s *hardwareStarting) checkController(c Controller) int {
+	mac := model.MacDecode(c.MAC)
+	invalid := func(msg string) int {
 		s.log(msg)
 		s.bus.publish(invalidConfigurationEvent{msg})
-		return
+		return controllerInvalid
 	}
 
-	alarmVoltage, err := s.camera.alarmVoltage(s.broadcastContext)
+	voltage, err := s.camera.voltage(s.broadcastContext, c)
 	if err != nil {
-		msg := fmt.Sprintf("could not get alarm voltage: %v", err)
-		s.log(msg)
-		s.bus.publish(invalidConfigurationEvent{msg})
-		return
+		return invalid(fmt.Sprintf("could not get hardware voltage of controller %s: %v", mac, err))
 	}
 
-	controllerIsOn, err := s.camera.isUp(s.broadcastContext)
+	alarmVoltage, err := s.camera.alarmVoltage(s.broadcastContext, c)
 	if err != nil {
-		msg := fmt.Sprintf("could not get controller status: %v", err)
-		s.log(msg)
-		s.bus.publish(invalidConfigurationEvent{msg})
-		return
+		return invalid(fmt.Sprintf("could not get alarm voltage of controller %s: %v", mac, err))
+	}
+
+	controllerIsOn, err := s.camera.isUp(s.broadcastContext, c)
+	if err != nil {
+		return invalid(fmt.Sprintf("could not get controller %s status: %v", mac, err))
 	}
 
 	if voltage <= alarmVoltage {
 		if controllerIsOn {
-			s.log("voltage less than alarm voltage but controller is on, something is configured incorrectly")
+			s.log("controller %s voltage less than alarm voltage but controller is on, something is configured incorrectly", mac)
 			s.bus.publish(invalidConfigurationEvent{"voltage less than alarm voltage but controller is on"})
-			return
+			return controllerInvalid
 		}
-		s.log("controller voltage is low, waiting for recovery before starting")
-		s.bus.publish(lowVoltageEvent{})
-		return
+		s.log("controller %s voltage is low, waiting for recovery before starting", mac)
+		return controllerLowVoltage
 	}
 
 	// Not below alarm voltage, but controller is not responding.
 	// This is a critical failure.
 	if !controllerIsOn {
-		s.log("controller not responding above alarm voltage")
-		s.bus.publish(controllerFailureEvent{})
-		return
+		s.log("controller %s not responding above alarm voltage", mac)
+		return controllerFailed
 	}
 
 	// Controller is reporting, but we're not above streaming voltage. Need
 	// to wait for recovery.
 	if voltage < s.cfg.RequiredStreamingVoltage {
-		s.log("controller voltage is below required streaming voltage, waiting for recovery before starting")
-		s.bus.publish(lowVoltageEvent{})
-		return
+		s.log("controller %s voltage is below required streaming voltage, waiting for recovery before starting", mac)
+		return controllerLowVoltage
 	}
-
-	// Controller is reporting and we're above streaming voltage, let's power
-	// on the camera.
-	s.camera.start(s.broadcastContext)
+	return controllerOK
 }
 
 func (s *hardwareStarting) exit() {}
@@ -113,6 +140,7 @@ func (s *hardwareStarting) reset(time.Duration) {}
 type hardwareRecoveringVoltage struct {
 	stateFields
 	stateWithTimeoutFields
+	Progress recoveryProgress
 }
 
 func newHardwareRecoveringVoltage(ctx *broadcastContext) *hardwareRecoveringVoltage {
@@ -125,6 +153,9 @@ func newHardwareRecoveringVoltage(ctx *broadcastContext) *hardwareRecoveringVolt
 
 func (s *hardwareRecoveringVoltage) enter() {
 	s.LastEntered = time.Now()
+	s.Timeout = voltageRecoveryTimeout(s.broadcastContext, s.LastEntered)
+	s.Progress = recoveryProgress{Deadline: s.LastEntered.Add(s.Timeout)}
+	s.Progress.Strategy, _ = getRecoveryStrategy(s.broadcastContext)
 }
 
 func sanatisedVoltageRecoveryTimeout(ctx *broadcastContext) int {
@@ -252,15 +283,15 @@ func (sm *hardwareStateMachine) handleTimeEvent(t timeEvent) {
 	case *hardwareRestarting:
 		eventIfStatus(hardwareStartRequestEvent{}, false)
 	case *hardwareRecoveringVoltage:
-		withTimeout := sm.currentState.(stateWithTimeout)
-		if withTimeout.timedOut(t.Time) {
-			sm.ctx.logAndNotify(broadcastHardware, "voltage recovery timed out")
+		s := sm.currentState.(*hardwareRecoveringVoltage)
+		if s.timedOut(t.Time) {
+			sm.ctx.logAndNotify(broadcastHardware, "voltage recovery timed out; %s", &s.Progress)
 			sm.ctx.bus.publish(hardwareStartFailedEvent{})
 			sm.transition(newHardwareOff())
 			return
 		}
 
-		voltage, err := sm.ctx.camera.voltage(sm.ctx)
+		voltage, err := lowestVoltage(sm.ctx)
 		if err != nil {
 			msg := fmt.Sprintf("could not get hardware voltage: %v", err)
 			sm.log(msg)
@@ -268,9 +299,8 @@ func (sm *hardwareStateMachine) handleTimeEvent(t timeEvent) {
 			return
 		}
 
-		// If RequiredStreamingVoltage is not set, default to 24.5.
+		// If RequiredStreamingVoltage is not set, use the default.
 		if sm.ctx.cfg.RequiredStreamingVoltage == 0 {
-			const defaultRequiredStreamingVoltage = 24.5
 			sm.log("required streaming voltage is not set, defaulting to %f", defaultRequiredStreamingVoltage)
 			try(
 				sm.ctx.man.Save(nil, func(_cfg *Cfg) { _cfg.RequiredStreamingVoltage = defaultRequiredStreamingVoltage }),
@@ -281,7 +311,19 @@ func (sm *hardwareStateMachine) handleTimeEvent(t timeEvent) {
 
 		if voltage >= sm.ctx.cfg.RequiredStreamingVoltage {
 			sm.ctx.bus.publish(voltageRecoveredEvent{})
+			return
 		}
+
+		_, strategy := getRecoveryStrategy(sm.ctx)
+		s.Progress.Required = sm.ctx.cfg.RequiredStreamingVoltage
+		err = strategy.update(&s.Progress, t.Time, voltage)
+		if err != nil {
+			sm.ctx.logAndNotify(broadcastHardware, "abandoned voltage recovery: %v; %s", err, &s.Progress)
+			sm.ctx.bus.publish(hardwareStartFailedEvent{})
+			sm.transition(newHardwareOff())
+			return
+		}
+		sm.log("recovering voltage: %s", &s.Progress)
 	default:
 		// Do nothing.
 	}
@@ -438,10 +480,26 @@ func (sm *hardwareStateMachine) log(format string, args ...interface{}) {
 	sm.ctx.log("(hardware sm) "+format, args...)
 }
 
+// lowestVoltage returns the lowest voltage of the broadcast's
+// controllers, since the hardware can only stream once all controllers
+// have recovered.
+func lowestVoltage(ctx *broadcastContext) (float64, error) {
+	lowest := math.Inf(1)
+	for _, c := range ctx.cfg.controllers() {
+		v, err := ctx.camera.voltage(ctx, c)
+		if err != nil {
+			return 0, fmt.Errorf("controller %s: %w", model.MacDecode(c.MAC), err)
+		}
+		lowest = math.Min(lowest, v)
+	}
+	return lowest, nil
+}
+
 type hardwareManager interface {
-	voltage(ctx *broadcastContext) (float64, error)
-	alarmVoltage(ctx *broadcastContext) (float64, error)
-	isUp(ctx *broadcastContext) (bool, error)
+	voltage(ctx *broadcastContext, c Controller) (float64, error)
+	alarmVoltage(ctx *broadcastContext, c Controller) (float64, error)
+	isUp(ctx *broadcastContext, c Controller) (bool, error)
+	lastReported(ctx *broadcastContext) (time.Time, error)
 	start(ctx *broadcastContext)
 	stop(ctx *broadcastContext)
 	publishEventIfStatus(event event, status bool, mac int64, store Store, log func(format string, args ...interface{}), publish func(event event))
@@ -449,10 +507,9 @@ type hardwareManager interface {
 
 type revidCameraClient struct{}
 
-func (c *revidCameraClient) voltage(ctx *broadcastContext) (float64, error) {
+func (c *revidCameraClient) voltage(ctx *broadcastContext, controller Controller) (float64, error) {
 	// Get battery voltage sensor, which we'll use to get scale factor and current voltage value.
-	const batteryVoltagePin = "A0"
-	sensor, err := model.GetSensorV2(context.Background(), ctx.store, ctx.cfg.ControllerMAC, batteryVoltagePin)
+	sensor, err := model.GetSensorV2(context.Background(), ctx.store, controller.MAC, controller.voltagePin())
 	if err != nil {
 		return 0, fmt.Errorf("could not get battery voltage sensor: %v", err)
 	}
@@ -465,10 +522,10 @@ func (c *revidCameraClient) voltage(ctx *broadcastContext) (float64, error) {
 	return voltage, nil
 }
 
-func (c *revidCameraClient) alarmVoltage(ctx *broadcastContext) (float64, error) {
+func (c *revidCameraClient) alarmVoltage(ctx *broadcastContext, controller Controller) (float64, error) {
 	// Get AlarmVoltage variable; if the voltage is above this we expect the controller to be on.
 	// If the voltage is below this, we expect the controller to be off.
-	controllerMACHex := (&model.Device{Mac: ctx.cfg.ControllerMAC}).Hex()
+	controllerMACHex := (&model.Device{Mac: controller.MAC}).Hex()
 	alarmVoltageVar, err := model.GetVariable(context.Background(), ctx.store, ctx.cfg.SKey, controllerMACHex+".AlarmVoltage")
 	if err != nil {
 		return 0, fmt.Errorf("could not get alarm voltage variable: %v", err)
@@ -481,8 +538,7 @@ func (c *revidCameraClient) alarmVoltage(ctx *broadcastContext) (float64, error)
 	}
 
 	// Get battery voltage sensor, which we'll use to get scale factor and current voltage value.
-	const batteryVoltagePin = "A0"
-	sensor, err := model.GetSensorV2(context.Background(), ctx.store, ctx.cfg.ControllerMAC, batteryVoltagePin)
+	sensor, err := model.GetSensorV2(context.Background(), ctx.store, controller.MAC, controller.voltagePin())
 	if err != nil {
 		return 0, fmt.Errorf("could not get battery voltage sensor: %v", err)
 	}
@@ -496,14 +552,28 @@ func (c *revidCameraClient) alarmVoltage(ctx *broadcastContext) (float64, error)
 	return alarmVoltage, nil
 }
 
-func (c *revidCameraClient) isUp(ctx *broadcastContext) (bool, error) {
-	controllerIsOn, err := model.DeviceIsUp(context.Background(), ctx.store, model.MacDecode(ctx.cfg.ControllerMAC))
+func (c *revidCameraClient) isUp(ctx *broadcastContext, controller Controller) (bool, error) {
+	controllerIsOn, err := model.DeviceIsUp(context.Background(), ctx.store, model.MacDecode(controller.MAC))
 	if err != nil {
 		return false, fmt.Errorf("could not get controller status: %v", err)
 	}
 	return controllerIsOn, nil
 }
 
+// lastReported returns the time that the camera last reported, i.e.,
+// the time its uptime variable was last updated.
+func (c *revidCameraClient) lastReported(ctx *broadcastContext) (time.Time, error) {
+	dev, err := model.GetDevice(context.Background(), ctx.store, ctx.cfg.CameraMac)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not get camera: %w", err)
+	}
+	v, err := model.GetVariable(context.Background(), ctx.store, dev.Skey, "_"+dev.Hex()+".uptime")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not get camera uptime variable: %w", err)
+	}
+	return v.Updated, nil
+}
+
 func (c *revidCameraClient) start(ctx *broadcastContext) {
 	err := extStart(context.Background(), ctx.cfg, ctx.log)
 	if err != nil {
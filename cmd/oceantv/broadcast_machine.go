@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/cloud/notify"
 )
 
@@ -15,27 +16,11 @@ type broadcastStateMachine struct {
 }
 
 func getBroadcastStateMachine(ctx *broadcastContext) (*broadcastStateMachine, error) {
-	// First make sure the times of the config are set to today's year and day date
-	// but we want to preserve the hour and min etc.
-	loc, err := time.LoadLocation(locationID)
-	if err != nil {
-		return nil, fmt.Errorf("could not load location: %w", err)
-	}
-
-	// Get local times.
-	nowInLoc := time.Now().In(loc)
-	startInLoc := ctx.cfg.Start.In(loc)
-	endInLoc := ctx.cfg.End.In(loc)
-
-	// Do the day adjustment in local time because it's easier
-	ctx.cfg.Start = time.Date(nowInLoc.Year(), nowInLoc.Month(), nowInLoc.Day(), startInLoc.Hour(), startInLoc.Minute(), startInLoc.Second(), startInLoc.Nanosecond(), startInLoc.Location())
-	ctx.cfg.End = time.Date(nowInLoc.Year(), nowInLoc.Month(), nowInLoc.Day(), endInLoc.Hour(), endInLoc.Minute(), endInLoc.Second(), endInLoc.Nanosecond(), endInLoc.Location())
-
-	// Store in UTC
-	ctx.cfg.Start = ctx.cfg.Start.In(time.UTC)
-	ctx.cfg.End = ctx.cfg.End.In(time.UTC)
+	// Move the start and end times of the config to the window in effect
+	// today, preserving their site-local times of day.
+	ctx.cfg.Start, ctx.cfg.End = broadcastWindow(time.Now(), ctx.cfg.Start, ctx.cfg.End, broadcastLocation(ctx.store, ctx.cfg, ctx.log))
 
-	err = ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.Start = ctx.cfg.Start; _cfg.End = ctx.cfg.End })
+	err := ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.Start = ctx.cfg.Start; _cfg.End = ctx.cfg.End })
 	if err != nil {
 		return nil, fmt.Errorf("could not update config start and end times in transaction: %w", err)
 	}
@@ -45,6 +30,52 @@ func getBroadcastStateMachine(ctx *broadcastContext) (*broadcastStateMachine, er
 	return sm, nil
 }
 
+// broadcastLocation returns the timezone of the broadcast's site, or
+// the default timezone if the site cannot be got.
+func broadcastLocation(store Store, cfg *BroadcastConfig, log func(string, ...interface{})) *time.Location {
+	site := &model.Site{Skey: cfg.SKey}
+	if store != nil {
+		s, err := model.GetSite(context.Background(), store, cfg.SKey)
+		if err != nil {
+			log("could not get site, using default timezone: %v", err)
+		} else {
+			site = s
+		}
+	}
+	return model.SiteNow(site).Location()
+}
+
+// broadcastWindow returns the start and end of the broadcast window in
+// effect at now, in UTC, given the start and end of any previous
+// window. The windows are defined by the civil (wall clock) times of
+// start and end in loc, which are applied to the local date of now, so
+// windows keep their local times of day across daylight saving
+// changes. A window whose end time of day is before its start time of
+// day finishes on the following day, and yesterday's window takes
+// precedence while it has yet to finish. Local times skipped by a
+// daylight saving change are normalized forward, as per time.Date.
+func broadcastWindow(now, start, end time.Time, loc *time.Location) (time.Time, time.Time) {
+	now, start, end = now.In(loc), start.In(loc), end.In(loc)
+	y, m, d := now.Date()
+	on := func(day int, t time.Time) time.Time {
+		return time.Date(y, m, d+day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+
+	if timeOfDay(end) >= timeOfDay(start) {
+		return on(0, start).UTC(), on(0, end).UTC()
+	}
+	if now.Before(on(0, end)) {
+		return on(-1, start).UTC(), on(0, end).UTC()
+	}
+	return on(0, start).UTC(), on(1, end).UTC()
+}
+
+// timeOfDay returns the duration since midnight of the wall clock time
+// of t.
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
 func (sm *broadcastStateMachine) handleEvent(event event) error {
 	switch event.(type) {
 	case timeEvent:
@@ -85,6 +116,10 @@ func (sm *broadcastStateMachine) handleEvent(event event) error {
 		sm.handleLowVoltageEvent(event.(lowVoltageEvent))
 	case voltageRecoveredEvent:
 		sm.handleVoltageRecoveredEvent(event.(voltageRecoveredEvent))
+	case hardwareStartRequestEvent, hardwareStopRequestEvent, hardwareResetRequestEvent, hardwareStopFailedEvent, slateResetRequested:
+		// Handled by the hardware state machine or the broadcast manager.
+	default:
+		sm.log("warning: dropping event %s, which has no handler", event.String())
 	}
 
 	// After handling of the event, we may have some changes in substates of the current state.
@@ -101,9 +136,27 @@ func (sm *broadcastStateMachine) handleLowVoltageEvent(event lowVoltageEvent) er
 		// mechanism, which is handled by the hardware SM but a rather a contingency that
 		// we shouldn't hit with normal behaviour).
 		const broadcastVoltageRecoveryOffset = 10 * time.Minute
-		sm.currentState.(stateWithTimeout).reset(time.Duration(sanatisedVoltageRecoveryTimeout(sm.ctx))*time.Hour + broadcastVoltageRecoveryOffset)
+		sm.currentState.(stateWithTimeout).reset(voltageRecoveryTimeout(sm.ctx, time.Now()) + broadcastVoltageRecoveryOffset)
 	case *vidforwardPermanentTransitionSlateToLive:
 		sm.transition(newVidforwardPermanentVoltageRecoverySlate(sm.ctx))
+	case
+		*vidforwardPermanentLive,
+		*vidforwardPermanentLiveUnhealthy,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; voltage is managed by the hardware state machine.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -117,6 +170,24 @@ func (sm *broadcastStateMachine) handleVoltageRecoveredEvent(event voltageRecove
 		sm.currentState.(stateWithTimeout).reset(5 * time.Minute)
 	case *vidforwardPermanentVoltageRecoverySlate:
 		sm.transition(newVidforwardPermanentTransitionSlateToLive(sm.ctx))
+	case
+		*vidforwardPermanentLive,
+		*vidforwardPermanentLiveUnhealthy,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; only starting and voltage recovery states wait for voltage.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -176,6 +247,7 @@ func (sm *broadcastStateMachine) handleInvalidConfigurationEvent(event invalidCo
 		*vidforwardPermanentSlateUnhealthy,
 		*vidforwardPermanentTransitionLiveToSlate,
 		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentVoltageRecoverySlate,
 		*vidforwardPermanentFailure:
 
 		sm.transition(newVidforwardPermanentIdle(sm.ctx))
@@ -190,10 +262,18 @@ func (sm *broadcastStateMachine) handleInvalidConfigurationEvent(event invalidCo
 	case
 		*directStarting,
 		*directLive,
-		*directLiveUnhealthy:
+		*directLiveUnhealthy,
+		*directFailure:
 
 		sm.transition(newDirectIdle(sm.ctx))
 
+	case *draining:
+		// Power off now rather than after the delay.
+		sm.transition(finishedState(sm.ctx))
+
+	case *vidforwardPermanentIdle, *vidforwardSecondaryIdle, *directIdle:
+		// Ignore; already idle.
+
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -208,6 +288,27 @@ func (sm *broadcastStateMachine) handleStartFailedEvent(event startFailedEvent)
 		sm.transition(newVidforwardSecondaryIdle(sm.ctx))
 	case *directStarting:
 		sm.transition(newDirectIdle(sm.ctx))
+	case *vidforwardPermanentTransitionSlateToLive:
+		// Return to the slate, from which the start is retried while due.
+		sm.transition(newVidforwardPermanentSlate())
+	case
+		*vidforwardPermanentLive,
+		*vidforwardPermanentLiveUnhealthy,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; a late failure of a start that has since been abandoned or superseded.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -227,6 +328,26 @@ func (sm *broadcastStateMachine) handleCriticalFailureEvent(event criticalFailur
 		sm.transition(newVidforwardSecondaryIdle(sm.ctx))
 	case *directStarting:
 		sm.transition(newDirectFailure(sm.ctx))
+	case *vidforwardPermanentTransitionSlateToLive:
+		sm.transition(newVidforwardPermanentFailure(sm.ctx))
+	case
+		*vidforwardPermanentLive,
+		*vidforwardPermanentLiveUnhealthy,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; a late failure of a start that has since been abandoned or superseded.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -244,6 +365,23 @@ func (sm *broadcastStateMachine) handleHardwareStartFailedEvent(event hardwareSt
 	case *vidforwardPermanentTransitionSlateToLive:
 		sm.logAndNotify(broadcastHardware, "hardware failure event in transition from slate to live, moving to failure slate state")
 		sm.transition(newVidforwardPermanentFailure(sm.ctx))
+	case
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; hardware is not expected to be starting, or its failure is
+		// detected by health checks.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -252,6 +390,18 @@ func (sm *broadcastStateMachine) handleHardwareStartFailedEvent(event hardwareSt
 
 func (sm *broadcastStateMachine) handleBadHealthEvent(event badHealthEvent) error {
 	sm.log("handling bad health event")
+
+	// Count the transition from healthy to unhealthy as an incident
+	// for the broadcast's archive.
+	switch sm.currentState.(type) {
+	case *vidforwardPermanentLive, *vidforwardPermanentSlate, *vidforwardSecondaryLive, *directLive:
+		try(
+			sm.ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.HealthIncidents++ }),
+			"could not save health incidents",
+			sm.logAndNotifySoftware,
+		)
+	}
+
 	switch sm.currentState.(type) {
 	case *vidforwardPermanentLive:
 		sm.transition(newVidforwardPermanentLiveUnhealthy(sm.ctx))
@@ -265,6 +415,19 @@ func (sm *broadcastStateMachine) handleBadHealthEvent(event badHealthEvent) erro
 		sm.logAndNotify(broadcastNetwork, "getting bad health event in permanent failure state")
 	case *vidforwardPermanentLiveUnhealthy, *vidforwardPermanentSlateUnhealthy, *vidforwardSecondaryLiveUnhealthy, *directLiveUnhealthy:
 		// Do nothing.
+	case
+		*vidforwardPermanentStarting,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryStarting,
+		*vidforwardSecondaryIdle,
+		*directStarting,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; transitions and starts are resolved by their timeouts.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -325,12 +488,16 @@ func (sm *broadcastStateMachine) handleTimeEvent(event timeEvent) {
 			return
 		}
 		sm.publishHealthStatusOrChatEvents(event)
+		sm.refreshMetadataIfDue(event.Time)
+		sm.sampleViewersIfDue(event.Time)
 	case *vidforwardPermanentLiveUnhealthy, *vidforwardSecondaryLiveUnhealthy, *directLiveUnhealthy:
 		if sm.finishIsDue(event) {
 			sm.ctx.bus.publish(finishEvent{})
 			return
 		}
 		sm.publishHealthStatusOrChatEvents(event)
+		sm.refreshMetadataIfDue(event.Time)
+		sm.sampleViewersIfDue(event.Time)
 		sm.tryToFixCurrentState()
 
 	case *vidforwardPermanentSlateUnhealthy:
@@ -340,11 +507,20 @@ func (sm *broadcastStateMachine) handleTimeEvent(event timeEvent) {
 		}
 		sm.tryToFixCurrentState()
 
-	case *vidforwardSecondaryIdle, *vidforwardPermanentIdle, *vidforwardPermanentSlate, *directIdle:
+	case *vidforwardSecondaryIdle, *vidforwardPermanentIdle, *directIdle:
 		if sm.startIsDue(event) {
 			sm.ctx.bus.publish(startEvent{})
 			return
 		}
+		sm.checkReadinessIfDue(event)
+	case *vidforwardPermanentSlate:
+		if sm.startIsDue(event) {
+			sm.ctx.bus.publish(startEvent{})
+			return
+		}
+		sm.checkReadinessIfDue(event)
+		sm.refreshMetadataIfDue(event.Time)
+		sm.selectScheduledSlate(event.Time)
 	case *vidforwardPermanentTransitionLiveToSlate:
 		withTimeout := sm.currentState.(stateWithTimeout)
 		if withTimeout.timedOut(event.Time) {
@@ -376,18 +552,76 @@ func (sm *broadcastStateMachine) handleTimeEvent(event timeEvent) {
 		if withTimeout.timedOut(event.Time) {
 			sm.transition(newVidforwardPermanentFailure(sm.ctx))
 		}
+	case *draining:
+		// Keep the hardware on until the power off delay has elapsed.
+		withTimeout := sm.currentState.(stateWithTimeout)
+		if withTimeout.timedOut(event.Time) {
+			sm.log("power off delay elapsed, finishing")
+			sm.transition(finishedState(sm.ctx))
+		}
+	case *vidforwardPermanentFailure, *directFailure:
+		// Ignore; failure states await intervention, e.g., re-enabling the broadcast.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
 }
 
+// selectScheduledSlate requests the slate scheduled for time t from the
+// forwarding service, unless it is already displayed. Broadcasts which
+// generate slates do so when no slate is scheduled. An invalid slate
+// schedule is notified once, rather than every time it is checked.
+func (sm *broadcastStateMachine) selectScheduledSlate(t time.Time) {
+	s := sm.currentState.(*vidforwardPermanentSlate)
+	slate, err := scheduledSlate(sm.ctx.cfg, t, broadcastLocation(sm.ctx.store, sm.ctx.cfg, sm.log))
+	if err != nil {
+		if err.Error() != s.ScheduleErr {
+			sm.logAndNotify(broadcastConfiguration, "invalid slate schedule: %v", err)
+			s.ScheduleErr = err.Error()
+		}
+		return
+	}
+	s.ScheduleErr = ""
+	if slate == "" && sm.ctx.cfg.GenerateSlate {
+		sm.generateSlateIfChanged(t)
+		return
+	}
+	if slate == s.Slate {
+		return
+	}
+	if try(sm.ctx.fwd.Slate(sm.ctx.cfg, WithSlate(slate)), "could not set scheduled slate", sm.log) {
+		sm.log("selected scheduled slate %q", slate)
+		s.Slate = slate
+	}
+}
+
 func (sm *broadcastStateMachine) handleFixFailureEvent(event fixFailureEvent) error {
 	sm.log("handling fix failure event")
 	switch sm.currentState.(type) {
 	case *vidforwardPermanentLiveUnhealthy:
 		sm.transition(newVidforwardPermanentFailure(sm.ctx))
+	case
+		*vidforwardPermanentStarting,
+		*vidforwardPermanentLive,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryStarting,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directStarting,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; only an unhealthy permanent broadcast fails to be fixed.
 	default:
-		sm.log("unhandled event %s in current state %s", event.String(), stateToString(sm.currentState))
+		sm.unexpectedEvent(event, sm.currentState)
 	}
 	return nil
 }
@@ -423,22 +657,57 @@ func (sm *broadcastStateMachine) startIsDue(event timeEvent) bool {
 }
 
 func (sm *broadcastStateMachine) publishHealthStatusOrChatEvents(event timeEvent) {
-	const (
-		statusInterval = 1 * time.Minute
-		chatInterval   = 30 * time.Minute
-	)
+	const chatInterval = 30 * time.Minute
 	sm.publishHealthEvent(event)
 	now := event.Time
-	if liveState, ok := sm.currentState.(liveState); ok && now.Sub(liveState.lastStatusCheck()) > statusInterval {
-		liveState.setLastStatusCheck(now)
-		sm.ctx.bus.publish(statusCheckDueEvent{})
-	}
+	sm.publishStatusEvent(now)
 	if liveState, ok := sm.currentState.(liveState); ok && now.Sub(liveState.lastChatMsg()) > chatInterval {
 		liveState.setLastChatMsg(now)
 		sm.ctx.bus.publish(chatMessageDueEvent{})
 	}
 }
 
+// publishStatusEvent publishes a status check event if one is due.
+// Status checks consume API quota, so the interval between checks
+// adapts to the health of the stream; it starts at the broadcast's
+// minimum interval and doubles after each check while the stream is
+// healthy, up to the maximum interval, and reverts to the minimum
+// while the stream is unhealthy.
+func (sm *broadcastStateMachine) publishStatusEvent(now time.Time) {
+	live, ok := sm.currentState.(liveState)
+	if !ok {
+		return
+	}
+	lo, hi := sm.ctx.cfg.statusIntervalBounds()
+	healthy := !isUnhealthy(sm.currentState)
+	interval := live.statusInterval()
+	if !healthy || interval < lo {
+		interval = lo
+	}
+	if interval > hi {
+		interval = hi
+	}
+	live.setStatusInterval(interval)
+	if now.Sub(live.lastStatusCheck()) <= interval {
+		return
+	}
+	live.setLastStatusCheck(now)
+	sm.ctx.bus.publish(statusCheckDueEvent{})
+	if healthy {
+		live.setStatusInterval(min(2*interval, hi))
+	}
+}
+
+// isUnhealthy returns true if s is an unhealthy live state.
+func isUnhealthy(s state) bool {
+	switch s.(type) {
+	case *vidforwardPermanentLiveUnhealthy, *vidforwardSecondaryLiveUnhealthy, *directLiveUnhealthy:
+		return true
+	default:
+		return false
+	}
+}
+
 func (sm *broadcastStateMachine) publishHealthEvent(event timeEvent) {
 	const healthInterval = 1 * time.Minute
 	now := event.Time
@@ -452,11 +721,40 @@ func (sm *broadcastStateMachine) handleFinishEvent(event finishEvent) error {
 	sm.log("handling finish event")
 	switch sm.currentState.(type) {
 	case *vidforwardPermanentLive, *vidforwardPermanentLiveUnhealthy:
+		if sm.ctx.cfg.PowerOffDelay > 0 {
+			sm.transition(newDraining(sm.ctx))
+			return nil
+		}
 		sm.transition(newVidforwardPermanentTransitionLiveToSlate(sm.ctx))
 	case *vidforwardSecondaryLive, *vidforwardSecondaryLiveUnhealthy:
+		if sm.ctx.cfg.PowerOffDelay > 0 {
+			sm.transition(newDraining(sm.ctx))
+			return nil
+		}
 		sm.transition(newVidforwardSecondaryIdle(sm.ctx))
 	case *directLive, *directLiveUnhealthy:
+		if sm.ctx.cfg.PowerOffDelay > 0 {
+			sm.transition(newDraining(sm.ctx))
+			return nil
+		}
 		sm.transition(newDirectIdle(sm.ctx))
+	case
+		*vidforwardPermanentStarting,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryStarting,
+		*vidforwardSecondaryIdle,
+		*directStarting,
+		*directFailure,
+		*directIdle:
+		// Ignore; not live, so there is nothing to finish.
+	case *draining:
+		// Ignore; already finishing.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -468,12 +766,30 @@ func (sm *broadcastStateMachine) handleStartEvent(event startEvent) error {
 	switch sm.currentState.(type) {
 	case *vidforwardPermanentIdle:
 		sm.transition(newVidforwardPermanentStarting(sm.ctx))
-	case *vidforwardPermanentSlate:
+	case *vidforwardPermanentSlate, *vidforwardPermanentSlateUnhealthy:
 		sm.transition(newVidforwardPermanentTransitionSlateToLive(sm.ctx))
 	case *vidforwardSecondaryIdle:
 		sm.transition(newVidforwardSecondaryStarting(sm.ctx))
 	case *directIdle:
 		sm.transition(newDirectStarting(sm.ctx))
+	case
+		*vidforwardPermanentStarting,
+		*vidforwardPermanentLive,
+		*vidforwardPermanentLiveUnhealthy,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardSecondaryStarting,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*directStarting,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure:
+		// Ignore; already starting, live, or awaiting recovery.
+	case *draining:
+		// Ignore; the broadcast is started again, if due, once it has finished.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -507,6 +823,25 @@ func (sm *broadcastStateMachine) handleStartedEvent(event startedEvent) error {
 		sm.transition(newVidforwardSecondaryLive(sm.ctx))
 	case *directStarting:
 		sm.transition(&directLive{})
+	case
+		*vidforwardPermanentLive,
+		*vidforwardPermanentLiveUnhealthy,
+		*vidforwardPermanentTransitionLiveToSlate,
+		*vidforwardPermanentTransitionSlateToLive,
+		*vidforwardPermanentSlate,
+		*vidforwardPermanentSlateUnhealthy,
+		*vidforwardPermanentVoltageRecoverySlate,
+		*vidforwardPermanentFailure,
+		*vidforwardPermanentIdle,
+		*vidforwardSecondaryLive,
+		*vidforwardSecondaryLiveUnhealthy,
+		*vidforwardSecondaryIdle,
+		*directLive,
+		*directLiveUnhealthy,
+		*directFailure,
+		*directIdle,
+		*draining:
+		// Ignore; a late start that has since been abandoned or superseded.
 	default:
 		sm.unexpectedEvent(event, sm.currentState)
 	}
@@ -527,8 +862,12 @@ func (sm *broadcastStateMachine) transition(newState state) {
 	sm.currentState.enter()
 }
 
+// unexpectedEvent warns that an event has been dropped because its
+// handler does not consider the current state. Events that are
+// deliberately ignored in a state should be listed in a case of their
+// handler instead; see TestBroadcastTransitions.
 func (sm *broadcastStateMachine) unexpectedEvent(event event, state state) {
-	sm.log("unexpected event %s in current state %s", event.String(), stateToString(state))
+	sm.log("warning: dropping unhandled event %s in current state %s", event.String(), stateToString(state))
 }
 
 func (sm *broadcastStateMachine) log(msg string, args ...interface{}) {
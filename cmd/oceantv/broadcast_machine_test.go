@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"context"
+	"reflect"
 
 	"bou.ke/monkey"
 	"github.com/ausocean/cloud/notify"
@@ -57,6 +58,34 @@ func TestHandleTimeEvent(t *testing.T) {
 				End:   now.Add(1 * time.Hour),
 			},
 		},
+		{
+			desc:           "directLive with time after End and power off delay",
+			initialState:   newDirectLive(bCtx),
+			event:          timeEvent{now.Add(2 * time.Hour)},
+			expectedEvents: []event{timeEvent{}, finishEvent{}},
+			expectedState:  &draining{},
+			cfg: &BroadcastConfig{
+				Start:         now,
+				End:           now.Add(1 * time.Hour),
+				PowerOffDelay: 5,
+			},
+		},
+		{
+			desc:           "draining timed out",
+			initialState:   &draining{stateWithTimeoutFields: newStateWithTimeoutFieldsWithLastEntered(bCtx, now)},
+			event:          timeEvent{now.Add(6 * time.Minute)},
+			expectedEvents: []event{timeEvent{}, hardwareStopRequestEvent{}},
+			expectedState:  newDirectIdle(bCtx),
+			cfg:            &BroadcastConfig{PowerOffDelay: 5},
+		},
+		{
+			desc:           "draining not timed out",
+			initialState:   &draining{stateWithTimeoutFields: newStateWithTimeoutFieldsWithLastEntered(bCtx, now)},
+			event:          timeEvent{now.Add(4 * time.Minute)},
+			expectedEvents: []event{timeEvent{}},
+			expectedState:  &draining{},
+			cfg:            &BroadcastConfig{PowerOffDelay: 5},
+		},
 		{
 			desc:           "vidforwardPermanentLiveUnhealthy with time after End",
 			initialState:   newVidforwardPermanentLiveUnhealthy(bCtx),
@@ -644,6 +673,24 @@ func TestHandleStartFailedEvent(t *testing.T) {
 				End:   now.Add(3 * time.Hour),
 			},
 		},
+		{
+			desc:          "vidforwardPermanentTransitionSlateToLive",
+			initialState:  newVidforwardPermanentTransitionSlateToLive(bCtx),
+			expectedState: newVidforwardPermanentSlate(),
+			cfg: &BroadcastConfig{
+				Start: now,
+				End:   now.Add(1 * time.Hour),
+			},
+		},
+		{
+			desc:          "vidforwardPermanentLive ignores late failure",
+			initialState:  newVidforwardPermanentLive(),
+			expectedState: newVidforwardPermanentLive(),
+			cfg: &BroadcastConfig{
+				Start: now,
+				End:   now.Add(1 * time.Hour),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -977,6 +1024,36 @@ func TestHandleFinishEvent(t *testing.T) {
 				End:   now.Add(6 * time.Hour),
 			},
 		},
+		{
+			desc:          "vidforwardPermanentLive with power off delay transitions to draining",
+			initialState:  newVidforwardPermanentLive(),
+			expectedState: &draining{},
+			cfg: &BroadcastConfig{
+				Start:         now,
+				End:           now.Add(1 * time.Hour),
+				PowerOffDelay: 10,
+			},
+		},
+		{
+			desc:          "vidforwardSecondaryLive with power off delay transitions to draining",
+			initialState:  newVidforwardSecondaryLive(bCtx),
+			expectedState: &draining{},
+			cfg: &BroadcastConfig{
+				Start:         now,
+				End:           now.Add(1 * time.Hour),
+				PowerOffDelay: 10,
+			},
+		},
+		{
+			desc:          "draining ignores finish",
+			initialState:  &draining{stateWithTimeoutFields: newStateWithTimeoutFieldsWithLastEntered(bCtx, now)},
+			expectedState: &draining{},
+			cfg: &BroadcastConfig{
+				Start:         now,
+				End:           now.Add(1 * time.Hour),
+				PowerOffDelay: 10,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1037,6 +1114,15 @@ func TestHandleStartEvent(t *testing.T) {
 				End:   now.Add(2 * time.Hour),
 			},
 		},
+		{
+			desc:          "vidforwardPermanentSlateUnhealthy transitions to vidforwardPermanentTransitionSlateToLive",
+			initialState:  newVidforwardPermanentSlateUnhealthy(bCtx),
+			expectedState: newVidforwardPermanentTransitionSlateToLive(bCtx),
+			cfg: &BroadcastConfig{
+				Start: now.Add(1 * time.Hour),
+				End:   now.Add(2 * time.Hour),
+			},
+		},
 		{
 			desc:          "vidforwardSecondaryIdle transitions to vidforwardSecondaryStarting",
 			initialState:  newVidforwardSecondaryIdle(bCtx),
@@ -1536,6 +1622,31 @@ func TestHardwareVoltageAndFaultHandling(t *testing.T) {
 			expectedNotify: map[int64]map[notify.Kind][]string{},
 		},
 
+		// Tests that low voltage on any of multiple controllers, e.g., one
+		// powering lights, prevents starting.
+		{
+			desc: "direct broadcast; multiple controllers, one with low voltage",
+			cfg: func(c *BroadcastConfig) {
+				c.Enabled = true
+				c.SKey = testSiteKey
+				c.Start = time.Now().Add(-1 * time.Hour)
+				c.End = time.Now().Add(1 * time.Hour)
+				c.HardwareState = "hardwareOff"
+				c.ControllerMAC = 1
+				c.Controllers = []Controller{{MAC: 2}}
+			},
+			initialBroadcastState: &directIdle{},
+			finalBroadcastState:   &directStarting{},
+			finalHardwareState:    &hardwareRecoveringVoltage{},
+			hardwareMan:           newDummyHardwareManager(withControllerVoltage(2, 24.0)),
+			newBroadcastMan: func(t *testing.T, c *BroadcastConfig) BroadcastManager {
+				return newDummyManager(t, c)
+			},
+			expectedEvents: []event{timeEvent{}, startEvent{}, hardwareStartRequestEvent{}, lowVoltageEvent{}},
+			expectedLogs:   []string{},
+			expectedNotify: map[int64]map[notify.Kind][]string{},
+		},
+
 		// Tests that we can recover from the voltage recovery state.
 		{
 			desc: "direct broadcast; successful voltage recovery",
@@ -1843,3 +1954,153 @@ func TestHardwareVoltageAndFaultHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusCheckBackoff(t *testing.T) {
+	var checks int
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := newBasicEventBus(ctx, nil, func(string, ...interface{}) {})
+	bus.subscribe(func(e event) error {
+		if _, ok := e.(statusCheckDueEvent); ok {
+			checks++
+		}
+		return nil
+	})
+	cfg := &BroadcastConfig{MinStatusInterval: 1, MaxStatusInterval: 4}
+	live := newVidforwardPermanentLive()
+	sm := &broadcastStateMachine{currentState: live, ctx: &broadcastContext{cfg: cfg, bus: bus}}
+
+	// Checks back off from 1 to 4 minutes while healthy.
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var times []int
+	for m := 0; m <= 20; m++ {
+		before := checks
+		sm.publishStatusEvent(now.Add(time.Duration(m)*time.Minute + time.Second))
+		if checks != before {
+			times = append(times, m)
+		}
+	}
+	want := []int{0, 3, 8, 13, 18}
+	if !reflect.DeepEqual(times, want) {
+		t.Errorf("healthy status checks at minutes %v, want %v", times, want)
+	}
+
+	// Checks revert to the minimum interval while unhealthy.
+	unhealthy := newVidforwardPermanentLiveUnhealthy(nil)
+	unhealthy.liveStateFields = live.liveStateFields
+	sm.currentState = unhealthy
+	checks = 0
+	for m := 21; m <= 25; m++ {
+		sm.publishStatusEvent(now.Add(time.Duration(m)*time.Minute + 2*time.Second))
+	}
+	if checks != 3 {
+		t.Errorf("got %d unhealthy status checks in 5 minutes, want 3", checks)
+	}
+}
+
+// TestBroadcastWindow tests broadcast windows at times which are
+// problematic around midnight and daylight saving changes.
+func TestBroadcastWindow(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		desc      string
+		now       time.Time
+		start     time.Time
+		end       time.Time
+		wantStart string
+		wantEnd   string
+	}{
+		{
+			desc:      "same day",
+			now:       at(time.October, 16, 12, 0),
+			start:     at(time.October, 1, 9, 0),
+			end:       at(time.October, 1, 17, 0),
+			wantStart: "2026-10-16T09:00:00+10:30",
+			wantEnd:   "2026-10-16T17:00:00+10:30",
+		},
+		{
+			desc:      "overnight before midnight",
+			now:       at(time.October, 16, 23, 0),
+			start:     at(time.October, 1, 22, 0),
+			end:       at(time.October, 2, 2, 0),
+			wantStart: "2026-10-16T22:00:00+10:30",
+			wantEnd:   "2026-10-17T02:00:00+10:30",
+		},
+		{
+			desc:      "overnight after midnight",
+			now:       at(time.October, 17, 1, 0),
+			start:     at(time.October, 1, 22, 0),
+			end:       at(time.October, 2, 2, 0),
+			wantStart: "2026-10-16T22:00:00+10:30",
+			wantEnd:   "2026-10-17T02:00:00+10:30",
+		},
+		{
+			desc:      "overnight after end",
+			now:       at(time.October, 17, 3, 0),
+			start:     at(time.October, 1, 22, 0),
+			end:       at(time.October, 2, 2, 0),
+			wantStart: "2026-10-17T22:00:00+10:30",
+			wantEnd:   "2026-10-18T02:00:00+10:30",
+		},
+		{
+			desc:      "ending at midnight",
+			now:       at(time.October, 16, 20, 0),
+			start:     at(time.October, 1, 18, 0),
+			end:       at(time.October, 2, 0, 0),
+			wantStart: "2026-10-16T18:00:00+10:30",
+			wantEnd:   "2026-10-17T00:00:00+10:30",
+		},
+		{
+			desc:      "overnight across end of daylight saving",
+			now:       at(time.April, 5, 1, 0),
+			start:     at(time.March, 1, 22, 0),
+			end:       at(time.March, 2, 6, 0),
+			wantStart: "2026-04-04T22:00:00+10:30",
+			wantEnd:   "2026-04-05T06:00:00+09:30",
+		},
+		{
+			desc:      "overnight across start of daylight saving",
+			now:       at(time.October, 4, 5, 0),
+			start:     at(time.September, 1, 22, 0),
+			end:       at(time.September, 2, 6, 0),
+			wantStart: "2026-10-03T22:00:00+09:30",
+			wantEnd:   "2026-10-04T06:00:00+10:30",
+		},
+		{
+			desc:      "start skipped by daylight saving",
+			now:       at(time.October, 4, 12, 0),
+			start:     at(time.September, 1, 2, 30),
+			end:       at(time.September, 1, 4, 0),
+			wantStart: "2026-10-04T03:30:00+10:30",
+			wantEnd:   "2026-10-04T04:00:00+10:30",
+		},
+		{
+			desc:      "previous window before daylight saving",
+			now:       at(time.October, 16, 12, 0),
+			start:     at(time.June, 1, 9, 0),
+			end:       at(time.June, 1, 17, 0),
+			wantStart: "2026-10-16T09:00:00+10:30",
+			wantEnd:   "2026-10-16T17:00:00+10:30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			start, end := broadcastWindow(tt.now, tt.start.UTC(), tt.end.UTC(), loc)
+			if start.Location() != time.UTC || end.Location() != time.UTC {
+				t.Errorf("window not in UTC, got start: %v, end: %v", start, end)
+			}
+			gotStart, gotEnd := start.In(loc).Format(time.RFC3339), end.In(loc).Format(time.RFC3339)
+			if gotStart != tt.wantStart || gotEnd != tt.wantEnd {
+				t.Errorf("got window %s to %s, want %s to %s", gotStart, gotEnd, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
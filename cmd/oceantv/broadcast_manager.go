@@ -98,14 +98,9 @@ func (m *OceanBroadcastManager) CreateBroadcast(
 		return nil
 	}
 
-	// We're going to add the date to the broadcast's name, so get this and format.
-	loc, err := time.LoadLocation(locationID)
-	if err != nil {
-		return fmt.Errorf("could not load location: %w", err)
-	}
-
+	// We're going to add the site-local date to the broadcast's name, so get this and format.
 	const layout = "02/01/2006"
-	dateStr := time.Now().In(loc).Format(layout)
+	dateStr := time.Now().In(broadcastLocation(store, cfg, m.log)).Format(layout)
 
 	const (
 		// This allows for 10 broadcasts to be created with 3 retries each
@@ -123,7 +118,7 @@ func (m *OceanBroadcastManager) CreateBroadcast(
 	resp, ids, rtmpKey, err := svc.CreateBroadcast(
 		context.Background(),
 		cfg.Name+" "+dateStr,
-		cfg.Description,
+		broadcastDescription(context.Background(), store, cfg, time.Now(), m.log),
 		cfg.StreamName,
 		cfg.Privacy,
 		cfg.Resolution,
@@ -139,6 +134,7 @@ func (m *OceanBroadcastManager) CreateBroadcast(
 		_cfg.SID = ids.SID
 		_cfg.CID = ids.CID
 		_cfg.RTMPKey = rtmpKey
+		_cfg.HealthIncidents = 0
 	})
 	if err != nil {
 		return fmt.Errorf("could not update config with transaction: %w", err)
@@ -246,8 +242,8 @@ func (m *OceanBroadcastManager) HandleStatus(ctx Ctx, cfg *Cfg, store Store, svc
 // searching the site for any registered ESP devices and looking at the latest
 // signal values on sensors which have been marked true to send a message.
 func (m *OceanBroadcastManager) HandleChatMessage(ctx Ctx, cfg *Cfg) error {
-	if !cfg.SendMsg {
-		m.log("ignoring sensors")
+	if !cfg.SendMsg && !cfg.SendConditions {
+		m.log("ignoring sensors and conditions")
 		return nil
 	}
 
@@ -255,7 +251,7 @@ func (m *OceanBroadcastManager) HandleChatMessage(ctx Ctx, cfg *Cfg) error {
 	var msg string
 
 	for _, sensor := range cfg.SensorList {
-		if !sensor.SendMsg {
+		if !cfg.SendMsg || !sensor.SendMsg {
 			continue
 		}
 		// Get the latest signal for the sensor.
@@ -289,6 +285,18 @@ func (m *OceanBroadcastManager) HandleChatMessage(ctx Ctx, cfg *Cfg) error {
 		msg += line
 	}
 
+	if cfg.SendConditions {
+		c, err := siteConditions(ctx, m.store, cfg.SKey, time.Now())
+		switch {
+		case err != nil:
+			m.log("could not get conditions for chat message: %v", err)
+		case msg == "":
+			msg = c.String()
+		default:
+			msg += "| " + c.String()
+		}
+	}
+
 	if msg == "" {
 		m.log("chat message empty")
 		return nil
@@ -359,6 +367,7 @@ func (m *OceanBroadcastManager) SetupSecondary(ctx Ctx, cfg *Cfg, store Store) e
 		_cfg.Privacy = "unlisted" // We don't want the secondary broadcast to be easily discovered by youtube watchers.
 		_cfg.OnActions = ""       // We don't need it to have any control of the camera hardware.
 		_cfg.OffActions = ""      // Ditto.
+		_cfg.Controllers = nil    // Ditto.
 		_cfg.SendMsg = true       // It would be handy to have sensors stored in the store broadcasts too.
 		_cfg.Start = cfg.Start
 		_cfg.End = cfg.End
@@ -394,20 +403,20 @@ func (m *OceanBroadcastManager) SetupSecondary(ctx Ctx, cfg *Cfg, store Store) e
 // broadcast.BroadcastStream function for notifications.
 func opsHealthNotifyFunc(ctx context.Context, cfg *BroadcastConfig) func(string) error {
 	return func(msg string) error {
-		return notifier.Send(ctx, cfg.SKey, broadcastGeneric, msg)
+		return notifier.Send(notifyContext(ctx, cfg), cfg.SKey, broadcastGeneric, msg)
 	}
 }
 
 // broadcastCanBeReused checks if a broadcast can be reused based on how old it
 // is, if it has been revoked or completed, and if its IDs have been set.
 func (m *OceanBroadcastManager) broadcastCanBeReused(cfg *BroadcastConfig, svc BroadcastService) bool {
-	// Check if the broadcast was created today. Don't reuse an old broadcast.
+	// Check if the broadcast was created today, in site-local time. Don't reuse an old broadcast.
 	startTime, err := svc.BroadcastScheduledStartTime(context.Background(), cfg.ID)
 	if err != nil {
 		m.log("could not get today's broadcast start time: %v", err)
 		return false
 	}
-	now := time.Now()
+	now := time.Now().In(broadcastLocation(m.store, cfg, m.log))
 	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	if startTime.Before(startOfToday) || startTime.IsZero() {
 		m.log("broadcast does not exist for today, last start time: %v", startTime)
@@ -0,0 +1,169 @@
+/*
+DESCRIPTION
+  broadcast_metadata.go provides scheduled refreshing of broadcast
+  metadata, i.e., titles and thumbnails, which keeps long-running
+  broadcasts current.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// defaultTitleTemplate is the title template used when a broadcast
+// does not specify one, which matches the title given to broadcasts
+// when they are created.
+const defaultTitleTemplate = "{{.Name}} {{.Date}}"
+
+// titleData is the data available to title templates.
+type titleData struct {
+	Name string // Broadcast name.
+	Site string // Site name.
+	Date string // Local date in dd/mm/yyyy format.
+}
+
+// metadataRefreshIsDue returns true if the broadcast's metadata should
+// be refreshed at t, i.e., t is at or after today's refresh time, in
+// the broadcast's location loc, and metadata has not been refreshed
+// since.
+func metadataRefreshIsDue(cfg *BroadcastConfig, t time.Time, loc *time.Location) (bool, error) {
+	if cfg.MetadataRefreshTime == "" {
+		return false, nil
+	}
+	hm, err := time.Parse("15:04", cfg.MetadataRefreshTime)
+	if err != nil {
+		return false, fmt.Errorf("invalid metadata refresh time: %q", cfg.MetadataRefreshTime)
+	}
+	local := t.In(loc)
+	refresh := time.Date(local.Year(), local.Month(), local.Day(), hm.Hour(), hm.Minute(), 0, 0, loc)
+	return !t.Before(refresh) && cfg.MetadataRefreshed.Before(refresh), nil
+}
+
+// broadcastTitle returns the title of the broadcast at t, as per its
+// title template, with the date in the broadcast's location loc.
+func broadcastTitle(cfg *BroadcastConfig, site string, t time.Time, loc *time.Location) (string, error) {
+	text := cfg.TitleTemplate
+	if text == "" {
+		text = defaultTitleTemplate
+	}
+	tmpl, err := template.New("title").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid title template: %w", err)
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, titleData{Name: cfg.Name, Site: site, Date: t.In(loc).Format("02/01/2006")})
+	if err != nil {
+		return "", fmt.Errorf("could not execute title template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// refreshMetadataIfDue refreshes the title, thumbnail and description
+// (with current conditions, if enabled) of the broadcast if due.
+// Failures are not retried until the next day's refresh time, so that a
+// persistent problem does not consume API quota every tick, and are
+// only notified when they differ from the last failure.
+func (sm *broadcastStateMachine) refreshMetadataIfDue(t time.Time) {
+	loc := broadcastLocation(sm.ctx.store, sm.ctx.cfg, sm.log)
+	due, err := metadataRefreshIsDue(sm.ctx.cfg, t, loc)
+	if err != nil {
+		sm.notifyMetadataErr(sm.logAndNotifyConfiguration, fmt.Errorf("could not check metadata refresh: %w", err))
+	}
+	if !due || sm.ctx.cfg.ID == "" {
+		return
+	}
+	if !try(
+		sm.ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.MetadataRefreshed = t }),
+		"could not save metadata refresh time",
+		sm.logAndNotifySoftware,
+	) {
+		return
+	}
+
+	var site string
+	s, err := model.GetSite(context.Background(), sm.ctx.store, sm.ctx.cfg.SKey)
+	if err != nil {
+		sm.log("could not get site for title: %v", err)
+	} else {
+		site = s.Name
+	}
+	title, err := broadcastTitle(sm.ctx.cfg, site, t, loc)
+	if err != nil {
+		sm.notifyMetadataErr(sm.logAndNotifyConfiguration, fmt.Errorf("could not get broadcast title: %w", err))
+		return
+	}
+
+	var thumbnail io.Reader
+	if sm.ctx.cfg.ThumbnailURL != "" {
+		const timeout = 30 * time.Second
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(sm.ctx.cfg.ThumbnailURL)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		if err != nil {
+			sm.notifyMetadataErr(sm.logAndNotifyConfiguration, fmt.Errorf("could not get thumbnail from %s: %w", sm.ctx.cfg.ThumbnailURL, err))
+			return
+		}
+		defer resp.Body.Close()
+		thumbnail = resp.Body
+	}
+
+	description := broadcastDescription(context.Background(), sm.ctx.store, sm.ctx.cfg, t, sm.log)
+	err = sm.ctx.svc.UpdateMetadata(context.Background(), sm.ctx.cfg.ID, title, description, thumbnail)
+	if err != nil {
+		sm.notifyMetadataErr(sm.logAndNotifySoftware, fmt.Errorf("could not update broadcast metadata: %w", err))
+		return
+	}
+	sm.log("refreshed metadata, title: %s", title)
+	if sm.ctx.cfg.MetadataErr != "" {
+		try(
+			sm.ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.MetadataErr = "" }),
+			"could not clear metadata error",
+			sm.log,
+		)
+	}
+}
+
+// notifyMetadataErr notifies a metadata refresh error using notify,
+// unless it is the error last notified, in which case it is only
+// logged.
+func (sm *broadcastStateMachine) notifyMetadataErr(notify func(string, ...interface{}), err error) {
+	if err.Error() == sm.ctx.cfg.MetadataErr {
+		sm.log("%v", err)
+		return
+	}
+	notify("%v", err)
+	try(
+		sm.ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.MetadataErr = err.Error() }),
+		"could not save metadata error",
+		sm.log,
+	)
+}
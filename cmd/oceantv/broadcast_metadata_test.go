@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestMetadataRefreshIsDue(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	at := func(d, h, m int) time.Time { return time.Date(2026, 10, d, h, m, 0, 0, loc) }
+
+	tests := []struct {
+		desc    string
+		cfg     BroadcastConfig
+		t       time.Time
+		want    bool
+		wantErr bool
+	}{
+		{
+			desc: "not configured",
+			cfg:  BroadcastConfig{},
+			t:    at(16, 12, 0),
+		},
+		{
+			desc: "before refresh time",
+			cfg:  BroadcastConfig{MetadataRefreshTime: "06:00"},
+			t:    at(16, 5, 59),
+		},
+		{
+			desc: "never refreshed",
+			cfg:  BroadcastConfig{MetadataRefreshTime: "06:00"},
+			t:    at(16, 6, 0),
+			want: true,
+		},
+		{
+			desc: "refreshed yesterday",
+			cfg:  BroadcastConfig{MetadataRefreshTime: "06:00", MetadataRefreshed: at(15, 6, 1)},
+			t:    at(16, 7, 0),
+			want: true,
+		},
+		{
+			desc: "refreshed today",
+			cfg:  BroadcastConfig{MetadataRefreshTime: "06:00", MetadataRefreshed: at(16, 6, 1)},
+			t:    at(16, 23, 0),
+		},
+		{
+			desc:    "invalid refresh time",
+			cfg:     BroadcastConfig{MetadataRefreshTime: "6am"},
+			t:       at(16, 7, 0),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := metadataRefreshIsDue(&tt.cfg, tt.t, loc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("metadataRefreshIsDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBroadcastTitle(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	now := time.Date(2026, 10, 16, 6, 0, 0, 0, loc)
+
+	tests := []struct {
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{tmpl: "", want: "Rapid Bay 16/10/2026"},
+		{tmpl: "{{.Site}} Live - {{.Date}}", want: "Rapid Bay Jetty Live - 16/10/2026"},
+		{tmpl: "{{.Site", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := &BroadcastConfig{Name: "Rapid Bay", TitleTemplate: tt.tmpl}
+		got, err := broadcastTitle(cfg, "Rapid Bay Jetty", now, loc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("template %q: unexpected error: %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("template %q: got %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestRefreshMetadataIfDue(t *testing.T) {
+	model.RegisterEntities()
+	store, err := datastore.NewStore(context.Background(), "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	err = model.PutSite(context.Background(), store, &model.Site{Skey: 1, Name: "Rapid Bay Jetty", TimezoneName: "Australia/Perth"})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	loc, err := time.LoadLocation("Australia/Perth")
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+
+	now := time.Now()
+	cfg := &BroadcastConfig{
+		SKey:                1,
+		ID:                  "id",
+		Name:                "Test",
+		TitleTemplate:       "{{.Site}} {{.Date}}",
+		MetadataRefreshTime: "00:00",
+	}
+	svc := newDummyService()
+	ctx := &broadcastContext{
+		cfg:       cfg,
+		man:       newDummyManager(t, cfg),
+		store:     store,
+		svc:       svc,
+		notifier:  newMockNotifier(),
+		logOutput: t.Log,
+	}
+	sm := &broadcastStateMachine{currentState: newDirectLive(ctx), ctx: ctx}
+
+	// The refresh is due on the first call only.
+	sm.refreshMetadataIfDue(now)
+	sm.refreshMetadataIfDue(now.Add(time.Minute))
+
+	if len(svc.titles) != 1 {
+		t.Fatalf("got %d metadata updates, want 1", len(svc.titles))
+	}
+	want, err := broadcastTitle(cfg, "Rapid Bay Jetty", now, loc)
+	if err != nil {
+		t.Fatalf("could not get title: %v", err)
+	}
+	if svc.titles[0] != want {
+		t.Errorf("got title %q, want %q", svc.titles[0], want)
+	}
+	if !cfg.MetadataRefreshed.Equal(now) {
+		t.Errorf("got refresh time %v, want %v", cfg.MetadataRefreshed, now)
+	}
+}
+
+// TestRefreshMetadataErrNotifiedOnce tests that a persistent metadata
+// refresh error is notified once rather than every day.
+func TestRefreshMetadataErrNotifiedOnce(t *testing.T) {
+	model.RegisterEntities()
+	store, err := datastore.NewStore(context.Background(), "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	err = model.PutSite(context.Background(), store, &model.Site{Skey: 1, Name: "Rapid Bay Jetty"})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+
+	cfg := &BroadcastConfig{
+		SKey:                1,
+		ID:                  "id",
+		Name:                "Test",
+		TitleTemplate:       "{{.Site",
+		MetadataRefreshTime: "00:00",
+	}
+	notifier := newMockNotifier()
+	ctx := &broadcastContext{
+		cfg:       cfg,
+		man:       newDummyManager(t, cfg),
+		store:     store,
+		svc:       newDummyService(),
+		notifier:  notifier,
+		logOutput: t.Log,
+	}
+	sm := &broadcastStateMachine{currentState: newDirectLive(ctx), ctx: ctx}
+
+	now := time.Now()
+	for day := 0; day < 3; day++ {
+		sm.refreshMetadataIfDue(now.AddDate(0, 0, day))
+	}
+	var n int
+	for _, msgs := range notifier.sent[1] {
+		n += len(msgs)
+	}
+	if n != 1 {
+		t.Errorf("got %d notifications, want 1", n)
+	}
+	if cfg.MetadataErr == "" {
+		t.Errorf("metadata error not saved")
+	}
+}
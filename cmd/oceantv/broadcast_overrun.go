@@ -0,0 +1,124 @@
+/*
+DESCRIPTION
+  broadcast_overrun.go provides detection of broadcasts which continue
+  past their scheduled end, e.g., because a finish event was missed, so
+  that they are forcibly finished rather than running unattended.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultOverrunTolerance is how long past its end, after any power off
+// delay, a broadcast may remain active when no tolerance is configured.
+const defaultOverrunTolerance = 30 * time.Minute
+
+// overrunTolerance returns the broadcast's overrun tolerance.
+func overrunTolerance(cfg *BroadcastConfig) time.Duration {
+	if cfg.OverrunTolerance <= 0 {
+		return defaultOverrunTolerance
+	}
+	return time.Duration(cfg.OverrunTolerance) * time.Minute
+}
+
+// overrun returns how long the broadcast has continued past its end at
+// t, and true if that exceeds its power off delay and overrun
+// tolerance. Only broadcasts which are streaming, i.e., active and not
+// in slate, can overrun, since permanent broadcasts remain active in
+// slate between their scheduled times.
+func overrun(cfg *BroadcastConfig, t time.Time) (time.Duration, bool) {
+	if !cfg.Active || cfg.Slate || cfg.End.IsZero() {
+		return 0, false
+	}
+	deadline := cfg.End.Add(time.Duration(cfg.PowerOffDelay)*time.Minute + overrunTolerance(cfg))
+	if !t.After(deadline) {
+		return 0, false
+	}
+	return t.Sub(cfg.End), true
+}
+
+// overrunCauses returns descriptions of the likely causes of an overrun
+// at t, based on the state machine's current state and the config.
+func overrunCauses(sm *broadcastStateMachine, t time.Time) []string {
+	var causes []string
+	cause := func(msg string, args ...interface{}) {
+		causes = append(causes, fmt.Sprintf(msg, args...))
+	}
+
+	cfg := sm.ctx.cfg
+	name := stateToString(sm.currentState)
+	switch s := sm.currentState.(type) {
+	case *vidforwardPermanentLive, *vidforwardPermanentLiveUnhealthy,
+		*vidforwardSecondaryLive, *vidforwardSecondaryLiveUnhealthy,
+		*directLive, *directLiveUnhealthy:
+		if sm.finishIsDue(timeEvent{t}) {
+			cause("finish was due but %s did not transition, e.g., the config could not be saved", name)
+		} else {
+			cause("finish was not due in %s at %s", name, t.Format(time.RFC3339))
+		}
+	case *draining:
+		cause("draining since %s did not power off after %d minute(s)", s.LastEntered.Format(time.RFC3339), cfg.PowerOffDelay)
+	default:
+		cause("%s ignores finish events", name)
+	}
+
+	if cfg.End.Before(cfg.Start) {
+		cause("end %s is before start %s", cfg.End.Format(time.RFC3339), cfg.Start.Format(time.RFC3339))
+	}
+	if len(cfg.Events) > 0 {
+		cause("%d stored event(s) were not handled: %s", len(cfg.Events), strings.Join(cfg.Events, ", "))
+	}
+	if cfg.HardwareState != "" {
+		cause("hardware is in %s", cfg.HardwareState)
+	}
+	return causes
+}
+
+// checkOverrun forcibly finishes the broadcast if it has overrun at t,
+// notifying of the likely causes. A finish event is published, and if
+// that does not finish the broadcast, i.e., the current state ignores
+// it or starts draining, the broadcast is transitioned to its finished
+// state directly, since any power off delay has already elapsed.
+func (bs *broadcastSystem) checkOverrun(t time.Time) {
+	over, ok := overrun(bs.ctx.cfg, t)
+	if !ok {
+		return
+	}
+
+	bs.ctx.logAndNotify(
+		broadcastOverrun,
+		"broadcast still active %v past its end of %s, forcing finish; likely cause(s): %s",
+		over.Round(time.Minute),
+		bs.ctx.cfg.End.Format(time.RFC3339),
+		strings.Join(overrunCauses(bs.sm, t), "; "),
+	)
+
+	prev := bs.sm.currentState
+	bs.ctx.bus.publish(finishEvent{})
+	if _, isDraining := bs.sm.currentState.(*draining); bs.sm.currentState != prev && !isDraining {
+		return
+	}
+	bs.log("finish event did not finish broadcast in %s, transitioning directly", stateToString(bs.sm.currentState))
+	bs.sm.transition(finishedState(bs.ctx))
+}
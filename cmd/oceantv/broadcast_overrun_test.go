@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverrun(t *testing.T) {
+	end := time.Date(2026, 10, 17, 17, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc     string
+		cfg      BroadcastConfig
+		t        time.Time
+		wantOver time.Duration
+		wantOK   bool
+	}{
+		{
+			desc: "not active",
+			cfg:  BroadcastConfig{End: end},
+			t:    end.Add(2 * time.Hour),
+		},
+		{
+			desc: "slate",
+			cfg:  BroadcastConfig{End: end, Active: true, Slate: true},
+			t:    end.Add(2 * time.Hour),
+		},
+		{
+			desc: "within default tolerance",
+			cfg:  BroadcastConfig{End: end, Active: true},
+			t:    end.Add(30 * time.Minute),
+		},
+		{
+			desc:     "past default tolerance",
+			cfg:      BroadcastConfig{End: end, Active: true},
+			t:        end.Add(31 * time.Minute),
+			wantOver: 31 * time.Minute,
+			wantOK:   true,
+		},
+		{
+			desc:     "past configured tolerance",
+			cfg:      BroadcastConfig{End: end, Active: true, OverrunTolerance: 5},
+			t:        end.Add(6 * time.Minute),
+			wantOver: 6 * time.Minute,
+			wantOK:   true,
+		},
+		{
+			desc: "within power off delay",
+			cfg:  BroadcastConfig{End: end, Active: true, OverrunTolerance: 5, PowerOffDelay: 60},
+			t:    end.Add(time.Hour),
+		},
+		{
+			desc:     "past power off delay and tolerance",
+			cfg:      BroadcastConfig{End: end, Active: true, OverrunTolerance: 5, PowerOffDelay: 60},
+			t:        end.Add(66 * time.Minute),
+			wantOver: 66 * time.Minute,
+			wantOK:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			over, ok := overrun(&tt.cfg, tt.t)
+			if over != tt.wantOver || ok != tt.wantOK {
+				t.Errorf("overrun() = %v, %v, want %v, %v", over, ok, tt.wantOver, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCheckOverrun(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		desc          string
+		state         func(*broadcastContext) state
+		cfg           *BroadcastConfig
+		wantNotify    []string
+		expectedState state
+	}{
+		{
+			desc:          "not overrun",
+			state:         func(ctx *broadcastContext) state { return newDirectLive(ctx) },
+			cfg:           &BroadcastConfig{Start: now.Add(-time.Hour), End: now.Add(-10 * time.Minute)},
+			expectedState: &directLive{},
+		},
+		{
+			desc:          "live finished by finish event",
+			state:         func(ctx *broadcastContext) state { return newDirectLive(ctx) },
+			cfg:           &BroadcastConfig{Start: now.Add(-3 * time.Hour), End: now.Add(-2 * time.Hour)},
+			wantNotify:    []string{"2h0m0s past its end", "finish was due but main.directLive did not transition"},
+			expectedState: &directIdle{},
+		},
+		{
+			desc:          "live finished without draining",
+			state:         func(ctx *broadcastContext) state { return newDirectLive(ctx) },
+			cfg:           &BroadcastConfig{Start: now.Add(-3 * time.Hour), End: now.Add(-2 * time.Hour), PowerOffDelay: 10},
+			wantNotify:    []string{"finish was due but main.directLive did not transition"},
+			expectedState: &directIdle{},
+		},
+		{
+			desc: "draining",
+			state: func(ctx *broadcastContext) state {
+				s := newDraining(ctx)
+				s.LastEntered = now.Add(-2 * time.Hour)
+				return s
+			},
+			cfg:           &BroadcastConfig{Start: now.Add(-3 * time.Hour), End: now.Add(-2 * time.Hour), PowerOffDelay: 10},
+			wantNotify:    []string{"did not power off after 10 minute(s)"},
+			expectedState: &directIdle{},
+		},
+		{
+			desc:          "state ignoring finish",
+			state:         func(ctx *broadcastContext) state { return newVidforwardPermanentTransitionLiveToSlate(ctx) },
+			cfg:           &BroadcastConfig{Start: now.Add(-3 * time.Hour), End: now.Add(-2 * time.Hour), Events: []string{"finishEvent"}},
+			wantNotify:    []string{"main.vidforwardPermanentTransitionLiveToSlate ignores finish events", "1 stored event(s) were not handled: finishEvent"},
+			expectedState: &vidforwardPermanentTransitionLiveToSlate{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			bCtx := standardMockBroadcastContext(t, true)
+			bCtx.cfg = tt.cfg
+			bCtx.man = newDummyManager(t, tt.cfg)
+			bCtx.fwd = newDummyForwardingService()
+			bCtx.bus = newBasicEventBus(ctx, nil, func(string, ...interface{}) {})
+
+			initial := tt.state(bCtx)
+			updateBroadcastBasedOnState(initial, tt.cfg)
+			sm := &broadcastStateMachine{currentState: initial, ctx: bCtx}
+			bCtx.bus.subscribe(sm.handleEvent)
+			sys := &broadcastSystem{ctx: bCtx, sm: sm, log: bCtx.log}
+
+			sys.checkOverrun(now)
+
+			sent := bCtx.notifier.(*mockNotifier).sent[tt.cfg.SKey][broadcastOverrun]
+			if len(tt.wantNotify) == 0 {
+				if len(sent) != 0 {
+					t.Errorf("got unexpected overrun notifications: %q", sent)
+				}
+			} else {
+				if len(sent) != 1 {
+					t.Fatalf("got %d overrun notifications, want 1", len(sent))
+				}
+				for _, want := range tt.wantNotify {
+					if !strings.Contains(sent[0], want) {
+						t.Errorf("notification %q does not contain %q", sent[0], want)
+					}
+				}
+			}
+
+			if stateToString(sm.currentState) != stateToString(tt.expectedState) {
+				t.Errorf("unexpected state after overrun check: got %v, want %v", stateToString(sm.currentState), stateToString(tt.expectedState))
+			}
+		})
+	}
+}
@@ -28,6 +28,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -35,15 +36,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ausocean/cloud/cmd/oceantv/broadcast"
 	"github.com/ausocean/cloud/model"
 )
 
-type SlateOption func(*BroadcastConfig) error
+// slateRequest holds the options of a slate request.
+type slateRequest struct {
+	typ  SlateType
+	name string
+}
+
+type SlateOption func(*slateRequest) error
 
 type ForwardingService interface {
 	Stream(cfg *BroadcastConfig) error
 	Slate(cfg *BroadcastConfig, opts ...SlateOption) error
 	UploadSlate(cfg *BroadcastConfig, name string, file io.Reader) error
+	Ping(cfg *BroadcastConfig) error
 }
 
 type vidforwardStatus string
@@ -62,7 +71,7 @@ func NewVidforwardService(log func(string, ...interface{})) *VidforwardService {
 }
 
 func (v *VidforwardService) Stream(cfg *BroadcastConfig) error {
-	return vidforwardRequest(cfg, vidforwardStatusPlay, v.log)
+	return vidforwardRequest(cfg, vidforwardStatusPlay, "", v.log)
 }
 
 type SlateType string
@@ -76,13 +85,53 @@ const (
 // the type of slate to display.
 // This is currently just a stub.
 func WithType(slate SlateType) SlateOption {
-	return func(cfg *BroadcastConfig) error {
+	return func(r *slateRequest) error {
+		r.typ = slate
+		return nil
+	}
+}
+
+// WithSlate is an option for the Slate function that allows the caller to
+// specify the slate to display by the name of an uploaded slate file.
+// The default slate is displayed if the name is empty.
+func WithSlate(name string) SlateOption {
+	return func(r *slateRequest) error {
+		r.name = name
 		return nil
 	}
 }
 
 func (v *VidforwardService) Slate(cfg *BroadcastConfig, opts ...SlateOption) error {
-	return vidforwardRequest(cfg, vidforwardStatusSlate, v.log)
+	var r slateRequest
+	for _, opt := range opts {
+		err := opt(&r)
+		if err != nil {
+			return fmt.Errorf("could not apply slate option: %w", err)
+		}
+	}
+	return vidforwardRequest(cfg, vidforwardStatusSlate, r.name, v.log)
+}
+
+// scheduledSlate returns the slate scheduled by the broadcast's slate
+// schedule for time t in the broadcast's location loc, or the empty
+// string, denoting the default slate, if none is scheduled. Earlier
+// windows take precedence.
+func scheduledSlate(cfg *BroadcastConfig, t time.Time, loc *time.Location) (string, error) {
+	if cfg.SlateSchedule == "" {
+		return "", nil
+	}
+	windows, err := broadcast.ParseSlateSchedule(cfg.SlateSchedule)
+	if err != nil {
+		return "", err
+	}
+	t = t.In(loc)
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range windows {
+		if w.Contains(d) {
+			return w.Slate, nil
+		}
+	}
+	return "", nil
 }
 
 func (v *VidforwardService) UploadSlate(cfg *BroadcastConfig, name string, file io.Reader) error {
@@ -124,7 +173,23 @@ func (v *VidforwardService) UploadSlate(cfg *BroadcastConfig, name string, file
 	return nil
 }
 
-func vidforwardRequest(cfg *BroadcastConfig, status vidforwardStatus, log func(string, ...interface{})) error {
+// Ping checks that the vidforward service is reachable. Any HTTP
+// response is taken to mean that it is.
+func (v *VidforwardService) Ping(cfg *BroadcastConfig) error {
+	if cfg.VidforwardHost == "" {
+		return errors.New("vidforward host is not set")
+	}
+	const timeout = 10 * time.Second
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get("http://" + cfg.VidforwardHost + "/")
+	if err != nil {
+		return fmt.Errorf("could not reach vidforward: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func vidforwardRequest(cfg *BroadcastConfig, status vidforwardStatus, slate string, log func(string, ...interface{})) error {
 	primary, secondary := cfg, cfg
 	var err error
 
@@ -151,10 +216,14 @@ func vidforwardRequest(cfg *BroadcastConfig, status vidforwardStatus, log func(s
 	data := struct {
 		MAC, Status string
 		URLs        []string
+		Slate       string `json:",omitempty"` // Slate file name, if not the default.
+		HLS         string `json:",omitempty"` // HLS playlist output, if any.
 	}{
 		MAC:    model.MacDecode(primary.CameraMac),
 		URLs:   urls,
 		Status: string(status),
+		Slate:  slate,
+		HLS:    primary.HLSOutput,
 	}
 
 	log("attempting to update vidforward configuration, data: %+v", data)
@@ -0,0 +1,147 @@
+/*
+DESCRIPTION
+  broadcast_readiness.go provides pre-broadcast readiness checks, which
+  are run ahead of a broadcast's start so that problems are found while
+  there is still time to fix them.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// cameraReportWindow is how recently the camera must have reported for
+// it to be considered ready. Cameras are generally powered down between
+// broadcasts, so this spans a day.
+const cameraReportWindow = 24 * time.Hour
+
+// readinessIsDue returns true if the readiness of the broadcast should
+// be checked at t, i.e., t is within the broadcast's readiness lead
+// time of its start, and readiness has not yet been checked for this
+// start.
+func readinessIsDue(cfg *BroadcastConfig, t time.Time) bool {
+	if cfg.ReadinessLeadTime <= 0 || cfg.Start.IsZero() || cfg.ReadinessChecked.Equal(cfg.Start) {
+		return false
+	}
+	lead := time.Duration(cfg.ReadinessLeadTime) * time.Minute
+	return !t.Before(cfg.Start.Add(-lead)) && t.Before(cfg.Start)
+}
+
+// checkReadiness checks that the broadcast is ready to start at its
+// scheduled start, i.e., the camera has reported recently, the
+// controller voltage is sufficient for streaming, vidforward is
+// reachable (if used), the YouTube token is valid and the stream has an
+// RTMP key. A description of each problem found is returned.
+func checkReadiness(ctx *broadcastContext, t time.Time) []string {
+	var problems []string
+	problem := func(msg string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(msg, args...))
+	}
+
+	if ctx.cfg.CameraMac == 0 {
+		problem("camera MAC is not set")
+	} else {
+		reported, err := ctx.camera.lastReported(ctx)
+		switch {
+		case err != nil:
+			problem("could not get camera's last report: %v", err)
+		case t.Sub(reported) > cameraReportWindow:
+			problem("camera has not reported since %s", reported.Format(time.RFC3339))
+		}
+	}
+
+	controllers := ctx.cfg.controllers()
+	if len(controllers) == 0 {
+		problem("controller MAC is not set")
+	}
+	required := ctx.cfg.RequiredStreamingVoltage
+	if required == 0 {
+		required = defaultRequiredStreamingVoltage
+	}
+	for _, c := range controllers {
+		mac := model.MacDecode(c.MAC)
+		voltage, err := ctx.camera.voltage(ctx, c)
+		switch {
+		case err != nil:
+			problem("could not get controller %s voltage: %v", mac, err)
+		case voltage < required:
+			problem("controller %s voltage %.2fV is below required streaming voltage %.2fV", mac, voltage, required)
+		}
+	}
+
+	if ctx.cfg.UsingVidforward {
+		err := ctx.fwd.Ping(ctx.cfg)
+		if err != nil {
+			problem("vidforward is unreachable: %v", err)
+		}
+	}
+
+	// Getting the RTMP key also verifies the account's YouTube token.
+	key, err := ctx.svc.RTMPKey(context.Background(), ctx.cfg.StreamName)
+	switch {
+	case err != nil:
+		problem("YouTube token for %s is invalid or the API is unavailable: %v", ctx.cfg.Account, err)
+	case key == "":
+		problem("no RTMP key for stream %q", ctx.cfg.StreamName)
+	}
+	if !ctx.cfg.UsingVidforward && ctx.cfg.RTMPVar == "" {
+		problem("RTMP variable is not set")
+	}
+
+	return problems
+}
+
+// checkReadinessIfDue checks the readiness of the broadcast if it is
+// due, sending a single notification listing all problems found.
+func (sm *broadcastStateMachine) checkReadinessIfDue(event timeEvent) {
+	if !readinessIsDue(sm.ctx.cfg, event.Time) {
+		return
+	}
+
+	// Record the check first so that a failure to notify does not
+	// result in repeated checks.
+	start := sm.ctx.cfg.Start
+	if !try(
+		sm.ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.ReadinessChecked = start }),
+		"could not save readiness check",
+		sm.logAndNotifySoftware,
+	) {
+		return
+	}
+
+	problems := checkReadiness(sm.ctx, event.Time)
+	if len(problems) == 0 {
+		sm.log("ready to start at %v", start)
+		return
+	}
+	sm.ctx.logAndNotify(
+		broadcastReadiness,
+		"broadcast at risk, starting in %v with %d problem(s): %s",
+		start.Sub(event.Time).Round(time.Minute),
+		len(problems),
+		strings.Join(problems, "; "),
+	)
+}
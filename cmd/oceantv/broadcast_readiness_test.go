@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadinessIsDue(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc string
+		cfg  BroadcastConfig
+		t    time.Time
+		want bool
+	}{
+		{
+			desc: "not configured",
+			cfg:  BroadcastConfig{Start: start},
+			t:    start.Add(-10 * time.Minute),
+			want: false,
+		},
+		{
+			desc: "before lead time",
+			cfg:  BroadcastConfig{Start: start, ReadinessLeadTime: 30},
+			t:    start.Add(-31 * time.Minute),
+			want: false,
+		},
+		{
+			desc: "within lead time",
+			cfg:  BroadcastConfig{Start: start, ReadinessLeadTime: 30},
+			t:    start.Add(-30 * time.Minute),
+			want: true,
+		},
+		{
+			desc: "already checked",
+			cfg:  BroadcastConfig{Start: start, ReadinessLeadTime: 30, ReadinessChecked: start},
+			t:    start.Add(-10 * time.Minute),
+			want: false,
+		},
+		{
+			desc: "checked for previous start",
+			cfg:  BroadcastConfig{Start: start, ReadinessLeadTime: 30, ReadinessChecked: start.AddDate(0, 0, -1)},
+			t:    start.Add(-10 * time.Minute),
+			want: true,
+		},
+		{
+			desc: "after start",
+			cfg:  BroadcastConfig{Start: start, ReadinessLeadTime: 30},
+			t:    start,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := readinessIsDue(&tt.cfg, tt.t)
+			if got != tt.want {
+				t.Errorf("readinessIsDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReadiness(t *testing.T) {
+	readyCfg := func() *BroadcastConfig {
+		return &BroadcastConfig{
+			CameraMac:     1,
+			ControllerMAC: 2,
+			StreamName:    "stream",
+			RTMPVar:       "RTMP",
+		}
+	}
+
+	tests := []struct {
+		desc     string
+		cfg      func(*BroadcastConfig)
+		hardware *dummyHardwareManager
+		rtmpKey  string
+		want     []string
+	}{
+		{
+			desc:     "ready",
+			cfg:      func(*BroadcastConfig) {},
+			hardware: newDummyHardwareManager(),
+			rtmpKey:  "key",
+		},
+		{
+			desc:     "camera not reporting",
+			cfg:      func(*BroadcastConfig) {},
+			hardware: newDummyHardwareManager(withHardwareFault()),
+			rtmpKey:  "key",
+			want:     []string{"camera has not reported"},
+		},
+		{
+			desc:     "low voltage",
+			cfg:      func(*BroadcastConfig) {},
+			hardware: newDummyHardwareManager(withLowVoltage(), withChargingFault()),
+			rtmpKey:  "key",
+			want:     []string{"below required streaming voltage"},
+		},
+		{
+			desc:     "additional controller low voltage",
+			cfg:      func(c *BroadcastConfig) { c.Controllers = []Controller{{MAC: 3}} },
+			hardware: newDummyHardwareManager(withControllerVoltage(3, 24.0)),
+			rtmpKey:  "key",
+			want:     []string{"controller 00:00:00:00:00:03 voltage 24.00V is below"},
+		},
+		{
+			desc:     "multiple problems",
+			cfg:      func(c *BroadcastConfig) { c.CameraMac = 0; c.RTMPVar = "" },
+			hardware: newDummyHardwareManager(),
+			want:     []string{"camera MAC is not set", "no RTMP key", "RTMP variable is not set"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := readyCfg()
+			tt.cfg(cfg)
+			ctx := &broadcastContext{
+				cfg:       cfg,
+				svc:       newDummyService(WithRTMPKey(tt.rtmpKey)),
+				fwd:       newDummyForwardingService(),
+				camera:    tt.hardware,
+				logOutput: t.Log,
+			}
+			got := checkReadiness(ctx, time.Now())
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d problems, want %d: %q", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !strings.Contains(got[i], tt.want[i]) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckReadinessIfDue(t *testing.T) {
+	start := time.Now().Add(20 * time.Minute)
+	cfg := &BroadcastConfig{
+		SKey:              1,
+		Start:             start,
+		End:               start.Add(time.Hour),
+		ReadinessLeadTime: 30,
+		StreamName:        "stream",
+		RTMPVar:           "RTMP",
+	}
+	n := newMockNotifier()
+	ctx := &broadcastContext{
+		cfg:       cfg,
+		man:       newDummyManager(t, cfg),
+		svc:       newDummyService(WithRTMPKey("key")),
+		fwd:       newDummyForwardingService(),
+		camera:    newDummyHardwareManager(),
+		notifier:  n,
+		logOutput: t.Log,
+	}
+	sm := &broadcastStateMachine{currentState: newDirectIdle(ctx), ctx: ctx}
+
+	// Both MACs are unset, so we expect a single notification
+	// listing both problems, despite checking on successive ticks.
+	sm.handleTimeEvent(timeEvent{start.Add(-15 * time.Minute)})
+	sm.handleTimeEvent(timeEvent{start.Add(-14 * time.Minute)})
+
+	if !cfg.ReadinessChecked.Equal(start) {
+		t.Errorf("readiness checked for %v, want %v", cfg.ReadinessChecked, start)
+	}
+	sent := n.sent[cfg.SKey][broadcastReadiness]
+	if len(sent) != 1 {
+		t.Fatalf("got %d readiness notifications, want 1", len(sent))
+	}
+	for _, want := range []string{"broadcast at risk", "2 problem(s)", "camera MAC is not set", "controller MAC is not set"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("notification %q does not contain %q", sent[0], want)
+		}
+	}
+	if len(n.sent[cfg.SKey]) != 1 {
+		t.Errorf("got unexpected notification kinds: %v", n.sent[cfg.SKey])
+	}
+}
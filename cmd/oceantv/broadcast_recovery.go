@@ -0,0 +1,202 @@
+/*
+DESCRIPTION
+  broadcast_recovery.go provides voltage recovery strategies, which
+  determine how long the hardware waits for voltage to recover before
+  starting, and whether voltage is still expected to recover. The
+  strategy is selected per broadcast.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/kortschak/sun"
+)
+
+// Voltage recovery strategy names, as used by
+// BroadcastConfig.VoltageRecoveryStrategy.
+const (
+	recoveryLinear      = "linear"
+	recoverySolar       = "solar"
+	recoveryExtrapolate = "extrapolate"
+)
+
+// recoveryStrategy is implemented by voltage recovery strategies.
+type recoveryStrategy interface {
+	// timeout returns the time allowed for voltage to recover, if
+	// recovery starts at t, given the configured voltage recovery timeout.
+	timeout(ctx *broadcastContext, t time.Time, base time.Duration) time.Duration
+
+	// update records the voltage v at t in the recovery's progress. An
+	// error is returned if voltage is not expected to recover before the
+	// recovery's deadline.
+	update(p *recoveryProgress, t time.Time, v float64) error
+}
+
+// recoveryStrategies holds the available strategies by name.
+var recoveryStrategies = map[string]recoveryStrategy{
+	recoveryLinear:      linearRecovery{},
+	recoverySolar:       solarRecovery{},
+	recoveryExtrapolate: extrapolateRecovery{},
+}
+
+// getRecoveryStrategy returns the name and implementation of the
+// broadcast's voltage recovery strategy, which defaults to linear if
+// not set or unknown.
+func getRecoveryStrategy(ctx *broadcastContext) (string, recoveryStrategy) {
+	name := ctx.cfg.VoltageRecoveryStrategy
+	s, ok := recoveryStrategies[name]
+	if !ok {
+		if name != "" {
+			ctx.log("unknown voltage recovery strategy %q, defaulting to %s", name, recoveryLinear)
+		}
+		return recoveryLinear, recoveryStrategies[recoveryLinear]
+	}
+	return name, s
+}
+
+// voltageRecoveryTimeout returns the time allowed for voltage to
+// recover if recovery starts at t, as per the broadcast's strategy.
+func voltageRecoveryTimeout(ctx *broadcastContext, t time.Time) time.Duration {
+	_, s := getRecoveryStrategy(ctx)
+	return s.timeout(ctx, t, time.Duration(sanatisedVoltageRecoveryTimeout(ctx))*time.Hour)
+}
+
+// recoveryProgress is the progress of a voltage recovery, which is
+// stored with the hardwareRecoveringVoltage state data.
+type recoveryProgress struct {
+	Strategy     string    // The name of the recovery strategy.
+	Deadline     time.Time // The time by which voltage must recover.
+	Required     float64   // The voltage required to stream.
+	Samples      int       // The number of voltage samples.
+	StartTime    time.Time // The time of the first sample.
+	StartVoltage float64   // The voltage of the first sample.
+	Voltage      float64   // The voltage of the latest sample.
+	Rate         float64   // The estimated charge rate in volts per hour, if any.
+}
+
+// record records the voltage v at t.
+func (p *recoveryProgress) record(t time.Time, v float64) {
+	if p.Samples == 0 {
+		p.StartTime, p.StartVoltage = t, v
+	}
+	p.Samples++
+	p.Voltage = v
+}
+
+// String returns a summary of the progress suitable for logs and
+// notifications.
+func (p *recoveryProgress) String() string {
+	if p.Samples == 0 {
+		return fmt.Sprintf("%s strategy, no voltage samples", p.Strategy)
+	}
+	parts := []string{
+		fmt.Sprintf("%s strategy", p.Strategy),
+		fmt.Sprintf("voltage %.2fV (from %.2fV, required %.2fV)", p.Voltage, p.StartVoltage, p.Required),
+	}
+	if p.Rate != 0 {
+		parts = append(parts, fmt.Sprintf("charging at %.2fV/h", p.Rate))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// linearRecovery waits for the configured timeout.
+type linearRecovery struct{}
+
+func (linearRecovery) timeout(ctx *broadcastContext, t time.Time, base time.Duration) time.Duration {
+	return base
+}
+
+func (linearRecovery) update(p *recoveryProgress, t time.Time, v float64) error {
+	p.record(t, v)
+	return nil
+}
+
+// solarRecovery waits for the configured timeout after daylight, since
+// solar-powered sites do not charge at night. Recovery that starts
+// during daylight waits for the configured timeout, as per
+// linearRecovery. Sites without a location also fall back to this.
+type solarRecovery struct{ linearRecovery }
+
+func (solarRecovery) timeout(ctx *broadcastContext, t time.Time, base time.Duration) time.Duration {
+	site, err := model.GetSite(context.Background(), ctx.store, ctx.cfg.SKey)
+	if err != nil {
+		ctx.log("could not get site for solar recovery, waiting without daylight: %v", err)
+		return base
+	}
+	if site.Latitude == 0 && site.Longitude == 0 {
+		ctx.log("site %d has no location for solar recovery, waiting without daylight", ctx.cfg.SKey)
+		return base
+	}
+	day, rise := daylight(t, site.Latitude, site.Longitude)
+	if day {
+		return base
+	}
+	ctx.log("recovering voltage at night, waiting until sunrise at %v", rise)
+	return rise.Sub(t) + base
+}
+
+// daylight returns true if it is daylight at t at the given location.
+// Otherwise, it returns the time of the next sunrise.
+func daylight(t time.Time, lat, lon float64) (bool, time.Time) {
+	// Times are calculated for the UTC date of t, so sunrise in
+	// eastern timezones may fall on the previous UTC date.
+	for _, d := range []int{-1, 0, 1} {
+		rise, _, set := sun.Times(t.AddDate(0, 0, d), lat, lon)
+		if t.Before(rise) {
+			return false, rise
+		}
+		if t.Before(set) {
+			return true, time.Time{}
+		}
+	}
+	return true, time.Time{}
+}
+
+// extrapolateRecovery estimates the charge rate from voltage samples
+// and abandons recovery early if voltage is not expected to recover
+// before the deadline, rather than waiting for the timeout.
+type extrapolateRecovery struct{ linearRecovery }
+
+// minRecoveryObservation is the time over which voltage is observed
+// before the charge rate is estimated.
+const minRecoveryObservation = 30 * time.Minute
+
+func (extrapolateRecovery) update(p *recoveryProgress, t time.Time, v float64) error {
+	p.record(t, v)
+	elapsed := t.Sub(p.StartTime)
+	if elapsed < minRecoveryObservation {
+		return nil
+	}
+	p.Rate = (v - p.StartVoltage) / elapsed.Hours()
+	if p.Rate <= 0 {
+		return fmt.Errorf("voltage has not increased in %v", elapsed.Round(time.Minute))
+	}
+	eta := t.Add(time.Duration((p.Required - v) / p.Rate * float64(time.Hour)))
+	if eta.After(p.Deadline) {
+		return fmt.Errorf("voltage projected to recover at %v, after deadline of %v", eta.Round(time.Minute), p.Deadline.Round(time.Minute))
+	}
+	return nil
+}
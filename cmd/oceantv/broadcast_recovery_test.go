@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestSolarRecoveryTimeout(t *testing.T) {
+	model.RegisterEntities()
+	store, err := datastore.NewStore(context.Background(), "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	err = model.PutSite(context.Background(), store, &model.Site{Skey: 1, Name: "Rapid Bay Jetty", Latitude: -35.52, Longitude: 138.18})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	err = model.PutSite(context.Background(), store, &model.Site{Skey: 2, Name: "Nowhere"})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+
+	const base = 4 * time.Hour
+	tests := []struct {
+		desc     string
+		skey     int64
+		t        time.Time
+		min, max time.Duration
+	}{
+		{
+			desc: "daylight",
+			skey: 1,
+			t:    time.Date(2026, 10, 16, 12, 0, 0, 0, loc),
+			min:  base,
+			max:  base,
+		},
+		{
+			desc: "night waits until sunrise",
+			skey: 1,
+			t:    time.Date(2026, 10, 16, 0, 0, 0, 0, loc),
+			min:  base + 6*time.Hour,
+			max:  base + 7*time.Hour,
+		},
+		{
+			desc: "evening waits until sunrise",
+			skey: 1,
+			t:    time.Date(2026, 10, 16, 22, 0, 0, 0, loc),
+			min:  base + 8*time.Hour,
+			max:  base + 9*time.Hour,
+		},
+		{
+			desc: "no location",
+			skey: 2,
+			t:    time.Date(2026, 10, 16, 0, 0, 0, 0, loc),
+			min:  base,
+			max:  base,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx := &broadcastContext{
+				cfg:       &BroadcastConfig{SKey: tt.skey},
+				store:     store,
+				logOutput: t.Log,
+			}
+			got := solarRecovery{}.timeout(ctx, tt.t, base)
+			if got < tt.min || got > tt.max {
+				t.Errorf("got timeout %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestExtrapolateRecovery(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	type sample struct {
+		after   time.Duration
+		voltage float64
+	}
+
+	tests := []struct {
+		desc    string
+		samples []sample
+		wantErr string
+	}{
+		{
+			desc:    "still observing",
+			samples: []sample{{0, 24.0}, {10 * time.Minute, 24.0}},
+		},
+		{
+			desc:    "not charging",
+			samples: []sample{{0, 24.0}, {30 * time.Minute, 24.0}},
+			wantErr: "voltage has not increased",
+		},
+		{
+			desc:    "charging too slowly",
+			samples: []sample{{0, 24.0}, {time.Hour, 24.1}},
+			wantErr: "after deadline",
+		},
+		{
+			desc:    "charging",
+			samples: []sample{{0, 24.0}, {time.Hour, 24.3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			p := &recoveryProgress{Strategy: recoveryExtrapolate, Deadline: start.Add(4 * time.Hour), Required: 24.5}
+			var err error
+			for _, s := range tt.samples {
+				err = extrapolateRecovery{}.update(p, start.Add(s.after), s.voltage)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("got error %v, want error containing %q", err, tt.wantErr)
+			}
+			if p.Samples != len(tt.samples) {
+				t.Errorf("got %d samples, want %d", p.Samples, len(tt.samples))
+			}
+		})
+	}
+}
+
+func TestAbandonVoltageRecovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	cfg := &BroadcastConfig{
+		SKey:                     1,
+		ControllerMAC:            1,
+		RequiredStreamingVoltage: 24.5,
+		VoltageRecoveryTimeout:   4,
+		VoltageRecoveryStrategy:  recoveryExtrapolate,
+	}
+	n := newMockNotifier()
+	bCtx := &broadcastContext{
+		cfg:       cfg,
+		man:       newDummyManager(t, cfg),
+		camera:    newDummyHardwareManager(withLowVoltage(), withChargingFault()),
+		bus:       newBasicEventBus(ctx, nil, func(string, ...interface{}) {}),
+		notifier:  n,
+		logOutput: t.Log,
+	}
+
+	// Voltage has not increased in the hour since recovery started.
+	s := newHardwareRecoveringVoltage(bCtx)
+	s.LastEntered = now.Add(-time.Hour)
+	s.Progress = recoveryProgress{
+		Strategy:     recoveryExtrapolate,
+		Deadline:     now.Add(3 * time.Hour),
+		Samples:      1,
+		StartTime:    now.Add(-time.Hour),
+		StartVoltage: 24.0,
+	}
+	sm := &hardwareStateMachine{currentState: s, ctx: bCtx}
+	sm.handleTimeEvent(timeEvent{now})
+
+	if _, ok := sm.currentState.(*hardwareOff); !ok {
+		t.Errorf("got state %s, want hardwareOff", stateToString(sm.currentState))
+	}
+	sent := n.sent[cfg.SKey][broadcastHardware]
+	if len(sent) != 1 {
+		t.Fatalf("got %d hardware notifications, want 1", len(sent))
+	}
+	for _, want := range []string{"abandoned voltage recovery", "extrapolate strategy", "voltage 24.00V"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("notification %q does not contain %q", sent[0], want)
+		}
+	}
+}
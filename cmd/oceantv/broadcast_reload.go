@@ -0,0 +1,193 @@
+/*
+DESCRIPTION
+  broadcast_reload.go provides safe reloading of the configurations of
+  active broadcasts when saved, i.e., changes that are safe to make
+  mid-stream are applied immediately, while changes that would leave
+  the broadcast in an inconsistent state, e.g., a new camera, are
+  deferred until the broadcast is no longer active.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// restartFields are the config fields that identify the broadcast's
+// stream and hardware, which cannot change while the broadcast is
+// active. Changes to these are deferred.
+var restartFields = []string{
+	"ID",
+	"SID",
+	"CID",
+	"StreamName",
+	"Privacy",
+	"Resolution",
+	"VidforwardHost",
+	"HLSOutput",
+	"CameraMac",
+	"ControllerMAC",
+	"OnActions",
+	"OffActions",
+	"Controllers",
+	"RTMPVar",
+	"RTMPKey",
+	"UsingVidforward",
+	"Account",
+}
+
+// runtimeFields are the config fields that hold the state of the
+// broadcast, which are maintained by the state machines rather than
+// saved by clients. These are kept while the broadcast is active.
+var runtimeFields = []string{
+	"Active",
+	"Slate",
+	"Issues",
+	"AttemptingToStart",
+	"Events",
+	"Unhealthy",
+	"HardwareState",
+	"StartFailures",
+	"Transitioning",
+	"StateData",
+	"HardwareStateData",
+	"InFailure",
+	"RecoveringVoltage",
+	"ReadinessChecked",
+	"MetadataRefreshed",
+	"MetadataErr",
+	"HealthIncidents",
+	"Draining",
+	"ViewersSampled",
+}
+
+// inProgress returns true if the broadcast is active, i.e., starting,
+// live, transitioning or draining. Permanent broadcasts in slate are
+// not in progress unless transitioning, since the camera is off.
+func inProgress(cfg *BroadcastConfig) bool {
+	return (cfg.Active && !cfg.Slate) || cfg.AttemptingToStart || cfg.Transitioning || cfg.Draining
+}
+
+// reloadConfig returns the config resulting from saving cfg over the
+// stored config old, along with the names of any fields whose changes
+// were deferred. The configs of broadcasts that are not in progress
+// are replaced by cfg. Otherwise, the runtime state of old is kept,
+// all other fields are reloaded from cfg, except restart-required
+// fields, which keep their old values and whose changes are stored as
+// pending changes, to be applied by applyPendingChanges. Changes are
+// merged with any previously pending changes, with new changes to the
+// same field taking precedence, and all pending fields are returned as
+// deferred.
+func reloadConfig(old, cfg *BroadcastConfig) (*BroadcastConfig, []string, error) {
+	c := *cfg
+	c.PendingChanges = nil
+	if !inProgress(old) {
+		return &c, nil, nil
+	}
+
+	ov, cv := reflect.ValueOf(old).Elem(), reflect.ValueOf(&c).Elem()
+	for _, f := range runtimeFields {
+		cv.FieldByName(f).Set(ov.FieldByName(f))
+	}
+
+	pending := make(map[string]json.RawMessage)
+	if len(old.PendingChanges) != 0 {
+		err := json.Unmarshal(old.PendingChanges, &pending)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not unmarshal pending changes: %w", err)
+		}
+	}
+	for _, f := range restartFields {
+		if equalField(ov.FieldByName(f), cv.FieldByName(f)) {
+			continue
+		}
+		v, err := json.Marshal(cv.FieldByName(f).Interface())
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not marshal pending change to %s: %w", f, err)
+		}
+		pending[f] = v
+		cv.FieldByName(f).Set(ov.FieldByName(f))
+	}
+	if len(pending) == 0 {
+		return &c, nil, nil
+	}
+
+	var deferred []string
+	for f := range pending {
+		deferred = append(deferred, f)
+	}
+	sort.Strings(deferred)
+	var err error
+	c.PendingChanges, err = json.Marshal(pending)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal pending changes: %w", err)
+	}
+	return &c, deferred, nil
+}
+
+// equalField returns true if two config field values are equal,
+// treating nil and empty slices as equal, since they are equivalent
+// once saved.
+func equalField(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// applyPendingChanges applies the broadcast's pending changes once it
+// is no longer in progress.
+func (bs *broadcastSystem) applyPendingChanges() {
+	if len(bs.ctx.cfg.PendingChanges) == 0 || inProgress(bs.ctx.cfg) {
+		return
+	}
+
+	var (
+		fields   []string
+		applyErr error
+	)
+	err := bs.ctx.man.Save(nil, func(_cfg *BroadcastConfig) {
+		pending := _cfg.PendingChanges
+		_cfg.PendingChanges = nil
+		var changes map[string]json.RawMessage
+		applyErr = json.Unmarshal(pending, &changes)
+		if applyErr != nil {
+			return
+		}
+		for f := range changes {
+			fields = append(fields, f)
+		}
+		applyErr = json.Unmarshal(pending, _cfg)
+	})
+	if err != nil {
+		bs.ctx.logAndNotify(broadcastSoftware, "could not save pending changes: %v", err)
+		return
+	}
+	if applyErr != nil {
+		bs.ctx.logAndNotify(broadcastConfiguration, "could not apply pending changes, discarding: %v", applyErr)
+		return
+	}
+	sort.Strings(fields)
+	bs.log("applied pending changes to %s", strings.Join(fields, ", "))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloadFields(t *testing.T) {
+	typ := reflect.TypeOf(BroadcastConfig{})
+	for _, f := range append(append([]string(nil), restartFields...), runtimeFields...) {
+		if _, ok := typ.FieldByName(f); !ok {
+			t.Errorf("BroadcastConfig has no field %s", f)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	end := time.Date(2026, 10, 17, 17, 0, 0, 0, time.UTC)
+	live := func() *BroadcastConfig {
+		return &BroadcastConfig{
+			Name:      "Rapid Bay",
+			ID:        "abc",
+			CameraMac: 1,
+			End:       end,
+			Active:    true,
+			StateData: []byte(`{}`),
+			Issues:    2,
+		}
+	}
+
+	tests := []struct {
+		desc         string
+		old          *BroadcastConfig
+		cfg          func(*BroadcastConfig)
+		want         func(*BroadcastConfig)
+		wantDeferred []string
+	}{
+		{
+			desc: "inactive replaced",
+			old:  &BroadcastConfig{Name: "Rapid Bay", CameraMac: 1, Issues: 2},
+			cfg:  func(c *BroadcastConfig) { c.CameraMac = 2; c.Active = false; c.Issues = 0 },
+			want: func(c *BroadcastConfig) { c.CameraMac = 2; c.Active = false; c.StateData = nil; c.Issues = 0 },
+		},
+		{
+			desc: "hot-reloadable applied",
+			old:  live(),
+			cfg: func(c *BroadcastConfig) {
+				c.End = end.Add(time.Hour)
+				c.Active, c.StateData, c.Issues = false, nil, 0
+			},
+			want: func(c *BroadcastConfig) { c.End = end.Add(time.Hour) },
+		},
+		{
+			desc: "restart-required deferred",
+			old:  live(),
+			cfg: func(c *BroadcastConfig) {
+				c.CameraMac = 2
+				c.End = end.Add(time.Hour)
+				c.Active, c.StateData, c.Issues = false, nil, 0
+			},
+			want: func(c *BroadcastConfig) {
+				c.End = end.Add(time.Hour)
+				c.PendingChanges = []byte(`{"CameraMac":2}`)
+			},
+			wantDeferred: []string{"CameraMac"},
+		},
+		{
+			desc: "hot-reloadable applied with earlier pending change kept",
+			old: func() *BroadcastConfig {
+				c := live()
+				c.PendingChanges = []byte(`{"CameraMac":2}`)
+				return c
+			}(),
+			cfg: func(c *BroadcastConfig) { c.End = end.Add(time.Hour) },
+			want: func(c *BroadcastConfig) {
+				c.End = end.Add(time.Hour)
+				c.PendingChanges = []byte(`{"CameraMac":2}`)
+			},
+			wantDeferred: []string{"CameraMac"},
+		},
+		{
+			desc: "restart-required merged with earlier pending changes",
+			old: func() *BroadcastConfig {
+				c := live()
+				c.PendingChanges = []byte(`{"CameraMac":2,"Privacy":"unlisted"}`)
+				return c
+			}(),
+			cfg: func(c *BroadcastConfig) { c.CameraMac = 3 },
+			want: func(c *BroadcastConfig) {
+				c.PendingChanges = []byte(`{"CameraMac":3,"Privacy":"unlisted"}`)
+			},
+			wantDeferred: []string{"CameraMac", "Privacy"},
+		},
+		{
+			desc: "empty controllers unchanged",
+			old:  live(),
+			cfg:  func(c *BroadcastConfig) { c.Controllers = []Controller{} },
+			want: func(c *BroadcastConfig) { c.Controllers = []Controller{} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := *tt.old
+			cfg.PendingChanges = nil
+			tt.cfg(&cfg)
+			want := *tt.old
+			want.PendingChanges = nil
+			tt.want(&want)
+
+			got, deferred, err := reloadConfig(tt.old, &cfg)
+			if err != nil {
+				t.Fatalf("reloadConfig returned error: %v", err)
+			}
+			if !reflect.DeepEqual(deferred, tt.wantDeferred) {
+				t.Errorf("deferred = %v, want %v", deferred, tt.wantDeferred)
+			}
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("reloadConfig() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestApplyPendingChanges(t *testing.T) {
+	cfg := &BroadcastConfig{CameraMac: 1, Active: true, PendingChanges: []byte(`{"CameraMac":2,"OnActions":"Power1=true"}`)}
+	bCtx := &broadcastContext{
+		cfg:       cfg,
+		man:       newDummyManager(t, cfg),
+		notifier:  newMockNotifier(),
+		logOutput: t.Log,
+	}
+	sys := &broadcastSystem{ctx: bCtx, log: bCtx.log}
+
+	// Changes are not applied while in progress.
+	sys.applyPendingChanges()
+	if cfg.CameraMac != 1 || len(cfg.PendingChanges) == 0 {
+		t.Fatalf("pending changes applied while in progress: %+v", cfg)
+	}
+
+	cfg.Active = false
+	sys.applyPendingChanges()
+	if cfg.CameraMac != 2 || cfg.OnActions != "Power1=true" || cfg.PendingChanges != nil {
+		t.Errorf("pending changes not applied: %+v", cfg)
+	}
+}
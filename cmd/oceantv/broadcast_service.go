@@ -31,6 +31,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -72,6 +73,8 @@ type BroadcastService interface {
 	RTMPKey(ctx context.Context, streamName string) (string, error)
 	CompleteBroadcast(ctx context.Context, id string) error
 	PostChatMessage(cID, msg string) error
+	UpdateMetadata(ctx context.Context, id, title, description string, thumbnail io.Reader) error
+	BroadcastStats(ctx context.Context, id string) (*broadcast.Stats, error)
 }
 
 // YouTubeResponse implements the ServerResponse interface for YouTube.
@@ -298,3 +301,32 @@ func (s *YouTubeBroadcastService) RTMPKey(ctx context.Context, streamName string
 func (s *YouTubeBroadcastService) PostChatMessage(cID, msg string) error {
 	return broadcast.PostChatMessage(cID, s.tokenURI, msg)
 }
+
+// UpdateMetadata updates the title, description and, if thumbnail is
+// not nil, the thumbnail of the broadcast with identification id using
+// the YouTube API.
+func (s *YouTubeBroadcastService) UpdateMetadata(ctx context.Context, id, title, description string, thumbnail io.Reader) error {
+	svc, err := broadcast.GetService(ctx, youtube.YoutubeScope, s.tokenURI)
+	if err != nil {
+		return fmt.Errorf("get service error: %w", err)
+	}
+	err = broadcast.UpdateMetadata(svc, id, title, description, thumbnail, s.log)
+	if err != nil {
+		return fmt.Errorf("update metadata error: %w", err)
+	}
+	return nil
+}
+
+// BroadcastStats gets the statistics of the broadcast with
+// identification id using the YouTube API.
+func (s *YouTubeBroadcastService) BroadcastStats(ctx context.Context, id string) (*broadcast.Stats, error) {
+	svc, err := broadcast.GetService(ctx, youtube.YoutubeScope, s.tokenURI)
+	if err != nil {
+		return nil, fmt.Errorf("get service error: %w", err)
+	}
+	stats, err := broadcast.GetStats(svc, id)
+	if err != nil {
+		return nil, fmt.Errorf("get stats error: %w", err)
+	}
+	return stats, nil
+}
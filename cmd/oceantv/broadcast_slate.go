@@ -0,0 +1,453 @@
+/*
+DESCRIPTION
+  broadcast_slate.go provides generation of slates for permanent
+  broadcasts, which render the site name and the time the stream
+  resumes onto a base image, so that viewers are given accurate
+  downtime messaging without slates being produced manually. Slates
+  are encoded as H.264, as required by vidforward.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Dimensions of generated slates, which must be multiples of the
+// 16 pixel H.264 macroblock size. Base images are scaled to fill them.
+const (
+	slateWidth  = 1280
+	slateHeight = 720
+)
+
+// slateFrames is the number of frames of a generated slate, which is
+// looped by vidforward at 25 frames per second. Only the first frame
+// is a keyframe, so this is the keyframe interval.
+const slateFrames = 100
+
+// slateText holds the dynamic text of a generated slate.
+type slateText struct {
+	Site    string    // Site name.
+	Resumes time.Time // Time the stream resumes, or zero if unknown.
+	Now     time.Time // Current local time.
+}
+
+// lines returns the lines of text of the slate, the first of which is
+// the heading.
+func (st slateText) lines() []string {
+	lines := []string{st.Site}
+	if !st.Resumes.IsZero() {
+		when := st.Resumes.Format("15:04 MST")
+		if !sameDay(st.Resumes, st.Now) {
+			when += st.Resumes.Format(" Mon 2 Jan")
+		}
+		return append(lines, "Stream resumes at "+when)
+	}
+	return append(lines, "Stream will resume soon")
+}
+
+// sameDay returns true if t and u fall on the same date in t's location.
+func sameDay(t, u time.Time) bool {
+	u = u.In(t.Location())
+	return t.YearDay() == u.YearDay() && t.Year() == u.Year()
+}
+
+// generateSlate renders a slate and returns it encoded as H.264.
+func generateSlate(base image.Image, st slateText) ([]byte, error) {
+	img, err := renderSlate(base, st)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSlate(img, slateFrames), nil
+}
+
+// renderSlate renders the slate text centred on a darkened band
+// across the base image, which is scaled and cropped to fill the
+// slate, or a plain background if base is nil.
+func renderSlate(base image.Image, st slateText) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, slateWidth, slateHeight))
+	if base != nil {
+		xdraw.ApproxBiLinear.Scale(img, img.Bounds(), base, coverRect(base.Bounds(), img.Bounds()), draw.Src, nil)
+	} else {
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0x0b, 0x2a, 0x4a, 0xff}), image.Point{}, draw.Src)
+	}
+
+	h := img.Bounds().Dy()
+	heading, err := newFace(gobold.TTF, float64(h)/12)
+	if err != nil {
+		return nil, err
+	}
+	defer heading.Close()
+	body, err := newFace(goregular.TTF, float64(h)/20)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	lines := st.lines()
+	faces := make([]font.Face, len(lines))
+	var height int
+	for i := range lines {
+		faces[i] = body
+		if i == 0 {
+			faces[i] = heading
+		}
+		height += faces[i].Metrics().Height.Ceil()
+	}
+
+	// Darken a band behind the text so that it's legible on any image.
+	pad := h / 20
+	top := (h-height)/2 - pad
+	band := image.Rect(0, top, img.Bounds().Dx(), top+height+2*pad)
+	draw.Draw(img, band, image.NewUniform(color.RGBA{0, 0, 0, 0xa0}), image.Point{}, draw.Over)
+
+	y := top + pad
+	for i, line := range lines {
+		m := faces[i].Metrics()
+		d := &font.Drawer{Dst: img, Src: image.White, Face: faces[i]}
+		w := d.MeasureString(line).Ceil()
+		d.Dot = fixed.P((img.Bounds().Dx()-w)/2, y+m.Ascent.Ceil())
+		d.DrawString(line)
+		y += m.Height.Ceil()
+	}
+
+	return img, nil
+}
+
+// coverRect returns the centred part of src with the aspect ratio of
+// dst, so that src scaled to dst is not distorted.
+func coverRect(src, dst image.Rectangle) image.Rectangle {
+	w, h := src.Dx(), src.Dy()
+	if w*dst.Dy() > h*dst.Dx() {
+		w = h * dst.Dx() / dst.Dy()
+	} else {
+		h = w * dst.Dy() / dst.Dx()
+	}
+	min := src.Min.Add(image.Pt((src.Dx()-w)/2, (src.Dy()-h)/2))
+	return image.Rectangle{min, min.Add(image.Pt(w, h))}
+}
+
+// newFace returns a face of the given size for a TrueType font.
+func newFace(ttf []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse font: %w", err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("could not create font face: %w", err)
+	}
+	return face, nil
+}
+
+// generatedSlateName returns the name of the broadcast's generated
+// slate file, which is unique to its camera.
+func generatedSlateName(cfg *BroadcastConfig) string {
+	return "generated-" + strings.ReplaceAll(model.MacDecode(cfg.CameraMac), ":", "") + ".h264"
+}
+
+// nextStart returns the next start of the broadcast after t, in the
+// location of t, preserving its local time of day.
+func nextStart(cfg *BroadcastConfig, t time.Time) time.Time {
+	if cfg.Start.IsZero() {
+		return time.Time{}
+	}
+	start := cfg.Start.In(t.Location())
+	for !start.After(t) {
+		start = start.AddDate(0, 0, 1)
+	}
+	for start.AddDate(0, 0, -1).After(t) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// slateImage gets the base image of the broadcast's generated slates,
+// or nil if it does not have one.
+func slateImage(cfg *BroadcastConfig) (image.Image, error) {
+	if cfg.SlateImageURL == "" {
+		return nil, nil
+	}
+	const timeout = 30 * time.Second
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(cfg.SlateImageURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not get slate image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get slate image: status %s", resp.Status)
+	}
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode slate image: %w", err)
+	}
+	return img, nil
+}
+
+// generateSlateIfChanged generates, uploads and displays a new slate
+// if the generated slate is not displayed or its content has changed.
+func (sm *broadcastStateMachine) generateSlateIfChanged(t time.Time) {
+	s := sm.currentState.(*vidforwardPermanentSlate)
+	name := generatedSlateName(sm.ctx.cfg)
+
+	st := slateText{Now: t.In(broadcastLocation(sm.ctx.store, sm.ctx.cfg, sm.log))}
+	st.Resumes = nextStart(sm.ctx.cfg, st.Now)
+	st.Site = sm.ctx.cfg.Name
+	site, err := model.GetSite(context.Background(), sm.ctx.store, sm.ctx.cfg.SKey)
+	if err != nil {
+		sm.log("could not get site for generated slate: %v", err)
+	} else if site.Name != "" {
+		st.Site = site.Name
+	}
+	content := strings.Join(append(st.lines(), sm.ctx.cfg.SlateImageURL), "\n")
+	if s.Slate == name && s.Generated == content {
+		return
+	}
+
+	base, err := slateImage(sm.ctx.cfg)
+	if err != nil {
+		sm.log("could not get base image of generated slate, using plain background: %v", err)
+	}
+	slate, err := generateSlate(base, st)
+	if !try(err, "could not generate slate", sm.log) {
+		return
+	}
+
+	if !try(sm.ctx.fwd.UploadSlate(sm.ctx.cfg, name, bytes.NewReader(slate)), "could not upload generated slate", sm.log) {
+		return
+	}
+	if try(sm.ctx.fwd.Slate(sm.ctx.cfg, WithSlate(name)), "could not set generated slate", sm.log) {
+		s.Slate = name
+		s.Generated = content
+	}
+}
+
+// encodeSlate encodes an image as an H.264 Annex B byte stream of the
+// given number of frames, for vidforward to loop. Since the image is
+// still, the first frame is an IDR picture of uncompressed (I_PCM)
+// macroblocks and the rest are P pictures which skip every macroblock,
+// which repeats the first. This avoids the need for an H.264 encoder,
+// at the cost of a large keyframe. The image's dimensions must be
+// multiples of 16.
+func encodeSlate(img *image.RGBA, frames int) []byte {
+	const (
+		log2MaxFrameNum = 8 // Enough for frame_num to count frames.
+		idrHeader       = 0x65
+		sliceHeader     = 0x41
+		spsHeader       = 0x67
+		ppsHeader       = 0x68
+		typeI           = 7 // All slices of the picture are I slices.
+		typeP           = 5 // All slices of the picture are P slices.
+		mbTypeIPCM      = 25
+	)
+	mbw, mbh := img.Bounds().Dx()/16, img.Bounds().Dy()/16
+
+	// Sequence parameter set for constrained baseline profile.
+	var w h264Writer
+	w.u(8, 66)                // profile_idc.
+	w.u(8, 0xc0)              // constraint_set0_flag and constraint_set1_flag.
+	w.u(8, 40)                // level_idc.
+	w.ue(0)                   // seq_parameter_set_id.
+	w.ue(log2MaxFrameNum - 4) // log2_max_frame_num_minus4.
+	w.ue(2)                   // pic_order_cnt_type.
+	w.ue(1)                   // max_num_ref_frames.
+	w.u(1, 0)                 // gaps_in_frame_num_value_allowed_flag.
+	w.ue(uint64(mbw - 1))     // pic_width_in_mbs_minus1.
+	w.ue(uint64(mbh - 1))     // pic_height_in_map_units_minus1.
+	w.u(1, 1)                 // frame_mbs_only_flag.
+	w.u(1, 1)                 // direct_8x8_inference_flag.
+	w.u(1, 0)                 // frame_cropping_flag.
+	w.u(1, 0)                 // vui_parameters_present_flag.
+	w.trailing()
+	out := appendNAL(nil, spsHeader, w.buf)
+
+	// Picture parameter set.
+	w = h264Writer{}
+	w.ue(0)   // pic_parameter_set_id.
+	w.ue(0)   // seq_parameter_set_id.
+	w.u(1, 0) // entropy_coding_mode_flag, i.e., CAVLC.
+	w.u(1, 0) // bottom_field_pic_order_in_frame_present_flag.
+	w.ue(0)   // num_slice_groups_minus1.
+	w.ue(0)   // num_ref_idx_l0_default_active_minus1.
+	w.ue(0)   // num_ref_idx_l1_default_active_minus1.
+	w.u(1, 0) // weighted_pred_flag.
+	w.u(2, 0) // weighted_bipred_idc.
+	w.se(0)   // pic_init_qp_minus26.
+	w.se(0)   // pic_init_qs_minus26.
+	w.se(0)   // chroma_qp_index_offset.
+	w.u(1, 1) // deblocking_filter_control_present_flag.
+	w.u(1, 0) // constrained_intra_pred_flag.
+	w.u(1, 0) // redundant_pic_cnt_present_flag.
+	w.trailing()
+	out = appendNAL(out, ppsHeader, w.buf)
+
+	// IDR picture of I_PCM macroblocks.
+	w = h264Writer{}
+	w.ue(0)                 // first_mb_in_slice.
+	w.ue(typeI)             // slice_type.
+	w.ue(0)                 // pic_parameter_set_id.
+	w.u(log2MaxFrameNum, 0) // frame_num.
+	w.ue(0)                 // idr_pic_id.
+	w.u(1, 0)               // no_output_of_prior_pics_flag.
+	w.u(1, 0)               // long_term_reference_flag.
+	w.se(0)                 // slice_qp_delta.
+	w.ue(1)                 // disable_deblocking_filter_idc.
+	y, cb, cr := make([]byte, 256), make([]byte, 64), make([]byte, 64)
+	for my := 0; my < mbh; my++ {
+		for mx := 0; mx < mbw; mx++ {
+			w.ue(mbTypeIPCM)
+			w.align() // pcm_alignment_zero_bit.
+			pcmSamples(img, mx*16, my*16, y, cb, cr)
+			w.buf = append(append(append(w.buf, y...), cb...), cr...)
+		}
+	}
+	w.trailing()
+	out = appendNAL(out, idrHeader, w.buf)
+
+	// P pictures which skip all macroblocks.
+	for i := 1; i < frames; i++ {
+		frameNum := uint64(i) % (1 << log2MaxFrameNum)
+		w = h264Writer{}
+		w.ue(0)                        // first_mb_in_slice.
+		w.ue(typeP)                    // slice_type.
+		w.ue(0)                        // pic_parameter_set_id.
+		w.u(log2MaxFrameNum, frameNum) // frame_num.
+		w.u(1, 0)                      // num_ref_idx_active_override_flag.
+		w.u(1, 0)                      // ref_pic_list_modification_flag_l0.
+		w.u(1, 0)                      // adaptive_ref_pic_marking_mode_flag.
+		w.se(0)                        // slice_qp_delta.
+		w.ue(1)                        // disable_deblocking_filter_idc.
+		w.ue(uint64(mbw * mbh))        // mb_skip_run.
+		w.trailing()
+		out = appendNAL(out, sliceHeader, w.buf)
+	}
+	return out
+}
+
+// pcmSamples converts the 16x16 macroblock of an image at x, y to
+// BT.601 limited range YCbCr 4:2:0 samples.
+func pcmSamples(img *image.RGBA, x, y int, luma, cb, cr []byte) {
+	for j := 0; j < 16; j++ {
+		for i := 0; i < 16; i++ {
+			c := img.RGBAAt(x+i, y+j)
+			r, g, b := int(c.R), int(c.G), int(c.B)
+			luma[j*16+i] = byte(16 + (66*r+129*g+25*b+128)>>8)
+		}
+	}
+	for j := 0; j < 8; j++ {
+		for i := 0; i < 8; i++ {
+			var r, g, b int
+			for _, d := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				c := img.RGBAAt(x+2*i+d.X, y+2*j+d.Y)
+				r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+			}
+			r, g, b = r/4, g/4, b/4
+			cb[j*8+i] = byte(128 + (-38*r-74*g+112*b+128)>>8)
+			cr[j*8+i] = byte(128 + (112*r-94*g-18*b+128)>>8)
+		}
+	}
+}
+
+// h264Writer writes the bits of an H.264 raw byte sequence payload.
+type h264Writer struct {
+	buf []byte
+	n   int // Number of bits written to the last byte, or 0 if byte aligned.
+}
+
+// u writes the n least significant bits of v.
+func (w *h264Writer) u(n int, v uint64) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte(v>>i&1) << (7 - w.n)
+		w.n = (w.n + 1) % 8
+	}
+}
+
+// ue writes v as an unsigned Exp-Golomb code.
+func (w *h264Writer) ue(v uint64) {
+	n := bits.Len64(v + 1)
+	w.u(n-1, 0)
+	w.u(n, v+1)
+}
+
+// se writes v as a signed Exp-Golomb code.
+func (w *h264Writer) se(v int64) {
+	if v > 0 {
+		w.ue(uint64(2*v - 1))
+	} else {
+		w.ue(uint64(-2 * v))
+	}
+}
+
+// align writes zero bits until byte aligned.
+func (w *h264Writer) align() {
+	for w.n != 0 {
+		w.u(1, 0)
+	}
+}
+
+// trailing writes the RBSP trailing bits.
+func (w *h264Writer) trailing() {
+	w.u(1, 1)
+	w.align()
+}
+
+// appendNAL appends a NAL unit with the given header byte and raw
+// byte sequence payload to an Annex B byte stream, preceded by a start
+// code and with emulation prevention bytes inserted.
+func appendNAL(dst []byte, header byte, rbsp []byte) []byte {
+	dst = append(dst, 0, 0, 0, 1, header)
+	var zeros int
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 3 {
+			dst = append(dst, 3)
+			zeros = 0
+		}
+		dst = append(dst, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return dst
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+	"time"
+
+	h264bits "github.com/ausocean/av/codec/h264/h264dec/bits"
+)
+
+func TestSlateTextLines(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	now := time.Date(2026, 10, 16, 21, 5, 0, 0, loc)
+
+	tests := []struct {
+		resumes time.Time
+		want    []string
+	}{
+		{
+			resumes: time.Date(2026, 10, 16, 22, 0, 0, 0, loc),
+			want:    []string{"Rapid Bay", "Stream resumes at 22:00 ACDT"},
+		},
+		{
+			resumes: time.Date(2026, 10, 17, 6, 30, 0, 0, loc),
+			want:    []string{"Rapid Bay", "Stream resumes at 06:30 ACDT Sat 17 Oct"},
+		},
+		{
+			want: []string{"Rapid Bay", "Stream will resume soon"},
+		},
+	}
+
+	for i, test := range tests {
+		got := slateText{Site: "Rapid Bay", Resumes: test.resumes, Now: now}.lines()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: got lines %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestRenderSlate(t *testing.T) {
+	now := time.Date(2026, 10, 16, 21, 5, 0, 0, time.UTC)
+	st := slateText{Site: "Rapid Bay", Resumes: now.Add(time.Hour), Now: now}
+
+	base := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			base.Set(x, y, color.RGBA{0x20, 0x80, 0x20, 0xff})
+		}
+	}
+
+	tests := []struct {
+		desc string
+		base image.Image
+		want color.RGBA // Colour of the slate's corner.
+	}{
+		{desc: "plain background", want: color.RGBA{0x0b, 0x2a, 0x4a, 0xff}},
+		{desc: "base image", base: base, want: color.RGBA{0x20, 0x80, 0x20, 0xff}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			img, err := renderSlate(test.base, st)
+			if err != nil {
+				t.Fatalf("renderSlate returned error: %v", err)
+			}
+			if img.Bounds().Dx() != slateWidth || img.Bounds().Dy() != slateHeight {
+				t.Errorf("got slate of size %v, want %dx%d", img.Bounds().Size(), slateWidth, slateHeight)
+			}
+			if got := img.RGBAAt(0, 0); got != test.want {
+				t.Errorf("got corner colour %v, want %v", got, test.want)
+			}
+
+			// Text is drawn in white, so the centre band should have white pixels.
+			var white bool
+			for x := 0; x < slateWidth && !white; x++ {
+				for y := slateHeight / 3; y < 2*slateHeight/3; y++ {
+					c := img.RGBAAt(x, y)
+					if c.R > 0xe0 && c.G > 0xe0 && c.B > 0xe0 {
+						white = true
+						break
+					}
+				}
+			}
+			if !white {
+				t.Errorf("no text found on slate")
+			}
+		})
+	}
+}
+
+// nalUnits splits an Annex B byte stream into NAL units, with their
+// emulation prevention bytes removed.
+func nalUnits(b []byte) [][]byte {
+	var nals [][]byte
+	for _, nal := range bytes.Split(b, []byte{0, 0, 0, 1})[1:] {
+		nals = append(nals, bytes.ReplaceAll(nal, []byte{0, 0, 3}, []byte{0, 0}))
+	}
+	return nals
+}
+
+// readUe reads an unsigned Exp-Golomb code.
+func readUe(t *testing.T, br *h264bits.BitReader) uint64 {
+	var zeros int
+	for {
+		b, err := br.ReadBits(1)
+		if err != nil {
+			t.Fatalf("could not read bit: %v", err)
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+	}
+	v, err := br.ReadBits(zeros)
+	if err != nil {
+		t.Fatalf("could not read bits: %v", err)
+	}
+	return 1<<zeros - 1 + v
+}
+
+func TestEncodeSlate(t *testing.T) {
+	const width, height, frames = 64, 48, 5
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(4 * x), uint8(5 * y), 0x80, 0xff})
+		}
+	}
+
+	nals := nalUnits(encodeSlate(img, frames))
+	if len(nals) != 2+frames {
+		t.Fatalf("got %d NAL units, want %d", len(nals), 2+frames)
+	}
+	wantTypes := []byte{7, 8, 5, 1, 1, 1, 1}
+	for i, nal := range nals {
+		if typ := nal[0] & 0x1f; typ != wantTypes[i] {
+			t.Errorf("NAL unit %d has type %d, want %d", i, typ, wantTypes[i])
+		}
+	}
+
+	br := h264bits.NewBitReader(bytes.NewReader(nals[0][1:]))
+	profile, _ := br.ReadBits(8)
+	br.ReadBits(16) // Constraint flags and level_idc.
+	var sps []uint64
+	for i := 0; i < 6; i++ {
+		if i == 4 {
+			br.ReadBits(1) // gaps_in_frame_num_value_allowed_flag.
+		}
+		sps = append(sps, readUe(t, br))
+	}
+	// seq_parameter_set_id, log2_max_frame_num_minus4, pic_order_cnt_type, max_num_ref_frames,
+	// pic_width_in_mbs_minus1 and pic_height_in_map_units_minus1.
+	wantSPS := []uint64{0, 4, 2, 1, width/16 - 1, height/16 - 1}
+	if profile != 66 || !reflect.DeepEqual(sps, wantSPS) {
+		t.Errorf("got SPS profile %d and fields %v, want baseline and %v", profile, sps, wantSPS)
+	}
+
+	// Check the PCM samples of each macroblock of the IDR picture.
+	br = h264bits.NewBitReader(bytes.NewReader(nals[2][1:]))
+	for _, want := range []uint64{0, 7, 0} { // first_mb_in_slice, slice_type, pic_parameter_set_id.
+		if got := readUe(t, br); got != want {
+			t.Fatalf("got slice header field %d, want %d", got, want)
+		}
+	}
+	frameNum, _ := br.ReadBits(8)
+	idrPicID := readUe(t, br)
+	br.ReadBits(2)              // no_output_of_prior_pics_flag and long_term_reference_flag.
+	qpDelta := readUe(t, br)    // slice_qp_delta of 0.
+	deblocking := readUe(t, br) // disable_deblocking_filter_idc.
+	if frameNum != 0 || idrPicID != 0 || qpDelta != 0 || deblocking != 1 {
+		t.Fatalf("unexpected IDR slice header: frame_num %d, idr_pic_id %d, slice_qp_delta %d, disable_deblocking_filter_idc %d", frameNum, idrPicID, qpDelta, deblocking)
+	}
+	y, cb, cr := make([]byte, 256), make([]byte, 64), make([]byte, 64)
+	for mb := 0; mb < width*height/256; mb++ {
+		if typ := readUe(t, br); typ != 25 {
+			t.Fatalf("macroblock %d has type %d, want I_PCM", mb, typ)
+		}
+		for !br.ByteAligned() {
+			br.ReadBits(1)
+		}
+		pcmSamples(img, mb%(width/16)*16, mb/(width/16)*16, y, cb, cr)
+		for i, want := range append(append(y, cb...), cr...) {
+			got, err := br.ReadBits(8)
+			if err != nil {
+				t.Fatalf("could not read sample: %v", err)
+			}
+			if byte(got) != want {
+				t.Fatalf("macroblock %d sample %d is %d, want %d", mb, i, got, want)
+			}
+		}
+	}
+
+	// Each P picture skips all macroblocks.
+	for i, nal := range nals[3:] {
+		br := h264bits.NewBitReader(bytes.NewReader(nal[1:]))
+		readUe(t, br) // first_mb_in_slice.
+		if typ := readUe(t, br); typ != 5 {
+			t.Errorf("P picture %d has slice type %d", i, typ)
+		}
+		readUe(t, br) // pic_parameter_set_id.
+		frameNum, _ := br.ReadBits(8)
+		if frameNum != uint64(i+1) {
+			t.Errorf("P picture %d has frame_num %d, want %d", i, frameNum, i+1)
+		}
+		br.ReadBits(3) // Flags.
+		readUe(t, br)  // slice_qp_delta.
+		readUe(t, br)  // disable_deblocking_filter_idc.
+		if run := readUe(t, br); run != width*height/256 {
+			t.Errorf("P picture %d skips %d macroblocks, want %d", i, run, width*height/256)
+		}
+	}
+}
+
+func TestH264WriterExpGolomb(t *testing.T) {
+	var w h264Writer
+	w.ue(0)  // 1
+	w.ue(3)  // 00100
+	w.se(-2) // 00101
+	w.trailing()
+	want := []byte{0b10010000, 0b10110000}
+	if !bytes.Equal(w.buf, want) {
+		t.Errorf("got %08b, want %08b", w.buf, want)
+	}
+}
+
+func TestNextStart(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	start := time.Date(2026, 10, 1, 7, 0, 0, 0, loc).UTC()
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{now: time.Date(2026, 10, 16, 6, 0, 0, 0, loc), want: time.Date(2026, 10, 16, 7, 0, 0, 0, loc)},
+		{now: time.Date(2026, 10, 16, 21, 0, 0, 0, loc), want: time.Date(2026, 10, 17, 7, 0, 0, 0, loc)},
+		{now: time.Date(2026, 9, 20, 21, 0, 0, 0, loc), want: time.Date(2026, 9, 21, 7, 0, 0, 0, loc)},
+	}
+
+	for i, test := range tests {
+		got := nextStart(&BroadcastConfig{Start: start}, test.now)
+		if !got.Equal(test.want) {
+			t.Errorf("test %d: got next start %v, want %v", i, got, test.want)
+		}
+	}
+	if got := nextStart(&BroadcastConfig{}, time.Now()); !got.IsZero() {
+		t.Errorf("got next start %v for broadcast without start, want zero", got)
+	}
+}
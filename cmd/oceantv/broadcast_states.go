@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/cloud/notify"
 )
 
@@ -44,10 +45,39 @@ const (
 	broadcastNetwork       notify.Kind = "broadcast-network"       // Problems related to bad bandwidth, generally indicated by bad health events.
 	broadcastSoftware      notify.Kind = "broadcast-software"      // Problems related to the functioning of our broadcast software.
 	broadcastConfiguration notify.Kind = "broadcast-configuration" // Problems related to the configuration of the broadcast.
+	broadcastReadiness     notify.Kind = "broadcast-readiness"     // Problems found by readiness checks before the broadcast starts.
+	broadcastOverrun       notify.Kind = "broadcast-overrun"       // Broadcasts forcibly finished after continuing past their end.
 )
 
 var errNoGlobalNotifier = errors.New("global notifier is nil")
 
+// notifyContext returns a copy of ctx carrying the broadcast for
+// notifications, along with the broadcast's additional recipients and
+// channel overrides, which are merged with those of the site.
+func notifyContext(ctx context.Context, cfg *BroadcastConfig) context.Context {
+	return notify.NewContext(ctx, &notify.Data{
+		Broadcast:  cfg,
+		Recipients: strings.Split(cfg.NotifyRecipients, ","),
+		Routes:     parseNotifyRoutes(cfg.NotifyRoutes),
+	})
+}
+
+// parseNotifyRoutes parses a broadcast's channel overrides, which are
+// semicolon-separated channels, each optionally followed by a colon
+// and comma-separated recipients, e.g., "sms:+61400000000;slack".
+func parseNotifyRoutes(s string) []model.NotificationRoute {
+	var routes []model.NotificationRoute
+	for _, r := range strings.Split(s, ";") {
+		ch, recipients, _ := strings.Cut(r, ":")
+		ch = strings.TrimSpace(ch)
+		if ch == "" {
+			continue
+		}
+		routes = append(routes, model.NotificationRoute{Channel: ch, Recipients: strings.TrimSpace(recipients)})
+	}
+	return routes
+}
+
 func (ctx *broadcastContext) logAndNotify(kind notify.Kind, msg string, args ...interface{}) {
 	ctx.log(msg, args...)
 	// If context has nil notifier, use global notifier
@@ -58,7 +88,7 @@ func (ctx *broadcastContext) logAndNotify(kind notify.Kind, msg string, args ...
 		}
 		ctx.notifier = notifier
 	}
-	err := ctx.notifier.Send(context.Background(), ctx.cfg.SKey, kind, fmtForBroadcastLog(ctx.cfg, msg, args...))
+	err := ctx.notifier.Send(notifyContext(context.Background(), ctx.cfg), ctx.cfg.SKey, kind, fmtForBroadcastLog(ctx.cfg, msg, args...))
 	if err != nil {
 		ctx.log("could not send health notification: %v", err)
 	}
@@ -129,8 +159,10 @@ type liveState interface {
 	stateWithHealth
 	lastStatusCheck() time.Time
 	lastChatMsg() time.Time
+	statusInterval() time.Duration
 	setLastStatusCheck(time.Time)
 	setLastChatMsg(time.Time)
+	setStatusInterval(time.Duration)
 }
 
 type stateWithHealthFields struct {
@@ -144,12 +176,15 @@ type liveStateFields struct {
 	stateWithHealthFields
 	LastStatusCheck time.Time
 	LastChatMsg     time.Time
+	StatusInterval  time.Duration // Current interval between status checks, which adapts to stream health.
 }
 
-func (s *liveStateFields) lastStatusCheck() time.Time     { return s.LastStatusCheck }
-func (s *liveStateFields) lastChatMsg() time.Time         { return s.LastChatMsg }
-func (s *liveStateFields) setLastStatusCheck(t time.Time) { s.LastStatusCheck = t }
-func (s *liveStateFields) setLastChatMsg(t time.Time)     { s.LastChatMsg = t }
+func (s *liveStateFields) lastStatusCheck() time.Time        { return s.LastStatusCheck }
+func (s *liveStateFields) lastChatMsg() time.Time            { return s.LastChatMsg }
+func (s *liveStateFields) statusInterval() time.Duration     { return s.StatusInterval }
+func (s *liveStateFields) setLastStatusCheck(t time.Time)    { s.LastStatusCheck = t }
+func (s *liveStateFields) setLastChatMsg(t time.Time)        { s.LastChatMsg = t }
+func (s *liveStateFields) setStatusInterval(d time.Duration) { s.StatusInterval = d }
 
 type vidforwardPermanentStarting struct {
 	stateFields
@@ -212,7 +247,11 @@ func (s *vidforwardPermanentTransitionLiveToSlate) enter() {
 	s.LastEntered = time.Now()
 
 	s.bus.publish(hardwareStopRequestEvent{})
-	try(s.fwd.Slate(s.cfg), "could not set vidforward mode to slate", s.log)
+	slate, err := scheduledSlate(s.cfg, s.LastEntered, broadcastLocation(s.store, s.cfg, s.log))
+	if err != nil {
+		s.log("could not get scheduled slate: %v", err)
+	}
+	try(s.fwd.Slate(s.cfg, WithSlate(slate)), "could not set vidforward mode to slate", s.log)
 }
 func (s *vidforwardPermanentTransitionLiveToSlate) isHardwareStopped() bool {
 	return s.cfg.HardwareState == hardwareStateToString(&hardwareOff{})
@@ -291,7 +330,12 @@ func (s *vidforwardPermanentFailure) requestSlate() {
 	try(s.fwd.Slate(s.cfg), "could not set vidforward mode to slate", s.log)
 }
 
-type vidforwardPermanentSlate struct{ stateFields }
+type vidforwardPermanentSlate struct {
+	stateFields
+	Slate       string // Scheduled or generated slate currently displayed, or empty for the default slate.
+	Generated   string // Content of the displayed slate, if it was generated.
+	ScheduleErr string // Slate schedule error last notified, if any.
+}
 
 func newVidforwardPermanentSlate() *vidforwardPermanentSlate { return &vidforwardPermanentSlate{} }
 
@@ -304,7 +348,7 @@ func newVidforwardPermanentVoltageRecoverySlate(ctx *broadcastContext) *vidforwa
 	return &vidforwardPermanentVoltageRecoverySlate{
 		stateWithTimeoutFields: newStateWithTimeoutFieldsWithTimeout(
 			ctx,
-			time.Duration(sanatisedVoltageRecoveryTimeout(ctx))*time.Hour,
+			voltageRecoveryTimeout(ctx, time.Now()),
 		),
 	}
 }
@@ -356,9 +400,6 @@ type vidforwardSecondaryLive struct {
 func newVidforwardSecondaryLive(ctx *broadcastContext) *vidforwardSecondaryLive {
 	return &vidforwardSecondaryLive{broadcastContext: ctx}
 }
-func (s *vidforwardSecondaryLive) exit() {
-	try(s.man.StopBroadcast(context.Background(), s.cfg, s.store, s.svc), "could not stop broadcast exiting secondary live", s.log)
-}
 
 type vidforwardSecondaryLiveUnhealthy struct {
 	stateFields
@@ -406,6 +447,7 @@ func newVidforwardSecondaryIdle(ctx *broadcastContext) *vidforwardSecondaryIdle
 	return &vidforwardSecondaryIdle{broadcastContext: ctx}
 }
 func (s *vidforwardSecondaryIdle) enter() {
+	try(s.man.StopBroadcast(context.Background(), s.cfg, s.store, s.svc), "could not stop broadcast on secondary idle entry", s.log)
 	s.bus.publish(hardwareStopRequestEvent{})
 }
 
@@ -494,7 +536,42 @@ func (s *directFailure) enter() {
 	s.bus.publish(hardwareStopRequestEvent{})
 }
 
+// draining is the state of a broadcast that has finished, but whose
+// hardware stays on and stream continues for the broadcast's power off
+// delay, so that the tail of the broadcast is not cut from its archive.
+// Once the delay has elapsed, the broadcast finishes as it would have
+// without a delay; see finishedState.
+type draining struct {
+	stateFields
+	stateWithTimeoutFields
+}
+
+func newDraining(ctx *broadcastContext) *draining {
+	return &draining{stateWithTimeoutFields: newStateWithTimeoutFieldsWithTimeout(ctx, time.Duration(ctx.cfg.PowerOffDelay)*time.Minute)}
+}
+func (s *draining) enter() {
+	s.LastEntered = time.Now()
+	s.log("draining for %d minutes before powering off", s.cfg.PowerOffDelay)
+}
+
+// finishedState returns the state that a finished broadcast of the
+// context's kind transitions to, i.e., the slate for permanent
+// broadcasts, and idle otherwise.
+func finishedState(ctx *broadcastContext) state {
+	switch {
+	case strings.Contains(ctx.cfg.Name, secondaryBroadcastPostfix):
+		return newVidforwardSecondaryIdle(ctx)
+	case ctx.cfg.UsingVidforward:
+		return newVidforwardPermanentTransitionLiveToSlate(ctx)
+	default:
+		return newDirectIdle(ctx)
+	}
+}
+
 func updateBroadcastBasedOnState(state state, cfg *BroadcastConfig) {
+	// Only the draining state drains.
+	cfg.Draining = false
+
 	switch state.(type) {
 	case *vidforwardPermanentLive:
 		cfg.Active = true
@@ -650,6 +727,15 @@ func updateBroadcastBasedOnState(state state, cfg *BroadcastConfig) {
 		cfg.Unhealthy = false
 		cfg.Transitioning = false
 		cfg.InFailure = false
+	case *draining:
+		// Draining is common to all kinds of broadcast, so UsingVidforward is unchanged.
+		cfg.Active = true
+		cfg.Slate = false
+		cfg.AttemptingToStart = false
+		cfg.Unhealthy = false
+		cfg.Transitioning = false
+		cfg.InFailure = false
+		cfg.Draining = true
 	default:
 		panic(fmt.Sprintf("unknown state: %v", stateToString(state)))
 	}
@@ -672,9 +758,12 @@ func broadcastCfgToState(ctx *broadcastContext) state {
 		transitioning     = ctx.cfg.Transitioning
 		inFailure         = ctx.cfg.InFailure
 		recoveringVoltage = ctx.cfg.RecoveringVoltage
+		draining          = ctx.cfg.Draining
 	)
 	var newState state
 	switch {
+	case draining && active && !starting && !inFailure:
+		newState = newDraining(ctx)
 	case vid && !slate && !unhealthy && starting && !isSecondary && !inFailure:
 		newState = newVidforwardPermanentStarting(ctx)
 	case vid && active && !slate && !unhealthy && !starting && !isSecondary && !transitioning && !inFailure:
@@ -5,6 +5,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/ausocean/cloud/model"
 )
 
 func TestUpdateBroadcastBasedOnState(t *testing.T) {
@@ -190,6 +192,15 @@ func TestUpdateBroadcastBasedOnState(t *testing.T) {
 				StateData:         marshal(&directStarting{}),
 			},
 		},
+		{
+			name:  "draining",
+			state: &draining{},
+			expectedCfg: BroadcastConfig{
+				Active:    true,
+				Draining:  true,
+				StateData: marshal(&draining{}),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -295,6 +306,16 @@ func TestBroadcastCfgToState(t *testing.T) {
 			cfg:  BroadcastConfig{Name: "", UsingVidforward: false, Active: false, Slate: false, Unhealthy: false, AttemptingToStart: true, Transitioning: false},
 			want: newDirectStarting(ctx),
 		},
+		{
+			name: "Vidforward Permanent Draining",
+			cfg:  BroadcastConfig{Name: "", UsingVidforward: true, Active: true, Draining: true},
+			want: &draining{},
+		},
+		{
+			name: "Direct Draining",
+			cfg:  BroadcastConfig{Name: "", UsingVidforward: false, Active: true, Draining: true},
+			want: &draining{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -493,3 +514,26 @@ func TestRateLimited(t *testing.T) {
 	}
 
 }
+
+func TestParseNotifyRoutes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []model.NotificationRoute
+	}{
+		{in: "", want: nil},
+		{in: "slack", want: []model.NotificationRoute{{Channel: "slack"}}},
+		{
+			in: "sms: +61400000000,+61411111111 ; ;pagerduty",
+			want: []model.NotificationRoute{
+				{Channel: "sms", Recipients: "+61400000000,+61411111111"},
+				{Channel: "pagerduty"},
+			},
+		},
+	}
+	for _, test := range tests {
+		got := parseNotifyRoutes(test.in)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseNotifyRoutes(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
@@ -27,8 +27,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -39,6 +41,14 @@ import (
 	"github.com/ausocean/openfish/datastore"
 )
 
+// TestBroadcastConfigFields tests that BroadcastConfig mirrors model.BroadcastConfig.
+func TestBroadcastConfigFields(t *testing.T) {
+	err := model.MatchBroadcastConfig(reflect.TypeOf(BroadcastConfig{}))
+	if err != nil {
+		t.Errorf("BroadcastConfig does not match model.BroadcastConfig: %v", err)
+	}
+}
+
 // TestRemoveDate tests the removeDate helper function.
 func TestRemoveDate(t *testing.T) {
 	tests := []struct {
@@ -59,6 +69,43 @@ func TestRemoveDate(t *testing.T) {
 	}
 }
 
+// TestScheduledSlate tests selection of slates from a slate schedule.
+func TestScheduledSlate(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	at := func(h, m int) time.Time { return time.Date(2026, 6, 1, h, m, 0, 0, loc) }
+	cfg := &BroadcastConfig{SlateSchedule: "20:00-06:00=back-at-dawn.mp4; 12:00-13:00=lunch.mp4"}
+
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{t: at(21, 0), want: "back-at-dawn.mp4"},
+		{t: at(5, 59), want: "back-at-dawn.mp4"},
+		{t: at(6, 0), want: ""},
+		{t: at(12, 30), want: "lunch.mp4"},
+		{t: at(13, 0), want: ""},
+	}
+	for i, test := range tests {
+		got, err := scheduledSlate(cfg, test.t, loc)
+		if err != nil {
+			t.Fatalf("scheduledSlate returned error: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("test %d: got slate %q, want %q", i, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"20:00=slate", "20:00-06:00", "8pm-6am=slate"} {
+		_, err := scheduledSlate(&BroadcastConfig{SlateSchedule: bad}, at(0, 0), loc)
+		if err == nil {
+			t.Errorf("expected error for slate schedule %q", bad)
+		}
+	}
+}
+
 // dummyManager is a dummy implementation of the broadcastManager interface.
 type dummyManager struct {
 	cfg                                                                *Cfg
@@ -197,7 +244,7 @@ func WithTokenBucketLimiter(limiter *OceanTokenBucketLimiter) dummyStoreOption {
 	}
 }
 
-func (d *dummyStore) IDKey(kind string, id int64) *Key { return nil }
+func (d *dummyStore) IDKey(kind string, id int64) *Key { return &Key{Kind: kind, ID: id} }
 func (d *dummyStore) NameKey(kind, name string) *Key   { return nil }
 func (d *dummyStore) IncompleteKey(kind string) *Key   { return nil }
 func (d *dummyStore) Get(ctx Ctx, key *Key, dst Ety) error {
@@ -230,8 +277,11 @@ func (d *dummyStore) Delete(ctx Ctx, key *Key) error                        { re
 // dummyService is a dummy implementation of the BroadcastService interface.
 // It does nothing and is used to test the broadcast functions.
 type dummyService struct {
-	status string
-	start  time.Time
+	status  string
+	start   time.Time
+	rtmpKey string
+	titles  []string // Titles set by UpdateMetadata.
+	stats   *broadcast.Stats
 }
 
 type dummyServiceOption func(*dummyService)
@@ -258,6 +308,20 @@ func WithStart(start time.Time) dummyServiceOption {
 	}
 }
 
+// WithRTMPKey is an option function for fixing the returned RTMP key of the dummyService.
+func WithRTMPKey(key string) dummyServiceOption {
+	return func(ds *dummyService) {
+		ds.rtmpKey = key
+	}
+}
+
+// WithStats is an option function for fixing the returned broadcast stats of the dummyService.
+func WithStats(stats *broadcast.Stats) dummyServiceOption {
+	return func(ds *dummyService) {
+		ds.stats = stats
+	}
+}
+
 func (d *dummyService) CreateBroadcast(
 	ctx Ctx,
 	broadcastName, description, streamName, privacy, resolution string,
@@ -281,9 +345,19 @@ func (d *dummyService) BroadcastScheduledStartTime(ctx Ctx, id string) (time.Tim
 	return d.start, nil
 }
 func (d *dummyService) BroadcastHealth(ctx Ctx, id string) (string, error) { return "", nil }
-func (d *dummyService) RTMPKey(ctx Ctx, streamName string) (string, error) { return "", nil }
+func (d *dummyService) RTMPKey(ctx Ctx, streamName string) (string, error) { return d.rtmpKey, nil }
 func (d *dummyService) CompleteBroadcast(ctx Ctx, id string) error         { return nil }
 func (d *dummyService) PostChatMessage(id, msg string) error               { return nil }
+func (d *dummyService) UpdateMetadata(ctx Ctx, id, title, description string, thumbnail io.Reader) error {
+	d.titles = append(d.titles, title)
+	return nil
+}
+func (d *dummyService) BroadcastStats(ctx Ctx, id string) (*broadcast.Stats, error) {
+	if d.stats == nil {
+		return nil, errors.New("no stats")
+	}
+	return d.stats, nil
+}
 
 type dummyForwardingService struct{}
 
@@ -291,6 +365,7 @@ func newDummyForwardingService() *dummyForwardingService
 func (v *dummyForwardingService) Stream(cfg *Cfg) error                                   { return nil }
 func (v *dummyForwardingService) Slate(cfg *Cfg, opts ...SlateOption) error               { return nil }
 func (v *dummyForwardingService) UploadSlate(cfg *Cfg, name string, file io.Reader) error { return nil }
+func (v *dummyForwardingService) Ping(cfg *Cfg) error                                     { return nil }
 
 type dummyHardwareManager struct {
 	hardwareHealthy bool
@@ -300,6 +375,7 @@ type dummyHardwareManager struct {
 	volts           float64
 	alarmVolts      float64
 	chargeRate      float64
+	controllerVolts map[int64]float64 // Fixed voltages by controller MAC.
 }
 
 func withHardwareFault() func(*dummyHardwareManager) {
@@ -314,6 +390,17 @@ func withLowVoltage() func(*dummyHardwareManager) {
 	}
 }
 
+// withControllerVoltage fixes the voltage of the controller with the
+// given MAC, which does not charge.
+func withControllerVoltage(mac int64, volts float64) func(*dummyHardwareManager) {
+	return func(h *dummyHardwareManager) {
+		if h.controllerVolts == nil {
+			h.controllerVolts = make(map[int64]float64)
+		}
+		h.controllerVolts[mac] = volts
+	}
+}
+
 func withMACSanitisation() func(*dummyHardwareManager) {
 	return func(h *dummyHardwareManager) {
 		h.checkMAC = true
@@ -343,20 +430,33 @@ func newDummyHardwareManager(options ...func(*dummyHardwareManager)) *dummyHardw
 	}
 	return m
 }
-func (h *dummyHardwareManager) voltage(ctx *broadcastContext) (float64, error) {
+func (h *dummyHardwareManager) voltage(ctx *broadcastContext, c Controller) (float64, error) {
+	if v, ok := h.controllerVolts[c.MAC]; ok {
+		return v, nil
+	}
 	// This is assuming we call this function every tick.
 	h.volts += h.chargeRate
 	return h.volts, nil
 }
-func (h *dummyHardwareManager) alarmVoltage(ctx *broadcastContext) (float64, error) {
+func (h *dummyHardwareManager) alarmVoltage(ctx *broadcastContext, c Controller) (float64, error) {
 	return h.alarmVolts, nil
 }
-func (h *dummyHardwareManager) isUp(ctx *broadcastContext) (bool, error) {
-	if h.volts < h.alarmVolts {
+func (h *dummyHardwareManager) isUp(ctx *broadcastContext, c Controller) (bool, error) {
+	volts := h.volts
+	if v, ok := h.controllerVolts[c.MAC]; ok {
+		volts = v
+	}
+	if volts < h.alarmVolts {
 		return false, nil
 	}
 	return h.hardwareHealthy, nil
 }
+func (h *dummyHardwareManager) lastReported(ctx *broadcastContext) (time.Time, error) {
+	if !h.hardwareHealthy {
+		return time.Time{}, nil
+	}
+	return time.Now(), nil
+}
 func (h *dummyHardwareManager) start(ctx *broadcastContext) {
 	h.startCalled = true
 }
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var updateTransitions = flag.Bool("update", false, "update the broadcast transition table in testdata")
+
+// transitionTablePath is the path of the generated transition table.
+const transitionTablePath = "testdata/broadcast_transitions.md"
+
+// Actions in the transition table other than transitions, which are
+// described as "-> nextState".
+const (
+	actionHandle    = "handle"    // Handled without a transition.
+	actionIgnore    = "ignore"    // Explicitly ignored.
+	actionUnhandled = "UNHANDLED" // Dropped with a warning by unexpectedEvent.
+	actionElsewhere = "elsewhere" // Handled by another state machine or the manager.
+)
+
+// TestBroadcastTransitions generates the broadcast state machine's
+// transition table (state × event → action) from the source of its
+// handlers, and checks that every published event is handled or
+// explicitly ignored in every state. The table is compared against
+// testdata/broadcast_transitions.md, which is regenerated with:
+//
+//	go test -run TestBroadcastTransitions -update
+func TestBroadcastTransitions(t *testing.T) {
+	fset := token.NewFileSet()
+	files := map[string]*ast.File{}
+	for _, name := range []string{"broadcast_machine.go", "broadcast_states.go", "broadcast_events.go"} {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("could not parse %s: %v", name, err)
+		}
+		files[name] = f
+	}
+
+	states := switchCaseTypes(t, findFunc(files["broadcast_states.go"], "", "updateBroadcastBasedOnState"))
+	events := eventNames(t, findFunc(files["broadcast_events.go"], "", "stringToEvent"))
+	table := transitionTable(t, files["broadcast_machine.go"], states, events)
+
+	for _, e := range events {
+		for _, s := range states {
+			switch a := table[e][s]; a {
+			case "":
+				t.Errorf("event %s has no handler", e)
+			case actionUnhandled:
+				t.Errorf("event %s is neither handled nor explicitly ignored in state %s", e, s)
+			}
+		}
+	}
+
+	got := renderTransitionTable(states, events, table)
+	if *updateTransitions {
+		err := os.MkdirAll(filepath.Dir(transitionTablePath), 0755)
+		if err == nil {
+			err = os.WriteFile(transitionTablePath, []byte(got), 0644)
+		}
+		if err != nil {
+			t.Fatalf("could not write transition table: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(transitionTablePath)
+	if err != nil {
+		t.Fatalf("could not read transition table: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s is out of date, regenerate it with: go test -run TestBroadcastTransitions -update", transitionTablePath)
+	}
+}
+
+// transitionTable returns the action of each event in each state, as
+// per the handlers of broadcastStateMachine.handleEvent.
+func transitionTable(t *testing.T, f *ast.File, states, events []string) map[string]map[string]string {
+	table := map[string]map[string]string{}
+	dispatch := findTypeSwitch(findFunc(f, "broadcastStateMachine", "handleEvent"), "event")
+	if dispatch == nil {
+		t.Fatal("could not find type switch in handleEvent")
+	}
+	for _, stmt := range dispatch.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		for _, e := range typeNames(cc.List) {
+			table[e] = map[string]string{}
+			handler := calledMethod(cc.Body, "handle")
+			for _, s := range states {
+				switch {
+				case handler == "":
+					table[e][s] = actionElsewhere
+				default:
+					table[e][s] = handlerAction(t, findFunc(f, "broadcastStateMachine", handler), s)
+				}
+			}
+		}
+	}
+	return table
+}
+
+// handlerAction returns the action of a handler in a state, as per the
+// handler's type switch on the current state. Handlers without one
+// handle the event regardless of state.
+func handlerAction(t *testing.T, fn *ast.FuncDecl, state string) string {
+	if fn == nil {
+		t.Fatal("could not find handler")
+	}
+	ts := findTypeSwitch(fn, "currentState")
+	if ts == nil {
+		return actionHandle
+	}
+	var def *ast.CaseClause
+	for _, stmt := range ts.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		if cc.List == nil {
+			def = cc
+			continue
+		}
+		for _, s := range typeNames(cc.List) {
+			if s == state {
+				return clauseAction(cc)
+			}
+		}
+	}
+	if def == nil {
+		return actionIgnore
+	}
+	return clauseAction(def)
+}
+
+// clauseAction describes the action of a case clause.
+func clauseAction(cc *ast.CaseClause) string {
+	if len(cc.Body) == 0 {
+		return actionIgnore
+	}
+	var next []string
+	var unhandled bool
+	ast.Inspect(&ast.BlockStmt{List: cc.Body}, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		switch methodName(call) {
+		case "transition":
+			next = append(next, "-> "+constructedType(call.Args[0]))
+		case "transitionIfTimedOut":
+			next = append(next, "-> "+constructedType(call.Args[1]))
+		case "unexpectedEvent":
+			unhandled = true
+		}
+		return true
+	})
+	switch {
+	case unhandled:
+		return actionUnhandled
+	case len(next) != 0:
+		return strings.Join(next, ", ")
+	default:
+		return actionHandle
+	}
+}
+
+// renderTransitionTable renders the transition table as markdown.
+func renderTransitionTable(states, events []string, table map[string]map[string]string) string {
+	var b strings.Builder
+	b.WriteString("# Broadcast state transitions\n\n")
+	b.WriteString("Generated from broadcast_machine.go by TestBroadcastTransitions; do not edit.\n")
+	b.WriteString("Regenerate with `go test -run TestBroadcastTransitions -update`.\n")
+	for _, e := range events {
+		fmt.Fprintf(&b, "\n## %s\n\n| State | Action |\n|---|---|\n", e)
+		for _, s := range states {
+			fmt.Fprintf(&b, "| %s | %s |\n", s, table[e][s])
+		}
+	}
+	return b.String()
+}
+
+// findFunc returns the function or method with the given receiver
+// type, if any, and name.
+func findFunc(f *ast.File, recv, name string) *ast.FuncDecl {
+	for _, d := range f.Decls {
+		fn, ok := d.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != name {
+			continue
+		}
+		if recv == "" && fn.Recv == nil {
+			return fn
+		}
+		if recv != "" && fn.Recv != nil {
+			if star, ok := fn.Recv.List[0].Type.(*ast.StarExpr); ok && star.X.(*ast.Ident).Name == recv {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+// findTypeSwitch returns the type switch in fn on a value whose name
+// ends with subject. Handlers that switch on the current state more
+// than once are described by the switch with a default clause.
+func findTypeSwitch(fn *ast.FuncDecl, subject string) *ast.TypeSwitchStmt {
+	if fn == nil {
+		return nil
+	}
+	var found *ast.TypeSwitchStmt
+	ast.Inspect(fn, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSwitchStmt)
+		if !ok {
+			return true
+		}
+		expr, ok := ts.Assign.(*ast.ExprStmt)
+		if !ok {
+			return true
+		}
+		ta := expr.X.(*ast.TypeAssertExpr)
+		var name string
+		switch x := ta.X.(type) {
+		case *ast.Ident:
+			name = x.Name
+		case *ast.SelectorExpr:
+			name = x.Sel.Name
+		}
+		if name != subject {
+			return true
+		}
+		if found == nil || hasDefault(ts) {
+			found = ts
+		}
+		return true
+	})
+	return found
+}
+
+// hasDefault returns true if a type switch has a default clause.
+func hasDefault(ts *ast.TypeSwitchStmt) bool {
+	for _, stmt := range ts.Body.List {
+		if stmt.(*ast.CaseClause).List == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// switchCaseTypes returns the types of the cases of the first type
+// switch in fn, sorted.
+func switchCaseTypes(t *testing.T, fn *ast.FuncDecl) []string {
+	ts := findTypeSwitch(fn, "state")
+	if ts == nil {
+		t.Fatal("could not find state type switch")
+	}
+	var names []string
+	for _, stmt := range ts.Body.List {
+		names = append(names, typeNames(stmt.(*ast.CaseClause).List)...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eventNames returns the names of the events in the map literal in
+// fn, sorted.
+func eventNames(t *testing.T, fn *ast.FuncDecl) []string {
+	var names []string
+	ast.Inspect(fn, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		if lit, ok := kv.Key.(*ast.BasicLit); ok {
+			name, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatalf("invalid event name %s", lit.Value)
+			}
+			names = append(names, name)
+		}
+		return true
+	})
+	if len(names) == 0 {
+		t.Fatal("could not find event names")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeNames returns the names of the types in a case list, without
+// any pointer indirection.
+func typeNames(exprs []ast.Expr) []string {
+	var names []string
+	for _, e := range exprs {
+		if star, ok := e.(*ast.StarExpr); ok {
+			e = star.X
+		}
+		if id, ok := e.(*ast.Ident); ok {
+			names = append(names, id.Name)
+		}
+	}
+	return names
+}
+
+// calledMethod returns the name of the first method with the given
+// prefix called in stmts, if any.
+func calledMethod(stmts []ast.Stmt, prefix string) string {
+	var name string
+	ast.Inspect(&ast.BlockStmt{List: stmts}, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if ok && name == "" && strings.HasPrefix(methodName(call), prefix) {
+			name = methodName(call)
+		}
+		return name == ""
+	})
+	return name
+}
+
+// methodName returns the name of the method called by sm.method(...),
+// or the empty string for other calls.
+func methodName(call *ast.CallExpr) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if id, ok := sel.X.(*ast.Ident); !ok || id.Name != "sm" {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// constructedType returns the type constructed by newT(...) or &t{},
+// with the first letter of the constructor's type lower cased, or the
+// name of any other function returning the state, e.g., finishedState.
+func constructedType(e ast.Expr) string {
+	switch x := e.(type) {
+	case *ast.CallExpr:
+		id, ok := x.Fun.(*ast.Ident)
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(id.Name, "new") {
+			name := strings.TrimPrefix(id.Name, "new")
+			return strings.ToLower(name[:1]) + name[1:]
+		}
+		return id.Name
+	case *ast.UnaryExpr:
+		if cl, ok := x.X.(*ast.CompositeLit); ok {
+			if id, ok := cl.Type.(*ast.Ident); ok {
+				return id.Name
+			}
+		}
+	}
+	return "?"
+}
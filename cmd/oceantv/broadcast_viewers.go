@@ -0,0 +1,84 @@
+/*
+DESCRIPTION
+  broadcast_viewers.go provides sampling of the concurrent viewers of
+  live broadcasts, which are stored along with the stream's health so
+  that viewer engagement can be correlated with stream health and time
+  of day.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// viewerSampleInterval is the interval between samples of a live
+// broadcast's concurrent viewers, which is long enough to keep the
+// YouTube API quota used by sampling low.
+const viewerSampleInterval = 15 * time.Minute
+
+// viewerSampleIsDue returns true if the broadcast's viewers should be
+// sampled at t.
+func viewerSampleIsDue(cfg *BroadcastConfig, t time.Time) bool {
+	return cfg.ID != "" && t.Sub(cfg.ViewersSampled) >= viewerSampleInterval
+}
+
+// sampleViewersIfDue stores a sample of the broadcast's concurrent
+// viewers and current health if due. Failures are logged rather than
+// notified, since samples are only used for reports.
+func (sm *broadcastStateMachine) sampleViewersIfDue(t time.Time) {
+	if !viewerSampleIsDue(sm.ctx.cfg, t) {
+		return
+	}
+
+	// Record the sample time first so that failures are not retried
+	// until the next sample is due.
+	if !try(
+		sm.ctx.man.Save(nil, func(_cfg *BroadcastConfig) { _cfg.ViewersSampled = t }),
+		"could not save viewer sample time",
+		sm.logAndNotifySoftware,
+	) {
+		return
+	}
+
+	ctx := context.Background()
+	stats, err := sm.ctx.svc.BroadcastStats(ctx, sm.ctx.cfg.ID)
+	if err != nil {
+		sm.log("could not get broadcast stats for viewer sample: %v", err)
+		return
+	}
+	v := &model.ViewerSample{
+		VideoID:   sm.ctx.cfg.ID,
+		Skey:      sm.ctx.cfg.SKey,
+		Timestamp: t,
+		Viewers:   stats.Viewers,
+		Healthy:   !isUnhealthy(sm.currentState),
+		Issues:    int64(sm.ctx.cfg.Issues),
+	}
+	err = model.PutViewerSample(ctx, sm.ctx.store, v)
+	if err != nil {
+		sm.log("could not put viewer sample: %v", err)
+		return
+	}
+	sm.log("sampled %d concurrent viewers", v.Viewers)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/cmd/oceantv/broadcast"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestSampleViewersIfDue(t *testing.T) {
+	ctx := context.Background()
+	model.RegisterEntities()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	now := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	cfg := &BroadcastConfig{SKey: 1, ID: "abc", Issues: 1}
+	bCtx := &broadcastContext{
+		cfg:       cfg,
+		man:       newDummyManager(t, cfg),
+		store:     store,
+		svc:       newDummyService(WithStats(&broadcast.Stats{Viewers: 42})),
+		logOutput: t.Log,
+	}
+	sm := &broadcastStateMachine{currentState: newDirectLiveUnhealthy(bCtx), ctx: bCtx}
+
+	// Only the first and last of these are due.
+	sm.sampleViewersIfDue(now)
+	sm.sampleViewersIfDue(now.Add(viewerSampleInterval - time.Minute))
+	sm.sampleViewersIfDue(now.Add(viewerSampleInterval))
+
+	if !cfg.ViewersSampled.Equal(now.Add(viewerSampleInterval)) {
+		t.Errorf("viewers sampled at %v, want %v", cfg.ViewersSampled, now.Add(viewerSampleInterval))
+	}
+	vs, err := model.GetViewerSamples(ctx, store, "abc")
+	if err != nil {
+		t.Fatalf("could not get viewer samples: %v", err)
+	}
+	if len(vs) != 2 {
+		t.Fatalf("got %d viewer samples, want 2", len(vs))
+	}
+	want := model.ViewerSample{VideoID: "abc", Skey: 1, Timestamp: now, Viewers: 42, Healthy: false, Issues: 1}
+	if !vs[0].Timestamp.Equal(want.Timestamp) || vs[0].VideoID != want.VideoID || vs[0].Skey != want.Skey ||
+		vs[0].Viewers != want.Viewers || vs[0].Healthy != want.Healthy || vs[0].Issues != want.Issues {
+		t.Errorf("got viewer sample %+v, want %+v", vs[0], want)
+	}
+}
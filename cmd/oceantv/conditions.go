@@ -0,0 +1,323 @@
+/*
+DESCRIPTION
+  conditions.go provides tide and weather conditions at a site, which
+  are added to broadcast descriptions and chat messages. Conditions are
+  obtained from providers, which may be swapped by implementing the
+  tide.Provider and weatherProvider interfaces.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This file is part of Ocean TV. Ocean TV is free software: you can
+  redistribute it and/or modify it under the terms of the GNU
+  General Public License as published by the Free Software
+  Foundation, either version 3 of the License, or (at your option)
+  any later version.
+
+  Ocean TV is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/tide"
+)
+
+// weatherReport is the current weather at a location.
+type weatherReport struct {
+	AirTemp       float64 // Degrees Celsius.
+	WindSpeed     float64 // Kilometres per hour.
+	WindDirection float64 // Degrees from which the wind blows.
+}
+
+// weatherProvider is implemented by sources of weather reports.
+type weatherProvider interface {
+	// Weather returns the current weather at a location.
+	Weather(ctx context.Context, lat, lon float64) (*weatherReport, error)
+}
+
+// Tide and weather providers, which are nil if not configured.
+var (
+	tides   tide.Provider
+	weather weatherProvider
+)
+
+// conditions holds the tide and weather conditions at a site. Either
+// may be nil if unavailable.
+type conditions struct {
+	Tide    *tide.Event    // The next tide.
+	Weather *weatherReport // The current weather.
+
+	loc *time.Location // The site's location, for local times.
+}
+
+// Conditions are cached since they change slowly and providers may
+// charge per request. Tides are requested a day at a time. Failures
+// are also cached, so that an unavailable provider is not requested
+// for every broadcast.
+const (
+	tideWindow      = 24 * time.Hour
+	weatherCacheTTL = 15 * time.Minute
+	failureCacheTTL = 15 * time.Minute
+)
+
+// conditionsCache caches tides and weather reports by location. The
+// mutex guards only the maps, and is not held while requesting
+// providers.
+type conditionsCache struct {
+	mu      sync.Mutex
+	tides   map[[2]float64]cachedTides
+	weather map[[2]float64]cachedWeather
+}
+
+// cachedTides are cached tides, or the error obtaining them, and the
+// time they were requested.
+type cachedTides struct {
+	events []tide.Event
+	err    error
+	time   time.Time
+}
+
+// cachedWeather is a cached weather report, or the error obtaining it,
+// and the time it was requested.
+type cachedWeather struct {
+	report *weatherReport
+	err    error
+	time   time.Time
+}
+
+var condCache = &conditionsCache{
+	tides:   make(map[[2]float64]cachedTides),
+	weather: make(map[[2]float64]cachedWeather),
+}
+
+// nextTide returns the next tide after t at the given location,
+// requesting tides from the provider when none are cached, unless the
+// last request failed within failureCacheTTL.
+func (c *conditionsCache) nextTide(ctx context.Context, p tide.Provider, lat, lon float64, t time.Time) (*tide.Event, error) {
+	loc := [2]float64{lat, lon}
+	c.mu.Lock()
+	ct := c.tides[loc]
+	c.mu.Unlock()
+	ev := firstTideAfter(ct.events, t)
+	if ev != nil {
+		return ev, nil
+	}
+	if ct.err != nil && t.Sub(ct.time) < failureCacheTTL {
+		return nil, ct.err
+	}
+
+	evs, err := p.Tides(ctx, lat, lon, t, t.Add(tideWindow))
+	if err == nil && firstTideAfter(evs, t) == nil {
+		err = errors.New("no tides found")
+	}
+	c.mu.Lock()
+	c.tides[loc] = cachedTides{events: evs, err: err, time: t}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return firstTideAfter(evs, t), nil
+}
+
+// firstTideAfter returns the first of the given tides after t, or nil
+// if there is none.
+func firstTideAfter(evs []tide.Event, t time.Time) *tide.Event {
+	for i := range evs {
+		if evs[i].Time.After(t) {
+			return &evs[i]
+		}
+	}
+	return nil
+}
+
+// currentWeather returns the current weather at the given location,
+// requesting it from the provider when the cached report, or error,
+// has expired.
+func (c *conditionsCache) currentWeather(ctx context.Context, p weatherProvider, lat, lon float64, t time.Time) (*weatherReport, error) {
+	loc := [2]float64{lat, lon}
+	c.mu.Lock()
+	cw, ok := c.weather[loc]
+	c.mu.Unlock()
+	switch {
+	case ok && cw.err == nil && t.Sub(cw.time) < weatherCacheTTL:
+		return cw.report, nil
+	case ok && cw.err != nil && t.Sub(cw.time) < failureCacheTTL:
+		return nil, cw.err
+	}
+
+	w, err := p.Weather(ctx, lat, lon)
+	c.mu.Lock()
+	c.weather[loc] = cachedWeather{report: w, err: err, time: t}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// siteConditions returns the conditions at t at the given site, from
+// whichever providers are configured. An error is returned only if no
+// conditions could be obtained.
+func siteConditions(ctx context.Context, store Store, skey int64, t time.Time) (*conditions, error) {
+	if tides == nil && weather == nil {
+		return nil, errors.New("no tide or weather provider")
+	}
+	site, err := model.GetSite(ctx, store, skey)
+	if err != nil {
+		return nil, fmt.Errorf("could not get site: %w", err)
+	}
+	if site.Latitude == 0 && site.Longitude == 0 {
+		return nil, fmt.Errorf("site %d has no location", skey)
+	}
+
+	c := conditions{loc: model.LocalTime(site, t).Location()}
+	var errs []error
+	if tides != nil {
+		c.Tide, err = condCache.nextTide(ctx, tides, site.Latitude, site.Longitude, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not get tide: %w", err))
+		}
+	}
+	if weather != nil {
+		c.Weather, err = condCache.currentWeather(ctx, weather, site.Latitude, site.Longitude, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not get weather: %w", err))
+		}
+	}
+	if c.Tide == nil && c.Weather == nil {
+		return nil, errors.Join(errs...)
+	}
+	return &c, nil
+}
+
+// String returns the conditions in a form suitable for descriptions
+// and chat messages, e.g., "High tide 1.4 m at 14:20 | Air 18.2°C |
+// Wind 12 km/h SSW", with times in the site's local time.
+func (c *conditions) String() string {
+	var parts []string
+	if c.Tide != nil {
+		kind := "Low"
+		if c.Tide.High {
+			kind = "High"
+		}
+		t := c.Tide.Time
+		if c.loc != nil {
+			t = t.In(c.loc)
+		}
+		parts = append(parts, fmt.Sprintf("%s tide %.1f m at %s", kind, c.Tide.Height, t.Format("15:04")))
+	}
+	if c.Weather != nil {
+		parts = append(parts,
+			fmt.Sprintf("Air %.1f°C", c.Weather.AirTemp),
+			fmt.Sprintf("Wind %.0f km/h %s", c.Weather.WindSpeed, compassPoint(c.Weather.WindDirection)),
+		)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// compassPoint returns the 16-point compass direction of the given
+// bearing in degrees, e.g., "SSW".
+func compassPoint(deg float64) string {
+	points := []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+	i := int(math.Round(math.Mod(math.Mod(deg, 360)+360, 360)/22.5)) % len(points)
+	return points[i]
+}
+
+// broadcastDescription returns the description of the broadcast at t,
+// which includes the conditions at the site if enabled. The plain
+// description is returned if conditions are unavailable.
+func broadcastDescription(ctx context.Context, store Store, cfg *BroadcastConfig, t time.Time, log func(string, ...interface{})) string {
+	if !cfg.SendConditions {
+		return cfg.Description
+	}
+	c, err := siteConditions(ctx, store, cfg.SKey, t)
+	if err != nil {
+		log("could not get conditions for description: %v", err)
+		return cfg.Description
+	}
+	if cfg.Description == "" {
+		return c.String()
+	}
+	return cfg.Description + "\n\n" + c.String()
+}
+
+// openMeteo is a weatherProvider using the Open-Meteo API, which does
+// not require a key.
+type openMeteo struct {
+	url string // For testing; openMeteoURL is used if empty.
+}
+
+// openMeteoURL is the Open-Meteo forecast API endpoint.
+const openMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// Weather implements weatherProvider.Weather.
+func (p *openMeteo) Weather(ctx context.Context, lat, lon float64) (*weatherReport, error) {
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', -1, 64))
+	q.Set("current", "temperature_2m,wind_speed_10m,wind_direction_10m")
+	u := p.url
+	if u == "" {
+		u = openMeteoURL
+	}
+
+	var res struct {
+		Reason  string `json:"reason"`
+		Current *struct {
+			Temperature   float64 `json:"temperature_2m"`
+			WindSpeed     float64 `json:"wind_speed_10m"`
+			WindDirection float64 `json:"wind_direction_10m"`
+		} `json:"current"`
+	}
+	err := getJSON(ctx, u+"?"+q.Encode(), &res)
+	if err != nil {
+		return nil, fmt.Errorf("weather request error: %w", err)
+	}
+	if res.Current == nil {
+		return nil, fmt.Errorf("weather request returned no current weather: %s", res.Reason)
+	}
+	return &weatherReport{
+		AirTemp:       res.Current.Temperature,
+		WindSpeed:     res.Current.WindSpeed,
+		WindDirection: res.Current.WindDirection,
+	}, nil
+}
+
+// getJSON performs a GET request and decodes the JSON response into dst.
+func getJSON(ctx context.Context, u string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	clt := &http.Client{Timeout: time.Minute}
+	resp, err := clt.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return fmt.Errorf("could not decode response: %w", err)
+	}
+	return nil
+}
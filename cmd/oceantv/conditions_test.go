@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/cloud/tide"
+	"github.com/ausocean/openfish/datastore"
+)
+
+func TestOpenMeteo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("latitude") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":true,"reason":"missing latitude"}`))
+			return
+		}
+		w.Write([]byte(`{"current":{"time":"2026-10-16T09:00","interval":900,"temperature_2m":18.2,"wind_speed_10m":12.4,"wind_direction_10m":200}}`))
+	}))
+	defer srv.Close()
+
+	p := &openMeteo{url: srv.URL}
+	got, err := p.Weather(context.Background(), -35.5, 138.2)
+	if err != nil {
+		t.Fatalf("could not get weather: %v", err)
+	}
+	want := weatherReport{AirTemp: 18.2, WindSpeed: 12.4, WindDirection: 200}
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+}
+
+func TestConditionsString(t *testing.T) {
+	loc, err := time.LoadLocation(locationID)
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	ev := &tide.Event{Time: time.Date(2026, 10, 16, 3, 50, 0, 0, time.UTC), High: true, Height: 1.43}
+	w := &weatherReport{AirTemp: 18.24, WindSpeed: 12.4, WindDirection: 200}
+
+	tests := []struct {
+		c    conditions
+		want string
+	}{
+		{conditions{Tide: ev, Weather: w, loc: loc}, "High tide 1.4 m at 14:20 | Air 18.2°C | Wind 12 km/h SSW"},
+		{conditions{Tide: ev, loc: loc}, "High tide 1.4 m at 14:20"},
+		{conditions{Tide: ev, loc: time.FixedZone("UTC+8", 8*3600)}, "High tide 1.4 m at 11:50"},
+		{conditions{Weather: w}, "Air 18.2°C | Wind 12 km/h SSW"},
+	}
+	for _, tt := range tests {
+		got := tt.c.String()
+		if got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCompassPoint(t *testing.T) {
+	tests := map[float64]string{0: "N", 11: "N", 12: "NNE", 90: "E", 200: "SSW", 350: "N", 360: "N", -90: "W"}
+	for deg, want := range tests {
+		got := compassPoint(deg)
+		if got != want {
+			t.Errorf("compassPoint(%v) = %s, want %s", deg, got, want)
+		}
+	}
+}
+
+// testTides is a tide.Provider that returns a tide every 6 hours.
+type testTides struct {
+	calls int
+}
+
+func (p *testTides) Tides(ctx context.Context, lat, lon float64, start, end time.Time) ([]tide.Event, error) {
+	p.calls++
+	var evs []tide.Event
+	high := true
+	for t := start.Truncate(6 * time.Hour).Add(6 * time.Hour); t.Before(end); t = t.Add(6 * time.Hour) {
+		evs = append(evs, tide.Event{Time: t, High: high, Height: 1})
+		high = !high
+	}
+	return evs, nil
+}
+
+// testWeather is a weatherProvider that fails.
+type testWeather struct {
+	calls int
+}
+
+func (p *testWeather) Weather(ctx context.Context, lat, lon float64) (*weatherReport, error) {
+	p.calls++
+	return nil, errors.New("weather unavailable")
+}
+
+func TestSiteConditions(t *testing.T) {
+	ctx := context.Background()
+	model.RegisterEntities()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	err = model.PutSite(ctx, store, &model.Site{Skey: 1, Name: "Rapid Bay Jetty", Latitude: -35.52, Longitude: 138.18})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	err = model.PutSite(ctx, store, &model.Site{Skey: 2, Name: "Nowhere"})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+
+	tp, wp := &testTides{}, &testWeather{}
+	tides, weather = tp, wp
+	defer func() { tides, weather = nil, nil }()
+
+	// Weather fails but tides succeed, so conditions are returned.
+	now := time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)
+	c, err := siteConditions(ctx, store, 1, now)
+	if err != nil {
+		t.Fatalf("could not get conditions: %v", err)
+	}
+	if c.Weather != nil || c.Tide == nil {
+		t.Fatalf("got conditions %+v, want tide only", c)
+	}
+	if want := time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC); !c.Tide.Time.Equal(want) {
+		t.Errorf("got next tide at %v, want %v", c.Tide.Time, want)
+	}
+
+	// Weather failures are cached until they expire.
+	_, err = siteConditions(ctx, store, 1, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("could not get conditions: %v", err)
+	}
+	if wp.calls != 1 {
+		t.Errorf("got %d weather requests, want 1", wp.calls)
+	}
+
+	// Later tides are served from the cache.
+	c, err = siteConditions(ctx, store, 1, now.Add(6*time.Hour))
+	if err != nil {
+		t.Fatalf("could not get conditions: %v", err)
+	}
+	if want := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC); !c.Tide.Time.Equal(want) || c.Tide.High {
+		t.Errorf("got next tide %+v, want low tide at %v", c.Tide, want)
+	}
+	if tp.calls != 1 {
+		t.Errorf("got %d tide requests, want 1", tp.calls)
+	}
+	if wp.calls != 2 {
+		t.Errorf("got %d weather requests after failure expired, want 2", wp.calls)
+	}
+
+	// Sites without a location have no conditions.
+	_, err = siteConditions(ctx, store, 2, now)
+	if err == nil {
+		t.Errorf("expected error for site without location")
+	}
+}
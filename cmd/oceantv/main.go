@@ -36,10 +36,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ausocean/cloud/backend"
 	"github.com/ausocean/cloud/cmd/oceantv/openfish"
 	"github.com/ausocean/cloud/gauth"
 	"github.com/ausocean/cloud/model"
 	"github.com/ausocean/cloud/notify"
+	"github.com/ausocean/cloud/tide"
 	"github.com/ausocean/cloud/utils"
 	"github.com/ausocean/openfish/datastore"
 )
@@ -50,6 +52,9 @@ const (
 	projectURL         = "https://oceantv.appspot.com"
 	cronServiceAccount = "oceancron@appspot.gserviceaccount.com"
 	locationID         = "Australia/Adelaide" // TODO: Use site location.
+	panicDedupPeriod   = time.Hour            // Period within which repeated panics are notified once.
+	dedupWindow        = time.Hour            // Period within which identical notifications are sent once.
+	ackTimeout         = 30 * time.Minute     // Period after which unacknowledged critical notifications are escalated.
 )
 
 var (
@@ -82,6 +87,7 @@ func main() {
 	flag.IntVar(&port, "port", defaultPort, "Port we listen on in standalone mode")
 	flag.StringVar(&storePath, "filestore", "store", "File store path")
 	flag.Parse()
+	backend.SetupLogging(!(debug || standalone))
 
 	// Perform one-time setup or bail.
 	setup(context.Background())
@@ -101,24 +107,29 @@ func main() {
 		log.Fatalf("could not get mailjetPrivateKey, can't send panic recovery notification")
 	}
 
-	mux := utils.NewRecoverableServeMux(
-		utils.NewConfigurableRecoveryHandler(
-			// Only consider handled if we can get a notification off.
-			utils.WithHandledConditions(utils.HandledConditions{HandledOnNotification: true}),
-			utils.WithLogOutput(log.Println),
-			utils.WithNotification(func(msg string) error { return sendPanicNotification(publicKey, privateKey, msg) }),
-			utils.WithHttpError(http.StatusInternalServerError),
-			utils.WithHandlers(errNoGlobalNotifierHandler(secrets)),
-		),
+	// Panics are only considered handled if we can get a notification off.
+	recovery := utils.NewConfigurableRecoveryHandler(
+		utils.WithHandledConditions(utils.HandledConditions{HandledOnNotification: true}),
+		utils.WithLogOutput(log.Println),
+		utils.WithNotification(func(msg string) error { return sendPanicNotification(publicKey, privateKey, msg) }),
+		utils.WithHttpError(http.StatusInternalServerError),
+		utils.WithHandlers(errNoGlobalNotifierHandler(secrets)),
+		utils.WithDeduplication(panicDedupPeriod),
 	)
 
+	mux := http.NewServeMux()
 	mux.HandleFunc("/_ah/warmup", warmupHandler)
 	mux.HandleFunc("/broadcast/", broadcastHandler)
 	mux.HandleFunc("/checkbroadcasts", checkBroadcastsHandler)
+	mux.Handle("/ack", notify.NewAckHandler(settingsStore, []byte(secrets[notify.AckSecretKey])))
 	mux.HandleFunc("/", indexHandler)
 
 	log.Printf("Listening on %s:%d", host, port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), mux))
+	h := backend.Chain(mux, backend.RequestIDs(projectID), backend.Recover(recovery), backend.LogRequests(debug || standalone))
+	err = backend.NewServer(fmt.Sprintf("%s:%d", host, port), h).ListenAndServe(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func sendPanicNotification(publicKey, privateKey, msg string) error {
@@ -135,11 +146,39 @@ func sendPanicNotification(publicKey, privateKey, msg string) error {
 		msg,
 	)
 	if err != nil {
-		return fmt.Errorf("could not send panic recovery email: %v", err)
+		return fmt.Errorf("could not send panic recovery email: %w", err)
 	}
 	return nil
 }
 
+// notifierOptions returns the options for the global notifier.
+// Broadcast failures that take a broadcast down are critical, so that
+// they may be routed to channels that page someone, and are escalated
+// to the site owner if not acknowledged within ackTimeout. Identical
+// messages, which are common during incidents, are suppressed for
+// dedupWindow.
+func notifierOptions(secrets map[string]string) []notify.Option {
+	return []notify.Option{
+		notify.WithSecrets(secrets),
+		notify.WithRecipientLookup(tvRecipients),
+		notify.WithStore(notify.NewStore(settingsStore)),
+		notify.WithDedup("", dedupWindow),
+		notify.WithTemplateStore(settingsStore),
+		notify.WithHistory(settingsStore),
+		notify.WithQuietHours(settingsStore),
+		notify.WithRouteStore(settingsStore),
+		notify.WithAcks(settingsStore, []byte(secrets[notify.AckSecretKey]), projectURL+"/ack", ackTimeout, notify.OwnerTier(settingsStore)),
+		notify.WithSeverity(broadcastGeneric, notify.SeverityCritical),
+		notify.WithSeverity(broadcastForwarder, notify.SeverityCritical),
+		notify.WithSeverity(broadcastHardware, notify.SeverityCritical),
+		notify.WithSeverity(broadcastSoftware, notify.SeverityCritical),
+		notify.WithSeverity(broadcastNetwork, notify.SeverityWarning),
+		notify.WithSeverity(broadcastConfiguration, notify.SeverityWarning),
+		notify.WithSeverity(broadcastReadiness, notify.SeverityWarning),
+		notify.WithSeverity(broadcastOverrun, notify.SeverityCritical),
+	}
+}
+
 func errNoGlobalNotifierHandler(secrets map[string]string) utils.RecoveryHandler {
 	return func(w http.ResponseWriter, panicErr any) bool {
 		err, ok := panicErr.(error)
@@ -147,11 +186,7 @@ func errNoGlobalNotifierHandler(secrets map[string]string) utils.RecoveryHandler
 			return false
 		}
 		if errors.Is(err, errNoGlobalNotifier) {
-			notifier, err = notify.NewMailjetNotifier(
-				notify.WithSecrets(secrets),
-				notify.WithRecipientLookup(tvRecipients),
-				notify.WithStore(notify.NewStore(settingsStore)),
-			)
+			notifier, err = notify.NewMailjetNotifier(notifierOptions(secrets)...)
 			if err != nil {
 				log.Printf("could not remediate missing global notifier: %v", err)
 				return false
@@ -170,7 +205,6 @@ func warmupHandler(w http.ResponseWriter, r *http.Request) {
 // indexHandler handles requests for the home page and is here just to
 // test that the service is running. Clients do not use this endpoint.
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
 	w.Write([]byte(projectID + " " + version))
 }
 
@@ -213,15 +247,18 @@ func setup(ctx context.Context) {
 		log.Fatalf("could not get secrets: %v", err)
 	}
 
-	notifier, err = notify.NewMailjetNotifier(
-		notify.WithSecrets(secrets),
-		notify.WithRecipientLookup(tvRecipients),
-		notify.WithStore(notify.NewStore(settingsStore)),
-	)
+	notifier, err = notify.NewMailjetNotifier(notifierOptions(secrets)...)
 	if err != nil {
 		log.Fatalf("could not set up email notifier: %v", err)
 	}
 
+	// Tides are only available when a tide provider is configured,
+	// whereas the weather provider requires no key.
+	if key := secrets["worldTidesKey"]; key != "" {
+		tides = &tide.WorldTides{Key: key}
+	}
+	weather = &openMeteo{}
+
 	ofsvc, err = openfish.New()
 	if err != nil {
 		log.Fatalf("could not setup openfish service: %v", err)
@@ -229,19 +266,20 @@ func setup(ctx context.Context) {
 }
 
 // tvRecipients looks up the email addresses and notification period
-// for the given site,
+// for the given site. A broadcast's own recipients and channel
+// overrides are merged with these by the notifier (see notifyContext).
 func tvRecipients(skey int64, kind notify.Kind) ([]string, time.Duration, error) {
 	ctx := context.Background()
 	site, err := model.GetSite(ctx, settingsStore, skey)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error getting site: %w", err)
+		return nil, 0, fmt.Errorf("could not get site: %w", err)
 	}
 	if site.OpsEmail == "" {
 		log.Printf("OpsEmail not defined for site %s", site.Name)
 	}
 	recipients := []string{site.OpsEmail}
 	switch kind {
-	case broadcastHardware, broadcastNetwork, broadcastConfiguration:
+	case broadcastHardware, broadcastNetwork, broadcastConfiguration, broadcastReadiness:
 		if site.YouTubeEmail == "" {
 			log.Printf("YouTubeEmail not defined for site %s", site.Name)
 			break
@@ -257,75 +295,78 @@ func tvRecipients(skey int64, kind notify.Kind) ([]string, time.Duration, error)
 // These take the form: /broadcast/op
 // TODO: Add JWT signing
 func broadcastHandler(w http.ResponseWriter, r *http.Request) {
-	logRequest(r)
-
 	ctx := r.Context()
 	setup(ctx)
 
 	req := strings.Split(r.URL.Path, "/")
 	if len(req) != 3 {
-		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid URL length"))
+		backend.WriteError(w, http.StatusBadRequest, errors.New("invalid URL length"))
 		return
 	}
 
 	op := req[2]
 	if op != "save" {
-		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid operation: %s", op))
+		backend.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid operation: %s", op))
 		return
 	}
 
 	ct := r.Header.Get("Content-Type")
 	if ct != "application/json" {
-		writeError(w, http.StatusBadRequest, fmt.Errorf("unexpected Content-Type: %s", ct))
+		backend.WriteError(w, http.StatusBadRequest, fmt.Errorf("unexpected Content-Type: %s", ct))
 		return
 	}
 
 	defer r.Body.Close()
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		backend.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
 	var cfg BroadcastConfig
 	err = json.Unmarshal(data, &cfg)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		backend.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
+	ctx = backend.WithBroadcast(backend.WithSite(ctx, cfg.SKey), cfg.ID)
 	log := func(msg string, args ...interface{}) {
-		logForBroadcast(&cfg, log.Println, msg, args...)
+		logForBroadcast(&cfg, backend.LogFunc(ctx), msg, args...)
 	}
 
-	// Use the broadcast manager to save the broadcast.
+	// Use the broadcast manager to save the broadcast, reloading the
+	// stored config safely if the broadcast is in progress.
 	// We can provide a nil BroadcastService given that Save
 	// won't need this.
-	err = newOceanBroadcastManager(nil, &cfg, settingsStore, log).Save(ctx, nil)
+	var (
+		resp      saveResponse
+		reloadErr error
+	)
+	err = newOceanBroadcastManager(nil, &cfg, settingsStore, log).Save(ctx, func(_cfg *BroadcastConfig) {
+		var reloaded *BroadcastConfig
+		reloaded, resp.Deferred, reloadErr = reloadConfig(_cfg, &cfg)
+		if reloadErr == nil {
+			*_cfg = *reloaded
+		}
+	})
+	if err == nil {
+		err = reloadErr
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		backend.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	log("broadcast saved")
-	w.WriteHeader(http.StatusOK)
-}
-
-// writeError writes HTTP errors to the response writer.
-func writeError(w http.ResponseWriter, code int, err error) {
-	log.Printf(err.Error())
-	http.Error(w, http.StatusText(code)+":"+err.Error(), code)
+	if len(resp.Deferred) != 0 {
+		log("broadcast saved while in progress, deferring changes to %s", strings.Join(resp.Deferred, ", "))
+	} else {
+		log("broadcast saved")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
-// logRequest logs a request if in debug mode and standalone mode.
-// It does nothing in App Engine mode as App Engine logs requests
-// automatically.
-func logRequest(r *http.Request) {
-	if !(debug || standalone) {
-		return
-	}
-	if r.URL.RawQuery == "" {
-		log.Println(r.URL.Path)
-		return
-	}
-	log.Println(r.URL.Path + "?" + r.URL.RawQuery)
+// saveResponse is the response to a broadcast save request.
+type saveResponse struct {
+	Deferred []string // Names of fields whose changes were deferred until the broadcast is no longer in progress.
 }
@@ -1,7 +1,7 @@
 /*
 DESCRIPTION
   openfish.go provides functions for registering a completed stream with
-  OpenFish.
+  OpenFish, creating the capture source for the stream if need be.
 
 AUTHORS
   Scott Barnard <scott@ausocean.org>
@@ -33,6 +33,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -41,6 +42,8 @@ import (
 	"google.golang.org/api/idtoken"
 )
 
+const apiURL = "https://openfish.appspot.com/api/v1"
+
 type OpenfishService struct {
 	Credentials []byte
 	Audience    string
@@ -94,38 +97,95 @@ func New() (OpenfishService, error) {
 	return OpenfishService{Credentials: creds, Audience: audience}, nil
 }
 
-func (o *OpenfishService) RegisterStream(SID string, captureSource int, start time.Time, end time.Time) error {
-
-	// Create new client to connect to OpenFish.
-	client, err := idtoken.NewClient(context.Background(), o.Audience, idtoken.WithCredentialsJSON(o.Credentials))
-	if err != nil {
-		return err
-	}
-
-	jsonBytes, err := json.Marshal(struct {
-		StreamUrl     string `json:"stream_url"`
-		Capturesource int    `json:"capturesource"`
-		Start         string `json:"startTime"`
-		End           string `json:"endTime"`
+// RegisterStream registers a completed YouTube stream with the given
+// capture source. Annotators, if any, are the email addresses of the
+// users permitted to annotate the stream, which seeds annotation of
+// the stream in OpenFish.
+func (o *OpenfishService) RegisterStream(SID string, captureSource int64, start time.Time, end time.Time, annotators []string) error {
+	_, err := o.post("/videostreams", struct {
+		StreamUrl     string   `json:"stream_url"`
+		Capturesource int64    `json:"capturesource"`
+		Start         string   `json:"startTime"`
+		End           string   `json:"endTime"`
+		Annotators    []string `json:"annotator_list,omitempty"`
 	}{
 		StreamUrl:     fmt.Sprintf("https://www.youtube.com/watch?v=%s", SID),
 		Capturesource: captureSource,
 		Start:         start.Format(time.RFC3339),
 		End:           end.Format(time.RFC3339),
+		Annotators:    annotators,
 	})
+	return err
+}
+
+// CreateCaptureSource creates a capture source, i.e., a camera rig,
+// returning its ID. Location is the latitude and longitude of the rig,
+// and siteID is the OceanBench site key, if any.
+func (o *OpenfishService) CreateCaptureSource(name string, lat, lng float64, hardware string, siteID int64) (int64, error) {
+	body := struct {
+		Name           string `json:"name"`
+		Location       string `json:"location"`
+		CameraHardware string `json:"camera_hardware"`
+		SiteID         *int64 `json:"site_id,omitempty"`
+	}{
+		Name:           name,
+		Location:       fmt.Sprintf("%f,%f", lat, lng),
+		CameraHardware: hardware,
+	}
+	if siteID != 0 {
+		body.SiteID = &siteID
+	}
+	return o.post("/capturesources", body)
+}
+
+// CreateAnnotation creates an annotation of the segment of a video
+// stream from start to end, returning its ID. The observation
+// describes what was observed, e.g., {"label": "whale"}.
+func (o *OpenfishService) CreateAnnotation(videostream int64, start, end time.Time, observation map[string]string) (int64, error) {
+	type timespan struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	return o.post("/annotations", struct {
+		VideostreamID int64             `json:"videostream_id"`
+		Timespan      timespan          `json:"timespan"`
+		Observation   map[string]string `json:"observation"`
+	}{
+		VideostreamID: videostream,
+		Timespan:      timespan{Start: start.Format(time.RFC3339), End: end.Format(time.RFC3339)},
+		Observation:   observation,
+	})
+}
+
+// post posts v as JSON to the given OpenFish API path, returning the
+// ID of the created entity, if any.
+func (o *OpenfishService) post(path string, v any) (int64, error) {
+	// Create new client to connect to OpenFish.
+	client, err := idtoken.NewClient(context.Background(), o.Audience, idtoken.WithCredentialsJSON(o.Credentials))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	body := bytes.NewReader(jsonBytes)
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
 
-	resp, err := client.Post("https://openfish.appspot.com/api/v1/videostreams", "application/json", body)
+	resp, err := client.Post(apiURL+path, "application/json", bytes.NewReader(jsonBytes))
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if resp.StatusCode != 201 {
-		return fmt.Errorf("openfish returned HTTP status: %s", resp.Status)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("openfish returned HTTP status: %s", resp.Status)
 	}
 
-	return nil
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("could not decode openfish response: %w", err)
+	}
+	return res.ID, nil
 }
@@ -136,7 +136,8 @@ func newBroadcastSystem(ctx context.Context, store Store, cfg *BroadcastConfig,
 // tick advances the broadcast system by one time step.
 // This will publish any events that weren't dealt with after context
 // cancellation the last time we ticked, and then publish a time event
-// to advanced the state machines again.
+// to advanced the state machines again. Finally, broadcasts that have
+// overrun their end are forcibly finished.
 func (bs *broadcastSystem) tick() error {
 	// Don't do anything if not enabled.
 	if !bs.ctx.cfg.Enabled {
@@ -146,14 +147,18 @@ func (bs *broadcastSystem) tick() error {
 				_cfg.AttemptingToStart = false
 				_cfg.Transitioning = false
 				_cfg.Active = false
+				_cfg.Draining = false
 			}),
 			"could not update config with callback",
 			log.Printf,
 		)
+		bs.applyPendingChanges()
 		bs.log("not enabled, not doing anything")
 		return nil
 	}
 
+	bs.applyPendingChanges()
+
 	for _, event := range bs.ctx.cfg.Events {
 		e, err := stringToEvent(event)
 		if err != nil {
@@ -170,6 +175,10 @@ func (bs *broadcastSystem) tick() error {
 		return fmt.Errorf("could not clear config events: %w", err)
 	}
 
-	bs.ctx.bus.publish(timeEvent{time.Now()})
+	now := time.Now()
+	bs.ctx.bus.publish(timeEvent{now})
+
+	// Catch broadcasts that the time event should have finished.
+	bs.checkOverrun(now)
 	return nil
 }
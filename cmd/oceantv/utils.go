@@ -133,7 +133,11 @@ func updateConfigWithTransaction(ctx context.Context, store Store, skey int64, b
 	const typeVariable = "Variable"
 	key := store.NameKey(typeVariable, strconv.FormatInt(skey, 10)+"."+name)
 
-	var callBackErr error
+	var (
+		callBackErr error
+		prev        []byte
+		updated     []byte
+	)
 	updateConfig := func(ety datastore.Entity) {
 		v, ok := ety.(*model.Variable)
 		if !ok {
@@ -141,6 +145,7 @@ func updateConfigWithTransaction(ctx context.Context, store Store, skey int64, b
 			return
 		}
 
+		prev = []byte(v.Value)
 		var cfg BroadcastConfig
 		err := json.Unmarshal([]byte(v.Value), &cfg)
 		if err != nil {
@@ -156,6 +161,7 @@ func updateConfigWithTransaction(ctx context.Context, store Store, skey int64, b
 			return
 		}
 
+		updated = d
 		v.Value = string(d)
 		v.Updated = time.Now()
 	}
@@ -189,6 +195,7 @@ func updateConfigWithTransaction(ctx context.Context, store Store, skey int64, b
 				return
 			}
 
+			updated = d
 			v.Value = string(d)
 			v.Updated = time.Now()
 		}
@@ -205,7 +212,7 @@ func updateConfigWithTransaction(ctx context.Context, store Store, skey int64, b
 		return fmt.Errorf("error from broadcast update callback: %w", callBackErr)
 	}
 
-	return nil
+	return putBroadcastEntity(ctx, store, updated, prev)
 }
 
 type ErrBroadcastNotFound struct{ name string }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
 )
 
 func TestTry(t *testing.T) {
@@ -59,3 +63,63 @@ func TestTry(t *testing.T) {
 		})
 	}
 }
+
+// TestUpdateConfigMirror tests that updateConfigWithTransaction
+// mirrors a broadcast config to its entity only when it changes.
+func TestUpdateConfigMirror(t *testing.T) {
+	model.RegisterEntities()
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	const skey, name = 1, "Test"
+	setID := func(id string) func(cfg *BroadcastConfig) {
+		return func(cfg *BroadcastConfig) {
+			cfg.SKey = skey
+			cfg.Name = name
+			cfg.ID = id
+		}
+	}
+
+	err = updateConfigWithTransaction(ctx, store, skey, name, setID("a"))
+	if err != nil {
+		t.Fatalf("could not create config: %v", err)
+	}
+	c, err := model.GetBroadcastConfig(ctx, store, skey, name)
+	if err != nil {
+		t.Fatalf("could not get mirrored config: %v", err)
+	}
+	if c.ID != "a" {
+		t.Errorf("mirrored config ID = %q, want %q", c.ID, "a")
+	}
+
+	// An unchanged config is not mirrored again, so the deleted entity
+	// stays deleted.
+	err = model.DeleteBroadcastConfig(ctx, store, skey, name)
+	if err != nil {
+		t.Fatalf("could not delete mirrored config: %v", err)
+	}
+	err = updateConfigWithTransaction(ctx, store, skey, name, setID("a"))
+	if err != nil {
+		t.Fatalf("could not update config: %v", err)
+	}
+	var e model.BroadcastConfig
+	err = store.Get(ctx, store.NameKey("BroadcastConfig", "1.Test"), &e)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("unchanged config was mirrored, got error: %v", err)
+	}
+
+	err = updateConfigWithTransaction(ctx, store, skey, name, setID("b"))
+	if err != nil {
+		t.Fatalf("could not update config: %v", err)
+	}
+	c, err = model.GetBroadcastConfig(ctx, store, skey, name)
+	if err != nil {
+		t.Fatalf("could not get mirrored config: %v", err)
+	}
+	if c.ID != "b" {
+		t.Errorf("mirrored config ID = %q, want %q", c.ID, "b")
+	}
+}
@@ -0,0 +1,156 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// loginHistoryPeriod is the period of login history against which
+// logins are compared to determine if they are from a new location.
+const loginHistoryPeriod = 90 * 24 * time.Hour
+
+// LoginNotifier is a function that notifies a user of a login from a
+// new location. See UserAuth.Notify.
+type LoginNotifier func(ctx context.Context, e *model.LoginEvent) error
+
+// audit records a login event for the OAuth callback, which failed if
+// loginErr is not nil, then notifies if the login is from a new
+// location. Errors are logged, since auditing is secondary to login.
+func (ua *UserAuth) audit(h backend.Handler, email string, loginErr error) {
+	if ua.Store == nil {
+		return
+	}
+	ctx := context.Background()
+	ip := clientIP(h)
+	e := &model.LoginEvent{
+		Email:     email,
+		Project:   ua.ProjectID,
+		IP:        ip,
+		UserAgent: h.Header("User-Agent"),
+		Location:  clientLocation(h, ip),
+		Region:    clientRegion(h, ip),
+		Success:   loginErr == nil,
+	}
+	if loginErr != nil {
+		e.Error = loginErr.Error()
+	}
+
+	var notify bool
+	if e.Success {
+		var err error
+		notify, err = isNewLocation(ctx, ua.Store, e.Email, e.Region, time.Now())
+		if err != nil {
+			log.Printf("could not check login location for %s: %v", e.Email, err)
+		}
+	}
+
+	err := model.CreateLoginEvent(ctx, ua.Store, e)
+	if err != nil {
+		log.Printf("could not record login event for %s: %v", e.Email, err)
+	}
+
+	if !notify || ua.Notify == nil {
+		return
+	}
+	log.Printf("login by %s from new location %s", e.Email, e.Location)
+	err = ua.Notify(ctx, e)
+	if err != nil {
+		log.Printf("could not notify login by %s from new location: %v", e.Email, err)
+	}
+}
+
+// isNewLocation returns true if a user has previously logged in
+// successfully within the login history period, but not from the given
+// region. Regions rather than cities are compared, since the city
+// reported for a client varies from day to day. A user's first login
+// is therefore not from a new location. Impersonations of the user are
+// ignored.
+func isNewLocation(ctx context.Context, store datastore.Store, email, region string, t time.Time) (bool, error) {
+	es, err := model.GetLoginEvents(ctx, store, email, t.Add(-loginHistoryPeriod), t)
+	if err != nil {
+		return false, err
+	}
+	var previous bool
+	for _, e := range es {
+		if !e.Success || e.Impersonator != "" {
+			continue
+		}
+		if e.Region == region {
+			return false, nil
+		}
+		previous = true
+	}
+	return previous, nil
+}
+
+// clientIP returns the client's IP address, as reported by App Engine
+// if available.
+func clientIP(h backend.Handler) string {
+	if ip := h.Header("X-Appengine-User-IP"); ip != "" {
+		return ip
+	}
+	return h.RemoteAddr()
+}
+
+// clientLocation returns the client's location, e.g., "Adelaide, sa,
+// AU", as reported by App Engine, or the IP address if unknown.
+func clientLocation(h backend.Handler, ip string) string {
+	return appEngineLocation(h, ip, "X-Appengine-City", "X-Appengine-Region", "X-Appengine-Country")
+}
+
+// clientRegion returns the client's region, e.g., "sa, AU", as
+// reported by App Engine, or the IP address if unknown.
+func clientRegion(h backend.Handler, ip string) string {
+	return appEngineLocation(h, ip, "X-Appengine-Region", "X-Appengine-Country")
+}
+
+// appEngineLocation returns the values of the given App Engine
+// location headers that are known, joined by commas, or the IP address
+// if none are known.
+func appEngineLocation(h backend.Handler, ip string, headers ...string) string {
+	var parts []string
+	for _, k := range headers {
+		v := h.Header(k)
+		if v != "" && v != "?" && v != "ZZ" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 {
+		return ip
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LoginMessage returns the subject and body of a notification of a
+// login from a new location.
+func LoginMessage(e *model.LoginEvent) (subject, body string) {
+	subject = fmt.Sprintf("New login to %s from %s", e.Project, e.Location)
+	body = fmt.Sprintf("%s logged in to %s from a new location at %s.\n\nLocation: %s\nIP address: %s\nBrowser: %s\n\nIf this was not you, please contact an administrator.\n",
+		e.Email, e.Project, e.Created.UTC().Format(time.RFC3339), e.Location, e.IP, e.UserAgent)
+	return subject, body
+}
@@ -0,0 +1,113 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestAudit tests that login events are recorded and that only
+// successful logins from new locations are notified.
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	var notified []string
+	ua := &UserAuth{
+		ProjectID: "oceanbench",
+		Store:     store,
+		Notify: func(ctx context.Context, e *model.LoginEvent) error {
+			notified = append(notified, e.Location)
+			return nil
+		},
+	}
+	handler := func(city, region string) backend.Handler {
+		r := httptest.NewRequest("GET", "/oauth2callback", nil)
+		r.RemoteAddr = "203.0.113.7:1234"
+		r.Header.Set("User-Agent", "test")
+		if city != "" {
+			r.Header.Set("X-Appengine-City", city)
+			r.Header.Set("X-Appengine-Region", region)
+			r.Header.Set("X-Appengine-Country", "AU")
+		}
+		return backend.NewNetHandler(httptest.NewRecorder(), r, nil)
+	}
+
+	const email = "test@ausocean.org"
+	logins := []struct {
+		city   string
+		region string
+		err    error
+	}{
+		{city: "adelaide", region: "sa"},                                // First login.
+		{city: "adelaide", region: "sa"},                                // Known location.
+		{city: "glenelg", region: "sa"},                                 // Known region.
+		{city: "perth", region: "wa", err: errors.New("invalid state")}, // Failed login.
+		{city: "perth", region: "wa"},                                   // New location.
+		{city: ""},                                                      // Unknown location, i.e., IP.
+	}
+	for _, l := range logins {
+		ua.audit(handler(l.city, l.region), email, l.err)
+	}
+
+	want := []string{"perth, wa, AU", "203.0.113.7"}
+	if len(notified) != len(want) {
+		t.Fatalf("got notifications for %v, want %v", notified, want)
+	}
+	for i := range want {
+		if notified[i] != want[i] {
+			t.Errorf("got notification for %s, want %s", notified[i], want[i])
+		}
+	}
+
+	es, err := model.GetLoginEvents(ctx, store, email, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("could not get login events: %v", err)
+	}
+	if len(es) != len(logins) {
+		t.Fatalf("got %d login events, want %d", len(es), len(logins))
+	}
+	var failed int
+	for _, e := range es {
+		if e.IP != "203.0.113.7" || e.UserAgent != "test" || e.Project != "oceanbench" {
+			t.Errorf("unexpected login event: %+v", e)
+		}
+		if !e.Success {
+			failed++
+			if e.Error != "invalid state" {
+				t.Errorf("got error %q, want %q", e.Error, "invalid state")
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("got %d failed login events, want 1", failed)
+	}
+}
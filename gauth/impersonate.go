@@ -0,0 +1,183 @@
+/*
+DESCRIPTION
+  Impersonation, which lets support engineers view a service as
+  another user in order to reproduce permission issues.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/cloud/model"
+)
+
+const (
+	// Key used in the default session to store the impersonation, if any.
+	impersonationKey = "impersonation"
+
+	// Maximum duration of an impersonation.
+	MaxImpersonation = time.Hour
+)
+
+var (
+	ErrImpersonating    = errors.New("already impersonating a user")
+	ErrNotImpersonating = errors.New("not impersonating a user")
+)
+
+// Impersonation holds the details of an admin viewing a service as
+// another user.
+type Impersonation struct {
+	Admin   string    // Email address of the admin.
+	Email   string    // Email address of the user being impersonated.
+	Reason  string    // Reason given by the admin, e.g., a support ticket.
+	Expires time.Time // Date/time the impersonation ends.
+}
+
+// Impersonate starts an impersonation of the user with the given
+// email address by the logged-in user, which lasts for d, up to
+// MaxImpersonation. While impersonating, GetProfile returns a profile
+// for the impersonated user, whose Impersonator is the admin's email
+// address. Services must treat such sessions as read-only. It is the
+// caller's responsibility to check that the logged-in user is
+// authorized to impersonate. Impersonations are audited if a Store is
+// configured.
+func (ua *UserAuth) Impersonate(h backend.Handler, email, reason string, d time.Duration) error {
+	admin, err := ua.GetProfile(h)
+	if err != nil {
+		return err
+	}
+	if admin.Impersonator != "" {
+		return ErrImpersonating
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || strings.EqualFold(email, admin.Email) {
+		return fmt.Errorf("invalid user to impersonate: %q", email)
+	}
+	if d <= 0 || d > MaxImpersonation {
+		d = MaxImpersonation
+	}
+
+	ua.Lock()
+	defer ua.Unlock()
+	imp := &Impersonation{Admin: admin.Email, Email: email, Reason: reason, Expires: time.Now().Add(d)}
+	err = ua.putImpersonation(h, imp)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s started impersonating %s until %s: %s", imp.Admin, imp.Email, imp.Expires.Format(time.RFC3339), imp.Reason)
+	ua.auditImpersonation(h, imp)
+	return nil
+}
+
+// StopImpersonating ends the current impersonation, if any, so that
+// GetProfile returns the admin's own profile again.
+func (ua *UserAuth) StopImpersonating(h backend.Handler) error {
+	p, err := ua.GetProfile(h)
+	if err != nil {
+		return err
+	}
+	if p.Impersonator == "" {
+		return ErrNotImpersonating
+	}
+
+	ua.Lock()
+	defer ua.Unlock()
+	err = ua.putImpersonation(h, &Impersonation{})
+	if err != nil {
+		return err
+	}
+	log.Printf("%s stopped impersonating %s", p.Impersonator, p.Email)
+	return nil
+}
+
+// putImpersonation stores an impersonation in the default session. An
+// empty impersonation ends any current impersonation.
+func (ua *UserAuth) putImpersonation(h backend.Handler, imp *Impersonation) error {
+	if ua.cfg == nil {
+		return NotConfigured
+	}
+	sess, err := h.LoadSession(ua.SessionID)
+	if err != nil {
+		return SessionNotFound
+	}
+	err = sess.Set(impersonationKey, imp)
+	if err != nil {
+		return fmt.Errorf("unable to set impersonation key: %w", err)
+	}
+	return h.SaveSession(sess)
+}
+
+// impersonated returns the profile of the user being impersonated by
+// the owner of the given profile, if any, or the given profile
+// otherwise. Expired impersonations are ended. The impersonated
+// profile shares the admin's profile data.
+func (ua *UserAuth) impersonated(h backend.Handler, sess backend.Session, profile *Profile) (*Profile, error) {
+	imp := &Impersonation{}
+	err := sess.Get(impersonationKey, &imp)
+	if err != nil || imp.Email == "" {
+		return profile, nil
+	}
+	if imp.Admin != profile.Email {
+		return profile, nil // Stale impersonation of a previous login.
+	}
+	if time.Now().After(imp.Expires) {
+		log.Printf("impersonation of %s by %s expired", imp.Email, imp.Admin)
+		err = sess.Set(impersonationKey, &Impersonation{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to set impersonation key: %w", err)
+		}
+		err = h.SaveSession(sess)
+		if err != nil {
+			return nil, fmt.Errorf("session save error: %w", err)
+		}
+		return profile, nil
+	}
+	return &Profile{Email: imp.Email, Data: profile.Data, Impersonator: imp.Admin, ImpersonationExpires: imp.Expires}, nil
+}
+
+// auditImpersonation records the start of an impersonation as a login
+// event for the impersonated user.
+func (ua *UserAuth) auditImpersonation(h backend.Handler, imp *Impersonation) {
+	if ua.Store == nil {
+		return
+	}
+	ip := clientIP(h)
+	e := &model.LoginEvent{
+		Email:        imp.Email,
+		Project:      ua.ProjectID,
+		IP:           ip,
+		UserAgent:    h.Header("User-Agent"),
+		Location:     clientLocation(h, ip),
+		Region:       clientRegion(h, ip),
+		Success:      true,
+		Impersonator: imp.Admin,
+		Reason:       imp.Reason,
+	}
+	err := model.CreateLoginEvent(context.Background(), ua.Store, e)
+	if err != nil {
+		log.Printf("could not record impersonation of %s by %s: %v", imp.Email, imp.Admin, err)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+
+	"github.com/ausocean/cloud/backend"
+)
+
+// TestImpersonate tests starting, stopping and expiry of impersonations.
+func TestImpersonate(t *testing.T) {
+	gob.Register(&oauth2.Token{})
+	gob.Register(&Profile{})
+	gob.Register(&Impersonation{})
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	ua := &UserAuth{ProjectID: "vidgrind", SessionID: "vidgrindAuth", cfg: &oauth2.Config{}}
+
+	// Requests carry the cookies of previous responses.
+	var cookies []*http.Cookie
+	handler := func() (backend.Handler, *httptest.ResponseRecorder) {
+		r := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		return backend.NewNetHandler(w, r, store), w
+	}
+	do := func(f func(h backend.Handler) error) error {
+		h, w := handler()
+		err := f(h)
+		if c := w.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		return err
+	}
+	profile := func() *Profile {
+		h, _ := handler()
+		p, err := ua.GetProfile(h)
+		if err != nil {
+			t.Fatalf("GetProfile returned error: %v", err)
+		}
+		return p
+	}
+
+	err := do(func(h backend.Handler) error {
+		return ua.LoginVerified(h, &Profile{Email: "admin@ausocean.org", Data: "1:Rapid Bay"})
+	})
+	if err != nil {
+		t.Fatalf("LoginVerified returned error: %v", err)
+	}
+	err = do(func(h backend.Handler) error { return ua.StopImpersonating(h) })
+	if !errors.Is(err, ErrNotImpersonating) {
+		t.Errorf("StopImpersonating returned %v, expected %v", err, ErrNotImpersonating)
+	}
+
+	err = do(func(h backend.Handler) error {
+		return ua.Impersonate(h, "User@example.org", "ticket 42", 2*MaxImpersonation)
+	})
+	if err != nil {
+		t.Fatalf("Impersonate returned error: %v", err)
+	}
+	p := profile()
+	if p.Email != "user@example.org" || p.Impersonator != "admin@ausocean.org" || p.Data != "1:Rapid Bay" {
+		t.Errorf("got impersonated profile %+v", p)
+	}
+	if p.ImpersonationExpires.After(time.Now().Add(MaxImpersonation)) {
+		t.Errorf("impersonation expires at %v, later than the maximum", p.ImpersonationExpires)
+	}
+	err = do(func(h backend.Handler) error { return ua.Impersonate(h, "other@example.org", "", time.Minute) })
+	if !errors.Is(err, ErrImpersonating) {
+		t.Errorf("nested Impersonate returned %v, expected %v", err, ErrImpersonating)
+	}
+
+	err = do(func(h backend.Handler) error { return ua.StopImpersonating(h) })
+	if err != nil {
+		t.Fatalf("StopImpersonating returned error: %v", err)
+	}
+	p = profile()
+	if p.Email != "admin@ausocean.org" || p.Impersonator != "" {
+		t.Errorf("got profile %+v after stopping", p)
+	}
+
+	// Expired impersonations end automatically.
+	err = do(func(h backend.Handler) error {
+		return ua.putImpersonation(h, &Impersonation{Admin: "admin@ausocean.org", Email: "user@example.org", Expires: time.Now().Add(-time.Second)})
+	})
+	if err != nil {
+		t.Fatalf("putImpersonation returned error: %v", err)
+	}
+	p = profile()
+	if p.Email != "admin@ausocean.org" || p.Impersonator != "" {
+		t.Errorf("got profile %+v after expiry", p)
+	}
+}
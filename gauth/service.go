@@ -0,0 +1,203 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Service token errors.
+var (
+	ErrUnknownServiceKey   = errors.New("unknown service key")
+	ErrInvalidServiceToken = errors.New("invalid service token")
+	ErrWrongAudience       = errors.New("service token has wrong audience")
+	ErrMissingScope        = errors.New("service token is missing scope")
+)
+
+// KeyRing holds the keys used to sign and validate service tokens.
+// Tokens are signed with the current key and validated with whichever
+// key signed them, identified by the kid header. To rotate keys, add
+// a new current key, then remove the old one once tokens signed with
+// it have expired.
+type KeyRing struct {
+	current string            // ID of the current key.
+	keys    map[string][]byte // Keys by ID.
+}
+
+// ParseKeyRing parses a key ring from a comma-separated list of keys,
+// each of which is an ID and a hex-encoded secret separated by an
+// equals sign, e.g., "k2=0a1b...,k1=2c3d...". The first key is the
+// current key.
+func ParseKeyRing(s string) (*KeyRing, error) {
+	var current string
+	keys := make(map[string][]byte)
+	for _, kv := range strings.Split(s, ",") {
+		id, h, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid service key: %s", kv)
+		}
+		secret, err := hex.DecodeString(h)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid service key %s: %v", id, err)
+		}
+		if current == "" {
+			current = id
+		}
+		keys[id] = secret
+	}
+	return &KeyRing{current: current, keys: keys}, nil
+}
+
+// ServiceClaims are the claims of a service token.
+type ServiceClaims struct {
+	Issuer   string    // Service that issued the token, e.g., oceanbench.
+	Audience string    // Service the token is for, e.g., oceantv.
+	Scopes   []string  // Operations the token permits.
+	Expires  time.Time // Date/time the token expires.
+}
+
+// HasScope returns true if the claims include the given scope.
+func (c *ServiceClaims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// IssueServiceToken issues a token, signed with the current key of
+// the key ring, that permits the issuer to perform the given scopes
+// of operations on the audience service until the token expires.
+func (kr *KeyRing) IssueServiceToken(issuer, audience string, scopes []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   issuer,
+		"aud":   audience,
+		"scope": strings.Join(scopes, " "),
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	})
+	tok.Header["kid"] = kr.current
+	tokString, err := tok.SignedString(kr.keys[kr.current])
+	if err != nil {
+		return "", fmt.Errorf("error signing token: %w", err)
+	}
+	return tokString, nil
+}
+
+// ValidateServiceToken validates a service token for the audience
+// service, which must be unexpired and include the given scope, if
+// any. Any "Bearer" string prefix will be ignored.
+func (kr *KeyRing) ValidateServiceToken(tokString, audience, scope string) (*ServiceClaims, error) {
+	tokString = strings.TrimPrefix(tokString, "Bearer ")
+	if tokString == "" {
+		return nil, errors.New("missing token")
+	}
+	tok, err := jwt.Parse(tokString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		secret, ok := kr.keys[kid]
+		if !ok {
+			return nil, ErrUnknownServiceKey
+		}
+		return secret, nil
+	}, jwt.WithExpirationRequired(), jwt.WithAudience(audience))
+	if errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		return nil, ErrWrongAudience
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidServiceToken, err)
+	}
+	mc, ok := tok.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidServiceToken
+	}
+
+	c := &ServiceClaims{Audience: audience}
+	c.Issuer, _ = mc.GetIssuer()
+	if exp, err := mc.GetExpirationTime(); err == nil && exp != nil {
+		c.Expires = exp.Time
+	}
+	if s, ok := mc["scope"].(string); ok && s != "" {
+		c.Scopes = strings.Fields(s)
+	}
+	if scope != "" && !c.HasScope(scope) {
+		return nil, ErrMissingScope
+	}
+	return c, nil
+}
+
+// serviceClaimsKey is the context key for service claims.
+type serviceClaimsKey struct{}
+
+// ServiceClaimsFromContext returns the service claims added to a
+// request context by RequireServiceToken, if any.
+func ServiceClaimsFromContext(ctx context.Context) (*ServiceClaims, bool) {
+	c, ok := ctx.Value(serviceClaimsKey{}).(*ServiceClaims)
+	return c, ok
+}
+
+// RequireServiceToken returns net/http middleware that only calls
+// next for requests with a valid service token for the audience and
+// scope in the Authorization header. The claims are added to the
+// request context.
+func (kr *KeyRing) RequireServiceToken(audience, scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := kr.ValidateServiceToken(r.Header.Get("Authorization"), audience, scope)
+		if err != nil {
+			http.Error(w, err.Error(), serviceTokenStatus(err))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serviceClaimsKey{}, c)))
+	})
+}
+
+// FiberServiceClaimsKey is the fiber.Ctx locals key for service claims.
+const FiberServiceClaimsKey = "serviceClaims"
+
+// FiberRequireServiceToken returns Fiber middleware that is the
+// equivalent of RequireServiceToken. The claims are stored in the
+// FiberServiceClaimsKey local.
+func (kr *KeyRing) FiberRequireServiceToken(audience, scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := kr.ValidateServiceToken(c.Get(fiber.HeaderAuthorization), audience, scope)
+		if err != nil {
+			return fiber.NewError(serviceTokenStatus(err), err.Error())
+		}
+		c.Locals(FiberServiceClaimsKey, claims)
+		return c.Next()
+	}
+}
+
+// serviceTokenStatus returns the HTTP status for a service token
+// validation error.
+func serviceTokenStatus(err error) int {
+	if errors.Is(err, ErrWrongAudience) || errors.Is(err, ErrMissingScope) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
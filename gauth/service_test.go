@@ -0,0 +1,143 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestServiceTokens tests issuing and validating service tokens,
+// including key rotation.
+func TestServiceTokens(t *testing.T) {
+	old, err := ParseKeyRing("k1=3af320667aba6a8b9ff9dc475adb382c")
+	if err != nil {
+		t.Fatalf("could not parse key ring: %v", err)
+	}
+	// Rotated key ring, which still accepts tokens signed with k1.
+	kr, err := ParseKeyRing("k2=9ff9dc475adb382c3af320667aba6a8b, k1=3af320667aba6a8b9ff9dc475adb382c")
+	if err != nil {
+		t.Fatalf("could not parse key ring: %v", err)
+	}
+	other, err := ParseKeyRing("k3=0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatalf("could not parse key ring: %v", err)
+	}
+
+	issue := func(kr *KeyRing, audience string, ttl time.Duration) string {
+		tok, err := kr.IssueServiceToken("oceanbench", audience, []string{"broadcast:read", "cron:run"}, ttl)
+		if err != nil {
+			t.Fatalf("could not issue token: %v", err)
+		}
+		return tok
+	}
+
+	tests := []struct {
+		tok      string
+		audience string
+		scope    string
+		want     error
+	}{
+		{tok: issue(kr, "oceantv", time.Hour), audience: "oceantv", scope: "broadcast:read"},
+		{tok: "Bearer " + issue(kr, "oceantv", time.Hour), audience: "oceantv", scope: "cron:run"},
+		{tok: issue(old, "oceantv", time.Hour), audience: "oceantv"},
+		{tok: issue(kr, "oceantv", time.Hour), audience: "oceantv", scope: "broadcast:write", want: ErrMissingScope},
+		{tok: issue(kr, "oceancron", time.Hour), audience: "oceantv", want: ErrWrongAudience},
+		{tok: issue(kr, "oceantv", -time.Minute), audience: "oceantv", want: ErrInvalidServiceToken},
+		{tok: issue(other, "oceantv", time.Hour), audience: "oceantv", want: ErrInvalidServiceToken},
+	}
+	for i, test := range tests {
+		c, err := kr.ValidateServiceToken(test.tok, test.audience, test.scope)
+		if !errors.Is(err, test.want) {
+			t.Errorf("test %d: got error %v, want %v", i, err, test.want)
+			continue
+		}
+		if err == nil && (c.Issuer != "oceanbench" || !c.HasScope("cron:run") || c.Expires.IsZero()) {
+			t.Errorf("test %d: unexpected claims %+v", i, c)
+		}
+	}
+
+	// Tokens signed with a removed key are rejected.
+	_, err = other.ValidateServiceToken(issue(kr, "oceantv", time.Hour), "oceantv", "")
+	if !errors.Is(err, ErrInvalidServiceToken) {
+		t.Errorf("got error %v for removed key, want %v", err, ErrInvalidServiceToken)
+	}
+
+	for _, s := range []string{"", "k1", "k1=xyz", "=3af3"} {
+		_, err := ParseKeyRing(s)
+		if err == nil {
+			t.Errorf("ParseKeyRing(%q) did not return error", s)
+		}
+	}
+
+	// net/http middleware.
+	h := kr.RequireServiceToken("oceantv", "cron:run", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ServiceClaimsFromContext(r.Context())
+		if !ok || c.Issuer != "oceanbench" {
+			t.Errorf("missing or unexpected claims: %+v", c)
+		}
+	}))
+	for _, test := range []struct {
+		tok  string
+		want int
+	}{
+		{tok: issue(kr, "oceantv", time.Hour), want: http.StatusOK},
+		{tok: issue(kr, "oceancron", time.Hour), want: http.StatusForbidden},
+		{tok: "", want: http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/checkbroadcasts", nil)
+		r.Header.Set("Authorization", "Bearer "+test.tok)
+		h.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("got status %d, want %d", w.Code, test.want)
+		}
+	}
+
+	// Fiber middleware.
+	app := fiber.New()
+	app.Get("/checkbroadcasts", kr.FiberRequireServiceToken("oceantv", "cron:run"), func(c *fiber.Ctx) error {
+		if claims, ok := c.Locals(FiberServiceClaimsKey).(*ServiceClaims); !ok || claims.Issuer != "oceanbench" {
+			t.Errorf("missing or unexpected claims: %+v", claims)
+		}
+		return nil
+	})
+	for _, test := range []struct {
+		tok  string
+		want int
+	}{
+		{tok: issue(kr, "oceantv", time.Hour), want: http.StatusOK},
+		{tok: "invalid", want: http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest("GET", "/checkbroadcasts", nil)
+		r.Header.Set("Authorization", "Bearer "+test.tok)
+		resp, err := app.Test(r)
+		if err != nil {
+			t.Fatalf("could not test fiber app: %v", err)
+		}
+		if resp.StatusCode != test.want {
+			t.Errorf("got fiber status %d, want %d", resp.StatusCode, test.want)
+		}
+	}
+}
@@ -45,6 +45,7 @@ import (
 	"time"
 
 	"github.com/ausocean/cloud/backend"
+	"github.com/ausocean/openfish/datastore"
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
 	"golang.org/x/oauth2"
@@ -66,17 +67,27 @@ const (
 
 	// Default OAuth redirect URL.
 	oauthRedirectUTL = "http://localhost:8080/oauth2callback"
+
+	// Token type and lifetime of sessions started by LoginVerified,
+	// whose tokens are not OAuth2 tokens and cannot be refreshed.
+	verifiedTokenType = "verified"
+	verifiedMaxAge    = 7 * 24 * time.Hour
 )
 
 // Profile holds info about the logged-in user.
 // GivenName, FamilyName, Email, and Locale come from the Google user profile.
 // Data is optional non-persistent data associated with the user.
+// Impersonator is the email address of the admin viewing as the user,
+// if any, in which case the session must be treated as read-only. See
+// UserAuth.Impersonate.
 type Profile struct {
-	GivenName  string
-	FamilyName string
-	Email      string
-	Locale     string
-	Data       string
+	GivenName            string
+	FamilyName           string
+	Email                string
+	Locale               string
+	Data                 string
+	Impersonator         string
+	ImpersonationExpires time.Time
 }
 
 // UserAuth implements authentication of Google users using OAuth2.
@@ -97,6 +108,8 @@ type UserAuth struct {
 	MaxAge    time.Duration         // OAuth2 max age.
 	cfg       *oauth2.Config        // OAuth2 configuration.
 	NetStore  *sessions.CookieStore // Session state (only used for net/http implementations)
+	Store     datastore.Store       // Login event store (optional).
+	Notify    LoginNotifier         // Notifies logins from new locations (optional).
 }
 
 var (
@@ -123,6 +136,7 @@ func (ua *UserAuth) Init(h backend.Handler) {
 	// Register gobs for the OAuth2 token and user profile.
 	gob.Register(&oauth2.Token{})
 	gob.Register(&Profile{})
+	gob.Register(&Impersonation{})
 
 	// Get our client secrets.
 	ctx := context.Background()
@@ -227,8 +241,9 @@ func validateRedirectURL(path string) (string, error) {
 }
 
 // CallbackHandler completes the OAuth flow, retrieves the user's
-// profile information and stores info in the default session.
-func (ua *UserAuth) CallbackHandler(h backend.Handler) error {
+// profile information and stores info in the default session. The
+// outcome is audited if a Store is configured.
+func (ua *UserAuth) CallbackHandler(h backend.Handler) (err error) {
 	ua.Lock()
 	defer ua.Unlock()
 
@@ -236,6 +251,9 @@ func (ua *UserAuth) CallbackHandler(h backend.Handler) error {
 		return NotConfigured
 	}
 
+	var email string
+	defer func() { ua.audit(h, email, err) }()
+
 	oauthFlowSession, err := h.LoadSession(h.FormValue("state"))
 	if err != nil {
 		return fmt.Errorf("could not get state parameter from session store: %w", err)
@@ -264,6 +282,7 @@ func (ua *UserAuth) CallbackHandler(h backend.Handler) error {
 	if err != nil {
 		return fmt.Errorf("could not fetch profile: %w", err)
 	}
+	email = profile.Email
 
 	err = sess.Set(oauthTokenSessionKey, tok)
 	if err != nil {
@@ -281,6 +300,41 @@ func (ua *UserAuth) CallbackHandler(h backend.Handler) error {
 	return h.Redirect(redirectURL, http.StatusFound)
 }
 
+// LoginVerified logs in a user whose email address has been verified
+// by means other than Google, e.g., an emailed magic link, storing the
+// given profile in the default session. Since such sessions cannot be
+// refreshed, the user must log in again after verifiedMaxAge. The
+// outcome is audited if a Store is configured.
+func (ua *UserAuth) LoginVerified(h backend.Handler, profile *Profile) (err error) {
+	ua.Lock()
+	defer ua.Unlock()
+
+	if ua.cfg == nil {
+		return NotConfigured
+	}
+
+	defer func() { ua.audit(h, profile.Email, err) }()
+
+	sess, err := h.LoadSession(ua.SessionID)
+	if err != nil {
+		return fmt.Errorf("could not create session %s: %w", ua.SessionID, err)
+	}
+	tok := &oauth2.Token{TokenType: verifiedTokenType, Expiry: time.Now().Add(verifiedMaxAge)}
+	err = sess.Set(oauthTokenSessionKey, tok)
+	if err != nil {
+		return fmt.Errorf("unable to set token session key: %w", err)
+	}
+	err = sess.Set(profileKey, profile)
+	if err != nil {
+		return fmt.Errorf("unable to set profile key: %w", err)
+	}
+	err = h.SaveSession(sess)
+	if err != nil {
+		return fmt.Errorf("could not save session %s: %w", ua.SessionID, err)
+	}
+	return nil
+}
+
 // fetchProfile retrieves profile info for the logged-in user, i.e.,
 // the user associated with the client's OAuth token, via the Google
 // People API, which must be enabled for the App Engine project.
@@ -343,6 +397,8 @@ func (ua *UserAuth) LogoutHandler(h backend.Handler) error {
 // If the OAuth session is still valid, the profile is retrieved from the session store.
 // If not, a new client request is issued to obtain the profile anew.
 // Optional profile data is preserved.
+// If the user is impersonating another user, the other user's profile
+// is returned instead.
 func (ua *UserAuth) GetProfile(h backend.Handler) (*Profile, error) {
 	ua.Lock()
 	defer ua.Unlock()
@@ -367,8 +423,11 @@ func (ua *UserAuth) GetProfile(h backend.Handler) (*Profile, error) {
 	if err != nil {
 		return nil, ProfileNotFound
 	}
-	if tok.Valid() {
-		return profile, nil
+	if tokenValid(tok) {
+		return ua.impersonated(h, sess, profile)
+	}
+	if tok.TokenType == verifiedTokenType {
+		return nil, TokenNotFound // Verified sessions cannot be refreshed.
 	}
 
 	// Issue a new client request to refresh the OAuth token.
@@ -400,7 +459,16 @@ func (ua *UserAuth) GetProfile(h backend.Handler) (*Profile, error) {
 		return nil, fmt.Errorf("session save error: %w", err)
 	}
 
-	return profile, nil
+	return ua.impersonated(h, sess, profile)
+}
+
+// tokenValid returns true if a session token is valid. Tokens of
+// verified sessions have no access token, so only expire.
+func tokenValid(tok *oauth2.Token) bool {
+	if tok.TokenType == verifiedTokenType {
+		return time.Now().Before(tok.Expiry)
+	}
+	return tok.Valid()
 }
 
 // PutData updates optional data for the logged-in user.
@@ -420,7 +488,7 @@ func (ua *UserAuth) PutData(h backend.Handler, data string) error {
 	if err != nil {
 		return TokenNotFound
 	}
-	if !tok.Valid() {
+	if !tokenValid(tok) {
 		return fmt.Errorf("token invalid, token: %+v", tok)
 	}
 	profile := &Profile{}
@@ -0,0 +1,87 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package gauth
+
+import (
+	"encoding/gob"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+
+	"github.com/ausocean/cloud/backend"
+)
+
+// TestLoginVerified tests that verified logins start sessions whose
+// profiles are returned until they expire.
+func TestLoginVerified(t *testing.T) {
+	gob.Register(&oauth2.Token{})
+	gob.Register(&Profile{})
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	ua := &UserAuth{ProjectID: "ausoceantv", SessionID: "ausoceantvAuth", cfg: &oauth2.Config{}}
+
+	w := httptest.NewRecorder()
+	err := ua.LoginVerified(backend.NewNetHandler(w, httptest.NewRequest("GET", "/verify", nil), store), &Profile{Email: "viewer@example.org"})
+	if err != nil {
+		t.Fatalf("LoginVerified returned error: %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/profile", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	p, err := ua.GetProfile(backend.NewNetHandler(httptest.NewRecorder(), r, store))
+	if err != nil {
+		t.Fatalf("GetProfile returned error: %v", err)
+	}
+	if p.Email != "viewer@example.org" {
+		t.Errorf("got profile %+v", p)
+	}
+
+	// A request without the session cookie is not logged in.
+	r = httptest.NewRequest("GET", "/profile", nil)
+	_, err = ua.GetProfile(backend.NewNetHandler(httptest.NewRecorder(), r, store))
+	if !errors.Is(err, TokenNotFound) {
+		t.Errorf("GetProfile without session returned %v, expected %v", err, TokenNotFound)
+	}
+}
+
+// TestTokenValid tests validity of OAuth2 and verified session tokens.
+func TestTokenValid(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		tok  *oauth2.Token
+		want bool
+	}{
+		{tok: &oauth2.Token{AccessToken: "abc", Expiry: now.Add(time.Hour)}, want: true},
+		{tok: &oauth2.Token{AccessToken: "abc", Expiry: now.Add(-time.Hour)}, want: false},
+		{tok: &oauth2.Token{Expiry: now.Add(time.Hour)}, want: false},
+		{tok: &oauth2.Token{TokenType: verifiedTokenType, Expiry: now.Add(time.Hour)}, want: true},
+		{tok: &oauth2.Token{TokenType: verifiedTokenType, Expiry: now.Add(-time.Hour)}, want: false},
+	}
+	for i, test := range tests {
+		got := tokenValid(test.tok)
+		if got != test.want {
+			t.Errorf("test %d: got %t, expected %t", i, got, test.want)
+		}
+	}
+}
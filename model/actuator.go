@@ -115,7 +115,7 @@ func GetActuatorsBySite(ctx context.Context, store datastore.Store, sKey int64,
 	q.Filter("Skey =", sKey)
 	q.Order("Aid")
 	var all, toOut []Actuator
-	_, err := store.GetAll(ctx, q, &all)
+	_, err := getAll(ctx, store, q, &all, idxActuatorSite)
 	if err != nil {
 		return nil, err
 	}
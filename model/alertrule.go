@@ -0,0 +1,277 @@
+/*
+DESCRIPTION
+  AlertRule datastore type and functions, which implement sensor threshold
+  alerts.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeAlertRule = "AlertRule" // AlertRule datastore type.
+
+// ErrInvalidComparison is returned when an alert rule's comparison
+// operator is not supported.
+var ErrInvalidComparison = errors.New("invalid comparison")
+
+// AlertComparisons are the supported alert rule comparison operators.
+var AlertComparisons = []string{">", ">=", "<", "<=", "=", "!="}
+
+// AlertRule represents a user-defined alert on a sensor value. The
+// rule trips when a sensor value compares with the threshold for at
+// least the duration, e.g., "battery voltage < 11.5 for 600s", and
+// clears when a subsequent value no longer compares. Sensor values
+// are transformed values, i.e., in the sensor's units. Since and
+// Tripped hold the evaluation state between values.
+type AlertRule struct {
+	ID         int64     // Rule ID.
+	Skey       int64     // Site key.
+	Mac        int64     // Device MAC address.
+	Pin        string    // Sensor pin, e.g., A0.
+	Name       string    // Rule name, e.g., Low battery.
+	Comparison string    // Comparison operator, e.g., <.
+	Threshold  float64   // Threshold value.
+	Duration   int64     // Seconds the comparison must hold before tripping.
+	Recipients string    // Comma-separated recipients in addition to the site's.
+	Enabled    bool      // True if the rule is evaluated.
+	Since      int64     // Unix time from which the comparison has held, or zero.
+	Tripped    bool      // True if the rule has tripped and not yet cleared.
+	Updated    time.Time // Date/time last updated.
+}
+
+// Copy copies an alert rule to dst, or returns a copy of the alert rule when dst is nil.
+func (r *AlertRule) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var r2 *AlertRule
+	if dst == nil {
+		r2 = new(AlertRule)
+	} else {
+		var ok bool
+		r2, ok = dst.(*AlertRule)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*r2 = *r
+	return r2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (r *AlertRule) GetCache() datastore.Cache {
+	return nil
+}
+
+// RecipientList returns the rule's recipients as a slice.
+func (r *AlertRule) RecipientList() []string {
+	var recipients []string
+	for _, s := range strings.Split(r.Recipients, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			recipients = append(recipients, s)
+		}
+	}
+	return recipients
+}
+
+// String returns a rule as a human-readable condition, e.g., "Low
+// battery: A0 < 11.5 for 600s".
+func (r *AlertRule) String() string {
+	s := fmt.Sprintf("%s: %s %s %g", r.Name, r.Pin, r.Comparison, r.Threshold)
+	if r.Duration > 0 {
+		s += fmt.Sprintf(" for %ds", r.Duration)
+	}
+	return s
+}
+
+// Alert rule transitions, as returned by Evaluate.
+const (
+	AlertRuleUnchanged = iota // Neither tripped nor cleared.
+	AlertRuleTripped          // The rule has just tripped.
+	AlertRuleCleared          // The rule has just cleared.
+)
+
+// Evaluate evaluates a sensor value at Unix time ts against the rule,
+// updating the rule's state, and returns the resulting transition.
+// The rule should be updated with EvaluateAlertRule if its state
+// changed, i.e., if its Since or Tripped fields changed.
+func (r *AlertRule) Evaluate(value float64, ts int64) (int, error) {
+	holds, err := compare(value, r.Comparison, r.Threshold)
+	if err != nil {
+		return AlertRuleUnchanged, err
+	}
+	if !holds {
+		r.Since = 0
+		if r.Tripped {
+			r.Tripped = false
+			return AlertRuleCleared, nil
+		}
+		return AlertRuleUnchanged, nil
+	}
+	if r.Since == 0 || ts < r.Since {
+		r.Since = ts
+	}
+	if !r.Tripped && ts-r.Since >= r.Duration {
+		r.Tripped = true
+		return AlertRuleTripped, nil
+	}
+	return AlertRuleUnchanged, nil
+}
+
+// compare returns the result of comparing a value with a threshold
+// using the given comparison operator.
+func compare(value float64, op string, threshold float64) (bool, error) {
+	switch op {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "=":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	default:
+		return false, fmt.Errorf("%w: %s", ErrInvalidComparison, op)
+	}
+}
+
+// CreateAlertRule creates an alert rule with a unique ID.
+func CreateAlertRule(ctx context.Context, store datastore.Store, r *AlertRule) error {
+	_, err := compare(0, r.Comparison, r.Threshold)
+	if err != nil {
+		return err
+	}
+	r.Updated = time.Now()
+	for {
+		r.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeAlertRule, r.ID), r)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create alert rule: %w", err)
+		}
+	}
+}
+
+// PutAlertRule updates an alert rule.
+func PutAlertRule(ctx context.Context, store datastore.Store, r *AlertRule) error {
+	_, err := compare(0, r.Comparison, r.Threshold)
+	if err != nil {
+		return err
+	}
+	r.Updated = time.Now()
+	_, err = store.Put(ctx, store.IDKey(typeAlertRule, r.ID), r)
+	return err
+}
+
+// GetAlertRule gets an alert rule.
+func GetAlertRule(ctx context.Context, store datastore.Store, id int64) (*AlertRule, error) {
+	r := new(AlertRule)
+	err := store.Get(ctx, store.IDKey(typeAlertRule, id), r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetAlertRulesBySite returns the alert rules for a site, sorted by name.
+func GetAlertRulesBySite(ctx context.Context, store datastore.Store, skey int64) ([]AlertRule, error) {
+	return getAlertRulesBySite(ctx, store, skey, false)
+}
+
+// GetEnabledAlertRulesBySite returns the enabled alert rules for a
+// site, sorted by name.
+func GetEnabledAlertRulesBySite(ctx context.Context, store datastore.Store, skey int64) ([]AlertRule, error) {
+	return getAlertRulesBySite(ctx, store, skey, true)
+}
+
+// getAlertRulesBySite returns the alert rules for a site, optionally
+// only those which are enabled, sorted by name. Equality filters do
+// not require a composite index, but the FileStore does not support
+// them, so FileStore rules are filtered here instead.
+func getAlertRulesBySite(ctx context.Context, store datastore.Store, skey int64, enabled bool) ([]AlertRule, error) {
+	q := store.NewQuery(typeAlertRule, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+		if enabled {
+			q.Filter("Enabled =", true)
+		}
+	}
+	var all []AlertRule
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var rules []AlertRule
+	for _, r := range all {
+		if r.Skey == skey && (r.Enabled || !enabled) {
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules, nil
+}
+
+// EvaluateAlertRule evaluates a sensor value at Unix time ts against
+// the stored state of an enabled alert rule, and returns the updated
+// rule and the resulting transition. Evaluation and update are a
+// single transaction, so that concurrently received values do not
+// overwrite each other's state.
+func EvaluateAlertRule(ctx context.Context, store datastore.Store, id int64, value float64, ts int64) (*AlertRule, int, error) {
+	transition := AlertRuleUnchanged
+	var evalErr error
+	r := new(AlertRule)
+	err := store.Update(ctx, store.IDKey(typeAlertRule, id), func(e datastore.Entity) {
+		r2, ok := e.(*AlertRule)
+		if !ok {
+			evalErr = datastore.ErrWrongType
+			return
+		}
+		if !r2.Enabled {
+			return
+		}
+		transition, evalErr = r2.Evaluate(value, ts)
+		r2.Updated = time.Now()
+	}, r)
+	if err != nil {
+		return nil, AlertRuleUnchanged, err
+	}
+	if evalErr != nil {
+		return nil, AlertRuleUnchanged, evalErr
+	}
+	return r, transition, nil
+}
+
+// DeleteAlertRule deletes an alert rule.
+func DeleteAlertRule(ctx context.Context, store datastore.Store, id int64) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.IDKey(typeAlertRule, id)})
+}
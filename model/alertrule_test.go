@@ -0,0 +1,151 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestAlertRuleEvaluate tests evaluating sensor values against an alert rule.
+func TestAlertRuleEvaluate(t *testing.T) {
+	r := &AlertRule{Pin: "A0", Comparison: "<", Threshold: 11.5, Duration: 60}
+	tests := []struct {
+		value float64
+		ts    int64
+		want  int
+	}{
+		{12, 0, AlertRuleUnchanged},
+		{11, 100, AlertRuleUnchanged},   // Holds from 100.
+		{11, 130, AlertRuleUnchanged},   // Not long enough.
+		{11.2, 160, AlertRuleTripped},   // Held for 60s.
+		{11.2, 220, AlertRuleUnchanged}, // Already tripped.
+		{11.5, 230, AlertRuleCleared},
+		{11.5, 240, AlertRuleUnchanged},
+		{10, 250, AlertRuleUnchanged},
+		{12, 260, AlertRuleUnchanged}, // Cleared before tripping.
+		{10, 270, AlertRuleUnchanged},
+		{10, 330, AlertRuleTripped},
+	}
+	for i, test := range tests {
+		got, err := r.Evaluate(test.value, test.ts)
+		if err != nil {
+			t.Fatalf("test %d: Evaluate returned error: %v", i, err)
+		}
+		if got != test.want {
+			t.Errorf("test %d: Evaluate(%g, %d) returned %d, expected %d", i, test.value, test.ts, got, test.want)
+		}
+	}
+
+	r.Comparison = "~"
+	_, err := r.Evaluate(0, 0)
+	if !errors.Is(err, ErrInvalidComparison) {
+		t.Errorf("Evaluate with invalid comparison returned %v, expected ErrInvalidComparison", err)
+	}
+}
+
+// TestAlertRules tests creating, getting and deleting alert rules.
+func TestAlertRules(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	rules := []AlertRule{
+		{Skey: 1, Mac: 1, Pin: "A0", Name: "Low battery", Comparison: "<", Threshold: 11.5, Enabled: true},
+		{Skey: 1, Mac: 1, Pin: "A0", Name: "High battery", Comparison: ">", Threshold: 15},
+		{Skey: 1, Mac: 1, Pin: "X1", Name: "Hot", Comparison: ">=", Threshold: 30, Enabled: true},
+		{Skey: 2, Mac: 2, Pin: "A0", Name: "Other site", Comparison: "<", Threshold: 1, Enabled: true},
+	}
+	for i := range rules {
+		err = CreateAlertRule(ctx, store, &rules[i])
+		if err != nil {
+			t.Fatalf("CreateAlertRule returned error: %v", err)
+		}
+	}
+	err = CreateAlertRule(ctx, store, &AlertRule{Skey: 1, Comparison: "~"})
+	if !errors.Is(err, ErrInvalidComparison) {
+		t.Errorf("CreateAlertRule with invalid comparison returned %v, expected ErrInvalidComparison", err)
+	}
+
+	got, err := GetAlertRulesBySite(ctx, store, 1)
+	if err != nil {
+		t.Fatalf("GetAlertRulesBySite returned error: %v", err)
+	}
+	if len(got) != 3 || got[0].Name != "High battery" || got[2].Name != "Low battery" {
+		t.Errorf("GetAlertRulesBySite returned %v", got)
+	}
+
+	got, err = GetEnabledAlertRulesBySite(ctx, store, 1)
+	if err != nil {
+		t.Fatalf("GetEnabledAlertRulesBySite returned error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != rules[2].ID || got[1].ID != rules[0].ID {
+		t.Errorf("GetEnabledAlertRulesBySite returned %v, expected only enabled rules %d and %d", got, rules[2].ID, rules[0].ID)
+	}
+
+	_, transition, err := EvaluateAlertRule(ctx, store, rules[0].ID, 11, 100)
+	if err != nil {
+		t.Fatalf("EvaluateAlertRule returned error: %v", err)
+	}
+	if transition != AlertRuleTripped {
+		t.Errorf("EvaluateAlertRule returned transition %d, expected %d", transition, AlertRuleTripped)
+	}
+	_, transition, err = EvaluateAlertRule(ctx, store, rules[1].ID, 16, 100)
+	if err != nil {
+		t.Fatalf("EvaluateAlertRule of disabled rule returned error: %v", err)
+	}
+	if transition != AlertRuleUnchanged {
+		t.Errorf("EvaluateAlertRule of disabled rule returned transition %d", transition)
+	}
+	r, err := GetAlertRule(ctx, store, rules[0].ID)
+	if err != nil {
+		t.Fatalf("GetAlertRule returned error: %v", err)
+	}
+	if !r.Tripped {
+		t.Errorf("GetAlertRule returned untripped rule")
+	}
+
+	r.Tripped = false
+	err = PutAlertRule(ctx, store, r)
+	if err != nil {
+		t.Fatalf("PutAlertRule returned error: %v", err)
+	}
+	r, err = GetAlertRule(ctx, store, rules[0].ID)
+	if err != nil {
+		t.Fatalf("GetAlertRule returned error: %v", err)
+	}
+	if r.Tripped {
+		t.Errorf("GetAlertRule returned tripped rule after put")
+	}
+
+	err = DeleteAlertRule(ctx, store, rules[0].ID)
+	if err != nil {
+		t.Fatalf("DeleteAlertRule returned error: %v", err)
+	}
+	_, err = GetAlertRule(ctx, store, rules[0].ID)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetAlertRule after delete returned %v, expected ErrNoSuchEntity", err)
+	}
+}
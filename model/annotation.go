@@ -0,0 +1,165 @@
+/*
+DESCRIPTION
+  Annotation datastore type and functions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeAnnotation = "Annotation" // Annotation datastore type.
+
+// ErrInvalidAnnotation is returned when an annotation is missing its
+// label or its end precedes its start.
+var ErrInvalidAnnotation = errors.New("invalid annotation")
+
+// Annotation represents a comment on a segment of media, e.g., the
+// moment a whale is heard in an audio recording. The key is the media
+// ID concatenated with the start timestamp and a sequence number, as
+// per datastore.IDKey, so that multiple annotations may start at the
+// same time.
+type Annotation struct {
+	ID         int64     // Key ID.
+	MID        int64     // Media ID.
+	Timestamp  int64     // Start Unix time in seconds.
+	End        int64     // End Unix time in seconds, which equals Timestamp for an instant.
+	Author     string    // Email of the author.
+	Label      string    // Short label, e.g., whale.
+	Note       string    `datastore:",noindex"` // Free-form note.
+	OpenFishID int64     // OpenFish annotation ID, if pushed to OpenFish.
+	Created    time.Time // Date/time created.
+	Updated    time.Time // Date/time last updated.
+}
+
+// Copy copies an annotation to dst, or returns a copy of the annotation when dst is nil.
+func (a *Annotation) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var a2 *Annotation
+	if dst == nil {
+		a2 = new(Annotation)
+	} else {
+		var ok bool
+		a2, ok = dst.(*Annotation)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*a2 = *a
+	return a2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (a *Annotation) GetCache() datastore.Cache {
+	return nil
+}
+
+// validate returns an error wrapping ErrInvalidAnnotation if the annotation is invalid.
+func (a *Annotation) validate() error {
+	switch {
+	case a.Label == "":
+		return fmt.Errorf("%w: missing label", ErrInvalidAnnotation)
+	case a.End < a.Timestamp:
+		return fmt.Errorf("%w: end precedes start", ErrInvalidAnnotation)
+	}
+	return nil
+}
+
+// CreateAnnotation creates an annotation, setting its ID.
+func CreateAnnotation(ctx context.Context, store datastore.Store, a *Annotation) error {
+	err := a.validate()
+	if err != nil {
+		return err
+	}
+	a.Created = time.Now()
+	a.Updated = a.Created
+	// Create gets any existing entity into a, so restore it before each attempt.
+	orig := *a
+	for st := int64(0); st < 1<<datastore.SubTimeBits; st++ {
+		*a = orig
+		a.ID = datastore.IDKey(a.MID, a.Timestamp, st)
+		err = store.Create(ctx, store.IDKey(typeAnnotation, a.ID), a)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create annotation: %w", err)
+		}
+	}
+	return fmt.Errorf("could not create annotation: too many annotations at %d", a.Timestamp)
+}
+
+// PutAnnotation updates an annotation. The media ID and start time
+// cannot be changed, since they form the key.
+func PutAnnotation(ctx context.Context, store datastore.Store, a *Annotation) error {
+	err := a.validate()
+	if err != nil {
+		return err
+	}
+	a.Updated = time.Now()
+	_, err = store.Put(ctx, store.IDKey(typeAnnotation, a.ID), a)
+	return err
+}
+
+// GetAnnotation gets an annotation.
+func GetAnnotation(ctx context.Context, store datastore.Store, id int64) (*Annotation, error) {
+	a := new(Annotation)
+	err := store.Get(ctx, store.IDKey(typeAnnotation, id), a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetAnnotations returns the annotations for a given media ID, ordered
+// by start time. If ts is a pair of timestamps, only annotations that
+// start before ts[1] and end at or after ts[0] are returned, i.e.,
+// those that overlap the period.
+func GetAnnotations(ctx context.Context, store datastore.Store, mid int64, ts []int64) ([]Annotation, error) {
+	q := store.NewQuery(typeAnnotation, false, "MID", "Timestamp")
+	q.Filter("MID =", mid)
+	if len(ts) > 1 {
+		q.Filter("Timestamp <", ts[1])
+	}
+	q.Order("Timestamp")
+	var all []Annotation
+	_, err := getAll(ctx, store, q, &all, idxAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	var as []Annotation
+	for _, a := range all {
+		if len(ts) > 1 && (a.Timestamp >= ts[1] || a.End < ts[0]) {
+			continue
+		}
+		as = append(as, a)
+	}
+	sort.SliceStable(as, func(i, j int) bool { return as[i].Timestamp < as[j].Timestamp })
+	return as, nil
+}
+
+// DeleteAnnotation deletes an annotation.
+func DeleteAnnotation(ctx context.Context, store datastore.Store, id int64) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.IDKey(typeAnnotation, id)})
+}
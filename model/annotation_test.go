@@ -0,0 +1,104 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestAnnotations tests creating, getting, updating and deleting annotations.
+func TestAnnotations(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mid = 123
+	const ts = 1700000000
+	as := []Annotation{
+		{MID: mid, Timestamp: ts, End: ts + 10, Label: "whale"},
+		{MID: mid, Timestamp: ts, End: ts, Label: "dolphin"}, // Same start.
+		{MID: mid, Timestamp: ts + 100, End: ts + 120, Label: "boat"},
+		{MID: mid + 1, Timestamp: ts, End: ts + 10, Label: "other"},
+	}
+	for i := range as {
+		err = CreateAnnotation(ctx, store, &as[i])
+		if err != nil {
+			t.Fatalf("CreateAnnotation returned error: %v", err)
+		}
+	}
+	if as[0].ID == as[1].ID {
+		t.Errorf("annotations with the same start have the same ID %d", as[0].ID)
+	}
+	for _, a := range []Annotation{{MID: mid, Timestamp: ts}, {MID: mid, Timestamp: ts, End: ts - 1, Label: "x"}} {
+		err = CreateAnnotation(ctx, store, &a)
+		if !errors.Is(err, ErrInvalidAnnotation) {
+			t.Errorf("CreateAnnotation(%+v) returned %v, expected ErrInvalidAnnotation", a, err)
+		}
+	}
+
+	tests := []struct {
+		ts   []int64
+		want int
+	}{
+		{nil, 3},
+		{[]int64{ts, ts + 200}, 3},
+		{[]int64{ts + 5, ts + 100}, 1},  // Overlaps whale only.
+		{[]int64{ts + 50, ts + 101}, 1}, // Overlaps boat only.
+		{[]int64{ts + 200, ts + 300}, 0},
+	}
+	for i, test := range tests {
+		got, err := GetAnnotations(ctx, store, mid, test.ts)
+		if err != nil {
+			t.Fatalf("test %d: GetAnnotations returned error: %v", i, err)
+		}
+		if len(got) != test.want {
+			t.Errorf("test %d: GetAnnotations returned %d annotations, expected %d", i, len(got), test.want)
+		}
+	}
+
+	a, err := GetAnnotation(ctx, store, as[2].ID)
+	if err != nil {
+		t.Fatalf("GetAnnotation returned error: %v", err)
+	}
+	a.Note = "outboard motor"
+	err = PutAnnotation(ctx, store, a)
+	if err != nil {
+		t.Fatalf("PutAnnotation returned error: %v", err)
+	}
+	a, err = GetAnnotation(ctx, store, as[2].ID)
+	if err != nil || a.Note != "outboard motor" {
+		t.Errorf("GetAnnotation after update returned %+v, %v", a, err)
+	}
+
+	err = DeleteAnnotation(ctx, store, as[2].ID)
+	if err != nil {
+		t.Fatalf("DeleteAnnotation returned error: %v", err)
+	}
+	_, err = GetAnnotation(ctx, store, as[2].ID)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetAnnotation after delete returned %v, expected ErrNoSuchEntity", err)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+DESCRIPTION
+  Announcement datastore type and functions, which implement
+  AusOcean TV announcement emails to subscribers.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeAnnouncement = "Announcement" // Announcement datastore type.
+
+// Announcement statuses.
+const (
+	AnnouncementPending = "pending" // Created but not yet sent.
+	AnnouncementSending = "sending" // Being sent.
+	AnnouncementSent    = "sent"    // Sent to all of the audience.
+)
+
+// Announcement represents an email announcement to subscribers, e.g.,
+// of a new stream or maintenance. The audience is active subscribers,
+// optionally limited to those with given areas of interest or
+// subscription classes. Sending progresses through subscribers in ID
+// order, so that an interrupted send can resume after the last
+// subscriber processed. The key is the ID.
+type Announcement struct {
+	ID      int64     // Announcement ID.
+	Subject string    // Email subject.
+	Body    string    // Email body, in plain text.
+	Areas   []string  // Areas of interest of the audience, or empty for all.
+	Classes []string  // Subscription classes of the audience, or empty for all.
+	Creator string    // Email address of the creator.
+	Created time.Time // Date/time created.
+	Status  string    // Status, e.g., AnnouncementSending.
+	Cursor  int64     // ID of the last subscriber processed.
+	Sent    int64     // Number of emails sent.
+	Failed  int64     // Number of emails that could not be sent.
+	Skipped int64     // Number of subscribers outside the audience or unsubscribed.
+	Updated time.Time // Date/time progress last updated.
+}
+
+// Copy copies an announcement to dst, or returns a copy of the announcement when dst is nil.
+func (a *Announcement) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var a2 *Announcement
+	if dst == nil {
+		a2 = new(Announcement)
+	} else {
+		var ok bool
+		a2, ok = dst.(*Announcement)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*a2 = *a
+	a2.Areas = slices.Clone(a.Areas)
+	a2.Classes = slices.Clone(a.Classes)
+	return a2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (a *Announcement) GetCache() datastore.Cache {
+	return nil
+}
+
+// Audience returns true if a subscriber with the given subscriptions
+// is in the audience of the announcement at time t, i.e., has a
+// subscription of a matching class that has not finished, and has a
+// matching area of interest. Unsubscribed subscribers are never in
+// the audience.
+func (a *Announcement) Audience(s *Subscriber, subs []Subscription, t time.Time) bool {
+	if !s.Unsubscribed.IsZero() {
+		return false
+	}
+	if len(a.Areas) > 0 && !slices.ContainsFunc(s.Area, a.hasArea) {
+		return false
+	}
+	for _, sub := range subs {
+		if sub.Finish.After(t) && (len(a.Classes) == 0 || slices.Contains(a.Classes, sub.Class)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasArea returns true if the announcement is for the given area of
+// interest, ignoring case.
+func (a *Announcement) hasArea(area string) bool {
+	return slices.ContainsFunc(a.Areas, func(s string) bool { return strings.EqualFold(s, area) })
+}
+
+// CreateAnnouncement creates a pending announcement with a unique ID.
+func CreateAnnouncement(ctx context.Context, store datastore.Store, a *Announcement) error {
+	a.Created = time.Now()
+	a.Updated = a.Created
+	a.Status = AnnouncementPending
+	for {
+		a.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeAnnouncement, a.ID), a)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create announcement: %w", err)
+		}
+	}
+}
+
+// GetAnnouncement returns the announcement with the given ID.
+func GetAnnouncement(ctx context.Context, store datastore.Store, id int64) (*Announcement, error) {
+	a := new(Announcement)
+	err := store.Get(ctx, store.IDKey(typeAnnouncement, id), a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetAnnouncements returns all announcements, most recent first.
+func GetAnnouncements(ctx context.Context, store datastore.Store) ([]Announcement, error) {
+	q := store.NewQuery(typeAnnouncement, false)
+	var as []Announcement
+	_, err := store.GetAll(ctx, q, &as)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Created.After(as[j].Created) })
+	return as, nil
+}
+
+// UpdateAnnouncement atomically updates the announcement with the
+// given ID by calling fn, returning the updated announcement.
+func UpdateAnnouncement(ctx context.Context, store datastore.Store, id int64, fn func(*Announcement)) (*Announcement, error) {
+	a := new(Announcement)
+	err := store.Update(ctx, store.IDKey(typeAnnouncement, id), func(e datastore.Entity) {
+		a2, ok := e.(*Announcement)
+		if ok {
+			fn(a2)
+			a2.Updated = time.Now()
+		}
+	}, a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,106 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestAnnouncements tests creating, updating and getting announcements.
+func TestAnnouncements(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	a := &Announcement{Subject: "New stream", Body: "Watch now.", Areas: []string{"Rapid Bay"}}
+	err = CreateAnnouncement(ctx, store, a)
+	if err != nil {
+		t.Fatalf("CreateAnnouncement returned error: %v", err)
+	}
+	if a.ID == 0 || a.Status != AnnouncementPending {
+		t.Errorf("CreateAnnouncement created %+v", a)
+	}
+
+	got, err := UpdateAnnouncement(ctx, store, a.ID, func(a *Announcement) {
+		a.Status = AnnouncementSending
+		a.Sent++
+		a.Cursor = 42
+	})
+	if err != nil {
+		t.Fatalf("UpdateAnnouncement returned error: %v", err)
+	}
+	if got.Status != AnnouncementSending || got.Sent != 1 || got.Cursor != 42 {
+		t.Errorf("UpdateAnnouncement returned %+v", got)
+	}
+
+	got, err = GetAnnouncement(ctx, store, a.ID)
+	if err != nil {
+		t.Fatalf("GetAnnouncement returned error: %v", err)
+	}
+	if got.Subject != a.Subject || got.Cursor != 42 || len(got.Areas) != 1 {
+		t.Errorf("GetAnnouncement returned %+v", got)
+	}
+
+	as, err := GetAnnouncements(ctx, store)
+	if err != nil {
+		t.Fatalf("GetAnnouncements returned error: %v", err)
+	}
+	if len(as) != 1 || as[0].ID != a.ID {
+		t.Errorf("GetAnnouncements returned %+v", as)
+	}
+}
+
+// TestAnnouncementAudience tests announcement audience filtering.
+func TestAnnouncementAudience(t *testing.T) {
+	now := time.Now()
+	active := []Subscription{{Class: SubscriptionMonth, Finish: now.Add(time.Hour)}}
+	expired := []Subscription{{Class: SubscriptionMonth, Finish: now.Add(-time.Hour)}}
+	s := &Subscriber{Area: []string{"rapid bay"}}
+	unsubscribed := &Subscriber{Area: []string{"rapid bay"}, Unsubscribed: now}
+
+	tests := []struct {
+		name string
+		a    Announcement
+		s    *Subscriber
+		subs []Subscription
+		want bool
+	}{
+		{"all", Announcement{}, s, active, true},
+		{"expired", Announcement{}, s, expired, false},
+		{"none", Announcement{}, s, nil, false},
+		{"unsubscribed", Announcement{}, unsubscribed, active, false},
+		{"area", Announcement{Areas: []string{"Rapid Bay"}}, s, active, true},
+		{"other area", Announcement{Areas: []string{"Port Phillip"}}, s, active, false},
+		{"class", Announcement{Classes: []string{SubscriptionMonth}}, s, active, true},
+		{"other class", Announcement{Classes: []string{SubscriptionYear}}, s, active, false},
+	}
+	for _, test := range tests {
+		got := test.a.Audience(test.s, test.subs, now)
+		if got != test.want {
+			t.Errorf("%s: Audience returned %t, expected %t", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+/*
+DESCRIPTION
+  Blackout windows, during which crons do not run.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// BlackoutVar is the name of the site variable that holds the site's
+// blackout windows, as a comma-separated list. Each window is either
+// daily, in site local time, e.g., "18:00-20:30", or a one-off period
+// in RFC 3339 format, e.g., "2026-05-01T18:00:00+09:30/2026-05-01T20:30:00+09:30".
+// Daily windows may span midnight, e.g., "22:00-02:00".
+const BlackoutVar = "Blackout"
+
+var ErrInvalidBlackout = errors.New("invalid blackout window")
+
+// Blackout represents a window during which crons do not run.
+type Blackout struct {
+	Daily      bool          // True for daily windows.
+	From, To   time.Duration // Daily window, as durations since local midnight.
+	Start, End time.Time     // One-off window.
+}
+
+// ParseBlackouts parses a comma-separated list of blackout windows.
+func ParseBlackouts(s string) ([]Blackout, error) {
+	var bs []Blackout
+	for _, w := range strings.Split(s, ",") {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		var b Blackout
+		var err error
+		if start, end, ok := strings.Cut(w, "/"); ok {
+			b.Start, err = time.Parse(time.RFC3339, start)
+			if err == nil {
+				b.End, err = time.Parse(time.RFC3339, end)
+			}
+			if err != nil || !b.End.After(b.Start) {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidBlackout, w)
+			}
+			bs = append(bs, b)
+			continue
+		}
+		from, to, ok := strings.Cut(w, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBlackout, w)
+		}
+		b.Daily = true
+		b.From, err = parseClock(from)
+		if err == nil {
+			b.To, err = parseClock(to)
+		}
+		if err != nil || b.From == b.To {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBlackout, w)
+		}
+		bs = append(bs, b)
+	}
+	return bs, nil
+}
+
+// parseClock parses a 24-hour time of the form hh:mm, returning the
+// duration since midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true if t falls within the blackout window, where
+// loc is the site's timezone, which is used for daily windows.
+func (b Blackout) Contains(t time.Time, loc *time.Location) bool {
+	if !b.Daily {
+		return !t.Before(b.Start) && t.Before(b.End)
+	}
+	h, m, s := t.In(loc).Clock()
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if b.From < b.To {
+		return d >= b.From && d < b.To
+	}
+	return d >= b.From || d < b.To
+}
+
+// InBlackout returns true if t falls within any of the site's blackout windows.
+func InBlackout(ctx context.Context, store datastore.Store, skey int64, t time.Time) (bool, error) {
+	v, err := GetVariable(ctx, store, skey, BlackoutVar)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	bs, err := ParseBlackouts(v.Value)
+	if err != nil {
+		return false, err
+	}
+	if len(bs) == 0 {
+		return false, nil
+	}
+	site, err := GetSite(ctx, store, skey)
+	if err != nil {
+		return false, err
+	}
+	loc := LocalTime(site, t).Location() // Observes daylight saving time, if any.
+	for _, b := range bs {
+		if b.Contains(t, loc) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBlackout tests parsing and checking blackout windows.
+func TestBlackout(t *testing.T) {
+	bs, err := ParseBlackouts("18:00-20:30, 22:00-02:00,2026-05-01T00:00:00Z/2026-05-02T00:00:00Z")
+	if err != nil {
+		t.Fatalf("ParseBlackouts failed with error: %v", err)
+	}
+	if len(bs) != 3 {
+		t.Fatalf("ParseBlackouts returned %d windows, expected 3", len(bs))
+	}
+
+	// Times are UTC, in Adelaide, which is 9.5 hours ahead in winter and
+	// 10.5 hours ahead in summer.
+	loc, err := time.LoadLocation("Australia/Adelaide")
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	tests := []struct {
+		t    time.Time
+		want []bool
+	}{
+		{t: time.Date(2026, 4, 30, 8, 30, 0, 0, time.UTC), want: []bool{true, false, false}},  // 18:00 local.
+		{t: time.Date(2026, 4, 30, 11, 0, 0, 0, time.UTC), want: []bool{false, false, false}}, // 20:30 local.
+		{t: time.Date(2026, 4, 30, 15, 0, 0, 0, time.UTC), want: []bool{false, true, false}},  // 00:30 local.
+		{t: time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC), want: []bool{false, false, true}},    // 12:30 local.
+		{t: time.Date(2026, 1, 15, 7, 30, 0, 0, time.UTC), want: []bool{true, false, false}},  // 18:00 local daylight time.
+	}
+	for i, test := range tests {
+		for j, b := range bs {
+			got := b.Contains(test.t, loc)
+			if got != test.want[j] {
+				t.Errorf("Contains %d for window %d returned %t, expected %t", i, j, got, test.want[j])
+			}
+		}
+	}
+
+	for _, s := range []string{"18:00", "18:00-18:00", "25:00-02:00", "2026-05-02T00:00:00Z/2026-05-01T00:00:00Z"} {
+		_, err := ParseBlackouts(s)
+		if !errors.Is(err, ErrInvalidBlackout) {
+			t.Errorf("ParseBlackouts(%q) returned %v, expected %v", s, err, ErrInvalidBlackout)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+/*
+DESCRIPTION
+  BroadcastConfig datastore type and functions. Broadcast
+  configurations were historically stored as JSON encoded variables
+  in the Broadcast scope, which remain supported for migration.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	typeBroadcastConfig  = "BroadcastConfig"  // BroadcastConfig datastore type.
+	typeBroadcastArchive = "BroadcastArchive" // BroadcastArchive datastore type.
+	typeViewerSample     = "ViewerSample"     // ViewerSample datastore type.
+)
+
+// BroadcastScope is the scope of variables holding JSON encoded
+// broadcast configurations, prior to migration.
+const BroadcastScope = "Broadcast"
+
+// BroadcastConfig is the stored configuration of a YouTube broadcast,
+// which mirrors the configuration used by Ocean TV and Ocean Bench.
+// Fields are JSON compatible with the variable-encoded form. The key
+// is the site key concatenated with the broadcast name.
+type BroadcastConfig struct {
+	SKey                     int64                 // The key of the site this broadcast belongs to.
+	Name                     string                // The name of the broadcast.
+	ID                       string                // Broadcast identification.
+	SID                      string                // Stream ID for any currently associated stream.
+	CID                      string                // ID of associated chat.
+	StreamName               string                // The name of the stream we'll bind to the broadcast.
+	Description              string                `datastore:",noindex"` // The broadcast description shown below viewing window.
+	Privacy                  string                // Privacy of the broadcast i.e. public, private or unlisted.
+	Resolution               string                // Resolution of the stream e.g. 1080p.
+	StartTimestamp           string                // Start time of the broadcast in unix format.
+	Start                    time.Time             // Start time in native go format for easy operations.
+	EndTimestamp             string                // End time of the broadcast in unix format.
+	End                      time.Time             // End time in native go format for easy operations.
+	VidforwardHost           string                // Host address of vidforward service.
+	HLSOutput                string                // Playlist vidforward also writes HLS to, if any.
+	HLSFeed                  int64                 // ID of the AusOcean TV feed played from the HLS output, if any.
+	CameraMac                int64                 // Camera hardware's MAC address.
+	ControllerMAC            int64                 // Controller hardware's MAC address (controller used to power camera).
+	OnActions                string                // A series of actions to be used for power up of camera hardware.
+	OffActions               string                // A series of actions to be used for power down of camera hardware.
+	Controllers              []BroadcastController `datastore:",noindex"` // Additional controllers, e.g., powering lights separately to the camera.
+	RTMPVar                  string                // The variable name that holds the RTMP URL and key.
+	Active                   bool                  // This is true if the broadcast is currently active i.e. waiting for data or currently streaming.
+	Slate                    bool                  // This is true if the broadcast is currently in slate mode i.e. no camera.
+	Issues                   int                   // The number of successive stream issues currently experienced.
+	SendMsg                  bool                  // True if sensor data will be sent to the YouTube live chat.
+	SensorList               []BroadcastSensor     `datastore:",noindex"` // List of sensors which can be reported to the YouTube live chat.
+	RTMPKey                  string                // The RTMP key corresponding to the newly created broadcast.
+	UsingVidforward          bool                  // Indicates if we're using vidforward i.e. doing long term broadcast.
+	CheckingHealth           bool                  // Are we performing health checks for the broadcast?
+	AttemptingToStart        bool                  // Indicates if we're currently attempting to start the broadcast.
+	Enabled                  bool                  // Is the broadcast enabled? If not, it will not be started.
+	Events                   []string              `datastore:",noindex"` // Holds names of events that are yet to be handled.
+	Unhealthy                bool                  // True if the broadcast is unhealthy.
+	HardwareState            string                // Holds the current state of the hardware.
+	StartFailures            int                   // The number of times the broadcast has failed to start.
+	Transitioning            bool                  // If the broadcast is transition from live to slate, or vice versa.
+	StateData                []byte                `datastore:",noindex"` // Marshalled broadcast state data.
+	HardwareStateData        []byte                `datastore:",noindex"` // Marshalled hardware state data.
+	Account                  string                // The YouTube account email that this broadcast is associated with.
+	InFailure                bool                  // True if the broadcast is in a failure state.
+	RecoveringVoltage        bool                  // True if the broadcast is currently recovering voltage.
+	RequiredStreamingVoltage float64               // The required battery voltage for the camera to stream.
+	VoltageRecoveryTimeout   int                   // Max allowable hours for voltage recovery before failure.
+	VoltageRecoveryStrategy  string                // The voltage recovery strategy, i.e. linear (default), solar or extrapolate.
+	RegisterOpenFish         bool                  // True if the video should be registered with openfish for annotation.
+	OpenFishCaptureSource    string                // The capture source to register the stream to, created if empty.
+	OpenFishAnnotators       string                // Comma-separated emails of users to annotate the stream in openfish.
+	NotifyRecipients         string                // Comma-separated emails notified in addition to the site's recipients.
+	NotifyRoutes             string                // Channel overrides, e.g., "sms:+61400000000,+61411111111;slack".
+	MinStatusInterval        int                   // Min minutes between status checks.
+	MaxStatusInterval        int                   // Max minutes between status checks.
+	SlateSchedule            string                // Daily slate windows for permanent broadcasts in local time.
+	GenerateSlate            bool                  // True if slates with dynamic text are generated for permanent broadcasts.
+	SlateImageURL            string                // URL of the base image of generated slates.
+	ReadinessLeadTime        int                   // Minutes before the start at which readiness is checked.
+	ReadinessChecked         time.Time             // The start time for which readiness was last checked.
+	TitleTemplate            string                // Template for refreshed titles.
+	ThumbnailURL             string                // URL of an image set as the thumbnail when metadata is refreshed.
+	MetadataRefreshTime      string                // Daily local time at which metadata is refreshed.
+	MetadataRefreshed        time.Time             // The time metadata was last refreshed.
+	MetadataErr              string                // The metadata refresh error last notified, if any.
+	SendConditions           bool                  // True if tide and weather conditions are added to the description and chat messages.
+	HealthIncidents          int                   // The number of health incidents during the current broadcast.
+	PowerOffDelay            int                   // Minutes after the end for which hardware stays on.
+	Draining                 bool                  // True if the broadcast has finished and is waiting to power off.
+	OverrunTolerance         int                   // Minutes past the end before an active broadcast is forcibly finished.
+	ViewersSampled           time.Time             // The time concurrent viewers were last sampled.
+	PendingChanges           []byte                `datastore:",noindex"` // Restart-required changes saved while active, JSON encoded.
+}
+
+// BroadcastSensor is a sensor that may be reported to a broadcast's
+// YouTube live chat.
+type BroadcastSensor struct {
+	SendMsg   bool
+	Sensor    SensorV2
+	Name      string
+	DeviceMac int64
+}
+
+// BroadcastController is an additional controller powering broadcast
+// hardware, with its own actions and battery voltage pin.
+type BroadcastController struct {
+	MAC        int64  // Controller MAC address.
+	OnActions  string // Actions to power up the controller's hardware.
+	OffActions string // Actions to power down the controller's hardware.
+	VoltagePin string // Battery voltage pin, A0 if empty.
+}
+
+// Copy copies a broadcast config to dst, or returns a copy of the config when dst is nil.
+func (c *BroadcastConfig) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var c2 *BroadcastConfig
+	if dst == nil {
+		c2 = new(BroadcastConfig)
+	} else {
+		var ok bool
+		c2, ok = dst.(*BroadcastConfig)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*c2 = *c
+	c2.SensorList = append([]BroadcastSensor(nil), c.SensorList...)
+	c2.Controllers = append([]BroadcastController(nil), c.Controllers...)
+	c2.Events = append([]string(nil), c.Events...)
+	c2.StateData = append([]byte(nil), c.StateData...)
+	c2.HardwareStateData = append([]byte(nil), c.HardwareStateData...)
+	c2.PendingChanges = append([]byte(nil), c.PendingChanges...)
+	return c2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (c *BroadcastConfig) GetCache() datastore.Cache {
+	return nil
+}
+
+// Secondary returns true if this is the secondary broadcast of a
+// permanent (vidforward) broadcast.
+func (c *BroadcastConfig) Secondary() bool {
+	return strings.HasSuffix(c.Name, "(Secondary)")
+}
+
+// MatchBroadcastConfig returns an error if the fields of t, a mirror
+// of BroadcastConfig such as the configs of Ocean TV and Ocean Bench,
+// differ from those of BroadcastConfig, in which case fields would be
+// silently dropped when converting between them. Fields must have the
+// same names, in the same order, and the same types, except that
+// struct types need only have matching fields.
+func MatchBroadcastConfig(t reflect.Type) error {
+	return matchFields(reflect.TypeOf(BroadcastConfig{}), t, "BroadcastConfig")
+}
+
+// matchFields returns an error if types a and b differ, as described
+// by MatchBroadcastConfig, where path names the type for errors.
+func matchFields(a, b reflect.Type, path string) error {
+	if a.Kind() != b.Kind() {
+		return fmt.Errorf("%s: kind %v does not match %v", path, b.Kind(), a.Kind())
+	}
+	switch a.Kind() {
+	case reflect.Slice, reflect.Pointer:
+		return matchFields(a.Elem(), b.Elem(), path+"[]")
+	case reflect.Struct:
+		if a.PkgPath() == b.PkgPath() && a.Name() == b.Name() {
+			return nil
+		}
+		if a.NumField() != b.NumField() {
+			return fmt.Errorf("%s: %d fields do not match %d", path, b.NumField(), a.NumField())
+		}
+		for i := 0; i < a.NumField(); i++ {
+			fa, fb := a.Field(i), b.Field(i)
+			if fa.Name != fb.Name {
+				return fmt.Errorf("%s: field %d is %s, not %s", path, i, fb.Name, fa.Name)
+			}
+			err := matchFields(fa.Type, fb.Type, path+"."+fa.Name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if a != b {
+			return fmt.Errorf("%s: type %v does not match %v", path, b, a)
+		}
+		return nil
+	}
+}
+
+// broadcastConfigKey returns the key for a broadcast config.
+func broadcastConfigKey(store datastore.Store, skey int64, name string) *datastore.Key {
+	return store.NameKey(typeBroadcastConfig, strconv.FormatInt(skey, 10)+"."+name)
+}
+
+// PutBroadcastConfig creates or updates a broadcast config.
+func PutBroadcastConfig(ctx context.Context, store datastore.Store, c *BroadcastConfig) error {
+	_, err := store.Put(ctx, broadcastConfigKey(store, c.SKey, c.Name), c)
+	return err
+}
+
+// GetBroadcastConfig gets a broadcast config. If there is no such
+// config, but there is a variable-encoded config, it is migrated.
+func GetBroadcastConfig(ctx context.Context, store datastore.Store, skey int64, name string) (*BroadcastConfig, error) {
+	c := new(BroadcastConfig)
+	err := store.Get(ctx, broadcastConfigKey(store, skey, name), c)
+	if err == nil {
+		return c, nil
+	}
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, err
+	}
+	v, err := GetVariable(ctx, store, skey, BroadcastScope+"."+name)
+	if err != nil {
+		return nil, err
+	}
+	return migrateBroadcastConfig(ctx, store, v)
+}
+
+// GetBroadcastConfigs returns the broadcast configs for a site, sorted by name.
+func GetBroadcastConfigs(ctx context.Context, store datastore.Store, skey int64) ([]BroadcastConfig, error) {
+	q := store.NewQuery(typeBroadcastConfig, false, "SKey", "Name")
+	q.Filter("SKey =", skey)
+	var cs []BroadcastConfig
+	_, err := store.GetAll(ctx, q, &cs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	return cs, nil
+}
+
+// DeleteBroadcastConfig deletes a broadcast config.
+func DeleteBroadcastConfig(ctx context.Context, store datastore.Store, skey int64, name string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{broadcastConfigKey(store, skey, name)})
+}
+
+// MigrateBroadcastConfigs migrates a site's variable-encoded broadcast
+// configs to BroadcastConfig entities, returning the number migrated.
+// Variables are left in place, since they remain in use until all
+// services use BroadcastConfig entities.
+func MigrateBroadcastConfigs(ctx context.Context, store datastore.Store, skey int64) (int, error) {
+	vars, err := GetVariablesBySite(ctx, store, skey, BroadcastScope)
+	if err != nil {
+		return 0, fmt.Errorf("could not get broadcast variables: %w", err)
+	}
+	for i := range vars {
+		_, err := migrateBroadcastConfig(ctx, store, &vars[i])
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(vars), nil
+}
+
+// migrateBroadcastConfig decodes a variable-encoded broadcast config
+// and puts it as a BroadcastConfig entity.
+func migrateBroadcastConfig(ctx context.Context, store datastore.Store, v *Variable) (*BroadcastConfig, error) {
+	c := new(BroadcastConfig)
+	err := json.Unmarshal([]byte(v.Value), c)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode broadcast variable %s: %w", v.Name, err)
+	}
+	c.SKey = v.Skey
+	c.Name = strings.TrimPrefix(v.Name, BroadcastScope+".")
+	err = PutBroadcastConfig(ctx, store, c)
+	if err != nil {
+		return nil, fmt.Errorf("could not put broadcast config %s: %w", c.Name, err)
+	}
+	return c, nil
+}
+
+// BroadcastArchive is a record of a finished broadcast, written by
+// Ocean TV when the broadcast is completed, for use in reports. The
+// key is the video ID.
+type BroadcastArchive struct {
+	VideoID               string    // YouTube video ID, which is the broadcast ID.
+	Skey                  int64     // Site key.
+	Name                  string    // Broadcast name.
+	ActualStart           time.Time // Date/time the broadcast went live.
+	ActualEnd             time.Time // Date/time the broadcast ended.
+	LiveMinutes           int64     // Total minutes live.
+	HealthIncidents       int64     // Number of times the stream became unhealthy.
+	Views                 int64     // View count.
+	Likes                 int64     // Like count.
+	Comments              int64     // Comment count.
+	OpenFishRegistered    bool      // True if the stream was registered with OpenFish.
+	OpenFishCaptureSource string    // OpenFish capture source, if registered.
+	Created               time.Time // Date/time created.
+}
+
+// Copy copies a broadcast archive to dst, or returns a copy of the archive when dst is nil.
+func (a *BroadcastArchive) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var a2 *BroadcastArchive
+	if dst == nil {
+		a2 = new(BroadcastArchive)
+	} else {
+		var ok bool
+		a2, ok = dst.(*BroadcastArchive)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*a2 = *a
+	return a2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (a *BroadcastArchive) GetCache() datastore.Cache {
+	return nil
+}
+
+// PutBroadcastArchive creates or updates a broadcast archive.
+func PutBroadcastArchive(ctx context.Context, store datastore.Store, a *BroadcastArchive) error {
+	a.Created = time.Now()
+	_, err := store.Put(ctx, store.NameKey(typeBroadcastArchive, a.VideoID), a)
+	return err
+}
+
+// GetBroadcastArchive gets the broadcast archive for a video.
+func GetBroadcastArchive(ctx context.Context, store datastore.Store, id string) (*BroadcastArchive, error) {
+	a := new(BroadcastArchive)
+	err := store.Get(ctx, store.NameKey(typeBroadcastArchive, id), a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetBroadcastArchives returns the archives of a site's broadcasts
+// that went live at or after since and before until, newest first.
+func GetBroadcastArchives(ctx context.Context, store datastore.Store, skey int64, since, until time.Time) ([]BroadcastArchive, error) {
+	q := store.NewQuery(typeBroadcastArchive, false, "Skey", "ActualStart")
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+		q.Filter("ActualStart >=", since)
+		q.Filter("ActualStart <", until)
+	}
+	var all []BroadcastArchive
+	_, err := getAll(ctx, store, q, &all, idxBroadcastArchive)
+	if err != nil {
+		return nil, err
+	}
+	var as []BroadcastArchive
+	for _, a := range all {
+		if a.Skey == skey && !a.ActualStart.Before(since) && a.ActualStart.Before(until) {
+			as = append(as, a)
+		}
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].ActualStart.After(as[j].ActualStart) })
+	return as, nil
+}
+
+// ViewerSample is a sample of a live broadcast's concurrent viewers,
+// written periodically by Ocean TV, along with the stream's health at
+// the time, so that viewer engagement can be correlated with stream
+// health and time of day. The key is the video ID concatenated with
+// the Unix time of the sample.
+type ViewerSample struct {
+	VideoID   string    // YouTube video ID, which is the broadcast ID.
+	Skey      int64     // Site key.
+	Timestamp time.Time // Date/time sampled.
+	Viewers   int64     // Concurrent viewers.
+	Healthy   bool      // True if the stream was healthy.
+	Issues    int64     // Number of successive stream issues.
+}
+
+// Copy copies a viewer sample to dst, or returns a copy of the sample when dst is nil.
+func (v *ViewerSample) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var v2 *ViewerSample
+	if dst == nil {
+		v2 = new(ViewerSample)
+	} else {
+		var ok bool
+		v2, ok = dst.(*ViewerSample)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*v2 = *v
+	return v2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (v *ViewerSample) GetCache() datastore.Cache {
+	return nil
+}
+
+// PutViewerSample creates or updates a viewer sample.
+func PutViewerSample(ctx context.Context, store datastore.Store, v *ViewerSample) error {
+	key := store.NameKey(typeViewerSample, v.VideoID+"."+strconv.FormatInt(v.Timestamp.Unix(), 10))
+	_, err := store.Put(ctx, key, v)
+	return err
+}
+
+// GetViewerSamples returns the viewer samples of a broadcast's video,
+// oldest first.
+func GetViewerSamples(ctx context.Context, store datastore.Store, id string) ([]ViewerSample, error) {
+	q := store.NewQuery(typeViewerSample, false, "VideoID", "Timestamp")
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("VideoID =", id)
+		q.Order("Timestamp")
+	}
+	var all []ViewerSample
+	_, err := getAll(ctx, store, q, &all, idxViewerSample)
+	if err != nil {
+		return nil, err
+	}
+	var vs []ViewerSample
+	for _, v := range all {
+		if v.VideoID == id {
+			vs = append(vs, v)
+		}
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Timestamp.Before(vs[j].Timestamp) })
+	return vs, nil
+}
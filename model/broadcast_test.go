@@ -0,0 +1,199 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestBroadcastConfig tests broadcast configs, including migration
+// from variable-encoded configs.
+func TestBroadcastConfig(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const skey = 1
+	for name, value := range map[string]string{
+		"Rapid Bay":            `{"ID":"abc","CameraMac":42,"Enabled":true,"SensorList":[{"Name":"Temp","SendMsg":true}],"Controllers":[{"MAC":43,"VoltagePin":"A4"}]}`,
+		"Rapid Bay(Secondary)": `{"ID":"def","UsingVidforward":true}`,
+		"Stony Point":          `{"ID":"ghi"}`,
+	} {
+		err = PutVariable(ctx, store, skey, BroadcastScope+"."+name, value)
+		if err != nil {
+			t.Fatalf("PutVariable returned error: %v", err)
+		}
+	}
+
+	// Getting an unmigrated config migrates it.
+	c, err := GetBroadcastConfig(ctx, store, skey, "Stony Point")
+	if err != nil {
+		t.Fatalf("GetBroadcastConfig returned error: %v", err)
+	}
+	if c.SKey != skey || c.Name != "Stony Point" || c.ID != "ghi" {
+		t.Errorf("GetBroadcastConfig returned %+v", c)
+	}
+	cs, err := GetBroadcastConfigs(ctx, store, skey)
+	if err != nil || len(cs) != 1 {
+		t.Fatalf("GetBroadcastConfigs returned %d configs, error %v, want 1", len(cs), err)
+	}
+
+	n, err := MigrateBroadcastConfigs(ctx, store, skey)
+	if err != nil || n != 3 {
+		t.Fatalf("MigrateBroadcastConfigs returned %d, %v, want 3", n, err)
+	}
+	cs, err = GetBroadcastConfigs(ctx, store, skey)
+	if err != nil || len(cs) != 3 {
+		t.Fatalf("GetBroadcastConfigs returned %d configs, error %v, want 3", len(cs), err)
+	}
+	if c := cs[0]; c.Name != "Rapid Bay" || c.CameraMac != 42 || !c.Enabled || len(c.SensorList) != 1 || c.SensorList[0].Name != "Temp" || len(c.Controllers) != 1 || c.Controllers[0].VoltagePin != "A4" {
+		t.Errorf("migrated config is %+v", c)
+	}
+	if c := cs[1]; !c.Secondary() || !c.UsingVidforward {
+		t.Errorf("migrated secondary config is %+v", c)
+	}
+
+	cs[0].Active = true
+	err = PutBroadcastConfig(ctx, store, &cs[0])
+	if err != nil {
+		t.Fatalf("PutBroadcastConfig returned error: %v", err)
+	}
+	c, err = GetBroadcastConfig(ctx, store, skey, "Rapid Bay")
+	if err != nil || !c.Active {
+		t.Errorf("GetBroadcastConfig returned %+v, %v, want active config", c, err)
+	}
+
+	err = DeleteBroadcastConfig(ctx, store, skey, "Stony Point")
+	if err != nil {
+		t.Fatalf("DeleteBroadcastConfig returned error: %v", err)
+	}
+	_, err = GetBroadcastConfig(ctx, store, skey, "Unknown")
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetBroadcastConfig of unknown broadcast returned %v", err)
+	}
+}
+
+// TestBroadcastArchive tests broadcast archives.
+func TestBroadcastArchive(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	for _, a := range []BroadcastArchive{
+		{VideoID: "abc", Skey: 1, Name: "Rapid Bay", ActualStart: start, LiveMinutes: 60, Views: 10},
+		{VideoID: "def", Skey: 1, Name: "Rapid Bay", ActualStart: start.AddDate(0, 0, 1), LiveMinutes: 30},
+		{VideoID: "ghi", Skey: 1, Name: "Rapid Bay", ActualStart: start.AddDate(0, 0, 2)},
+		{VideoID: "jkl", Skey: 2, Name: "Stony Point", ActualStart: start},
+	} {
+		err = PutBroadcastArchive(ctx, store, &a)
+		if err != nil {
+			t.Fatalf("PutBroadcastArchive returned error: %v", err)
+		}
+	}
+
+	a, err := GetBroadcastArchive(ctx, store, "abc")
+	if err != nil {
+		t.Fatalf("GetBroadcastArchive returned error: %v", err)
+	}
+	if a.Skey != 1 || a.LiveMinutes != 60 || a.Views != 10 || a.Created.IsZero() {
+		t.Errorf("GetBroadcastArchive returned %+v", a)
+	}
+
+	as, err := GetBroadcastArchives(ctx, store, 1, start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("GetBroadcastArchives returned error: %v", err)
+	}
+	if len(as) != 2 || as[0].VideoID != "def" || as[1].VideoID != "abc" {
+		t.Errorf("GetBroadcastArchives returned %+v, want def and abc", as)
+	}
+}
+
+// TestViewerSamples tests putting and getting viewer samples.
+func TestViewerSamples(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	ts := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	for _, v := range []ViewerSample{
+		{VideoID: "abc", Skey: 1, Timestamp: ts.Add(15 * time.Minute), Viewers: 12, Healthy: true},
+		{VideoID: "abc", Skey: 1, Timestamp: ts, Viewers: 3, Healthy: true},
+		{VideoID: "abc", Skey: 1, Timestamp: ts.Add(30 * time.Minute), Viewers: 7, Issues: 2},
+		{VideoID: "def", Skey: 2, Timestamp: ts, Viewers: 100},
+	} {
+		err = PutViewerSample(ctx, store, &v)
+		if err != nil {
+			t.Fatalf("PutViewerSample returned error: %v", err)
+		}
+	}
+
+	vs, err := GetViewerSamples(ctx, store, "abc")
+	if err != nil {
+		t.Fatalf("GetViewerSamples returned error: %v", err)
+	}
+	var got []int64
+	for _, v := range vs {
+		got = append(got, v.Viewers)
+	}
+	if len(got) != 3 || got[0] != 3 || got[1] != 12 || got[2] != 7 {
+		t.Errorf("GetViewerSamples returned viewers %v, want [3 12 7]", got)
+	}
+	if vs[2].Healthy || vs[2].Issues != 2 {
+		t.Errorf("GetViewerSamples returned %+v, want unhealthy with 2 issues", vs[2])
+	}
+}
+
+// TestMatchBroadcastConfig tests checking mirrors of BroadcastConfig.
+func TestMatchBroadcastConfig(t *testing.T) {
+	err := MatchBroadcastConfig(reflect.TypeOf(BroadcastConfig{}))
+	if err != nil {
+		t.Errorf("MatchBroadcastConfig of BroadcastConfig returned error: %v", err)
+	}
+
+	type controller struct{ MAC int64 }
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(struct{ SKey int64 }{}),
+		reflect.TypeOf(struct{ SKey, Name string }{}),
+		reflect.TypeOf(struct {
+			SKey        int64
+			Controllers []controller
+		}{}),
+	} {
+		err := MatchBroadcastConfig(typ)
+		if err == nil {
+			t.Errorf("MatchBroadcastConfig of %v returned no error", typ)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+DESCRIPTION
+  Entity caching with expiry and write-through invalidation.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Cache TTLs. Caches are per instance, so an entity updated by
+// another instance may be stale for up to its TTL. Updates made by
+// this instance invalidate the cache immediately.
+const (
+	siteCacheTTL   = 5 * time.Minute
+	deviceCacheTTL = time.Minute
+	varSumCacheTTL = 30 * time.Second
+)
+
+// TTLCache, which implements datastore.Cache, is an in-memory entity
+// cache in which entries expire after a given time-to-live (TTL).
+type TTLCache struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	data  map[datastore.Key]ttlEntry
+	clock func() time.Time // For testing.
+}
+
+// ttlEntry is a cached entity and its expiry time.
+type ttlEntry struct {
+	entity  datastore.Entity
+	expires time.Time
+}
+
+// NewTTLCache returns a new TTLCache with the given TTL.
+func NewTTLCache(ttl time.Duration) *TTLCache {
+	return &TTLCache{ttl: ttl, data: make(map[datastore.Key]ttlEntry), clock: time.Now}
+}
+
+// Set adds or updates a value to the cache.
+func (c *TTLCache) Set(key *datastore.Key, src datastore.Entity) error {
+	e, err := src.Copy(nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[*key] = ttlEntry{entity: e, expires: c.clock().Add(c.ttl)}
+	return nil
+}
+
+// Get retrieves a value from the cache, or returns
+// datastore.ErrCacheMiss if the key is not found or has expired.
+func (c *TTLCache) Get(key *datastore.Key, dst datastore.Entity) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.data[*key]
+	if !ok {
+		return datastore.ErrCacheMiss{}
+	}
+	if !c.clock().Before(e.expires) {
+		delete(c.data, *key)
+		return datastore.ErrCacheMiss{}
+	}
+	_, err := e.entity.Copy(dst)
+	return err
+}
+
+// Delete removes a value from the cache.
+func (c *TTLCache) Delete(key *datastore.Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, *key)
+}
+
+// Reset resets (clears) the cache.
+func (c *TTLCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = make(map[datastore.Key]ttlEntry)
+}
+
+var (
+	invalidationMu    sync.RWMutex
+	invalidationHooks []func(key *datastore.Key)
+)
+
+// AddInvalidationHook adds a function which is called whenever a
+// cached entity is written or deleted by this instance, e.g., to
+// propagate invalidations to other instances.
+func AddInvalidationHook(fn func(key *datastore.Key)) {
+	invalidationMu.Lock()
+	defer invalidationMu.Unlock()
+	invalidationHooks = append(invalidationHooks, fn)
+}
+
+// cacheable returns true if entities from store may be cached. The
+// FileStore, which is used for testing, does not use caches, so
+// neither do we, since caches are shared by all stores.
+func cacheable(store datastore.Store) bool {
+	_, filestore := store.(*datastore.FileStore)
+	return !filestore
+}
+
+// getCached gets an entity from its cache, if any, otherwise from the
+// store, in which case the entity is then cached.
+func getCached(ctx context.Context, store datastore.Store, key *datastore.Key, dst datastore.Entity) error {
+	cache := dst.GetCache()
+	if cache == nil || !cacheable(store) {
+		return store.Get(ctx, key, dst)
+	}
+	if cache.Get(key, dst) == nil {
+		return nil
+	}
+	err := store.Get(ctx, key, dst)
+	if err != nil {
+		return err
+	}
+	cache.Set(key, dst)
+	return nil
+}
+
+// invalidate removes an entity from cache, if any, and calls the
+// invalidation hooks. It should be called after an entity is written
+// or deleted.
+func invalidate(cache datastore.Cache, key *datastore.Key) {
+	if cache == nil {
+		return
+	}
+	cache.Delete(key)
+	invalidationMu.RLock()
+	defer invalidationMu.RUnlock()
+	for _, fn := range invalidationHooks {
+		fn(key)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// countingStore is a FileStore that counts gets and, unlike a
+// FileStore, is cacheable.
+type countingStore struct {
+	*datastore.FileStore
+	gets int
+}
+
+// Get counts gets then gets from the FileStore.
+func (s *countingStore) Get(ctx context.Context, key *datastore.Key, dst datastore.Entity) error {
+	s.gets++
+	return s.FileStore.Get(ctx, key, dst)
+}
+
+// TestTTLCache tests cache expiry.
+func TestTTLCache(t *testing.T) {
+	now := time.Now()
+	c := NewTTLCache(time.Minute)
+	c.clock = func() time.Time { return now }
+
+	key := &datastore.Key{Kind: typeSite, ID: 1}
+	err := c.Set(key, &Site{Skey: 1, Name: "Rapid Bay"})
+	if err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	var site Site
+	err = c.Get(key, &site)
+	if err != nil || site.Name != "Rapid Bay" {
+		t.Errorf("Get returned %v, %v", site, err)
+	}
+	now = now.Add(time.Minute)
+	err = c.Get(key, &site)
+	if err == nil {
+		t.Errorf("Get after expiry did not return error")
+	}
+}
+
+// TestSiteCaching tests read-through caching and invalidation of sites.
+func TestSiteCaching(t *testing.T) {
+	ctx := context.Background()
+	fs, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+	store := &countingStore{FileStore: fs.(*datastore.FileStore)}
+	defer siteCache.Reset()
+
+	var invalidated []int64
+	AddInvalidationHook(func(key *datastore.Key) { invalidated = append(invalidated, key.ID) })
+	defer func() { invalidationHooks = nil }()
+
+	site := &Site{Skey: 1, Name: "Rapid Bay"}
+	err = PutSite(ctx, store, site)
+	if err != nil {
+		t.Fatalf("PutSite returned error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		s, err := GetSite(ctx, store, 1)
+		if err != nil || s.Name != "Rapid Bay" {
+			t.Fatalf("GetSite returned %v, %v", s, err)
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("got %d store gets, expected 1", store.gets)
+	}
+
+	site.Name = "Stony Point"
+	err = PutSite(ctx, store, site)
+	if err != nil {
+		t.Fatalf("PutSite returned error: %v", err)
+	}
+	s, err := GetSite(ctx, store, 1)
+	if err != nil || s.Name != "Stony Point" {
+		t.Errorf("GetSite after update returned %v, %v", s, err)
+	}
+	if store.gets != 2 {
+		t.Errorf("got %d store gets, expected 2", store.gets)
+	}
+	if len(invalidated) != 2 || invalidated[0] != 1 {
+		t.Errorf("got invalidations %v, expected [1 1]", invalidated)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+DESCRIPTION
+  DeviceCommand datastore type and functions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeDeviceCommand = "DeviceCommand" // DeviceCommand datastore type.
+
+const (
+	MaxDeviceCommands      = 50                 // Maximum commands stored per device.
+	DeviceCommandRetention = 7 * 24 * time.Hour // Time commands are kept, after which pending commands expire.
+)
+
+// ErrTooManyCommands is returned when queuing a command for a device
+// that has MaxDeviceCommands pending commands.
+var ErrTooManyCommands = errors.New("too many pending commands")
+
+// DeviceCommand represents a command queued for a device. Unlike
+// actuation via variables, commands are delivered to the device when
+// it polls and remain pending until the device acknowledges them,
+// which allows services to know whether a command was executed. The
+// key is the MAC address concatenated with the ID.
+type DeviceCommand struct {
+	Mac       int64     // MAC address of device.
+	ID        int64     // Command ID.
+	Skey      int64     // Site key of device.
+	Command   string    // Command name, e.g., "reboot".
+	Payload   string    // Command payload, if any.
+	Issued    time.Time // Date/time the command was queued.
+	Delivered time.Time // Date/time the command was first delivered, or zero.
+	Acked     time.Time // Date/time the command was acknowledged, or zero.
+	Result    string    // Result reported by the device, if any.
+}
+
+// Copy copies a command to dst, or returns a copy of the command when dst is nil.
+func (c *DeviceCommand) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var c2 *DeviceCommand
+	if dst == nil {
+		c2 = new(DeviceCommand)
+	} else {
+		var ok bool
+		c2, ok = dst.(*DeviceCommand)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*c2 = *c
+	return c2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (c *DeviceCommand) GetCache() datastore.Cache {
+	return nil
+}
+
+// Pending returns true if the command has not been acknowledged.
+func (c *DeviceCommand) Pending() bool {
+	return c.Acked.IsZero()
+}
+
+// Expired returns true if the command was issued more than
+// DeviceCommandRetention ago.
+func (c *DeviceCommand) Expired() bool {
+	return time.Since(c.Issued) > DeviceCommandRetention
+}
+
+// deviceCommandKey returns the key for a device command.
+func deviceCommandKey(store datastore.Store, mac, id int64) *datastore.Key {
+	return store.NameKey(typeDeviceCommand, strconv.FormatInt(mac, 10)+"."+strconv.FormatInt(id, 10))
+}
+
+// CreateDeviceCommand queues a new command with a unique ID. The
+// device's expired commands are deleted first, as are its oldest
+// acknowledged commands when it has MaxDeviceCommands commands.
+// ErrTooManyCommands is returned if the device's commands are all
+// pending.
+func CreateDeviceCommand(ctx context.Context, store datastore.Store, c *DeviceCommand) error {
+	err := pruneDeviceCommands(ctx, store, c.Mac)
+	if err != nil {
+		return err
+	}
+	c.Issued = time.Now()
+	for {
+		c.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, deviceCommandKey(store, c.Mac, c.ID), c)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create device command: %w", err)
+		}
+	}
+}
+
+// PutDeviceCommand updates a command.
+func PutDeviceCommand(ctx context.Context, store datastore.Store, c *DeviceCommand) error {
+	_, err := store.Put(ctx, deviceCommandKey(store, c.Mac, c.ID), c)
+	return err
+}
+
+// GetDeviceCommand gets a command.
+func GetDeviceCommand(ctx context.Context, store datastore.Store, mac, id int64) (*DeviceCommand, error) {
+	c := new(DeviceCommand)
+	err := store.Get(ctx, deviceCommandKey(store, mac, id), c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// pruneDeviceCommands deletes a device's expired commands, and its
+// oldest acknowledged commands in order to make room for a new
+// command, returning ErrTooManyCommands if there is no room.
+func pruneDeviceCommands(ctx context.Context, store datastore.Store, mac int64) error {
+	cmds, err := GetDeviceCommands(ctx, store, mac, false)
+	if err != nil {
+		return fmt.Errorf("could not get device commands: %w", err)
+	}
+	var keys []*datastore.Key
+	n := len(cmds)
+	for _, c := range cmds {
+		if c.Expired() || (n >= MaxDeviceCommands && !c.Pending()) {
+			keys = append(keys, deviceCommandKey(store, c.Mac, c.ID))
+			n--
+		}
+	}
+	if len(keys) > 0 {
+		err = store.DeleteMulti(ctx, keys)
+		if err != nil {
+			return fmt.Errorf("could not delete device commands: %w", err)
+		}
+	}
+	if n >= MaxDeviceCommands {
+		return ErrTooManyCommands
+	}
+	return nil
+}
+
+// GetDeviceCommands returns the commands for a device in the order
+// they were issued, optionally only those that are pending and have
+// not expired.
+func GetDeviceCommands(ctx context.Context, store datastore.Store, mac int64, pending bool) ([]DeviceCommand, error) {
+	q := store.NewQuery(typeDeviceCommand, false, "Mac", "ID")
+	q.Filter("Mac =", mac)
+	var all []DeviceCommand
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var cmds []DeviceCommand
+	for _, c := range all {
+		if !pending || (c.Pending() && !c.Expired()) {
+			cmds = append(cmds, c)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Issued.Before(cmds[j].Issued) })
+	return cmds, nil
+}
+
+// AckDeviceCommand marks a command as acknowledged with the given
+// result. Acknowledging a command more than once has no effect.
+func AckDeviceCommand(ctx context.Context, store datastore.Store, mac, id int64, result string) error {
+	return store.Update(ctx, deviceCommandKey(store, mac, id), func(e datastore.Entity) {
+		c, ok := e.(*DeviceCommand)
+		if ok && c.Pending() {
+			c.Acked = time.Now()
+			c.Result = result
+		}
+	}, &DeviceCommand{})
+}
+
+// DeleteDeviceCommand deletes a command.
+func DeleteDeviceCommand(ctx context.Context, store datastore.Store, mac, id int64) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{deviceCommandKey(store, mac, id)})
+}
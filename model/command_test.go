@@ -0,0 +1,131 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestDeviceCommands tests queuing and acknowledging device commands.
+func TestDeviceCommands(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mac = 1
+	for _, cmd := range []string{"reboot", "upgrade"} {
+		err = CreateDeviceCommand(ctx, store, &DeviceCommand{Mac: mac, Skey: 1, Command: cmd})
+		if err != nil {
+			t.Fatalf("CreateDeviceCommand returned error: %v", err)
+		}
+	}
+
+	cmds, err := GetDeviceCommands(ctx, store, mac, true)
+	if err != nil {
+		t.Fatalf("GetDeviceCommands returned error: %v", err)
+	}
+	if len(cmds) != 2 || cmds[0].Command != "reboot" {
+		t.Fatalf("did not get expected pending commands, got: %v", cmds)
+	}
+
+	err = AckDeviceCommand(ctx, store, mac, cmds[0].ID, "ok")
+	if err != nil {
+		t.Fatalf("AckDeviceCommand returned error: %v", err)
+	}
+	err = AckDeviceCommand(ctx, store, mac, cmds[0].ID, "again")
+	if err != nil {
+		t.Fatalf("AckDeviceCommand returned error: %v", err)
+	}
+
+	c, err := GetDeviceCommand(ctx, store, mac, cmds[0].ID)
+	if err != nil {
+		t.Fatalf("GetDeviceCommand returned error: %v", err)
+	}
+	if c.Pending() || c.Result != "ok" {
+		t.Errorf("expected acknowledged command with result ok, got: %v", c)
+	}
+
+	cmds, err = GetDeviceCommands(ctx, store, mac, true)
+	if err != nil {
+		t.Fatalf("GetDeviceCommands returned error: %v", err)
+	}
+	if len(cmds) != 1 || cmds[0].Command != "upgrade" {
+		t.Errorf("did not get expected pending commands, got: %v", cmds)
+	}
+}
+
+// TestDeviceCommandLimits tests that expired and excess acknowledged
+// commands are deleted, and that pending commands are capped.
+func TestDeviceCommandLimits(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mac = 1
+	expired := &DeviceCommand{Mac: mac, ID: 1, Command: "reboot", Issued: time.Now().Add(-DeviceCommandRetention - time.Hour)}
+	err = PutDeviceCommand(ctx, store, expired)
+	if err != nil {
+		t.Fatalf("PutDeviceCommand returned error: %v", err)
+	}
+	for i := 0; i < MaxDeviceCommands; i++ {
+		c := &DeviceCommand{Mac: mac, Command: "reboot"}
+		err = CreateDeviceCommand(ctx, store, c)
+		if err != nil {
+			t.Fatalf("CreateDeviceCommand %d returned error: %v", i, err)
+		}
+		if i == 0 {
+			err = AckDeviceCommand(ctx, store, mac, c.ID, "ok")
+			if err != nil {
+				t.Fatalf("AckDeviceCommand returned error: %v", err)
+			}
+		}
+	}
+	_, err = GetDeviceCommand(ctx, store, mac, expired.ID)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected expired command to be deleted, got error: %v", err)
+	}
+
+	// The acknowledged command makes room for one more command.
+	err = CreateDeviceCommand(ctx, store, &DeviceCommand{Mac: mac, Command: "reboot"})
+	if err != nil {
+		t.Fatalf("CreateDeviceCommand returned error: %v", err)
+	}
+	err = CreateDeviceCommand(ctx, store, &DeviceCommand{Mac: mac, Command: "reboot"})
+	if !errors.Is(err, ErrTooManyCommands) {
+		t.Errorf("CreateDeviceCommand returned %v, expected %v", err, ErrTooManyCommands)
+	}
+	cmds, err := GetDeviceCommands(ctx, store, mac, false)
+	if err != nil {
+		t.Fatalf("GetDeviceCommands returned error: %v", err)
+	}
+	if len(cmds) != MaxDeviceCommands {
+		t.Errorf("got %d commands, expected %d", len(cmds), MaxDeviceCommands)
+	}
+}
@@ -141,7 +141,7 @@ func GetCredentialsByMID(ctx context.Context, store datastore.Store, mid int64)
 	q.Order("Name")
 
 	var creds []Credential
-	_, err := store.GetAll(ctx, q, &creds)
+	_, err := getAll(ctx, store, q, &creds, idxCredentialMID)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +155,7 @@ func GetCredentialsByName(ctx context.Context, store datastore.Store, name strin
 	q.Order("MID")
 
 	var creds []Credential
-	_, err := store.GetAll(ctx, q, &creds)
+	_, err := getAll(ctx, store, q, &creds, idxCredentialName)
 	if err != nil {
 		return nil, err
 	}
@@ -55,18 +55,25 @@ type Cron struct {
 	Var     string    // Action variable (if any).
 	Data    string    `datastore:",noindex"` // Action data (if any).
 	Enabled bool      // True if enabled, false otherwise.
+	Jitter  int64     // Random jitter in minutes, applied either side of the cron time.
 }
 
-// Encode serializes a Cron into tab-separated values.
+// Encode serializes a Cron into tab-separated values. Jitter is
+// only encoded when non-zero, so that crons without jitter remain
+// compatible with the original encoding.
 func (c *Cron) Encode() []byte {
-	return []byte(fmt.Sprintf("%d\t%s\t%d\t%s\t%t\t%d\t%s\t%s\t%s\t%t",
-		c.Skey, c.ID, c.Time.Unix(), c.TOD, c.Repeat, c.Minutes, c.Action, c.Var, c.Data, c.Enabled))
+	enc := fmt.Sprintf("%d\t%s\t%d\t%s\t%t\t%d\t%s\t%s\t%s\t%t",
+		c.Skey, c.ID, c.Time.Unix(), c.TOD, c.Repeat, c.Minutes, c.Action, c.Var, c.Data, c.Enabled)
+	if c.Jitter != 0 {
+		enc += fmt.Sprintf("\t%d", c.Jitter)
+	}
+	return []byte(enc)
 }
 
 // Decode deserializes a Cron from tab-separated values.
 func (c *Cron) Decode(b []byte) error {
 	p := strings.Split(string(b), "\t")
-	if len(p) != 10 {
+	if len(p) != 10 && len(p) != 11 {
 		return datastore.ErrDecoding
 	}
 	var err error
@@ -97,6 +104,12 @@ func (c *Cron) Decode(b []byte) error {
 	if err != nil {
 		return datastore.ErrDecoding
 	}
+	if len(p) == 11 {
+		c.Jitter, err = strconv.ParseInt(p[10], 10, 64)
+		if err != nil {
+			return datastore.ErrDecoding
+		}
+	}
 	return nil
 }
 
@@ -134,7 +147,7 @@ func GetCronsBySite(ctx context.Context, store datastore.Store, skey int64) ([]C
 	q.Filter("Skey =", skey)
 	q.Order("ID")
 	var crons []Cron
-	_, err := store.GetAll(ctx, q, &crons)
+	_, err := getAll(ctx, store, q, &crons, idxCronSite)
 	return crons, err
 }
 
@@ -0,0 +1,204 @@
+/*
+DESCRIPTION
+  CronRun datastore type and functions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeCronRun = "CronRun" // CronRun datastore type.
+
+// CronRunRetention is the period for which cron runs are kept.
+const CronRunRetention = 30 * 24 * time.Hour
+
+// cronRunDeleteBatch is the maximum number of cron runs deleted at once.
+const cronRunDeleteBatch = 500
+
+// Cron run outcomes.
+const (
+	CronRunning   = "running"
+	CronSucceeded = "succeeded"
+	CronFailed    = "failed"
+	CronSkipped   = "skipped"
+)
+
+// CronRun represents a single execution of a cron. The key is the
+// site key, cron ID and scheduled time (in Unix seconds), so at most
+// one run can exist for each scheduled time. Creating the run
+// therefore acts as a lock, which ensures that a cron executes at most
+// once per scheduled time even when multiple schedulers are running.
+type CronRun struct {
+	Skey      int64     // Site key.
+	CronID    string    // Cron ID.
+	Scheduled time.Time // Scheduled time.
+	Instance  string    // Instance that claimed the run.
+	Started   time.Time // Date/time the run started.
+	Finished  time.Time // Date/time the run finished, or zero if running.
+	Outcome   string    // Run outcome, i.e., running, succeeded, failed or skipped.
+	Error     string    `datastore:",noindex"` // Error text if failed, or reason if skipped.
+}
+
+// Copy copies a cron run to dst, or returns a copy of the cron run when dst is nil.
+func (r *CronRun) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var r2 *CronRun
+	if dst == nil {
+		r2 = new(CronRun)
+	} else {
+		var ok bool
+		r2, ok = dst.(*CronRun)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*r2 = *r
+	return r2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (r *CronRun) GetCache() datastore.Cache {
+	return nil
+}
+
+// cronRunKey returns the key for a cron run.
+func cronRunKey(store datastore.Store, r *CronRun) *datastore.Key {
+	return store.NameKey(typeCronRun, strconv.FormatInt(r.Skey, 10)+"."+r.CronID+"."+strconv.FormatInt(r.Scheduled.Unix(), 10))
+}
+
+// ClaimCronRun creates a cron run, marking it as running. It returns
+// datastore.ErrEntityExists if the run has already been claimed.
+func ClaimCronRun(ctx context.Context, store datastore.Store, r *CronRun) error {
+	r.Started = time.Now()
+	r.Outcome = CronRunning
+	return store.Create(ctx, cronRunKey(store, r), r)
+}
+
+// FinishCronRun records the outcome of a cron run, which is failed if
+// err is non-nil, or succeeded otherwise.
+func FinishCronRun(ctx context.Context, store datastore.Store, r *CronRun, err error) error {
+	r.Finished = time.Now()
+	if err != nil {
+		r.Outcome = CronFailed
+		r.Error = err.Error()
+	} else {
+		r.Outcome = CronSucceeded
+	}
+	_, err = store.Put(ctx, cronRunKey(store, r), r)
+	return err
+}
+
+// SkipCronRun records that a cron run was skipped for the given reason.
+func SkipCronRun(ctx context.Context, store datastore.Store, r *CronRun, reason string) error {
+	r.Finished = time.Now()
+	r.Outcome = CronSkipped
+	r.Error = reason
+	_, err := store.Put(ctx, cronRunKey(store, r), r)
+	return err
+}
+
+// GetCronRuns returns the most recent runs of a cron, newest first,
+// up to limit runs, or all runs when limit is zero.
+func GetCronRuns(ctx context.Context, store datastore.Store, skey int64, id string, limit int) ([]CronRun, error) {
+	q := store.NewQuery(typeCronRun, false, "Skey", "CronID", "Scheduled")
+	q.Filter("Skey =", skey)
+	q.Filter("CronID =", id)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Order("-Scheduled")
+		if limit > 0 {
+			q.Limit(limit)
+		}
+	}
+	var runs []CronRun
+	_, err := getAll(ctx, store, q, &runs, idxCronRun)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Scheduled.After(runs[j].Scheduled) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// ConsecutiveFailures returns the number of consecutive failed runs,
+// counting back from the most recent run. Runs that are in progress
+// or skipped are ignored.
+func ConsecutiveFailures(runs []CronRun) int {
+	var n int
+	for _, r := range runs {
+		switch r.Outcome {
+		case CronFailed:
+			n++
+		case CronSucceeded:
+			return n
+		}
+	}
+	return n
+}
+
+// DeleteCronRuns deletes cron runs scheduled before t, returning the
+// number deleted.
+func DeleteCronRuns(ctx context.Context, store datastore.Store, t time.Time) (int, error) {
+	_, filestore := store.(*datastore.FileStore)
+	if filestore {
+		var runs []CronRun
+		keys, err := store.GetAll(ctx, store.NewQuery(typeCronRun, false, "Skey", "CronID", "Scheduled"), &runs)
+		if err != nil {
+			return 0, fmt.Errorf("could not get cron runs: %w", err)
+		}
+		var old []*datastore.Key
+		for i := range runs {
+			if runs[i].Scheduled.Before(t) {
+				old = append(old, keys[i])
+			}
+		}
+		return len(old), store.DeleteMulti(ctx, old)
+	}
+
+	var n int
+	for {
+		q := store.NewQuery(typeCronRun, true)
+		q.Filter("Scheduled <", t)
+		q.Limit(cronRunDeleteBatch)
+		keys, err := store.GetAll(ctx, q, nil)
+		if err != nil {
+			return n, fmt.Errorf("could not get cron run keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return n, nil
+		}
+		err = store.DeleteMulti(ctx, keys)
+		if err != nil {
+			return n, fmt.Errorf("could not delete cron runs: %w", err)
+		}
+		n += len(keys)
+		if len(keys) < cronRunDeleteBatch {
+			return n, nil
+		}
+	}
+}
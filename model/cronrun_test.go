@@ -0,0 +1,102 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestClaimCronRun tests that a cron run can only be claimed once.
+func TestClaimCronRun(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	sched := time.Now().Round(time.Minute)
+	err = ClaimCronRun(ctx, store, &CronRun{Skey: 1, CronID: "cron1", Scheduled: sched, Instance: "a"})
+	if err != nil {
+		t.Fatalf("ClaimCronRun returned error: %v", err)
+	}
+	err = ClaimCronRun(ctx, store, &CronRun{Skey: 1, CronID: "cron1", Scheduled: sched, Instance: "b"})
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		t.Errorf("expected ErrEntityExists, got: %v", err)
+	}
+	err = ClaimCronRun(ctx, store, &CronRun{Skey: 1, CronID: "cron1", Scheduled: sched.Add(time.Minute), Instance: "b"})
+	if err != nil {
+		t.Errorf("ClaimCronRun for next minute returned error: %v", err)
+	}
+}
+
+// TestGetCronRuns tests getting recent cron runs and counting failures.
+func TestGetCronRuns(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	start := time.Now().Round(time.Minute)
+	for i, fail := range []bool{false, true, false, true, true} {
+		r := &CronRun{Skey: 1, CronID: "cron1", Scheduled: start.Add(time.Duration(i) * time.Minute)}
+		err = ClaimCronRun(ctx, store, r)
+		if err != nil {
+			t.Fatalf("ClaimCronRun returned error: %v", err)
+		}
+		var runErr error
+		if fail {
+			runErr = errors.New("failed")
+		}
+		err = FinishCronRun(ctx, store, r, runErr)
+		if err != nil {
+			t.Fatalf("FinishCronRun returned error: %v", err)
+		}
+	}
+
+	runs, err := GetCronRuns(ctx, store, 1, "cron1", 4)
+	if err != nil {
+		t.Fatalf("GetCronRuns returned error: %v", err)
+	}
+	if len(runs) != 4 || !runs[0].Scheduled.Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("did not get expected runs, got: %v", runs)
+	}
+	if n := ConsecutiveFailures(runs); n != 2 {
+		t.Errorf("unexpected consecutive failures, got: %d, want: 2", n)
+	}
+
+	n, err := DeleteCronRuns(ctx, store, start.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteCronRuns returned error: %v", err)
+	}
+	runs, err = GetCronRuns(ctx, store, 1, "cron1", 0)
+	if err != nil {
+		t.Fatalf("GetCronRuns returned error: %v", err)
+	}
+	if n != 2 || len(runs) != 3 {
+		t.Errorf("unexpected runs after deleting %d: %v", n, runs)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+DESCRIPTION
+  CronSync datastore type and functions. A cron sync is a pending
+  request to synchronize a cron with the cron scheduler, which is
+  retried with backoff until the scheduler accepts it.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeCronSync = "CronSync" // CronSync datastore type.
+
+// Cron sync retry backoff, which doubles after each failed attempt.
+const (
+	cronSyncMinBackoff = time.Minute
+	cronSyncMaxBackoff = time.Hour
+)
+
+// CronSync represents a pending synchronization of a cron with the
+// cron scheduler. Syncs do not record whether the cron is to be set
+// or unset, since that is determined by the cron's state in the
+// datastore when the sync is attempted, so a sync is never stale. The
+// key is the site key and cron ID, so there is at most one pending
+// sync per cron.
+type CronSync struct {
+	Skey        int64     // Site key.
+	ID          string    // Cron ID.
+	Queued      time.Time // Date/time queued.
+	Attempts    int       // Number of failed attempts.
+	NextAttempt time.Time // Date/time of the next attempt.
+	LastError   string    `datastore:",noindex"` // Error of the last failed attempt, if any.
+}
+
+// Copy copies a cron sync to dst, or returns a copy of the cron sync when dst is nil.
+func (cs *CronSync) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var cs2 *CronSync
+	if dst == nil {
+		cs2 = new(CronSync)
+	} else {
+		var ok bool
+		cs2, ok = dst.(*CronSync)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*cs2 = *cs
+	return cs2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (cs *CronSync) GetCache() datastore.Cache {
+	return nil
+}
+
+// Due returns true if the sync should be attempted at time now.
+func (cs *CronSync) Due(now time.Time) bool {
+	return !now.Before(cs.NextAttempt)
+}
+
+func cronSyncKey(store datastore.Store, skey int64, id string) *datastore.Key {
+	return store.NameKey(typeCronSync, strconv.FormatInt(skey, 10)+"."+id)
+}
+
+// QueueCronSync queues a sync of a cron, due immediately, replacing
+// any pending sync of the same cron.
+func QueueCronSync(ctx context.Context, store datastore.Store, skey int64, id string) (*CronSync, error) {
+	// Datastore times have microsecond precision, and Queued identifies
+	// the sync when it is completed or fails.
+	now := time.Now().Truncate(time.Microsecond)
+	cs := &CronSync{Skey: skey, ID: id, Queued: now, NextAttempt: now}
+	_, err := store.Put(ctx, cronSyncKey(store, skey, id), cs)
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// GetCronSyncs returns all pending cron syncs, oldest first.
+func GetCronSyncs(ctx context.Context, store datastore.Store) ([]CronSync, error) {
+	var syncs []CronSync
+	_, err := store.GetAll(ctx, store.NewQuery(typeCronSync, false), &syncs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(syncs, func(i, j int) bool { return syncs[i].Queued.Before(syncs[j].Queued) })
+	return syncs, nil
+}
+
+// CompleteCronSync deletes a cron sync once it has succeeded, unless
+// the cron has been queued again since, in which case the newer sync
+// remains pending.
+func CompleteCronSync(ctx context.Context, store datastore.Store, cs *CronSync) error {
+	key := cronSyncKey(store, cs.Skey, cs.ID)
+	var current CronSync
+	err := store.Get(ctx, key, &current)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !current.Queued.Equal(cs.Queued) {
+		return nil
+	}
+	return store.DeleteMulti(ctx, []*datastore.Key{key})
+}
+
+// FailCronSync records a failed attempt of a cron sync at time now,
+// deferring the next attempt by a backoff that doubles with each
+// failure, from a minute up to an hour. A cron queued again since is
+// left as is, since its sync is due immediately.
+func FailCronSync(ctx context.Context, store datastore.Store, cs *CronSync, cause error, now time.Time) error {
+	var queued bool
+	err := store.Update(ctx, cronSyncKey(store, cs.Skey, cs.ID), func(ety datastore.Entity) {
+		current, ok := ety.(*CronSync)
+		if !ok || !current.Queued.Equal(cs.Queued) {
+			queued = true
+			return
+		}
+		current.Attempts++
+		current.LastError = cause.Error()
+		current.NextAttempt = now.Add(cronSyncBackoff(current.Attempts))
+		*cs = *current
+	}, &CronSync{})
+	if errors.Is(err, datastore.ErrNoSuchEntity) || queued {
+		return nil
+	}
+	return err
+}
+
+// cronSyncBackoff returns the delay before the next attempt after the
+// given number of failed attempts.
+func cronSyncBackoff(attempts int) time.Duration {
+	d := cronSyncMinBackoff
+	for i := 1; i < attempts && d < cronSyncMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, cronSyncMaxBackoff)
+}
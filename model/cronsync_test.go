@@ -0,0 +1,115 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestCronSync tests queuing, failing and completing cron syncs.
+func TestCronSync(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	cs, err := QueueCronSync(ctx, store, 1, "cron1")
+	if err != nil {
+		t.Fatalf("QueueCronSync returned error: %v", err)
+	}
+	now := time.Now()
+	if !cs.Due(now) {
+		t.Errorf("queued sync is not due")
+	}
+
+	// Failures back off exponentially.
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		err = FailCronSync(ctx, store, cs, errors.New("unavailable"), now)
+		if err != nil {
+			t.Fatalf("FailCronSync returned error: %v", err)
+		}
+		if cs.Attempts != i+1 || !cs.NextAttempt.Equal(now.Add(want)) {
+			t.Errorf("after %d failures got attempts %d, next attempt %v, expected %v", i+1, cs.Attempts, cs.NextAttempt.Sub(now), want)
+		}
+	}
+	if cs.Due(now) {
+		t.Errorf("failed sync is due before its backoff")
+	}
+
+	// Queuing the cron again replaces the failed sync, which can then
+	// be neither failed nor completed.
+	time.Sleep(time.Millisecond)
+	newer, err := QueueCronSync(ctx, store, 1, "cron1")
+	if err != nil {
+		t.Fatalf("QueueCronSync returned error: %v", err)
+	}
+	err = FailCronSync(ctx, store, cs, errors.New("unavailable"), now)
+	if err != nil {
+		t.Fatalf("FailCronSync of replaced sync returned error: %v", err)
+	}
+	err = CompleteCronSync(ctx, store, cs)
+	if err != nil {
+		t.Fatalf("CompleteCronSync of replaced sync returned error: %v", err)
+	}
+	syncs, err := GetCronSyncs(ctx, store)
+	if err != nil {
+		t.Fatalf("GetCronSyncs returned error: %v", err)
+	}
+	if len(syncs) != 1 || syncs[0].Attempts != 0 || !syncs[0].Due(time.Now()) {
+		t.Fatalf("got syncs %+v, expected one due sync", syncs)
+	}
+
+	err = CompleteCronSync(ctx, store, newer)
+	if err != nil {
+		t.Fatalf("CompleteCronSync returned error: %v", err)
+	}
+	syncs, err = GetCronSyncs(ctx, store)
+	if err != nil {
+		t.Fatalf("GetCronSyncs returned error: %v", err)
+	}
+	if len(syncs) != 0 {
+		t.Errorf("got %d syncs after completion, expected none", len(syncs))
+	}
+}
+
+func TestCronSyncBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 6, want: 32 * time.Minute},
+		{attempts: 7, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+	for _, test := range tests {
+		got := cronSyncBackoff(test.attempts)
+		if got != test.want {
+			t.Errorf("cronSyncBackoff(%d) = %v, expected %v", test.attempts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+DESCRIPTION
+  DataUsage datastore type and functions, which record the volume of
+  data ingested from devices.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeDataUsage = "DataUsage" // DataUsage datastore type.
+
+// DataUsageDateFormat is the format of DataUsage dates.
+const DataUsageDateFormat = "2006-01-02"
+
+// DataUsage represents the volume of data ingested from a device on
+// a given day (UTC), which is used to plan cellular data budgets.
+// Bytes counts request bodies and query strings, but not protocol
+// overhead, so actual cellular usage is somewhat higher. The key is
+// the MAC address concatenated with the date.
+type DataUsage struct {
+	Skey     int64     // Site key at the time the data was ingested.
+	Mac      int64     // Encoded MAC address.
+	Date     string    // UTC date in DataUsageDateFormat.
+	Bytes    int64     // Bytes ingested.
+	Requests int64     // Requests received.
+	Updated  time.Time // Date/time last updated.
+}
+
+// Copy copies a data usage to dst, or returns a copy of the data usage when dst is nil.
+func (u *DataUsage) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var u2 *DataUsage
+	if dst == nil {
+		u2 = new(DataUsage)
+	} else {
+		var ok bool
+		u2, ok = dst.(*DataUsage)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*u2 = *u
+	return u2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (u *DataUsage) GetCache() datastore.Cache {
+	return nil
+}
+
+// AddDataUsage adds bytes and requests to a device's data usage for
+// the day of t (UTC), creating the data usage if necessary.
+func AddDataUsage(ctx context.Context, store datastore.Store, skey, mac int64, t time.Time, bytes, requests int64) error {
+	date := t.UTC().Format(DataUsageDateFormat)
+	key := store.NameKey(typeDataUsage, strconv.FormatInt(mac, 10)+"."+date)
+	add := func(e datastore.Entity) {
+		u, ok := e.(*DataUsage)
+		if ok {
+			u.Skey = skey
+			u.Bytes += bytes
+			u.Requests += requests
+			u.Updated = time.Now()
+		}
+	}
+	err := store.Update(ctx, key, add, &DataUsage{})
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return err
+	}
+	u := &DataUsage{Skey: skey, Mac: mac, Date: date, Bytes: bytes, Requests: requests, Updated: time.Now()}
+	err = store.Create(ctx, key, u)
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		return err
+	}
+	// Another instance created it first.
+	return store.Update(ctx, key, add, &DataUsage{})
+}
+
+// GetDataUsage returns the data usage for a site from date from to
+// date to inclusive, ordered by date then MAC address. Dates are in
+// DataUsageDateFormat.
+func GetDataUsage(ctx context.Context, store datastore.Store, skey int64, from, to string) ([]DataUsage, error) {
+	q := store.NewQuery(typeDataUsage, false, "Skey", "Date")
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+		q.Filter("Date >=", from)
+		q.Filter("Date <=", to)
+	}
+	var all []DataUsage
+	_, err := getAll(ctx, store, q, &all, idxDataUsage)
+	if err != nil {
+		return nil, err
+	}
+	var usage []DataUsage
+	for _, u := range all {
+		if u.Skey == skey && u.Date >= from && u.Date <= to {
+			usage = append(usage, u)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Date != usage[j].Date {
+			return usage[i].Date < usage[j].Date
+		}
+		return usage[i].Mac < usage[j].Mac
+	})
+	return usage, nil
+}
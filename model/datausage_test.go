@@ -0,0 +1,85 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestDataUsage tests adding and getting data usage.
+func TestDataUsage(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const skey = 1
+	day1 := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	adds := []struct {
+		skey, mac int64
+		t         time.Time
+		bytes     int64
+	}{
+		{skey, 2, day1, 100},
+		{skey, 2, day1, 50},
+		{skey, 1, day1, 10},
+		{skey, 2, day2, 200},
+		{skey + 1, 3, day1, 1000}, // Another site.
+	}
+	for _, a := range adds {
+		err = AddDataUsage(ctx, store, a.skey, a.mac, a.t, a.bytes, 1)
+		if err != nil {
+			t.Fatalf("AddDataUsage returned error: %v", err)
+		}
+	}
+
+	usage, err := GetDataUsage(ctx, store, skey, "2026-03-01", "2026-03-02")
+	if err != nil {
+		t.Fatalf("GetDataUsage returned error: %v", err)
+	}
+	want := []DataUsage{
+		{Skey: skey, Mac: 1, Date: "2026-03-01", Bytes: 10, Requests: 1},
+		{Skey: skey, Mac: 2, Date: "2026-03-01", Bytes: 150, Requests: 2},
+		{Skey: skey, Mac: 2, Date: "2026-03-02", Bytes: 200, Requests: 1},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("GetDataUsage returned %d usages, expected %d", len(usage), len(want))
+	}
+	for i := range want {
+		usage[i].Updated = time.Time{}
+		if usage[i] != want[i] {
+			t.Errorf("usage %d: got %+v, expected %+v", i, usage[i], want[i])
+		}
+	}
+
+	usage, err = GetDataUsage(ctx, store, skey, "2026-03-02", "2026-03-02")
+	if err != nil {
+		t.Fatalf("GetDataUsage returned error: %v", err)
+	}
+	if len(usage) != 1 {
+		t.Errorf("GetDataUsage for one day returned %d usages, expected 1", len(usage))
+	}
+}
@@ -0,0 +1,164 @@
+/*
+DESCRIPTION
+  DeviceArchive datastore type and device decommissioning.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeDeviceArchive = "DeviceArchive" // DeviceArchive datastore type.
+
+// DeviceArchive holds the configuration of a decommissioned device,
+// i.e., the device itself, its variables, sensors and actuators. The
+// key is the MAC address concatenated with the archive time (in Unix
+// seconds), since a MAC address may be reused and decommissioned more
+// than once.
+type DeviceArchive struct {
+	Mac      int64     // MAC address of device.
+	Skey     int64     // Site key of device.
+	Name     string    // Device name.
+	Reason   string    // Reason for decommissioning.
+	Archived time.Time // Date/time archived.
+	Config   []byte    `datastore:",noindex"` // Device configuration, as JSON encoded DeviceConfig.
+}
+
+// DeviceConfig is the archived configuration of a device.
+type DeviceConfig struct {
+	Device    Device
+	Variables []Variable
+	Sensors   []SensorV2
+	Actuators []ActuatorV2
+}
+
+// Copy copies a device archive to dst, or returns a copy of the device archive when dst is nil.
+func (a *DeviceArchive) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var a2 *DeviceArchive
+	if dst == nil {
+		a2 = new(DeviceArchive)
+	} else {
+		var ok bool
+		a2, ok = dst.(*DeviceArchive)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*a2 = *a
+	return a2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (a *DeviceArchive) GetCache() datastore.Cache {
+	return nil
+}
+
+// DecommissionDevice archives the configuration of a device then
+// deletes the device, its variables, sensors and actuators. The MAC
+// address is then free to be reused, e.g., by reinstalling the
+// device.
+func DecommissionDevice(ctx context.Context, store datastore.Store, mac int64, reason string) (*DeviceArchive, error) {
+	dev, err := GetDevice(ctx, store, mac)
+	if err != nil {
+		return nil, fmt.Errorf("could not get device: %w", err)
+	}
+	cfg := DeviceConfig{Device: *dev}
+	for _, scope := range []string{dev.Hex(), "_" + dev.Hex()} {
+		vars, err := GetVariablesBySite(ctx, store, dev.Skey, scope)
+		if err != nil {
+			return nil, fmt.Errorf("could not get variables: %w", err)
+		}
+		cfg.Variables = append(cfg.Variables, vars...)
+	}
+	cfg.Sensors, err = GetSensorsV2(ctx, store, mac)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sensors: %w", err)
+	}
+	cfg.Actuators, err = GetActuatorsV2(ctx, store, mac)
+	if err != nil {
+		return nil, fmt.Errorf("could not get actuators: %w", err)
+	}
+
+	a := &DeviceArchive{Mac: mac, Skey: dev.Skey, Name: dev.Name, Reason: reason, Archived: time.Now()}
+	a.Config, err = json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode device config: %w", err)
+	}
+	key := store.NameKey(typeDeviceArchive, strconv.FormatInt(mac, 10)+"."+strconv.FormatInt(a.Archived.Unix(), 10))
+	_, err = store.Put(ctx, key, a)
+	if err != nil {
+		return nil, fmt.Errorf("could not put device archive: %w", err)
+	}
+
+	// Only delete once archived.
+	for _, scope := range []string{dev.Hex(), "_" + dev.Hex()} {
+		err = DeleteVariables(ctx, store, dev.Skey, scope)
+		if err != nil {
+			return nil, fmt.Errorf("could not delete variables: %w", err)
+		}
+	}
+	for _, s := range cfg.Sensors {
+		err = DeleteSensorV2(ctx, store, mac, s.Pin)
+		if err != nil {
+			return nil, fmt.Errorf("could not delete sensor: %w", err)
+		}
+	}
+	for _, act := range cfg.Actuators {
+		err = DeleteActuatorV2(ctx, store, mac, act.Pin)
+		if err != nil {
+			return nil, fmt.Errorf("could not delete actuator: %w", err)
+		}
+	}
+	err = DeleteDevice(ctx, store, mac)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete device: %w", err)
+	}
+	return a, nil
+}
+
+// GetDeviceArchives returns the archives for a MAC address, newest first.
+func GetDeviceArchives(ctx context.Context, store datastore.Store, mac int64) ([]DeviceArchive, error) {
+	q := store.NewQuery(typeDeviceArchive, false, "Mac", "Archived")
+	q.Filter("Mac =", mac)
+	var as []DeviceArchive
+	_, err := store.GetAll(ctx, q, &as)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Archived.After(as[j].Archived) })
+	return as, nil
+}
+
+// DeviceConfig returns the archived configuration.
+func (a *DeviceArchive) DeviceConfig() (*DeviceConfig, error) {
+	var cfg DeviceConfig
+	err := json.Unmarshal(a.Config, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,80 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestDecommissionDevice tests that decommissioning archives and deletes a device.
+func TestDecommissionDevice(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	dev := &Device{Skey: 1, Mac: 1, Dkey: 1, Name: "test", Inputs: "A0", Enabled: true}
+	err = PutDevice(ctx, store, dev)
+	if err != nil {
+		t.Fatalf("PutDevice returned error: %v", err)
+	}
+	err = PutVariable(ctx, store, 1, dev.Hex()+".mode", "Normal")
+	if err != nil {
+		t.Fatalf("PutVariable returned error: %v", err)
+	}
+	err = PutSensorV2(ctx, store, &SensorV2{Name: "battery", Mac: 1, Pin: "A0"})
+	if err != nil {
+		t.Fatalf("PutSensorV2 returned error: %v", err)
+	}
+
+	_, err = DecommissionDevice(ctx, store, 1, "retired")
+	if err != nil {
+		t.Fatalf("DecommissionDevice returned error: %v", err)
+	}
+
+	_, err = GetDevice(ctx, store, 1)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetDevice returned %v, expected %v", err, datastore.ErrNoSuchEntity)
+	}
+	vars, err := GetVariablesBySite(ctx, store, 1, dev.Hex())
+	if err != nil || len(vars) != 0 {
+		t.Errorf("GetVariablesBySite returned %v, %v, expected no variables", vars, err)
+	}
+
+	as, err := GetDeviceArchives(ctx, store, 1)
+	if err != nil {
+		t.Fatalf("GetDeviceArchives returned error: %v", err)
+	}
+	if len(as) != 1 || as[0].Reason != "retired" {
+		t.Fatalf("did not get expected archives, got: %v", as)
+	}
+	cfg, err := as[0].DeviceConfig()
+	if err != nil {
+		t.Fatalf("DeviceConfig returned error: %v", err)
+	}
+	if cfg.Device.Name != "test" || len(cfg.Variables) != 1 || len(cfg.Sensors) != 1 {
+		t.Errorf("did not get expected config, got: %+v", cfg)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+DESCRIPTION
+  Detection datastore type and functions. Detections are the queue of
+  audio detection jobs for received audio, which are deleted once the
+  audio's detectors have been run.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeDetection = "Detection" // Detection datastore type.
+
+// DetectionAttempts is the maximum number of times running the
+// detectors on audio is attempted before the detection is dropped.
+const DetectionAttempts = 3
+
+// ErrDetectionClaimed is returned when claiming a detection that has
+// been claimed by another worker.
+var ErrDetectionClaimed = errors.New("detection already claimed")
+
+// Detection represents a pending run of the audio detectors of a
+// device on the audio of a MID received from Start to End inclusive,
+// i.e., the timestamps of the first and last segments of a clip. The
+// key is the MID and start, so that retried uploads are only queued
+// once.
+type Detection struct {
+	MID      int64     // Media ID.
+	Start    int64     // Timestamp of the first segment, as a Unix time.
+	End      int64     // Timestamp of the last segment, as a Unix time.
+	Attempts int64     // Number of attempts to run the detectors.
+	Claimed  time.Time // Date/time last claimed by a worker, if any.
+	Created  time.Time // Date/time queued.
+}
+
+// Copy copies a detection to dst, or returns a copy of the detection when dst is nil.
+func (d *Detection) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var d2 *Detection
+	if dst == nil {
+		d2 = new(Detection)
+	} else {
+		var ok bool
+		d2, ok = dst.(*Detection)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*d2 = *d
+	return d2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (d *Detection) GetCache() datastore.Cache {
+	return nil
+}
+
+// detectionKey returns the datastore key for a detection.
+func detectionKey(store datastore.Store, mid, start int64) *datastore.Key {
+	return store.NameKey(typeDetection, fmt.Sprintf("%d.%d", mid, start))
+}
+
+// QueueDetection queues a detection for the audio of a MID from start
+// to end. Queuing audio that is already queued has no effect.
+func QueueDetection(ctx context.Context, store datastore.Store, mid, start, end int64) error {
+	d := &Detection{MID: mid, Start: start, End: end, Created: time.Now()}
+	err := store.Create(ctx, detectionKey(store, mid, start), d)
+	if err != nil && !errors.Is(err, datastore.ErrEntityExists) {
+		return fmt.Errorf("could not create detection: %w", err)
+	}
+	return nil
+}
+
+// GetPendingDetections returns up to limit queued detections, oldest
+// first, including those claimed by workers that presumably died.
+func GetPendingDetections(ctx context.Context, store datastore.Store, limit int) ([]Detection, error) {
+	q := store.NewQuery(typeDetection, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Order("Created")
+		q.Limit(limit)
+	}
+	var ds []Detection
+	_, err := store.GetAll(ctx, q, &ds)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Created.Before(ds[j].Created) })
+	if len(ds) > limit {
+		ds = ds[:limit]
+	}
+	return ds, nil
+}
+
+// ClaimDetection claims a detection for a worker, which prevents other
+// workers from running it concurrently. ErrDetectionClaimed is returned
+// if the detection was claimed since stale, or if it has already been
+// attempted DetectionAttempts times, in which case it is deleted.
+func ClaimDetection(ctx context.Context, store datastore.Store, mid, start int64, stale time.Time) (*Detection, error) {
+	var claimErr error
+	var expired bool
+	d := new(Detection)
+	key := detectionKey(store, mid, start)
+	err := store.Update(ctx, key, func(e datastore.Entity) {
+		d2, ok := e.(*Detection)
+		if !ok {
+			claimErr = datastore.ErrWrongType
+			return
+		}
+		if d2.Claimed.After(stale) {
+			claimErr = ErrDetectionClaimed
+			return
+		}
+		if d2.Attempts >= DetectionAttempts {
+			// The last attempt's worker died or failed.
+			claimErr = ErrDetectionClaimed
+			expired = true
+			return
+		}
+		d2.Attempts++
+		d2.Claimed = time.Now()
+	}, d)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		err = store.Delete(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not delete expired detection: %w", err)
+		}
+	}
+	if claimErr != nil {
+		return nil, claimErr
+	}
+	return d, nil
+}
+
+// DeleteDetection deletes a detection once its detectors have been run.
+func DeleteDetection(ctx context.Context, store datastore.Store, mid, start int64) error {
+	return store.Delete(ctx, detectionKey(store, mid, start))
+}
@@ -0,0 +1,92 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestDetections tests queuing, claiming and deleting detections.
+func TestDetections(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const (
+		mid   = 123
+		start = 1700000000
+		end   = start + 2
+	)
+	for i := 0; i < 2; i++ {
+		err = QueueDetection(ctx, store, mid, start, end) // Retries are queued once.
+		if err != nil {
+			t.Fatalf("QueueDetection returned error: %v", err)
+		}
+	}
+	err = QueueDetection(ctx, store, mid, start+10, end+10)
+	if err != nil {
+		t.Fatalf("QueueDetection returned error: %v", err)
+	}
+
+	pending, err := GetPendingDetections(ctx, store, 10)
+	if err != nil {
+		t.Fatalf("GetPendingDetections returned error: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Start != start || pending[0].End != end {
+		t.Fatalf("GetPendingDetections returned %v, expected 2 detections, oldest first", pending)
+	}
+
+	// Claimed detections are reclaimed once stale, until the maximum
+	// number of attempts. A stale time in the future treats all claims
+	// as stale.
+	for i := 1; i <= DetectionAttempts; i++ {
+		_, err = ClaimDetection(ctx, store, mid, start, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("ClaimDetection %d returned error: %v", i, err)
+		}
+		_, err = ClaimDetection(ctx, store, mid, start, time.Now().Add(-time.Minute))
+		if !errors.Is(err, ErrDetectionClaimed) {
+			t.Errorf("ClaimDetection of claimed detection returned %v, expected %v", err, ErrDetectionClaimed)
+		}
+	}
+	_, err = ClaimDetection(ctx, store, mid, start, time.Now().Add(time.Minute))
+	if !errors.Is(err, ErrDetectionClaimed) {
+		t.Errorf("ClaimDetection of expired detection returned %v, expected %v", err, ErrDetectionClaimed)
+	}
+
+	err = DeleteDetection(ctx, store, mid, start+10)
+	if err != nil {
+		t.Fatalf("DeleteDetection returned error: %v", err)
+	}
+	pending, err = GetPendingDetections(ctx, store, 10)
+	if err != nil {
+		t.Fatalf("GetPendingDetections returned error: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("GetPendingDetections returned %v, expected expired and deleted detections to be removed", pending)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"sort"
 	"strconv"
 	"strings"
@@ -82,8 +83,12 @@ type Device struct {
 	Status        int64             // Status code.
 	Latitude      float64           // Device latitude.
 	Longitude     float64           // Device longtitude.
+	Geohash       string            // Geohash of the device's location, if set, which is derived from its latitude and longitude.
 	Enabled       bool              // True if enabled, false otherwise.
 	Updated       time.Time         // Date/time last updated.
+	NewDkey       int64             // New device key while a key rotation is in progress, else zero.
+	RotationEnds  time.Time         // End of the key rotation grace window.
+	LastDkey      int64             // Device key last used by the device, if known.
 	other         map[string]string // Other, non-persistent data.
 }
 
@@ -98,16 +103,26 @@ const (
 	DevTypeUnknown    = ""
 )
 
-// Encode serializes a Device into tab-separated values.
+// Encode serializes a Device into tab-separated values. Key rotation
+// data is only encoded when present, so that devices without it remain
+// compatible with the original encoding.
 func (dev *Device) Encode() []byte {
-	return []byte(fmt.Sprintf("%d\t%d\t%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%f\t%f\t%t\t%d",
-		dev.Skey, dev.Dkey, dev.Mac, dev.Name, dev.Inputs, dev.Outputs, dev.Wifi, dev.MonitorPeriod, dev.ActPeriod, dev.Status, dev.Type, dev.Version, dev.Protocol, dev.Latitude, dev.Longitude, dev.Enabled, dev.Updated.Unix()))
+	enc := fmt.Sprintf("%d\t%d\t%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%f\t%f\t%t\t%d",
+		dev.Skey, dev.Dkey, dev.Mac, dev.Name, dev.Inputs, dev.Outputs, dev.Wifi, dev.MonitorPeriod, dev.ActPeriod, dev.Status, dev.Type, dev.Version, dev.Protocol, dev.Latitude, dev.Longitude, dev.Enabled, dev.Updated.Unix())
+	if dev.NewDkey != 0 || dev.LastDkey != 0 {
+		var ends int64
+		if !dev.RotationEnds.IsZero() {
+			ends = dev.RotationEnds.Unix()
+		}
+		enc += fmt.Sprintf("\t%d\t%d\t%d", dev.NewDkey, ends, dev.LastDkey)
+	}
+	return []byte(enc)
 }
 
 // Decode deserializes a Device from tab-separated values.
 func (dev *Device) Decode(b []byte) error {
 	p := strings.Split(string(b), "\t")
-	if len(p) != 17 {
+	if len(p) != 17 && len(p) != 20 {
 		return datastore.ErrDecoding
 	}
 	var err error
@@ -150,6 +165,7 @@ func (dev *Device) Decode(b []byte) error {
 	if err != nil {
 		return datastore.ErrDecoding
 	}
+	dev.Geohash = locationGeohash(dev.Latitude, dev.Longitude)
 	dev.Enabled, err = strconv.ParseBool(p[15])
 	if err != nil {
 		return datastore.ErrDecoding
@@ -159,6 +175,23 @@ func (dev *Device) Decode(b []byte) error {
 		return datastore.ErrDecoding
 	}
 	dev.Updated = time.Unix(ts, 0)
+	if len(p) == 20 {
+		dev.NewDkey, err = strconv.ParseInt(p[17], 10, 64)
+		if err != nil {
+			return datastore.ErrDecoding
+		}
+		ts, err = strconv.ParseInt(p[18], 10, 64)
+		if err != nil {
+			return datastore.ErrDecoding
+		}
+		if ts != 0 {
+			dev.RotationEnds = time.Unix(ts, 0)
+		}
+		dev.LastDkey, err = strconv.ParseInt(p[19], 10, 64)
+		if err != nil {
+			return datastore.ErrDecoding
+		}
+	}
 	return nil
 }
 
@@ -175,14 +208,15 @@ func (dev *Device) Copy(dst datastore.Entity) (datastore.Entity, error) {
 		}
 	}
 	*d = *dev
+	d.other = maps.Clone(dev.other)
 	return d, nil
 }
 
-var devCache datastore.Cache = datastore.NewEntityCache()
+var devCache datastore.Cache = NewTTLCache(deviceCacheTTL)
 
 // GetCache returns the device cache.
 func (dev *Device) GetCache() datastore.Cache {
-	return nil
+	return devCache
 }
 
 // Return the MAC address as a formated string, i.e., a wrapper for MacDecode(dev.Mac).
@@ -245,17 +279,61 @@ func (dev *Device) OutputList() []string {
 // PutDevice creates or updates a device.
 func PutDevice(ctx context.Context, store datastore.Store, dev *Device) error {
 	dev.Updated = time.Now()
+	dev.Geohash = locationGeohash(dev.Latitude, dev.Longitude)
 	key := store.IDKey(typeDevice, dev.Mac)
 	_, err := store.Put(ctx, key, dev)
+	invalidate(devCache, key)
+	return err
+}
+
+// CreateDevice creates a device, or returns datastore.ErrEntityExists
+// if a device with the same MAC address exists.
+func CreateDevice(ctx context.Context, store datastore.Store, dev *Device) error {
+	dev.Updated = time.Now()
+	dev.Geohash = locationGeohash(dev.Latitude, dev.Longitude)
+	key := store.IDKey(typeDevice, dev.Mac)
+	err := store.Create(ctx, key, dev)
+	invalidate(devCache, key)
 	return err
 }
 
+// UpdateDevice atomically updates a device with fn, returning the
+// updated device. The device is not updated if fn returns an error.
+func UpdateDevice(ctx context.Context, store datastore.Store, mac int64, fn func(*Device) error) (*Device, error) {
+	key := store.IDKey(typeDevice, mac)
+	var dev *Device
+	var fnErr error
+	err := store.Update(ctx, key, func(e datastore.Entity) {
+		d, ok := e.(*Device)
+		if !ok {
+			return
+		}
+		// Apply fn to a copy, so that the stored device is unchanged on error.
+		tmp := *d
+		fnErr = fn(&tmp)
+		if fnErr != nil {
+			return
+		}
+		tmp.Updated = time.Now()
+		*d = tmp
+		dev = &tmp
+	}, new(Device))
+	invalidate(devCache, key)
+	if err != nil {
+		return nil, err
+	}
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return dev, nil
+}
+
 // GetDevice returns a Device by its integer ID (which is the encoded
 // MAC address).
 func GetDevice(ctx context.Context, store datastore.Store, mac int64) (*Device, error) {
 	key := store.IDKey(typeDevice, mac)
 	dev := new(Device)
-	err := store.Get(ctx, key, dev)
+	err := getCached(ctx, store, key, dev)
 	if err != nil {
 		return nil, err
 	}
@@ -274,7 +352,7 @@ func GetDevicesBySite(ctx context.Context, store datastore.Store, skey int64) ([
 	q.Filter("Skey =", skey)
 	q.Order("Name")
 	var devs []Device
-	_, err := store.GetAll(ctx, q, &devs)
+	_, err := getAll(ctx, store, q, &devs, idxDeviceSite)
 	return devs, err
 }
 
@@ -311,7 +389,9 @@ func getDevicesBySiteFromFileStore(ctx context.Context, store datastore.Store, s
 // DeleteDevice deletes a device.
 func DeleteDevice(ctx context.Context, store datastore.Store, mac int64) error {
 	key := store.IDKey(typeDevice, mac)
-	return store.DeleteMulti(ctx, []*datastore.Key{key})
+	err := store.DeleteMulti(ctx, []*datastore.Key{key})
+	invalidate(devCache, key)
+	return err
 }
 
 // MacEncode encodes a MAC address string, optionally colon-separated,
@@ -373,6 +453,7 @@ func MacDecode(enc int64) string {
 
 // CheckDevice returns a device if the supplied MAC address is valid,
 // the device key (supplied as a string) is correct and the device is enabled, else an error.
+// During a key rotation, either the old or new device key is correct. See ValidKey.
 func CheckDevice(ctx context.Context, store datastore.Store, mac string, dk string) (*Device, error) {
 	if !IsMacAddress(mac) {
 		return nil, ErrInvalidMACAddress
@@ -389,7 +470,7 @@ func CheckDevice(ctx context.Context, store datastore.Store, mac string, dk stri
 	if err != nil {
 		return dev, ErrMalformedDeviceKey
 	}
-	if dev.Dkey != dkey {
+	if !dev.ValidKey(dkey, time.Now()) {
 		return dev, ErrInvalidDeviceKey
 	}
 	if !dev.Enabled {
@@ -0,0 +1,136 @@
+/*
+DESCRIPTION
+  Device key rotation, which replaces a device's key with a new one
+  while accepting both keys during a grace window, so that devices can
+  adopt their new key without losing contact with the cloud.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// DefaultKeyRotationGrace is the default grace window of a key
+// rotation, during which both the old and new device keys are valid.
+const DefaultKeyRotationGrace = 7 * 24 * time.Hour
+
+var (
+	ErrNoKeyRotation     = errors.New("no key rotation in progress")
+	ErrDeviceKeyNotInUse = errors.New("device has not used its new key")
+)
+
+// NewDeviceKey returns a random 8-digit device key.
+func NewDeviceKey() (int64, error) {
+	r, err := rand.Int(rand.Reader, big.NewInt(9e7))
+	if err != nil {
+		return 0, fmt.Errorf("could not generate device key: %w", err)
+	}
+	return r.Int64() + 1e7, nil
+}
+
+// Rotating returns true if a key rotation is in progress.
+func (dev *Device) Rotating() bool {
+	return dev.NewDkey != 0
+}
+
+// ActiveKey returns the key the device should be using, which is the
+// new key while a key rotation is in progress.
+func (dev *Device) ActiveKey() int64 {
+	if dev.Rotating() {
+		return dev.NewDkey
+	}
+	return dev.Dkey
+}
+
+// ValidKey returns true if dkey is a valid key for the device at time
+// now. While a key rotation is in progress the new key is valid, and
+// the old key remains valid until the end of the grace window.
+func (dev *Device) ValidKey(dkey int64, now time.Time) bool {
+	if !dev.Rotating() {
+		return dkey == dev.Dkey
+	}
+	return dkey == dev.NewDkey || (dkey == dev.Dkey && now.Before(dev.RotationEnds))
+}
+
+// StartKeyRotation generates a new key for a device, which is valid
+// alongside its current key for the given grace window. The device is
+// set to update, so that it requests its configuration and is informed
+// of its new key, which is only revealed to a device that presents a
+// valid key. Restarting a rotation replaces the new key.
+func StartKeyRotation(ctx context.Context, store datastore.Store, mac int64, grace time.Duration) (*Device, error) {
+	dkey, err := NewDeviceKey()
+	if err != nil {
+		return nil, err
+	}
+	return UpdateDevice(ctx, store, mac, func(dev *Device) error {
+		dev.NewDkey = dkey
+		dev.RotationEnds = time.Now().Add(grace)
+		dev.Status = DeviceStatusUpdate
+		return nil
+	})
+}
+
+// FinalizeKeyRotation completes a device's key rotation, replacing its
+// key with the new key, after which the old key is no longer valid.
+// Unless force is true, ErrDeviceKeyNotInUse is returned if the device
+// has not yet used its new key.
+func FinalizeKeyRotation(ctx context.Context, store datastore.Store, mac int64, force bool) (*Device, error) {
+	return UpdateDevice(ctx, store, mac, func(dev *Device) error {
+		if !dev.Rotating() {
+			return ErrNoKeyRotation
+		}
+		if !force && dev.LastDkey != dev.NewDkey {
+			return ErrDeviceKeyNotInUse
+		}
+		dev.Dkey = dev.NewDkey
+		dev.NewDkey = 0
+		dev.RotationEnds = time.Time{}
+		return nil
+	})
+}
+
+// CancelKeyRotation cancels a device's key rotation, leaving its key
+// unchanged.
+func CancelKeyRotation(ctx context.Context, store datastore.Store, mac int64) (*Device, error) {
+	return UpdateDevice(ctx, store, mac, func(dev *Device) error {
+		if !dev.Rotating() {
+			return ErrNoKeyRotation
+		}
+		dev.NewDkey = 0
+		dev.RotationEnds = time.Time{}
+		return nil
+	})
+}
+
+// RecordDeviceKey records the key last used by a device.
+func RecordDeviceKey(ctx context.Context, store datastore.Store, mac, dkey int64) error {
+	_, err := UpdateDevice(ctx, store, mac, func(dev *Device) error {
+		dev.LastDkey = dkey
+		return nil
+	})
+	return err
+}
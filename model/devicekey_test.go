@@ -0,0 +1,144 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestDeviceKeyRotation tests starting, finalizing and cancelling device key rotations.
+func TestDeviceKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mac, oldKey = 1, 12345678
+	err = PutDevice(ctx, store, &Device{Skey: 1, Mac: mac, Dkey: oldKey, Name: "test", Enabled: true})
+	if err != nil {
+		t.Fatalf("PutDevice returned error: %v", err)
+	}
+
+	_, err = FinalizeKeyRotation(ctx, store, mac, false)
+	if !errors.Is(err, ErrNoKeyRotation) {
+		t.Errorf("FinalizeKeyRotation returned %v, expected %v", err, ErrNoKeyRotation)
+	}
+
+	dev, err := StartKeyRotation(ctx, store, mac, time.Hour)
+	if err != nil {
+		t.Fatalf("StartKeyRotation returned error: %v", err)
+	}
+	newKey := dev.NewDkey
+	if newKey < 1e7 || newKey >= 1e8 || newKey == oldKey {
+		t.Fatalf("got new key %d, expected a different 8-digit key", newKey)
+	}
+	if dev.Status != DeviceStatusUpdate {
+		t.Errorf("got status %d, expected %d", dev.Status, DeviceStatusUpdate)
+	}
+
+	// Both keys are valid during the grace window, and only the new key after it.
+	now := time.Now()
+	dev, err = GetDevice(ctx, store, mac)
+	if err != nil {
+		t.Fatalf("GetDevice returned error: %v", err)
+	}
+	tests := []struct {
+		dkey int64
+		now  time.Time
+		want bool
+	}{
+		{dkey: oldKey, now: now, want: true},
+		{dkey: newKey, now: now, want: true},
+		{dkey: oldKey, now: now.Add(2 * time.Hour), want: false},
+		{dkey: newKey, now: now.Add(2 * time.Hour), want: true},
+		{dkey: 1, now: now, want: false},
+	}
+	for i, test := range tests {
+		if got := dev.ValidKey(test.dkey, test.now); got != test.want {
+			t.Errorf("test %d: ValidKey(%d) returned %t, expected %t", i, test.dkey, got, test.want)
+		}
+	}
+	if dev.ActiveKey() != newKey {
+		t.Errorf("got active key %d, expected %d", dev.ActiveKey(), newKey)
+	}
+
+	// Finalizing requires the device to have used its new key.
+	_, err = FinalizeKeyRotation(ctx, store, mac, false)
+	if !errors.Is(err, ErrDeviceKeyNotInUse) {
+		t.Errorf("FinalizeKeyRotation returned %v, expected %v", err, ErrDeviceKeyNotInUse)
+	}
+	err = RecordDeviceKey(ctx, store, mac, newKey)
+	if err != nil {
+		t.Fatalf("RecordDeviceKey returned error: %v", err)
+	}
+	_, err = FinalizeKeyRotation(ctx, store, mac, false)
+	if err != nil {
+		t.Fatalf("FinalizeKeyRotation returned error: %v", err)
+	}
+	dev, err = GetDevice(ctx, store, mac)
+	if err != nil {
+		t.Fatalf("GetDevice returned error: %v", err)
+	}
+	if dev.Dkey != newKey || dev.Rotating() || dev.LastDkey != newKey {
+		t.Errorf("got key %d, new key %d, last key %d, expected %d, 0, %d", dev.Dkey, dev.NewDkey, dev.LastDkey, newKey, newKey)
+	}
+	if dev.ValidKey(oldKey, now) {
+		t.Errorf("old key valid after rotation finalized")
+	}
+
+	// Cancelling a rotation leaves the key unchanged.
+	_, err = StartKeyRotation(ctx, store, mac, time.Hour)
+	if err != nil {
+		t.Fatalf("StartKeyRotation returned error: %v", err)
+	}
+	dev, err = CancelKeyRotation(ctx, store, mac)
+	if err != nil {
+		t.Fatalf("CancelKeyRotation returned error: %v", err)
+	}
+	if dev.Dkey != newKey || dev.Rotating() {
+		t.Errorf("got key %d, new key %d, expected %d, 0", dev.Dkey, dev.NewDkey, newKey)
+	}
+}
+
+// TestDeviceEncoding tests that devices with and without key rotation data round trip.
+func TestDeviceEncoding(t *testing.T) {
+	updated := time.Unix(1700000000, 0)
+	tests := []Device{
+		{Skey: 1, Dkey: 2, Mac: 3, Name: "test", Enabled: true, Updated: updated},
+		{Skey: 1, Dkey: 2, Mac: 3, Name: "test", Updated: updated, NewDkey: 4, RotationEnds: updated.Add(time.Hour), LastDkey: 2},
+		{Skey: 1, Dkey: 2, Mac: 3, Name: "test", Updated: updated, LastDkey: 2},
+	}
+	for i, want := range tests {
+		var got Device
+		err := got.Decode(want.Encode())
+		if err != nil {
+			t.Fatalf("test %d: Decode returned error: %v", i, err)
+		}
+		if got.Dkey != want.Dkey || got.NewDkey != want.NewDkey || got.LastDkey != want.LastDkey || !got.RotationEnds.Equal(want.RotationEnds) || !got.Updated.Equal(want.Updated) {
+			t.Errorf("test %d: got %+v, expected %+v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+DESCRIPTION
+  FeedEngagement datastore type and functions, which implement
+  AusOcean TV viewer analytics.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeFeedEngagement = "FeedEngagement" // FeedEngagement datastore type.
+
+// FeedEngagementDateFormat is the format of FeedEngagement dates.
+const FeedEngagementDateFormat = "2006-01-02"
+
+// FeedEngagement represents viewer engagement with a feed on a given
+// day (UTC), as reported by playback events from the web player. The
+// key is the feed ID concatenated with the date.
+type FeedEngagement struct {
+	Feed           int64     // Feed ID.
+	Date           string    // UTC date in FeedEngagementDateFormat.
+	Plays          int64     // Number of times playback started.
+	Pauses         int64     // Number of times playback paused.
+	QualityChanges int64     // Number of times playback quality changed.
+	Watched        int64     // Seconds watched.
+	GuestWatched   int64     // Seconds watched by guests, which are included in Watched.
+	Updated        time.Time // Date/time last updated.
+}
+
+// Copy copies a feed engagement to dst, or returns a copy of the feed engagement when dst is nil.
+func (e *FeedEngagement) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var e2 *FeedEngagement
+	if dst == nil {
+		e2 = new(FeedEngagement)
+	} else {
+		var ok bool
+		e2, ok = dst.(*FeedEngagement)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*e2 = *e
+	return e2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (e *FeedEngagement) GetCache() datastore.Cache {
+	return nil
+}
+
+// Add adds the counts of another feed engagement.
+func (e *FeedEngagement) Add(o *FeedEngagement) {
+	e.Plays += o.Plays
+	e.Pauses += o.Pauses
+	e.QualityChanges += o.QualityChanges
+	e.Watched += o.Watched
+	e.GuestWatched += o.GuestWatched
+}
+
+// AddFeedEngagement adds the counts of e to the engagement with its
+// feed on its date, creating the engagement if necessary.
+func AddFeedEngagement(ctx context.Context, store datastore.Store, e *FeedEngagement) error {
+	key := store.NameKey(typeFeedEngagement, strconv.FormatInt(e.Feed, 10)+"."+e.Date)
+	add := func(ety datastore.Entity) {
+		e2, ok := ety.(*FeedEngagement)
+		if ok {
+			e2.Add(e)
+			e2.Updated = time.Now()
+		}
+	}
+	err := store.Update(ctx, key, add, &FeedEngagement{})
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return err
+	}
+	e2 := &FeedEngagement{Feed: e.Feed, Date: e.Date, Updated: time.Now()}
+	e2.Add(e)
+	err = store.Create(ctx, key, e2)
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		return err
+	}
+	// Another instance created it first.
+	return store.Update(ctx, key, add, &FeedEngagement{})
+}
+
+// GetFeedEngagement returns the engagement with all feeds from date
+// from to date to inclusive, ordered by date then feed. Dates are in
+// FeedEngagementDateFormat.
+func GetFeedEngagement(ctx context.Context, store datastore.Store, from, to string) ([]FeedEngagement, error) {
+	q := store.NewQuery(typeFeedEngagement, false, "Feed", "Date")
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Date >=", from)
+		q.Filter("Date <=", to)
+	}
+	var all []FeedEngagement
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var engagement []FeedEngagement
+	for _, e := range all {
+		if e.Date >= from && e.Date <= to {
+			engagement = append(engagement, e)
+		}
+	}
+	sort.Slice(engagement, func(i, j int) bool {
+		if engagement[i].Date != engagement[j].Date {
+			return engagement[i].Date < engagement[j].Date
+		}
+		return engagement[i].Feed < engagement[j].Feed
+	})
+	return engagement, nil
+}
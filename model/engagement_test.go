@@ -0,0 +1,70 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestFeedEngagement tests adding and getting feed engagement.
+func TestFeedEngagement(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	adds := []FeedEngagement{
+		{Feed: 2, Date: "2026-03-01", Plays: 1, Watched: 30},
+		{Feed: 2, Date: "2026-03-01", Plays: 1, Pauses: 1, Watched: 60, GuestWatched: 60},
+		{Feed: 1, Date: "2026-03-01", QualityChanges: 2, Watched: 10},
+		{Feed: 2, Date: "2026-03-02", Plays: 3},
+		{Feed: 1, Date: "2026-02-28", Plays: 5}, // Before the period.
+	}
+	for i := range adds {
+		err = AddFeedEngagement(ctx, store, &adds[i])
+		if err != nil {
+			t.Fatalf("AddFeedEngagement returned error: %v", err)
+		}
+	}
+
+	got, err := GetFeedEngagement(ctx, store, "2026-03-01", "2026-03-02")
+	if err != nil {
+		t.Fatalf("GetFeedEngagement returned error: %v", err)
+	}
+	want := []FeedEngagement{
+		{Feed: 1, Date: "2026-03-01", QualityChanges: 2, Watched: 10},
+		{Feed: 2, Date: "2026-03-01", Plays: 2, Pauses: 1, Watched: 90, GuestWatched: 60},
+		{Feed: 2, Date: "2026-03-02", Plays: 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetFeedEngagement returned %d engagements, expected %d", len(got), len(want))
+	}
+	for i := range want {
+		got[i].Updated = time.Time{}
+		if got[i] != want[i] {
+			t.Errorf("engagement %d: got %+v, expected %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -41,6 +41,7 @@ func RegisterEntities() {
 	datastore.RegisterEntity(typeScalar, func() datastore.Entity { return new(Scalar) })
 	datastore.RegisterEntity(typeSensor, func() datastore.Entity { return new(Sensor) })
 	datastore.RegisterEntity(typeSensorV2, func() datastore.Entity { return new(SensorV2) })
+	datastore.RegisterEntity(typeSensorDismissal, func() datastore.Entity { return new(SensorDismissal) })
 	datastore.RegisterEntity(typeSite, func() datastore.Entity { return new(Site) })
 	datastore.RegisterEntity(typeText, func() datastore.Entity { return new(Text) })
 	datastore.RegisterEntity(typeUser, func() datastore.Entity { return new(User) })
@@ -48,4 +49,48 @@ func RegisterEntities() {
 	datastore.RegisterEntity(typeFeed, func() datastore.Entity { return new(Feed) })
 	datastore.RegisterEntity(typeSubscriber, func() datastore.Entity { return new(Subscriber) })
 	datastore.RegisterEntity(typeSubscription, func() datastore.Entity { return new(Subscription) })
+	datastore.RegisterEntity(typeMqttTopic, func() datastore.Entity { return new(MqttTopic) })
+	datastore.RegisterEntity(typeTokenBucket, func() datastore.Entity { return new(TokenBucket) })
+	datastore.RegisterEntity(typeDeviceCommand, func() datastore.Entity { return new(DeviceCommand) })
+	datastore.RegisterEntity(typeCronRun, func() datastore.Entity { return new(CronRun) })
+	datastore.RegisterEntity(typeCronSync, func() datastore.Entity { return new(CronSync) })
+	datastore.RegisterEntity(typeRelease, func() datastore.Entity { return new(Release) })
+	datastore.RegisterEntity(typeDeviceRelease, func() datastore.Entity { return new(DeviceRelease) })
+	datastore.RegisterEntity(typeDeviceArchive, func() datastore.Entity { return new(DeviceArchive) })
+	datastore.RegisterEntity(typeProvisioningToken, func() datastore.Entity { return new(ProvisioningToken) })
+	datastore.RegisterEntity(typeEscalationPolicy, func() datastore.Entity { return new(EscalationPolicy) })
+	datastore.RegisterEntity(typeIncident, func() datastore.Entity { return new(Incident) })
+	datastore.RegisterEntity(typeNotificationTemplate, func() datastore.Entity { return new(NotificationTemplate) })
+	datastore.RegisterEntity(typeNotificationRoute, func() datastore.Entity { return new(NotificationRoute) })
+	datastore.RegisterEntity(typePendingNotification, func() datastore.Entity { return new(PendingNotification) })
+	datastore.RegisterEntity(typeAlert, func() datastore.Entity { return new(Alert) })
+	datastore.RegisterEntity(typeNotification, func() datastore.Entity { return new(Notification) })
+	datastore.RegisterEntity(typeRecipientPreference, func() datastore.Entity { return new(RecipientPreference) })
+	datastore.RegisterEntity(typeLoginEvent, func() datastore.Entity { return new(LoginEvent) })
+	datastore.RegisterEntity(typePromoCode, func() datastore.Entity { return new(PromoCode) })
+	datastore.RegisterEntity(typeEntitlement, func() datastore.Entity { return new(Entitlement) })
+	datastore.RegisterEntity(typeGuestSession, func() datastore.Entity { return new(GuestSession) })
+	datastore.RegisterEntity(typeFunnelEvent, func() datastore.Entity { return new(FunnelEvent) })
+	datastore.RegisterEntity(typeFeedEngagement, func() datastore.Entity { return new(FeedEngagement) })
+	datastore.RegisterEntity(typeMagicLink, func() datastore.Entity { return new(MagicLink) })
+	datastore.RegisterEntity(typeAnnouncement, func() datastore.Entity { return new(Announcement) })
+	datastore.RegisterEntity(typeInvite, func() datastore.Entity { return new(Invite) })
+	datastore.RegisterEntity(typeMediaShare, func() datastore.Entity { return new(MediaShare) })
+	datastore.RegisterEntity(typeSpectrogram, func() datastore.Entity { return new(Spectrogram) })
+	datastore.RegisterEntity(typeDetection, func() datastore.Entity { return new(Detection) })
+	datastore.RegisterEntity(typeBroadcastConfig, func() datastore.Entity { return new(BroadcastConfig) })
+	datastore.RegisterEntity(typeBroadcastArchive, func() datastore.Entity { return new(BroadcastArchive) })
+	datastore.RegisterEntity(typeViewerSample, func() datastore.Entity { return new(ViewerSample) })
+	datastore.RegisterEntity(typeVariableSchema, func() datastore.Entity { return new(VariableSchema) })
+	datastore.RegisterEntity(typeTrackPoint, func() datastore.Entity { return new(TrackPoint) })
+	datastore.RegisterEntity(typeAlertRule, func() datastore.Entity { return new(AlertRule) })
+	datastore.RegisterEntity(typeForwarding, func() datastore.Entity { return new(Forwarding) })
+	datastore.RegisterEntity(typeAnnotation, func() datastore.Entity { return new(Annotation) })
+	datastore.RegisterEntity(typeDataUsage, func() datastore.Entity { return new(DataUsage) })
+	datastore.RegisterEntity(typeMtsUpload, func() datastore.Entity { return new(MtsUpload) })
+	datastore.RegisterEntity(typeMtsValidation, func() datastore.Entity { return new(MtsValidation) })
+	datastore.RegisterEntity(typeOrganization, func() datastore.Entity { return new(Organization) })
+	datastore.RegisterEntity(typeOrgMember, func() datastore.Entity { return new(OrgMember) })
+	datastore.RegisterEntity(typeTextRetention, func() datastore.Entity { return new(TextRetention) })
+	datastore.RegisterEntity(typeQueuedEdit, func() datastore.Entity { return new(QueuedEdit) })
 }
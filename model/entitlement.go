@@ -0,0 +1,155 @@
+/*
+DESCRIPTION
+  Entitlement datastore type and functions, which determine the feeds
+  that AusOcean TV subscribers may access.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeEntitlement = "Entitlement" // Entitlement datastore type.
+
+// ErrNotEntitled is returned when a subscriber is not entitled to a feed.
+var ErrNotEntitled = errors.New("not entitled to feed")
+
+// Entitlement maps a subscription class to the feeds accessible by
+// subscriptions of that class that are not for a specific feed, i.e.,
+// those with NoFeedID. When a class has no entitlement, all feeds are
+// accessible. The key is the class.
+type Entitlement struct {
+	Class   string    // Subscription class, e.g., "Month".
+	Feeds   []int64   // IDs of accessible feeds, or nil for all feeds.
+	Updated time.Time // Date/time last updated.
+}
+
+// Copy copies an entitlement to dst, or returns a copy of the entitlement when dst is nil.
+func (e *Entitlement) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var e2 *Entitlement
+	if dst == nil {
+		e2 = new(Entitlement)
+	} else {
+		var ok bool
+		e2, ok = dst.(*Entitlement)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*e2 = *e
+	e2.Feeds = slices.Clone(e.Feeds)
+	return e2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (e *Entitlement) GetCache() datastore.Cache {
+	return nil
+}
+
+// Includes returns true if the entitlement includes the given feed.
+func (e *Entitlement) Includes(fid int64) bool {
+	return e.Feeds == nil || slices.Contains(e.Feeds, fid)
+}
+
+// PutEntitlement creates or updates the entitlement for a class.
+func PutEntitlement(ctx context.Context, store datastore.Store, e *Entitlement) error {
+	e.Updated = time.Now()
+	_, err := store.Put(ctx, store.NameKey(typeEntitlement, e.Class), e)
+	return err
+}
+
+// GetEntitlement gets the entitlement for a class. If there is none,
+// an entitlement to all feeds is returned.
+func GetEntitlement(ctx context.Context, store datastore.Store, class string) (*Entitlement, error) {
+	e := new(Entitlement)
+	err := store.Get(ctx, store.NameKey(typeEntitlement, class), e)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return &Entitlement{Class: class}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// DeleteEntitlement deletes the entitlement for a class, making all
+// feeds accessible.
+func DeleteEntitlement(ctx context.Context, store datastore.Store, class string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.NameKey(typeEntitlement, class)})
+}
+
+// Active returns true if the subscription is current and has not
+// been canceled or left unpaid.
+func (s *Subscription) Active() bool {
+	switch s.Status {
+	case "canceled", "unpaid", "incomplete", "incomplete_expired":
+		return false
+	}
+	return s.Finish.After(time.Now())
+}
+
+// Entitled returns the active subscription that entitles a subscriber
+// to a feed, or ErrNotEntitled if there is none. A subscription
+// entitles a subscriber to its own feed and any feeds bundled with it,
+// or, for subscriptions with NoFeedID, to the feeds of the
+// entitlement for its class.
+func Entitled(ctx context.Context, store datastore.Store, sid, fid int64) (*Subscription, error) {
+	subs, err := GetSubscriptions(ctx, store, sid)
+	if err != nil {
+		return nil, fmt.Errorf("could not get subscriptions: %w", err)
+	}
+	for i := range subs {
+		s := &subs[i]
+		if s.SubscriberID != sid || !s.Active() {
+			continue
+		}
+		switch s.FeedID {
+		case fid:
+			return s, nil
+		case NoFeedID:
+			e, err := GetEntitlement(ctx, store, s.Class)
+			if err != nil {
+				return nil, fmt.Errorf("could not get entitlement: %w", err)
+			}
+			if e.Includes(fid) {
+				return s, nil
+			}
+		default:
+			f, err := GetFeed(ctx, store, s.FeedID)
+			if errors.Is(err, datastore.ErrNoSuchEntity) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not get feed: %w", err)
+			}
+			if slices.Contains(f.Bundle, strconv.FormatInt(fid, 10)) {
+				return s, nil
+			}
+		}
+	}
+	return nil, ErrNotEntitled
+}
@@ -0,0 +1,95 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestEntitled tests feed entitlements for the various kinds of subscription.
+func TestEntitled(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const (
+		dayFan   = 1 // Day subscriber to all feeds.
+		monthFan = 2 // Month subscriber, restricted to feeds 10 and 11.
+		feedFan  = 3 // Subscriber to feed 20, which bundles feed 21.
+		lapsed   = 4 // Subscriber whose subscription was canceled.
+	)
+	err = PutFeed(ctx, store, &Feed{ID: 20, Name: "Rapid Bay", Bundle: []string{"21"}})
+	if err != nil {
+		t.Fatalf("PutFeed returned error: %v", err)
+	}
+	err = PutEntitlement(ctx, store, &Entitlement{Class: SubscriptionMonth, Feeds: []int64{10, 11}})
+	if err != nil {
+		t.Fatalf("PutEntitlement returned error: %v", err)
+	}
+	for _, s := range []struct {
+		sid, fid int64
+		class    string
+	}{
+		{dayFan, NoFeedID, SubscriptionDay},
+		{monthFan, NoFeedID, SubscriptionMonth},
+		{feedFan, 20, SubscriptionYear},
+		{lapsed, NoFeedID, SubscriptionYear},
+	} {
+		err = CreateSubscription(ctx, store, s.sid, s.fid, s.class, "", true)
+		if err != nil {
+			t.Fatalf("CreateSubscription returned error: %v", err)
+		}
+	}
+	err = UpdateSubscription(ctx, store, &Subscription{SubscriberID: lapsed, FeedID: NoFeedID, Class: SubscriptionYear, Finish: time.Now().Add(time.Hour), Status: "canceled"})
+	if err != nil {
+		t.Fatalf("UpdateSubscription returned error: %v", err)
+	}
+
+	tests := []struct {
+		sid, fid int64
+		want     bool
+	}{
+		{dayFan, 10, true},
+		{dayFan, 99, true},
+		{monthFan, 11, true},
+		{monthFan, 12, false},
+		{feedFan, 20, true},
+		{feedFan, 21, true},
+		{feedFan, 22, false},
+		{lapsed, 10, false},
+		{99, 10, false},
+	}
+	for _, test := range tests {
+		_, err := Entitled(ctx, store, test.sid, test.fid)
+		if test.want && err != nil {
+			t.Errorf("Entitled(%d, %d) returned error: %v", test.sid, test.fid, err)
+		}
+		if !test.want && !errors.Is(err, ErrNotEntitled) {
+			t.Errorf("Entitled(%d, %d) returned %v, expected %v", test.sid, test.fid, err, ErrNotEntitled)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+/*
+DESCRIPTION
+  EscalationPolicy and Incident datastore types and functions, which
+  implement escalation when devices go dark, i.e., stop reporting.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	typeEscalationPolicy = "EscalationPolicy" // EscalationPolicy datastore type.
+	typeIncident         = "Incident"         // Incident datastore type.
+)
+
+// Escalation levels, in increasing order of severity.
+const (
+	EscalateNone = iota
+	EscalateOps
+	EscalateOwner
+	EscalateIncident
+)
+
+// EscalationPolicy defines how a site escalates when one of its
+// devices goes dark, in terms of the number of consecutive missed
+// reports, i.e., monitor periods without a report. Ops is notified
+// after OpsAfter missed reports, the site owner after OwnerAfter and
+// an incident is opened after IncidentAfter, at which time the
+// PowerCycle cron, if any, is run. A zero threshold disables that
+// level. The key is the site key.
+type EscalationPolicy struct {
+	Skey          int64     // Site key.
+	OpsAfter      int64     // Missed reports before notifying ops.
+	OwnerAfter    int64     // Missed reports before notifying the site owner.
+	IncidentAfter int64     // Missed reports before opening an incident.
+	PowerCycle    string    // ID of cron to run when opening an incident (optional).
+	Updated       time.Time // Date/time last updated.
+}
+
+// Copy copies a policy to dst, or returns a copy of the policy when dst is nil.
+func (p *EscalationPolicy) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var p2 *EscalationPolicy
+	if dst == nil {
+		p2 = new(EscalationPolicy)
+	} else {
+		var ok bool
+		p2, ok = dst.(*EscalationPolicy)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*p2 = *p
+	return p2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (p *EscalationPolicy) GetCache() datastore.Cache {
+	return nil
+}
+
+// Level returns the escalation level for the given number of missed reports.
+func (p *EscalationPolicy) Level(missed int64) int {
+	reached := func(n int64) bool { return n > 0 && missed >= n }
+	switch {
+	case reached(p.IncidentAfter):
+		return EscalateIncident
+	case reached(p.OwnerAfter):
+		return EscalateOwner
+	case reached(p.OpsAfter):
+		return EscalateOps
+	default:
+		return EscalateNone
+	}
+}
+
+// PutEscalationPolicy creates or updates a site's escalation policy.
+func PutEscalationPolicy(ctx context.Context, store datastore.Store, p *EscalationPolicy) error {
+	p.Updated = time.Now()
+	_, err := store.Put(ctx, store.IDKey(typeEscalationPolicy, p.Skey), p)
+	return err
+}
+
+// GetEscalationPolicy gets a site's escalation policy.
+func GetEscalationPolicy(ctx context.Context, store datastore.Store, skey int64) (*EscalationPolicy, error) {
+	p := new(EscalationPolicy)
+	err := store.Get(ctx, store.IDKey(typeEscalationPolicy, skey), p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeleteEscalationPolicy deletes a site's escalation policy.
+func DeleteEscalationPolicy(ctx context.Context, store datastore.Store, skey int64) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.IDKey(typeEscalationPolicy, skey)})
+}
+
+// MissedReports returns the number of consecutive reports a device
+// has missed at time t, given the time of its last report.
+func MissedReports(dev *Device, last, t time.Time) int64 {
+	if dev.MonitorPeriod <= 0 || !t.After(last) {
+		return 0
+	}
+	return int64(t.Sub(last) / (time.Duration(dev.MonitorPeriod) * time.Second))
+}
+
+// Incident represents a device that has gone dark for long enough to
+// require intervention. An incident remains open until the device
+// reports again or the incident is resolved by an operator.
+type Incident struct {
+	ID       int64     // Incident ID.
+	Skey     int64     // Site key.
+	Mac      int64     // MAC address of device.
+	Missed   int64     // Missed reports when opened.
+	Opened   time.Time // Date/time opened.
+	Resolved time.Time // Date/time resolved, or zero if open.
+	Notes    string    `datastore:",noindex"` // Notes, e.g., power cycle outcome.
+}
+
+// Copy copies an incident to dst, or returns a copy of the incident when dst is nil.
+func (i *Incident) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var i2 *Incident
+	if dst == nil {
+		i2 = new(Incident)
+	} else {
+		var ok bool
+		i2, ok = dst.(*Incident)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*i2 = *i
+	return i2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (i *Incident) GetCache() datastore.Cache {
+	return nil
+}
+
+// Open returns true if the incident is open.
+func (i *Incident) Open() bool {
+	return i.Resolved.IsZero()
+}
+
+// ErrIncidentOpen is returned when opening an incident for a device
+// that already has an open incident.
+var ErrIncidentOpen = errors.New("incident already open")
+
+// OpenIncident opens an incident for a device with a unique ID,
+// unless the device already has an open incident, in which case that
+// incident is returned along with ErrIncidentOpen.
+func OpenIncident(ctx context.Context, store datastore.Store, skey, mac, missed int64) (*Incident, error) {
+	inc, err := GetOpenIncident(ctx, store, skey, mac)
+	if err == nil {
+		return inc, ErrIncidentOpen
+	}
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, err
+	}
+	inc = &Incident{Skey: skey, Mac: mac, Missed: missed, Opened: time.Now()}
+	for {
+		inc.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeIncident, inc.ID), inc)
+		if err == nil {
+			return inc, nil
+		} else if err != datastore.ErrEntityExists {
+			return nil, fmt.Errorf("could not create incident: %w", err)
+		}
+	}
+}
+
+// PutIncident updates an incident.
+func PutIncident(ctx context.Context, store datastore.Store, inc *Incident) error {
+	_, err := store.Put(ctx, store.IDKey(typeIncident, inc.ID), inc)
+	return err
+}
+
+// GetIncident gets an incident.
+func GetIncident(ctx context.Context, store datastore.Store, id int64) (*Incident, error) {
+	inc := new(Incident)
+	err := store.Get(ctx, store.IDKey(typeIncident, id), inc)
+	if err != nil {
+		return nil, err
+	}
+	return inc, nil
+}
+
+// GetIncidents returns the incidents for a site, newest first.
+func GetIncidents(ctx context.Context, store datastore.Store, skey int64) ([]Incident, error) {
+	q := store.NewQuery(typeIncident, false)
+	var all []Incident
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var incs []Incident
+	for _, inc := range all {
+		if inc.Skey == skey {
+			incs = append(incs, inc)
+		}
+	}
+	sort.Slice(incs, func(i, j int) bool { return incs[i].Opened.After(incs[j].Opened) })
+	return incs, nil
+}
+
+// GetOpenIncident returns the open incident for a device, or
+// datastore.ErrNoSuchEntity if there is none.
+func GetOpenIncident(ctx context.Context, store datastore.Store, skey, mac int64) (*Incident, error) {
+	incs, err := GetIncidents(ctx, store, skey)
+	if err != nil {
+		return nil, err
+	}
+	for _, inc := range incs {
+		if inc.Mac == mac && inc.Open() {
+			return &inc, nil
+		}
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
+
+// ResolveIncident resolves an incident, appending the given note.
+func ResolveIncident(ctx context.Context, store datastore.Store, inc *Incident, note string) error {
+	inc.Resolved = time.Now()
+	if note != "" {
+		if inc.Notes != "" {
+			inc.Notes += "\n"
+		}
+		inc.Notes += note
+	}
+	return PutIncident(ctx, store, inc)
+}
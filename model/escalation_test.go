@@ -0,0 +1,86 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestEscalation tests escalation levels, missed reports and incidents.
+func TestEscalation(t *testing.T) {
+	p := &EscalationPolicy{OpsAfter: 2, IncidentAfter: 5}
+	for _, test := range []struct {
+		missed int64
+		want   int
+	}{
+		{0, EscalateNone},
+		{1, EscalateNone},
+		{2, EscalateOps},
+		{4, EscalateOps}, // Owner level is disabled.
+		{5, EscalateIncident},
+		{100, EscalateIncident},
+	} {
+		got := p.Level(test.missed)
+		if got != test.want {
+			t.Errorf("Level(%d) returned %d, want %d", test.missed, got, test.want)
+		}
+	}
+
+	now := time.Now()
+	dev := &Device{MonitorPeriod: 60}
+	if got := MissedReports(dev, now.Add(-150*time.Second), now); got != 2 {
+		t.Errorf("MissedReports returned %d, want 2", got)
+	}
+	if got := MissedReports(dev, now, now); got != 0 {
+		t.Errorf("MissedReports returned %d, want 0", got)
+	}
+
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	inc, err := OpenIncident(ctx, store, 1, 2, 5)
+	if err != nil {
+		t.Fatalf("OpenIncident returned error: %v", err)
+	}
+	inc2, err := OpenIncident(ctx, store, 1, 2, 6)
+	if !errors.Is(err, ErrIncidentOpen) || inc2.ID != inc.ID {
+		t.Fatalf("OpenIncident did not return existing incident, got: %v, %v", inc2, err)
+	}
+	err = ResolveIncident(ctx, store, inc, "device reported")
+	if err != nil {
+		t.Fatalf("ResolveIncident returned error: %v", err)
+	}
+	_, err = GetOpenIncident(ctx, store, 1, 2)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetOpenIncident returned %v, want ErrNoSuchEntity", err)
+	}
+	incs, err := GetIncidents(ctx, store, 1)
+	if err != nil || len(incs) != 1 || incs[0].Open() {
+		t.Errorf("GetIncidents returned %v, %v", incs, err)
+	}
+}
@@ -22,6 +22,7 @@ LICENSE
 package model
 
 import (
+	"context"
 	"time"
 
 	"github.com/ausocean/openfish/datastore"
@@ -40,6 +41,7 @@ type Feed struct {
 	Source  string    // Feed source URL, e.g., a YouTube URL, or a URL to an AusOcean data stream (such as weather data).
 	Params  string    // Optional params to be applied to the source.
 	Bundle  []string  // Feed IDs of other feeds bundled with this feed, or nil.
+	Skey    int64     // Key of the site whose broadcasts may set the source, or zero for none.
 	Created time.Time // Time the feed entity was created.
 }
 
@@ -63,3 +65,19 @@ func (f *Feed) Copy(dst datastore.Entity) (datastore.Entity, error) {
 func (f *Feed) GetCache() datastore.Cache {
 	return nil
 }
+
+// PutFeed creates or updates a feed.
+func PutFeed(ctx context.Context, store datastore.Store, f *Feed) error {
+	_, err := store.Put(ctx, store.IDKey(typeFeed, f.ID), f)
+	return err
+}
+
+// GetFeed gets the feed with the given ID.
+func GetFeed(ctx context.Context, store datastore.Store, id int64) (*Feed, error) {
+	f := new(Feed)
+	err := store.Get(ctx, store.IDKey(typeFeed, id), f)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
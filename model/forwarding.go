@@ -0,0 +1,349 @@
+/*
+DESCRIPTION
+  Forwarding datastore type and functions, which implement the
+  delivery of sensor data to external endpoints.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeForwarding = "Forwarding" // Forwarding datastore type.
+
+// Forwarding destination types.
+const (
+	ForwardERDDAP = "erddap" // ERDDAP EDDTableFromHttpGet dataset.
+	ForwardHTTP   = "http"   // HTTP endpoint accepting CSV.
+	ForwardSFTP   = "sftp"   // SFTP server directory.
+)
+
+// ForwardTypes are the supported forwarding destination types.
+var ForwardTypes = []string{ForwardERDDAP, ForwardHTTP, ForwardSFTP}
+
+// ErrInvalidForwarding is returned when a forwarding is incomplete or
+// its destination is not supported.
+var ErrInvalidForwarding = errors.New("invalid forwarding")
+
+// Forwarding represents the periodic delivery of new readings from
+// selected sensors of a site to an external endpoint, such as a
+// research partner's ERDDAP server. Readings are delivered in time
+// order, from Cursor up to the time of delivery, so each reading is
+// delivered once, and failed deliveries are retried in full. The
+// destination depends on the type:
+//
+//   - erddap: the URL of the dataset's insert endpoint, e.g.,
+//     https://erddap.example.org/erddap/tabledap/buoy.insert, with
+//     Credential being the author key.
+//   - http: a URL to which CSV is POSTed, with Credential, if any,
+//     sent as a bearer token.
+//   - sftp: a URL of the form sftp://user@host:port/dir, with
+//     Credential being the password and HostKey the server's public
+//     key in authorized_keys format.
+type Forwarding struct {
+	ID            int64     // Forwarding ID.
+	Skey          int64     // Site key.
+	Name          string    // Forwarding name, e.g., Partner ERDDAP.
+	Sensors       string    // Comma-separated sensors as MAC.pin, e.g., 00:00:00:00:00:01.A0.
+	DestType      string    // Destination type, e.g., erddap.
+	Dest          string    // Destination URL.
+	Credential    string    `datastore:",noindex"` // Destination credential, if any.
+	HostKey       string    `datastore:",noindex"` // SFTP server public key.
+	Period        int64     // Minutes between deliveries.
+	Enabled       bool      // True if readings are delivered.
+	Cursor        int64     // Unix time up to which readings have been delivered.
+	LastAttempt   time.Time // Date/time of the last delivery attempt.
+	LastDelivered time.Time // Date/time readings were last delivered.
+	Delivered     int64     // Total number of readings delivered.
+	Failures      int       // Number of consecutive failed attempts.
+	LastError     string    `datastore:",noindex"` // Error of the last failed attempt, if any.
+	Updated       time.Time // Date/time last updated.
+}
+
+// ForwardedSensor identifies a sensor by its device's MAC address and pin.
+type ForwardedSensor struct {
+	Mac int64
+	Pin string
+}
+
+// String returns a sensor as MAC.pin.
+func (s ForwardedSensor) String() string {
+	return MacDecode(s.Mac) + "." + s.Pin
+}
+
+// Copy copies a forwarding to dst, or returns a copy of the forwarding when dst is nil.
+func (f *Forwarding) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var f2 *Forwarding
+	if dst == nil {
+		f2 = new(Forwarding)
+	} else {
+		var ok bool
+		f2, ok = dst.(*Forwarding)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*f2 = *f
+	return f2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (f *Forwarding) GetCache() datastore.Cache {
+	return nil
+}
+
+// SensorList returns the forwarding's sensors.
+func (f *Forwarding) SensorList() ([]ForwardedSensor, error) {
+	var sensors []ForwardedSensor
+	for _, s := range strings.Split(f.Sensors, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		ma, pin, ok := strings.Cut(s, ".")
+		if !ok || !IsMacAddress(ma) || pin == "" {
+			return nil, fmt.Errorf("%w: sensor %q is not of the form MAC.pin", ErrInvalidForwarding, s)
+		}
+		sensors = append(sensors, ForwardedSensor{Mac: MacEncode(ma), Pin: pin})
+	}
+	return sensors, nil
+}
+
+// Validate returns an error if the forwarding has no sensors or its
+// destination is invalid for its type. ERDDAP and HTTP destinations
+// must be https URLs of public hosts, so that forwardings cannot be
+// used to reach internal services.
+func (f *Forwarding) Validate() error {
+	sensors, err := f.SensorList()
+	if err != nil {
+		return err
+	}
+	if len(sensors) == 0 {
+		return fmt.Errorf("%w: no sensors", ErrInvalidForwarding)
+	}
+	u, err := url.Parse(f.Dest)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: invalid destination %q", ErrInvalidForwarding, f.Dest)
+	}
+	switch f.DestType {
+	case ForwardERDDAP, ForwardHTTP:
+		if u.Scheme != "https" || u.User != nil {
+			return fmt.Errorf("%w: %s destination must be an https URL without credentials", ErrInvalidForwarding, f.DestType)
+		}
+		if !PublicHost(u.Hostname()) {
+			return fmt.Errorf("%w: %s destination must be a public host", ErrInvalidForwarding, f.DestType)
+		}
+	case ForwardSFTP:
+		if u.Scheme != "sftp" || u.User == nil {
+			return fmt.Errorf("%w: sftp destination must be of the form sftp://user@host/dir", ErrInvalidForwarding)
+		}
+		if !PublicHost(u.Hostname()) {
+			return fmt.Errorf("%w: sftp destination must be a public host", ErrInvalidForwarding)
+		}
+		if f.HostKey == "" {
+			return fmt.Errorf("%w: sftp destination requires a host key", ErrInvalidForwarding)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported destination type %q", ErrInvalidForwarding, f.DestType)
+	}
+	if f.Period < 0 {
+		return fmt.Errorf("%w: negative period", ErrInvalidForwarding)
+	}
+	return nil
+}
+
+// PublicHost returns true if host may be a public internet host, i.e.,
+// it is neither a local name, such as localhost or an internal cloud
+// name, nor the literal address of a non-public IP. Since names may
+// resolve to any address, resolved addresses must also be checked with
+// PublicIP when connecting.
+func PublicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || host == "localhost" || !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range []string{".localhost", ".local", ".internal"} {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || PublicIP(ip)
+}
+
+// PublicIP returns true if ip is a public unicast address, i.e., it is
+// not a loopback, private, link-local (including cloud metadata
+// servers), multicast or unspecified address.
+func PublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// Due returns true if an enabled forwarding should be attempted at
+// time now, i.e., if its period has elapsed since the last attempt.
+func (f *Forwarding) Due(now time.Time) bool {
+	return f.Enabled && !now.Before(f.LastAttempt.Add(time.Duration(f.Period)*time.Minute))
+}
+
+// CreateForwarding creates a forwarding with a unique ID. Readings
+// are delivered from the time of creation, unless Cursor is set.
+func CreateForwarding(ctx context.Context, store datastore.Store, f *Forwarding) error {
+	err := f.Validate()
+	if err != nil {
+		return err
+	}
+	f.Updated = time.Now()
+	if f.Cursor == 0 {
+		f.Cursor = f.Updated.Unix()
+	}
+	for {
+		f.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeForwarding, f.ID), f)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create forwarding: %w", err)
+		}
+	}
+}
+
+// PutForwarding updates a forwarding.
+func PutForwarding(ctx context.Context, store datastore.Store, f *Forwarding) error {
+	err := f.Validate()
+	if err != nil {
+		return err
+	}
+	f.Updated = time.Now()
+	_, err = store.Put(ctx, store.IDKey(typeForwarding, f.ID), f)
+	return err
+}
+
+// GetForwarding gets a forwarding.
+func GetForwarding(ctx context.Context, store datastore.Store, id int64) (*Forwarding, error) {
+	f := new(Forwarding)
+	err := store.Get(ctx, store.IDKey(typeForwarding, id), f)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetForwardingsBySite returns the forwardings for a site, sorted by name.
+func GetForwardingsBySite(ctx context.Context, store datastore.Store, skey int64) ([]Forwarding, error) {
+	q := store.NewQuery(typeForwarding, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+	}
+	var all []Forwarding
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var fwds []Forwarding
+	for _, f := range all {
+		if f.Skey == skey {
+			fwds = append(fwds, f)
+		}
+	}
+	sort.Slice(fwds, func(i, j int) bool { return fwds[i].Name < fwds[j].Name })
+	return fwds, nil
+}
+
+// GetEnabledForwardings returns the enabled forwardings of all sites.
+func GetEnabledForwardings(ctx context.Context, store datastore.Store) ([]Forwarding, error) {
+	q := store.NewQuery(typeForwarding, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Enabled =", true)
+	}
+	var all []Forwarding
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var fwds []Forwarding
+	for _, f := range all {
+		if f.Enabled {
+			fwds = append(fwds, f)
+		}
+	}
+	return fwds, nil
+}
+
+// RecordForwarding records a delivery attempt of a forwarding at time
+// now. If cause is nil, n readings up to Unix time cursor were
+// delivered, else the attempt failed and the cursor is unchanged, so
+// that the readings are delivered by a later attempt. Only the
+// delivery status is updated, so that concurrent edits are retained.
+func RecordForwarding(ctx context.Context, store datastore.Store, id, cursor int64, n int, cause error, now time.Time) error {
+	return store.Update(ctx, store.IDKey(typeForwarding, id), func(ety datastore.Entity) {
+		f, ok := ety.(*Forwarding)
+		if !ok {
+			return
+		}
+		f.LastAttempt = now
+		if cause != nil {
+			f.Failures++
+			f.LastError = cause.Error()
+			return
+		}
+		f.Failures = 0
+		f.LastError = ""
+		f.Cursor = cursor
+		if n > 0 {
+			f.Delivered += int64(n)
+			f.LastDelivered = now
+		}
+	}, &Forwarding{})
+}
+
+// UpdateForwarding updates a forwarding transactionally by calling fn,
+// which must only change the settings of the forwarding, so that
+// concurrent deliveries are retained.
+func UpdateForwarding(ctx context.Context, store datastore.Store, id int64, fn func(*Forwarding)) (*Forwarding, error) {
+	f := new(Forwarding)
+	err := store.Update(ctx, store.IDKey(typeForwarding, id), func(ety datastore.Entity) {
+		fwd, ok := ety.(*Forwarding)
+		if !ok {
+			return
+		}
+		fn(fwd)
+		fwd.Updated = time.Now()
+	}, f)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// DeleteForwarding deletes a forwarding.
+func DeleteForwarding(ctx context.Context, store datastore.Store, id int64) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.IDKey(typeForwarding, id)})
+}
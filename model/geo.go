@@ -0,0 +1,425 @@
+/*
+DESCRIPTION
+  Geospatial functions, which implement geohashing and bounding box
+  and radius queries for devices, sites and media.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	// GeohashPrecision is the number of characters in the geohashes of
+	// devices and sites, which is precise to about 5m.
+	GeohashPrecision = 9
+
+	geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	// geohashEnd sorts after every geohash character, so that the range
+	// [prefix, prefix+geohashEnd) includes every geohash with the prefix.
+	geohashEnd = "{"
+
+	// maxGeohashCells is the maximum number of geohash cells, and hence
+	// queries, used to cover a bounding box.
+	maxGeohashCells = 16
+
+	earthRadius = 6371.0 // Mean radius of the earth (km).
+)
+
+// BoundingBox represents a rectangular area in degrees of latitude and
+// longitude. Boxes that cross the antimeridian are not supported.
+type BoundingBox struct {
+	MinLat, MinLon float64 // South west corner.
+	MaxLat, MaxLon float64 // North east corner.
+}
+
+// Validate returns ErrInvalidLocation if the box's corners are out of
+// range or not ordered.
+func (b BoundingBox) Validate() error {
+	if b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180 || b.MinLat > b.MaxLat || b.MinLon > b.MaxLon {
+		return fmt.Errorf("%w: bounding box %+v", ErrInvalidLocation, b)
+	}
+	return nil
+}
+
+// Contains returns true if a location is within the box, inclusive.
+func (b BoundingBox) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// RadiusBox returns the bounding box of the circle with the given
+// radius in km around a location. The box is clamped to valid
+// latitudes and longitudes, rather than crossing a pole or the
+// antimeridian.
+func RadiusBox(lat, lon, km float64) BoundingBox {
+	dLat := km / earthRadius * 180 / math.Pi
+	b := BoundingBox{MinLat: max(lat-dLat, -90), MaxLat: min(lat+dLat, 90), MinLon: -180, MaxLon: 180}
+	cos := math.Cos(lat * math.Pi / 180)
+	if b.MinLat > -90 && b.MaxLat < 90 && cos > 0 {
+		dLon := dLat / cos
+		b.MinLon = max(lon-dLon, -180)
+		b.MaxLon = min(lon+dLon, 180)
+	}
+	return b
+}
+
+// Distance returns the great circle distance in km between two
+// locations, using the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(min(a, 1)))
+}
+
+// Geohash returns the geohash of a location with the given number of
+// characters, e.g., r1f9652gs for -34.91805,138.60475.
+func Geohash(lat, lon float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	var sb strings.Builder
+	var ch, bits int
+	even := true // Even bits encode longitude, odd bits latitude.
+	for sb.Len() < precision {
+		ch <<= 1
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				ch |= 1
+				minLon = mid
+			} else {
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch |= 1
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+		bits++
+		if bits == 5 {
+			sb.WriteByte(geohashBase32[ch])
+			ch, bits = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// GeohashBox returns the bounding box of a geohash's cell.
+func GeohashBox(gh string) (BoundingBox, error) {
+	b := BoundingBox{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}
+	even := true
+	for i := 0; i < len(gh); i++ {
+		ch := strings.IndexByte(geohashBase32, gh[i])
+		if ch < 0 {
+			return b, fmt.Errorf("%w: geohash %q", ErrInvalidLocation, gh)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := ch>>bit&1 == 1
+			if even {
+				mid := (b.MinLon + b.MaxLon) / 2
+				if set {
+					b.MinLon = mid
+				} else {
+					b.MaxLon = mid
+				}
+			} else {
+				mid := (b.MinLat + b.MaxLat) / 2
+				if set {
+					b.MinLat = mid
+				} else {
+					b.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return b, nil
+}
+
+// GeohashLocation returns the location of the center of a geohash's cell.
+func GeohashLocation(gh string) (lat, lon float64, err error) {
+	b, err := GeohashBox(gh)
+	if err != nil {
+		return 0, 0, err
+	}
+	return (b.MinLat + b.MaxLat) / 2, (b.MinLon + b.MaxLon) / 2, nil
+}
+
+// locationGeohash returns the geohash of a device or site location,
+// or the empty string if the location is not set, i.e., is 0,0.
+func locationGeohash(lat, lon float64) string {
+	if lat == 0 && lon == 0 {
+		return ""
+	}
+	return Geohash(lat, lon, GeohashPrecision)
+}
+
+// geohashRanges returns the geohash ranges, as [start, end) pairs,
+// of the cells that cover a bounding box. The cells are the smallest
+// for which there are at most maxGeohashCells, so the ranges may
+// include locations outside of the box.
+func geohashRanges(b BoundingBox) [][2]string {
+	// Find the greatest precision that covers the box in few enough cells.
+	var precision int
+	var latStep, lonStep float64
+	for p := 1; p <= GeohashPrecision; p++ {
+		lonBits := (5*p + 1) / 2
+		latBits := 5 * p / 2
+		dLon := 360 / math.Exp2(float64(lonBits))
+		dLat := 180 / math.Exp2(float64(latBits))
+		cols := math.Floor((b.MaxLon+180)/dLon) - math.Floor((b.MinLon+180)/dLon) + 1
+		rows := math.Floor((b.MaxLat+90)/dLat) - math.Floor((b.MinLat+90)/dLat) + 1
+		if cols*rows > maxGeohashCells {
+			break
+		}
+		precision, latStep, lonStep = p, dLat, dLon
+	}
+	if precision == 0 {
+		return [][2]string{{geohashBase32[:1], geohashEnd}}
+	}
+
+	// Enumerate the cells by the geohashes of points within each, which
+	// are visited by stepping one cell at a time.
+	var ranges [][2]string
+	seen := map[string]bool{}
+	for lat := b.MinLat; ; lat = min(lat+latStep, b.MaxLat) {
+		for lon := b.MinLon; ; lon = min(lon+lonStep, b.MaxLon) {
+			gh := Geohash(lat, lon, precision)
+			if !seen[gh] {
+				seen[gh] = true
+				ranges = append(ranges, [2]string{gh, gh + geohashEnd})
+			}
+			if lon == b.MaxLon {
+				break
+			}
+		}
+		if lat == b.MaxLat {
+			break
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	return ranges
+}
+
+// getInBox returns the entities of the given kind, which must have a
+// Geohash property, whose locations are within a bounding box. Since
+// the FileStore does not support property filters, FileStore entities
+// are all retrieved then filtered.
+func getInBox[T any](ctx context.Context, store datastore.Store, kind string, b BoundingBox, location func(*T) (lat, lon float64, ok bool)) ([]T, error) {
+	err := b.Validate()
+	if err != nil {
+		return nil, err
+	}
+	var queries []datastore.Query
+	if _, filestore := store.(*datastore.FileStore); filestore {
+		queries = append(queries, store.NewQuery(kind, false))
+	} else {
+		for _, r := range geohashRanges(b) {
+			q := store.NewQuery(kind, false)
+			q.Filter("Geohash >=", r[0])
+			q.Filter("Geohash <", r[1])
+			queries = append(queries, q)
+		}
+	}
+
+	// Cells do not overlap, so each entity is found at most once.
+	var found []T
+	for _, q := range queries {
+		var all []T
+		_, err := store.GetAll(ctx, q, &all)
+		if err != nil {
+			return nil, err
+		}
+		for i := range all {
+			lat, lon, ok := location(&all[i])
+			if ok && b.Contains(lat, lon) {
+				found = append(found, all[i])
+			}
+		}
+	}
+	return found, nil
+}
+
+// deviceLocation returns the location of a device, if set.
+func deviceLocation(dev *Device) (float64, float64, bool) {
+	return dev.Latitude, dev.Longitude, dev.Geohash != ""
+}
+
+// siteLocation returns the location of a site, if set.
+func siteLocation(site *Site) (float64, float64, bool) {
+	return site.Latitude, site.Longitude, site.Geohash != ""
+}
+
+// GetDevicesInBox returns the devices located within a bounding box.
+// Devices without a location are excluded.
+func GetDevicesInBox(ctx context.Context, store datastore.Store, b BoundingBox) ([]Device, error) {
+	return getInBox(ctx, store, typeDevice, b, deviceLocation)
+}
+
+// GetDevicesWithinRadius returns the devices located within the given
+// radius in km of a location.
+func GetDevicesWithinRadius(ctx context.Context, store datastore.Store, lat, lon, km float64) ([]Device, error) {
+	devs, err := GetDevicesInBox(ctx, store, RadiusBox(lat, lon, km))
+	if err != nil {
+		return nil, err
+	}
+	return withinRadius(devs, lat, lon, km, deviceLocation), nil
+}
+
+// GetSitesInBox returns the sites located within a bounding box.
+// Sites without a location are excluded.
+func GetSitesInBox(ctx context.Context, store datastore.Store, b BoundingBox) ([]Site, error) {
+	return getInBox(ctx, store, typeSite, b, siteLocation)
+}
+
+// GetSitesWithinRadius returns the sites located within the given
+// radius in km of a location.
+func GetSitesWithinRadius(ctx context.Context, store datastore.Store, lat, lon, km float64) ([]Site, error) {
+	sites, err := GetSitesInBox(ctx, store, RadiusBox(lat, lon, km))
+	if err != nil {
+		return nil, err
+	}
+	return withinRadius(sites, lat, lon, km, siteLocation), nil
+}
+
+// GetMtsMediaInBox returns the MTS media for a given Media ID whose
+// geohashes are within a bounding box, ordered by geohash then
+// timestamp. Media is located at the center of its geohash's cell.
+//
+// NB: Since FileStore queries are limited to MID and Timestamp, all
+// FileStore media for the MID is retrieved then filtered.
+func GetMtsMediaInBox(ctx context.Context, store datastore.Store, mid int64, b BoundingBox) ([]MtsMedia, error) {
+	err := b.Validate()
+	if err != nil {
+		return nil, err
+	}
+	ranges := geohashRanges(b)
+	if _, filestore := store.(*datastore.FileStore); filestore {
+		ranges = ranges[:1] // Geohash filters are ignored.
+	}
+	var found []MtsMedia
+	for _, r := range ranges {
+		clips, err := GetMtsMedia(ctx, store, mid, r[:], nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range clips {
+			lat, lon, ok := mediaLocation(&m)
+			if ok && b.Contains(lat, lon) {
+				found = append(found, m)
+			}
+		}
+	}
+	return found, nil
+}
+
+// GetMtsMediaWithinRadius returns the MTS media for a given Media ID
+// whose geohashes are within the given radius in km of a location.
+func GetMtsMediaWithinRadius(ctx context.Context, store datastore.Store, mid int64, lat, lon, km float64) ([]MtsMedia, error) {
+	clips, err := GetMtsMediaInBox(ctx, store, mid, RadiusBox(lat, lon, km))
+	if err != nil {
+		return nil, err
+	}
+	return withinRadius(clips, lat, lon, km, mediaLocation), nil
+}
+
+// mediaLocation returns the location of MTS media, if it has a geohash.
+func mediaLocation(m *MtsMedia) (float64, float64, bool) {
+	if m.Geohash == "" {
+		return 0, 0, false
+	}
+	lat, lon, err := GeohashLocation(m.Geohash)
+	return lat, lon, err == nil
+}
+
+// withinRadius filters entities to those within the given radius in
+// km of a location.
+func withinRadius[T any](entities []T, lat, lon, km float64, location func(*T) (float64, float64, bool)) []T {
+	var found []T
+	for i := range entities {
+		elat, elon, ok := location(&entities[i])
+		if ok && Distance(lat, lon, elat, elon) <= km {
+			found = append(found, entities[i])
+		}
+	}
+	return found
+}
+
+// PutGeohashes sets the geohashes of devices and sites that were put
+// before they were geohashed, or whose geohashes are otherwise stale,
+// returning the number updated. Entities are put directly, so that
+// their update times are unchanged.
+func PutGeohashes(ctx context.Context, store datastore.Store) (int, error) {
+	var n int
+
+	var devs []Device
+	keys, err := store.GetAll(ctx, store.NewQuery(typeDevice, false), &devs)
+	if err != nil {
+		return n, fmt.Errorf("could not get devices: %w", err)
+	}
+	for i := range devs {
+		dev := &devs[i]
+		gh := locationGeohash(dev.Latitude, dev.Longitude)
+		if dev.Geohash == gh {
+			continue
+		}
+		dev.Geohash = gh
+		_, err = store.Put(ctx, keys[i], dev)
+		if err != nil {
+			return n, fmt.Errorf("could not put device %s: %w", dev.MAC(), err)
+		}
+		invalidate(devCache, keys[i])
+		n++
+	}
+
+	var sites []Site
+	keys, err = store.GetAll(ctx, store.NewQuery(typeSite, false), &sites)
+	if err != nil {
+		return n, fmt.Errorf("could not get sites: %w", err)
+	}
+	for i := range sites {
+		site := &sites[i]
+		gh := locationGeohash(site.Latitude, site.Longitude)
+		if site.Geohash == gh {
+			continue
+		}
+		site.Geohash = gh
+		_, err = store.Put(ctx, keys[i], site)
+		if err != nil {
+			return n, fmt.Errorf("could not put site %d: %w", site.Skey, err)
+		}
+		invalidate(siteCache, keys[i])
+		n++
+	}
+	return n, nil
+}
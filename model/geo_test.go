@@ -0,0 +1,216 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestGeohash tests encoding and decoding geohashes.
+func TestGeohash(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{-34.91805, 138.60475, "r1f9652gs"}, // Benham Lab, University of Adelaide.
+		{-34.92857, 138.60006, "r1f93cmqr"}, // Victoria Square.
+		{57.64911, 10.40744, "u4pruydqq"},
+		{0, 0, "s00000000"},
+	}
+	for _, test := range tests {
+		got := Geohash(test.lat, test.lon, GeohashPrecision)
+		if got != test.want {
+			t.Errorf("Geohash(%g, %g) = %s, expected %s", test.lat, test.lon, got, test.want)
+		}
+		b, err := GeohashBox(got)
+		if err != nil {
+			t.Fatalf("GeohashBox(%s) returned error: %v", got, err)
+		}
+		if !b.Contains(test.lat, test.lon) {
+			t.Errorf("GeohashBox(%s) = %+v, which does not contain %g, %g", got, b, test.lat, test.lon)
+		}
+	}
+
+	_, err := GeohashBox("r1fa")
+	if err == nil {
+		t.Errorf("GeohashBox of invalid geohash did not return error")
+	}
+}
+
+// TestDistance tests great circle distances and radius bounding boxes.
+func TestDistance(t *testing.T) {
+	// Adelaide to Melbourne is about 654km.
+	d := Distance(-34.9285, 138.6007, -37.8136, 144.9631)
+	if math.Abs(d-654) > 5 {
+		t.Errorf("Distance from Adelaide to Melbourne = %gkm, expected about 654km", d)
+	}
+
+	b := RadiusBox(-34.9285, 138.6007, 10)
+	for _, bearing := range []float64{0, 45, 90, 135, 180, 225, 270, 315} {
+		// Points 10km away are within the box.
+		rad := bearing * math.Pi / 180
+		lat := -34.9285 + 9.99/earthRadius*180/math.Pi*math.Cos(rad)
+		lon := 138.6007 + 9.99/earthRadius*180/math.Pi*math.Sin(rad)/math.Cos(-34.9285*math.Pi/180)
+		if !b.Contains(lat, lon) {
+			t.Errorf("RadiusBox %+v does not contain %g, %g", b, lat, lon)
+		}
+	}
+	if err := RadiusBox(89.99, 0, 10).Validate(); err != nil {
+		t.Errorf("polar RadiusBox is invalid: %v", err)
+	}
+}
+
+// TestGeohashRanges tests that geohash ranges cover bounding boxes.
+func TestGeohashRanges(t *testing.T) {
+	boxes := []BoundingBox{
+		{MinLat: -34.93, MinLon: 138.59, MaxLat: -34.91, MaxLon: 138.61},
+		{MinLat: -40, MinLon: 130, MaxLat: -30, MaxLon: 150},
+		{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180},
+		{MinLat: 1, MinLon: 1, MaxLat: 1, MaxLon: 1},
+	}
+	for _, b := range boxes {
+		ranges := geohashRanges(b)
+		if len(ranges) == 0 || len(ranges) > maxGeohashCells {
+			t.Errorf("got %d ranges for %+v", len(ranges), b)
+		}
+		if !sort.SliceIsSorted(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] }) {
+			t.Errorf("ranges for %+v are not sorted", b)
+		}
+		// Sample points within the box are within a range.
+		for i := 0; i <= 10; i++ {
+			for j := 0; j <= 10; j++ {
+				lat := b.MinLat + (b.MaxLat-b.MinLat)*float64(i)/10
+				lon := b.MinLon + (b.MaxLon-b.MinLon)*float64(j)/10
+				gh := Geohash(lat, lon, GeohashPrecision)
+				var ok bool
+				for _, r := range ranges {
+					ok = ok || (gh >= r[0] && gh < r[1])
+				}
+				if !ok {
+					t.Errorf("%s (%g, %g) is not within ranges %v for %+v", gh, lat, lon, ranges, b)
+				}
+			}
+		}
+	}
+}
+
+// TestGeoQueries tests finding devices, sites and media by location.
+func TestGeoQueries(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	devs := []Device{
+		{Skey: 1, Mac: 1, Name: "Benham", Latitude: -34.91805, Longitude: 138.60475},
+		{Skey: 1, Mac: 2, Name: "Museum", Latitude: -34.92069, Longitude: 138.60311},
+		{Skey: 1, Mac: 3, Name: "Melbourne", Latitude: -37.8136, Longitude: 144.9631},
+		{Skey: 1, Mac: 4, Name: "Unlocated"},
+	}
+	for i := range devs {
+		err = PutDevice(ctx, store, &devs[i])
+		if err != nil {
+			t.Fatalf("could not put device: %v", err)
+		}
+	}
+	if devs[0].Geohash != "r1f9652gs" || devs[3].Geohash != "" {
+		t.Errorf("unexpected device geohashes %q and %q", devs[0].Geohash, devs[3].Geohash)
+	}
+	dev, err := GetDevice(ctx, store, 1)
+	if err != nil {
+		t.Fatalf("could not get device: %v", err)
+	}
+	if dev.Geohash != "r1f9652gs" {
+		t.Errorf("got device geohash %q after decoding", dev.Geohash)
+	}
+
+	names := func(devs []Device) string {
+		var s []string
+		for _, d := range devs {
+			s = append(s, d.Name)
+		}
+		sort.Strings(s)
+		return strings.Join(s, ",")
+	}
+	got, err := GetDevicesInBox(ctx, store, BoundingBox{MinLat: -35, MinLon: 138, MaxLat: -34, MaxLon: 139})
+	if err != nil {
+		t.Fatalf("GetDevicesInBox returned error: %v", err)
+	}
+	if names(got) != "Benham,Museum" {
+		t.Errorf("GetDevicesInBox returned %s", names(got))
+	}
+	got, err = GetDevicesWithinRadius(ctx, store, -34.91805, 138.60475, 0.1)
+	if err != nil {
+		t.Fatalf("GetDevicesWithinRadius returned error: %v", err)
+	}
+	if names(got) != "Benham" {
+		t.Errorf("GetDevicesWithinRadius returned %s", names(got))
+	}
+	_, err = GetDevicesInBox(ctx, store, BoundingBox{MinLat: 1, MaxLat: 0})
+	if err == nil {
+		t.Errorf("GetDevicesInBox with invalid box did not return error")
+	}
+
+	err = PutSite(ctx, store, &Site{Skey: 1, Name: "Adelaide", Latitude: -34.9285, Longitude: 138.6007})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	err = PutSite(ctx, store, &Site{Skey: 2, Name: "Melbourne", Latitude: -37.8136, Longitude: 144.9631})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+	sites, err := GetSitesWithinRadius(ctx, store, -35, 139, 100)
+	if err != nil {
+		t.Fatalf("GetSitesWithinRadius returned error: %v", err)
+	}
+	if len(sites) != 1 || sites[0].Skey != 1 || sites[0].Geohash == "" {
+		t.Errorf("GetSitesWithinRadius returned %+v", sites)
+	}
+
+	for i, gh := range []string{"r1f9652gs", "r1f93cmqr", "r1r0fsnzs", ""} {
+		ts := datastore.EpochStart + int64(i)
+		_, err = store.Put(ctx, store.IDKey(typeMtsMedia, datastore.IDKey(1, ts, 0)), &MtsMedia{MID: 1, Geohash: gh, Timestamp: ts})
+		if err != nil {
+			t.Fatalf("could not put media: %v", err)
+		}
+	}
+	clips, err := GetMtsMediaWithinRadius(ctx, store, 1, -34.92, 138.60, 2)
+	if err != nil {
+		t.Fatalf("GetMtsMediaWithinRadius returned error: %v", err)
+	}
+	if len(clips) != 2 {
+		t.Errorf("GetMtsMediaWithinRadius returned %d clips, expected 2", len(clips))
+	}
+
+	n, err := PutGeohashes(ctx, store)
+	if err != nil {
+		t.Fatalf("PutGeohashes returned error: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("PutGeohashes updated %d entities, expected none", n)
+	}
+}
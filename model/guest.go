@@ -0,0 +1,303 @@
+/*
+DESCRIPTION
+  GuestSession and FunnelEvent datastore types and functions, which
+  implement AusOcean TV guest viewing and conversion tracking.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	typeGuestSession = "GuestSession" // GuestSession datastore type.
+	typeFunnelEvent  = "FunnelEvent"  // FunnelEvent datastore type.
+)
+
+// SubscriptionGuest is the pseudo subscription class of guests, whose
+// entitlement determines the feeds guests may view.
+const SubscriptionGuest = "Guest"
+
+// Funnel events, in funnel order.
+const (
+	FunnelGuest     = "guest"     // A guest session started.
+	FunnelView      = "view"      // A guest viewed a feed.
+	FunnelSignup    = "signup"    // A user signed up as a subscriber.
+	FunnelSubscribe = "subscribe" // A subscriber subscribed.
+)
+
+// FunnelEvents lists the funnel events in funnel order.
+var FunnelEvents = []string{FunnelGuest, FunnelView, FunnelSignup, FunnelSubscribe}
+
+// GuestSession represents an anonymous viewer, who is allowed a
+// limited amount of free viewing. A guest converts when they sign up
+// as a subscriber. Sessions are identified by a cookie, but also
+// record the client they were created by, so that clearing cookies
+// does not renew the allowance.
+type GuestSession struct {
+	ID         int64     // Guest ID.
+	Client     string    // Identifier of the client that created the session, e.g., a hash of its IP address.
+	Viewed     int64     // Viewing time granted so far, in seconds.
+	Subscriber int64     // Subscriber ID once converted, else zero.
+	Created    time.Time // Date/time created.
+	Converted  time.Time // Date/time converted, or zero.
+}
+
+// Copy copies a guest session to dst, or returns a copy of the guest session when dst is nil.
+func (g *GuestSession) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var g2 *GuestSession
+	if dst == nil {
+		g2 = new(GuestSession)
+	} else {
+		var ok bool
+		g2, ok = dst.(*GuestSession)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*g2 = *g
+	return g2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (g *GuestSession) GetCache() datastore.Cache {
+	return nil
+}
+
+// CreateGuestSession creates a guest session for a client with a unique ID.
+func CreateGuestSession(ctx context.Context, store datastore.Store, client string) (*GuestSession, error) {
+	g := &GuestSession{Client: client, Created: time.Now()}
+	for {
+		g.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeGuestSession, g.ID), g)
+		if err == nil {
+			return g, nil
+		} else if err != datastore.ErrEntityExists {
+			return nil, fmt.Errorf("could not create guest session: %w", err)
+		}
+	}
+}
+
+// GetGuestSession gets a guest session.
+func GetGuestSession(ctx context.Context, store datastore.Store, id int64) (*GuestSession, error) {
+	g := new(GuestSession)
+	err := store.Get(ctx, store.IDKey(typeGuestSession, id), g)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// GetGuestByClient returns the latest guest session created by a
+// client since the given time, or datastore.ErrNoSuchEntity if there
+// is none.
+func GetGuestByClient(ctx context.Context, store datastore.Store, client string, since time.Time) (*GuestSession, error) {
+	q := store.NewQuery(typeGuestSession, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Client =", client)
+	}
+	var all []GuestSession
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var latest *GuestSession
+	for i, g := range all {
+		if g.Client != client || g.Created.Before(since) {
+			continue
+		}
+		if latest == nil || g.Created.After(latest.Created) {
+			latest = &all[i]
+		}
+	}
+	if latest == nil {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	return latest, nil
+}
+
+// GrantGuestViewing grants a guest up to d of viewing time from
+// their allowance, returning the time granted, which is zero once the
+// allowance is used up.
+func GrantGuestViewing(ctx context.Context, store datastore.Store, id int64, d, allowance time.Duration) (time.Duration, error) {
+	var granted time.Duration
+	err := store.Update(ctx, store.IDKey(typeGuestSession, id), func(e datastore.Entity) {
+		granted = 0
+		g, ok := e.(*GuestSession)
+		if !ok {
+			return
+		}
+		remaining := allowance - time.Duration(g.Viewed)*time.Second
+		granted = min(d, remaining.Truncate(time.Second))
+		if granted <= 0 {
+			granted = 0
+			return
+		}
+		g.Viewed += int64(granted / time.Second)
+	}, new(GuestSession))
+	return granted, err
+}
+
+// ConvertGuest records that a guest signed up as the given subscriber.
+// Guests only convert once.
+func ConvertGuest(ctx context.Context, store datastore.Store, id, sid int64) error {
+	return store.Update(ctx, store.IDKey(typeGuestSession, id), func(e datastore.Entity) {
+		g, ok := e.(*GuestSession)
+		if !ok || g.Subscriber != 0 {
+			return
+		}
+		g.Subscriber = sid
+		g.Converted = time.Now()
+	}, new(GuestSession))
+}
+
+// FunnelEvent records a step in the conversion funnel of a guest or
+// subscriber.
+type FunnelEvent struct {
+	ID         int64     // Event ID.
+	Event      string    // Event, e.g., "guest" or "subscribe".
+	Guest      int64     // Guest ID, if known.
+	Subscriber int64     // Subscriber ID, if known.
+	Feed       int64     // Feed ID, for view events.
+	Created    time.Time // Date/time of the event.
+}
+
+// Copy copies a funnel event to dst, or returns a copy of the funnel event when dst is nil.
+func (f *FunnelEvent) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var f2 *FunnelEvent
+	if dst == nil {
+		f2 = new(FunnelEvent)
+	} else {
+		var ok bool
+		f2, ok = dst.(*FunnelEvent)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*f2 = *f
+	return f2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (f *FunnelEvent) GetCache() datastore.Cache {
+	return nil
+}
+
+// CreateFunnelEvent records a funnel event with a unique ID.
+func CreateFunnelEvent(ctx context.Context, store datastore.Store, f *FunnelEvent) error {
+	f.Created = time.Now()
+	for {
+		f.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeFunnelEvent, f.ID), f)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create funnel event: %w", err)
+		}
+	}
+}
+
+// CreateFunnelEventOnce records a funnel event unless the same event
+// has already been recorded for the guest or subscriber and feed, e.g.,
+// so that a guest viewing a feed is counted once no matter how many
+// playback tokens they are issued. The ID is derived from these.
+func CreateFunnelEventOnce(ctx context.Context, store datastore.Store, f *FunnelEvent) error {
+	h := fnv.New64a()
+	h.Write([]byte(f.Event + "." + strconv.FormatInt(f.Guest, 10) + "." + strconv.FormatInt(f.Subscriber, 10) + "." + strconv.FormatInt(f.Feed, 10)))
+	f.ID = int64(h.Sum64() &^ (1 << 63))
+	f.Created = time.Now()
+	err := store.Create(ctx, store.IDKey(typeFunnelEvent, f.ID), f)
+	if err != nil && !errors.Is(err, datastore.ErrEntityExists) {
+		return fmt.Errorf("could not create funnel event: %w", err)
+	}
+	return nil
+}
+
+// FunnelMetrics summarizes the conversion funnel over a period.
+type FunnelMetrics struct {
+	Since  time.Time        `json:"since"`  // Start of the period.
+	Counts map[string]int64 `json:"counts"` // Number of events by event.
+	Guests map[string]int64 `json:"guests"` // Number of distinct guests reaching each event.
+}
+
+// GetFunnelMetrics returns the funnel metrics for events since the
+// given time. Signup and subscribe events carry the guest ID of
+// converted guests, so that Guests measures how many guests converted.
+func GetFunnelMetrics(ctx context.Context, store datastore.Store, since time.Time) (*FunnelMetrics, error) {
+	q := store.NewQuery(typeFunnelEvent, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Created >=", since)
+	}
+	var all []FunnelEvent
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	m := &FunnelMetrics{Since: since, Counts: make(map[string]int64), Guests: make(map[string]int64)}
+	guests := make(map[string]map[int64]bool)
+	for _, f := range all {
+		if f.Created.Before(since) {
+			continue
+		}
+		m.Counts[f.Event]++
+		if f.Guest == 0 {
+			continue
+		}
+		if guests[f.Event] == nil {
+			guests[f.Event] = make(map[int64]bool)
+		}
+		guests[f.Event][f.Guest] = true
+	}
+	for ev, ids := range guests {
+		m.Guests[ev] = int64(len(ids))
+	}
+	return m, nil
+}
+
+// GetGuestBySubscriber returns the guest session that converted to
+// the given subscriber, or datastore.ErrNoSuchEntity if there is none.
+func GetGuestBySubscriber(ctx context.Context, store datastore.Store, sid int64) (*GuestSession, error) {
+	q := store.NewQuery(typeGuestSession, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Subscriber =", sid)
+	}
+	var all []GuestSession
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range all {
+		if g.Subscriber == sid {
+			return &g, nil
+		}
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
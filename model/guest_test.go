@@ -0,0 +1,102 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestGuestSessions tests guest viewing allowances, conversion and funnel metrics.
+func TestGuestSessions(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	g, err := CreateGuestSession(ctx, store, "client1")
+	if err != nil {
+		t.Fatalf("CreateGuestSession returned error: %v", err)
+	}
+	got, err := GetGuestByClient(ctx, store, "client1", time.Now().Add(-time.Hour))
+	if err != nil || got.ID != g.ID {
+		t.Errorf("GetGuestByClient returned %+v, %v, expected guest %d", got, err, g.ID)
+	}
+	_, err = GetGuestByClient(ctx, store, "client2", time.Now().Add(-time.Hour))
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetGuestByClient for another client returned %v, expected %v", err, datastore.ErrNoSuchEntity)
+	}
+
+	const allowance = 10 * time.Minute
+	for _, want := range []time.Duration{4 * time.Minute, 4 * time.Minute, 2 * time.Minute, 0} {
+		got, err := GrantGuestViewing(ctx, store, g.ID, 4*time.Minute, allowance)
+		if err != nil {
+			t.Fatalf("GrantGuestViewing returned error: %v", err)
+		}
+		if got != want {
+			t.Errorf("GrantGuestViewing granted %v, expected %v", got, want)
+		}
+	}
+
+	for _, sid := range []int64{7, 8} {
+		err = ConvertGuest(ctx, store, g.ID, sid)
+		if err != nil {
+			t.Fatalf("ConvertGuest returned error: %v", err)
+		}
+	}
+	got, err = GetGuestBySubscriber(ctx, store, 7)
+	if err != nil {
+		t.Fatalf("GetGuestBySubscriber returned error: %v", err)
+	}
+	if got.ID != g.ID || got.Converted.IsZero() {
+		t.Errorf("GetGuestBySubscriber returned %+v, expected guest %d converted once", got, g.ID)
+	}
+
+	for _, f := range []FunnelEvent{
+		{Event: FunnelGuest, Guest: g.ID},
+		{Event: FunnelView, Guest: g.ID, Feed: 1},
+		{Event: FunnelView, Guest: g.ID, Feed: 2},
+		{Event: FunnelSignup, Guest: g.ID, Subscriber: 7},
+		{Event: FunnelSubscribe, Subscriber: 9},
+	} {
+		err = CreateFunnelEvent(ctx, store, &f)
+		if err != nil {
+			t.Fatalf("CreateFunnelEvent returned error: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		err = CreateFunnelEventOnce(ctx, store, &FunnelEvent{Event: FunnelView, Guest: g.ID, Feed: 3})
+		if err != nil {
+			t.Fatalf("CreateFunnelEventOnce returned error: %v", err)
+		}
+	}
+	m, err := GetFunnelMetrics(ctx, store, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetFunnelMetrics returned error: %v", err)
+	}
+	if m.Counts[FunnelView] != 3 || m.Guests[FunnelView] != 1 || m.Counts[FunnelSubscribe] != 1 || m.Guests[FunnelSubscribe] != 0 {
+		t.Errorf("GetFunnelMetrics returned %+v", m)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+DESCRIPTION
+  Composite index manifest and query helpers.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// ErrMissingIndex is returned when a query fails because the
+// composite index it requires does not exist.
+var ErrMissingIndex = errors.New("missing composite index")
+
+// Index represents a composite datastore index, i.e., an index on
+// multiple properties. Queries that use an index filter for equality
+// on all but the last property, and order by (or filter by a range
+// of) the last property, which is in descending order when prefixed
+// with "-".
+type Index struct {
+	Kind       string
+	Properties []string
+}
+
+// String returns an index in the form Kind(Property1, Property2, ...).
+func (idx Index) String() string {
+	return idx.Kind + "(" + strings.Join(idx.Properties, ", ") + ")"
+}
+
+// YAML returns an index in index.yaml format.
+func (idx Index) YAML() string {
+	s := "- kind: " + idx.Kind + "\n  properties:\n"
+	for _, p := range idx.Properties {
+		if strings.HasPrefix(p, "-") {
+			s += "  - name: " + p[1:] + "\n    direction: desc\n"
+			continue
+		}
+		s += "  - name: " + p + "\n"
+	}
+	return s
+}
+
+// Composite indexes required by model queries.
+var (
+	idxActuatorSite      = Index{typeActuator, []string{"Skey", "Aid"}}
+	idxAlertDue          = Index{typeAlert, []string{"Acked", "Escalated"}}
+	idxBroadcastArchive  = Index{typeBroadcastArchive, []string{"Skey", "ActualStart"}}
+	idxAnnotation        = Index{typeAnnotation, []string{"MID", "Timestamp"}}
+	idxCredentialMID     = Index{typeCredential, []string{"MID", "Name"}}
+	idxCredentialName    = Index{typeCredential, []string{"Name", "MID"}}
+	idxCronRun           = Index{typeCronRun, []string{"Skey", "CronID", "-Scheduled"}}
+	idxCronSite          = Index{typeCron, []string{"Skey", "ID"}}
+	idxDataUsage         = Index{typeDataUsage, []string{"Skey", "Date"}}
+	idxDeviceSite        = Index{typeDevice, []string{"Skey", "Name"}}
+	idxLoginEvent        = Index{typeLoginEvent, []string{"Email", "Created"}}
+	idxMtsMedia          = Index{typeMtsMedia, []string{"MID", "Timestamp"}}
+	idxMtsMediaGeohash   = Index{typeMtsMedia, []string{"MID", "Geohash", "Timestamp"}}
+	idxMtsMediaLatest    = Index{typeMtsMedia, []string{"MID", "-Timestamp"}}
+	idxNotification      = Index{typeNotification, []string{"Skey", "-Created"}}
+	idxScalar            = Index{typeScalar, []string{"ID", "Timestamp"}}
+	idxSensorSite        = Index{typeSensor, []string{"skey", "sid"}}
+	idxText              = Index{typeText, []string{"MID", "Timestamp"}}
+	idxTextType          = Index{typeText, []string{"MID", "Type", "Timestamp"}}
+	idxTrackPoint        = Index{typeTrackPoint, []string{"Skey", "Timestamp"}}
+	idxVariableSite      = Index{typeVariable, []string{"Skey", "Name"}}
+	idxVariableSiteScope = Index{typeVariable, []string{"Skey", "Scope", "Name"}}
+	idxViewerSample      = Index{typeViewerSample, []string{"VideoID", "Timestamp"}}
+)
+
+// Indexes is the manifest of composite indexes required by model
+// queries, which must be kept in sync with index.yaml.
+var Indexes = []Index{
+	idxActuatorSite,
+	idxAlertDue,
+	idxBroadcastArchive,
+	idxAnnotation,
+	idxCredentialMID,
+	idxCredentialName,
+	idxCronRun,
+	idxCronSite,
+	idxDataUsage,
+	idxDeviceSite,
+	idxLoginEvent,
+	idxMtsMedia,
+	idxMtsMediaGeohash,
+	idxMtsMediaLatest,
+	idxNotification,
+	idxScalar,
+	idxSensorSite,
+	idxText,
+	idxTextType,
+	idxTrackPoint,
+	idxVariableSite,
+	idxVariableSiteScope,
+	idxViewerSample,
+}
+
+// isMissingIndex returns true if err is a datastore error caused by a
+// missing composite index.
+func isMissingIndex(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no matching index found")
+}
+
+// getAll is like store.GetAll but fails with an error wrapping
+// ErrMissingIndex, which names the required index, when the query
+// fails because the index does not exist.
+func getAll(ctx context.Context, store datastore.Store, q datastore.Query, dst interface{}, idx Index) ([]*datastore.Key, error) {
+	keys, err := store.GetAll(ctx, q, dst)
+	if isMissingIndex(err) {
+		return nil, fmt.Errorf("%w: %s is required, see model.Indexes: %v", ErrMissingIndex, idx, err)
+	}
+	return keys, err
+}
+
+// VerifyIndexes checks that the given indexes exist by running a
+// probe query for each, returning the indexes that are missing. Probe
+// queries return no results, so are cheap. Since the FileStore does
+// not use indexes, all indexes are deemed to exist.
+func VerifyIndexes(ctx context.Context, store datastore.Store, indexes []Index) ([]Index, error) {
+	if _, filestore := store.(*datastore.FileStore); filestore {
+		return nil, nil
+	}
+	var missing []Index
+	for _, idx := range indexes {
+		n := len(idx.Properties)
+		q := store.NewQuery(idx.Kind, true, idx.Properties...)
+		for _, p := range idx.Properties[:n-1] {
+			q.FilterField(p, "=", int64(-1))
+		}
+		q.Order(idx.Properties[n-1])
+		q.Limit(1)
+		_, err := store.GetAll(ctx, q, nil)
+		switch {
+		case isMissingIndex(err):
+			missing = append(missing, idx)
+		case err != nil:
+			return nil, fmt.Errorf("could not verify index %s: %w", idx, err)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,82 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// unindexedStore is a FileStore whose queries for the given kinds
+// fail as if their indexes are missing.
+type unindexedStore struct {
+	*datastore.FileStore
+	kinds map[string]bool
+}
+
+// unindexedQuery is a FileStore query which records its kind.
+type unindexedQuery struct {
+	datastore.Query
+	kind string
+}
+
+// NewQuery returns a new FileStore query which records its kind.
+func (s *unindexedStore) NewQuery(kind string, keysOnly bool, keyParts ...string) datastore.Query {
+	return &unindexedQuery{Query: s.FileStore.NewQuery(kind, keysOnly, keyParts...), kind: kind}
+}
+
+// GetAll fails for unindexed kinds, otherwise gets from the FileStore.
+func (s *unindexedStore) GetAll(ctx context.Context, q datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+	uq := q.(*unindexedQuery)
+	if s.kinds[uq.kind] {
+		return nil, errors.New("rpc error: code = FailedPrecondition desc = no matching index found. recommended index is: ...")
+	}
+	return s.FileStore.GetAll(ctx, uq.Query, dst)
+}
+
+// TestIndexes tests detection of missing indexes.
+func TestIndexes(t *testing.T) {
+	ctx := context.Background()
+	fs, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+	store := &unindexedStore{FileStore: fs.(*datastore.FileStore), kinds: map[string]bool{typeVariable: true}}
+
+	_, err = GetVariablesBySite(ctx, store, 1, "")
+	if !errors.Is(err, ErrMissingIndex) {
+		t.Errorf("GetVariablesBySite returned %v, expected %v", err, ErrMissingIndex)
+	}
+	_, err = GetCronsBySite(ctx, store, 1)
+	if err != nil {
+		t.Errorf("GetCronsBySite returned error: %v", err)
+	}
+
+	missing, err := VerifyIndexes(ctx, store, Indexes)
+	if err != nil {
+		t.Fatalf("VerifyIndexes returned error: %v", err)
+	}
+	if len(missing) != 2 || missing[0].Kind != typeVariable || missing[1].Kind != typeVariable {
+		t.Errorf("VerifyIndexes returned %v, expected Variable indexes", missing)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+DESCRIPTION
+  Invite datastore type and functions, which implement invitation of
+  users to sites.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeInvite = "Invite" // Invite datastore type.
+
+// Invite statuses.
+const (
+	InvitePending  = "pending"
+	InviteExpired  = "expired"
+	InviteAccepted = "accepted"
+)
+
+// Invite errors.
+var (
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrInviteExpired  = errors.New("invite expired")
+	ErrInviteAccepted = errors.New("invite already accepted")
+	ErrInviteEmail    = errors.New("invite is for a different email address")
+)
+
+// Invite represents an invitation for a user to join a site with the
+// given permissions. The user is created when the invitee accepts the
+// invite by following an emailed link and logging in with the invited
+// email address. Only a hash of the link's token is stored. The key is
+// the hash.
+type Invite struct {
+	Hash     string    // Hex-encoded SHA-256 hash of the token.
+	Skey     int64     // Site key.
+	Email    string    // Email address of the invitee.
+	Perm     int64     // Permissions granted on acceptance.
+	Inviter  string    // Email address of the inviter.
+	Created  time.Time // Date/time created.
+	Expires  time.Time // Date/time expires.
+	Accepted time.Time // Date/time accepted, or zero.
+}
+
+// Copy copies an invite to dst, or returns a copy of the invite when dst is nil.
+func (inv *Invite) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var inv2 *Invite
+	if dst == nil {
+		inv2 = new(Invite)
+	} else {
+		var ok bool
+		inv2, ok = dst.(*Invite)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*inv2 = *inv
+	return inv2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (inv *Invite) GetCache() datastore.Cache {
+	return nil
+}
+
+// Status returns the status of an invite, i.e., InvitePending,
+// InviteExpired or InviteAccepted.
+func (inv *Invite) Status() string {
+	switch {
+	case !inv.Accepted.IsZero():
+		return InviteAccepted
+	case time.Now().After(inv.Expires):
+		return InviteExpired
+	default:
+		return InvitePending
+	}
+}
+
+// CreateInvite creates an invite which expires after ttl, returning
+// the token for the invitee's link. Email addresses are compared
+// without regard to case.
+func CreateInvite(ctx context.Context, store datastore.Store, inv *Invite, ttl time.Duration) (string, error) {
+	token, hash, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	inv.Hash = hash
+	inv.Email = strings.ToLower(inv.Email)
+	inv.Created = time.Now()
+	inv.Expires = inv.Created.Add(ttl)
+	err = store.Create(ctx, store.NameKey(typeInvite, hash), inv)
+	if err != nil {
+		return "", fmt.Errorf("could not create invite: %w", err)
+	}
+	return token, nil
+}
+
+// GetInvite returns the invite with the given token.
+func GetInvite(ctx context.Context, store datastore.Store, token string) (*Invite, error) {
+	inv := new(Invite)
+	err := store.Get(ctx, store.NameKey(typeInvite, tokenHash(token)), inv)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// GetInvitesBySite returns the invites for a site, most recent first.
+func GetInvitesBySite(ctx context.Context, store datastore.Store, skey int64) ([]Invite, error) {
+	q := store.NewQuery(typeInvite, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+	}
+	var all []Invite
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var invites []Invite
+	for _, inv := range all {
+		if inv.Skey == skey {
+			invites = append(invites, inv)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].Created.After(invites[j].Created) })
+	return invites, nil
+}
+
+// AcceptInvite accepts the invite with the given token on behalf of
+// the user with the given email address, and creates the user. It
+// returns ErrInviteNotFound, ErrInviteExpired, ErrInviteAccepted or
+// ErrInviteEmail if the invite cannot be accepted. An existing user
+// for the site gains the invite's permissions.
+func AcceptInvite(ctx context.Context, store datastore.Store, token, email string) (*Invite, error) {
+	inv := new(Invite)
+	var acceptErr error
+	err := store.Update(ctx, store.NameKey(typeInvite, tokenHash(token)), func(e datastore.Entity) {
+		acceptErr = nil
+		inv2, ok := e.(*Invite)
+		if !ok {
+			acceptErr = datastore.ErrWrongType
+			return
+		}
+		switch inv2.Status() {
+		case InviteAccepted:
+			acceptErr = ErrInviteAccepted
+			return
+		case InviteExpired:
+			acceptErr = ErrInviteExpired
+			return
+		}
+		if !strings.EqualFold(inv2.Email, email) {
+			acceptErr = ErrInviteEmail
+			return
+		}
+		inv2.Accepted = time.Now()
+	}, inv)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if acceptErr != nil {
+		return nil, acceptErr
+	}
+
+	user, err := GetUser(ctx, store, inv.Skey, email)
+	switch {
+	case err == nil:
+		user.Perm |= inv.Perm
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+		user = &User{Skey: inv.Skey, Email: email, Perm: inv.Perm, Created: time.Now()}
+	default:
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	err = PutUser(ctx, store, user)
+	if err != nil {
+		return nil, fmt.Errorf("could not put user: %w", err)
+	}
+	return inv, nil
+}
+
+// DeleteInvite deletes the invite with the given hash, e.g., to revoke
+// a pending invite.
+func DeleteInvite(ctx context.Context, store datastore.Store, hash string) error {
+	return store.Delete(ctx, store.NameKey(typeInvite, hash))
+}
@@ -0,0 +1,102 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestInvites tests creating, accepting and deleting invites.
+func TestInvites(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const skey = 1
+	inv := &Invite{Skey: skey, Email: "Invitee@example.com", Perm: ReadPermission | WritePermission, Inviter: "admin@ausocean.org"}
+	tok, err := CreateInvite(ctx, store, inv, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInvite returned error: %v", err)
+	}
+	expired := &Invite{Skey: skey, Email: "late@example.com", Perm: ReadPermission}
+	expiredTok, err := CreateInvite(ctx, store, expired, -time.Minute)
+	if err != nil {
+		t.Fatalf("CreateInvite returned error: %v", err)
+	}
+
+	invites, err := GetInvitesBySite(ctx, store, skey)
+	if err != nil {
+		t.Fatalf("GetInvitesBySite returned error: %v", err)
+	}
+	if len(invites) != 2 {
+		t.Fatalf("GetInvitesBySite returned %d invites, expected 2", len(invites))
+	}
+	if invites[0].Status() != InviteExpired || invites[1].Status() != InvitePending {
+		t.Errorf("GetInvitesBySite returned statuses %s and %s", invites[0].Status(), invites[1].Status())
+	}
+
+	_, err = AcceptInvite(ctx, store, tok, "someone@example.com")
+	if !errors.Is(err, ErrInviteEmail) {
+		t.Errorf("accepting with wrong email returned %v, expected %v", err, ErrInviteEmail)
+	}
+	_, err = AcceptInvite(ctx, store, expiredTok, "late@example.com")
+	if !errors.Is(err, ErrInviteExpired) {
+		t.Errorf("accepting expired invite returned %v, expected %v", err, ErrInviteExpired)
+	}
+	_, err = AcceptInvite(ctx, store, "bogus", "invitee@example.com")
+	if !errors.Is(err, ErrInviteNotFound) {
+		t.Errorf("accepting unknown invite returned %v, expected %v", err, ErrInviteNotFound)
+	}
+
+	got, err := AcceptInvite(ctx, store, tok, "invitee@example.com")
+	if err != nil {
+		t.Fatalf("AcceptInvite returned error: %v", err)
+	}
+	if got.Status() != InviteAccepted {
+		t.Errorf("accepted invite has status %s", got.Status())
+	}
+	user, err := GetUser(ctx, store, skey, "invitee@example.com")
+	if err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if user.Perm != ReadPermission|WritePermission {
+		t.Errorf("user has permissions %d, expected %d", user.Perm, ReadPermission|WritePermission)
+	}
+	_, err = AcceptInvite(ctx, store, tok, "invitee@example.com")
+	if !errors.Is(err, ErrInviteAccepted) {
+		t.Errorf("accepting invite twice returned %v, expected %v", err, ErrInviteAccepted)
+	}
+
+	err = DeleteInvite(ctx, store, expired.Hash)
+	if err != nil {
+		t.Fatalf("DeleteInvite returned error: %v", err)
+	}
+	_, err = GetInvite(ctx, store, expiredTok)
+	if !errors.Is(err, ErrInviteNotFound) {
+		t.Errorf("getting deleted invite returned %v, expected %v", err, ErrInviteNotFound)
+	}
+}
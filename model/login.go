@@ -0,0 +1,115 @@
+/*
+DESCRIPTION
+  LoginEvent datastore type and functions, which audit user logins.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeLoginEvent = "LoginEvent" // LoginEvent datastore type.
+
+// LoginEvent records a successful or failed completion of the OAuth
+// login flow. The email is empty for failures that occur before the
+// user is known.
+type LoginEvent struct {
+	ID           int64     // Login event ID.
+	Email        string    // User's email address, if known.
+	Project      string    // Project ID of the service logged in to.
+	IP           string    // Client IP address.
+	UserAgent    string    `datastore:",noindex"` // Client user agent.
+	Location     string    // Client location, e.g., "Adelaide, sa, AU", or the IP address if unknown.
+	Region       string    `datastore:",noindex"` // Client region, e.g., "sa, AU", or the IP address if unknown.
+	Success      bool      // True if the login succeeded.
+	Error        string    `datastore:",noindex"` // Failure reason, if any.
+	Created      time.Time // Date/time created.
+	Impersonator string    `datastore:",noindex"` // Email address of the admin impersonating the user, if any.
+	Reason       string    `datastore:",noindex"` // Reason for the impersonation, if any.
+}
+
+// Copy copies a login event to dst, or returns a copy of the login event when dst is nil.
+func (e *LoginEvent) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var e2 *LoginEvent
+	if dst == nil {
+		e2 = new(LoginEvent)
+	} else {
+		var ok bool
+		e2, ok = dst.(*LoginEvent)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*e2 = *e
+	return e2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (e *LoginEvent) GetCache() datastore.Cache {
+	return nil
+}
+
+// CreateLoginEvent creates a login event with a unique ID.
+func CreateLoginEvent(ctx context.Context, store datastore.Store, e *LoginEvent) error {
+	e.Created = time.Now()
+	for {
+		e.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeLoginEvent, e.ID), e)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create login event: %w", err)
+		}
+	}
+}
+
+// GetLoginEvents returns the login events for a user, or for all
+// users if email is empty, created at or after since and before until,
+// newest first.
+func GetLoginEvents(ctx context.Context, store datastore.Store, email string, since, until time.Time) ([]LoginEvent, error) {
+	q := store.NewQuery(typeLoginEvent, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		if email != "" {
+			q.Filter("Email =", email)
+		}
+		q.Filter("Created >=", since)
+		q.Filter("Created <", until)
+	}
+	var all []LoginEvent
+	_, err := getAll(ctx, store, q, &all, idxLoginEvent)
+	if err != nil {
+		return nil, err
+	}
+	var es []LoginEvent
+	for _, e := range all {
+		if (email == "" || e.Email == email) && !e.Created.Before(since) && e.Created.Before(until) {
+			es = append(es, e)
+		}
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].Created.After(es[j].Created) })
+	return es, nil
+}
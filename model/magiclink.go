@@ -0,0 +1,178 @@
+/*
+DESCRIPTION
+  MagicLink datastore type and functions, which implement login by
+  emailed link for users without Google accounts.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeMagicLink = "MagicLink" // MagicLink datastore type.
+
+// Magic link errors.
+var (
+	ErrLinkNotFound = errors.New("login link not found")
+	ErrLinkExpired  = errors.New("login link expired")
+	ErrLinkUsed     = errors.New("login link already used")
+)
+
+// MagicLink represents a single-use login link emailed to a user,
+// which verifies their email address when followed. Only a hash of
+// the link's token is stored, so that stored links cannot be used to
+// log in. The key is the hash.
+type MagicLink struct {
+	Hash     string    // Hex-encoded SHA-256 hash of the token.
+	Email    string    // Email address the link was sent to.
+	Redirect string    // Relative URL to redirect to after login.
+	Created  time.Time // Date/time created.
+	Expires  time.Time // Date/time expires.
+	Used     time.Time // Date/time used, or zero.
+}
+
+// Copy copies a magic link to dst, or returns a copy of the magic link when dst is nil.
+func (l *MagicLink) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var l2 *MagicLink
+	if dst == nil {
+		l2 = new(MagicLink)
+	} else {
+		var ok bool
+		l2, ok = dst.(*MagicLink)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*l2 = *l
+	return l2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (l *MagicLink) GetCache() datastore.Cache {
+	return nil
+}
+
+// randomToken returns a random URL-safe token and its hash, so that
+// tokens can be stored by hash rather than in the clear.
+func randomToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", fmt.Errorf("could not generate token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, tokenHash(token), nil
+}
+
+// tokenHash returns the hex-encoded SHA-256 hash of a token.
+func tokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateMagicLink creates a magic link for an email address which
+// expires after ttl, returning the link's token, which is a random
+// URL-safe string.
+func CreateMagicLink(ctx context.Context, store datastore.Store, email, redirect string, ttl time.Duration) (string, error) {
+	token, hash, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	l := &MagicLink{Hash: hash, Email: email, Redirect: redirect, Created: now, Expires: now.Add(ttl)}
+	err = store.Create(ctx, store.NameKey(typeMagicLink, l.Hash), l)
+	if err != nil {
+		return "", fmt.Errorf("could not create magic link: %w", err)
+	}
+	return token, nil
+}
+
+// UseMagicLink uses the magic link with the given token, returning the
+// link if it is valid, or ErrLinkNotFound, ErrLinkExpired or
+// ErrLinkUsed otherwise. Links can only be used once.
+func UseMagicLink(ctx context.Context, store datastore.Store, token string) (*MagicLink, error) {
+	l := new(MagicLink)
+	var useErr error
+	err := store.Update(ctx, store.NameKey(typeMagicLink, tokenHash(token)), func(e datastore.Entity) {
+		useErr = nil
+		l2, ok := e.(*MagicLink)
+		if !ok {
+			useErr = datastore.ErrWrongType
+			return
+		}
+		now := time.Now()
+		switch {
+		case !l2.Used.IsZero():
+			useErr = ErrLinkUsed
+			return
+		case now.After(l2.Expires):
+			useErr = ErrLinkExpired
+			return
+		}
+		l2.Used = now
+	}, l)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, ErrLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if useErr != nil {
+		return nil, useErr
+	}
+	return l, nil
+}
+
+// DeleteExpiredMagicLinks deletes magic links that expired before t,
+// whether or not they were used, returning the number deleted.
+func DeleteExpiredMagicLinks(ctx context.Context, store datastore.Store, t time.Time) (int, error) {
+	_, filestore := store.(*datastore.FileStore)
+	if filestore {
+		var ls []MagicLink
+		keys, err := store.GetAll(ctx, store.NewQuery(typeMagicLink, false), &ls)
+		if err != nil {
+			return 0, fmt.Errorf("could not get magic links: %w", err)
+		}
+		var expired []*datastore.Key
+		for i := range ls {
+			if ls[i].Expires.Before(t) {
+				expired = append(expired, keys[i])
+			}
+		}
+		return len(expired), store.DeleteMulti(ctx, expired)
+	}
+
+	q := store.NewQuery(typeMagicLink, true)
+	q.Filter("Expires <", t)
+	keys, err := store.GetAll(ctx, q, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not get expired magic links: %w", err)
+	}
+	return len(keys), store.DeleteMulti(ctx, keys)
+}
@@ -0,0 +1,78 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestMagicLinks tests creating and using magic links.
+func TestMagicLinks(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	tok, err := CreateMagicLink(ctx, store, "viewer@example.com", "/watch", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateMagicLink returned error: %v", err)
+	}
+	l, err := UseMagicLink(ctx, store, tok)
+	if err != nil {
+		t.Fatalf("UseMagicLink returned error: %v", err)
+	}
+	if l.Email != "viewer@example.com" || l.Redirect != "/watch" || l.Used.IsZero() {
+		t.Errorf("UseMagicLink returned %+v", l)
+	}
+
+	_, err = UseMagicLink(ctx, store, tok)
+	if !errors.Is(err, ErrLinkUsed) {
+		t.Errorf("reusing link returned %v, expected %v", err, ErrLinkUsed)
+	}
+	_, err = UseMagicLink(ctx, store, "bogus")
+	if !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("using unknown link returned %v, expected %v", err, ErrLinkNotFound)
+	}
+
+	tok, err = CreateMagicLink(ctx, store, "viewer@example.com", "", -time.Minute)
+	if err != nil {
+		t.Fatalf("CreateMagicLink returned error: %v", err)
+	}
+	_, err = UseMagicLink(ctx, store, tok)
+	if !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("using expired link returned %v, expected %v", err, ErrLinkExpired)
+	}
+
+	// Only the expired link is deleted.
+	n, err := DeleteExpiredMagicLinks(ctx, store, time.Now())
+	if err != nil || n != 1 {
+		t.Errorf("DeleteExpiredMagicLinks returned %d, %v, expected 1", n, err)
+	}
+	_, err = UseMagicLink(ctx, store, tok)
+	if !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("using deleted link returned %v, expected %v", err, ErrLinkNotFound)
+	}
+}
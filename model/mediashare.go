@@ -0,0 +1,169 @@
+/*
+DESCRIPTION
+  MediaShare datastore type and functions, which implement sharing of
+  specific media clips with users who are not logged in.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeMediaShare = "MediaShare" // MediaShare datastore type.
+
+// Media share errors.
+var (
+	ErrShareExpired = errors.New("share expired")
+	ErrShareRevoked = errors.New("share revoked")
+	ErrShareScope   = errors.New("media is outside the scope of the share")
+)
+
+// MediaShare represents a share of media for a single MID within a
+// time range, which permits anyone with the share's token to get the
+// media until the share expires or is revoked. Tokens are signed by
+// the client application and identify the share, so that shares can
+// be revoked. The key is the ID.
+type MediaShare struct {
+	ID      int64     // Share ID.
+	Skey    int64     // Site key of the media's device.
+	MID     int64     // Media ID.
+	Start   int64     // Start of the shared time range, as a Unix time.
+	End     int64     // End of the shared time range (inclusive), as a Unix time.
+	Note    string    // Note describing the share, e.g., who it is for.
+	Creator string    // Email address of the user who created the share.
+	Created time.Time // Date/time created.
+	Expires time.Time // Date/time expires.
+	Revoked time.Time // Date/time revoked, or zero.
+}
+
+// Copy copies a media share to dst, or returns a copy of the media share when dst is nil.
+func (s *MediaShare) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var s2 *MediaShare
+	if dst == nil {
+		s2 = new(MediaShare)
+	} else {
+		var ok bool
+		s2, ok = dst.(*MediaShare)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*s2 = *s
+	return s2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (s *MediaShare) GetCache() datastore.Cache {
+	return nil
+}
+
+// Check returns nil if the share permits getting the media with the
+// given MID and timestamps at t, or ErrShareExpired, ErrShareRevoked
+// or ErrShareScope otherwise. Timestamps are either a single timestamp
+// or a start and end, all of which must be within the share's time
+// range.
+func (s *MediaShare) Check(mid int64, ts []int64, t time.Time) error {
+	switch {
+	case !s.Revoked.IsZero():
+		return ErrShareRevoked
+	case t.After(s.Expires):
+		return ErrShareExpired
+	case mid != s.MID || len(ts) == 0 || len(ts) > 2:
+		return ErrShareScope
+	}
+	for _, ts := range ts {
+		if ts < s.Start || ts > s.End {
+			return ErrShareScope
+		}
+	}
+	return nil
+}
+
+// CreateMediaShare creates a media share which expires after ttl,
+// assigning it a random ID.
+func CreateMediaShare(ctx context.Context, store datastore.Store, s *MediaShare, ttl time.Duration) error {
+	s.Created = time.Now()
+	s.Expires = s.Created.Add(ttl)
+	for {
+		s.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeMediaShare, s.ID), s)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create media share: %w", err)
+		}
+	}
+}
+
+// GetMediaShare returns the media share with the given ID.
+func GetMediaShare(ctx context.Context, store datastore.Store, id int64) (*MediaShare, error) {
+	s := new(MediaShare)
+	err := store.Get(ctx, store.IDKey(typeMediaShare, id), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetMediaSharesBySite returns the media shares for a site, most
+// recent first.
+func GetMediaSharesBySite(ctx context.Context, store datastore.Store, skey int64) ([]MediaShare, error) {
+	q := store.NewQuery(typeMediaShare, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+	}
+	var all []MediaShare
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var shares []MediaShare
+	for _, s := range all {
+		if s.Skey == skey {
+			shares = append(shares, s)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Created.After(shares[j].Created) })
+	return shares, nil
+}
+
+// RevokeMediaShare revokes the media share with the given ID, after
+// which its token no longer permits getting media.
+func RevokeMediaShare(ctx context.Context, store datastore.Store, id int64) error {
+	return store.Update(ctx, store.IDKey(typeMediaShare, id), func(e datastore.Entity) {
+		s, ok := e.(*MediaShare)
+		if ok && s.Revoked.IsZero() {
+			s.Revoked = time.Now()
+		}
+	}, &MediaShare{})
+}
+
+// DeleteMediaShare deletes the media share with the given ID.
+func DeleteMediaShare(ctx context.Context, store datastore.Store, id int64) error {
+	return store.Delete(ctx, store.IDKey(typeMediaShare, id))
+}
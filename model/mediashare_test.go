@@ -0,0 +1,95 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestMediaShares tests creating, checking and revoking media shares.
+func TestMediaShares(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const (
+		skey  = 1
+		mid   = 123
+		start = 1700000000
+		end   = start + 60
+	)
+	s := &MediaShare{Skey: skey, MID: mid, Start: start, End: end, Note: "for review", Creator: "admin@ausocean.org"}
+	err = CreateMediaShare(ctx, store, s, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateMediaShare returned error: %v", err)
+	}
+	err = CreateMediaShare(ctx, store, &MediaShare{Skey: skey + 1, MID: mid}, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateMediaShare returned error: %v", err)
+	}
+
+	shares, err := GetMediaSharesBySite(ctx, store, skey)
+	if err != nil {
+		t.Fatalf("GetMediaSharesBySite returned error: %v", err)
+	}
+	if len(shares) != 1 || shares[0].ID != s.ID {
+		t.Fatalf("GetMediaSharesBySite returned %v, expected share %d only", shares, s.ID)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		mid  int64
+		ts   []int64
+		t    time.Time
+		want error
+	}{
+		{mid, []int64{start}, now, nil},
+		{mid, []int64{start, end}, now, nil},
+		{mid + 1, []int64{start}, now, ErrShareScope},
+		{mid, nil, now, ErrShareScope},
+		{mid, []int64{start - 1, end}, now, ErrShareScope},
+		{mid, []int64{start, end + 1}, now, ErrShareScope},
+		{mid, []int64{start}, now.Add(2 * time.Hour), ErrShareExpired},
+	}
+	for i, test := range tests {
+		got := s.Check(test.mid, test.ts, test.t)
+		if got != test.want {
+			t.Errorf("Check %d returned %v, expected %v", i, got, test.want)
+		}
+	}
+
+	err = RevokeMediaShare(ctx, store, s.ID)
+	if err != nil {
+		t.Fatalf("RevokeMediaShare returned error: %v", err)
+	}
+	s, err = GetMediaShare(ctx, store, s.ID)
+	if err != nil {
+		t.Fatalf("GetMediaShare returned error: %v", err)
+	}
+	if err := s.Check(mid, []int64{start}, now); err != ErrShareRevoked {
+		t.Errorf("Check returned %v for revoked share, expected %v", err, ErrShareRevoked)
+	}
+}
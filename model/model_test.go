@@ -27,6 +27,7 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -853,6 +854,18 @@ func testBigBuckBunny(t *testing.T, store datastore.Store) {
 		t.Errorf("GetMtsMediaKeys #3 returned wrong number of results; expected 0, got %d", len(keys))
 	}
 
+	// Get the latest key.
+	key, err := GetLatestMtsMediaKey(ctx, store, testMID2, datastore.EpochStart+20)
+	if err != nil {
+		t.Errorf("GetLatestMtsMediaKey #1 failed with error: %v", err)
+	} else if key.ID != ids[8] {
+		t.Errorf("GetLatestMtsMediaKey #1 expected ID of %d, got %d", ids[8], key.ID)
+	}
+	_, err = GetLatestMtsMediaKey(ctx, store, testMID2, datastore.EpochStart+100)
+	if !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("GetLatestMtsMediaKey #2 expected %v, got %v", ErrMediaNotFound, err)
+	}
+
 	// Get media by a range of keys.
 	m, err = GetMtsMediaByKeys(ctx, store, []uint64{uint64(ids[0]), uint64(ids[7])})
 	if err != nil {
@@ -1345,7 +1358,7 @@ func testSubscriber(t *testing.T, kind string) {
 	store.Delete(ctx, store.IDKey(typeSubscriber, testSubscriberID))
 
 	// Remove the monotonic time element from the Created field.
-	s1 := &Subscriber{testSubscriberID, "", testUserEmail, "first", "last", nil, "", "", time.Now().Round(time.Second).UTC()}
+	s1 := &Subscriber{testSubscriberID, "", testUserEmail, "first", "last", nil, "", "", time.Now().Round(time.Second).UTC(), time.Time{}, time.Time{}}
 
 	err = CreateSubscriber(ctx, store, s1)
 	if err != nil {
@@ -1399,7 +1412,7 @@ func testSubscription(t *testing.T, kind string) {
 
 	start := time.Now().Truncate(24 * time.Hour).UTC()
 	finish := start.AddDate(0, 0, 1)
-	s1 := &Subscription{testSubscriberID, testFeedID, SubscriptionDay, "", start, finish, true}
+	s1 := &Subscription{SubscriberID: testSubscriberID, FeedID: testFeedID, Class: SubscriptionDay, Start: start, Finish: finish, Renew: true}
 
 	err = CreateSubscription(ctx, store, testSubscriberID, testFeedID, SubscriptionDay, "", true)
 	if err != nil {
@@ -0,0 +1,113 @@
+/*
+DESCRIPTION
+  MqttTopic datastore type and functions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeMqttTopic = "MqttTopic" // MqttTopic datastore type.
+
+// MqttTopic maps an MQTT topic to a device pin, so that readings
+// published by devices that only speak MQTT can be stored in the same
+// way as readings sent via HTTP. Like SensorV2, the key is the MAC
+// address concatenated with the pin.
+type MqttTopic struct {
+	Skey    int64     // Site key.
+	Mac     int64     // MAC address of associated device.
+	Pin     string    // Pin of associated device.
+	Topic   string    // MQTT topic.
+	Enabled bool      // True if enabled, false otherwise.
+	Updated time.Time // Date/time last updated.
+}
+
+// Copy copies an MqttTopic to dst, or returns a copy of the MqttTopic when dst is nil.
+func (t *MqttTopic) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var t2 *MqttTopic
+	if dst == nil {
+		t2 = new(MqttTopic)
+	} else {
+		var ok bool
+		t2, ok = dst.(*MqttTopic)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*t2 = *t
+	return t2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (t *MqttTopic) GetCache() datastore.Cache {
+	return nil
+}
+
+// PutMqttTopic creates or updates an MQTT topic mapping.
+func PutMqttTopic(ctx context.Context, store datastore.Store, t *MqttTopic) error {
+	t.Updated = time.Now()
+	key := store.NameKey(typeMqttTopic, strconv.FormatInt(t.Mac, 10)+"."+t.Pin)
+	_, err := store.Put(ctx, key, t)
+	return err
+}
+
+// GetMqttTopic gets the MQTT topic mapping for a device pin.
+func GetMqttTopic(ctx context.Context, store datastore.Store, mac int64, pin string) (*MqttTopic, error) {
+	key := store.NameKey(typeMqttTopic, strconv.FormatInt(mac, 10)+"."+pin)
+	t := new(MqttTopic)
+	err := store.Get(ctx, key, t)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetMqttTopics returns all MQTT topic mappings, optionally filtered
+// by site when skey is non-zero. Since MqttTopic keys do not include
+// the site key, filtering is performed after retrieval.
+func GetMqttTopics(ctx context.Context, store datastore.Store, skey int64) ([]MqttTopic, error) {
+	q := store.NewQuery(typeMqttTopic, false, "Mac", "Pin")
+	var all []MqttTopic
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	if skey == 0 {
+		return all, nil
+	}
+	var topics []MqttTopic
+	for _, t := range all {
+		if t.Skey == skey {
+			topics = append(topics, t)
+		}
+	}
+	return topics, nil
+}
+
+// DeleteMqttTopic deletes the MQTT topic mapping for a device pin.
+func DeleteMqttTopic(ctx context.Context, store datastore.Store, mac int64, pin string) error {
+	key := store.NameKey(typeMqttTopic, strconv.FormatInt(mac, 10)+"."+pin)
+	return store.DeleteMulti(ctx, []*datastore.Key{key})
+}
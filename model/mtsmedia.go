@@ -32,6 +32,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 	"unsafe"
 
@@ -355,7 +356,7 @@ func GetMtsMedia(ctx context.Context, store datastore.Store, mid int64, gh []str
 		return nil, err
 	}
 	var clips []MtsMedia
-	_, err = store.GetAll(ctx, q, &clips)
+	_, err = getAll(ctx, store, q, &clips, mtsMediaIndex(gh))
 	return clips, err
 }
 
@@ -366,7 +367,42 @@ func GetMtsMediaKeys(ctx context.Context, store datastore.Store, mid int64, gh [
 	if err != nil {
 		return nil, err
 	}
-	return store.GetAll(ctx, q, nil)
+	return getAll(ctx, store, q, nil, mtsMediaIndex(gh))
+}
+
+// GetLatestMtsMediaKey returns the key of the latest MtsMedia for a
+// given Media ID with a timestamp at or after since, using a keys-only
+// query for just the one key. ErrMediaNotFound is returned if there is
+// none.
+func GetLatestMtsMediaKey(ctx context.Context, store datastore.Store, mid, since int64) (*datastore.Key, error) {
+	q := store.NewQuery(typeMtsMedia, true, "MID", "Timestamp")
+	q.Filter("MID =", mid)
+	q.Filter("Timestamp >=", since)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Order("-Timestamp")
+		q.Limit(1)
+	}
+	keys, err := getAll(ctx, store, q, nil, idxMtsMediaLatest)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrMediaNotFound
+	}
+	if filestore {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+		return keys[len(keys)-1], nil
+	}
+	return keys[0], nil
+}
+
+// mtsMediaIndex returns the index required by an MtsMedia query.
+func mtsMediaIndex(gh []string) Index {
+	if gh != nil {
+		return idxMtsMediaGeohash
+	}
+	return idxMtsMedia
 }
 
 // newMtsMediaQuery constructs an MtsMedia query.
@@ -0,0 +1,219 @@
+/*
+DESCRIPTION
+  MtsUpload datastore type and functions, which make MTS uploads
+  idempotent.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeMtsUpload = "MtsUpload" // MtsUpload datastore type.
+
+// MtsUploadTTL is how long an MTS upload is remembered, i.e., the
+// window within which a retried upload is recognized.
+const MtsUploadTTL = 24 * time.Hour
+
+// mtsUploadStale is how long an upload is claimed for while its media
+// is written, after which the request is presumed to have died.
+const mtsUploadStale = 5 * time.Minute
+
+// mtsUploadPurgeBatch is the number of expired uploads deleted at a time.
+const mtsUploadPurgeBatch = 500
+
+// ErrMtsUploadInProgress is returned when claiming an MTS upload that
+// is being persisted by another request.
+var ErrMtsUploadInProgress = errors.New("MTS upload in progress")
+
+// MtsUpload records an MTS upload, identified by the device's MAC
+// address and an idempotency key chosen by the device, so that a
+// retried upload returns the original response instead of writing the
+// media again. An upload is claimed, without a response, while its
+// media is being written. Expired uploads are ignored, and are deleted
+// by DeleteExpiredMtsUploads. The key is the MAC address concatenated
+// with the idempotency key.
+type MtsUpload struct {
+	Mac      int64     // Encoded MAC address.
+	Key      string    // Idempotency key.
+	Response []byte    `datastore:",noindex"` // Original response, or nil while in progress.
+	Created  time.Time // Date/time created.
+	Expires  time.Time // Date/time after which the upload is forgotten.
+}
+
+// Copy copies an MTS upload to dst, or returns a copy of the MTS upload when dst is nil.
+func (u *MtsUpload) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var u2 *MtsUpload
+	if dst == nil {
+		u2 = new(MtsUpload)
+	} else {
+		var ok bool
+		u2, ok = dst.(*MtsUpload)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*u2 = *u
+	return u2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (u *MtsUpload) GetCache() datastore.Cache {
+	return nil
+}
+
+// mtsUploadKey returns the datastore key for an MTS upload.
+func mtsUploadKey(store datastore.Store, mac int64, key string) *datastore.Key {
+	return store.NameKey(typeMtsUpload, strconv.FormatInt(mac, 10)+"."+key)
+}
+
+// ClaimMtsUpload claims an MTS upload before its media is written. If
+// the upload was previously persisted it is returned, so that the
+// original response can be returned instead. Otherwise nil is returned,
+// and the caller must write the media then call PutMtsUpload, or else
+// DeleteMtsUpload on failure. Checking for and claiming the upload is
+// a single transaction, so that concurrent retries are not both
+// written. ErrMtsUploadInProgress is returned if the upload is claimed
+// by another request.
+func ClaimMtsUpload(ctx context.Context, store datastore.Store, mac int64, key string) (*MtsUpload, error) {
+	now := time.Now()
+	claim := &MtsUpload{Mac: mac, Key: key, Created: now, Expires: now.Add(mtsUploadStale)}
+	k := mtsUploadKey(store, mac, key)
+	err := store.Create(ctx, k, claim)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		return nil, fmt.Errorf("could not create MTS upload: %w", err)
+	}
+
+	// Take over expired uploads and stale claims.
+	var claimErr error
+	var persisted bool
+	u := new(MtsUpload)
+	err = store.Update(ctx, k, func(e datastore.Entity) {
+		u2, ok := e.(*MtsUpload)
+		if !ok {
+			claimErr = datastore.ErrWrongType
+			return
+		}
+		switch {
+		case now.After(u2.Expires):
+			*u2 = *claim
+		case u2.Response == nil:
+			claimErr = ErrMtsUploadInProgress
+		default:
+			persisted = true
+		}
+	}, u)
+	if err != nil {
+		return nil, fmt.Errorf("could not update MTS upload: %w", err)
+	}
+	if claimErr != nil {
+		return nil, claimErr
+	}
+	if persisted {
+		return u, nil
+	}
+	return nil, nil
+}
+
+// PutMtsUpload records a persisted MTS upload and its response, which
+// expires after MtsUploadTTL.
+func PutMtsUpload(ctx context.Context, store datastore.Store, u *MtsUpload) error {
+	u.Created = time.Now()
+	u.Expires = u.Created.Add(MtsUploadTTL)
+	_, err := store.Put(ctx, mtsUploadKey(store, u.Mac, u.Key), u)
+	return err
+}
+
+// GetMtsUpload gets a persisted MTS upload by MAC address and
+// idempotency key, returning datastore.ErrNoSuchEntity if there is no
+// such upload, it is in progress, or it has expired.
+func GetMtsUpload(ctx context.Context, store datastore.Store, mac int64, key string) (*MtsUpload, error) {
+	u := new(MtsUpload)
+	err := store.Get(ctx, mtsUploadKey(store, mac, key), u)
+	if err != nil {
+		return nil, err
+	}
+	if u.Response == nil || time.Now().After(u.Expires) {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	return u, nil
+}
+
+// DeleteMtsUpload deletes an MTS upload, which releases its claim.
+func DeleteMtsUpload(ctx context.Context, store datastore.Store, mac int64, key string) error {
+	return store.Delete(ctx, mtsUploadKey(store, mac, key))
+}
+
+// DeleteExpiredMtsUploads deletes MTS uploads that expired before t,
+// returning the number deleted.
+func DeleteExpiredMtsUploads(ctx context.Context, store datastore.Store, t time.Time) (int, error) {
+	_, filestore := store.(*datastore.FileStore)
+	if filestore {
+		// The file store only filters on key parts, and sets Key fields
+		// to the datastore key, so get uploads one at a time.
+		all, err := store.GetAll(ctx, store.NewQuery(typeMtsUpload, true), nil)
+		if err != nil {
+			return 0, err
+		}
+		var keys []*datastore.Key
+		for _, k := range all {
+			var u MtsUpload
+			err = store.Get(ctx, k, &u)
+			if err != nil {
+				return 0, err
+			}
+			if u.Expires.Before(t) {
+				keys = append(keys, k)
+			}
+		}
+		return len(keys), store.DeleteMulti(ctx, keys)
+	}
+
+	var n int
+	for {
+		q := store.NewQuery(typeMtsUpload, true)
+		q.Filter("Expires <", t)
+		q.Limit(mtsUploadPurgeBatch)
+		keys, err := store.GetAll(ctx, q, nil)
+		if err != nil {
+			return n, err
+		}
+		if len(keys) == 0 {
+			return n, nil
+		}
+		err = store.DeleteMulti(ctx, keys)
+		if err != nil {
+			return n, err
+		}
+		n += len(keys)
+		if len(keys) < mtsUploadPurgeBatch {
+			return n, nil
+		}
+	}
+}
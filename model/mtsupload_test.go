@@ -0,0 +1,136 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestMtsUploads tests recording and getting MTS uploads.
+func TestMtsUploads(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mac = 1
+	err = PutMtsUpload(ctx, store, &MtsUpload{Mac: mac, Key: "abc", Response: []byte(`{"ts":1}`)})
+	if err != nil {
+		t.Fatalf("PutMtsUpload returned error: %v", err)
+	}
+	u, err := GetMtsUpload(ctx, store, mac, "abc")
+	if err != nil {
+		t.Fatalf("GetMtsUpload returned error: %v", err)
+	}
+	if string(u.Response) != `{"ts":1}` {
+		t.Errorf("GetMtsUpload returned response %s", u.Response)
+	}
+
+	for _, k := range []struct {
+		mac int64
+		key string
+	}{{mac, "abd"}, {mac + 1, "abc"}} {
+		_, err = GetMtsUpload(ctx, store, k.mac, k.key)
+		if !errors.Is(err, datastore.ErrNoSuchEntity) {
+			t.Errorf("GetMtsUpload(%d, %s) returned %v, expected ErrNoSuchEntity", k.mac, k.key, err)
+		}
+	}
+
+	// Expire the upload.
+	u.Expires = time.Now().Add(-time.Second)
+	_, err = store.Put(ctx, mtsUploadKey(store, mac, "abc"), u)
+	if err != nil {
+		t.Fatalf("could not put upload: %v", err)
+	}
+	_, err = GetMtsUpload(ctx, store, mac, "abc")
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetMtsUpload returned %v for expired upload, expected ErrNoSuchEntity", err)
+	}
+
+	n, err := DeleteExpiredMtsUploads(ctx, store, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteExpiredMtsUploads returned error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteExpiredMtsUploads deleted %d uploads, expected 1", n)
+	}
+}
+
+// TestClaimMtsUpload tests claiming MTS uploads.
+func TestClaimMtsUpload(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mac = 1
+	u, err := ClaimMtsUpload(ctx, store, mac, "abc")
+	if err != nil || u != nil {
+		t.Fatalf("ClaimMtsUpload returned %v, %v, expected nil, nil", u, err)
+	}
+	_, err = ClaimMtsUpload(ctx, store, mac, "abc")
+	if !errors.Is(err, ErrMtsUploadInProgress) {
+		t.Errorf("ClaimMtsUpload of claimed upload returned %v, expected ErrMtsUploadInProgress", err)
+	}
+	_, err = GetMtsUpload(ctx, store, mac, "abc")
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetMtsUpload of claimed upload returned %v, expected ErrNoSuchEntity", err)
+	}
+
+	// A failed upload releases its claim.
+	err = DeleteMtsUpload(ctx, store, mac, "abc")
+	if err != nil {
+		t.Fatalf("DeleteMtsUpload returned error: %v", err)
+	}
+	u, err = ClaimMtsUpload(ctx, store, mac, "abc")
+	if err != nil || u != nil {
+		t.Fatalf("ClaimMtsUpload of released upload returned %v, %v, expected nil, nil", u, err)
+	}
+
+	err = PutMtsUpload(ctx, store, &MtsUpload{Mac: mac, Key: "abc", Response: []byte(`{"ts":1}`)})
+	if err != nil {
+		t.Fatalf("PutMtsUpload returned error: %v", err)
+	}
+	u, err = ClaimMtsUpload(ctx, store, mac, "abc")
+	if err != nil {
+		t.Fatalf("ClaimMtsUpload of persisted upload returned error: %v", err)
+	}
+	if u == nil || string(u.Response) != `{"ts":1}` {
+		t.Errorf("ClaimMtsUpload of persisted upload returned %v", u)
+	}
+
+	// A stale claim is taken over.
+	_, err = store.Put(ctx, mtsUploadKey(store, mac, "abd"), &MtsUpload{Mac: mac, Key: "abd", Expires: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("could not put upload: %v", err)
+	}
+	u, err = ClaimMtsUpload(ctx, store, mac, "abd")
+	if err != nil || u != nil {
+		t.Errorf("ClaimMtsUpload of stale upload returned %v, %v, expected nil, nil", u, err)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+DESCRIPTION
+  MtsValidation datastore type and functions. MTS validations record
+  the errors found by validating the MPEG-TS clips of a device pin on
+  receipt.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeMtsValidation = "MtsValidation" // MtsValidation datastore type.
+
+// MtsValidation holds the totals of the errors found in the MPEG-TS
+// clips received from a device pin, which indicate corruption at the
+// device, rather than in forwarding. Only clips with errors are
+// recorded. The key is the MID.
+type MtsValidation struct {
+	MID       int64     // Media ID.
+	Clips     int64     // Clips with errors.
+	CCErrors  int64     // Continuity counter discontinuities.
+	PCRErrors int64     // PCRs which did not increase.
+	BadSync   int64     // Packets without a sync byte.
+	LastError time.Time // Date/time of the last clip with errors.
+}
+
+// Copy copies an MTS validation to dst, or returns a copy of the MTS validation when dst is nil.
+func (v *MtsValidation) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var v2 *MtsValidation
+	if dst == nil {
+		v2 = new(MtsValidation)
+	} else {
+		var ok bool
+		v2, ok = dst.(*MtsValidation)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*v2 = *v
+	return v2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (v *MtsValidation) GetCache() datastore.Cache {
+	return nil
+}
+
+// AddMtsValidation adds the errors found in a clip received at t to
+// the MTS validation of a MID, creating it if necessary.
+func AddMtsValidation(ctx context.Context, store datastore.Store, mid, ccErrs, pcrErrs, badSync int64, t time.Time) error {
+	key := store.NameKey(typeMtsValidation, strconv.FormatInt(mid, 10))
+	add := func(e datastore.Entity) {
+		v, ok := e.(*MtsValidation)
+		if ok {
+			v.Clips++
+			v.CCErrors += ccErrs
+			v.PCRErrors += pcrErrs
+			v.BadSync += badSync
+			v.LastError = t
+		}
+	}
+	err := store.Update(ctx, key, add, &MtsValidation{})
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return err
+	}
+	v := &MtsValidation{MID: mid, Clips: 1, CCErrors: ccErrs, PCRErrors: pcrErrs, BadSync: badSync, LastError: t}
+	err = store.Create(ctx, key, v)
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		return err
+	}
+	// Another instance created it first.
+	return store.Update(ctx, key, add, &MtsValidation{})
+}
+
+// GetMtsValidation gets the MTS validation of a MID.
+func GetMtsValidation(ctx context.Context, store datastore.Store, mid int64) (*MtsValidation, error) {
+	v := new(MtsValidation)
+	err := store.Get(ctx, store.NameKey(typeMtsValidation, strconv.FormatInt(mid, 10)), v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
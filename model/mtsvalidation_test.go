@@ -0,0 +1,67 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestMtsValidations tests adding up the errors of MTS clips.
+func TestMtsValidations(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const mid = 1
+	_, err = GetMtsValidation(ctx, store, mid)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetMtsValidation returned %v, expected ErrNoSuchEntity", err)
+	}
+
+	t1 := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+	err = AddMtsValidation(ctx, store, mid, 2, 1, 0, t1)
+	if err != nil {
+		t.Fatalf("AddMtsValidation returned error: %v", err)
+	}
+	err = AddMtsValidation(ctx, store, mid, 1, 0, 3, t2)
+	if err != nil {
+		t.Fatalf("AddMtsValidation returned error: %v", err)
+	}
+	v, err := GetMtsValidation(ctx, store, mid)
+	if err != nil {
+		t.Fatalf("GetMtsValidation returned error: %v", err)
+	}
+	want := MtsValidation{MID: mid, Clips: 2, CCErrors: 3, PCRErrors: 1, BadSync: 3, LastError: t2}
+	if !v.LastError.Equal(want.LastError) {
+		t.Errorf("got last error %v, want %v", v.LastError, want.LastError)
+	}
+	v.LastError = want.LastError
+	if *v != want {
+		t.Errorf("got MTS validation %+v, want %+v", *v, want)
+	}
+}
@@ -0,0 +1,707 @@
+/*
+DESCRIPTION
+  NotificationTemplate, NotificationRoute, PendingNotification, Alert,
+  Notification and RecipientPreference datastore types and functions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	typeNotificationTemplate = "NotificationTemplate" // NotificationTemplate datastore type.
+	typeNotificationRoute    = "NotificationRoute"    // NotificationRoute datastore type.
+	typePendingNotification  = "PendingNotification"  // PendingNotification datastore type.
+	typeAlert                = "Alert"                // Alert datastore type.
+	typeNotification         = "Notification"         // Notification datastore type.
+	typeRecipientPreference  = "RecipientPreference"  // RecipientPreference datastore type.
+)
+
+// Notification delivery statuses.
+const (
+	NotificationSent   = "sent"
+	NotificationFailed = "failed"
+	NotificationQueued = "queued"
+)
+
+// NotificationTemplate defines the format of a kind of notification,
+// as Go text/template or html/template templates for the subject and
+// body. Templates with a zero site key apply to all sites, unless
+// overridden by a template for a specific site. The key is the site
+// key concatenated with the kind.
+type NotificationTemplate struct {
+	Skey    int64     // Site key, or 0 for all sites.
+	Kind    string    // Notification kind.
+	Subject string    `datastore:",noindex"` // Subject template.
+	Body    string    `datastore:",noindex"` // Body template.
+	HTML    bool      // True if the body is HTML, false for plain text.
+	Updated time.Time // Date/time last updated.
+}
+
+// Copy copies a notification template to dst, or returns a copy of the template when dst is nil.
+func (t *NotificationTemplate) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var t2 *NotificationTemplate
+	if dst == nil {
+		t2 = new(NotificationTemplate)
+	} else {
+		var ok bool
+		t2, ok = dst.(*NotificationTemplate)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*t2 = *t
+	return t2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (t *NotificationTemplate) GetCache() datastore.Cache {
+	return nil
+}
+
+// notificationTemplateKey returns the key for a notification template.
+func notificationTemplateKey(store datastore.Store, skey int64, kind string) *datastore.Key {
+	return store.NameKey(typeNotificationTemplate, strconv.FormatInt(skey, 10)+"."+kind)
+}
+
+// PutNotificationTemplate creates or updates a notification template.
+func PutNotificationTemplate(ctx context.Context, store datastore.Store, t *NotificationTemplate) error {
+	t.Updated = time.Now()
+	_, err := store.Put(ctx, notificationTemplateKey(store, t.Skey, t.Kind), t)
+	return err
+}
+
+// GetNotificationTemplate gets the notification template for a site
+// and kind, falling back to the template for all sites.
+func GetNotificationTemplate(ctx context.Context, store datastore.Store, skey int64, kind string) (*NotificationTemplate, error) {
+	t := new(NotificationTemplate)
+	err := store.Get(ctx, notificationTemplateKey(store, skey, kind), t)
+	if errors.Is(err, datastore.ErrNoSuchEntity) && skey != 0 {
+		err = store.Get(ctx, notificationTemplateKey(store, 0, kind), t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// DeleteNotificationTemplate deletes a notification template.
+func DeleteNotificationTemplate(ctx context.Context, store datastore.Store, skey int64, kind string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{notificationTemplateKey(store, skey, kind)})
+}
+
+// NotificationRoute routes notifications for a site to a delivery
+// channel, such as Slack, SMS or PagerDuty, in addition to email.
+// Routes with an empty kind apply to all kinds of notifications. Only
+// notifications with at least the minimum severity are routed. The
+// key is the site key concatenated with the kind and channel.
+type NotificationRoute struct {
+	Skey        int64     // Site key.
+	Kind        string    // Notification kind, or empty for all kinds.
+	Channel     string    // Channel name, e.g., "slack", "sms" or "pagerduty".
+	Recipients  string    // Comma-separated recipients, e.g., phone numbers for SMS.
+	MinSeverity int64     // Minimum severity.
+	Updated     time.Time // Date/time last updated.
+}
+
+// Copy copies a notification route to dst, or returns a copy of the route when dst is nil.
+func (r *NotificationRoute) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var r2 *NotificationRoute
+	if dst == nil {
+		r2 = new(NotificationRoute)
+	} else {
+		var ok bool
+		r2, ok = dst.(*NotificationRoute)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*r2 = *r
+	return r2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (r *NotificationRoute) GetCache() datastore.Cache {
+	return nil
+}
+
+// RecipientList returns the route's recipients as a slice.
+func (r *NotificationRoute) RecipientList() []string {
+	var rs []string
+	for _, s := range strings.Split(r.Recipients, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			rs = append(rs, s)
+		}
+	}
+	return rs
+}
+
+// notificationRouteKey returns the key for a notification route.
+func notificationRouteKey(store datastore.Store, skey int64, kind, channel string) *datastore.Key {
+	return store.NameKey(typeNotificationRoute, strconv.FormatInt(skey, 10)+"."+kind+"."+channel)
+}
+
+// PutNotificationRoute creates or updates a notification route.
+func PutNotificationRoute(ctx context.Context, store datastore.Store, r *NotificationRoute) error {
+	r.Updated = time.Now()
+	_, err := store.Put(ctx, notificationRouteKey(store, r.Skey, r.Kind, r.Channel), r)
+	return err
+}
+
+// GetNotificationRoutes returns the routes for a site that apply to
+// the given kind of notification, or all routes for the site if kind
+// is empty.
+func GetNotificationRoutes(ctx context.Context, store datastore.Store, skey int64, kind string) ([]NotificationRoute, error) {
+	q := store.NewQuery(typeNotificationRoute, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+	}
+	var all []NotificationRoute
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var rs []NotificationRoute
+	for _, r := range all {
+		if r.Skey == skey && (kind == "" || r.Kind == "" || r.Kind == kind) {
+			rs = append(rs, r)
+		}
+	}
+	return rs, nil
+}
+
+// DeleteNotificationRoute deletes a notification route.
+func DeleteNotificationRoute(ctx context.Context, store datastore.Store, skey int64, kind, channel string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{notificationRouteKey(store, skey, kind, channel)})
+}
+
+// PendingNotification is a notification awaiting delivery in a
+// digest, which batches non-urgent notifications for a site into a
+// single email, or a notification deferred until the end of its
+// recipient's quiet hours.
+type PendingNotification struct {
+	ID         int64     // Notification ID.
+	Skey       int64     // Site key.
+	Kind       string    // Notification kind.
+	Recipients string    // Comma-separated recipients.
+	Subject    string    `datastore:",noindex"` // Subject.
+	Msg        string    `datastore:",noindex"` // Message.
+	HTML       bool      `datastore:",noindex"` // True if a deferred message is HTML.
+	NotBefore  time.Time // Date/time before which a deferred notification is not sent, or zero for digests.
+	Created    time.Time // Date/time created.
+}
+
+// Copy copies a pending notification to dst, or returns a copy of the pending notification when dst is nil.
+func (p *PendingNotification) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var p2 *PendingNotification
+	if dst == nil {
+		p2 = new(PendingNotification)
+	} else {
+		var ok bool
+		p2, ok = dst.(*PendingNotification)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*p2 = *p
+	return p2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (p *PendingNotification) GetCache() datastore.Cache {
+	return nil
+}
+
+// CreatePendingNotification creates a pending notification with a unique ID.
+func CreatePendingNotification(ctx context.Context, store datastore.Store, p *PendingNotification) error {
+	p.Created = time.Now()
+	for {
+		p.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typePendingNotification, p.ID), p)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create pending notification: %w", err)
+		}
+	}
+}
+
+// GetPendingNotifications returns the pending notifications for a
+// site, or for all sites if skey is 0, oldest first.
+func GetPendingNotifications(ctx context.Context, store datastore.Store, skey int64) ([]PendingNotification, error) {
+	q := store.NewQuery(typePendingNotification, false)
+	var all []PendingNotification
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var ps []PendingNotification
+	for _, p := range all {
+		if skey == 0 || p.Skey == skey {
+			ps = append(ps, p)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Created.Before(ps[j].Created) })
+	return ps, nil
+}
+
+// GetDuePendingNotifications returns the deferred notifications due
+// to be sent at t, oldest first. Digests, which have no NotBefore, are
+// not returned.
+func GetDuePendingNotifications(ctx context.Context, store datastore.Store, t time.Time) ([]PendingNotification, error) {
+	q := store.NewQuery(typePendingNotification, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("NotBefore >", time.Unix(0, 0))
+		q.Filter("NotBefore <=", t)
+	}
+	var all []PendingNotification
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var ps []PendingNotification
+	for _, p := range all {
+		if !p.NotBefore.IsZero() && !t.Before(p.NotBefore) {
+			ps = append(ps, p)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Created.Before(ps[j].Created) })
+	return ps, nil
+}
+
+// DeletePendingNotifications deletes pending notifications.
+func DeletePendingNotifications(ctx context.Context, store datastore.Store, ps []PendingNotification) error {
+	var keys []*datastore.Key
+	for _, p := range ps {
+		keys = append(keys, store.IDKey(typePendingNotification, p.ID))
+	}
+	return store.DeleteMulti(ctx, keys)
+}
+
+// Alert tracks the acknowledgement of an urgent notification. An
+// unacknowledged alert is escalated to the next tier of recipients
+// after a timeout.
+type Alert struct {
+	ID         int64     // Alert ID.
+	Skey       int64     // Site key.
+	Kind       string    // Notification kind.
+	Subject    string    `datastore:",noindex"` // Subject.
+	Msg        string    `datastore:",noindex"` // Message.
+	Tier       int64     // Current recipient tier, starting at 0.
+	Recipients string    // Comma-separated recipients of the current tier.
+	Created    time.Time // Date/time created.
+	Escalated  time.Time // Date/time last escalated, or created.
+	Acked      time.Time // Date/time acknowledged, or zero.
+	AckedBy    string    // Recipients of the tier that acknowledged.
+}
+
+// Copy copies an alert to dst, or returns a copy of the alert when dst is nil.
+func (a *Alert) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var a2 *Alert
+	if dst == nil {
+		a2 = new(Alert)
+	} else {
+		var ok bool
+		a2, ok = dst.(*Alert)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*a2 = *a
+	return a2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (a *Alert) GetCache() datastore.Cache {
+	return nil
+}
+
+// CreateAlert creates an alert with a unique ID.
+func CreateAlert(ctx context.Context, store datastore.Store, a *Alert) error {
+	a.Created = time.Now()
+	a.Escalated = a.Created
+	for {
+		a.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeAlert, a.ID), a)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create alert: %w", err)
+		}
+	}
+}
+
+// PutAlert updates an alert.
+func PutAlert(ctx context.Context, store datastore.Store, a *Alert) error {
+	_, err := store.Put(ctx, store.IDKey(typeAlert, a.ID), a)
+	return err
+}
+
+// UpdateAlert updates an alert in a transaction by applying fn,
+// returning the updated alert. The alert is not updated if fn returns
+// an error, which is returned.
+func UpdateAlert(ctx context.Context, store datastore.Store, id int64, fn func(*Alert) error) (*Alert, error) {
+	var a *Alert
+	var fnErr error
+	err := store.Update(ctx, store.IDKey(typeAlert, id), func(e datastore.Entity) {
+		a2, ok := e.(*Alert)
+		if !ok {
+			return
+		}
+		tmp := *a2
+		fnErr = fn(&tmp)
+		if fnErr != nil {
+			return
+		}
+		*a2 = tmp
+		a = &tmp
+	}, new(Alert))
+	if err != nil {
+		return nil, err
+	}
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return a, nil
+}
+
+// GetAlert gets an alert.
+func GetAlert(ctx context.Context, store datastore.Store, id int64) (*Alert, error) {
+	a := new(Alert)
+	err := store.Get(ctx, store.IDKey(typeAlert, id), a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetUnackedAlerts returns the unacknowledged alerts for all sites, oldest first.
+func GetUnackedAlerts(ctx context.Context, store datastore.Store) ([]Alert, error) {
+	q := store.NewQuery(typeAlert, false)
+	if _, filestore := store.(*datastore.FileStore); !filestore {
+		q.Filter("Acked =", time.Time{})
+	}
+	var all []Alert
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var as []Alert
+	for _, a := range all {
+		if a.Acked.IsZero() {
+			as = append(as, a)
+		}
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Created.Before(as[j].Created) })
+	return as, nil
+}
+
+// GetDueAlerts returns the unacknowledged alerts for all sites that
+// were last escalated at or after since and before until, oldest
+// first.
+func GetDueAlerts(ctx context.Context, store datastore.Store, since, until time.Time) ([]Alert, error) {
+	q := store.NewQuery(typeAlert, false)
+	if _, filestore := store.(*datastore.FileStore); !filestore {
+		q.Filter("Acked =", time.Time{})
+		q.Filter("Escalated >=", since)
+		q.Filter("Escalated <", until)
+	}
+	var all []Alert
+	_, err := getAll(ctx, store, q, &all, idxAlertDue)
+	if err != nil {
+		return nil, err
+	}
+	var as []Alert
+	for _, a := range all {
+		if a.Acked.IsZero() && !a.Escalated.Before(since) && a.Escalated.Before(until) {
+			as = append(as, a)
+		}
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Created.Before(as[j].Created) })
+	return as, nil
+}
+
+// Notification records a notification delivered, or attempted, via a
+// given channel, for display in the notification center.
+type Notification struct {
+	ID         int64     // Notification ID.
+	Skey       int64     // Site key.
+	Kind       string    // Notification kind.
+	Channel    string    // Delivery channel, e.g., email or slack.
+	Recipients string    // Comma-separated recipients.
+	Subject    string    `datastore:",noindex"` // Subject.
+	Msg        string    `datastore:",noindex"` // Message.
+	Severity   int64     // Severity.
+	Status     string    // Delivery status, i.e., sent, failed or queued.
+	Error      string    `datastore:",noindex"` // Delivery error, if failed.
+	Created    time.Time // Date/time created.
+}
+
+// Copy copies a notification to dst, or returns a copy of the notification when dst is nil.
+func (n *Notification) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var n2 *Notification
+	if dst == nil {
+		n2 = new(Notification)
+	} else {
+		var ok bool
+		n2, ok = dst.(*Notification)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*n2 = *n
+	return n2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (n *Notification) GetCache() datastore.Cache {
+	return nil
+}
+
+// CreateNotification creates a notification with a unique ID.
+func CreateNotification(ctx context.Context, store datastore.Store, n *Notification) error {
+	n.Created = time.Now()
+	for {
+		n.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeNotification, n.ID), n)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create notification: %w", err)
+		}
+	}
+}
+
+// NotificationFilter filters the notifications returned by
+// GetNotifications. Zero-valued fields do not filter.
+type NotificationFilter struct {
+	Kind    string    // Notification kind.
+	Channel string    // Delivery channel.
+	Status  string    // Delivery status.
+	Since   time.Time // Created at or after.
+	Until   time.Time // Created before.
+	Limit   int       // Maximum number of notifications.
+}
+
+// match returns true if a notification passes the filter.
+func (f *NotificationFilter) match(n *Notification) bool {
+	switch {
+	case f.Kind != "" && n.Kind != f.Kind,
+		f.Channel != "" && n.Channel != f.Channel,
+		f.Status != "" && n.Status != f.Status,
+		!f.Since.IsZero() && n.Created.Before(f.Since),
+		!f.Until.IsZero() && !n.Created.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// GetNotifications returns the notifications for a site that pass
+// the filter, if any, newest first. The site and period are filtered
+// by the query, and the kind, channel and status in memory.
+func GetNotifications(ctx context.Context, store datastore.Store, skey int64, f *NotificationFilter) ([]Notification, error) {
+	if f == nil {
+		f = &NotificationFilter{}
+	}
+	q := store.NewQuery(typeNotification, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+		if !f.Since.IsZero() {
+			q.Filter("Created >=", f.Since)
+		}
+		if !f.Until.IsZero() {
+			q.Filter("Created <", f.Until)
+		}
+		q.Order("-Created")
+		if f.Limit > 0 && f.Kind == "" && f.Channel == "" && f.Status == "" {
+			q.Limit(f.Limit)
+		}
+	}
+	var all []Notification
+	_, err := getAll(ctx, store, q, &all, idxNotification)
+	if err != nil {
+		return nil, err
+	}
+	var ns []Notification
+	for i := range all {
+		if all[i].Skey == skey && f.match(&all[i]) {
+			ns = append(ns, all[i])
+		}
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].Created.After(ns[j].Created) })
+	if f.Limit > 0 && len(ns) > f.Limit {
+		ns = ns[:f.Limit]
+	}
+	return ns, nil
+}
+
+// NotificationRetention is the period for which notifications are
+// kept, after which they are deleted by DeleteNotifications.
+const NotificationRetention = 90 * 24 * time.Hour
+
+// notificationDeleteBatch is the maximum number of notifications
+// deleted at once.
+const notificationDeleteBatch = 500
+
+// DeleteNotifications deletes notifications created before t,
+// returning the number deleted.
+func DeleteNotifications(ctx context.Context, store datastore.Store, t time.Time) (int, error) {
+	_, filestore := store.(*datastore.FileStore)
+	if filestore {
+		var ns []Notification
+		keys, err := store.GetAll(ctx, store.NewQuery(typeNotification, false), &ns)
+		if err != nil {
+			return 0, fmt.Errorf("could not get notifications: %w", err)
+		}
+		var old []*datastore.Key
+		for i := range ns {
+			if ns[i].Created.Before(t) {
+				old = append(old, keys[i])
+			}
+		}
+		return len(old), store.DeleteMulti(ctx, old)
+	}
+
+	var n int
+	for {
+		q := store.NewQuery(typeNotification, true)
+		q.Filter("Created <", t)
+		q.Limit(notificationDeleteBatch)
+		keys, err := store.GetAll(ctx, q, nil)
+		if err != nil {
+			return n, fmt.Errorf("could not get notification keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return n, nil
+		}
+		err = store.DeleteMulti(ctx, keys)
+		if err != nil {
+			return n, fmt.Errorf("could not delete notifications: %w", err)
+		}
+		n += len(keys)
+		if len(keys) < notificationDeleteBatch {
+			return n, nil
+		}
+	}
+}
+
+// RecipientPreference holds the delivery preferences of a
+// notification recipient. During quiet hours, which are in the
+// recipient's local time and may span midnight, only notifications of
+// at least the minimum quiet severity are delivered and others are
+// deferred until quiet hours end. The key is the recipient's email
+// address.
+type RecipientPreference struct {
+	Email            string    // Recipient email address.
+	Timezone         string    // IANA timezone, e.g., "Australia/Adelaide", or empty for UTC.
+	QuietStart       string    // Local start of quiet hours, e.g., "22:00", or empty for none.
+	QuietEnd         string    // Local end of quiet hours, e.g., "07:00".
+	QuietMinSeverity int64     // Minimum severity delivered during quiet hours, or zero for critical only.
+	Updated          time.Time // Date/time last updated.
+}
+
+// Copy copies a recipient preference to dst, or returns a copy of the preference when dst is nil.
+func (p *RecipientPreference) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var p2 *RecipientPreference
+	if dst == nil {
+		p2 = new(RecipientPreference)
+	} else {
+		var ok bool
+		p2, ok = dst.(*RecipientPreference)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*p2 = *p
+	return p2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (p *RecipientPreference) GetCache() datastore.Cache {
+	return nil
+}
+
+// PutRecipientPreference creates or updates a recipient preference.
+func PutRecipientPreference(ctx context.Context, store datastore.Store, p *RecipientPreference) error {
+	p.Updated = time.Now()
+	_, err := store.Put(ctx, store.NameKey(typeRecipientPreference, p.Email), p)
+	return err
+}
+
+// GetRecipientPreference gets the preference for a recipient.
+func GetRecipientPreference(ctx context.Context, store datastore.Store, email string) (*RecipientPreference, error) {
+	p := new(RecipientPreference)
+	err := store.Get(ctx, store.NameKey(typeRecipientPreference, email), p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// maxInValues is the maximum number of values in an "in" filter.
+const maxInValues = 30
+
+// GetRecipientPreferences gets the preferences for recipients, keyed
+// by email address, omitting recipients without preferences. Each
+// query gets the preferences of up to maxInValues recipients.
+func GetRecipientPreferences(ctx context.Context, store datastore.Store, emails []string) (map[string]*RecipientPreference, error) {
+	prefs := make(map[string]*RecipientPreference)
+	_, filestore := store.(*datastore.FileStore)
+	for len(emails) > 0 {
+		n := min(len(emails), maxInValues)
+		batch := emails[:n]
+		emails = emails[n:]
+
+		q := store.NewQuery(typeRecipientPreference, false)
+		if !filestore {
+			q.FilterField("Email", "in", batch)
+		}
+		var ps []RecipientPreference
+		_, err := store.GetAll(ctx, q, &ps)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ps {
+			if slices.Contains(batch, ps[i].Email) {
+				prefs[ps[i].Email] = &ps[i]
+			}
+		}
+	}
+	return prefs, nil
+}
+
+// DeleteRecipientPreference deletes the preference for a recipient.
+func DeleteRecipientPreference(ctx context.Context, store datastore.Store, email string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.NameKey(typeRecipientPreference, email)})
+}
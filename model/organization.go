@@ -0,0 +1,319 @@
+/*
+DESCRIPTION
+  Organization and organization membership datastore types and
+  functions. An organization groups the sites of a partner, identified
+  by the sites' OrgID, and its members may administer those sites
+  according to their role.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	typeOrganization = "Organization" // Organization datastore type.
+	typeOrgMember    = "OrgMember"    // OrgMember datastore type.
+)
+
+// Organization member roles.
+const (
+	OrgRoleMember = "member" // Has read access to the organization's sites.
+	OrgRoleAdmin  = "admin"  // Administers the organization's sites and members.
+)
+
+var (
+	ErrInvalidOrgID   = errors.New("invalid organization ID")
+	ErrInvalidOrgRole = errors.New("invalid organization role")
+)
+
+// Organization represents a partner group, whose sites are those with
+// the same OrgID. The key is the OrgID.
+type Organization struct {
+	OrgID       string    // Organization ID, e.g., "AusOcean".
+	Name        string    // Display name.
+	Description string    // Description.
+	OwnerEmail  string    // Email of the owner.
+	Created     time.Time // Date/time created.
+}
+
+// Copy copies an organization to dst, or returns a copy of the organization when dst is nil.
+func (org *Organization) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var o *Organization
+	if dst == nil {
+		o = new(Organization)
+	} else {
+		var ok bool
+		o, ok = dst.(*Organization)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*o = *org
+	return o, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (org *Organization) GetCache() datastore.Cache {
+	return nil
+}
+
+// OrgMember represents a user's membership of an organization. The
+// key is the concatenated OrgID.Email.
+type OrgMember struct {
+	OrgID   string    // Organization ID.
+	Email   string    // User email address.
+	Role    string    // OrgRoleMember or OrgRoleAdmin.
+	Created time.Time // Date/time created.
+}
+
+// Copy copies a member to dst, or returns a copy of the member when dst is nil.
+func (m *OrgMember) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var m2 *OrgMember
+	if dst == nil {
+		m2 = new(OrgMember)
+	} else {
+		var ok bool
+		m2, ok = dst.(*OrgMember)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*m2 = *m
+	return m2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (m *OrgMember) GetCache() datastore.Cache {
+	return nil
+}
+
+// IsAdmin returns true if the member administers the organization.
+func (m *OrgMember) IsAdmin() bool {
+	return m.Role == OrgRoleAdmin
+}
+
+// validOrgID returns ErrInvalidOrgID unless id is non-empty and free
+// of dots, which separate the parts of member keys.
+func validOrgID(id string) error {
+	if strings.TrimSpace(id) == "" || strings.Contains(id, ".") {
+		return fmt.Errorf("%w: %q", ErrInvalidOrgID, id)
+	}
+	return nil
+}
+
+// CreateOrganization creates an organization, or returns an error if
+// one with the same ID exists.
+func CreateOrganization(ctx context.Context, store datastore.Store, org *Organization) error {
+	err := validOrgID(org.OrgID)
+	if err != nil {
+		return err
+	}
+	if org.Created.IsZero() {
+		org.Created = time.Now()
+	}
+	return store.Create(ctx, store.NameKey(typeOrganization, org.OrgID), org)
+}
+
+// PutOrganization creates or updates an organization.
+func PutOrganization(ctx context.Context, store datastore.Store, org *Organization) error {
+	err := validOrgID(org.OrgID)
+	if err != nil {
+		return err
+	}
+	_, err = store.Put(ctx, store.NameKey(typeOrganization, org.OrgID), org)
+	return err
+}
+
+// GetOrganization returns an organization by its ID.
+func GetOrganization(ctx context.Context, store datastore.Store, id string) (*Organization, error) {
+	org := new(Organization)
+	err := store.Get(ctx, store.NameKey(typeOrganization, id), org)
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// GetAllOrganizations returns all organizations.
+func GetAllOrganizations(ctx context.Context, store datastore.Store) ([]Organization, error) {
+	var orgs []Organization
+	_, err := store.GetAll(ctx, store.NewQuery(typeOrganization, false), &orgs)
+	return orgs, err
+}
+
+// EnsureOrganization returns the organization with the given ID,
+// creating it if it does not exist. This adopts OrgIDs that predate
+// organizations, which were free text on sites.
+func EnsureOrganization(ctx context.Context, store datastore.Store, id string) (*Organization, error) {
+	org, err := GetOrganization(ctx, store, id)
+	if err == nil {
+		return org, nil
+	}
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, err
+	}
+	org = &Organization{OrgID: id, Name: id}
+	err = CreateOrganization(ctx, store, org)
+	if errors.Is(err, datastore.ErrEntityExists) {
+		return GetOrganization(ctx, store, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// DeleteOrganization deletes an organization and its memberships. Its
+// sites are unchanged.
+func DeleteOrganization(ctx context.Context, store datastore.Store, id string) error {
+	q := store.NewQuery(typeOrgMember, true, "OrgID", "Email")
+	q.Filter("OrgID =", id)
+	keys, err := store.GetAll(ctx, q, nil)
+	if err != nil {
+		return fmt.Errorf("could not get members: %w", err)
+	}
+	keys = append(keys, store.NameKey(typeOrganization, id))
+	return store.DeleteMulti(ctx, keys)
+}
+
+// PutOrgMember creates or updates a membership of an existing
+// organization.
+func PutOrgMember(ctx context.Context, store datastore.Store, m *OrgMember) error {
+	if m.Role != OrgRoleMember && m.Role != OrgRoleAdmin {
+		return fmt.Errorf("%w: %q", ErrInvalidOrgRole, m.Role)
+	}
+	_, err := GetOrganization(ctx, store, m.OrgID)
+	if err != nil {
+		return fmt.Errorf("could not get organization %s: %w", m.OrgID, err)
+	}
+	if m.Created.IsZero() {
+		m.Created = time.Now()
+	}
+	_, err = store.Put(ctx, store.NameKey(typeOrgMember, m.OrgID+"."+m.Email), m)
+	return err
+}
+
+// GetOrgMember returns a user's membership of an organization.
+func GetOrgMember(ctx context.Context, store datastore.Store, id, email string) (*OrgMember, error) {
+	m := new(OrgMember)
+	err := store.Get(ctx, store.NameKey(typeOrgMember, id+"."+email), m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetOrgMembers returns the members of an organization, sorted by email.
+func GetOrgMembers(ctx context.Context, store datastore.Store, id string) ([]OrgMember, error) {
+	q := store.NewQuery(typeOrgMember, false, "OrgID", "Email")
+	q.Filter("OrgID =", id)
+	var members []OrgMember
+	_, err := store.GetAll(ctx, q, &members)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Email < members[j].Email })
+	return members, nil
+}
+
+// GetMemberships returns the organization memberships of a user.
+func GetMemberships(ctx context.Context, store datastore.Store, email string) ([]OrgMember, error) {
+	q := store.NewQuery(typeOrgMember, false, "OrgID", "Email")
+	q.Filter("Email =", email)
+	var members []OrgMember
+	_, err := store.GetAll(ctx, q, &members)
+	return members, err
+}
+
+// DeleteOrgMember deletes a user's membership of an organization.
+func DeleteOrgMember(ctx context.Context, store datastore.Store, id, email string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.NameKey(typeOrgMember, id+"."+email)})
+}
+
+// IsOrgAdmin returns true if the user administers the organization.
+func IsOrgAdmin(ctx context.Context, store datastore.Store, id, email string) bool {
+	if id == "" {
+		return false
+	}
+	m, err := GetOrgMember(ctx, store, id, email)
+	return err == nil && m.IsAdmin()
+}
+
+// GetOrgSites returns the sites of an organization, sorted by site key.
+func GetOrgSites(ctx context.Context, store datastore.Store, id string) ([]Site, error) {
+	q := store.NewQuery(typeSite, false)
+	q.Filter("OrgID =", id)
+	var sites []Site
+	_, err := store.GetAll(ctx, q, &sites)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sites: %w", err)
+	}
+	// The file store ignores filters on fields that are not key parts.
+	var orgSites []Site
+	for _, s := range sites {
+		if s.OrgID == id {
+			orgSites = append(orgSites, s)
+		}
+	}
+	sort.Slice(orgSites, func(i, j int) bool { return orgSites[i].Skey < orgSites[j].Skey })
+	return orgSites, nil
+}
+
+// GetOrgDevices returns the devices of all of an organization's sites.
+func GetOrgDevices(ctx context.Context, store datastore.Store, id string) ([]Device, error) {
+	sites, err := GetOrgSites(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+	var devs []Device
+	for _, s := range sites {
+		d, err := GetDevicesBySite(ctx, store, s.Skey)
+		if err != nil {
+			return nil, fmt.Errorf("could not get devices for site %d: %w", s.Skey, err)
+		}
+		devs = append(devs, d...)
+	}
+	return devs, nil
+}
+
+// GetOrgUsers returns the users of all of an organization's sites.
+func GetOrgUsers(ctx context.Context, store datastore.Store, id string) ([]User, error) {
+	sites, err := GetOrgSites(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	for _, s := range sites {
+		u, err := GetUsersBySite(ctx, store, s.Skey)
+		if err != nil {
+			return nil, fmt.Errorf("could not get users for site %d: %w", s.Skey, err)
+		}
+		users = append(users, u...)
+	}
+	return users, nil
+}
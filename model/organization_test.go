@@ -0,0 +1,122 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestOrganization tests organizations, membership and org-scoped queries.
+func TestOrganization(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const orgID = "Partner"
+	err = CreateOrganization(ctx, store, &Organization{OrgID: "Bad.ID"})
+	if !errors.Is(err, ErrInvalidOrgID) {
+		t.Errorf("CreateOrganization with dotted ID returned %v, expected %v", err, ErrInvalidOrgID)
+	}
+	err = PutOrgMember(ctx, store, &OrgMember{OrgID: orgID, Email: "a@partner.org", Role: OrgRoleAdmin})
+	if err == nil {
+		t.Errorf("PutOrgMember succeeded for missing organization")
+	}
+
+	org, err := EnsureOrganization(ctx, store, orgID)
+	if err != nil {
+		t.Fatalf("EnsureOrganization returned error: %v", err)
+	}
+	if org.Name != orgID || org.Created.IsZero() {
+		t.Errorf("got organization %+v", org)
+	}
+	_, err = EnsureOrganization(ctx, store, orgID)
+	if err != nil {
+		t.Errorf("EnsureOrganization of existing organization returned error: %v", err)
+	}
+
+	members := []OrgMember{
+		{OrgID: orgID, Email: "a@partner.org", Role: OrgRoleAdmin},
+		{OrgID: orgID, Email: "b@partner.org", Role: OrgRoleMember},
+	}
+	for i := range members {
+		err = PutOrgMember(ctx, store, &members[i])
+		if err != nil {
+			t.Fatalf("PutOrgMember returned error: %v", err)
+		}
+	}
+	err = PutOrgMember(ctx, store, &OrgMember{OrgID: orgID, Email: "c@partner.org", Role: "owner"})
+	if !errors.Is(err, ErrInvalidOrgRole) {
+		t.Errorf("PutOrgMember with bad role returned %v, expected %v", err, ErrInvalidOrgRole)
+	}
+
+	got, err := GetOrgMembers(ctx, store, orgID)
+	if err != nil || len(got) != 2 || got[0].Email != "a@partner.org" {
+		t.Errorf("GetOrgMembers returned %v, %v", got, err)
+	}
+	got, err = GetMemberships(ctx, store, "b@partner.org")
+	if err != nil || len(got) != 1 || got[0].OrgID != orgID {
+		t.Errorf("GetMemberships returned %v, %v", got, err)
+	}
+	if !IsOrgAdmin(ctx, store, orgID, "a@partner.org") || IsOrgAdmin(ctx, store, orgID, "b@partner.org") || IsOrgAdmin(ctx, store, "", "a@partner.org") {
+		t.Errorf("IsOrgAdmin returned unexpected result")
+	}
+
+	for _, s := range []Site{{Skey: 1, OrgID: orgID}, {Skey: 2, OrgID: "Other"}, {Skey: 3, OrgID: orgID}} {
+		err = PutSite(ctx, store, &s)
+		if err != nil {
+			t.Fatalf("PutSite returned error: %v", err)
+		}
+		err = PutDevice(ctx, store, &Device{Skey: s.Skey, Mac: s.Skey, Name: "dev"})
+		if err != nil {
+			t.Fatalf("PutDevice returned error: %v", err)
+		}
+		err = PutUser(ctx, store, &User{Skey: s.Skey, Email: "user@partner.org", Perm: ReadPermission})
+		if err != nil {
+			t.Fatalf("PutUser returned error: %v", err)
+		}
+	}
+	sites, err := GetOrgSites(ctx, store, orgID)
+	if err != nil || len(sites) != 2 || sites[0].Skey != 1 || sites[1].Skey != 3 {
+		t.Errorf("GetOrgSites returned %v, %v", sites, err)
+	}
+	devs, err := GetOrgDevices(ctx, store, orgID)
+	if err != nil || len(devs) != 2 {
+		t.Errorf("GetOrgDevices returned %d devices, %v, expected 2", len(devs), err)
+	}
+	users, err := GetOrgUsers(ctx, store, orgID)
+	if err != nil || len(users) != 2 {
+		t.Errorf("GetOrgUsers returned %d users, %v, expected 2", len(users), err)
+	}
+
+	err = DeleteOrganization(ctx, store, orgID)
+	if err != nil {
+		t.Fatalf("DeleteOrganization returned error: %v", err)
+	}
+	got, err = GetOrgMembers(ctx, store, orgID)
+	if err != nil || len(got) != 0 {
+		t.Errorf("GetOrgMembers after delete returned %v, %v", got, err)
+	}
+}
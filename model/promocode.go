@@ -0,0 +1,304 @@
+/*
+DESCRIPTION
+  PromoCode datastore type and functions, which implement promotional
+  and gift codes for AusOcean TV subscriptions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typePromoCode = "PromoCode" // PromoCode datastore type.
+
+// Promo code statuses.
+const (
+	PromoActive    = "active"
+	PromoExhausted = "exhausted"
+	PromoExpired   = "expired"
+)
+
+// promoAlphabet is the alphabet for generated promo codes, which
+// omits easily confused characters, e.g., 0 and O.
+const promoAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// promoLen is the length of generated promo codes.
+const promoLen = 8
+
+// Promo code errors.
+var (
+	ErrPromoNotFound = errors.New("promo code not found")
+	ErrPromoExpired  = errors.New("promo code expired")
+	ErrPromoUsed     = errors.New("promo code fully redeemed")
+	ErrPromoRedeemed = errors.New("promo code already redeemed by subscriber")
+	ErrPromoExists   = errors.New("promo code exists")
+	ErrPromoClass    = errors.New("promo code is for a different subscription class")
+)
+
+// PromoCode is a code that grants a discounted or free subscription
+// of a given class, e.g., for a marketing campaign or as a gift. A
+// code with PercentOff of 100 grants a free subscription period,
+// otherwise the discount is applied to the payment, using the Stripe
+// coupon, if any. Codes may be redeemed up to MaxRedemptions times,
+// but only once per subscriber. The key is the code.
+type PromoCode struct {
+	Code           string    // Code, in upper case.
+	Class          string    // Subscription class, e.g., "Month".
+	PercentOff     int64     // Percentage discount, where 100 is free.
+	Coupon         string    // Stripe coupon ID, for discounted codes.
+	Campaign       string    // Campaign name, or "gift" for gift codes.
+	MaxRedemptions int64     // Maximum number of redemptions.
+	Redemptions    int64     // Number of redemptions.
+	Subscribers    string    `datastore:",noindex"` // Comma-separated IDs of subscribers who redeemed this code.
+	Creator        string    // Email address of creator.
+	Created        time.Time // Date/time created.
+	Expires        time.Time // Date/time expires.
+}
+
+// Copy copies a promo code to dst, or returns a copy of the promo code when dst is nil.
+func (p *PromoCode) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var p2 *PromoCode
+	if dst == nil {
+		p2 = new(PromoCode)
+	} else {
+		var ok bool
+		p2, ok = dst.(*PromoCode)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*p2 = *p
+	return p2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (p *PromoCode) GetCache() datastore.Cache {
+	return nil
+}
+
+// Status returns the code's status, i.e., active, exhausted or expired.
+func (p *PromoCode) Status() string {
+	switch {
+	case p.Redemptions >= p.MaxRedemptions:
+		return PromoExhausted
+	case time.Now().After(p.Expires):
+		return PromoExpired
+	default:
+		return PromoActive
+	}
+}
+
+// Free returns true if the code grants a free subscription period.
+func (p *PromoCode) Free() bool {
+	return p.PercentOff >= 100
+}
+
+// Redeemed returns true if the code has been redeemed by the given subscriber.
+func (p *PromoCode) Redeemed(sid int64) bool {
+	return p.Subscribers != "" && slices.Contains(strings.Split(p.Subscribers, ","), strconv.FormatInt(sid, 10))
+}
+
+// Check returns ErrPromoUsed, ErrPromoExpired or ErrPromoRedeemed if
+// the code cannot be redeemed by the given subscriber, or nil otherwise.
+func (p *PromoCode) Check(sid int64) error {
+	switch {
+	case p.Status() == PromoExhausted:
+		return ErrPromoUsed
+	case p.Status() == PromoExpired:
+		return ErrPromoExpired
+	case p.Redeemed(sid):
+		return ErrPromoRedeemed
+	default:
+		return nil
+	}
+}
+
+// CreatePromoCode creates a promo code. If p.Code is empty a unique
+// random code is generated, otherwise ErrPromoExists is returned if
+// the code already exists.
+func CreatePromoCode(ctx context.Context, store datastore.Store, p *PromoCode) error {
+	p.Created = time.Now()
+	p.Code = strings.ToUpper(p.Code)
+	gen := p.Code == ""
+	for {
+		if gen {
+			code, err := generatePromoCode()
+			if err != nil {
+				return fmt.Errorf("could not generate promo code: %w", err)
+			}
+			p.Code = code
+		}
+		err := store.Create(ctx, store.NameKey(typePromoCode, p.Code), p)
+		switch {
+		case err == nil:
+			return nil
+		case err == datastore.ErrEntityExists && gen:
+			continue
+		case err == datastore.ErrEntityExists:
+			return ErrPromoExists
+		default:
+			return fmt.Errorf("could not create promo code: %w", err)
+		}
+	}
+}
+
+// generatePromoCode returns a random promo code.
+func generatePromoCode() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < promoLen; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(promoAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(promoAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// GetPromoCode gets a promo code, returning ErrPromoNotFound if there
+// is no such code. Codes are case insensitive.
+func GetPromoCode(ctx context.Context, store datastore.Store, code string) (*PromoCode, error) {
+	p := new(PromoCode)
+	err := store.Get(ctx, store.NameKey(typePromoCode, strings.ToUpper(code)), p)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPromoCodes returns the promo codes for a campaign, or all codes
+// if campaign is empty, newest first.
+func GetPromoCodes(ctx context.Context, store datastore.Store, campaign string) ([]PromoCode, error) {
+	q := store.NewQuery(typePromoCode, false)
+	var all []PromoCode
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var ps []PromoCode
+	for _, p := range all {
+		if campaign == "" || p.Campaign == campaign {
+			ps = append(ps, p)
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if !ps[i].Created.Equal(ps[j].Created) {
+			return ps[i].Created.After(ps[j].Created)
+		}
+		return ps[i].Code < ps[j].Code
+	})
+	return ps, nil
+}
+
+// RedeemPromoCode redeems a promo code for the subscriber with the
+// given ID and a subscription of the given class, returning the code
+// if it is valid, or ErrPromoNotFound, ErrPromoExpired, ErrPromoUsed,
+// ErrPromoRedeemed or ErrPromoClass otherwise. The code is checked and
+// redeemed in a transaction. If fulfil is not nil, it is then called
+// with the code, e.g., to grant a subscription, and should it fail the
+// redemption is released and its error returned, so that codes are not
+// consumed without being fulfilled.
+func RedeemPromoCode(ctx context.Context, store datastore.Store, code string, sid int64, class string, fulfil func(*PromoCode) error) (*PromoCode, error) {
+	p := new(PromoCode)
+	var redeemErr error
+	err := store.Update(ctx, store.NameKey(typePromoCode, strings.ToUpper(code)), func(e datastore.Entity) {
+		redeemErr = nil
+		p2, ok := e.(*PromoCode)
+		if !ok {
+			redeemErr = datastore.ErrWrongType
+			return
+		}
+		if p2.Class != class {
+			redeemErr = ErrPromoClass
+			return
+		}
+		redeemErr = p2.Check(sid)
+		if redeemErr != nil {
+			return
+		}
+		p2.Redemptions++
+		ids := []string{strconv.FormatInt(sid, 10)}
+		if p2.Subscribers != "" {
+			ids = append(strings.Split(p2.Subscribers, ","), ids...)
+		}
+		p2.Subscribers = strings.Join(ids, ",")
+	}, p)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if redeemErr != nil {
+		return nil, redeemErr
+	}
+	if fulfil == nil {
+		return p, nil
+	}
+
+	err = fulfil(p)
+	if err != nil {
+		relErr := releasePromoCode(ctx, store, p.Code, sid)
+		if relErr != nil {
+			return nil, fmt.Errorf("%w (could not release promo code: %v)", err, relErr)
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// releasePromoCode reverses the redemption of a promo code by a
+// subscriber in a transaction.
+func releasePromoCode(ctx context.Context, store datastore.Store, code string, sid int64) error {
+	return store.Update(ctx, store.NameKey(typePromoCode, code), func(e datastore.Entity) {
+		p, ok := e.(*PromoCode)
+		if !ok || !p.Redeemed(sid) {
+			return
+		}
+		id := strconv.FormatInt(sid, 10)
+		var ids []string
+		for _, s := range strings.Split(p.Subscribers, ",") {
+			if s != id {
+				ids = append(ids, s)
+			}
+		}
+		p.Subscribers = strings.Join(ids, ",")
+		p.Redemptions--
+	}, new(PromoCode))
+}
+
+// DeletePromoCode deletes a promo code.
+func DeletePromoCode(ctx context.Context, store datastore.Store, code string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.NameKey(typePromoCode, strings.ToUpper(code))})
+}
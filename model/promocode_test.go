@@ -0,0 +1,155 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestPromoCodes tests creating and redeeming promo codes, and
+// granting the resulting subscriptions.
+func TestPromoCodes(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	p := &PromoCode{Class: SubscriptionMonth, PercentOff: 100, Campaign: "launch", MaxRedemptions: 2, Expires: time.Now().Add(time.Hour)}
+	err = CreatePromoCode(ctx, store, p)
+	if err != nil {
+		t.Fatalf("CreatePromoCode returned error: %v", err)
+	}
+	if len(p.Code) != promoLen {
+		t.Errorf("generated code %s does not have %d characters", p.Code, promoLen)
+	}
+	err = CreatePromoCode(ctx, store, &PromoCode{Code: p.Code})
+	if !errors.Is(err, ErrPromoExists) {
+		t.Errorf("CreatePromoCode returned %v, expected %v", err, ErrPromoExists)
+	}
+
+	for sid := int64(1); sid <= 2; sid++ {
+		got, err := RedeemPromoCode(ctx, store, p.Code, sid, SubscriptionMonth, nil)
+		if err != nil {
+			t.Fatalf("RedeemPromoCode returned error: %v", err)
+		}
+		if got.Redemptions != sid || !got.Redeemed(sid) {
+			t.Errorf("RedeemPromoCode returned %+v", got)
+		}
+	}
+	_, err = RedeemPromoCode(ctx, store, p.Code, 3, SubscriptionMonth, nil)
+	if !errors.Is(err, ErrPromoUsed) {
+		t.Errorf("RedeemPromoCode returned %v, expected %v", err, ErrPromoUsed)
+	}
+	_, err = RedeemPromoCode(ctx, store, "NOSUCHCODE", 3, SubscriptionMonth, nil)
+	if !errors.Is(err, ErrPromoNotFound) {
+		t.Errorf("RedeemPromoCode returned %v, expected %v", err, ErrPromoNotFound)
+	}
+
+	gift := &PromoCode{Code: "gift-reef", Class: SubscriptionYear, PercentOff: 100, Campaign: "gift", MaxRedemptions: 5, Expires: time.Now().Add(time.Hour)}
+	err = CreatePromoCode(ctx, store, gift)
+	if err != nil {
+		t.Fatalf("CreatePromoCode returned error: %v", err)
+	}
+	_, err = RedeemPromoCode(ctx, store, "Gift-Reef", 1, SubscriptionYear, nil)
+	if err != nil {
+		t.Fatalf("RedeemPromoCode returned error: %v", err)
+	}
+	_, err = RedeemPromoCode(ctx, store, "GIFT-REEF", 1, SubscriptionYear, nil)
+	if !errors.Is(err, ErrPromoRedeemed) {
+		t.Errorf("RedeemPromoCode returned %v, expected %v", err, ErrPromoRedeemed)
+	}
+
+	// Codes are only redeemed for their class, and are released if
+	// they cannot be fulfilled.
+	_, err = RedeemPromoCode(ctx, store, gift.Code, 2, SubscriptionMonth, nil)
+	if !errors.Is(err, ErrPromoClass) {
+		t.Errorf("RedeemPromoCode returned %v, expected %v", err, ErrPromoClass)
+	}
+	errFulfil := errors.New("fulfil failed")
+	_, err = RedeemPromoCode(ctx, store, gift.Code, 2, SubscriptionYear, func(*PromoCode) error { return errFulfil })
+	if !errors.Is(err, errFulfil) {
+		t.Errorf("RedeemPromoCode returned %v, expected %v", err, errFulfil)
+	}
+	got, err := GetPromoCode(ctx, store, gift.Code)
+	if err != nil {
+		t.Fatalf("GetPromoCode returned error: %v", err)
+	}
+	if got.Redemptions != 1 || got.Redeemed(2) {
+		t.Errorf("promo code not released: %+v", got)
+	}
+
+	expired := &PromoCode{Class: SubscriptionDay, PercentOff: 50, MaxRedemptions: 1, Expires: time.Now().Add(-time.Hour)}
+	err = CreatePromoCode(ctx, store, expired)
+	if err != nil {
+		t.Fatalf("CreatePromoCode returned error: %v", err)
+	}
+	_, err = RedeemPromoCode(ctx, store, expired.Code, 1, SubscriptionDay, nil)
+	if !errors.Is(err, ErrPromoExpired) {
+		t.Errorf("RedeemPromoCode returned %v, expected %v", err, ErrPromoExpired)
+	}
+
+	ps, err := GetPromoCodes(ctx, store, "launch")
+	if err != nil {
+		t.Fatalf("GetPromoCodes returned error: %v", err)
+	}
+	if len(ps) != 1 || ps[0].Status() != PromoExhausted {
+		t.Errorf("GetPromoCodes returned %+v", ps)
+	}
+
+	// Granting twice extends the subscription by two periods.
+	s, err := GrantSubscription(ctx, store, 1, NoFeedID, SubscriptionMonth)
+	if err != nil {
+		t.Fatalf("GrantSubscription returned error: %v", err)
+	}
+	first := s.Finish
+	s, err = GrantSubscription(ctx, store, 1, NoFeedID, SubscriptionMonth)
+	if err != nil {
+		t.Fatalf("GrantSubscription returned error: %v", err)
+	}
+	if !s.Finish.Equal(SubscriptionEnd(first, SubscriptionMonth)) {
+		t.Errorf("GrantSubscription finish is %v, expected %v", s.Finish, SubscriptionEnd(first, SubscriptionMonth))
+	}
+
+	// Active paid subscriptions are not overwritten.
+	paid := &Subscription{SubscriberID: 2, FeedID: NoFeedID, Class: SubscriptionYear, Finish: time.Now().Add(time.Hour), Status: "active", PaymentID: "sub_123"}
+	err = UpdateSubscription(ctx, store, paid)
+	if err != nil {
+		t.Fatalf("UpdateSubscription returned error: %v", err)
+	}
+	_, err = GrantSubscription(ctx, store, 2, NoFeedID, SubscriptionMonth)
+	if !errors.Is(err, ErrPaidSubscription) {
+		t.Errorf("GrantSubscription returned %v, expected %v", err, ErrPaidSubscription)
+	}
+
+	err = DeletePromoCode(ctx, store, gift.Code)
+	if err != nil {
+		t.Fatalf("DeletePromoCode returned error: %v", err)
+	}
+	_, err = GetPromoCode(ctx, store, gift.Code)
+	if !errors.Is(err, ErrPromoNotFound) {
+		t.Errorf("GetPromoCode returned %v, expected %v", err, ErrPromoNotFound)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+DESCRIPTION
+  ProvisioningToken datastore type and functions.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeProvisioningToken = "ProvisioningToken" // ProvisioningToken datastore type.
+
+// Provisioning token statuses.
+const (
+	TokenOutstanding = "outstanding"
+	TokenUsed        = "used"
+	TokenExpired     = "expired"
+)
+
+// Provisioning token errors.
+var (
+	ErrTokenNotFound = errors.New("provisioning token not found")
+	ErrTokenExpired  = errors.New("provisioning token expired")
+	ErrTokenUsed     = errors.New("provisioning token used")
+)
+
+// ProvisioningToken is a pre-generated device key that installs
+// devices into a given site, as an alternative to a TOTP, which
+// installs a single device into the sandbox site. Tokens are created
+// in batches, e.g., for a field tech's installation day, and may be
+// used up to MaxUses times before they expire. Like TOTPs, tokens are
+// 16-digit numbers. The key is the token.
+type ProvisioningToken struct {
+	Token    int64     // Token.
+	Skey     int64     // Site key of site that devices are installed into.
+	Batch    int64     // Batch ID, which is the creation time in Unix seconds.
+	MaxUses  int64     // Maximum number of uses.
+	Uses     int64     // Number of uses.
+	Devices  string    // Comma-separated MAC addresses of devices installed with this token.
+	Creator  string    // Email address of creator.
+	Created  time.Time // Date/time created.
+	Expires  time.Time // Date/time expires.
+	LastUsed time.Time // Date/time last used, or zero.
+}
+
+// Copy copies a token to dst, or returns a copy of the token when dst is nil.
+func (t *ProvisioningToken) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var t2 *ProvisioningToken
+	if dst == nil {
+		t2 = new(ProvisioningToken)
+	} else {
+		var ok bool
+		t2, ok = dst.(*ProvisioningToken)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*t2 = *t
+	return t2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (t *ProvisioningToken) GetCache() datastore.Cache {
+	return nil
+}
+
+// Status returns the token's status, i.e., outstanding, used or expired.
+func (t *ProvisioningToken) Status() string {
+	switch {
+	case t.Uses >= t.MaxUses:
+		return TokenUsed
+	case time.Now().After(t.Expires):
+		return TokenExpired
+	default:
+		return TokenOutstanding
+	}
+}
+
+// CreateProvisioningTokens creates a batch of n tokens for a site,
+// each of which may be used up to maxUses times until it expires.
+func CreateProvisioningTokens(ctx context.Context, store datastore.Store, skey int64, n int, maxUses int64, expires time.Time, creator string) ([]ProvisioningToken, error) {
+	now := time.Now()
+	var toks []ProvisioningToken
+	for len(toks) < n {
+		// Tokens have exactly 16 digits.
+		r, err := rand.Int(rand.Reader, big.NewInt(9e15))
+		if err != nil {
+			return nil, fmt.Errorf("could not generate token: %w", err)
+		}
+		t := ProvisioningToken{
+			Token:   r.Int64() + 1e15,
+			Skey:    skey,
+			Batch:   now.Unix(),
+			MaxUses: maxUses,
+			Creator: creator,
+			Created: now,
+			Expires: expires,
+		}
+		err = store.Create(ctx, store.IDKey(typeProvisioningToken, t.Token), &t)
+		if err == datastore.ErrEntityExists {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not create token: %w", err)
+		}
+		toks = append(toks, t)
+	}
+	return toks, nil
+}
+
+// GetProvisioningTokens returns the tokens for a site, newest first.
+func GetProvisioningTokens(ctx context.Context, store datastore.Store, skey int64) ([]ProvisioningToken, error) {
+	q := store.NewQuery(typeProvisioningToken, false)
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("Skey =", skey)
+	}
+	var all []ProvisioningToken
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var toks []ProvisioningToken
+	for _, t := range all {
+		if t.Skey == skey {
+			toks = append(toks, t)
+		}
+	}
+	sort.Slice(toks, func(i, j int) bool {
+		if toks[i].Batch != toks[j].Batch {
+			return toks[i].Batch > toks[j].Batch
+		}
+		return toks[i].Token < toks[j].Token
+	})
+	return toks, nil
+}
+
+// UseProvisioningToken uses a token to install the device with the
+// given MAC address, returning the token if it is valid, or
+// ErrTokenNotFound, ErrTokenExpired or ErrTokenUsed otherwise. Using a
+// token again for the same device does not count as another use.
+func UseProvisioningToken(ctx context.Context, store datastore.Store, token, mac int64) (*ProvisioningToken, error) {
+	t, _, err := useProvisioningToken(ctx, store, token, mac)
+	return t, err
+}
+
+// useProvisioningToken implements UseProvisioningToken, also returning
+// true if the token was used by this call, rather than previously for
+// the same device.
+func useProvisioningToken(ctx context.Context, store datastore.Store, token, mac int64) (*ProvisioningToken, bool, error) {
+	t := new(ProvisioningToken)
+	var useErr error
+	var used bool
+	err := store.Update(ctx, store.IDKey(typeProvisioningToken, token), func(e datastore.Entity) {
+		useErr = nil
+		used = false
+		t2, ok := e.(*ProvisioningToken)
+		if !ok {
+			useErr = datastore.ErrWrongType
+			return
+		}
+		if t2.hasDevice(mac) {
+			return
+		}
+		switch t2.Status() {
+		case TokenUsed:
+			useErr = ErrTokenUsed
+			return
+		case TokenExpired:
+			useErr = ErrTokenExpired
+			return
+		}
+		t2.Uses++
+		t2.LastUsed = time.Now()
+		macs := []string{MacDecode(mac)}
+		if t2.Devices != "" {
+			macs = append(strings.Split(t2.Devices, ","), macs...)
+		}
+		t2.Devices = strings.Join(macs, ",")
+		used = true
+	}, t)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return nil, false, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if useErr != nil {
+		return nil, false, useErr
+	}
+	return t, used, nil
+}
+
+// hasDevice returns true if the token was used to install the device
+// with the given MAC address.
+func (t *ProvisioningToken) hasDevice(mac int64) bool {
+	return t.Devices != "" && slices.Contains(strings.Split(t.Devices, ","), MacDecode(mac))
+}
+
+// releaseProvisioningToken undoes the use of a token by a device.
+func releaseProvisioningToken(ctx context.Context, store datastore.Store, token, mac int64) error {
+	return store.Update(ctx, store.IDKey(typeProvisioningToken, token), func(e datastore.Entity) {
+		t, ok := e.(*ProvisioningToken)
+		if !ok || !t.hasDevice(mac) {
+			return
+		}
+		ma := MacDecode(mac)
+		macs := slices.DeleteFunc(strings.Split(t.Devices, ","), func(s string) bool { return s == ma })
+		t.Devices = strings.Join(macs, ",")
+		t.Uses--
+	}, new(ProvisioningToken))
+}
+
+// ProvisionDevice uses a token to create a new device in the token's
+// site. Since the datastore does not support transactions across
+// entities, the use of the token is undone if the device cannot be
+// created, e.g., because it was created concurrently, in which case
+// datastore.ErrEntityExists is returned. Errors using the token are
+// as per UseProvisioningToken.
+func ProvisionDevice(ctx context.Context, store datastore.Store, token int64, dev *Device) (*ProvisioningToken, error) {
+	t, used, err := useProvisioningToken(ctx, store, token, dev.Mac)
+	if err != nil {
+		return nil, err
+	}
+	dev.Skey = t.Skey
+	err = CreateDevice(ctx, store, dev)
+	if err == nil {
+		return t, nil
+	}
+	if used {
+		relErr := releaseProvisioningToken(ctx, store, token, dev.Mac)
+		if relErr != nil {
+			return nil, errors.Join(fmt.Errorf("could not create device: %w", err), fmt.Errorf("could not release token: %w", relErr))
+		}
+	}
+	return nil, fmt.Errorf("could not create device: %w", err)
+}
+
+// DeleteProvisioningToken deletes a token.
+func DeleteProvisioningToken(ctx context.Context, store datastore.Store, token int64) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{store.IDKey(typeProvisioningToken, token)})
+}
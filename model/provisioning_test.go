@@ -0,0 +1,121 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestProvisioningTokens tests creating and using provisioning tokens.
+func TestProvisioningTokens(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	toks, err := CreateProvisioningTokens(ctx, store, 1, 3, 2, time.Now().Add(time.Hour), "tech@ausocean.org")
+	if err != nil {
+		t.Fatalf("CreateProvisioningTokens returned error: %v", err)
+	}
+	if len(toks) != 3 {
+		t.Fatalf("CreateProvisioningTokens returned %d tokens, expected 3", len(toks))
+	}
+	for _, tok := range toks {
+		if len(strconv.FormatInt(tok.Token, 10)) != 16 {
+			t.Errorf("token %d does not have 16 digits", tok.Token)
+		}
+	}
+
+	tok := toks[0].Token
+	for mac := int64(1); mac <= 2; mac++ {
+		got, err := UseProvisioningToken(ctx, store, tok, mac)
+		if err != nil {
+			t.Fatalf("UseProvisioningToken returned error: %v", err)
+		}
+		if got.Skey != 1 || got.Uses != mac {
+			t.Errorf("UseProvisioningToken returned %+v", got)
+		}
+	}
+	_, err = UseProvisioningToken(ctx, store, tok, 3)
+	if !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("UseProvisioningToken returned %v, expected %v", err, ErrTokenUsed)
+	}
+	_, err = UseProvisioningToken(ctx, store, 1, 3)
+	if !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("UseProvisioningToken returned %v, expected %v", err, ErrTokenNotFound)
+	}
+
+	expired, err := CreateProvisioningTokens(ctx, store, 1, 1, 1, time.Now().Add(-time.Hour), "tech@ausocean.org")
+	if err != nil {
+		t.Fatalf("CreateProvisioningTokens returned error: %v", err)
+	}
+	_, err = UseProvisioningToken(ctx, store, expired[0].Token, 3)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("UseProvisioningToken returned %v, expected %v", err, ErrTokenExpired)
+	}
+
+	// Provisioning a device that exists does not use the token.
+	prov, err := CreateProvisioningTokens(ctx, store, 2, 1, 1, time.Now().Add(time.Hour), "tech@ausocean.org")
+	if err != nil {
+		t.Fatalf("CreateProvisioningTokens returned error: %v", err)
+	}
+	err = PutDevice(ctx, store, &Device{Skey: 3, Mac: 4})
+	if err != nil {
+		t.Fatalf("PutDevice returned error: %v", err)
+	}
+	_, err = ProvisionDevice(ctx, store, prov[0].Token, &Device{Mac: 4})
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		t.Errorf("ProvisionDevice returned %v, expected %v", err, datastore.ErrEntityExists)
+	}
+	got, err := ProvisionDevice(ctx, store, prov[0].Token, &Device{Mac: 5})
+	if err != nil {
+		t.Fatalf("ProvisionDevice returned error: %v", err)
+	}
+	if got.Uses != 1 || got.Devices != MacDecode(5) {
+		t.Errorf("ProvisionDevice returned %+v", got)
+	}
+	dev, err := GetDevice(ctx, store, 5)
+	if err != nil || dev.Skey != 2 {
+		t.Errorf("GetDevice returned %+v, %v, expected device in site 2", dev, err)
+	}
+	// Retrying with the same device does not use the token again.
+	_, err = UseProvisioningToken(ctx, store, prov[0].Token, 5)
+	if err != nil {
+		t.Errorf("UseProvisioningToken for the same device returned error: %v", err)
+	}
+
+	all, err := GetProvisioningTokens(ctx, store, 1)
+	if err != nil {
+		t.Fatalf("GetProvisioningTokens returned error: %v", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("GetProvisioningTokens returned %d tokens, expected 4", len(all))
+	}
+	if all[0].Status() == TokenOutstanding && all[0].Token == tok {
+		t.Errorf("used token is outstanding")
+	}
+}
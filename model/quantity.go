@@ -0,0 +1,282 @@
+/*
+DESCRIPTION
+  Standard quantities, their canonical units and unit conversion.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ausocean/utils/nmea"
+)
+
+// Quantities that are not defined by NMEA.
+const (
+	qtySalinity  nmea.Code = "SAL"
+	typeSalinity nmea.Type = "salinity"
+)
+
+// Additional units used by sensors. See also the units defined in sensor.go.
+const (
+	unitFahrenheit Unit = "F"
+	unitKelvin     Unit = "K"
+	unitMillivolt  Unit = "mV"
+	unitMetre      Unit = "m"
+	unitCentimetre Unit = "cm"
+	unitMillimetre Unit = "mm"
+	unitKilometre  Unit = "km"
+	unitFoot       Unit = "ft"
+	unitFathom     Unit = "fathom"
+	unitPSU        Unit = "PSU"
+	unitPPT        Unit = "ppt"
+	unitHectopasc  Unit = "hPa"
+	unitMillibar   Unit = "mbar"
+	unitKilopasc   Unit = "kPa"
+	unitPascal     Unit = "Pa"
+	unitMPS        Unit = "m/s"
+	unitKPH        Unit = "km/h"
+	unitKnot       Unit = "kn"
+	unitDegree     Unit = "deg"
+	unitRadian     Unit = "rad"
+)
+
+// Exported errors.
+var (
+	ErrUnknownQuantity = errors.New("unknown quantity")
+	ErrInvalidUnit     = errors.New("invalid unit for quantity")
+)
+
+// conversion defines a linear conversion from a unit to the
+// canonical unit of its quantity type, i.e., canonical = v*scale + offset.
+type conversion struct {
+	scale  float64
+	offset float64
+}
+
+// canonicalUnits maps a quantity type to its canonical unit. Types
+// without an entry, e.g., audio or video, are unitless.
+var canonicalUnits = map[nmea.Type]Unit{
+	nmea.TypeTemperature: unitCelsius,
+	nmea.TypeVoltage:     unitVoltage,
+	nmea.TypeLength:      unitMetre,
+	nmea.TypeDistance:    unitMetre,
+	nmea.TypePercent:     unitPercent,
+	nmea.TypePressure:    unitHectopasc,
+	nmea.TypeSpeed:       unitMPS,
+	nmea.TypeAngle:       unitDegree,
+	typeSalinity:         unitPSU,
+}
+
+// lengthUnits are shared by length and distance quantities.
+var lengthUnits = map[Unit]conversion{
+	unitMetre:      {1, 0},
+	unitCentimetre: {0.01, 0},
+	unitMillimetre: {0.001, 0},
+	unitKilometre:  {1000, 0},
+	unitFoot:       {0.3048, 0},
+	unitFathom:     {1.8288, 0},
+}
+
+// quantityUnits maps a quantity type to its accepted units and their
+// conversions to the canonical unit.
+var quantityUnits = map[nmea.Type]map[Unit]conversion{
+	nmea.TypeTemperature: {
+		unitCelsius:    {1, 0},
+		unitFahrenheit: {5.0 / 9.0, -160.0 / 9.0},
+		unitKelvin:     {1, -273.15},
+	},
+	nmea.TypeVoltage: {
+		unitVoltage:   {1, 0},
+		unitMillivolt: {0.001, 0},
+	},
+	nmea.TypeLength:   lengthUnits,
+	nmea.TypeDistance: lengthUnits,
+	nmea.TypePercent: {
+		unitPercent: {1, 0},
+	},
+	nmea.TypePressure: {
+		unitHectopasc: {1, 0},
+		unitMillibar:  {1, 0},
+		unitKilopasc:  {10, 0},
+		unitPascal:    {0.01, 0},
+	},
+	nmea.TypeSpeed: {
+		unitMPS:  {1, 0},
+		unitKPH:  {1 / 3.6, 0},
+		unitKnot: {1852.0 / 3600.0, 0},
+	},
+	nmea.TypeAngle: {
+		unitDegree: {1, 0},
+		unitRadian: {180 / math.Pi, 0},
+	},
+	typeSalinity: {
+		unitPSU: {1, 0},
+		unitPPT: {1, 0},
+	},
+}
+
+// Quantities returns the registry of standard quantities, namely the
+// NMEA default quantities together with those defined by AusOcean.
+func Quantities() []nmea.Quantity {
+	var qtys []nmea.Quantity
+	for _, q := range nmea.DefaultQuantities() {
+		if q.Code == nmea.Other {
+			// Keep "Other" last.
+			qtys = append(qtys, nmea.Quantity{Code: qtySalinity, Name: "Salinity", Type: typeSalinity})
+		}
+		qtys = append(qtys, q)
+	}
+	return qtys
+}
+
+// GetQuantity returns the standard quantity for the given code, or
+// ErrUnknownQuantity.
+func GetQuantity(code string) (nmea.Quantity, error) {
+	for _, q := range Quantities() {
+		if string(q.Code) == code {
+			return q, nil
+		}
+	}
+	return nmea.Quantity{}, fmt.Errorf("%w: %s", ErrUnknownQuantity, code)
+}
+
+// CanonicalUnit returns the canonical unit for the quantity with the
+// given code, or the empty unit if the quantity is unitless.
+func CanonicalUnit(code string) (Unit, error) {
+	q, err := GetQuantity(code)
+	if err != nil {
+		return "", err
+	}
+	return canonicalUnits[q.Type], nil
+}
+
+// QuantityUnits returns the units accepted for the quantity with the
+// given code, with the canonical unit first.
+func QuantityUnits(code string) ([]Unit, error) {
+	q, err := GetQuantity(code)
+	if err != nil {
+		return nil, err
+	}
+	canonical, ok := canonicalUnits[q.Type]
+	if !ok {
+		return nil, nil
+	}
+	var units []Unit
+	for u := range quantityUnits[q.Type] {
+		if u != canonical {
+			units = append(units, u)
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i] < units[j] })
+	return append([]Unit{canonical}, units...), nil
+}
+
+// NormalizeUnit returns the registered spelling of unit for the
+// quantity with the given code. Matching ignores case, surrounding
+// space and any degree sign, so that "°c" is normalized to "C". An
+// empty unit is normalized to the canonical unit. ErrInvalidUnit is
+// returned if the unit is not accepted for the quantity.
+func NormalizeUnit(code, unit string) (Unit, error) {
+	q, err := GetQuantity(code)
+	if err != nil {
+		return "", err
+	}
+	units, ok := quantityUnits[q.Type]
+	if !ok {
+		return Unit(unit), nil // Unitless quantities accept anything.
+	}
+	unit = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(unit), "°"))
+	if unit == "" {
+		return canonicalUnits[q.Type], nil
+	}
+	for u := range units {
+		if strings.EqualFold(string(u), unit) {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("%w %s: %s", ErrInvalidUnit, q.Name, unit)
+}
+
+// ConvertUnits converts the value v of the quantity with the given
+// code from one unit to another.
+func ConvertUnits(code string, v float64, from, to Unit) (float64, error) {
+	q, err := GetQuantity(code)
+	if err != nil {
+		return 0, err
+	}
+	from, err = NormalizeUnit(code, string(from))
+	if err != nil {
+		return 0, err
+	}
+	to, err = NormalizeUnit(code, string(to))
+	if err != nil {
+		return 0, err
+	}
+	if from == to {
+		return v, nil
+	}
+	units := quantityUnits[q.Type]
+	f, t := units[from], units[to]
+	canonical := v*f.scale + f.offset
+	return (canonical - t.offset) / t.scale, nil
+}
+
+// ValidateUnits checks that the sensor's units are accepted for its
+// quantity and normalizes them, defaulting empty units to the
+// canonical unit. Sensors with non-standard quantities are left as is.
+func (s *SensorV2) ValidateUnits() error {
+	if s.Quantity == "" {
+		return nil
+	}
+	u, err := NormalizeUnit(s.Quantity, s.Units)
+	switch {
+	case errors.Is(err, ErrUnknownQuantity):
+		return nil
+	case err != nil:
+		return err
+	}
+	s.Units = string(u)
+	return nil
+}
+
+// CanonicalValue converts a transformed sensor value to the canonical
+// unit for the sensor's quantity, returning the converted value and
+// the canonical unit. This allows downstream exports to rely on
+// consistent units regardless of how an individual sensor is
+// configured. Values of sensors with non-standard or unitless
+// quantities are returned unchanged, along with the sensor's units.
+func (s *SensorV2) CanonicalValue(v float64) (float64, Unit, error) {
+	canonical, err := CanonicalUnit(s.Quantity)
+	switch {
+	case errors.Is(err, ErrUnknownQuantity), canonical == "":
+		return v, Unit(s.Units), nil
+	case err != nil:
+		return v, Unit(s.Units), err
+	}
+	v, err = ConvertUnits(s.Quantity, v, Unit(s.Units), canonical)
+	if err != nil {
+		return v, Unit(s.Units), err
+	}
+	return v, canonical, nil
+}
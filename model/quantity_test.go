@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ausocean/utils/nmea"
+)
+
+// TestConvertUnits tests unit conversion between units of the same quantity.
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		code     nmea.Code
+		v        float64
+		from, to Unit
+		want     float64
+		wantErr  error
+	}{
+		{code: nmea.WaterTemperature, v: 212, from: "F", to: "C", want: 100},
+		{code: nmea.WaterTemperature, v: 0, from: "°C", to: "K", want: 273.15},
+		{code: nmea.AirTemperature, v: 20, from: "c", to: "c", want: 20},
+		{code: nmea.DCVoltage, v: 12500, from: "mV", to: "V", want: 12.5},
+		{code: nmea.Depth, v: 10, from: "fathom", to: "ft", want: 60},
+		{code: nmea.Precipitation, v: 25, from: "mm", to: "cm", want: 2.5},
+		{code: qtySalinity, v: 35, from: "ppt", to: "PSU", want: 35},
+		{code: nmea.Depth, v: 1, from: "m", to: "V", wantErr: ErrInvalidUnit},
+		{code: "XYZ", v: 1, from: "m", to: "m", wantErr: ErrUnknownQuantity},
+	}
+
+	for i, test := range tests {
+		got, err := ConvertUnits(string(test.code), test.v, test.from, test.to)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("unexpected error for test no. %d: got: %v, want: %v", i, err, test.wantErr)
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("unexpected result for test no. %d: got: %v, want: %v", i, got, test.want)
+		}
+	}
+}
+
+// TestValidateUnits tests sensor unit validation and normalization.
+func TestValidateUnits(t *testing.T) {
+	tests := []struct {
+		sensor    SensorV2
+		wantUnits string
+		wantErr   error
+	}{
+		{sensor: SensorV2{Quantity: string(nmea.WaterTemperature), Units: "°c"}, wantUnits: "C"},
+		{sensor: SensorV2{Quantity: string(nmea.DCVoltage)}, wantUnits: "V"},
+		{sensor: SensorV2{Quantity: string(qtySalinity), Units: "psu"}, wantUnits: "PSU"},
+		{sensor: SensorV2{Quantity: string(nmea.Precipitation), Units: "mm"}, wantUnits: "mm"},
+		{sensor: SensorV2{Quantity: string(nmea.Depth), Units: "knots"}, wantErr: ErrInvalidUnit},
+		{sensor: SensorV2{Quantity: string(nmea.Video), Units: "fps"}, wantUnits: "fps"},
+		{sensor: SensorV2{Quantity: "custom", Units: "widgets"}, wantUnits: "widgets"},
+	}
+
+	for i, test := range tests {
+		err := test.sensor.ValidateUnits()
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("unexpected error for test no. %d: got: %v, want: %v", i, err, test.wantErr)
+			continue
+		}
+		if err == nil && test.sensor.Units != test.wantUnits {
+			t.Errorf("unexpected units for test no. %d: got: %s, want: %s", i, test.sensor.Units, test.wantUnits)
+		}
+	}
+}
+
+// TestCanonicalValue tests conversion of sensor values to canonical units.
+func TestCanonicalValue(t *testing.T) {
+	s := SensorV2{Quantity: string(nmea.AirTemperature), Units: "F"}
+	v, u, err := s.CanonicalValue(32)
+	if err != nil {
+		t.Fatalf("CanonicalValue returned unexpected error: %v", err)
+	}
+	if u != unitCelsius || math.Abs(v) > 1e-9 {
+		t.Errorf("unexpected canonical value: got: %v %s, want: 0 C", v, u)
+	}
+
+	s = SensorV2{Quantity: "custom", Units: "widgets"}
+	v, u, err = s.CanonicalValue(7)
+	if err != nil || u != "widgets" || v != 7 {
+		t.Errorf("unexpected canonical value for non-standard quantity: got: %v %s %v, want: 7 widgets <nil>", v, u, err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+DESCRIPTION
+  QueuedEdit datastore type and functions, which make settings edits
+  queued offline and replayed idempotent.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeQueuedEdit = "QueuedEdit" // QueuedEdit datastore type.
+
+// QueuedEditTTL is how long a queued edit is remembered, i.e., the
+// window within which a replayed edit is recognized. Edits may be
+// queued offline for days, so this is generous.
+const QueuedEditTTL = 30 * 24 * time.Hour
+
+// queuedEditPurgeBatch is the number of expired edits deleted at a time.
+const queuedEditPurgeBatch = 500
+
+// ErrQueuedEditApplied is returned when claiming a queued edit that
+// has already been applied.
+var ErrQueuedEditApplied = errors.New("queued edit already applied")
+
+// QueuedEdit records a settings edit that was queued offline,
+// identified by an idempotency key chosen by the client, so that an
+// edit replayed more than once is applied only once. Expired edits are
+// ignored, and are deleted by DeleteExpiredQueuedEdits. The key is the
+// idempotency key.
+type QueuedEdit struct {
+	Key     string    // Idempotency key.
+	Created time.Time // Date/time created.
+	Expires time.Time // Date/time after which the edit is forgotten.
+}
+
+// Copy copies a queued edit to dst, or returns a copy of the queued edit when dst is nil.
+func (e *QueuedEdit) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var e2 *QueuedEdit
+	if dst == nil {
+		e2 = new(QueuedEdit)
+	} else {
+		var ok bool
+		e2, ok = dst.(*QueuedEdit)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*e2 = *e
+	return e2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (e *QueuedEdit) GetCache() datastore.Cache {
+	return nil
+}
+
+// ClaimQueuedEdit claims a queued edit before it is applied, returning
+// ErrQueuedEditApplied if it was claimed before, in which case it must
+// not be applied again. Checking for and claiming the edit is a single
+// transaction, so that concurrent replays are not both applied. If
+// applying the edit fails, the caller should call DeleteQueuedEdit so
+// that it can be replayed.
+func ClaimQueuedEdit(ctx context.Context, store datastore.Store, key string) error {
+	now := time.Now()
+	claim := &QueuedEdit{Key: key, Created: now, Expires: now.Add(QueuedEditTTL)}
+	k := store.NameKey(typeQueuedEdit, key)
+	err := store.Create(ctx, k, claim)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		return fmt.Errorf("could not create queued edit: %w", err)
+	}
+
+	// Take over expired edits.
+	var claimErr error
+	err = store.Update(ctx, k, func(e datastore.Entity) {
+		e2, ok := e.(*QueuedEdit)
+		if !ok {
+			claimErr = datastore.ErrWrongType
+			return
+		}
+		if now.After(e2.Expires) {
+			*e2 = *claim
+			return
+		}
+		claimErr = ErrQueuedEditApplied
+	}, new(QueuedEdit))
+	if err != nil {
+		return fmt.Errorf("could not update queued edit: %w", err)
+	}
+	return claimErr
+}
+
+// DeleteQueuedEdit deletes a queued edit, which releases its claim.
+func DeleteQueuedEdit(ctx context.Context, store datastore.Store, key string) error {
+	return store.Delete(ctx, store.NameKey(typeQueuedEdit, key))
+}
+
+// DeleteExpiredQueuedEdits deletes queued edits that expired before t,
+// returning the number deleted.
+func DeleteExpiredQueuedEdits(ctx context.Context, store datastore.Store, t time.Time) (int, error) {
+	_, filestore := store.(*datastore.FileStore)
+	if filestore {
+		// The file store only filters on key parts, so get edits one at a time.
+		all, err := store.GetAll(ctx, store.NewQuery(typeQueuedEdit, true), nil)
+		if err != nil {
+			return 0, err
+		}
+		var keys []*datastore.Key
+		for _, k := range all {
+			var e QueuedEdit
+			err = store.Get(ctx, k, &e)
+			if err != nil {
+				return 0, err
+			}
+			if e.Expires.Before(t) {
+				keys = append(keys, k)
+			}
+		}
+		return len(keys), store.DeleteMulti(ctx, keys)
+	}
+
+	var n int
+	for {
+		q := store.NewQuery(typeQueuedEdit, true)
+		q.Filter("Expires <", t)
+		q.Limit(queuedEditPurgeBatch)
+		keys, err := store.GetAll(ctx, q, nil)
+		if err != nil {
+			return n, err
+		}
+		if len(keys) == 0 {
+			return n, nil
+		}
+		err = store.DeleteMulti(ctx, keys)
+		if err != nil {
+			return n, err
+		}
+		n += len(keys)
+		if len(keys) < queuedEditPurgeBatch {
+			return n, nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestClaimQueuedEdit tests claiming queued edits.
+func TestClaimQueuedEdit(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	err = ClaimQueuedEdit(ctx, store, "abc")
+	if err != nil {
+		t.Fatalf("ClaimQueuedEdit returned error: %v", err)
+	}
+	err = ClaimQueuedEdit(ctx, store, "abc")
+	if !errors.Is(err, ErrQueuedEditApplied) {
+		t.Errorf("ClaimQueuedEdit of replayed edit returned %v, expected ErrQueuedEditApplied", err)
+	}
+
+	// A failed edit releases its claim.
+	err = DeleteQueuedEdit(ctx, store, "abc")
+	if err != nil {
+		t.Fatalf("DeleteQueuedEdit returned error: %v", err)
+	}
+	err = ClaimQueuedEdit(ctx, store, "abc")
+	if err != nil {
+		t.Errorf("ClaimQueuedEdit of released edit returned error: %v", err)
+	}
+
+	// An expired edit is taken over, and is purged.
+	_, err = store.Put(ctx, store.NameKey(typeQueuedEdit, "abd"), &QueuedEdit{Key: "abd", Expires: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("could not put edit: %v", err)
+	}
+	n, err := DeleteExpiredQueuedEdits(ctx, store, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteExpiredQueuedEdits returned error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteExpiredQueuedEdits deleted %d edits, expected 1", n)
+	}
+	_, err = store.Put(ctx, store.NameKey(typeQueuedEdit, "abd"), &QueuedEdit{Key: "abd", Expires: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("could not put edit: %v", err)
+	}
+	err = ClaimQueuedEdit(ctx, store, "abd")
+	if err != nil {
+		t.Errorf("ClaimQueuedEdit of expired edit returned error: %v", err)
+	}
+}
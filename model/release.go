@@ -0,0 +1,256 @@
+/*
+DESCRIPTION
+  Release and DeviceRelease datastore types and functions, which
+  implement staged software rollouts.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/cloud/utils"
+	"github.com/ausocean/openfish/datastore"
+)
+
+const (
+	typeRelease       = "Release"       // Release datastore type.
+	typeDeviceRelease = "DeviceRelease" // DeviceRelease datastore type.
+)
+
+// Release stages. A release starts as a canary, which is assigned to
+// a percentage of devices, and is then promoted to the whole fleet.
+// A halted release is not assigned to any devices.
+const (
+	ReleaseCanary = "canary"
+	ReleaseFleet  = "fleet"
+	ReleaseHalted = "halted"
+)
+
+// Device release statuses.
+const (
+	DeviceReleaseAssigned  = "assigned"
+	DeviceReleaseInstalled = "installed"
+	DeviceReleaseFailed    = "failed"
+)
+
+// Release represents a software or firmware release for a given
+// device type, e.g., "camera".
+type Release struct {
+	ID       int64     // Release ID.
+	Type     string    // Target device type.
+	Version  string    // Release version.
+	URL      string    // Artifact URL, or GCS object, e.g., gs://bucket/object.
+	Checksum string    // SHA-256 checksum of the artifact, in hex.
+	Canary   int64     // Percentage of devices assigned while a canary.
+	Stage    string    // Release stage, i.e., canary, fleet or halted.
+	Notes    string    `datastore:",noindex"` // Release notes, or reason for halting.
+	Created  time.Time // Date/time created.
+	Updated  time.Time // Date/time last updated.
+}
+
+// Copy copies a release to dst, or returns a copy of the release when dst is nil.
+func (r *Release) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var r2 *Release
+	if dst == nil {
+		r2 = new(Release)
+	} else {
+		var ok bool
+		r2, ok = dst.(*Release)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*r2 = *r
+	return r2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (r *Release) GetCache() datastore.Cache {
+	return nil
+}
+
+// Assigned returns true if the release is assigned to the device with
+// the given MAC address. Canary releases are assigned to a fixed
+// subset of devices, determined by hashing the MAC address and the
+// release ID.
+func (r *Release) Assigned(mac int64) bool {
+	switch r.Stage {
+	case ReleaseFleet:
+		return true
+	case ReleaseCanary:
+		h := fnv.New32a()
+		h.Write([]byte(strconv.FormatInt(mac, 10) + "." + strconv.FormatInt(r.ID, 10)))
+		return int64(h.Sum32()%100) < r.Canary
+	default:
+		return false
+	}
+}
+
+// CreateRelease creates a release with a unique ID, starting as a canary.
+func CreateRelease(ctx context.Context, store datastore.Store, r *Release) error {
+	r.Stage = ReleaseCanary
+	r.Created = time.Now()
+	r.Updated = r.Created
+	for {
+		r.ID = utils.GenerateInt64ID()
+		err := store.Create(ctx, store.IDKey(typeRelease, r.ID), r)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrEntityExists {
+			return fmt.Errorf("could not create release: %w", err)
+		}
+	}
+}
+
+// PutRelease updates a release.
+func PutRelease(ctx context.Context, store datastore.Store, r *Release) error {
+	r.Updated = time.Now()
+	_, err := store.Put(ctx, store.IDKey(typeRelease, r.ID), r)
+	return err
+}
+
+// GetRelease gets a release.
+func GetRelease(ctx context.Context, store datastore.Store, id int64) (*Release, error) {
+	r := new(Release)
+	err := store.Get(ctx, store.IDKey(typeRelease, id), r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetReleases returns the releases for a device type, or all releases
+// if typ is empty, newest first.
+func GetReleases(ctx context.Context, store datastore.Store, typ string) ([]Release, error) {
+	q := store.NewQuery(typeRelease, false)
+	var all []Release
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var rs []Release
+	for _, r := range all {
+		if typ == "" || r.Type == typ {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Created.After(rs[j].Created) })
+	return rs, nil
+}
+
+// AssignedRelease returns the newest release in rs assigned to the
+// device, or nil if there is none or the device is already running
+// it. Releases are expected to be newest first, as returned by
+// GetReleases.
+func AssignedRelease(rs []Release, dev *Device) *Release {
+	for i := range rs {
+		r := &rs[i]
+		if r.Type != dev.Type || !r.Assigned(dev.Mac) {
+			continue
+		}
+		if r.Version == dev.Version {
+			return nil
+		}
+		return r
+	}
+	return nil
+}
+
+// DeviceRelease records the progress of a release on a device. The
+// key is the release ID concatenated with the MAC address.
+type DeviceRelease struct {
+	ReleaseID int64     // Release ID.
+	Mac       int64     // MAC address of device.
+	Status    string    // Status, i.e., assigned, installed or failed.
+	Error     string    `datastore:",noindex"` // Error reported by the device, if failed.
+	Updated   time.Time // Date/time last updated.
+}
+
+// Copy copies a device release to dst, or returns a copy of the device release when dst is nil.
+func (d *DeviceRelease) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var d2 *DeviceRelease
+	if dst == nil {
+		d2 = new(DeviceRelease)
+	} else {
+		var ok bool
+		d2, ok = dst.(*DeviceRelease)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*d2 = *d
+	return d2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (d *DeviceRelease) GetCache() datastore.Cache {
+	return nil
+}
+
+// deviceReleaseKey returns the key for a device release.
+func deviceReleaseKey(store datastore.Store, id, mac int64) *datastore.Key {
+	return store.NameKey(typeDeviceRelease, strconv.FormatInt(id, 10)+"."+strconv.FormatInt(mac, 10))
+}
+
+// PutDeviceRelease creates or updates a device release.
+func PutDeviceRelease(ctx context.Context, store datastore.Store, d *DeviceRelease) error {
+	d.Updated = time.Now()
+	_, err := store.Put(ctx, deviceReleaseKey(store, d.ReleaseID, d.Mac), d)
+	return err
+}
+
+// GetDeviceRelease gets a device release.
+func GetDeviceRelease(ctx context.Context, store datastore.Store, id, mac int64) (*DeviceRelease, error) {
+	d := new(DeviceRelease)
+	err := store.Get(ctx, deviceReleaseKey(store, id, mac), d)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetDeviceReleases returns the device releases for a release.
+func GetDeviceReleases(ctx context.Context, store datastore.Store, id int64) ([]DeviceRelease, error) {
+	q := store.NewQuery(typeDeviceRelease, false, "ReleaseID", "Mac")
+	q.Filter("ReleaseID =", id)
+	var ds []DeviceRelease
+	_, err := store.GetAll(ctx, q, &ds)
+	return ds, err
+}
+
+// ReleaseFailures returns the number of devices that have reported
+// the outcome of a release, and how many of those failed.
+func ReleaseFailures(ds []DeviceRelease) (reported, failed int) {
+	for _, d := range ds {
+		switch d.Status {
+		case DeviceReleaseInstalled:
+			reported++
+		case DeviceReleaseFailed:
+			reported++
+			failed++
+		}
+	}
+	return reported, failed
+}
@@ -0,0 +1,91 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestReleases tests release creation, assignment and failure counting.
+func TestReleases(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	r := &Release{Type: DevTypeCamera, Version: "v1.0.0", URL: "gs://releases/camera-v1.0.0.tar.gz", Canary: 10}
+	err = CreateRelease(ctx, store, r)
+	if err != nil {
+		t.Fatalf("CreateRelease returned error: %v", err)
+	}
+	rs, err := GetReleases(ctx, store, DevTypeCamera)
+	if err != nil {
+		t.Fatalf("GetReleases returned error: %v", err)
+	}
+	if len(rs) != 1 || rs[0].Stage != ReleaseCanary {
+		t.Fatalf("did not get expected releases, got: %v", rs)
+	}
+
+	// Roughly 10% of devices should be assigned a 10% canary.
+	const n = 1000
+	var assigned int
+	for mac := int64(1); mac <= n; mac++ {
+		if AssignedRelease(rs, &Device{Mac: mac, Type: DevTypeCamera}) != nil {
+			assigned++
+		}
+	}
+	if assigned < n/20 || assigned > n/5 {
+		t.Errorf("unexpected number of canary devices: %d of %d", assigned, n)
+	}
+
+	rs[0].Stage = ReleaseFleet
+	if AssignedRelease(rs, &Device{Mac: 1, Type: DevTypeCamera}) == nil {
+		t.Errorf("fleet release not assigned")
+	}
+	if AssignedRelease(rs, &Device{Mac: 1, Type: DevTypeCamera, Version: "v1.0.0"}) != nil {
+		t.Errorf("release assigned to device already running it")
+	}
+	if AssignedRelease(rs, &Device{Mac: 1, Type: DevTypeSpeaker}) != nil {
+		t.Errorf("release assigned to wrong device type")
+	}
+	rs[0].Stage = ReleaseHalted
+	if AssignedRelease(rs, &Device{Mac: 1, Type: DevTypeCamera}) != nil {
+		t.Errorf("halted release assigned")
+	}
+
+	for mac, status := range []string{DeviceReleaseAssigned, DeviceReleaseInstalled, DeviceReleaseFailed, DeviceReleaseInstalled} {
+		err = PutDeviceRelease(ctx, store, &DeviceRelease{ReleaseID: r.ID, Mac: int64(mac + 1), Status: status})
+		if err != nil {
+			t.Fatalf("PutDeviceRelease returned error: %v", err)
+		}
+	}
+	ds, err := GetDeviceReleases(ctx, store, r.ID)
+	if err != nil {
+		t.Fatalf("GetDeviceReleases returned error: %v", err)
+	}
+	reported, failed := ReleaseFailures(ds)
+	if reported != 3 || failed != 1 {
+		t.Errorf("ReleaseFailures returned %d, %d, expected 3, 1", reported, failed)
+	}
+}
@@ -135,7 +135,7 @@ func GetScalars(ctx context.Context, store datastore.Store, id int64, ts []int64
 	filterByTime(q, ts)
 
 	var data []Scalar
-	_, err := store.GetAll(ctx, q, &data)
+	_, err := getAll(ctx, store, q, &data, idxScalar)
 	return data, err
 }
 
@@ -145,7 +145,7 @@ func GetScalarKeys(ctx context.Context, store datastore.Store, id int64, ts []in
 	q.Filter("ID =", id)
 	filterByTime(q, ts)
 
-	return store.GetAll(ctx, q, nil)
+	return getAll(ctx, store, q, nil, idxScalar)
 }
 
 // filterByTime optionally adds timestamp filters to a Scalar query.
@@ -30,6 +30,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Knetic/govaluate"
 	"github.com/ausocean/openfish/datastore"
@@ -38,10 +39,15 @@ import (
 
 // The entitiy type as named in the datastore.
 const (
-	typeSensor   = "Sensor"
-	typeSensorV2 = "SensorV2"
+	typeSensor          = "Sensor"
+	typeSensorV2        = "SensorV2"
+	typeSensorDismissal = "SensorDismissal"
 )
 
+// ErrSensorDismissed is returned when provisioning a sensor for a
+// device pin whose provisional sensor was dismissed.
+var ErrSensorDismissed = errors.New("provisional sensor dismissed")
+
 // Number of arguments for each function type.
 const (
 	nArgsScale     = 1
@@ -352,7 +358,7 @@ func GetSensorsBySite(ctx context.Context, store datastore.Store, skey int64, de
 	q.Filter("skey =", skey)
 	q.Order("sid")
 	var all, toOut []Sensor
-	_, err := store.GetAll(ctx, q, &all)
+	_, err := getAll(ctx, store, q, &all, idxSensorSite)
 	if err != nil {
 		return nil, err
 	}
@@ -384,6 +390,13 @@ type SensorV2 struct {
 	Offset   float64 // Deprecated.
 	Units    string  // Units of transformed value.
 	Format   string  // Format of transformed value.
+	Min      float64 // Minimum valid transformed value.
+	Max      float64 // Maximum valid transformed value, ignored unless greater than Min.
+	MaxRate  float64 // Maximum valid rate of change per second, or 0 for no limit.
+
+	// Provisional is true for a sensor created automatically for a pin
+	// that sent data without a sensor, until it is confirmed by an admin.
+	Provisional bool
 }
 
 // Encode encodes a sensor as JSON.
@@ -436,6 +449,86 @@ func (s *SensorV2) Transform(v float64) (float64, error) {
 	return res, nil
 }
 
+// Validity is the outcome of validating a sensor reading.
+type Validity int
+
+// Validity values.
+const (
+	ReadingValid    Validity = iota // Reading is valid.
+	ReadingFlagged                  // Reading is stored but changed faster than MaxRate.
+	ReadingRejected                 // Reading is outside of Min and Max, or not a number, and is not stored.
+)
+
+// Validate checks the raw value v received at time ts against the
+// sensor's limits, which are expressed in transformed units. When
+// non-nil, prev is the previous raw reading and is used to check the
+// rate of change.
+func (s *SensorV2) Validate(v float64, ts int64, prev *Scalar) (Validity, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ReadingRejected, nil
+	}
+	if s.Max <= s.Min && s.MaxRate == 0 {
+		return ReadingValid, nil
+	}
+
+	x, err := s.Transform(v)
+	if err != nil {
+		return ReadingValid, err
+	}
+	if s.Max > s.Min && (x < s.Min || x > s.Max) {
+		return ReadingRejected, nil
+	}
+
+	if s.MaxRate == 0 || prev == nil || ts <= prev.Timestamp {
+		return ReadingValid, nil
+	}
+	px, err := s.Transform(prev.Value)
+	if err != nil {
+		return ReadingValid, err
+	}
+	if math.Abs(x-px)/float64(ts-prev.Timestamp) > s.MaxRate {
+		return ReadingFlagged, nil
+	}
+	return ReadingValid, nil
+}
+
+// Limits returns the sensor's limits as comma-separated min, max and
+// max rate values, or the empty string if there are none.
+func (s *SensorV2) Limits() string {
+	if s.Max <= s.Min && s.MaxRate == 0 {
+		return ""
+	}
+	return catArgs(Arg(s.Min)) + "," + catArgs(Arg(s.Max)) + "," + catArgs(Arg(s.MaxRate))
+}
+
+// SetLimits sets the sensor's limits from comma-separated min, max
+// and optional max rate values. An empty string clears the limits.
+func (s *SensorV2) SetLimits(limits string) error {
+	s.Min, s.Max, s.MaxRate = 0, 0, 0
+	if strings.TrimSpace(limits) == "" {
+		return nil
+	}
+	n := strings.Count(limits, ",") + 1
+	if n != 2 && n != 3 {
+		return fmt.Errorf("%w, got: %d, want: 2 or 3", ErrUnexpectedArgs, n)
+	}
+	args, err := parseArgs(limits, n)
+	if err != nil {
+		return fmt.Errorf("invalid limits: %w", err)
+	}
+	if args[1] <= args[0] {
+		return fmt.Errorf("invalid limits: max %v not greater than min %v", args[1], args[0])
+	}
+	s.Min, s.Max = args[0], args[1]
+	if n == 3 {
+		if args[2] < 0 {
+			return fmt.Errorf("invalid limits: negative rate %v", args[2])
+		}
+		s.MaxRate = args[2]
+	}
+	return nil
+}
+
 // PutSensorV2 creates/updates a sensor.
 func PutSensorV2(ctx context.Context, store datastore.Store, s *SensorV2) error {
 	k := store.NameKey(typeSensorV2, strconv.FormatInt(s.Mac, 10)+"."+s.Pin)
@@ -443,6 +536,28 @@ func PutSensorV2(ctx context.Context, store datastore.Store, s *SensorV2) error
 	return err
 }
 
+// CreateProvisionalSensorV2 creates a provisional sensor for a device
+// pin, which passes values through untransformed and is named after
+// the pin. It returns datastore.ErrEntityExists if the pin already has
+// a sensor, or ErrSensorDismissed if the pin's provisional sensor was
+// dismissed.
+func CreateProvisionalSensorV2(ctx context.Context, store datastore.Store, mac int64, pin string) (*SensorV2, error) {
+	err := store.Get(ctx, store.NameKey(typeSensorDismissal, strconv.FormatInt(mac, 10)+"."+pin), &SensorDismissal{})
+	switch {
+	case err == nil:
+		return nil, ErrSensorDismissed
+	case !errors.Is(err, datastore.ErrNoSuchEntity):
+		return nil, err
+	}
+	k := store.NameKey(typeSensorV2, strconv.FormatInt(mac, 10)+"."+pin)
+	s := &SensorV2{Name: pin, Mac: mac, Pin: pin, Func: string(funcNone), Provisional: true}
+	err = store.Create(ctx, k, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
 // GetSensorV2 gets a sensor.
 func GetSensorV2(ctx context.Context, store datastore.Store, mac int64, pin string) (*SensorV2, error) {
 	k := store.NameKey(typeSensorV2, strconv.FormatInt(mac, 10)+"."+pin)
@@ -469,6 +584,47 @@ func DeleteSensorV2(ctx context.Context, store datastore.Store, mac int64, pin s
 	return store.Delete(ctx, k)
 }
 
+// SensorDismissal records that the provisional sensor of a device pin
+// was dismissed by an admin, so that the pin is not provisioned, nor
+// ops notified, again.
+type SensorDismissal struct {
+	Mac     int64     // MAC address of the device.
+	Pin     string    // Pin of the dismissed sensor.
+	Created time.Time // Date/time dismissed.
+}
+
+// Copy copies a sensor dismissal to dst, or returns a copy of the dismissal when dst is nil.
+func (d *SensorDismissal) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var d2 *SensorDismissal
+	if dst == nil {
+		d2 = new(SensorDismissal)
+	} else {
+		var ok bool
+		d2, ok = dst.(*SensorDismissal)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*d2 = *d
+	return d2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (d *SensorDismissal) GetCache() datastore.Cache {
+	return nil
+}
+
+// DismissSensorV2 deletes the provisional sensor of a device pin and
+// records its dismissal, so that it is not provisioned again.
+func DismissSensorV2(ctx context.Context, store datastore.Store, mac int64, pin string) error {
+	k := store.NameKey(typeSensorDismissal, strconv.FormatInt(mac, 10)+"."+pin)
+	_, err := store.Put(ctx, k, &SensorDismissal{Mac: mac, Pin: pin, Created: time.Now()})
+	if err != nil {
+		return fmt.Errorf("could not put sensor dismissal: %w", err)
+	}
+	return DeleteSensorV2(ctx, store, mac, pin)
+}
+
 // shim is a thin shim between typed values and a sensor.
 func sensorShim(name string, pin Pin, qty nmea.Code, function Func, units Unit, format Format, args ...Arg) *SensorV2 {
 	return &SensorV2{
@@ -2,7 +2,9 @@ package model
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"math"
 	"reflect"
 	"strconv"
 	"testing"
@@ -91,6 +93,65 @@ func TestTransform(t *testing.T) {
 	}
 }
 
+// TestValidate tests the SensorV2.Validate method.
+func TestValidate(t *testing.T) {
+	bounded := SensorV2{Func: string(funcScale), Args: "0.1", Min: -5, Max: 40, MaxRate: 0.5}
+	tests := []struct {
+		sensor SensorV2
+		val    float64
+		ts     int64
+		prev   *Scalar
+		want   Validity
+	}{
+		{sensor: SensorV2{}, val: -999, want: ReadingValid},
+		{sensor: SensorV2{}, val: math.NaN(), want: ReadingRejected},
+		{sensor: bounded, val: 250, want: ReadingValid},
+		{sensor: bounded, val: -999, want: ReadingRejected},
+		{sensor: bounded, val: 401, want: ReadingRejected},
+		{sensor: bounded, val: 250, ts: 100, prev: &Scalar{Timestamp: 90, Value: 249}, want: ReadingValid},
+		{sensor: bounded, val: 250, ts: 100, prev: &Scalar{Timestamp: 90, Value: 150}, want: ReadingFlagged},
+		{sensor: bounded, val: 250, ts: 100, prev: &Scalar{Timestamp: 100, Value: 150}, want: ReadingValid},
+	}
+
+	for i, test := range tests {
+		got, err := test.sensor.Validate(test.val, test.ts, test.prev)
+		if err != nil {
+			t.Errorf("unexpected error for test no. %d: %v", i, err)
+		}
+		if got != test.want {
+			t.Errorf("did not get expected result for test no. %d, \ngot: %v, \nwant: %v", i, got, test.want)
+		}
+	}
+}
+
+// TestSetLimits tests the SensorV2.SetLimits and SensorV2.Limits methods.
+func TestSetLimits(t *testing.T) {
+	tests := []struct {
+		limits  string
+		want    string
+		wantErr bool
+	}{
+		{limits: "", want: ""},
+		{limits: "-5,40", want: "-5,40,0"},
+		{limits: "-5, 40, 0.5", want: "-5,40,0.5"},
+		{limits: "40,-5", wantErr: true},
+		{limits: "0,1,-1", wantErr: true},
+		{limits: "1", wantErr: true},
+		{limits: "0,num", wantErr: true},
+	}
+
+	for i, test := range tests {
+		var s SensorV2
+		err := s.SetLimits(test.limits)
+		if (err != nil) != test.wantErr {
+			t.Errorf("did not get expected error for test no. %d, \ngot: %v, \nwantErr: %v", i, err, test.wantErr)
+		}
+		if err == nil && s.Limits() != test.want {
+			t.Errorf("did not get expected limits for test no. %d, \ngot: %v, \nwant: %v", i, s.Limits(), test.want)
+		}
+	}
+}
+
 func TestSensorEncode(t *testing.T) {
 	tests := []struct {
 		Name   string
@@ -189,3 +250,57 @@ func TestSensorDecode(t *testing.T) {
 	}
 
 }
+
+// TestCreateProvisionalSensorV2 tests that provisional sensors are
+// only created for pins without a sensor.
+func TestCreateProvisionalSensorV2(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	err = PutSensorV2(ctx, store, &SensorV2{Name: "battery", Mac: 1, Pin: "A0"})
+	if err != nil {
+		t.Fatalf("PutSensorV2 returned error: %v", err)
+	}
+	_, err = CreateProvisionalSensorV2(ctx, store, 1, "A0")
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		t.Errorf("CreateProvisionalSensorV2 for existing sensor returned %v, expected ErrEntityExists", err)
+	}
+	s, err := GetSensorV2(ctx, store, 1, "A0")
+	if err != nil || s.Name != "battery" || s.Provisional {
+		t.Errorf("existing sensor changed to %+v, err: %v", s, err)
+	}
+
+	_, err = CreateProvisionalSensorV2(ctx, store, 1, "X20")
+	if err != nil {
+		t.Fatalf("CreateProvisionalSensorV2 returned error: %v", err)
+	}
+	s, err = GetSensorV2(ctx, store, 1, "X20")
+	if err != nil {
+		t.Fatalf("GetSensorV2 returned error: %v", err)
+	}
+	if !s.Provisional || s.Name != "X20" || s.Func != "none" {
+		t.Errorf("got provisional sensor %+v", s)
+	}
+	v, err := s.Transform(42)
+	if err != nil || v != 42 {
+		t.Errorf("provisional sensor transformed 42 to %v, err: %v", v, err)
+	}
+
+	// Dismissed sensors are not provisioned again.
+	err = DismissSensorV2(ctx, store, 1, "X20")
+	if err != nil {
+		t.Fatalf("DismissSensorV2 returned error: %v", err)
+	}
+	_, err = GetSensorV2(ctx, store, 1, "X20")
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("GetSensorV2 of dismissed sensor returned %v, expected ErrNoSuchEntity", err)
+	}
+	_, err = CreateProvisionalSensorV2(ctx, store, 1, "X20")
+	if !errors.Is(err, ErrSensorDismissed) {
+		t.Errorf("CreateProvisionalSensorV2 for dismissed sensor returned %v, expected ErrSensorDismissed", err)
+	}
+}
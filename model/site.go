@@ -50,7 +50,9 @@ type Site struct {
 	YouTubeEmail string
 	Latitude     float64
 	Longitude    float64
+	Geohash      string `json:"-"` // Geohash of the site's location, if set, which is derived from its latitude and longitude.
 	Timezone     float64
+	TimezoneName string `json:",omitempty"` // IANA timezone, e.g., "Australia/Adelaide", which takes precedence over Timezone.
 	NotifyPeriod int64
 	Enabled      bool
 	Confirmed    bool
@@ -66,9 +68,15 @@ func (site *Site) Encode() []byte {
 	return bytes
 }
 
-// Decode deserializes a Site from JSON.
+// Decode deserializes a Site from JSON, deriving its geohash, which
+// sites encoded before geohashing lack.
 func (site *Site) Decode(b []byte) error {
-	return json.Unmarshal(b, site)
+	err := json.Unmarshal(b, site)
+	if err != nil {
+		return err
+	}
+	site.Geohash = locationGeohash(site.Latitude, site.Longitude)
+	return nil
 }
 
 // Copy copies a site to dst, or returns a copy of the site when dst is nil.
@@ -87,31 +95,132 @@ func (site *Site) Copy(dst datastore.Entity) (datastore.Entity, error) {
 	return s, nil
 }
 
-var siteCache datastore.Cache = datastore.NewEntityCache()
+var siteCache datastore.Cache = NewTTLCache(siteCacheTTL)
 
 // GetCache returns the site cache.
 func (site *Site) GetCache() datastore.Cache {
 	return siteCache
 }
 
+// DefaultTimezoneName is the IANA timezone of sites with neither an
+// IANA timezone nor a UTC offset.
+const DefaultTimezoneName = "Australia/Adelaide"
+
+// Location returns the site's timezone, which is its IANA timezone if
+// set, otherwise its fixed UTC offset, if any, otherwise
+// DefaultTimezoneName. Only IANA timezones observe daylight saving
+// time.
+func (site *Site) Location() (*time.Location, error) {
+	name := site.TimezoneName
+	if name == "" && site.Timezone == 0 {
+		name = DefaultTimezoneName
+	}
+	if name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q for site %d: %w", name, site.Skey, err)
+		}
+		return loc, nil
+	}
+	return fixedZone(site.Timezone), nil
+}
+
+// fixedZone returns a location for a UTC offset in hours, e.g., 9.5.
+func fixedZone(offset float64) *time.Location {
+	return time.FixedZone(fmt.Sprintf("UTC%+g", offset), int(offset*3600))
+}
+
+// LocalTime returns t in the site's local time. Sites with an invalid
+// IANA timezone fall back to their UTC offset.
+func LocalTime(site *Site, t time.Time) time.Time {
+	loc, err := site.Location()
+	if err != nil {
+		loc = fixedZone(site.Timezone)
+	}
+	return t.In(loc)
+}
+
+// SiteNow returns the current time in the site's local time.
+func SiteNow(site *Site) time.Time {
+	return LocalTime(site, time.Now())
+}
+
+// MigrateSiteTimezone sets a site's IANA timezone from its UTC offset
+// and latitude, if not already set, returning the timezone set, if
+// any. Sites whose offset does not correspond to a known timezone are
+// left unchanged.
+func MigrateSiteTimezone(ctx context.Context, store datastore.Store, skey int64) (string, error) {
+	site, err := GetSite(ctx, store, skey)
+	if err != nil {
+		return "", fmt.Errorf("could not get site: %w", err)
+	}
+	if site.TimezoneName != "" {
+		return "", nil
+	}
+	name := timezoneName(site.Timezone, site.Latitude)
+	if name == "" {
+		return "", nil
+	}
+	site.TimezoneName = name
+	err = PutSite(ctx, store, site)
+	if err != nil {
+		return "", fmt.Errorf("could not put site: %w", err)
+	}
+	return name, nil
+}
+
+// timezoneName returns the IANA timezone for a UTC offset in hours.
+// Australian offsets, which are ambiguous since only some states
+// observe daylight saving time, are disambiguated by latitude, i.e.,
+// whether north of the South Australia or New South Wales borders.
+// Other whole-hour offsets use fixed Etc timezones, whose signs are
+// inverted by convention. Otherwise, the empty string is returned.
+func timezoneName(offset, lat float64) string {
+	switch offset {
+	case 0:
+		return ""
+	case 8:
+		return "Australia/Perth"
+	case 9.5:
+		if lat > -26 {
+			return "Australia/Darwin"
+		}
+		return "Australia/Adelaide"
+	case 10:
+		if lat > -28.2 {
+			return "Australia/Brisbane"
+		}
+		return "Australia/Sydney"
+	}
+	if offset != float64(int(offset)) || offset < -12 || offset > 14 {
+		return ""
+	}
+	return fmt.Sprintf("Etc/GMT%+d", -int(offset))
+}
+
 // PutSite creates or updates a site.
 func PutSite(ctx context.Context, store datastore.Store, site *Site) error {
+	site.Geohash = locationGeohash(site.Latitude, site.Longitude)
 	key := store.IDKey(typeSite, site.Skey)
 	_, err := store.Put(ctx, key, site)
+	invalidate(siteCache, key)
 	return err
 }
 
 // CreateSite creates a site, or returns an error if a site with the given key exists.
 func CreateSite(ctx context.Context, store datastore.Store, site *Site) error {
+	site.Geohash = locationGeohash(site.Latitude, site.Longitude)
 	key := store.IDKey(typeSite, site.Skey)
-	return store.Create(ctx, key, site)
+	err := store.Create(ctx, key, site)
+	invalidate(siteCache, key)
+	return err
 }
 
 // GetSite returns a site by its site key.
 func GetSite(ctx context.Context, store datastore.Store, skey int64) (*Site, error) {
 	key := store.IDKey(typeSite, skey)
 	var site Site
-	err := store.Get(ctx, key, &site)
+	err := getCached(ctx, store, key, &site)
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +276,9 @@ func GetUserSites(ctx context.Context, store datastore.Store, email string) ([]U
 // DeleteSite deletes a site.
 func DeleteSite(ctx context.Context, store datastore.Store, skey int64) error {
 	key := store.IDKey(typeSite, skey)
-	return store.DeleteMulti(ctx, []*datastore.Key{key})
+	err := store.DeleteMulti(ctx, []*datastore.Key{key})
+	invalidate(siteCache, key)
+	return err
 }
 
 // GetSiteName is a helper function that returns the site name string given the site key.
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestLocalTime tests converting times to site local time, including
+// across daylight saving transitions.
+func TestLocalTime(t *testing.T) {
+	tests := []struct {
+		site Site
+		t    time.Time
+		want string
+	}{
+		{site: Site{TimezoneName: "Australia/Adelaide"}, t: time.Date(2026, 4, 4, 14, 0, 0, 0, time.UTC), want: "2026-04-05T00:30:00+10:30"},
+		{site: Site{TimezoneName: "Australia/Adelaide"}, t: time.Date(2026, 4, 5, 14, 0, 0, 0, time.UTC), want: "2026-04-05T23:30:00+09:30"},
+		{site: Site{TimezoneName: "Australia/Perth", Timezone: 9.5}, t: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: "2026-10-16T08:00:00+08:00"},
+		{site: Site{Timezone: 9.5}, t: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: "2026-10-16T09:30:00+09:30"},
+		{site: Site{}, t: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: "2026-10-16T10:30:00+10:30"},
+		{site: Site{TimezoneName: "Mars/Olympus", Timezone: 10}, t: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: "2026-10-16T10:00:00+10:00"},
+	}
+	for i, test := range tests {
+		got := LocalTime(&test.site, test.t).Format(time.RFC3339)
+		if got != test.want {
+			t.Errorf("LocalTime %d returned %s, expected %s", i, got, test.want)
+		}
+	}
+}
+
+// TestMigrateSiteTimezone tests setting IANA timezones from offsets.
+func TestMigrateSiteTimezone(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	tests := []struct {
+		site Site
+		want string
+	}{
+		{site: Site{Skey: 1, Timezone: 9.5, Latitude: -35.5}, want: "Australia/Adelaide"},
+		{site: Site{Skey: 2, Timezone: 9.5, Latitude: -12.4}, want: "Australia/Darwin"},
+		{site: Site{Skey: 3, Timezone: 10, Latitude: -27.5}, want: "Australia/Brisbane"},
+		{site: Site{Skey: 4, Timezone: 10, Latitude: -42.9}, want: "Australia/Sydney"},
+		{site: Site{Skey: 5, Timezone: -5}, want: "Etc/GMT+5"},
+		{site: Site{Skey: 6, Timezone: 5.75}},
+		{site: Site{Skey: 7}},
+		{site: Site{Skey: 8, Timezone: 8, TimezoneName: "Asia/Singapore"}},
+	}
+	for _, test := range tests {
+		err := PutSite(ctx, store, &test.site)
+		if err != nil {
+			t.Fatalf("PutSite failed with error: %v", err)
+		}
+		got, err := MigrateSiteTimezone(ctx, store, test.site.Skey)
+		if err != nil {
+			t.Fatalf("MigrateSiteTimezone failed with error: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("MigrateSiteTimezone for site %d returned %q, expected %q", test.site.Skey, got, test.want)
+		}
+		site, err := GetSite(ctx, store, test.site.Skey)
+		if err != nil {
+			t.Fatalf("GetSite failed with error: %v", err)
+		}
+		if test.want != "" && site.TimezoneName != test.want {
+			t.Errorf("site %d has timezone %q, expected %q", test.site.Skey, site.TimezoneName, test.want)
+		}
+		if _, err := site.Location(); err != nil {
+			t.Errorf("site %d has invalid timezone: %v", test.site.Skey, err)
+		}
+	}
+}
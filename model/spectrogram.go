@@ -0,0 +1,223 @@
+/*
+DESCRIPTION
+  Spectrogram datastore type and functions. Spectrograms serve as both
+  the queue of spectrogram generation jobs and the cache of generated
+  spectrogram images.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeSpectrogram = "Spectrogram" // Spectrogram datastore type.
+
+// Spectrogram statuses.
+const (
+	SpectrogramPending    = "pending"    // Waiting to be generated.
+	SpectrogramProcessing = "processing" // Claimed by a worker.
+	SpectrogramDone       = "done"       // Generated.
+	SpectrogramFailed     = "failed"     // Could not be generated.
+)
+
+// SpectrogramAttempts is the maximum number of times generating a
+// spectrogram is attempted before it is failed.
+const SpectrogramAttempts = 3
+
+// ErrSpectrogramClaimed is returned when claiming a spectrogram that
+// is not pending.
+var ErrSpectrogramClaimed = errors.New("spectrogram already claimed")
+
+// Spectrogram represents a request to generate a spectrogram image for
+// the audio of a MID within a time range, which once generated caches
+// the image. The key is the MID, start and end, so that requests for
+// the same audio share a spectrogram.
+type Spectrogram struct {
+	MID       int64     // Media ID.
+	Start     int64     // Start of the time range, as a Unix time.
+	End       int64     // End of the time range, as a Unix time.
+	Status    string    // Status, one of the above.
+	Attempts  int64     // Number of attempts to generate.
+	Error     string    `datastore:",noindex"` // Error from the last failed attempt, if any.
+	Image     []byte    `datastore:",noindex"` // PNG image.
+	Requester string    // Email address of the user who requested the spectrogram.
+	Created   time.Time // Date/time requested.
+	Updated   time.Time // Date/time last updated.
+}
+
+// Copy copies a spectrogram to dst, or returns a copy of the spectrogram when dst is nil.
+func (s *Spectrogram) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var s2 *Spectrogram
+	if dst == nil {
+		s2 = new(Spectrogram)
+	} else {
+		var ok bool
+		s2, ok = dst.(*Spectrogram)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*s2 = *s
+	s2.Image = append([]byte(nil), s.Image...)
+	return s2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (s *Spectrogram) GetCache() datastore.Cache {
+	return nil
+}
+
+// spectrogramKey returns the datastore key for a spectrogram.
+func spectrogramKey(store datastore.Store, mid, start, end int64) *datastore.Key {
+	return store.NameKey(typeSpectrogram, fmt.Sprintf("%d.%d.%d", mid, start, end))
+}
+
+// RequestSpectrogram requests a spectrogram for the audio of a MID
+// within a time range, returning the existing spectrogram if it has
+// already been requested, or a new pending one otherwise.
+func RequestSpectrogram(ctx context.Context, store datastore.Store, mid, start, end int64, requester string) (*Spectrogram, error) {
+	now := time.Now()
+	s := &Spectrogram{MID: mid, Start: start, End: end, Status: SpectrogramPending, Requester: requester, Created: now, Updated: now}
+	err := store.Create(ctx, spectrogramKey(store, mid, start, end), s)
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, datastore.ErrEntityExists) {
+		return nil, fmt.Errorf("could not create spectrogram: %w", err)
+	}
+	return GetSpectrogram(ctx, store, mid, start, end)
+}
+
+// GetSpectrogram returns the spectrogram for the audio of a MID within a time range.
+func GetSpectrogram(ctx context.Context, store datastore.Store, mid, start, end int64) (*Spectrogram, error) {
+	s := new(Spectrogram)
+	err := store.Get(ctx, spectrogramKey(store, mid, start, end), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetPendingSpectrograms returns up to limit spectrograms that need
+// generating, oldest first, which are those that are pending or whose
+// processing has not been updated since before stale, i.e., whose
+// worker presumably died.
+func GetPendingSpectrograms(ctx context.Context, store datastore.Store, stale time.Time, limit int) ([]Spectrogram, error) {
+	var all []Spectrogram
+	for _, status := range []string{SpectrogramPending, SpectrogramProcessing} {
+		q := store.NewQuery(typeSpectrogram, false)
+		_, filestore := store.(*datastore.FileStore)
+		if !filestore {
+			q.Filter("Status =", status)
+		}
+		var ss []Spectrogram
+		_, err := store.GetAll(ctx, q, &ss)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			if s.Status == status && (status == SpectrogramPending || s.Updated.Before(stale)) {
+				all = append(all, s)
+			}
+		}
+		if filestore {
+			break // All statuses are returned by the first query.
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Created.Before(all[j].Created) })
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+// ClaimSpectrogram claims a spectrogram for generation by setting its
+// status to processing, which prevents other workers from generating
+// it concurrently. ErrSpectrogramClaimed is returned if the spectrogram
+// is neither pending nor stale.
+func ClaimSpectrogram(ctx context.Context, store datastore.Store, mid, start, end int64, stale time.Time) (*Spectrogram, error) {
+	var claimErr error
+	s := new(Spectrogram)
+	err := store.Update(ctx, spectrogramKey(store, mid, start, end), func(e datastore.Entity) {
+		s2, ok := e.(*Spectrogram)
+		if !ok {
+			claimErr = datastore.ErrWrongType
+			return
+		}
+		if s2.Status != SpectrogramPending && !(s2.Status == SpectrogramProcessing && s2.Updated.Before(stale)) {
+			claimErr = ErrSpectrogramClaimed
+			return
+		}
+		if s2.Attempts >= SpectrogramAttempts {
+			// The last attempt's worker died.
+			s2.Status = SpectrogramFailed
+			s2.Error = "generation timed out"
+			claimErr = ErrSpectrogramClaimed
+			return
+		}
+		s2.Status = SpectrogramProcessing
+		s2.Attempts++
+		s2.Updated = time.Now()
+	}, s)
+	if err != nil {
+		return nil, err
+	}
+	if claimErr != nil {
+		return nil, claimErr
+	}
+	return s, nil
+}
+
+// CompleteSpectrogram stores the result of generating a spectrogram.
+// When generation failed, the spectrogram is returned to pending to
+// be retried, unless it has been attempted SpectrogramAttempts times.
+func CompleteSpectrogram(ctx context.Context, store datastore.Store, mid, start, end int64, img []byte, genErr error) error {
+	return store.Update(ctx, spectrogramKey(store, mid, start, end), func(e datastore.Entity) {
+		s, ok := e.(*Spectrogram)
+		if !ok {
+			return
+		}
+		s.Updated = time.Now()
+		switch {
+		case genErr == nil:
+			s.Status = SpectrogramDone
+			s.Image = img
+			s.Error = ""
+		case s.Attempts >= SpectrogramAttempts:
+			s.Status = SpectrogramFailed
+			s.Error = genErr.Error()
+		default:
+			s.Status = SpectrogramPending
+			s.Error = genErr.Error()
+		}
+	}, &Spectrogram{})
+}
+
+// DeleteSpectrogram deletes a spectrogram, e.g., so that a failed
+// spectrogram can be requested again.
+func DeleteSpectrogram(ctx context.Context, store datastore.Store, mid, start, end int64) error {
+	return store.Delete(ctx, spectrogramKey(store, mid, start, end))
+}
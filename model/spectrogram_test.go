@@ -0,0 +1,112 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestSpectrograms tests requesting, claiming and completing spectrograms.
+func TestSpectrograms(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const (
+		mid   = 123
+		start = 1700000000
+		end   = start + 60
+	)
+	s, err := RequestSpectrogram(ctx, store, mid, start, end, "user@ausocean.org")
+	if err != nil {
+		t.Fatalf("RequestSpectrogram returned error: %v", err)
+	}
+	if s.Status != SpectrogramPending {
+		t.Errorf("RequestSpectrogram returned status %s, expected %s", s.Status, SpectrogramPending)
+	}
+	_, err = RequestSpectrogram(ctx, store, mid, start+60, end+60, "user@ausocean.org")
+	if err != nil {
+		t.Fatalf("RequestSpectrogram returned error: %v", err)
+	}
+
+	now := time.Now()
+	pending, err := GetPendingSpectrograms(ctx, store, now.Add(-time.Minute), 1)
+	if err != nil {
+		t.Fatalf("GetPendingSpectrograms returned error: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Start != start {
+		t.Fatalf("GetPendingSpectrograms returned %v, expected oldest spectrogram only", pending)
+	}
+
+	// Failures are retried until the maximum number of attempts.
+	for i := 1; i <= SpectrogramAttempts; i++ {
+		s, err = ClaimSpectrogram(ctx, store, mid, start, end, now.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("ClaimSpectrogram %d returned error: %v", i, err)
+		}
+		_, err = ClaimSpectrogram(ctx, store, mid, start, end, now.Add(-time.Minute))
+		if !errors.Is(err, ErrSpectrogramClaimed) {
+			t.Errorf("ClaimSpectrogram of claimed spectrogram returned %v, expected %v", err, ErrSpectrogramClaimed)
+		}
+		err = CompleteSpectrogram(ctx, store, mid, start, end, nil, errors.New("no audio"))
+		if err != nil {
+			t.Fatalf("CompleteSpectrogram returned error: %v", err)
+		}
+	}
+	s, err = GetSpectrogram(ctx, store, mid, start, end)
+	if err != nil {
+		t.Fatalf("GetSpectrogram returned error: %v", err)
+	}
+	if s.Status != SpectrogramFailed || s.Error != "no audio" {
+		t.Errorf("spectrogram has status %s and error %q, expected %s and %q", s.Status, s.Error, SpectrogramFailed, "no audio")
+	}
+
+	// Requesting a generated spectrogram returns the cached image.
+	_, err = ClaimSpectrogram(ctx, store, mid, start+60, end+60, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ClaimSpectrogram returned error: %v", err)
+	}
+	err = CompleteSpectrogram(ctx, store, mid, start+60, end+60, []byte("png"), nil)
+	if err != nil {
+		t.Fatalf("CompleteSpectrogram returned error: %v", err)
+	}
+	s, err = RequestSpectrogram(ctx, store, mid, start+60, end+60, "other@ausocean.org")
+	if err != nil {
+		t.Fatalf("RequestSpectrogram returned error: %v", err)
+	}
+	if s.Status != SpectrogramDone || string(s.Image) != "png" || s.Requester != "user@ausocean.org" {
+		t.Errorf("RequestSpectrogram returned %+v, expected cached spectrogram", s)
+	}
+
+	pending, err = GetPendingSpectrograms(ctx, store, now.Add(-time.Minute), 10)
+	if err != nil {
+		t.Fatalf("GetPendingSpectrograms returned error: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("GetPendingSpectrograms returned %d spectrograms, expected none", len(pending))
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/ausocean/cloud/utils"
@@ -51,6 +52,8 @@ type Subscriber struct {
 	DemographicInfo string    // Optional demographic info about the subscriber, e.g., their postcode.
 	PaymentInfo     string    // Info required to use a payments platform. (Stripe Customer ID)
 	Created         time.Time // Time the subscriber entity was created.
+	EmailVerified   time.Time // Time the subscriber's email was verified by an emailed link, or zero.
+	Unsubscribed    time.Time // Time the subscriber unsubscribed from announcements, or zero.
 }
 
 // Copy copies a Subscriber to dst, or returns a copy of the Subscriber when dst is nil.
@@ -121,6 +124,30 @@ func GetSubscriberByEmail(ctx context.Context, store datastore.Store, email stri
 	return &subs[0], err
 }
 
+// GetSubscriberByPaymentInfo returns the subscriber with the given
+// payment info, i.e., Stripe customer ID, if it exists.
+func GetSubscriberByPaymentInfo(ctx context.Context, store datastore.Store, info string) (*Subscriber, error) {
+	if info == "" {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	q := store.NewQuery(typeSubscriber, false, "ID", "Email")
+	_, filestore := store.(*datastore.FileStore)
+	if !filestore {
+		q.Filter("PaymentInfo =", info)
+	}
+	var subs []Subscriber
+	_, err := store.GetAll(ctx, q, &subs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all subscribers: %w", err)
+	}
+	for _, s := range subs {
+		if s.PaymentInfo == info {
+			return &s, nil
+		}
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
+
 // UpdateSubscriber updates the subscriber record with the given subscriber, based on the ID
 // of the passed subscriber.
 func UpdateSubscriber(ctx context.Context, store datastore.Store, subscriber *Subscriber) error {
@@ -149,3 +176,15 @@ func GetSubscriber(ctx context.Context, store datastore.Store, id int64) (*Subsc
 
 	return &subs[0], err
 }
+
+// GetSubscribers returns all subscribers, ordered by ID.
+func GetSubscribers(ctx context.Context, store datastore.Store) ([]Subscriber, error) {
+	q := store.NewQuery(typeSubscriber, false, "ID", "Email")
+	var subs []Subscriber
+	_, err := store.GetAll(ctx, q, &subs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all subscribers: %w", err)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
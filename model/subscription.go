@@ -46,6 +46,10 @@ const (
 
 var errDuplicateSubscriptions = errors.New("multiple subscriptions exist for given SubscriberID and FeedID")
 
+// ErrPaidSubscription is returned when granting a subscription that
+// is already paid for.
+var ErrPaidSubscription = errors.New("subscription already paid for")
+
 // Subscription is an entity in the datastore that represents the relationship between a subscriber and a feed.
 type Subscription struct {
 	SubscriberID int64     // Subscriber’s ID.
@@ -55,6 +59,8 @@ type Subscription struct {
 	Start        time.Time // Start time of the subscription.
 	Finish       time.Time // Finish time of the subscription.
 	Renew        bool      // True if the subscription should auto-renew.
+	Status       string    // Payment status, e.g., "active", "past_due" or "canceled", or empty if not paid via Stripe.
+	PaymentID    string    // Stripe subscription ID, if any.
 }
 
 // Copy copies a Subscription to dst, or returns a copy of the Subscription when dst is nil.
@@ -105,20 +111,88 @@ func GetSubscription(ctx context.Context, store datastore.Store, sid, fid int64)
 func CreateSubscription(ctx context.Context, store datastore.Store, sid, fid int64, class, prefs string, renew bool) error {
 	// Calculate characteristics of the subscription.
 	start := time.Now().Truncate(time.Hour * 24).UTC() // Start the subscription at the start of the current day.
-	var end time.Time
+	end := SubscriptionEnd(start, class)
+
+	s := &Subscription{SubscriberID: sid, FeedID: fid, Class: class, Prefs: prefs, Start: start, Finish: end, Renew: renew}
+
+	key := store.NameKey(typeSubscription, fmt.Sprintf("%d.%d", sid, fid))
+	return store.Create(ctx, key, s)
+}
+
+// SubscriptionEnd returns the end of a subscription period of the
+// given class that starts at start, or start for an unknown class.
+func SubscriptionEnd(start time.Time, class string) time.Time {
 	switch class {
 	case SubscriptionDay:
-		end = start.AddDate(0, 0, 1)
+		return start.AddDate(0, 0, 1)
 	case SubscriptionMonth:
-		end = start.AddDate(0, 1, 0)
+		return start.AddDate(0, 1, 0)
 	case SubscriptionYear:
-		end = start.AddDate(1, 0, 0)
+		return start.AddDate(1, 0, 0)
+	default:
+		return start
 	}
+}
 
-	s := &Subscription{sid, fid, class, prefs, start, end, renew}
-
+// GrantSubscription grants a subscriber one subscription period of the
+// given class for a feed, e.g., when redeeming a promo code. A new
+// subscription is created if none exists, otherwise the existing
+// subscription is extended by one period from its finish time, or from
+// now if it has expired. Granted subscriptions do not renew. The
+// subscription is updated in a transaction, and ErrPaidSubscription is
+// returned if it is an active paid subscription, which would otherwise
+// be overwritten.
+func GrantSubscription(ctx context.Context, store datastore.Store, sid, fid int64, class string) (*Subscription, error) {
 	key := store.NameKey(typeSubscription, fmt.Sprintf("%d.%d", sid, fid))
-	return store.Create(ctx, key, s)
+	grant := func(s *Subscription) error {
+		if s.Paid() {
+			return ErrPaidSubscription
+		}
+		from := s.Finish
+		if now := time.Now().UTC(); from.Before(now) {
+			from = now
+			s.Start = now
+		}
+		s.Class = class
+		s.Finish = SubscriptionEnd(from, class)
+		s.Renew = false
+		return nil
+	}
+
+	for {
+		s := new(Subscription)
+		var grantErr error
+		err := store.Update(ctx, key, func(e datastore.Entity) {
+			s2, ok := e.(*Subscription)
+			if !ok {
+				grantErr = datastore.ErrWrongType
+				return
+			}
+			grantErr = grant(s2)
+		}, s)
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			s = &Subscription{SubscriberID: sid, FeedID: fid, Start: time.Now().Truncate(time.Hour * 24).UTC()}
+			s.Finish = s.Start
+			grant(s)
+			err = store.Create(ctx, key, s)
+			if errors.Is(err, datastore.ErrEntityExists) {
+				continue // Created concurrently, so update it instead.
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not update subscription: %w", err)
+		}
+		if grantErr != nil {
+			return nil, grantErr
+		}
+		return s, nil
+	}
+}
+
+// Paid returns true if the subscription is an active subscription
+// paid via Stripe.
+func (s *Subscription) Paid() bool {
+	return s.PaymentID != "" && s.Status == "active" && time.Now().Before(s.Finish)
 }
 
 // UpdateSubscription updates a subscription.
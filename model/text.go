@@ -120,7 +120,7 @@ func GetText(ctx context.Context, store datastore.Store, mid int64, ts []int64)
 		return nil, err
 	}
 	var texts []Text
-	_, err = store.GetAll(ctx, q, &texts)
+	_, err = getAll(ctx, store, q, &texts, idxText)
 	return texts, err
 }
 
@@ -131,7 +131,7 @@ func GetTextKeys(ctx context.Context, store datastore.Store, mid int64, ts []int
 	if err != nil {
 		return nil, err
 	}
-	return store.GetAll(ctx, q, nil)
+	return getAll(ctx, store, q, nil, idxText)
 }
 
 // DeleteText deletes all text for a given Media ID.
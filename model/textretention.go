@@ -0,0 +1,361 @@
+/*
+DESCRIPTION
+  Text retention datastore type and functions. Text, i.e., logs, GPS
+  and diagnostics, is retained per text type, independently of media,
+  and purged once older than its retention period.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeTextRetention = "TextRetention" // TextRetention datastore type.
+
+// AnyTextType is the text type of the default retention, which
+// applies to text types without their own retention.
+const AnyTextType = "*"
+
+// textDeleteBatch is the maximum number of texts deleted at once.
+const textDeleteBatch = 500
+
+// ErrInvalidRetention is returned for negative retention periods.
+var ErrInvalidRetention = errors.New("invalid retention period")
+
+// TextRetention is the retention period of a text type, e.g.,
+// "text/plain", or AnyTextType. Text types without a retention,
+// either their own or the default, are retained indefinitely. The key
+// is the escaped type.
+type TextRetention struct {
+	Type    string    // Text type.
+	Days    int64     // Retention period in days, or zero to retain indefinitely.
+	Updated time.Time // Date/time last updated.
+}
+
+// Copy copies a text retention to dst, or returns a copy of the text retention when dst is nil.
+func (tr *TextRetention) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var tr2 *TextRetention
+	if dst == nil {
+		tr2 = new(TextRetention)
+	} else {
+		var ok bool
+		tr2, ok = dst.(*TextRetention)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*tr2 = *tr
+	return tr2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (tr *TextRetention) GetCache() datastore.Cache {
+	return nil
+}
+
+// Cutoff returns the Unix timestamp before which text is purged at
+// time now, or zero if text is retained indefinitely.
+func (tr *TextRetention) Cutoff(now time.Time) int64 {
+	if tr.Days <= 0 {
+		return 0
+	}
+	return now.AddDate(0, 0, -int(tr.Days)).Unix()
+}
+
+// textRetentionKey returns the key of a text retention. Types are
+// escaped since they contain slashes.
+func textRetentionKey(store datastore.Store, typ string) *datastore.Key {
+	return store.NameKey(typeTextRetention, url.QueryEscape(typ))
+}
+
+// PutTextRetention creates or updates the retention of a text type.
+func PutTextRetention(ctx context.Context, store datastore.Store, tr *TextRetention) error {
+	if tr.Days < 0 {
+		return fmt.Errorf("%w: %d days", ErrInvalidRetention, tr.Days)
+	}
+	tr.Updated = time.Now()
+	_, err := store.Put(ctx, textRetentionKey(store, tr.Type), tr)
+	return err
+}
+
+// GetTextRetentions returns all text retentions.
+func GetTextRetentions(ctx context.Context, store datastore.Store) ([]TextRetention, error) {
+	var trs []TextRetention
+	_, err := store.GetAll(ctx, store.NewQuery(typeTextRetention, false), &trs)
+	return trs, err
+}
+
+// DeleteTextRetention deletes the retention of a text type, which is
+// then subject to the default retention, if any.
+func DeleteTextRetention(ctx context.Context, store datastore.Store, typ string) error {
+	return store.DeleteMulti(ctx, []*datastore.Key{textRetentionKey(store, typ)})
+}
+
+// TextRetentions maps text types to their retention.
+type TextRetentions map[string]TextRetention
+
+// NewTextRetentions returns the map of the given text retentions.
+func NewTextRetentions(trs []TextRetention) TextRetentions {
+	m := make(TextRetentions, len(trs))
+	for _, tr := range trs {
+		m[tr.Type] = tr
+	}
+	return m
+}
+
+// For returns the retention of a text type, which is its own
+// retention, otherwise the default retention, otherwise indefinite.
+func (m TextRetentions) For(typ string) TextRetention {
+	if tr, ok := m[typ]; ok {
+		return tr
+	}
+	if tr, ok := m[AnyTextType]; ok {
+		tr.Type = typ
+		return tr
+	}
+	return TextRetention{Type: typ}
+}
+
+// Cutoff returns the Unix timestamp before which text of the given
+// type is purged at time now, or zero if it is retained indefinitely.
+func (m TextRetentions) Cutoff(typ string, now time.Time) int64 {
+	tr := m.For(typ)
+	return tr.Cutoff(now)
+}
+
+// maxCutoff returns the latest cutoff of all retentions at time now,
+// or zero if none purge text.
+func (m TextRetentions) maxCutoff(now time.Time) int64 {
+	var latest int64
+	for _, tr := range m {
+		if c := tr.Cutoff(now); c > latest {
+			latest = c
+		}
+	}
+	return latest
+}
+
+// PurgeText deletes the text for a Media ID that is older than the
+// retention of its type at time now, returning the number of texts
+// deleted. Text of each type with its own retention is deleted a page
+// of keys at a time, followed by the text subject to the default
+// retention.
+func PurgeText(ctx context.Context, store datastore.Store, mid int64, m TextRetentions, now time.Time) (int, error) {
+	if _, filestore := store.(*datastore.FileStore); filestore {
+		// The file store cannot filter by type.
+		return purgeTextByEntity(ctx, store, mid, m, now, datastore.EpochStart, m.maxCutoff(now))
+	}
+
+	var n int
+	for typ, tr := range m {
+		cutoff := tr.Cutoff(now)
+		if typ == AnyTextType || cutoff == 0 {
+			continue
+		}
+		deleted, err := purgeTextByKey(ctx, store, mid, typ, cutoff)
+		n += deleted
+		if err != nil {
+			return n, err
+		}
+	}
+
+	cutoff := m.Cutoff(AnyTextType, now)
+	if cutoff == 0 {
+		return n, nil
+	}
+	// Types retained for longer than the default must be kept, so if
+	// there are any, text before the default cutoff is only deleted by
+	// key up to the earliest of their cutoffs.
+	keysBefore := cutoff
+	for typ, tr := range m {
+		if typ != AnyTextType && tr.Cutoff(now) < keysBefore {
+			keysBefore = tr.Cutoff(now)
+		}
+	}
+	if keysBefore != 0 {
+		deleted, err := purgeTextByKey(ctx, store, mid, "", keysBefore)
+		n += deleted
+		if err != nil {
+			return n, err
+		}
+	}
+	if keysBefore < cutoff {
+		deleted, err := purgeTextByEntity(ctx, store, mid, m, now, keysBefore, cutoff)
+		n += deleted
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// purgeTextByKey deletes the text for a Media ID of the given type, or
+// any type if typ is empty, from before cutoff, textDeleteBatch keys
+// at a time.
+func purgeTextByKey(ctx context.Context, store datastore.Store, mid int64, typ string, cutoff int64) (int, error) {
+	idx := idxText
+	if typ != "" {
+		idx = idxTextType
+	}
+	var n int
+	for {
+		q := store.NewQuery(typeText, true, "MID", "Timestamp")
+		q.Filter("MID =", mid)
+		if typ != "" {
+			q.Filter("Type =", typ)
+		}
+		q.Filter("Timestamp <", cutoff)
+		q.Limit(textDeleteBatch)
+		keys, err := getAll(ctx, store, q, nil, idx)
+		if err != nil {
+			return n, fmt.Errorf("could not get text keys for %d: %w", mid, err)
+		}
+		if len(keys) == 0 {
+			return n, nil
+		}
+		err = store.DeleteMulti(ctx, keys)
+		if err != nil {
+			return n, fmt.Errorf("could not delete text for %d: %w", mid, err)
+		}
+		n += len(keys)
+		if len(keys) < textDeleteBatch {
+			return n, nil
+		}
+	}
+}
+
+// purgeTextByEntity deletes the text for a Media ID from start until
+// end that is older than the retention of its type at time now, which
+// requires getting the text to learn its type. Text is paged by
+// timestamp, which is unique for a Media ID.
+func purgeTextByEntity(ctx context.Context, store datastore.Store, mid int64, m TextRetentions, now time.Time, start, end int64) (int, error) {
+	var n int
+	for start < end {
+		q, err := newTextQuery(store, mid, []int64{start, end}, false)
+		if err != nil {
+			return n, err
+		}
+		_, filestore := store.(*datastore.FileStore)
+		if !filestore {
+			q.Limit(textDeleteBatch)
+		}
+		var texts []Text
+		_, err = getAll(ctx, store, q, &texts, idxText)
+		if err != nil {
+			return n, fmt.Errorf("could not get text for %d: %w", mid, err)
+		}
+		var keys []*datastore.Key
+		for _, t := range texts {
+			if t.Timestamp < m.Cutoff(t.Type, now) {
+				keys = append(keys, t.Key)
+			}
+		}
+		for i := 0; i < len(keys); i += textDeleteBatch {
+			err = store.DeleteMulti(ctx, keys[i:min(i+textDeleteBatch, len(keys))])
+			if err != nil {
+				return n, fmt.Errorf("could not delete text for %d: %w", mid, err)
+			}
+			n += min(textDeleteBatch, len(keys)-i)
+		}
+		if filestore || len(texts) < textDeleteBatch {
+			return n, nil
+		}
+		start = texts[len(texts)-1].Timestamp + 1
+	}
+	return n, nil
+}
+
+// TextCoverage summarizes the text for a Media ID over a period,
+// accounting for retention so that purged text is distinguishable
+// from missing text.
+type TextCoverage struct {
+	MID          int64
+	Count        int   // Number of texts.
+	First        int64 // Timestamp of the first text, if any.
+	Last         int64 // Timestamp of the last text, if any.
+	PurgedBefore int64 // Timestamp before which text has been purged, or zero if none.
+}
+
+// Purged returns true if the period from start has been at least
+// partly purged.
+func (c *TextCoverage) Purged(start int64) bool {
+	return c.PurgedBefore != 0 && start < c.PurgedBefore
+}
+
+// GetTextCoverage returns the coverage of the text of a given type,
+// or any type if typ is empty, for a Media ID over the period ts, as
+// per GetText. PurgedBefore is the earliest cutoff of the applicable
+// retentions, before which text may have been purged.
+func GetTextCoverage(ctx context.Context, store datastore.Store, mid int64, typ string, ts []int64, m TextRetentions, now time.Time) (*TextCoverage, error) {
+	texts, err := GetText(ctx, store, mid, ts)
+	if err != nil {
+		return nil, fmt.Errorf("could not get text for %d: %w", mid, err)
+	}
+	c := &TextCoverage{MID: mid, PurgedBefore: m.PurgedBefore(typ, now)}
+	for _, t := range texts {
+		if typ != "" && t.Type != typ {
+			continue
+		}
+		if c.Count == 0 || t.Timestamp < c.First {
+			c.First = t.Timestamp
+		}
+		if t.Timestamp > c.Last {
+			c.Last = t.Timestamp
+		}
+		c.Count++
+	}
+	return c, nil
+}
+
+// PurgedBefore returns the timestamp before which text of the given
+// type, or any type if typ is empty, may have been purged at time
+// now, or zero if none has been.
+func (m TextRetentions) PurgedBefore(typ string, now time.Time) int64 {
+	if typ != "" {
+		return m.Cutoff(typ, now)
+	}
+	return m.minCutoff(now)
+}
+
+// minCutoff returns the earliest cutoff of all retentions at time
+// now, or zero if any text type is retained indefinitely, which
+// includes types without a retention when there is no default.
+func (m TextRetentions) minCutoff(now time.Time) int64 {
+	if _, ok := m[AnyTextType]; !ok {
+		return 0
+	}
+	var earliest int64
+	for _, tr := range m {
+		c := tr.Cutoff(now)
+		if c == 0 {
+			return 0
+		}
+		if earliest == 0 || c < earliest {
+			earliest = c
+		}
+	}
+	return earliest
+}
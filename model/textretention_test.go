@@ -0,0 +1,117 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestTextRetention tests text retention configuration, purging and coverage.
+func TestTextRetention(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	err = PutTextRetention(ctx, store, &TextRetention{Type: "text/plain", Days: -1})
+	if !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("PutTextRetention with negative days returned %v, expected %v", err, ErrInvalidRetention)
+	}
+	for _, tr := range []TextRetention{{Type: "text/plain", Days: 7}, {Type: AnyTextType, Days: 30}} {
+		err = PutTextRetention(ctx, store, &tr)
+		if err != nil {
+			t.Fatalf("PutTextRetention returned error: %v", err)
+		}
+	}
+	trs, err := GetTextRetentions(ctx, store)
+	if err != nil || len(trs) != 2 {
+		t.Fatalf("GetTextRetentions returned %v, %v", trs, err)
+	}
+	m := NewTextRetentions(trs)
+	if m.For("text/plain").Days != 7 || m.For("application/json").Days != 30 {
+		t.Errorf("got retentions %v", m)
+	}
+
+	// Plain text older than 7 days and other text older than 30 days is purged.
+	now := time.Now()
+	day := int64(24 * 60 * 60)
+	const mid = 1234
+	texts := []struct {
+		age  int64 // Age in days.
+		typ  string
+		kept bool
+	}{
+		{age: 1, typ: "text/plain", kept: true},
+		{age: 10, typ: "text/plain"},
+		{age: 10, typ: "application/json", kept: true},
+		{age: 40, typ: "application/json"},
+	}
+	var kept int
+	for i, text := range texts {
+		err = WriteText(ctx, store, &Text{MID: mid, Timestamp: now.Unix() - text.age*day + int64(i), Type: text.typ, Data: "data"})
+		if err != nil {
+			t.Fatalf("WriteText returned error: %v", err)
+		}
+		if text.kept {
+			kept++
+		}
+	}
+
+	n, err := PurgeText(ctx, store, mid, m, now)
+	if err != nil {
+		t.Fatalf("PurgeText returned error: %v", err)
+	}
+	if n != len(texts)-kept {
+		t.Errorf("PurgeText deleted %d texts, expected %d", n, len(texts)-kept)
+	}
+
+	start := now.Unix() - 60*day
+	c, err := GetTextCoverage(ctx, store, mid, "", []int64{start, datastore.EpochEnd}, m, now)
+	if err != nil {
+		t.Fatalf("GetTextCoverage returned error: %v", err)
+	}
+	if c.Count != kept || c.PurgedBefore != now.AddDate(0, 0, -30).Unix() || !c.Purged(start) {
+		t.Errorf("got coverage %+v", c)
+	}
+	c, err = GetTextCoverage(ctx, store, mid, "text/plain", []int64{start, datastore.EpochEnd}, m, now)
+	if err != nil {
+		t.Fatalf("GetTextCoverage returned error: %v", err)
+	}
+	if c.Count != 1 || c.PurgedBefore != now.AddDate(0, 0, -7).Unix() {
+		t.Errorf("got text/plain coverage %+v", c)
+	}
+
+	// Without a default, unconfigured types are retained indefinitely.
+	err = DeleteTextRetention(ctx, store, AnyTextType)
+	if err != nil {
+		t.Fatalf("DeleteTextRetention returned error: %v", err)
+	}
+	trs, _ = GetTextRetentions(ctx, store)
+	m = NewTextRetentions(trs)
+	if m.Cutoff("application/json", now) != 0 || m.minCutoff(now) != 0 {
+		t.Errorf("got retentions %v after deleting default", m)
+	}
+}
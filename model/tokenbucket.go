@@ -0,0 +1,125 @@
+/*
+DESCRIPTION
+  TokenBucket datastore type and functions, used for rate limiting.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeTokenBucket = "TokenBucket" // TokenBucket datastore type.
+
+// TokenBucket represents a token bucket for rate limiting. Tokens
+// are replenished continuously at the rate supplied to TakeToken, up
+// to the bucket's capacity. The key is the name, which is
+// conventionally the kind of thing being limited followed by its ID,
+// e.g., "device.<mac>" or "site.<skey>". See DeviceBucket and
+// SiteBucket.
+type TokenBucket struct {
+	Name      string    // Bucket name.
+	Tokens    float64   // Tokens remaining.
+	Throttled time.Time // Date/time when the bucket was first exhausted, or zero.
+	Updated   time.Time // Date/time last updated.
+}
+
+// Copy copies a token bucket to dst, or returns a copy of the token bucket when dst is nil.
+func (b *TokenBucket) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var b2 *TokenBucket
+	if dst == nil {
+		b2 = new(TokenBucket)
+	} else {
+		var ok bool
+		b2, ok = dst.(*TokenBucket)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*b2 = *b
+	return b2, nil
+}
+
+// GetCache returns nil, indicating no caching, since buckets are only
+// read within transactions.
+func (b *TokenBucket) GetCache() datastore.Cache {
+	return nil
+}
+
+// DeviceBucket returns the token bucket name for a device.
+func DeviceBucket(mac int64) string {
+	return "device." + strconv.FormatInt(mac, 10)
+}
+
+// SiteBucket returns the token bucket name for a site.
+func SiteBucket(skey int64) string {
+	return "site." + strconv.FormatInt(skey, 10)
+}
+
+// TakeToken attempts to take a token from the named bucket, which is
+// replenished at rate tokens per second up to capacity. A new bucket
+// starts full. It returns true if a token was taken, or false if the
+// bucket was empty. Started is true only for the request that first
+// exhausts the bucket, so that callers can report each episode of
+// throttling once. A zero rate disables limiting. Tokens are taken
+// transactionally, so that concurrent requests handled by different
+// instances cannot take the same token.
+func TakeToken(ctx context.Context, store datastore.Store, name string, rate, capacity float64) (ok, started bool, err error) {
+	if rate == 0 {
+		return true, false, nil
+	}
+
+	key := store.NameKey(typeTokenBucket, name)
+	take := func(e datastore.Entity) {
+		b, isBucket := e.(*TokenBucket)
+		if !isBucket {
+			return
+		}
+		// The function is rerun if the transaction is retried.
+		ok, started = false, false
+		now := time.Now()
+		b.Tokens = math.Min(capacity, b.Tokens+now.Sub(b.Updated).Seconds()*rate)
+		b.Updated = now
+		if b.Tokens >= 1 {
+			b.Tokens--
+			b.Throttled = time.Time{}
+			ok = true
+		} else if b.Throttled.IsZero() {
+			b.Throttled = now
+			started = true
+		}
+	}
+	err = store.Update(ctx, key, take, &TokenBucket{})
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return ok, started, err
+	}
+	err = store.Create(ctx, key, &TokenBucket{Name: name, Tokens: capacity, Updated: time.Now()})
+	if err != nil && !errors.Is(err, datastore.ErrEntityExists) {
+		return false, false, err
+	}
+	// Take from the new bucket, or the bucket another instance created first.
+	err = store.Update(ctx, key, take, &TokenBucket{})
+	return ok, started, err
+}
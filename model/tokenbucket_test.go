@@ -0,0 +1,67 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestTakeToken tests that a bucket is exhausted after its capacity
+// is used and that only the first failure starts throttling.
+func TestTakeToken(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const capacity = 3
+	name := DeviceBucket(1)
+	for i := 0; i < capacity; i++ {
+		ok, _, err := TakeToken(ctx, store, name, 0.001, capacity)
+		if err != nil {
+			t.Fatalf("TakeToken returned error: %v", err)
+		}
+		if !ok {
+			t.Errorf("expected token %d to be taken", i)
+		}
+	}
+
+	ok, started, err := TakeToken(ctx, store, name, 0.001, capacity)
+	if err != nil {
+		t.Fatalf("TakeToken returned error: %v", err)
+	}
+	if ok || !started {
+		t.Errorf("expected bucket to be exhausted, got ok=%v, started=%v", ok, started)
+	}
+
+	ok, started, _ = TakeToken(ctx, store, name, 0.001, capacity)
+	if ok || started {
+		t.Errorf("expected throttling to continue, got ok=%v, started=%v", ok, started)
+	}
+
+	ok, _, _ = TakeToken(ctx, store, SiteBucket(1), 0, 0)
+	if !ok {
+		t.Errorf("expected zero rate to disable limiting")
+	}
+}
@@ -0,0 +1,118 @@
+/*
+DESCRIPTION
+  TrackPoint datastore type and functions, which record GPS tracks.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeTrackPoint = "TrackPoint" // TrackPoint datastore type.
+
+// TrackPoint represents a GPS location of a site at a given time,
+// e.g., a boat conducting a survey. A sequence of track points forms
+// a track, which is used to georeference media and scalars collected
+// along the way. The key is the site key concatenated with the
+// timestamp, as per datastore.IDKey.
+type TrackPoint struct {
+	Skey      int64   // Site key.
+	Timestamp int64   // Unix time in seconds.
+	Latitude  float64 // Latitude in decimal degrees.
+	Longitude float64 // Longitude in decimal degrees.
+	Altitude  float64 // Altitude in metres, or negative for depth.
+}
+
+// Copy copies a track point to dst, or returns a copy of the track point when dst is nil.
+func (p *TrackPoint) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var p2 *TrackPoint
+	if dst == nil {
+		p2 = new(TrackPoint)
+	} else {
+		var ok bool
+		p2, ok = dst.(*TrackPoint)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*p2 = *p
+	return p2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (p *TrackPoint) GetCache() datastore.Cache {
+	return nil
+}
+
+// PutTrackPoint writes a track point.
+func PutTrackPoint(ctx context.Context, store datastore.Store, p *TrackPoint) error {
+	key := store.IDKey(typeTrackPoint, datastore.IDKey(p.Skey, p.Timestamp, 0))
+	_, err := store.Put(ctx, key, p)
+	return err
+}
+
+// GetTrack returns the track points for a site from start (inclusive)
+// to end (exclusive), in Unix seconds, ordered by time.
+func GetTrack(ctx context.Context, store datastore.Store, skey, start, end int64) ([]TrackPoint, error) {
+	q := store.NewQuery(typeTrackPoint, false, "Skey", "Timestamp")
+	q.Filter("Skey =", skey)
+	q.Filter("Timestamp >=", start)
+	q.Filter("Timestamp <", end)
+	q.Order("Timestamp")
+	var track []TrackPoint
+	_, err := getAll(ctx, store, q, &track, idxTrackPoint)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(track, func(i, j int) bool { return track[i].Timestamp < track[j].Timestamp })
+	return track, nil
+}
+
+// TrackPosition returns the track point nearest in time to ts,
+// provided it is within maxGap of ts, with ok false otherwise. The
+// track must be ordered by time, as returned by GetTrack.
+func TrackPosition(track []TrackPoint, ts int64, maxGap time.Duration) (p TrackPoint, ok bool) {
+	i := sort.Search(len(track), func(i int) bool { return track[i].Timestamp >= ts })
+	best := -1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(track) {
+			continue
+		}
+		if best == -1 || abs(track[j].Timestamp-ts) < abs(track[best].Timestamp-ts) {
+			best = j
+		}
+	}
+	if best == -1 || time.Duration(abs(track[best].Timestamp-ts))*time.Second > maxGap {
+		return TrackPoint{}, false
+	}
+	return track[best], true
+}
+
+// abs returns the absolute value of n.
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
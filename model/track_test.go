@@ -0,0 +1,71 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestTrack tests recording tracks and georeferencing times.
+func TestTrack(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "vidgrind", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const start = 1780000000
+	for i, skey := range []int64{1, 1, 1, 2} {
+		p := &TrackPoint{Skey: skey, Timestamp: start + int64(i)*10, Latitude: -34.9 - float64(i)/100, Longitude: 138.6}
+		err = PutTrackPoint(ctx, store, p)
+		if err != nil {
+			t.Fatalf("PutTrackPoint returned error: %v", err)
+		}
+	}
+
+	track, err := GetTrack(ctx, store, 1, start, start+60)
+	if err != nil {
+		t.Fatalf("GetTrack returned error: %v", err)
+	}
+	if len(track) != 3 || track[0].Timestamp != start || track[2].Timestamp != start+20 {
+		t.Fatalf("GetTrack returned %v", track)
+	}
+
+	tests := []struct {
+		ts   int64
+		want int64
+		ok   bool
+	}{
+		{ts: start - 5, want: start, ok: true},
+		{ts: start + 4, want: start, ok: true},
+		{ts: start + 6, want: start + 10, ok: true},
+		{ts: start + 50, ok: false},
+	}
+	for _, test := range tests {
+		p, ok := TrackPosition(track, test.ts, 15*time.Second)
+		if ok != test.ok || (ok && p.Timestamp != test.want) {
+			t.Errorf("TrackPosition(%d) returned %d, %t, expected %d, %t", test.ts, p.Timestamp, ok, test.want, test.ok)
+		}
+	}
+}
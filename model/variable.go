@@ -26,6 +26,7 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"strconv"
@@ -80,9 +81,20 @@ func (v *Variable) Decode(b []byte) error {
 	return nil
 }
 
-// Copy is not currently implemented.
-func (v *Variable) Copy(datastore.Entity) (datastore.Entity, error) {
-	return nil, datastore.ErrUnimplemented
+// Copy copies a variable to dst, or returns a copy of the variable when dst is nil.
+func (v *Variable) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var v2 *Variable
+	if dst == nil {
+		v2 = new(Variable)
+	} else {
+		var ok bool
+		v2, ok = dst.(*Variable)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*v2 = *v
+	return v2, nil
 }
 
 // GetCache returns nil, indicating no caching.
@@ -126,12 +138,51 @@ func PutVariable(ctx context.Context, store datastore.Store, skey int64, name, v
 	v := &Variable{Skey: skey, Name: name, Scope: scope, Value: value, Updated: time.Now()}
 	key := store.NameKey(typeVariable, strconv.FormatInt(skey, 10)+"."+name)
 	_, err := store.Put(ctx, key, v)
+	if strings.HasPrefix(name, "_varsum.") {
+		invalidate(varSumCache, key)
+	}
 	if err == nil {
 		invalidateVarSum(ctx, store, skey, name)
 	}
 	return err
 }
 
+// IncrementVariable atomically adds delta to the integer value of a
+// variable, creating the variable if it does not exist. Values that
+// are not integers are treated as zero. Colons are stripped from the
+// scope, as with PutVariable.
+func IncrementVariable(ctx context.Context, store datastore.Store, skey int64, name string, delta int64) error {
+	sep := strings.Index(name, ".")
+	scope := ""
+	if sep >= 0 {
+		scope = strings.ReplaceAll(name[:sep], ":", "")
+		name = scope + name[sep:]
+	}
+	key := store.NameKey(typeVariable, strconv.FormatInt(skey, 10)+"."+name)
+	for {
+		err := store.Update(ctx, key, func(e datastore.Entity) {
+			v, ok := e.(*Variable)
+			if !ok {
+				return
+			}
+			n, _ := strconv.ParseInt(v.Value, 10, 64)
+			v.Value = strconv.FormatInt(n+delta, 10)
+			v.Updated = time.Now()
+		}, &Variable{})
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			v := &Variable{Skey: skey, Name: name, Scope: scope, Value: strconv.FormatInt(delta, 10), Updated: time.Now()}
+			err = store.Create(ctx, key, v)
+			if errors.Is(err, datastore.ErrEntityExists) {
+				continue // Created concurrently, so update it instead.
+			}
+		}
+		if err == nil {
+			invalidateVarSum(ctx, store, skey, name)
+		}
+		return err
+	}
+}
+
 // GetVariable gets a variable.
 // Ignore colons in the scope.
 func GetVariable(ctx context.Context, store datastore.Store, skey int64, name string) (*Variable, error) {
@@ -148,18 +199,20 @@ func GetVariable(ctx context.Context, store datastore.Store, skey int64, name st
 // Ignore colons in the scope.
 func GetVariablesBySite(ctx context.Context, store datastore.Store, skey int64, scope string) ([]Variable, error) {
 	var q datastore.Query
+	idx := idxVariableSite
 	if scope != "" {
 		scope = strings.ReplaceAll(scope, ":", "")
 		q = store.NewQuery(typeVariable, false, "Skey", "Scope", "Name")
 		q.Filter("Skey =", skey)
 		q.Filter("Scope =", scope)
+		idx = idxVariableSiteScope
 	} else {
 		q = store.NewQuery(typeVariable, false, "Skey", "Name")
 		q.Filter("Skey =", skey)
 	}
 	q.Order("Name")
 	var vars []Variable
-	_, err := store.GetAll(ctx, q, &vars)
+	_, err := getAll(ctx, store, q, &vars, idx)
 	return vars, err
 }
 
@@ -209,14 +262,26 @@ func ComputeVarSum(vars []Variable) int64 {
 	return (int64(crc32.Checksum([]byte(s), crc32.MakeTable(crc32.IEEE))) ^ 0x80000000) - 0x80000000
 }
 
+// varSumCache caches varsum variables.
+var varSumCache datastore.Cache = NewTTLCache(varSumCacheTTL)
+
 // GetVarSum gets the varsum for a given scope, and saves it as the
 // system variable named "_varsum.<scope>". Note that the varsum is
-// itself stored in the datastore not in memory, since it can be
-// mutated any time by another datastore client. If the var sum is not
-// found it is recomputed and saved.
+// itself stored in the datastore, since it can be mutated any time by
+// another datastore client, and is cached in memory only briefly. If
+// the var sum is not found it is recomputed and saved.
 func GetVarSum(ctx context.Context, store datastore.Store, skey int64, scope string) (int64, error) {
 	name := "_varsum." + scope
-	v, err := GetVariable(ctx, store, skey, name)
+	key := store.NameKey(typeVariable, strconv.FormatInt(skey, 10)+"."+name)
+	cached := cacheable(store) && varSumCache != nil
+	v := new(Variable)
+	var err error
+	if !cached || varSumCache.Get(key, v) != nil {
+		v, err = GetVariable(ctx, store, skey, name)
+		if cached && err == nil && v.Value != "" {
+			varSumCache.Set(key, v)
+		}
+	}
 	if err == nil && v.Value != "" {
 		vs, err := strconv.ParseInt(v.Value, 10, 64)
 		if err != nil {
@@ -0,0 +1,59 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestIncrementVariable tests that concurrent increments are not lost.
+func TestIncrementVariable(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const name = "_000000000001.rejected"
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := IncrementVariable(ctx, store, 1, name, 2)
+			if err != nil {
+				t.Errorf("IncrementVariable returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, err := GetVariable(ctx, store, 1, name)
+	if err != nil {
+		t.Fatalf("GetVariable returned error: %v", err)
+	}
+	if v.Value != "20" {
+		t.Errorf("unexpected value: got %s, want 20", v.Value)
+	}
+}
@@ -0,0 +1,281 @@
+/*
+DESCRIPTION
+  VariableSchema datastore type and functions, which implement typed
+  variable values.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+const typeVariableSchema = "VariableSchema" // VariableSchema datastore type.
+
+// Variable types.
+const (
+	VarString   = "string"
+	VarBool     = "bool"
+	VarInt      = "int"
+	VarFloat    = "float"
+	VarDuration = "duration"
+	VarEnum     = "enum"
+)
+
+// ErrInvalidValue is returned when a variable value does not conform
+// to its schema.
+var ErrInvalidValue = errors.New("invalid variable value")
+
+// VariableSchema describes the type of a variable's value. Variable
+// values are always stored as strings, but a schema allows values to
+// be validated when written and parsed when read. A schema named
+// without a scope, e.g., "Mode", applies to variables of that name in
+// every scope, e.g., "<MAC>.Mode", unless a schema exists for the
+// scoped name. The key is the site key concatenated with the name.
+type VariableSchema struct {
+	Skey    int64     // Site key.
+	Name    string    // Variable name, with or without scope.
+	Type    string    // Variable type, e.g., bool.
+	Values  string    // Comma-separated permitted values, for enums only.
+	Updated time.Time // Date/time last updated.
+}
+
+// Copy copies a schema to dst, or returns a copy of the schema when dst is nil.
+func (s *VariableSchema) Copy(dst datastore.Entity) (datastore.Entity, error) {
+	var s2 *VariableSchema
+	if dst == nil {
+		s2 = new(VariableSchema)
+	} else {
+		var ok bool
+		s2, ok = dst.(*VariableSchema)
+		if !ok {
+			return nil, datastore.ErrWrongType
+		}
+	}
+	*s2 = *s
+	return s2, nil
+}
+
+// GetCache returns nil, indicating no caching.
+func (s *VariableSchema) GetCache() datastore.Cache {
+	return nil
+}
+
+// Validate returns an error wrapping ErrInvalidValue if value does
+// not conform to the schema. Bools are any value accepted by
+// strconv.ParseBool and durations are either Go durations, e.g.,
+// "90s", or a bare number of seconds.
+func (s *VariableSchema) Validate(value string) error {
+	var err error
+	switch s.Type {
+	case VarString, "":
+	case VarBool:
+		_, err = strconv.ParseBool(value)
+	case VarInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case VarFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case VarDuration:
+		_, err = parseDuration(value)
+	case VarEnum:
+		if !slices.Contains(s.values(), value) {
+			err = fmt.Errorf("expected one of %s", s.Values)
+		}
+	default:
+		return fmt.Errorf("invalid variable type: %s", s.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%w for %s %s: %q", ErrInvalidValue, s.Type, s.Name, value)
+	}
+	return nil
+}
+
+// values returns the permitted values of an enum.
+func (s *VariableSchema) values() []string {
+	var vals []string
+	for _, v := range strings.Split(s.Values, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+// parseDuration parses a Go duration or a bare number of seconds.
+func parseDuration(s string) (time.Duration, error) {
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// varSchemaName returns a variable name with colons stripped from its
+// scope, as per PutVariable.
+func varSchemaName(name string) string {
+	sep := strings.Index(name, ".")
+	if sep >= 0 {
+		name = strings.ReplaceAll(name[:sep], ":", "") + name[sep:]
+	}
+	return name
+}
+
+// PutVariableSchema creates or updates a variable schema.
+func PutVariableSchema(ctx context.Context, store datastore.Store, s *VariableSchema) error {
+	s.Name = varSchemaName(s.Name)
+	switch s.Type {
+	case VarString, VarBool, VarInt, VarFloat, VarDuration:
+	case VarEnum:
+		if len(s.values()) == 0 {
+			return fmt.Errorf("enum %s has no values", s.Name)
+		}
+	default:
+		return fmt.Errorf("invalid variable type: %s", s.Type)
+	}
+	s.Updated = time.Now()
+	key := store.NameKey(typeVariableSchema, strconv.FormatInt(s.Skey, 10)+"."+s.Name)
+	_, err := store.Put(ctx, key, s)
+	return err
+}
+
+// GetVariableSchema gets the schema that applies to the named
+// variable, i.e., the schema for the scoped name if any, otherwise
+// the schema for the name without the scope.
+func GetVariableSchema(ctx context.Context, store datastore.Store, skey int64, name string) (*VariableSchema, error) {
+	name = varSchemaName(name)
+	s := new(VariableSchema)
+	err := store.Get(ctx, store.NameKey(typeVariableSchema, strconv.FormatInt(skey, 10)+"."+name), s)
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		return s, err
+	}
+	sep := strings.Index(name, ".")
+	if sep < 0 {
+		return nil, err
+	}
+	err = store.Get(ctx, store.NameKey(typeVariableSchema, strconv.FormatInt(skey, 10)+"."+name[sep+1:]), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetVariableSchemas returns the variable schemas for a site, sorted by name.
+func GetVariableSchemas(ctx context.Context, store datastore.Store, skey int64) ([]VariableSchema, error) {
+	q := store.NewQuery(typeVariableSchema, false)
+	var all []VariableSchema
+	_, err := store.GetAll(ctx, q, &all)
+	if err != nil {
+		return nil, err
+	}
+	var ss []VariableSchema
+	for _, s := range all {
+		if s.Skey == skey {
+			ss = append(ss, s)
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Name < ss[j].Name })
+	return ss, nil
+}
+
+// DeleteVariableSchema deletes a variable schema.
+func DeleteVariableSchema(ctx context.Context, store datastore.Store, skey int64, name string) error {
+	key := store.NameKey(typeVariableSchema, strconv.FormatInt(skey, 10)+"."+varSchemaName(name))
+	return store.DeleteMulti(ctx, []*datastore.Key{key})
+}
+
+// PutTypedVariable is like PutVariable, but first validates the value
+// against the variable's schema, if any, returning an error wrapping
+// ErrInvalidValue if the value does not conform. Values are stored as
+// given, since devices may depend on the exact representation.
+func PutTypedVariable(ctx context.Context, store datastore.Store, skey int64, name, value string) error {
+	s, err := GetVariableSchema(ctx, store, skey, name)
+	switch {
+	case err == nil:
+		err = s.Validate(value)
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, datastore.ErrNoSuchEntity):
+		return fmt.Errorf("could not get variable schema: %w", err)
+	}
+	return PutVariable(ctx, store, skey, name, value)
+}
+
+// GetBoolVariable gets a variable and parses it as a bool.
+func GetBoolVariable(ctx context.Context, store datastore.Store, skey int64, name string) (bool, error) {
+	v, err := GetVariable(ctx, store, skey, name)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v.Value)
+	if err != nil {
+		return false, fmt.Errorf("%w for bool %s: %q", ErrInvalidValue, name, v.Value)
+	}
+	return b, nil
+}
+
+// GetIntVariable gets a variable and parses it as an int.
+func GetIntVariable(ctx context.Context, store datastore.Store, skey int64, name string) (int64, error) {
+	v, err := GetVariable(ctx, store, skey, name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w for int %s: %q", ErrInvalidValue, name, v.Value)
+	}
+	return n, nil
+}
+
+// GetFloatVariable gets a variable and parses it as a float.
+func GetFloatVariable(ctx context.Context, store datastore.Store, skey int64, name string) (float64, error) {
+	v, err := GetVariable(ctx, store, skey, name)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(v.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w for float %s: %q", ErrInvalidValue, name, v.Value)
+	}
+	return f, nil
+}
+
+// GetDurationVariable gets a variable and parses it as a duration,
+// which is either a Go duration or a bare number of seconds.
+func GetDurationVariable(ctx context.Context, store datastore.Store, skey int64, name string) (time.Duration, error) {
+	v, err := GetVariable(ctx, store, skey, name)
+	if err != nil {
+		return 0, err
+	}
+	d, err := parseDuration(v.Value)
+	if err != nil {
+		return 0, fmt.Errorf("%w for duration %s: %q", ErrInvalidValue, name, v.Value)
+	}
+	return d, nil
+}
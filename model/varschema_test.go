@@ -0,0 +1,113 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean).
+
+  This file is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  This is distributed in the hope that it will be useful, but WITHOUT
+  ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public
+  License for more details.
+
+  You should have received a copy of the GNU General Public License in
+  gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestVariableSchema tests validating and parsing typed variables.
+func TestVariableSchema(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	RegisterEntities()
+
+	const skey = 1
+	for _, s := range []VariableSchema{
+		{Skey: skey, Name: "Alarm", Type: VarBool},
+		{Skey: skey, Name: "Period", Type: VarDuration},
+		{Skey: skey, Name: "Mode", Type: VarEnum, Values: "Normal,Paused,Burst"},
+		{Skey: skey, Name: "000000000001.Mode", Type: VarEnum, Values: "Normal,Test"},
+	} {
+		err = PutVariableSchema(ctx, store, &s)
+		if err != nil {
+			t.Fatalf("PutVariableSchema returned error: %v", err)
+		}
+	}
+	err = PutVariableSchema(ctx, store, &VariableSchema{Skey: skey, Name: "Foo", Type: VarEnum})
+	if err == nil {
+		t.Errorf("PutVariableSchema for enum without values did not return error")
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{name: "00:00:00:00:00:02.Alarm", value: "true", ok: true},
+		{name: "00:00:00:00:00:02.Alarm", value: "1", ok: true},
+		{name: "00:00:00:00:00:02.Alarm", value: "yes", ok: false},
+		{name: "000000000002.Period", value: "60", ok: true},
+		{name: "000000000002.Period", value: "1m30s", ok: true},
+		{name: "000000000002.Period", value: "soon", ok: false},
+		{name: "000000000002.Mode", value: "Paused", ok: true},
+		{name: "000000000002.Mode", value: "Test", ok: false},
+		{name: "000000000001.Mode", value: "Test", ok: true},
+		{name: "000000000001.Mode", value: "Paused", ok: false},
+		{name: "000000000001.Untyped", value: "anything", ok: true},
+	}
+	for _, test := range tests {
+		err := PutTypedVariable(ctx, store, skey, test.name, test.value)
+		if test.ok && err != nil {
+			t.Errorf("PutTypedVariable(%s, %s) returned error: %v", test.name, test.value, err)
+		}
+		if !test.ok && !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("PutTypedVariable(%s, %s) returned %v, expected %v", test.name, test.value, err, ErrInvalidValue)
+		}
+	}
+
+	// Values are stored as given.
+	v, err := GetVariable(ctx, store, skey, "000000000002.Period")
+	if err != nil || v.Value != "1m30s" {
+		t.Errorf("GetVariable returned %v, %v", v, err)
+	}
+	d, err := GetDurationVariable(ctx, store, skey, "000000000002.Period")
+	if err != nil || d != 90*time.Second {
+		t.Errorf("GetDurationVariable returned %v, %v, expected 1m30s", d, err)
+	}
+	b, err := GetBoolVariable(ctx, store, skey, "000000000002.Alarm")
+	if err != nil || !b {
+		t.Errorf("GetBoolVariable returned %t, %v, expected true", b, err)
+	}
+	_, err = GetIntVariable(ctx, store, skey, "000000000001.Untyped")
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("GetIntVariable returned %v, expected %v", err, ErrInvalidValue)
+	}
+
+	ss, err := GetVariableSchemas(ctx, store, skey)
+	if err != nil || len(ss) != 4 || ss[0].Name != "000000000001.Mode" {
+		t.Errorf("GetVariableSchemas returned %v, %v", ss, err)
+	}
+	err = DeleteVariableSchema(ctx, store, skey, "000000000001.Mode")
+	if err != nil {
+		t.Fatalf("DeleteVariableSchema returned error: %v", err)
+	}
+	s, err := GetVariableSchema(ctx, store, skey, "000000000001.Mode")
+	if err != nil || s.Name != "Mode" {
+		t.Errorf("GetVariableSchema returned %v, %v, expected Mode", s, err)
+	}
+}
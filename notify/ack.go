@@ -0,0 +1,280 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Acknowledgement constants.
+const (
+	AckSecretKey = "ackSecret"    // Name of the secret for signing acknowledgement links.
+	maxAlertAge  = 24 * time.Hour // Age beyond which unacknowledged alerts are no longer escalated.
+)
+
+// errAlertChanged is returned when an alert changes while being escalated.
+var errAlertChanged = errors.New("alert changed")
+
+// TierLookup returns the recipients for an escalation tier of a site
+// and notification kind, where tier 1 is the first tier beyond the
+// usual recipients. No recipients are returned when there are no more
+// tiers.
+type TierLookup func(skey int64, kind Kind, tier int) ([]string, error)
+
+// acks holds the configuration for acknowledging alerts.
+type acks struct {
+	store   datastore.Store // Alert store.
+	secret  []byte          // Secret for signing acknowledgement links.
+	url     string          // Acknowledgement handler URL.
+	timeout time.Duration   // Time before an unacknowledged alert is escalated.
+	tiers   TierLookup      // Escalation tiers.
+}
+
+// alert creates an alert for a notification with the given severity,
+// if acknowledgements are enabled and the notification is urgent,
+// returning the body with an acknowledgement link appended.
+func (n *MailjetNotifier) alert(ctx context.Context, skey int64, kind Kind, recipients []string, subject, msg, body string, html bool) string {
+	if n.acks == nil || n.severity(ctx, kind) < SeverityWarning {
+		return body
+	}
+	a := &model.Alert{Skey: skey, Kind: string(kind), Subject: subject, Msg: msg, Recipients: strings.Join(recipients, ",")}
+	err := model.CreateAlert(ctx, n.acks.store, a)
+	if err != nil {
+		log.Printf("could not create alert: %v", err)
+		return body
+	}
+	return n.acks.appendLink(a, body, html)
+}
+
+// appendLink appends a signed acknowledgement link for an alert to a
+// notification body. The link expires once the alert is too old to be
+// escalated.
+func (ak *acks) appendLink(a *model.Alert, body string, isHTML bool) string {
+	claims := map[string]interface{}{"alert": strconv.FormatInt(a.ID, 10), "exp": a.Created.Add(maxAlertAge).Unix()}
+	tok, err := gauth.PutClaims(claims, ak.secret)
+	if err != nil {
+		log.Printf("could not sign acknowledgement link: %v", err)
+		return body
+	}
+	link := ak.url + "?tk=" + url.QueryEscape(tok)
+	if isHTML {
+		return body + fmt.Sprintf(`<p><a href="%s">Acknowledge</a></p>`, html.EscapeString(link))
+	}
+	return body + "\n\nAcknowledge: " + link
+}
+
+// EscalateAlerts escalates alerts that have not been acknowledged
+// within the timeout to the next tier of recipients. Since alerts are
+// shared by all services, it should be called periodically by just
+// one service.
+func (n *MailjetNotifier) EscalateAlerts(ctx context.Context) error {
+	if n.acks == nil || n.acks.tiers == nil {
+		return nil
+	}
+	now := time.Now()
+	as, err := model.GetDueAlerts(ctx, n.acks.store, now.Add(-maxAlertAge), now.Add(-n.acks.timeout))
+	if err != nil {
+		return fmt.Errorf("could not get due alerts: %w", err)
+	}
+	for _, a := range as {
+		if now.Sub(a.Created) > maxAlertAge {
+			continue
+		}
+		recipients, err := n.acks.tiers(a.Skey, Kind(a.Kind), int(a.Tier)+1)
+		if err != nil {
+			log.Printf("could not get tier %d recipients for alert %d: %v", a.Tier+1, a.ID, err)
+			continue
+		}
+		if len(recipients) == 0 {
+			continue // No more tiers.
+		}
+		// Escalate in a transaction, so as not to undo a concurrent acknowledgement.
+		tier := a.Tier
+		_, err = model.UpdateAlert(ctx, n.acks.store, a.ID, func(a *model.Alert) error {
+			if !a.Acked.IsZero() || a.Tier != tier {
+				return errAlertChanged
+			}
+			a.Tier++
+			a.Recipients = strings.Join(recipients, ",")
+			a.Escalated = time.Now()
+			return nil
+		})
+		if errors.Is(err, errAlertChanged) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not update alert: %w", err)
+		}
+		a.Tier++
+		a.Recipients = strings.Join(recipients, ",")
+
+		log.Printf("escalating %s alert %d to %s", a.Kind, a.ID, a.Recipients)
+		subject := "Unacknowledged: " + a.Subject
+		body := n.acks.appendLink(&a, fmt.Sprintf("This alert was sent at %s and has not been acknowledged.\n\n%s", a.Created.UTC().Format(time.RFC3339), a.Msg), false)
+		if n.publicKey != "" && n.privateKey != "" {
+			err = send(n.publicKey, n.privateKey, n.sender, recipients, subject, body, false)
+			n.record(ctx, a.Skey, Kind(a.Kind), ChannelEmail, a.Recipients, subject, a.Msg, model.NotificationSent, err)
+			if err != nil {
+				return fmt.Errorf("could not send mail: %w", err)
+			}
+		} else {
+			n.record(ctx, a.Skey, Kind(a.Kind), ChannelEmail, a.Recipients, subject, a.Msg, model.NotificationSent, nil)
+		}
+	}
+	return nil
+}
+
+// OwnerTier returns a TierLookup that escalates to the site owner,
+// as the only tier beyond the usual recipients.
+func OwnerTier(store datastore.Store) TierLookup {
+	return func(skey int64, kind Kind, tier int) ([]string, error) {
+		if tier != 1 {
+			return nil, nil
+		}
+		site, err := model.GetSite(context.Background(), store, skey)
+		if err != nil {
+			return nil, fmt.Errorf("could not get site %d: %w", skey, err)
+		}
+		if site.OwnerEmail == "" {
+			return nil, nil
+		}
+		return []string{site.OwnerEmail}, nil
+	}
+}
+
+// ackHandler handles acknowledgement links.
+type ackHandler struct {
+	store  datastore.Store
+	secret []byte
+}
+
+// NewAckHandler returns an HTTP handler for acknowledgement links,
+// which services using acknowledgements should register at the URL
+// supplied to WithAcks. The tk parameter is a JWT signed with the
+// secret, which identifies the alert.
+func NewAckHandler(store datastore.Store, secret []byte) http.Handler {
+	return &ackHandler{store: store, secret: secret}
+}
+
+// ackPage is the page asking the recipient of an acknowledgement link
+// to confirm the acknowledgement. Alerts are only acknowledged by a
+// POST request, so that links that are merely fetched, e.g., by email
+// scanners and link previews, do not acknowledge alerts.
+const ackPage = `<!DOCTYPE html>
+<html>
+<head><title>Acknowledge alert</title></head>
+<body>
+<p>%s</p>
+<form method="post">
+<input type="hidden" name="tk" value="%s">
+<button type="submit">Acknowledge</button>
+</form>
+</body>
+</html>
+`
+
+// ServeHTTP serves a page confirming the acknowledgement of the alert
+// identified by the tk parameter for GET requests, and acknowledges
+// it for POST requests.
+func (h *ackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if len(h.secret) == 0 {
+		http.Error(w, "acknowledgements disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tk := r.FormValue("tk")
+	claims, err := gauth.GetClaims(tk, h.secret)
+	if err != nil {
+		http.Error(w, "invalid or expired acknowledgement link", http.StatusUnauthorized)
+		return
+	}
+	s, _ := claims["alert"].(string)
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid alert", http.StatusBadRequest)
+		return
+	}
+
+	var a *model.Alert
+	if r.Method == http.MethodGet {
+		a, err = model.GetAlert(ctx, h.store, id)
+	} else {
+		a, err = model.UpdateAlert(ctx, h.store, id, func(a *model.Alert) error {
+			if a.Acked.IsZero() {
+				a.Acked = time.Now()
+				a.AckedBy = a.Recipients
+				log.Printf("%s alert %d acknowledged", a.Kind, a.ID)
+			}
+			return nil
+		})
+	}
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		http.Error(w, "alert not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "could not get alert", http.StatusInternalServerError)
+		return
+	}
+
+	if !a.Acked.IsZero() {
+		fmt.Fprintf(w, "Alert acknowledged at %s: %s\n", a.Acked.UTC().Format(time.RFC3339), a.Subject)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, ackPage, html.EscapeString(a.Subject), html.EscapeString(tk))
+}
+
+// Tick performs periodic notifier work, namely sending digests and
+// deferred emails that are due and escalating unacknowledged alerts.
+// It does nothing for notifiers other than MailjetNotifier. Since this
+// work is for all sites, it should be performed by a single scheduler,
+// namely Ocean Cron.
+func Tick(ctx context.Context, n Notifier) error {
+	mn, ok := n.(*MailjetNotifier)
+	if !ok {
+		return nil
+	}
+	err := mn.FlushDigests(ctx)
+	if err != nil {
+		return err
+	}
+	err = mn.FlushDeferred(ctx)
+	if err != nil {
+		return err
+	}
+	return mn.EscalateAlerts(ctx)
+}
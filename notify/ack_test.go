@@ -0,0 +1,149 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/gauth"
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestAcks tests acknowledgement links and escalation of
+// unacknowledged alerts.
+func TestAcks(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	secret := []byte("secret")
+	tiers := func(skey int64, kind Kind, tier int) ([]string, error) {
+		if tier > 1 {
+			return nil, nil
+		}
+		return []string{"owner"}, nil
+	}
+	n, err := NewMailjetNotifier(
+		WithRecipient(testRecipient),
+		WithSeverity("urgent", SeverityCritical),
+		WithAcks(store, secret, "https://example.com/ack", 0, tiers),
+	)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	// Only urgent notifications create alerts.
+	for _, kind := range []Kind{"routine", "urgent"} {
+		err = n.Send(ctx, 1, kind, message)
+		if err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+	as, err := model.GetUnackedAlerts(ctx, store)
+	if err != nil {
+		t.Fatalf("could not get alerts: %v", err)
+	}
+	if len(as) != 1 || as[0].Kind != "urgent" || as[0].Recipients != testRecipient {
+		t.Fatalf("unexpected alerts: %v", as)
+	}
+
+	// Unacknowledged alerts escalate until there are no more tiers.
+	for i := 0; i < 2; i++ {
+		err = n.EscalateAlerts(ctx)
+		if err != nil {
+			t.Fatalf("EscalateAlerts returned error: %v", err)
+		}
+	}
+	a, err := model.GetAlert(ctx, store, as[0].ID)
+	if err != nil {
+		t.Fatalf("could not get alert: %v", err)
+	}
+	if a.Tier != 1 || a.Recipients != "owner" {
+		t.Errorf("alert not escalated, got tier %d to %s", a.Tier, a.Recipients)
+	}
+
+	// Acknowledge the alert, but not with a bad token.
+	h := NewAckHandler(store, secret)
+	tok, err := gauth.PutClaims(map[string]interface{}{"alert": "1"}, []byte("wrong"))
+	if err != nil {
+		t.Fatalf("could not sign claims: %v", err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/ack?tk="+url.QueryEscape(tok), nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bad token returned status %d", w.Code)
+	}
+
+	link := n.acks.appendLink(a, "", false)
+	u, err := url.Parse(link[len("\n\nAcknowledge: "):])
+	if err != nil {
+		t.Fatalf("could not parse link %q: %v", link, err)
+	}
+
+	// Expired links are rejected.
+	tok, err = gauth.PutClaims(map[string]interface{}{"alert": strconv.FormatInt(a.ID, 10), "exp": time.Now().Add(-time.Minute).Unix()}, secret)
+	if err != nil {
+		t.Fatalf("could not sign claims: %v", err)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/ack?tk="+url.QueryEscape(tok), nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token returned status %d", w.Code)
+	}
+
+	// Following the link only asks for confirmation.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/ack?"+u.RawQuery, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `method="post"`) {
+		t.Fatalf("ack confirmation returned status %d: %s", w.Code, w.Body.String())
+	}
+	a, err = model.GetAlert(ctx, store, a.ID)
+	if err != nil {
+		t.Fatalf("could not get alert: %v", err)
+	}
+	if !a.Acked.IsZero() {
+		t.Errorf("alert acknowledged by GET request")
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/ack", strings.NewReader(u.RawQuery))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ack returned status %d: %s", w.Code, w.Body.String())
+	}
+	a, err = model.GetAlert(ctx, store, a.ID)
+	if err != nil {
+		t.Fatalf("could not get alert: %v", err)
+	}
+	if a.Acked.IsZero() || time.Since(a.Acked) > time.Minute || a.AckedBy != "owner" {
+		t.Errorf("alert not acknowledged: %v", a)
+	}
+}
@@ -0,0 +1,319 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Severity represents the severity of a notification, which
+// determines the channels it is delivered to.
+type Severity int
+
+// Severities, in increasing order.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+// String returns the name of a severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+// Channel names.
+const (
+	ChannelEmail     = "email"
+	ChannelSlack     = "slack"
+	ChannelSMS       = "sms"
+	ChannelPagerDuty = "pagerduty"
+)
+
+// Channel is a notification delivery channel, other than email.
+type Channel interface {
+	Name() string                                                                              // Returns the channel name.
+	Deliver(ctx context.Context, recipients []string, subject, msg string, sev Severity) error // Delivers a notification.
+}
+
+// severity returns the severity of a notification.
+func (n *MailjetNotifier) severity(ctx context.Context, kind Kind) Severity {
+	if d := dataFromContext(ctx); d.Severity != nil {
+		return *d.Severity
+	}
+	return n.severities[kind]
+}
+
+// route delivers a notification to the channels that the site routes
+// it to, given its severity. Routes carried by the context override
+// the site's routes for the same channel. Errors are logged, since
+// routing is in addition to email.
+func (n *MailjetNotifier) route(ctx context.Context, skey int64, kind Kind, subject, msg string) {
+	if len(n.channels) == 0 {
+		return
+	}
+	overrides := dataFromContext(ctx).Routes
+	var routes []model.NotificationRoute
+	if n.routeStore != nil {
+		var err error
+		routes, err = n.routeCache.get(ctx, n.routeStore, skey, kind)
+		if err != nil {
+			log.Printf("could not get notification routes: %v", err)
+			return
+		}
+	}
+	routes = overrideRoutes(routes, overrides, kind)
+	sev := n.severity(ctx, kind)
+	for _, r := range routes {
+		if int64(sev) < r.MinSeverity {
+			continue
+		}
+		ch, ok := n.channels[r.Channel]
+		if !ok {
+			log.Printf("no %s channel for %s message", r.Channel, kind)
+			continue
+		}
+		log.Printf("delivering %s %s message via %s", sev, kind, r.Channel)
+		err := ch.Deliver(ctx, r.RecipientList(), subject, msg, sev)
+		n.record(ctx, skey, kind, r.Channel, r.Recipients, subject, msg, model.NotificationSent, err)
+		if err != nil {
+			log.Printf("could not deliver %s message via %s: %v", kind, r.Channel, err)
+		}
+	}
+}
+
+// routeCacheTTL is the time for which a site's routes are cached, so
+// that routes are not queried for every notification. Route changes
+// therefore take up to this long to take effect.
+const routeCacheTTL = time.Minute
+
+// routeCache caches the notification routes of sites.
+type routeCache struct {
+	mu      sync.Mutex
+	entries map[int64]cachedRoutes
+}
+
+// cachedRoutes are the cached routes of a site and their expiry time.
+type cachedRoutes struct {
+	routes  []model.NotificationRoute
+	expires time.Time
+}
+
+// get returns the routes of a site that apply to kind, from the cache
+// if they have not expired, otherwise from the store.
+func (c *routeCache) get(ctx context.Context, store datastore.Store, skey int64, kind Kind) ([]model.NotificationRoute, error) {
+	c.mu.Lock()
+	cr, ok := c.entries[skey]
+	c.mu.Unlock()
+	if !ok || !time.Now().Before(cr.expires) {
+		all, err := model.GetNotificationRoutes(ctx, store, skey, "")
+		if err != nil {
+			return nil, err
+		}
+		cr = cachedRoutes{routes: all, expires: time.Now().Add(routeCacheTTL)}
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = make(map[int64]cachedRoutes)
+		}
+		c.entries[skey] = cr
+		c.mu.Unlock()
+	}
+	var routes []model.NotificationRoute
+	for _, r := range cr.routes {
+		if r.Kind == "" || r.Kind == string(kind) {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+// overrideRoutes returns routes with those for the channels of the
+// overrides that apply to kind replaced by the overrides.
+func overrideRoutes(routes, overrides []model.NotificationRoute, kind Kind) []model.NotificationRoute {
+	if len(overrides) == 0 {
+		return routes
+	}
+	overridden := map[string]bool{}
+	var rs []model.NotificationRoute
+	for _, r := range overrides {
+		if r.Kind == "" || r.Kind == string(kind) {
+			overridden[r.Channel] = true
+			rs = append(rs, r)
+		}
+	}
+	for _, r := range routes {
+		if !overridden[r.Channel] {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// SlackChannel delivers notifications to a Slack incoming webhook.
+// Since the webhook determines the Slack channel, recipients are
+// ignored.
+type SlackChannel struct {
+	URL string // Webhook URL.
+}
+
+// Name returns the channel name.
+func (c *SlackChannel) Name() string {
+	return ChannelSlack
+}
+
+// Deliver posts a notification to the webhook.
+func (c *SlackChannel) Deliver(ctx context.Context, recipients []string, subject, msg string, sev Severity) error {
+	text := fmt.Sprintf("*%s* (%s)\n%s", subject, sev, msg)
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.URL, "application/json", bytes.NewReader(body), nil)
+}
+
+// twilioURL is the Twilio API base URL.
+const twilioURL = "https://api.twilio.com/2010-04-01/Accounts/"
+
+// SMSChannel delivers notifications as SMS messages via Twilio.
+// Recipients are phone numbers in E.164 format.
+type SMSChannel struct {
+	AccountSID string // Twilio account SID.
+	AuthToken  string // Twilio auth token.
+	From       string // Sender phone number.
+	url        string // API base URL, for testing.
+}
+
+// Name returns the channel name.
+func (c *SMSChannel) Name() string {
+	return ChannelSMS
+}
+
+// Deliver sends the subject of a notification to each recipient,
+// since SMS messages are short.
+func (c *SMSChannel) Deliver(ctx context.Context, recipients []string, subject, msg string, sev Severity) error {
+	if len(recipients) == 0 {
+		return ErrNoRecipient
+	}
+	base := c.url
+	if base == "" {
+		base = twilioURL
+	}
+	for _, to := range recipients {
+		form := url.Values{"To": {to}, "From": {c.From}, "Body": {subject}}
+		err := post(ctx, base+c.AccountSID+"/Messages.json", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), func(req *http.Request) {
+			req.SetBasicAuth(c.AccountSID, c.AuthToken)
+		})
+		if err != nil {
+			return fmt.Errorf("could not send SMS to %s: %w", to, err)
+		}
+	}
+	return nil
+}
+
+// pagerDutyURL is the PagerDuty Events API v2 URL.
+const pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyChannel delivers notifications as PagerDuty events, which
+// page the on-call responder for the service with the routing key.
+type PagerDutyChannel struct {
+	RoutingKey string // Integration routing key.
+	Source     string // Event source, e.g., the service name.
+	url        string // API URL, for testing.
+}
+
+// Name returns the channel name.
+func (c *PagerDutyChannel) Name() string {
+	return ChannelPagerDuty
+}
+
+// Deliver triggers a PagerDuty event. The notification severity maps
+// to the event severity. Events with the same source and subject have
+// the same deduplication key, so that repeated notifications of the
+// same problem are grouped into a single incident.
+func (c *PagerDutyChannel) Deliver(ctx context.Context, recipients []string, subject, msg string, sev Severity) error {
+	h := fnv.New64a()
+	h.Write([]byte(c.Source + "\n" + subject))
+	event := map[string]any{
+		"routing_key":  c.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    fmt.Sprintf("%016x", h.Sum64()),
+		"payload": map[string]any{
+			"summary":        subject,
+			"source":         c.Source,
+			"severity":       sev.String(),
+			"custom_details": map[string]string{"message": msg},
+		},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	u := c.url
+	if u == "" {
+		u = pagerDutyURL
+	}
+	return post(ctx, u, "application/json", bytes.NewReader(body), nil)
+}
+
+// httpClient is the client for channel requests, which time out so
+// that an unresponsive channel does not hold up notifications.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// post posts a request, optionally modified by fn, and checks the
+// response status.
+func post(ctx context.Context, url, contentType string, body io.Reader, fn func(*http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if fn != nil {
+		fn(req)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
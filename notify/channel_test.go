@@ -0,0 +1,117 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestChannels tests routing of notifications to channels.
+func TestChannels(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	var mu sync.Mutex
+	got := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got[r.URL.Path] += string(b)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	n, err := NewMailjetNotifier(
+		WithRecipient(testRecipient),
+		WithChannel(&SlackChannel{URL: srv.URL + "/slack"}),
+		WithChannel(&SMSChannel{AccountSID: "sid", From: "+61400000000", url: srv.URL + "/sms/"}),
+		WithChannel(&PagerDutyChannel{RoutingKey: "key", url: srv.URL + "/pagerduty"}),
+		WithSeverity("broadcast", SeverityCritical),
+		WithRouteStore(store),
+	)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	for _, r := range []model.NotificationRoute{
+		{Skey: 1, Channel: ChannelSlack},
+		{Skey: 1, Kind: "broadcast", Channel: ChannelPagerDuty, MinSeverity: int64(SeverityCritical)},
+		{Skey: 1, Kind: "broadcast", Channel: ChannelSMS, Recipients: "+61411111111, +61422222222", MinSeverity: int64(SeverityWarning)},
+		{Skey: 2, Channel: ChannelSlack},
+	} {
+		err = model.PutNotificationRoute(ctx, store, &r)
+		if err != nil {
+			t.Fatalf("could not put route: %v", err)
+		}
+	}
+
+	err = n.Send(ctx, 1, "health", "health message")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if !strings.Contains(got["/slack"], "health message") || got["/pagerduty"] != "" || got["/sms/sid/Messages.json"] != "" {
+		t.Errorf("info message routed unexpectedly: %v", got)
+	}
+
+	err = n.Send(ctx, 1, "broadcast", "broadcast failed")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if !strings.Contains(got["/pagerduty"], `"severity":"critical"`) || !strings.Contains(got["/pagerduty"], `"dedup_key":`) {
+		t.Errorf("critical message not routed to PagerDuty: %v", got)
+	}
+	if sms := got["/sms/sid/Messages.json"]; strings.Count(sms, "To=") != 2 {
+		t.Errorf("critical message not routed to SMS recipients: %q", sms)
+	}
+
+	// Severity may be overridden per message.
+	delete(got, "/pagerduty")
+	info := SeverityInfo
+	err = n.Send(NewContext(ctx, &Data{Severity: &info}), 1, "broadcast", "broadcast recovered")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if got["/pagerduty"] != "" {
+		t.Errorf("info message routed to PagerDuty: %v", got)
+	}
+
+	// Routes may be overridden per message, e.g., for a broadcast.
+	delete(got, "/sms/sid/Messages.json")
+	override := []model.NotificationRoute{{Channel: ChannelSMS, Recipients: "+61433333333"}}
+	err = n.Send(NewContext(ctx, &Data{Routes: override}), 1, "broadcast", "partner broadcast failed")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if sms := got["/sms/sid/Messages.json"]; strings.Count(sms, "To=") != 1 || !strings.Contains(sms, "61433333333") {
+		t.Errorf("message not routed to overriding SMS recipient: %q", sms)
+	}
+}
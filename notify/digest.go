@@ -0,0 +1,120 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// queue queues a message for the site's digest, then sends the
+// site's digests if due.
+func (n *MailjetNotifier) queue(ctx context.Context, skey int64, kind Kind, recipients, subject, msg string) error {
+	p := &model.PendingNotification{Skey: skey, Kind: string(kind), Recipients: recipients, Subject: subject, Msg: msg}
+	err := model.CreatePendingNotification(ctx, n.digestStore, p)
+	if err != nil {
+		return err
+	}
+	return n.flush(ctx, skey)
+}
+
+// FlushDigests sends the digests that are due for all sites. Services
+// using digests should call this periodically, so that digests are
+// sent even when no further notifications are queued.
+func (n *MailjetNotifier) FlushDigests(ctx context.Context) error {
+	if n.digestStore == nil {
+		return nil
+	}
+	return n.flush(ctx, 0)
+}
+
+// flush sends the digests for a site, or all sites if skey is 0, then
+// deletes the sent notifications. A digest is sent for each site and
+// set of recipients once its oldest notification has been pending for
+// the digest period.
+func (n *MailjetNotifier) flush(ctx context.Context, skey int64) error {
+	ps, err := model.GetPendingNotifications(ctx, n.digestStore, skey)
+	if err != nil {
+		return fmt.Errorf("could not get pending notifications: %w", err)
+	}
+
+	type digestKey struct {
+		skey       int64
+		recipients string
+	}
+	digests := make(map[digestKey][]model.PendingNotification)
+	var keys []digestKey
+	for _, p := range ps {
+		if !p.NotBefore.IsZero() {
+			continue // Deferred, not a digest.
+		}
+		k := digestKey{p.Skey, p.Recipients}
+		if _, ok := digests[k]; !ok {
+			keys = append(keys, k)
+		}
+		digests[k] = append(digests[k], p)
+	}
+
+	for _, k := range keys {
+		d := digests[k]
+		if time.Since(d[0].Created) < n.digestPeriod {
+			continue // Not yet due.
+		}
+		subject, body := digest(k.skey, d)
+		to, err := n.deferQuiet(ctx, k.skey, KindDigest, strings.Split(k.recipients, ","), subject, body, body, false, SeverityInfo, time.Now())
+		if err != nil {
+			return err
+		}
+		csvTo := strings.Join(to, ",")
+		switch {
+		case len(to) == 0:
+			// Deferred for all recipients.
+		case n.publicKey != "" && n.privateKey != "":
+			log.Printf("sending digest of %d messages to %s", len(d), csvTo)
+			err = send(n.publicKey, n.privateKey, n.sender, to, subject, body, false)
+			n.record(ctx, k.skey, KindDigest, ChannelEmail, csvTo, subject, body, model.NotificationSent, err)
+			if err != nil {
+				return fmt.Errorf("could not send digest: %w", err)
+			}
+		default:
+			log.Printf("sending digest of %d messages to %s", len(d), csvTo)
+			n.record(ctx, k.skey, KindDigest, ChannelEmail, csvTo, subject, body, model.NotificationSent, nil)
+		}
+		err = model.DeletePendingNotifications(ctx, n.digestStore, d)
+		if err != nil {
+			return fmt.Errorf("could not delete pending notifications: %w", err)
+		}
+	}
+	return nil
+}
+
+// digest returns the subject and body of a digest email.
+func digest(skey int64, ps []model.PendingNotification) (subject, body string) {
+	subject = fmt.Sprintf("Notification digest for site %d (%d notifications)", skey, len(ps))
+	var sb strings.Builder
+	for _, p := range ps {
+		fmt.Fprintf(&sb, "%s %s: %s\n%s\n\n", p.Created.UTC().Format(time.RFC3339), p.Kind, p.Subject, p.Msg)
+	}
+	return subject, sb.String()
+}
@@ -0,0 +1,95 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestDedupAndDigest tests deduplication of identical messages and
+// batching of non-urgent messages into digests.
+func TestDedupAndDigest(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	n, err := NewMailjetNotifier(
+		WithRecipient(testRecipient),
+		WithStore(NewStore(store)),
+		WithDedup("health", time.Hour),
+		WithDigest(store, time.Hour),
+		WithSeverity("urgent", SeverityCritical),
+	)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	pending := func() int {
+		ps, err := model.GetPendingNotifications(ctx, store, 1)
+		if err != nil {
+			t.Fatalf("could not get pending notifications: %v", err)
+		}
+		return len(ps)
+	}
+
+	// Identical health messages are suppressed, but not others.
+	for _, msg := range []string{"could not send health notification", "could not send health notification", "health check failed"} {
+		err = n.Send(ctx, 1, "health", msg)
+		if err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+	if got := pending(); got != 2 {
+		t.Errorf("got %d pending notifications, want 2", got)
+	}
+
+	// Urgent messages are not queued.
+	err = n.Send(ctx, 1, "urgent", "broadcast failed")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if got := pending(); got != 2 {
+		t.Errorf("got %d pending notifications, want 2", got)
+	}
+
+	// Digests are not sent until due.
+	err = n.FlushDigests(ctx)
+	if err != nil {
+		t.Fatalf("FlushDigests returned error: %v", err)
+	}
+	if got := pending(); got != 2 {
+		t.Errorf("got %d pending notifications before digest due, want 2", got)
+	}
+	n.digestPeriod = 0
+	err = n.FlushDigests(ctx)
+	if err != nil {
+		t.Fatalf("FlushDigests returned error: %v", err)
+	}
+	if got := pending(); got != 0 {
+		t.Errorf("got %d pending notifications after digest sent, want 0", got)
+	}
+}
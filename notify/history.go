@@ -0,0 +1,56 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// KindDigest is the kind recorded for digest emails.
+const KindDigest Kind = "digest"
+
+// record records a notification in the history store, if any, with
+// the given status, or failed if err is not nil. Errors are logged,
+// since history is secondary to delivery.
+func (n *MailjetNotifier) record(ctx context.Context, skey int64, kind Kind, channel, recipients, subject, msg, status string, err error) {
+	if n.historyStore == nil {
+		return
+	}
+	h := &model.Notification{
+		Skey:       skey,
+		Kind:       string(kind),
+		Channel:    channel,
+		Recipients: recipients,
+		Subject:    subject,
+		Msg:        msg,
+		Severity:   int64(n.severity(ctx, kind)),
+		Status:     status,
+	}
+	if err != nil {
+		h.Status = model.NotificationFailed
+		h.Error = err.Error()
+	}
+	err = model.CreateNotification(ctx, n.historyStore, h)
+	if err != nil {
+		log.Printf("could not record %s notification: %v", kind, err)
+	}
+}
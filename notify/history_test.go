@@ -0,0 +1,131 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestHistory tests recording of notifications and filtering of the
+// notification history.
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := NewMailjetNotifier(
+		WithRecipient(testRecipient),
+		WithChannel(&SlackChannel{URL: srv.URL + "/ok"}),
+		WithChannel(&PagerDutyChannel{RoutingKey: "key", url: srv.URL + "/fail"}),
+		WithSeverity("broadcast", SeverityCritical),
+		WithRouteStore(store),
+		WithDigest(store, time.Hour),
+		WithHistory(store),
+	)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	for _, r := range []model.NotificationRoute{
+		{Skey: 1, Kind: "broadcast", Channel: ChannelSlack},
+		{Skey: 1, Kind: "broadcast", Channel: ChannelPagerDuty},
+	} {
+		err = model.PutNotificationRoute(ctx, store, &r)
+		if err != nil {
+			t.Fatalf("could not put route: %v", err)
+		}
+	}
+
+	for _, kind := range []Kind{"health", "broadcast"} {
+		err = n.Send(ctx, 1, kind, string(kind)+" message")
+		if err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter model.NotificationFilter
+		want   int
+	}{
+		{filter: model.NotificationFilter{}, want: 4},
+		{filter: model.NotificationFilter{Kind: "health", Status: model.NotificationQueued}, want: 1},
+		{filter: model.NotificationFilter{Kind: "broadcast", Channel: ChannelEmail, Status: model.NotificationSent}, want: 1},
+		{filter: model.NotificationFilter{Channel: ChannelSlack, Status: model.NotificationSent}, want: 1},
+		{filter: model.NotificationFilter{Channel: ChannelPagerDuty, Status: model.NotificationFailed}, want: 1},
+		{filter: model.NotificationFilter{Since: time.Now().Add(time.Hour)}, want: 0},
+		{filter: model.NotificationFilter{Until: time.Now().Add(-time.Hour)}, want: 0},
+		{filter: model.NotificationFilter{Limit: 2}, want: 2},
+	}
+	for i, test := range tests {
+		ns, err := model.GetNotifications(ctx, store, 1, &test.filter)
+		if err != nil {
+			t.Fatalf("could not get notifications: %v", err)
+		}
+		if len(ns) != test.want {
+			t.Errorf("test %d: got %d notifications, want %d", i, len(ns), test.want)
+		}
+	}
+
+	ns, err := model.GetNotifications(ctx, store, 1, &model.NotificationFilter{Channel: ChannelPagerDuty})
+	if err != nil {
+		t.Fatalf("could not get notifications: %v", err)
+	}
+	if len(ns) != 1 || ns[0].Error == "" || ns[0].Severity != int64(SeverityCritical) {
+		t.Errorf("unexpected pagerduty notification: %+v", ns)
+	}
+
+	ns, err = model.GetNotifications(ctx, store, 2, nil)
+	if err != nil {
+		t.Fatalf("could not get notifications: %v", err)
+	}
+	if len(ns) != 0 {
+		t.Errorf("got %d notifications for site 2, want 0", len(ns))
+	}
+
+	// Notifications created before the cutoff are deleted.
+	deleted, err := model.DeleteNotifications(ctx, store, time.Now().Add(-time.Hour))
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteNotifications of old notifications returned %d, %v, want 0", deleted, err)
+	}
+	deleted, err = model.DeleteNotifications(ctx, store, time.Now().Add(time.Hour))
+	if err != nil || deleted == 0 {
+		t.Errorf("DeleteNotifications of all notifications returned %d, %v", deleted, err)
+	}
+	ns, err = model.GetNotifications(ctx, store, 1, nil)
+	if err != nil || len(ns) != 0 {
+		t.Errorf("got %d notifications after deletion, %v, want 0", len(ns), err)
+	}
+}
@@ -22,11 +22,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
 	mailjet "github.com/mailjet/mailjet-apiv3-go"
 )
 
@@ -39,15 +43,27 @@ type Notifier interface {
 
 // Notifier represents a notifier that uses the Mailjet API to send email.
 type MailjetNotifier struct {
-	mutex      sync.Mutex    // Lock access.
-	sender     string        // Sender email address.
-	recipients []string      // Recipient email addresses.
-	lookup     Lookup        // Recipient lookup function (optional).
-	store      TimeStore     // Notification store (optional).
-	period     time.Duration // Minimum notification period (optional)
-	filters    []string      // Message filters (optional).
-	publicKey  string        // Public key for accessing Mailjet API.
-	privateKey string        // Public key for accessing Mailjet API.
+	mutex         sync.Mutex             // Lock access.
+	sender        string                 // Sender email address.
+	recipients    []string               // Recipient email addresses.
+	lookup        Lookup                 // Recipient lookup function (optional).
+	store         TimeStore              // Notification store (optional).
+	period        time.Duration          // Minimum notification period (optional)
+	filters       []string               // Message filters (optional).
+	templates     map[Kind]Template      // Templates by kind (optional).
+	templateStore datastore.Store        // Template store (optional).
+	channels      map[string]Channel     // Additional delivery channels (optional).
+	severities    map[Kind]Severity      // Severities by kind (optional).
+	routeStore    datastore.Store        // Route store (optional).
+	routeCache    routeCache             // Cached routes by site.
+	dedup         map[Kind]time.Duration // Deduplication windows by kind (optional).
+	digestStore   datastore.Store        // Digest store (optional).
+	digestPeriod  time.Duration          // Digest period (optional).
+	acks          *acks                  // Acknowledgement configuration (optional).
+	historyStore  datastore.Store        // Notification history store (optional).
+	quietStore    datastore.Store        // Recipient preference store for quiet hours (optional).
+	publicKey     string                 // Public key for accessing Mailjet API.
+	privateKey    string                 // Public key for accessing Mailjet API.
 }
 
 // Kind represents a kind of notification.
@@ -57,10 +73,12 @@ type Kind string
 var ErrNoRecipient = errors.New("no recipient")
 
 // NewMailjetNotifier initializes a MailjetNotifier with the supplied
-// options. See WithSender, WithRecipient, WithFilter, WithStore and
-// WithSecrets for a description of the various options. Secrets are
-// required to send actual emails using the Mailjet API, but can be
-// omitted during testing.
+// options. See WithSender, WithRecipient, WithFilter, WithStore,
+// WithDedup, WithDigest, WithTemplate, WithTemplateStore,
+// WithChannel, WithSeverity, WithRouteStore, WithAcks, WithHistory,
+// WithQuietHours and WithSecrets for a description of the various options. Secrets are required to send
+// actual emails using the Mailjet API, but can be omitted during
+// testing.
 func NewMailjetNotifier(options ...Option) (*MailjetNotifier, error) {
 	n := &MailjetNotifier{}
 	n.mutex.Lock()
@@ -73,6 +91,17 @@ func NewMailjetNotifier(options ...Option) (*MailjetNotifier, error) {
 	n.store = nil
 	n.period = 0
 	n.filters = nil
+	n.templates = nil
+	n.templateStore = nil
+	n.channels = nil
+	n.severities = nil
+	n.routeStore = nil
+	n.dedup = nil
+	n.digestStore = nil
+	n.digestPeriod = 0
+	n.acks = nil
+	n.historyStore = nil
+	n.quietStore = nil
 	n.publicKey = ""
 	n.privateKey = ""
 
@@ -90,11 +119,21 @@ func NewMailjetNotifier(options ...Option) (*MailjetNotifier, error) {
 // Send sends an email message, depending on what options are present.
 // With filters, then all filters must match in order to send.
 // With persistence, then the message is sent only if it was not sent to the same recipient recently.
+// With deduplication, then the message is also sent only if an identical message was not sent recently.
+// With digests, then non-urgent messages are queued and sent in a single digest email per site.
+// With acknowledgements, then urgent messages include an acknowledgement link.
+// Recipients carried by the context, e.g., for a particular broadcast, are sent to in addition to those looked up.
+// The subject and body are rendered from the template for the kind, if any, else the message is sent as is.
+// The message is also delivered to the channels the site routes the kind to, depending on its severity.
+// With history, then each delivery is recorded for display in the notification center.
+// With quiet hours, then emails are deferred for recipients in their quiet hours unless sufficiently severe.
 func (n *MailjetNotifier) Send(ctx context.Context, skey int64, kind Kind, msg string) error {
 	recipients, period, err := n.Recipients(skey, kind)
-	if err != nil {
+	extra := dataFromContext(ctx).Recipients
+	if err != nil && !(errors.Is(err, ErrNoRecipient) && len(extra) != 0) {
 		return err
 	}
+	recipients = mergeRecipients(recipients, extra)
 	csvRecipients := strings.Join(recipients, ",")
 
 	for _, f := range n.filters {
@@ -115,37 +154,119 @@ func (n *MailjetNotifier) Send(ctx context.Context, skey int64, kind Kind, msg s
 		}
 	}
 
-	log.Printf("sending %s message to %s", kind, csvRecipients)
+	var dk string
+	if window := n.dedupWindow(kind); window > 0 && n.store != nil {
+		dk = dedupKey(kind, msg)
+		sendable, err := n.store.Sendable(ctx, skey, window, dk)
+		if err != nil {
+			log.Printf("store.IsSendable returned error: %v", err)
+		}
+		if !sendable {
+			log.Printf("suppressing duplicate %s message to %s", kind, csvRecipients)
+			return nil
+		}
+	}
 
-	if n.publicKey != "" && n.privateKey != "" {
-		err = send(n.publicKey, n.privateKey, n.sender, recipients, strings.Title(string(kind))+" notification", msg)
+	subject, body, html := n.render(ctx, skey, kind, msg)
+	n.route(ctx, skey, kind, subject, msg)
+	body = n.alert(ctx, skey, kind, recipients, subject, msg, body, html)
+
+	sev := n.severity(ctx, kind)
+	digest := n.digestStore != nil && sev < SeverityWarning
+	sendTo := recipients
+	if !digest {
+		sendTo, err = n.deferQuiet(ctx, skey, kind, recipients, subject, msg, body, html, sev, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	csvSendTo := strings.Join(sendTo, ",")
+
+	switch {
+	case digest:
+		log.Printf("queuing %s message to %s", kind, csvRecipients)
+		err = n.queue(ctx, skey, kind, csvRecipients, subject, msg)
+		if err != nil {
+			return fmt.Errorf("could not queue message: %w", err)
+		}
+		n.record(ctx, skey, kind, ChannelEmail, csvRecipients, subject, msg, model.NotificationQueued, nil)
+
+	case len(sendTo) == 0:
+		// Deferred for all recipients.
+
+	case n.publicKey != "" && n.privateKey != "":
+		log.Printf("sending %s message to %s", kind, csvSendTo)
+		err = send(n.publicKey, n.privateKey, n.sender, sendTo, subject, body, html)
+		n.record(ctx, skey, kind, ChannelEmail, csvSendTo, subject, msg, model.NotificationSent, err)
 		if err != nil {
 			return fmt.Errorf("could not send mail: %w", err)
 		}
+
+	default:
+		log.Printf("sending %s message to %s", kind, csvSendTo)
+		n.record(ctx, skey, kind, ChannelEmail, csvSendTo, subject, msg, model.NotificationSent, nil)
 	}
 
 	if n.store != nil {
-		err := n.store.Sent(ctx, skey, string(kind)+"."+csvRecipients)
-		if err != nil {
-			log.Printf("store.Sent returned error: %v", err)
+		for _, key := range []string{string(kind) + "." + csvRecipients, dk} {
+			if key == "" {
+				continue
+			}
+			err := n.store.Sent(ctx, skey, key)
+			if err != nil {
+				log.Printf("store.Sent returned error: %v", err)
+			}
 		}
 	}
 
 	return nil
 }
 
-func send(publicKey, privateKey, sender string, recipients []string, subject, msg string) error {
+// mergeRecipients returns recipients with the non-empty extra
+// recipients not already present appended.
+func mergeRecipients(recipients, extra []string) []string {
+	for _, r := range extra {
+		r = strings.TrimSpace(r)
+		if r != "" && !slices.Contains(recipients, r) {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+// dedupWindow returns the deduplication window for a kind of
+// notification, if any.
+func (n *MailjetNotifier) dedupWindow(kind Kind) time.Duration {
+	if window, ok := n.dedup[kind]; ok {
+		return window
+	}
+	return n.dedup[""]
+}
+
+// dedupKey returns the time store key for deduplicating a message,
+// which is the kind concatenated with a hash of the message.
+func dedupKey(kind Kind, msg string) string {
+	h := fnv.New64a()
+	h.Write([]byte(msg))
+	return fmt.Sprintf("%s.%016x", kind, h.Sum64())
+}
+
+func send(publicKey, privateKey, sender string, recipients []string, subject, msg string, html bool) error {
 	clt := mailjet.NewMailjetClient(publicKey, privateKey)
 	var mjRecipients mailjet.RecipientsV31
 	for _, recipient := range recipients {
 		mjRecipients = append(mjRecipients, mailjet.RecipientV31{Email: recipient})
 	}
 	info := []mailjet.InfoMessagesV31{{
-		From:     &mailjet.RecipientV31{Email: sender},
-		To:       &mjRecipients,
-		Subject:  subject,
-		TextPart: msg,
+		From:    &mailjet.RecipientV31{Email: sender},
+		To:      &mjRecipients,
+		Subject: subject,
 	}}
+	if html {
+		info[0].HTMLPart = msg
+	} else {
+		info[0].TextPart = msg
+	}
 
 	msgs := mailjet.MessagesV31{Info: info}
 	_, err := clt.SendMailV31(&msgs)
@@ -157,7 +278,7 @@ func send(publicKey, privateKey, sender string, recipients []string, subject, ms
 
 // Send sends an email message using the Mailjet API.
 func Send(publicKey, privateKey, sender string, recipients []string, subject, msg string) error {
-	return send(publicKey, privateKey, sender, recipients, subject, msg)
+	return send(publicKey, privateKey, sender, recipients, subject, msg, false)
 }
 
 // Recipients returns a list of recipients and their corresponding
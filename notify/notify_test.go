@@ -189,6 +189,12 @@ func TestRecipients(t *testing.T) {
 	if !errors.Is(err, ErrNoRecipient) {
 		t.Errorf("Recipients did not return ErrNoRecipient")
 	}
+
+	// Additional recipients, e.g., for a broadcast, are merged.
+	r = mergeRecipients([]string{testRecipient}, []string{"", testRecipient, " partner@example.com"})
+	if len(r) != 2 || r[1] != "partner@example.com" {
+		t.Errorf("mergeRecipients returned %v, expected [%s partner@example.com]", r, testRecipient)
+	}
 }
 
 // testLookup is our recipient lookup function.
@@ -21,6 +21,8 @@ package notify
 import (
 	"errors"
 	"time"
+
+	"github.com/ausocean/openfish/datastore"
 )
 
 // Option is a functional option supplied to Init.
@@ -98,9 +100,139 @@ func WithPeriod(period time.Duration) Option {
 	}
 }
 
+// WithDedup sets the deduplication window for a kind of
+// notification, or for all kinds if kind is empty. Identical
+// messages sent within the window are suppressed. Requires a
+// TimeStore.
+func WithDedup(kind Kind, window time.Duration) Option {
+	return func(n *MailjetNotifier) error {
+		if n.dedup == nil {
+			n.dedup = make(map[Kind]time.Duration)
+		}
+		n.dedup[kind] = window
+		return nil
+	}
+}
+
+// WithDigest enables digest mode, in which non-urgent notifications,
+// i.e., those with a severity below SeverityWarning, are queued in
+// the datastore and sent in a single digest email per site once the
+// oldest has been queued for the given period. Digests are sent when
+// subsequent notifications are queued, or by calling FlushDigests.
+func WithDigest(store datastore.Store, period time.Duration) Option {
+	return func(n *MailjetNotifier) error {
+		n.digestStore = store
+		n.digestPeriod = period
+		return nil
+	}
+}
+
+// WithTemplate sets the template for a kind of notification, which
+// is used unless overridden by a template in the template store.
+func WithTemplate(kind Kind, t Template) Option {
+	return func(n *MailjetNotifier) error {
+		if n.templates == nil {
+			n.templates = make(map[Kind]Template)
+		}
+		n.templates[kind] = t
+		return nil
+	}
+}
+
+// WithTemplateStore sets the datastore holding NotificationTemplate
+// entities, which take precedence over templates supplied by
+// WithTemplate. The store is also used to look up sites for template
+// data.
+func WithTemplateStore(store datastore.Store) Option {
+	return func(n *MailjetNotifier) error {
+		n.templateStore = store
+		return nil
+	}
+}
+
+// WithChannel adds a delivery channel, to which notifications are
+// routed according to the site's notification routes.
+func WithChannel(ch Channel) Option {
+	return func(n *MailjetNotifier) error {
+		if n.channels == nil {
+			n.channels = make(map[string]Channel)
+		}
+		n.channels[ch.Name()] = ch
+		return nil
+	}
+}
+
+// WithSeverity sets the severity of a kind of notification, which
+// otherwise defaults to SeverityInfo unless supplied in Data.
+func WithSeverity(kind Kind, sev Severity) Option {
+	return func(n *MailjetNotifier) error {
+		if n.severities == nil {
+			n.severities = make(map[Kind]Severity)
+		}
+		n.severities[kind] = sev
+		return nil
+	}
+}
+
+// WithRouteStore sets the datastore holding NotificationRoute
+// entities, which route notifications to channels.
+func WithRouteStore(store datastore.Store) Option {
+	return func(n *MailjetNotifier) error {
+		n.routeStore = store
+		return nil
+	}
+}
+
+// WithAcks enables acknowledgement of urgent notifications, i.e.,
+// those with a severity of at least SeverityWarning. Such
+// notifications include a link to url, signed with secret, which is
+// handled by the handler returned by NewAckHandler. Notifications that
+// are not acknowledged within timeout are escalated to the next tier
+// of recipients, as returned by tiers, by EscalateAlerts. Alerts are
+// tracked in store. Acknowledgements are disabled if secret is empty,
+// e.g., when the AckSecretKey secret is missing.
+func WithAcks(store datastore.Store, secret []byte, url string, timeout time.Duration, tiers TierLookup) Option {
+	return func(n *MailjetNotifier) error {
+		if len(secret) == 0 {
+			n.acks = nil
+			return nil
+		}
+		n.acks = &acks{store: store, secret: secret, url: url, timeout: timeout, tiers: tiers}
+		return nil
+	}
+}
+
+// WithHistory sets the datastore in which every notification is
+// recorded as a Notification entity, including its delivery channel
+// and status, for display in the notification center. Query the
+// history with model.GetNotifications.
+func WithHistory(store datastore.Store) Option {
+	return func(n *MailjetNotifier) error {
+		n.historyStore = store
+		return nil
+	}
+}
+
+// WithQuietHours sets the datastore holding RecipientPreference
+// entities, which defer emails to recipients during their quiet hours,
+// unless the notification is sufficiently severe. Deferred emails are
+// held in the same datastore until sent by FlushDeferred. Digests are
+// similarly deferred.
+func WithQuietHours(store datastore.Store) Option {
+	return func(n *MailjetNotifier) error {
+		n.quietStore = store
+		return nil
+	}
+}
+
 // WithSecrets applies the secrets necessary for sending email,
 // notably the public and private mail API keys. This is always
-// required, unless testing.
+// required, unless testing. Channels are also added for any of the
+// following optional secrets that are present:
+//
+//   - slackWebhookURL: Slack incoming webhook.
+//   - twilioAccountSID, twilioAuthToken and twilioFrom: Twilio SMS.
+//   - pagerDutyRoutingKey: PagerDuty events.
 func WithSecrets(secrets map[string]string) Option {
 	return func(n *MailjetNotifier) error {
 		var ok bool
@@ -112,6 +244,23 @@ func WithSecrets(secrets map[string]string) Option {
 		if !ok {
 			return errors.New("mailjetPrivateKey secret not found")
 		}
+
+		var chs []Channel
+		if u := secrets["slackWebhookURL"]; u != "" {
+			chs = append(chs, &SlackChannel{URL: u})
+		}
+		if sid := secrets["twilioAccountSID"]; sid != "" {
+			chs = append(chs, &SMSChannel{AccountSID: sid, AuthToken: secrets["twilioAuthToken"], From: secrets["twilioFrom"]})
+		}
+		if k := secrets["pagerDutyRoutingKey"]; k != "" {
+			chs = append(chs, &PagerDutyChannel{RoutingKey: k, Source: n.sender})
+		}
+		for _, ch := range chs {
+			err := WithChannel(ch)(n)
+			if err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 }
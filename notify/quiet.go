@@ -0,0 +1,153 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+)
+
+// quietUntil returns the end of the recipient's quiet hours if t is
+// within them and a notification of the given severity should be
+// deferred, else the zero time.
+func quietUntil(p *model.RecipientPreference, sev Severity, t time.Time) (time.Time, error) {
+	if p.QuietStart == "" || p.QuietEnd == "" {
+		return time.Time{}, nil
+	}
+	min := Severity(p.QuietMinSeverity)
+	if min == SeverityInfo {
+		min = SeverityCritical
+	}
+	if sev >= min {
+		return time.Time{}, nil
+	}
+
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", p.Timezone, err)
+	}
+	start, err := time.Parse("15:04", p.QuietStart)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid quiet start %q", p.QuietStart)
+	}
+	end, err := time.Parse("15:04", p.QuietEnd)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid quiet end %q", p.QuietEnd)
+	}
+
+	local := t.In(loc)
+	at := func(hm time.Time, days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, hm.Hour(), hm.Minute(), 0, 0, loc)
+	}
+	startToday, endToday := at(start, 0), at(end, 0)
+	switch {
+	case !startToday.Before(endToday):
+		// Quiet hours span midnight, e.g., 22:00 to 07:00.
+		if local.Before(endToday) {
+			return endToday, nil
+		}
+		if !local.Before(startToday) {
+			return at(end, 1), nil
+		}
+	case !local.Before(startToday) && local.Before(endToday):
+		return endToday, nil
+	}
+	return time.Time{}, nil
+}
+
+// deferQuiet defers the delivery of an email to the recipients in
+// their quiet hours at t, returning the remaining recipients, to whom
+// it may be sent now. Deferred emails are sent by FlushDeferred. All
+// recipients are returned if quiet hours are not enabled. Recipients
+// whose preferences cannot be obtained are not deferred.
+func (n *MailjetNotifier) deferQuiet(ctx context.Context, skey int64, kind Kind, recipients []string, subject, msg, body string, html bool, sev Severity, t time.Time) ([]string, error) {
+	if n.quietStore == nil {
+		return recipients, nil
+	}
+	prefs, err := model.GetRecipientPreferences(ctx, n.quietStore, recipients)
+	if err != nil {
+		log.Printf("could not get recipient preferences: %v", err)
+		return recipients, nil
+	}
+	var now []string
+	for _, r := range recipients {
+		p, ok := prefs[r]
+		if !ok {
+			now = append(now, r)
+			continue
+		}
+		until, err := quietUntil(p, sev, t)
+		if err != nil {
+			log.Printf("could not get quiet hours for %s: %v", r, err)
+		}
+		if until.IsZero() {
+			now = append(now, r)
+			continue
+		}
+
+		log.Printf("deferring %s message to %s until %s", kind, r, until.UTC().Format(time.RFC3339))
+		pn := &model.PendingNotification{Skey: skey, Kind: string(kind), Recipients: r, Subject: subject, Msg: body, HTML: html, NotBefore: until}
+		err = model.CreatePendingNotification(ctx, n.quietStore, pn)
+		if err != nil {
+			return nil, fmt.Errorf("could not defer message to %s: %w", r, err)
+		}
+		n.record(ctx, skey, kind, ChannelEmail, r, subject, msg, model.NotificationQueued, nil)
+	}
+	return now, nil
+}
+
+// FlushDeferred sends the deferred emails whose recipients' quiet
+// hours have ended, then deletes them. Services using quiet hours
+// should call this periodically, e.g., via Tick. A message that cannot
+// be sent is kept, and retried by the next call, without preventing
+// others from being sent.
+func (n *MailjetNotifier) FlushDeferred(ctx context.Context) error {
+	if n.quietStore == nil {
+		return nil
+	}
+	ps, err := model.GetDuePendingNotifications(ctx, n.quietStore, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not get due pending notifications: %w", err)
+	}
+	var errs []error
+	for _, p := range ps {
+		log.Printf("sending deferred %s message to %s", p.Kind, p.Recipients)
+		if n.publicKey != "" && n.privateKey != "" {
+			err = send(n.publicKey, n.privateKey, n.sender, strings.Split(p.Recipients, ","), p.Subject, p.Msg, p.HTML)
+			n.record(ctx, p.Skey, Kind(p.Kind), ChannelEmail, p.Recipients, p.Subject, p.Msg, model.NotificationSent, err)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not send deferred message %d: %w", p.ID, err))
+				continue
+			}
+		} else {
+			n.record(ctx, p.Skey, Kind(p.Kind), ChannelEmail, p.Recipients, p.Subject, p.Msg, model.NotificationSent, nil)
+		}
+		err = model.DeletePendingNotifications(ctx, n.quietStore, []model.PendingNotification{p})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not delete deferred message %d: %w", p.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
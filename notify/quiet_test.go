@@ -0,0 +1,161 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestQuietUntil tests quiet hours, including those spanning
+// midnight, in the recipient's timezone.
+func TestQuietUntil(t *testing.T) {
+	loc, err := time.LoadLocation("Australia/Adelaide")
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+	at := func(d, h, m int) time.Time { return time.Date(2026, 10, d, h, m, 0, 0, loc) }
+	overnight := &model.RecipientPreference{Timezone: "Australia/Adelaide", QuietStart: "22:00", QuietEnd: "07:00"}
+	daytime := &model.RecipientPreference{Timezone: "Australia/Adelaide", QuietStart: "12:00", QuietEnd: "13:00", QuietMinSeverity: int64(SeverityWarning)}
+
+	tests := []struct {
+		desc    string
+		pref    *model.RecipientPreference
+		sev     Severity
+		t       time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{desc: "no quiet hours", pref: &model.RecipientPreference{}, t: at(16, 3, 0)},
+		{desc: "before midnight", pref: overnight, t: at(16, 23, 0), want: at(17, 7, 0)},
+		{desc: "after midnight", pref: overnight, t: at(16, 3, 0), want: at(16, 7, 0)},
+		{desc: "outside overnight", pref: overnight, t: at(16, 7, 0)},
+		{desc: "critical", pref: overnight, sev: SeverityCritical, t: at(16, 3, 0)},
+		{desc: "warning", pref: overnight, sev: SeverityWarning, t: at(16, 3, 0), want: at(16, 7, 0)},
+		{desc: "within daytime", pref: daytime, t: at(16, 12, 30), want: at(16, 13, 0)},
+		{desc: "daytime warning", pref: daytime, sev: SeverityWarning, t: at(16, 12, 30)},
+		{desc: "outside daytime", pref: daytime, t: at(16, 23, 0)},
+		{desc: "UTC timezone", pref: &model.RecipientPreference{QuietStart: "00:00", QuietEnd: "06:00"}, t: time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC), want: time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)},
+		{desc: "invalid timezone", pref: &model.RecipientPreference{Timezone: "Mars/Olympus", QuietStart: "00:00", QuietEnd: "06:00"}, t: at(16, 3, 0), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := quietUntil(tt.pref, tt.sev, tt.t)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestQuietHours tests that emails are deferred for recipients in
+// their quiet hours and sent once they end.
+func TestQuietHours(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+
+	const quiet = "quiet@ausocean.org"
+	now := time.Now().UTC()
+	err = model.PutRecipientPreference(ctx, store, &model.RecipientPreference{
+		Email:      quiet,
+		QuietStart: now.Add(-time.Hour).Format("15:04"),
+		QuietEnd:   now.Add(time.Hour).Format("15:04"),
+	})
+	if err != nil {
+		t.Fatalf("could not put recipient preference: %v", err)
+	}
+
+	n, err := NewMailjetNotifier(
+		WithRecipients([]string{testRecipient, quiet}),
+		WithQuietHours(store),
+		WithHistory(store),
+		WithSeverity("urgent", SeverityCritical),
+	)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	deferred := func() []model.PendingNotification {
+		ps, err := model.GetPendingNotifications(ctx, store, 1)
+		if err != nil {
+			t.Fatalf("could not get pending notifications: %v", err)
+		}
+		return ps
+	}
+
+	// Non-urgent messages are deferred for the quiet recipient only.
+	err = n.Send(ctx, 1, "health", "health check failed")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	ps := deferred()
+	if len(ps) != 1 || ps[0].Recipients != quiet {
+		t.Fatalf("got deferred notifications %+v, want one for %s", ps, quiet)
+	}
+	sent, err := model.GetNotifications(ctx, store, 1, &model.NotificationFilter{Status: model.NotificationSent})
+	if err != nil {
+		t.Fatalf("could not get notifications: %v", err)
+	}
+	if len(sent) != 1 || sent[0].Recipients != testRecipient {
+		t.Errorf("got sent notifications %+v, want one for %s", sent, testRecipient)
+	}
+
+	// Urgent messages are not deferred.
+	err = n.Send(ctx, 1, "urgent", "broadcast failed")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if got := len(deferred()); got != 1 {
+		t.Errorf("got %d deferred notifications, want 1", got)
+	}
+
+	// Deferred messages are not sent until quiet hours end.
+	err = n.FlushDeferred(ctx)
+	if err != nil {
+		t.Fatalf("FlushDeferred returned error: %v", err)
+	}
+	if got := len(deferred()); got != 1 {
+		t.Errorf("got %d deferred notifications before quiet hours end, want 1", got)
+	}
+	p := ps[0]
+	p.NotBefore = now.Add(-time.Minute)
+	_, err = store.Put(ctx, store.IDKey("PendingNotification", p.ID), &p)
+	if err != nil {
+		t.Fatalf("could not update deferred notification: %v", err)
+	}
+	err = n.FlushDeferred(ctx)
+	if err != nil {
+		t.Fatalf("FlushDeferred returned error: %v", err)
+	}
+	if got := len(deferred()); got != 0 {
+		t.Errorf("got %d deferred notifications after quiet hours end, want 0", got)
+	}
+}
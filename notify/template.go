@@ -0,0 +1,170 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// Template defines the format of a kind of notification. Subject and
+// Body are Go templates, which are executed with Data. The body is
+// executed as an HTML template when HTML is true.
+type Template struct {
+	Subject string
+	Body    string
+	HTML    bool
+}
+
+// defaultTemplate is the template used when there is no template for
+// a kind of notification.
+var defaultTemplate = Template{
+	Subject: `{{title (print .Kind)}} notification`,
+	Body:    "{{.Msg}}{{if .Error}}\n\nError: {{.Error}}{{end}}",
+}
+
+// Data holds the variables available to templates. Services supply
+// data by attaching it to the context passed to Send using
+// NewContext, since notification messages alone do not carry it.
+type Data struct {
+	Kind       Kind                      // Notification kind.
+	Msg        string                    // Message passed to Send.
+	Time       time.Time                 // Time sent.
+	Site       *model.Site               // Site, if known.
+	Device     *model.Device             // Device, if any.
+	Broadcast  any                       // Broadcast, if any, e.g., an Ocean TV broadcast config.
+	Error      string                    // Error, if any.
+	Severity   *Severity                 // Severity, overriding the severity of the kind (optional).
+	Recipients []string                  // Email recipients in addition to those looked up (optional).
+	Routes     []model.NotificationRoute // Routes, overriding the site's routes for the same channel (optional).
+	Vars       map[string]string         // Additional variables.
+}
+
+// dataKey is the context key for template data.
+type dataKey struct{}
+
+// NewContext returns a copy of ctx carrying template data.
+func NewContext(ctx context.Context, d *Data) context.Context {
+	return context.WithValue(ctx, dataKey{}, d)
+}
+
+// dataFromContext returns a copy of the template data carried by
+// ctx, if any, else empty data.
+func dataFromContext(ctx context.Context) Data {
+	d, ok := ctx.Value(dataKey{}).(*Data)
+	if !ok || d == nil {
+		return Data{}
+	}
+	return *d
+}
+
+// templateFuncs are the functions available to templates.
+var templateFuncs = map[string]any{
+	"title": strings.Title,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// lookupTemplate returns the template for a site and kind of notification.
+// Templates in the template store take precedence over those supplied
+// by WithTemplate, which in turn take precedence over the default.
+func (n *MailjetNotifier) lookupTemplate(ctx context.Context, skey int64, kind Kind) Template {
+	if n.templateStore != nil {
+		t, err := model.GetNotificationTemplate(ctx, n.templateStore, skey, string(kind))
+		switch {
+		case err == nil:
+			return Template{Subject: t.Subject, Body: t.Body, HTML: t.HTML}
+		case !errors.Is(err, datastore.ErrNoSuchEntity):
+			log.Printf("could not get %s template: %v", kind, err)
+		}
+	}
+	if t, ok := n.templates[kind]; ok {
+		return t
+	}
+	return defaultTemplate
+}
+
+// render renders the subject and body of a notification, returning
+// true if the body is HTML. If the template cannot be executed, the
+// default template is used instead, so that notifications are not
+// lost due to bad templates.
+func (n *MailjetNotifier) render(ctx context.Context, skey int64, kind Kind, msg string) (subject, body string, html bool) {
+	d := dataFromContext(ctx)
+	d.Kind = kind
+	d.Msg = msg
+	d.Time = time.Now()
+	if d.Site == nil && n.templateStore != nil {
+		site, err := model.GetSite(ctx, n.templateStore, skey)
+		if err == nil {
+			d.Site = site
+		}
+	}
+
+	t := n.lookupTemplate(ctx, skey, kind)
+	subject, body, err := t.execute(&d)
+	if err != nil {
+		log.Printf("could not execute %s template: %v", kind, err)
+		subject, body, _ = defaultTemplate.execute(&d)
+		return subject, body, false
+	}
+	return subject, body, t.HTML
+}
+
+// execute executes the template with the given data.
+func (t Template) execute(d *Data) (subject, body string, err error) {
+	var buf bytes.Buffer
+	st, err := template.New("subject").Funcs(templateFuncs).Parse(t.Subject)
+	if err != nil {
+		return "", "", err
+	}
+	err = st.Execute(&buf, d)
+	if err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if t.HTML {
+		bt, err := htmltemplate.New("body").Funcs(templateFuncs).Parse(t.Body)
+		if err == nil {
+			err = bt.Execute(&buf, d)
+		}
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		bt, err := template.New("body").Funcs(templateFuncs).Parse(t.Body)
+		if err == nil {
+			err = bt.Execute(&buf, d)
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return subject, buf.String(), nil
+}
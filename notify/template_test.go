@@ -0,0 +1,91 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ausocean/cloud/model"
+	"github.com/ausocean/openfish/datastore"
+)
+
+// TestTemplates tests rendering of notifications from templates.
+func TestTemplates(t *testing.T) {
+	ctx := context.Background()
+	store, err := datastore.NewStore(ctx, "file", "netreceiver", t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+	model.RegisterEntities()
+	err = model.PutSite(ctx, store, &model.Site{Skey: 1, Name: "Rapid Bay"})
+	if err != nil {
+		t.Fatalf("could not put site: %v", err)
+	}
+
+	n, err := NewMailjetNotifier(
+		WithRecipient(testRecipient),
+		WithTemplate("device", Template{Subject: "{{.Device.Name}} is {{.Vars.state}}", Body: "{{.Msg}} at {{.Site.Name}}"}),
+		WithTemplateStore(store),
+	)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	dctx := NewContext(ctx, &Data{Device: &model.Device{Name: "Camera"}, Vars: map[string]string{"state": "down"}})
+	tests := []struct {
+		ctx         context.Context
+		skey        int64
+		kind        Kind
+		msg         string
+		wantSubject string
+		wantBody    string
+		wantHTML    bool
+	}{
+		{ctx: ctx, skey: 1, kind: "health", msg: message, wantSubject: "Health notification", wantBody: message},
+		{ctx: NewContext(ctx, &Data{Error: "timeout"}), skey: 1, kind: "health", msg: message, wantSubject: "Health notification", wantBody: message + "\n\nError: timeout"},
+		{ctx: dctx, skey: 1, kind: "device", msg: message, wantSubject: "Camera is down", wantBody: message + " at Rapid Bay"},
+		{ctx: ctx, skey: 1, kind: "device", msg: message, wantSubject: "Device notification", wantBody: message}, // Missing data.
+	}
+	for i, test := range tests {
+		subject, body, html := n.render(test.ctx, test.skey, test.kind, test.msg)
+		if subject != test.wantSubject || body != test.wantBody || html != test.wantHTML {
+			t.Errorf("test %d: got %q, %q, %v, want %q, %q, %v", i, subject, body, html, test.wantSubject, test.wantBody, test.wantHTML)
+		}
+	}
+
+	// Datastore templates override, with site templates overriding
+	// templates for all sites.
+	err = model.PutNotificationTemplate(ctx, store, &model.NotificationTemplate{Kind: "device", Subject: "Alert", Body: "<p>{{.Msg}}</p>", HTML: true})
+	if err != nil {
+		t.Fatalf("could not put template: %v", err)
+	}
+	err = model.PutNotificationTemplate(ctx, store, &model.NotificationTemplate{Skey: 2, Kind: "device", Subject: "Site 2 alert", Body: "{{.Msg}}"})
+	if err != nil {
+		t.Fatalf("could not put template: %v", err)
+	}
+	subject, body, html := n.render(dctx, 1, "device", "<down>")
+	if subject != "Alert" || body != "<p>&lt;down&gt;</p>" || !html {
+		t.Errorf("got %q, %q, %v from all sites template", subject, body, html)
+	}
+	subject, body, html = n.render(dctx, 2, "device", "<down>")
+	if subject != "Site 2 alert" || body != "<down>" || html {
+		t.Errorf("got %q, %q, %v from site template", subject, body, html)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+DESCRIPTION
+  Tide predictions from the WorldTides API.
+
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+// Package tide provides tide predictions, which are shared by the
+// services that schedule or describe events by the tide.
+package tide
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Event is a high or low tide.
+type Event struct {
+	Time   time.Time
+	High   bool
+	Height float64 // Metres relative to the provider's datum.
+}
+
+// Provider is implemented by sources of tide predictions.
+type Provider interface {
+	// Tides returns the high and low tides at a location between start and end, in order.
+	Tides(ctx context.Context, lat, lon float64, start, end time.Time) ([]Event, error)
+}
+
+// WorldTidesURL is the WorldTides API endpoint.
+const WorldTidesURL = "https://www.worldtides.info/api/v3"
+
+// requestTimeout is the timeout of requests to the WorldTides API.
+const requestTimeout = time.Minute
+
+// WorldTides is a Provider using the WorldTides API, see
+// https://www.worldtides.info/apidocs.
+type WorldTides struct {
+	Key string // API key.
+	URL string // For testing; WorldTidesURL is used if empty.
+}
+
+// Tides implements Provider.Tides.
+func (p *WorldTides) Tides(ctx context.Context, lat, lon float64, start, end time.Time) ([]Event, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	q.Set("start", strconv.FormatInt(start.Unix(), 10))
+	q.Set("length", strconv.FormatInt(int64(end.Sub(start)/time.Second), 10))
+	q.Set("key", p.Key)
+	u := p.URL
+	if u == "" {
+		u = WorldTidesURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u+"?extremes&"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	clt := &http.Client{Timeout: requestTimeout}
+	resp, err := clt.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tide request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Status   int    `json:"status"`
+		Error    string `json:"error"`
+		Extremes []struct {
+			Dt     int64   `json:"dt"`
+			Height float64 `json:"height"`
+			Type   string  `json:"type"`
+		} `json:"extremes"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode tide response: %w", err)
+	}
+	if res.Status != http.StatusOK {
+		return nil, fmt.Errorf("tide request returned status %d: %s", res.Status, res.Error)
+	}
+	var evs []Event
+	for _, e := range res.Extremes {
+		evs = append(evs, Event{Time: time.Unix(e.Dt, 0), High: e.Type == "High", Height: e.Height})
+	}
+	return evs, nil
+}
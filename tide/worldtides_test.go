@@ -0,0 +1,62 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package tide
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWorldTides(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "key" || r.URL.Query().Get("lat") != "-35.5" {
+			w.Write([]byte(`{"status":400,"error":"bad request"}`))
+			return
+		}
+		w.Write([]byte(`{"status":200,"extremes":[{"dt":1792130400,"height":1.2,"type":"High"},{"dt":1792152000,"height":-0.4,"type":"Low"}]}`))
+	}))
+	defer srv.Close()
+
+	p := &WorldTides{Key: "key", URL: srv.URL}
+	evs, err := p.Tides(context.Background(), -35.5, 138.2, time.Unix(1792120000, 0), time.Unix(1792206400, 0))
+	if err != nil {
+		t.Fatalf("could not get tides: %v", err)
+	}
+	want := []Event{
+		{Time: time.Unix(1792130400, 0), High: true, Height: 1.2},
+		{Time: time.Unix(1792152000, 0), High: false, Height: -0.4},
+	}
+	if len(evs) != len(want) {
+		t.Fatalf("got %d tides, want %d", len(evs), len(want))
+	}
+	for i := range want {
+		if !evs[i].Time.Equal(want[i].Time) || evs[i].High != want[i].High || evs[i].Height != want[i].Height {
+			t.Errorf("tide %d: got %+v, want %+v", i, evs[i], want[i])
+		}
+	}
+
+	p.Key = "wrong"
+	_, err = p.Tides(context.Background(), -35.5, 138.2, time.Unix(1792120000, 0), time.Unix(1792206400, 0))
+	if err == nil {
+		t.Errorf("expected error for bad request")
+	}
+}
@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	runtime "runtime/debug"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/exp/rand"
 )
@@ -51,6 +56,7 @@ type recoveryConfig struct {
 	handlingCriteria  func(err any) bool
 	handledConditions HandledConditions
 	httpError         int
+	dedup             *panicDeduplicator
 }
 
 type recoveryOption func(*recoveryConfig)
@@ -118,6 +124,19 @@ func WithHandlingCriteria(criteria func(err any) bool) recoveryOption {
 	}
 }
 
+// WithDeduplication allows the caller to suppress repeated notifications
+// of the same panic, as identified by PanicFingerprint, within the given
+// period. The first occurrence is notified immediately, then, if the
+// panic recurs within the period, a single notification with the count
+// of repeats is sent at the end of the period. This prevents a crash loop
+// from producing hundreds of identical notifications. By default, every
+// panic is notified.
+func WithDeduplication(period time.Duration) recoveryOption {
+	return func(c *recoveryConfig) {
+		c.dedup = &panicDeduplicator{period: period, seen: make(map[string]*panicRecord)}
+	}
+}
+
 // NewConfigurableRecoveryHandler provides a RecoveryHandler that can be configured
 // with various options. It is capable of performing multiple actions when a panic
 // occurs, such as logging, sending notifications, returning HTTP errors and calling
@@ -166,6 +185,8 @@ func WithHandlingCriteria(criteria func(err any) bool) recoveryOption {
 //	),
 //
 // )
+//
+// For Fiber apps, pass the handler to backend.FiberRecover instead.
 func NewConfigurableRecoveryHandler(opts ...recoveryOption) RecoveryHandler {
 	defaultLog := func(v ...any) {}
 	cfg := &recoveryConfig{
@@ -198,7 +219,10 @@ func NewConfigurableRecoveryHandler(opts ...recoveryOption) RecoveryHandler {
 
 		if cfg.notify != nil {
 			handled = cfg.handledConditions.HandledOnNotification
-			err := cfg.notify(panicMsg)
+			var err error
+			if cfg.dedup == nil || cfg.dedup.first(PanicFingerprint(panicErr, runtime.Stack()), panicMsg, cfg.notify, cfg.logOutput) {
+				err = cfg.notify(panicMsg)
+			}
 			if err != nil {
 				if cfg.handledConditions.HandledOnNotification {
 					handled = false
@@ -221,6 +245,81 @@ func NewConfigurableRecoveryHandler(opts ...recoveryOption) RecoveryHandler {
 	}
 }
 
+// PanicFingerprint returns a short hash identifying a panic by the type
+// of its value and the functions and source lines in its stack, which
+// are the same each time a given bug is hit, unlike the panic message,
+// which may include variable values, or the goroutine ID and arguments
+// in the stack.
+func PanicFingerprint(v any, stack []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%T\n", v)
+	for _, line := range strings.Split(string(stack), "\n") {
+		if strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if i := strings.LastIndex(line, " +0x"); i != -1 {
+			line = line[:i] // File and line, less the PC offset.
+		} else if i := strings.LastIndex(line, "("); i != -1 {
+			line = line[:i] // Function, less the arguments.
+		}
+		fmt.Fprintln(h, line)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// panicDeduplicator suppresses repeated notifications of panics with
+// the same fingerprint within a period.
+type panicDeduplicator struct {
+	mu     sync.Mutex
+	period time.Duration
+	seen   map[string]*panicRecord
+}
+
+// panicRecord records the repeats of a panic since it was notified.
+type panicRecord struct {
+	count int    // Repeats since first notified.
+	msg   string // Latest panic message.
+}
+
+// first returns true if the panic with the given fingerprint has not
+// occurred within the period and should be notified now. Otherwise, the
+// repeat is counted and a summary is sent with notify at the end of the
+// period.
+func (d *panicDeduplicator) first(fp, msg string, notify func(string) error, logOutput func(v ...any)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r, ok := d.seen[fp]
+	if !ok {
+		d.seen[fp] = &panicRecord{msg: msg}
+		time.AfterFunc(d.period, func() { d.summarize(fp, notify, logOutput) })
+		return true
+	}
+	r.count++
+	r.msg = msg
+	logOutput(fmt.Sprintf("suppressed notification of repeated panic %s", fp))
+	return false
+}
+
+// summarize notifies the number of times a panic was repeated within
+// the period, if any, then forgets it, so that its next occurrence is
+// notified immediately.
+func (d *panicDeduplicator) summarize(fp string, notify func(string) error, logOutput func(v ...any)) {
+	d.mu.Lock()
+	r := d.seen[fp]
+	delete(d.seen, fp)
+	d.mu.Unlock()
+
+	if r == nil || r.count == 0 {
+		return
+	}
+	err := notify(fmt.Sprintf("panic %s repeated %d times in the %v since first notified, latest: %s", fp, r.count, d.period, r.msg))
+	if err != nil {
+		logOutput(fmt.Sprintf("could not notify of repeated panic: %v", err))
+	}
+}
+
 // RecoverableServeMux extends the default http.ServeMux and accepts
 // a callback function to be called in case of handler panic recovery.
 type RecoverableServeMux struct {
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRecoverableServeMux(t *testing.T) {
@@ -438,3 +440,58 @@ func TestNewConfigurableRecoveryHandler(t *testing.T) {
 		})
 	}
 }
+
+// TestRecoveryDeduplication tests that a repeated panic is notified
+// once, followed by a summary of the repeats, whereas a different panic
+// is notified immediately.
+func TestRecoveryDeduplication(t *testing.T) {
+	var mu sync.Mutex
+	var notified []string
+	notify := func(msg string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		notified = append(notified, msg)
+		return nil
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(notified)
+	}
+
+	const period = 100 * time.Millisecond
+	handle := NewConfigurableRecoveryHandler(
+		WithNotification(notify),
+		WithDeduplication(period),
+		WithFmtMsg(func(v any) string { return fmt.Sprint(v) }),
+	)
+	crash := func(i int) {
+		defer func() { handle(httptest.NewRecorder(), recover()) }()
+		panic(fmt.Sprintf("index out of range [%d]", i))
+	}
+	other := func() {
+		defer func() { handle(httptest.NewRecorder(), recover()) }()
+		panic(errors.New("nil pointer"))
+	}
+
+	for i := 0; i < 5; i++ {
+		crash(i)
+	}
+	if got := count(); got != 1 {
+		t.Fatalf("got %d notifications for crash loop, want 1", got)
+	}
+	other()
+	if got := count(); got != 2 {
+		t.Fatalf("got %d notifications after different panic, want 2", got)
+	}
+
+	time.Sleep(2 * period)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notified) != 3 {
+		t.Fatalf("got notifications %q, want 3", notified)
+	}
+	if !strings.Contains(notified[2], "repeated 4 times") || !strings.Contains(notified[2], "index out of range [4]") {
+		t.Errorf("got summary %q, want count of repeats and latest panic", notified[2])
+	}
+}
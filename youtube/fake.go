@@ -0,0 +1,153 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FakeLiveService is an in-memory LiveService for testing, which
+// enforces the broadcast lifecycle.
+type FakeLiveService struct {
+	mu         sync.Mutex
+	n          int
+	broadcasts map[string]*FakeBroadcast
+	streams    map[string]StreamOptions
+	Chat       map[string][]string // Chat messages by chat ID.
+}
+
+// FakeBroadcast is a broadcast held by a FakeLiveService.
+type FakeBroadcast struct {
+	Options   BroadcastOptions
+	Status    string
+	StreamID  string
+	Thumbnail []byte
+	ChatID    string
+}
+
+// NewFakeLiveService returns a new FakeLiveService.
+func NewFakeLiveService() *FakeLiveService {
+	return &FakeLiveService{
+		broadcasts: make(map[string]*FakeBroadcast),
+		streams:    make(map[string]StreamOptions),
+		Chat:       make(map[string][]string),
+	}
+}
+
+// id returns a new unique ID with the given prefix.
+func (f *FakeLiveService) id(prefix string) string {
+	f.n++
+	return fmt.Sprintf("%s%d", prefix, f.n)
+}
+
+// CreateBroadcast implements LiveService.CreateBroadcast.
+func (f *FakeLiveService) CreateBroadcast(ctx context.Context, opts BroadcastOptions) (*Broadcast, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := &Broadcast{ID: f.id("broadcast"), ChatID: f.id("chat")}
+	f.broadcasts[b.ID] = &FakeBroadcast{Options: opts, Status: StatusCreated, ChatID: b.ChatID}
+	return b, nil
+}
+
+// CreateStream implements LiveService.CreateStream.
+func (f *FakeLiveService) CreateStream(ctx context.Context, opts StreamOptions) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.id("stream")
+	f.streams[id] = opts
+	return id, nil
+}
+
+// BindStream implements LiveService.BindStream.
+func (f *FakeLiveService) BindStream(ctx context.Context, broadcastID, streamID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.broadcasts[broadcastID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := f.streams[streamID]; !ok {
+		return ErrNotFound
+	}
+	b.StreamID = streamID
+	if b.Status == StatusCreated {
+		b.Status = StatusReady
+	}
+	return nil
+}
+
+// Transition implements LiveService.Transition.
+func (f *FakeLiveService) Transition(ctx context.Context, broadcastID, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.broadcasts[broadcastID]
+	if !ok {
+		return ErrNotFound
+	}
+	if !validTransition(b.Status, status) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, b.Status, status)
+	}
+	b.Status = status
+	return nil
+}
+
+// GetStatus implements LiveService.GetStatus.
+func (f *FakeLiveService) GetStatus(ctx context.Context, broadcastID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.broadcasts[broadcastID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return b.Status, nil
+}
+
+// SetThumbnail implements LiveService.SetThumbnail.
+func (f *FakeLiveService) SetThumbnail(ctx context.Context, broadcastID string, img io.Reader) error {
+	data, err := io.ReadAll(img)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.broadcasts[broadcastID]
+	if !ok {
+		return ErrNotFound
+	}
+	b.Thumbnail = data
+	return nil
+}
+
+// PostChatMessage implements LiveService.PostChatMessage.
+func (f *FakeLiveService) PostChatMessage(ctx context.Context, chatID, msg string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Chat[chatID] = append(f.Chat[chatID], msg)
+	return nil
+}
+
+// Broadcast returns a broadcast, or nil if there is no such broadcast.
+func (f *FakeLiveService) Broadcast(id string) *FakeBroadcast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.broadcasts[id]
+}
@@ -0,0 +1,231 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+// Package youtube provides reusable access to the YouTube Data API
+// for managing live broadcasts, independent of Ocean TV, so that
+// other tools may manage live streams. Obtain an authorized
+// *youtube.Service with broadcast.GetService.
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Broadcast lifecycle statuses. A broadcast is created, becomes ready
+// once bound to a stream, then transitions to testing (optionally),
+// live and finally complete.
+const (
+	StatusCreated  = "created"
+	StatusReady    = "ready"
+	StatusTesting  = "testing"
+	StatusLive     = "live"
+	StatusComplete = "complete"
+	StatusRevoked  = "revoked"
+)
+
+// sciTechCategory is the YouTube video category ID for "Science & Technology".
+const sciTechCategory = "28"
+
+// Errors.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidTransition = errors.New("invalid transition")
+)
+
+// BroadcastOptions are the options for creating a broadcast.
+type BroadcastOptions struct {
+	Title       string    // Broadcast title.
+	Description string    // Broadcast description (optional).
+	Privacy     string    // Privacy status, i.e., public, unlisted or private.
+	Start       time.Time // Scheduled start time.
+	End         time.Time // Scheduled end time.
+}
+
+// StreamOptions are the options for creating a stream.
+type StreamOptions struct {
+	Title      string // Stream title.
+	Resolution string // Resolution, e.g., 1080p.
+	Ingestion  string // Ingestion type, e.g., rtmp.
+	FrameRate  string // Frame rate, e.g., 30fps.
+}
+
+// Broadcast identifies a created broadcast.
+type Broadcast struct {
+	ID     string // Broadcast ID, which is also the video ID.
+	ChatID string // Live chat ID.
+}
+
+// LiveService manages the lifecycle of live broadcasts.
+type LiveService interface {
+	// CreateBroadcast creates a broadcast in the created status.
+	CreateBroadcast(ctx context.Context, opts BroadcastOptions) (*Broadcast, error)
+
+	// CreateStream creates a stream, returning its ID.
+	CreateStream(ctx context.Context, opts StreamOptions) (string, error)
+
+	// BindStream binds a stream to a broadcast, which makes it ready.
+	BindStream(ctx context.Context, broadcastID, streamID string) error
+
+	// Transition transitions a broadcast to the testing, live or complete status.
+	Transition(ctx context.Context, broadcastID, status string) error
+
+	// GetStatus returns the lifecycle status of a broadcast.
+	GetStatus(ctx context.Context, broadcastID string) (string, error)
+
+	// SetThumbnail sets the thumbnail image of a broadcast.
+	SetThumbnail(ctx context.Context, broadcastID string, img io.Reader) error
+
+	// PostChatMessage posts a message to a live chat.
+	PostChatMessage(ctx context.Context, chatID, msg string) error
+}
+
+// Live implements LiveService using the YouTube Data API.
+type Live struct {
+	svc *youtube.Service
+}
+
+// NewLiveService returns a LiveService for an authorized YouTube service.
+func NewLiveService(svc *youtube.Service) *Live {
+	return &Live{svc: svc}
+}
+
+// CreateBroadcast implements LiveService.CreateBroadcast. The video
+// category is set to "Science & Technology".
+func (l *Live) CreateBroadcast(ctx context.Context, opts BroadcastOptions) (*Broadcast, error) {
+	resp, err := l.svc.LiveBroadcasts.Insert([]string{"snippet", "status"}, &youtube.LiveBroadcast{
+		Snippet: &youtube.LiveBroadcastSnippet{
+			Title:              opts.Title,
+			Description:        opts.Description,
+			ScheduledStartTime: opts.Start.Format(time.RFC3339),
+			ScheduledEndTime:   opts.End.Format(time.RFC3339),
+		},
+		Status: &youtube.LiveBroadcastStatus{
+			PrivacyStatus:           opts.Privacy,
+			SelfDeclaredMadeForKids: false,
+			ForceSendFields:         []string{"SelfDeclaredMadeForKids"},
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("could not insert broadcast: %w", err)
+	}
+	b := &Broadcast{ID: resp.Id, ChatID: resp.Snippet.LiveChatId}
+
+	_, err = l.svc.Videos.Update([]string{"snippet"}, &youtube.Video{
+		Id: b.ID,
+		Snippet: &youtube.VideoSnippet{
+			CategoryId:  sciTechCategory,
+			Title:       opts.Title,
+			Description: opts.Description,
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return b, fmt.Errorf("could not set video category: %w", err)
+	}
+	return b, nil
+}
+
+// CreateStream implements LiveService.CreateStream.
+func (l *Live) CreateStream(ctx context.Context, opts StreamOptions) (string, error) {
+	resp, err := l.svc.LiveStreams.Insert([]string{"snippet", "cdn"}, &youtube.LiveStream{
+		Snippet: &youtube.LiveStreamSnippet{Title: opts.Title},
+		Cdn: &youtube.CdnSettings{
+			Resolution:    opts.Resolution,
+			IngestionType: opts.Ingestion,
+			FrameRate:     opts.FrameRate,
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("could not insert stream: %w", err)
+	}
+	return resp.Id, nil
+}
+
+// BindStream implements LiveService.BindStream.
+func (l *Live) BindStream(ctx context.Context, broadcastID, streamID string) error {
+	_, err := l.svc.LiveBroadcasts.Bind(broadcastID, []string{"id", "contentDetails"}).StreamId(streamID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("could not bind broadcast: %w", err)
+	}
+	return nil
+}
+
+// Transition implements LiveService.Transition.
+func (l *Live) Transition(ctx context.Context, broadcastID, status string) error {
+	_, err := l.svc.LiveBroadcasts.Transition(status, broadcastID, []string{"status"}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("could not transition to %s: %w", status, err)
+	}
+	return nil
+}
+
+// GetStatus implements LiveService.GetStatus.
+func (l *Live) GetStatus(ctx context.Context, broadcastID string) (string, error) {
+	resp, err := l.svc.LiveBroadcasts.List([]string{"status"}).Id(broadcastID).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("could not list broadcasts: %w", err)
+	}
+	if len(resp.Items) == 0 {
+		return "", ErrNotFound
+	}
+	return resp.Items[0].Status.LifeCycleStatus, nil
+}
+
+// SetThumbnail implements LiveService.SetThumbnail.
+func (l *Live) SetThumbnail(ctx context.Context, broadcastID string, img io.Reader) error {
+	_, err := l.svc.Thumbnails.Set(broadcastID).Media(img).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("could not set thumbnail: %w", err)
+	}
+	return nil
+}
+
+// PostChatMessage implements LiveService.PostChatMessage.
+func (l *Live) PostChatMessage(ctx context.Context, chatID, msg string) error {
+	_, err := l.svc.LiveChatMessages.Insert([]string{"snippet"}, &youtube.LiveChatMessage{
+		Snippet: &youtube.LiveChatMessageSnippet{
+			LiveChatId:         chatID,
+			Type:               "textMessageEvent",
+			TextMessageDetails: &youtube.LiveChatTextMessageDetails{MessageText: msg},
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("could not post live chat message: %w", err)
+	}
+	return nil
+}
+
+// validTransition returns true if a broadcast may transition from one
+// status to another.
+func validTransition(from, to string) bool {
+	switch to {
+	case StatusTesting:
+		return from == StatusReady
+	case StatusLive:
+		return from == StatusReady || from == StatusTesting
+	case StatusComplete:
+		return from == StatusLive
+	default:
+		return false
+	}
+}
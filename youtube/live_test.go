@@ -0,0 +1,92 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package youtube
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Check that the implementations satisfy the interface.
+var (
+	_ LiveService = (*Live)(nil)
+	_ LiveService = (*FakeLiveService)(nil)
+)
+
+// TestLifecycle tests the broadcast lifecycle using the fake service.
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	var svc LiveService = NewFakeLiveService()
+
+	b, err := svc.CreateBroadcast(ctx, BroadcastOptions{Title: "Rapid Bay", Privacy: "public", Start: time.Now(), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("could not create broadcast: %v", err)
+	}
+
+	// Broadcasts cannot go live until bound to a stream.
+	err = svc.Transition(ctx, b.ID, StatusLive)
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("got error %v for unbound broadcast, want %v", err, ErrInvalidTransition)
+	}
+
+	sID, err := svc.CreateStream(ctx, StreamOptions{Title: "Rapid Bay", Resolution: "1080p", Ingestion: "rtmp", FrameRate: "30fps"})
+	if err != nil {
+		t.Fatalf("could not create stream: %v", err)
+	}
+	err = svc.BindStream(ctx, b.ID, sID)
+	if err != nil {
+		t.Fatalf("could not bind stream: %v", err)
+	}
+
+	for _, status := range []string{StatusTesting, StatusLive, StatusComplete} {
+		err = svc.Transition(ctx, b.ID, status)
+		if err != nil {
+			t.Fatalf("could not transition to %s: %v", status, err)
+		}
+		got, err := svc.GetStatus(ctx, b.ID)
+		if err != nil {
+			t.Fatalf("could not get status: %v", err)
+		}
+		if got != status {
+			t.Errorf("got status %s, want %s", got, status)
+		}
+	}
+
+	err = svc.Transition(ctx, b.ID, StatusLive)
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("got error %v for complete broadcast, want %v", err, ErrInvalidTransition)
+	}
+
+	err = svc.SetThumbnail(ctx, b.ID, strings.NewReader("jpeg"))
+	if err != nil {
+		t.Errorf("could not set thumbnail: %v", err)
+	}
+	err = svc.PostChatMessage(ctx, b.ChatID, "hello")
+	if err != nil {
+		t.Errorf("could not post chat message: %v", err)
+	}
+
+	_, err = svc.GetStatus(ctx, "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v for missing broadcast, want %v", err, ErrNotFound)
+	}
+}
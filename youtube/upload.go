@@ -0,0 +1,111 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Upload defaults.
+const (
+	DefaultChunkSize     = 8 << 20         // 8MB, which is rounded up to a multiple of 256KB.
+	DefaultRetryDeadline = 2 * time.Minute // Per-chunk retry deadline.
+)
+
+// VideoOptions are the options for uploading a video.
+type VideoOptions struct {
+	Title       string   // Video title.
+	Description string   // Video description (optional).
+	Tags        []string // Video tags (optional).
+	Category    string   // Category ID, defaulting to "Science & Technology".
+	Privacy     string   // Privacy status, i.e., public, unlisted or private.
+}
+
+// UploadOptions are options for controlling an upload.
+type UploadOptions struct {
+	// ChunkSize is the size of each chunk. Media smaller than the
+	// chunk size is uploaded in a single request. Zero means
+	// DefaultChunkSize.
+	ChunkSize int
+
+	// RetryDeadline is the time for which a failed chunk is retried,
+	// with exponential backoff, before the upload fails. Zero means
+	// DefaultRetryDeadline.
+	RetryDeadline time.Duration
+
+	// Size is the total size of the media in bytes, if known, which is
+	// passed to Progress.
+	Size int64
+
+	// Progress, if not nil, is called after each chunk is uploaded
+	// with the number of bytes sent so far and the total size, which is
+	// zero if unknown.
+	Progress func(sent, total int64)
+}
+
+// Upload uploads a video using a chunked resumable upload, returning
+// the video ID. Each chunk is retried until the retry deadline, so
+// that a large video, e.g., a multi-GB timelapse, survives transient
+// network and server errors without restarting from the beginning.
+// Cancelling the context aborts the upload.
+func Upload(ctx context.Context, svc *youtube.Service, r io.Reader, v VideoOptions, opts UploadOptions) (string, error) {
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.RetryDeadline == 0 {
+		opts.RetryDeadline = DefaultRetryDeadline
+	}
+	if v.Category == "" {
+		v.Category = sciTechCategory
+	}
+
+	call := svc.Videos.Insert([]string{"snippet", "status"}, &youtube.Video{
+		Snippet: &youtube.VideoSnippet{
+			Title:       v.Title,
+			Description: v.Description,
+			Tags:        v.Tags,
+			CategoryId:  v.Category,
+		},
+		Status: &youtube.VideoStatus{
+			PrivacyStatus:           v.Privacy,
+			SelfDeclaredMadeForKids: false,
+			ForceSendFields:         []string{"SelfDeclaredMadeForKids"},
+		},
+	}).Media(r, googleapi.ChunkSize(opts.ChunkSize), googleapi.ChunkRetryDeadline(opts.RetryDeadline)).Context(ctx)
+	if opts.Progress != nil {
+		call = call.ProgressUpdater(func(current, total int64) {
+			if total == 0 {
+				total = opts.Size
+			}
+			opts.Progress(current, total)
+		})
+	}
+
+	resp, err := call.Do()
+	if err != nil {
+		return "", fmt.Errorf("could not upload video: %w", err)
+	}
+	return resp.Id, nil
+}
@@ -0,0 +1,135 @@
+/*
+LICENSE
+  Copyright (C) 2026 the Australian Ocean Lab (AusOcean)
+
+  This is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  It is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  in gpl.txt. If not, see http://www.gnu.org/licenses/.
+*/
+
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// resumableServer is a minimal implementation of the resumable upload
+// protocol, as used with the X-GUploader-No-308 header, which fails
+// the first attempt at each chunk listed in fail.
+type resumableServer struct {
+	mu   sync.Mutex
+	data bytes.Buffer
+	fail map[int]bool
+	n    int // Number of chunk requests.
+}
+
+func (s *resumableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Query().Get("uploadType") == "resumable" {
+		w.Header().Set("Location", "http://"+r.Host+"/session")
+		return
+	}
+
+	s.n++
+	chunk := s.n
+	if s.fail[chunk] {
+		delete(s.fail, chunk)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	// Content-Range is "bytes first-last/total" or "bytes first-last/*".
+	var first, last int64
+	var total string
+	rng := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	if _, err := fmt.Sscanf(strings.Replace(rng, "-", " ", 1), "%d %d/%s", &first, &last, &total); err != nil {
+		// Zero-length final chunk, i.e., "bytes */total".
+		total = strings.TrimPrefix(rng, "*/")
+		first = int64(s.data.Len())
+	}
+	body, _ := io.ReadAll(r.Body)
+	if first == int64(s.data.Len()) {
+		s.data.Write(body)
+	}
+	if total == "*" || strconv.Itoa(s.data.Len()) != total {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", s.data.Len()-1))
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"id":"video1"}`)
+}
+
+// TestUpload tests chunked uploads, including retrying a failed chunk.
+func TestUpload(t *testing.T) {
+	const chunkSize = 256 << 10
+	media := bytes.Repeat([]byte("0123456789abcdef"), 3*chunkSize/16+100)
+
+	s := &resumableServer{fail: map[int]bool{2: true}}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ctx := context.Background()
+	svc, err := youtube.NewService(ctx, option.WithHTTPClient(ts.Client()), option.WithEndpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+
+	var sent []int64
+	id, err := Upload(ctx, svc, bytes.NewReader(media), VideoOptions{Title: "Timelapse", Privacy: "unlisted"}, UploadOptions{
+		ChunkSize: chunkSize,
+		Size:      int64(len(media)),
+		Progress:  func(n, total int64) { sent = append(sent, n) },
+	})
+	if err != nil {
+		t.Fatalf("could not upload: %v", err)
+	}
+	if id != "video1" {
+		t.Errorf("got video ID %s, want video1", id)
+	}
+	if !bytes.Equal(s.data.Bytes(), media) {
+		t.Errorf("uploaded %d bytes, want %d bytes", s.data.Len(), len(media))
+	}
+	if len(sent) == 0 || sent[len(sent)-1] != int64(len(media)) {
+		t.Errorf("got progress %v, want final progress of %d", sent, len(media))
+	}
+}
+
+// TestUploadCancel tests that cancelling the context aborts an upload.
+func TestUploadCancel(t *testing.T) {
+	ts := httptest.NewServer(&resumableServer{})
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	svc, err := youtube.NewService(ctx, option.WithHTTPClient(ts.Client()), option.WithEndpoint(ts.URL))
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+	cancel()
+	_, err = Upload(ctx, svc, strings.NewReader("video"), VideoOptions{Title: "Timelapse"}, UploadOptions{})
+	if err == nil {
+		t.Error("expected error for cancelled upload")
+	}
+}